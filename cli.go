@@ -0,0 +1,445 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc/mgr"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/driver"
+	"golang.zx2c4.com/wireguard/windows/manager"
+	"golang.zx2c4.com/wireguard/windows/ui"
+	"golang.zx2c4.com/wireguard/windows/updater"
+)
+
+const cliShutdownTimeout = 30 * time.Second
+
+// cliArgs splits the arguments following the command into positional arguments and the /json flag.
+func cliArgs() (args []string, asJSON bool) {
+	for _, arg := range os.Args[2:] {
+		if arg == "/json" {
+			asJSON = true
+		} else {
+			args = append(args, arg)
+		}
+	}
+	return
+}
+
+func cliStdout() (*os.File, error) {
+	outputHandle, err := windows.GetStdHandle(windows.STD_OUTPUT_HANDLE)
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Abrufen des stdout-Handles: %w", err)
+	}
+	if outputHandle == 0 {
+		return nil, errors.New("stdout muss gesetzt sein")
+	}
+	return os.NewFile(uintptr(outputHandle), "stdout"), nil
+}
+
+func cliStdin() (*os.File, error) {
+	inputHandle, err := windows.GetStdHandle(windows.STD_INPUT_HANDLE)
+	if err != nil {
+		return nil, fmt.Errorf("Fehler beim Abrufen des stdin-Handles: %w", err)
+	}
+	if inputHandle == 0 {
+		return nil, errors.New("stdin muss gesetzt sein")
+	}
+	return os.NewFile(uintptr(inputHandle), "stdin"), nil
+}
+
+func cliPrintKey(key *conf.Key) error {
+	file, err := cliStdout()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.WriteString(key.String() + "\n")
+	return err
+}
+
+// cliGenKey prints a new private key, like wg genkey.
+func cliGenKey() error {
+	if len(os.Args) != 2 {
+		usage()
+	}
+	key, err := conf.NewPrivateKey()
+	if err != nil {
+		return err
+	}
+	return cliPrintKey(key)
+}
+
+// cliGenPSK prints a new preshared key, like wg genpsk.
+func cliGenPSK() error {
+	if len(os.Args) != 2 {
+		usage()
+	}
+	key, err := conf.NewPresharedKey()
+	if err != nil {
+		return err
+	}
+	return cliPrintKey(key)
+}
+
+// cliPubKey reads a private key from standard input and prints its public key, like wg pubkey.
+func cliPubKey() error {
+	if len(os.Args) != 2 {
+		usage()
+	}
+	file, err := cliStdin()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	// A base64 key with a line ending, and whatever whitespace is around it, fits easily.
+	input, err := io.ReadAll(io.LimitReader(file, 1024))
+	if err != nil {
+		return fmt.Errorf("Konnte den privaten Schlüssel nicht von stdin lesen: %w", err)
+	}
+	key, err := conf.NewPrivateKeyFromString(strings.TrimSpace(string(input)))
+	if err != nil {
+		return errors.New("Der private Schlüssel auf stdin ist ungültig")
+	}
+	return cliPrintKey(key.Public())
+}
+
+func cliAutomationCall(request manager.AutomationRequest) (*manager.AutomationResponse, error) {
+	response, err := manager.AutomationCall(request)
+	if errors.Is(err, windows.ERROR_FILE_NOT_FOUND) {
+		return nil, errors.New("Der WireGuard-Managerdienst läuft nicht")
+	}
+	return response, err
+}
+
+// cliPrintTunnels prints the tunnels, preceded, unless asJSON, by the driver if it is given.
+func cliPrintTunnels(tunnels []manager.AutomationTunnel, driverInfo *manager.AutomationDriver, asJSON bool) error {
+	file, err := cliStdout()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if asJSON {
+		if tunnels == nil {
+			tunnels = []manager.AutomationTunnel{}
+		}
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(tunnels)
+	}
+	var output strings.Builder
+	if driverInfo != nil {
+		version := driverInfo.Version
+		if len(version) == 0 {
+			version = "not loaded"
+		}
+		fmt.Fprintf(&output, "driver: %s, features: %s\n", version, strings.Join(driverInfo.Features, ", "))
+	}
+	for _, tunnel := range tunnels {
+		fmt.Fprintf(&output, "%s: %s\n", tunnel.Name, tunnel.State)
+		if tunnel.LUID != 0 {
+			fmt.Fprintf(&output, "  adapter: luid %d, guid %s\n", tunnel.LUID, tunnel.GUID)
+		}
+		for _, peer := range tunnel.Peers {
+			if len(peer.Endpoint) > 0 {
+				fmt.Fprintf(&output, "  peer %s\n", peer.Endpoint)
+			} else {
+				output.WriteString("  peer\n")
+			}
+			if len(peer.AllowedIPs) > 0 {
+				fmt.Fprintf(&output, "    allowed ips: %s\n", strings.Join(peer.AllowedIPs, ", "))
+			}
+			if peer.LastHandshake > 0 {
+				handshake := time.Unix(peer.LastHandshake, 0)
+				fmt.Fprintf(&output, "    latest handshake: %s (%s ago)\n", handshake.Format(time.RFC3339), time.Since(handshake).Round(time.Second))
+			} else {
+				output.WriteString("    latest handshake: never\n")
+			}
+			fmt.Fprintf(&output, "    transfer: %s received, %s sent\n", conf.Bytes(peer.RxBytes), conf.Bytes(peer.TxBytes))
+		}
+	}
+	_, err = file.WriteString(output.String())
+	return err
+}
+
+func cliList() error {
+	args, asJSON := cliArgs()
+	if len(args) != 0 {
+		usage()
+	}
+	response, err := cliAutomationCall(manager.AutomationRequest{Method: "list"})
+	if err != nil {
+		return err
+	}
+	return cliPrintTunnels(response.Tunnels, nil, asJSON)
+}
+
+func cliStatus() error {
+	args, asJSON := cliArgs()
+	if len(args) > 1 {
+		usage()
+	}
+	var names []string
+	if len(args) == 1 {
+		names = args
+	} else {
+		response, err := cliAutomationCall(manager.AutomationRequest{Method: "list"})
+		if err != nil {
+			return err
+		}
+		for _, tunnel := range response.Tunnels {
+			names = append(names, tunnel.Name)
+		}
+	}
+	tunnels := make([]manager.AutomationTunnel, 0, len(names))
+	for _, name := range names {
+		response, err := cliAutomationCall(manager.AutomationRequest{Method: "stats", Tunnel: name})
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		tunnels = append(tunnels, response.Tunnels...)
+	}
+	response, err := cliAutomationCall(manager.AutomationRequest{Method: "driver"})
+	if err != nil {
+		return err
+	}
+	return cliPrintTunnels(tunnels, response.Driver, asJSON)
+}
+
+func cliSetState(method string) error {
+	args, _ := cliArgs()
+	if len(args) != 1 {
+		usage()
+	}
+	_, err := cliAutomationCall(manager.AutomationRequest{Method: method, Tunnel: args[0]})
+	return err
+}
+
+func cliShutdownManager() error {
+	args, _ := cliArgs()
+	if len(args) > 1 {
+		usage()
+	}
+	// Without a flag, the manager decides by the StopTunnelsOnShutdown admin registry key.
+	var stopTunnels *bool
+	if len(args) == 1 {
+		if args[0] != "/stoptunnels" && args[0] != "/keeptunnels" {
+			usage()
+		}
+		stop := args[0] == "/stoptunnels"
+		stopTunnels = &stop
+	}
+	_, err := cliAutomationCall(manager.AutomationRequest{Method: "shutdown", StopTunnels: stopTunnels})
+	if err != nil {
+		return err
+	}
+	return manager.WaitForManagerStop(cliShutdownTimeout)
+}
+
+func cliSyncConf() error {
+	args, _ := cliArgs()
+	if len(args) != 2 {
+		usage()
+	}
+	config, err := os.ReadFile(args[1])
+	if err != nil {
+		return err
+	}
+	_, err = cliAutomationCall(manager.AutomationRequest{Method: "syncconf", Tunnel: args[0], Config: string(config)})
+	return err
+}
+
+// cliValidationResult is what /validate prints with /json.
+type cliValidationResult struct {
+	Valid  bool   `json:"valid"`
+	Error  string `json:"error,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+	Key    string `json:"key,omitempty"`
+}
+
+// cliValidate parses a configuration file as importing it would, and points at what is wrong with
+// it, in the form path:line:column, without needing the manager service.
+func cliValidate() error {
+	args, asJSON := cliArgs()
+	if len(args) != 1 {
+		usage()
+	}
+	path := args[0]
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	_, err = conf.FromWgQuickWithUnknownEncoding(string(text), name)
+	result := cliValidationResult{Valid: err == nil}
+	var parseErr *conf.ParseError
+	if errors.As(err, &parseErr) {
+		result.Error, result.Line, result.Column, result.Key = parseErr.Description(), parseErr.Line, parseErr.Column, parseErr.Key
+		switch {
+		case parseErr.Column > 0:
+			err = fmt.Errorf("%s:%d:%d: %s", path, parseErr.Line, parseErr.Column, parseErr.Description())
+		case parseErr.Line > 0:
+			err = fmt.Errorf("%s:%d: %s", path, parseErr.Line, parseErr.Description())
+		default:
+			err = fmt.Errorf("%s: %s", path, parseErr.Description())
+		}
+	} else if err != nil {
+		result.Error = err.Error()
+	}
+	if asJSON {
+		file, fileErr := cliStdout()
+		if fileErr != nil {
+			return fileErr
+		}
+		defer file.Close()
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		if fileErr = encoder.Encode(result); fileErr != nil {
+			return fileErr
+		}
+	}
+	return err
+}
+
+// cliImportFromURL fetches a configuration file, or a zip of them, and adds them as tunnels,
+// printing those that were added.
+func cliImportFromURL() error {
+	args, asJSON := cliArgs()
+	var rawURL, digest string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "/sha256" && i+1 < len(args):
+			digest = args[i+1]
+			i++
+		case len(rawURL) == 0:
+			rawURL = args[i]
+		default:
+			usage()
+		}
+	}
+	if len(rawURL) == 0 {
+		usage()
+	}
+	configs, err := ui.FetchConfigs(rawURL, digest)
+	if err != nil {
+		return err
+	}
+	var tunnels []manager.AutomationTunnel
+	var lastErr error
+	for _, config := range configs {
+		response, err := cliAutomationCall(manager.AutomationRequest{Method: "import", Tunnel: config.Name, Config: config.Config})
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %w", config.Name, err)
+			continue
+		}
+		tunnels = append(tunnels, response.Tunnels...)
+	}
+	err = cliPrintTunnels(tunnels, nil, asJSON)
+	if lastErr != nil {
+		return fmt.Errorf("Konnte %d von %d Tunneln nicht importieren: %w", len(configs)-len(tunnels), len(configs), lastErr)
+	}
+	return err
+}
+
+// cliDiagnostics writes the diagnostics bundle of the manager to a zip file.
+func cliDiagnostics() error {
+	args, _ := cliArgs()
+	if len(args) != 1 {
+		usage()
+	}
+	response, err := cliAutomationCall(manager.AutomationRequest{Method: "diagnostics"})
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(args[0], response.Diagnostics, 0o600)
+	if err != nil {
+		return fmt.Errorf("Konnte das Diagnosepaket nicht schreiben: %w", err)
+	}
+	return nil
+}
+
+// cliUpdateProgress logs the progress of an update or rollback until it finishes.
+func cliUpdateProgress(progress chan updater.DownloadProgress) error {
+	for progress := range progress {
+		if len(progress.Activity) > 0 {
+			if progress.BytesTotal > 0 || progress.BytesDownloaded > 0 {
+				var percent float64
+				if progress.BytesTotal > 0 {
+					percent = float64(progress.BytesDownloaded) / float64(progress.BytesTotal) * 100.0
+				}
+				log.Printf("%s: %d/%d (%.2f%%)\n", progress.Activity, progress.BytesDownloaded, progress.BytesTotal, percent)
+			} else {
+				log.Println(progress.Activity)
+			}
+		}
+		if progress.Error != nil {
+			log.Printf("Fehler: %v\n", progress.Error)
+		}
+		if progress.Complete || progress.Error != nil {
+			return progress.Error
+		}
+	}
+	return nil
+}
+
+// cliCleanupAdapters reports, and unless /dryrun is given removes, adapters that no tunnel service
+// owns.
+func cliCleanupAdapters() error {
+	args, _ := cliArgs()
+	if len(args) > 1 || (len(args) == 1 && args[0] != "/dryrun") {
+		usage()
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	hasTunnel := func(name string) bool {
+		serviceName, err := conf.ServiceNameOfTunnel(name)
+		if err != nil {
+			return false
+		}
+		service, err := m.OpenService(serviceName)
+		if err != nil {
+			return false
+		}
+		service.Close()
+		return true
+	}
+	adapters, err := driver.OrphanedAdapters(hasTunnel)
+	if err != nil {
+		return err
+	}
+	if len(adapters) == 0 {
+		log.Println("No orphaned adapters found")
+		return nil
+	}
+	var lastErr error
+	for i := range adapters {
+		if len(args) == 1 {
+			log.Printf("Orphaned adapter: %v", &adapters[i])
+			continue
+		}
+		log.Printf("Removing orphaned adapter: %v", &adapters[i])
+		if err := adapters[i].Remove(); err != nil {
+			log.Printf("Unable to remove %s: %v", adapters[i].Name, err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
@@ -0,0 +1,136 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ActivationRules describe when the manager activates and deactivates a tunnel on its own,
+// based on the networks that the computer is connected to. They are kept apart from the
+// configuration itself, which remains compatible with wg-quick.
+type ActivationRules struct {
+	OnUntrustedWiFi bool     `json:"on_untrusted_wifi,omitempty"`
+	OnEthernet      bool     `json:"on_ethernet,omitempty"`
+	TrustedSSIDs    []string `json:"trusted_ssids,omitempty"`
+}
+
+// NetworkState is a summary of the networks that the computer is connected to.
+type NetworkState struct {
+	SSIDs    []string
+	Ethernet bool
+}
+
+func (state *NetworkState) String() string {
+	s := strings.Join(state.SSIDs, ", ")
+	if state.Ethernet {
+		if len(s) > 0 {
+			s += ", "
+		}
+		s += "Ethernet"
+	}
+	if len(s) == 0 {
+		return "no network"
+	}
+	return s
+}
+
+func (rules *ActivationRules) IsEmpty() bool {
+	return !rules.OnUntrustedWiFi && !rules.OnEthernet
+}
+
+// WantsActive reports whether the tunnel should be active on the given networks. Connecting to
+// an untrusted Wi-Fi network takes precedence over simultaneously being on a trusted one.
+func (rules *ActivationRules) WantsActive(state *NetworkState) bool {
+	if rules.OnEthernet && state.Ethernet {
+		return true
+	}
+	if !rules.OnUntrustedWiFi {
+		return false
+	}
+	for _, ssid := range state.SSIDs {
+		trusted := false
+		for _, trustedSSID := range rules.TrustedSSIDs {
+			if ssid == trustedSSID {
+				trusted = true
+				break
+			}
+		}
+		if !trusted {
+			return true
+		}
+	}
+	return false
+}
+
+func activationRulesPath(name string) (string, error) {
+	if !TunnelNameIsValid(name) {
+		return "", errors.New("Tunnel name is not valid")
+	}
+	root, err := RootDirectory(true)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, "Activation")
+	err = os.Mkdir(dir, os.ModeDir|0o700)
+	if err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// LoadActivationRules returns the activation rules of the named tunnel, which are empty if
+// none have been saved.
+func LoadActivationRules(name string) (*ActivationRules, error) {
+	path, err := activationRulesPath(name)
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &ActivationRules{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var rules ActivationRules
+	err = json.Unmarshal(bytes, &rules)
+	if err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// SaveActivationRules saves the activation rules of the named tunnel, or removes them if empty.
+func SaveActivationRules(name string, rules *ActivationRules) error {
+	if rules.IsEmpty() {
+		return DeleteActivationRules(name)
+	}
+	path, err := activationRulesPath(name)
+	if err != nil {
+		return err
+	}
+	bytes, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+	return writeLockedDownFile(path, true, bytes)
+}
+
+func DeleteActivationRules(name string) error {
+	path, err := activationRulesPath(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,60 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"testing"
+)
+
+func TestActivationRules(t *testing.T) {
+	rules := ActivationRules{OnUntrustedWiFi: true, TrustedSSIDs: []string{"home"}}
+	tests := []struct {
+		state NetworkState
+		want  bool
+	}{
+		{NetworkState{}, false},
+		{NetworkState{SSIDs: []string{"home"}}, false},
+		{NetworkState{SSIDs: []string{"cafe"}}, true},
+		{NetworkState{SSIDs: []string{"home", "cafe"}}, true},
+		{NetworkState{Ethernet: true}, false},
+	}
+	for _, test := range tests {
+		if got := rules.WantsActive(&test.state); got != test.want {
+			t.Errorf("WantsActive(%v) = %v, want %v", test.state.String(), got, test.want)
+		}
+	}
+
+	rules = ActivationRules{OnEthernet: true}
+	if !rules.WantsActive(&NetworkState{Ethernet: true}) {
+		t.Error("Ethernet rule did not activate on Ethernet")
+	}
+	if rules.WantsActive(&NetworkState{SSIDs: []string{"cafe"}}) {
+		t.Error("Ethernet rule activated on Wi-Fi")
+	}
+}
+
+func TestActivationRulesStorage(t *testing.T) {
+	rules := &ActivationRules{OnUntrustedWiFi: true, TrustedSSIDs: []string{"home", "office"}}
+	err := SaveActivationRules("golangTest", rules)
+	if err != nil {
+		t.Fatalf("Unable to save activation rules: %v", err)
+	}
+	loaded, err := LoadActivationRules("golangTest")
+	if err != nil {
+		t.Fatalf("Unable to load activation rules: %v", err)
+	}
+	if !loaded.OnUntrustedWiFi || len(loaded.TrustedSSIDs) != 2 {
+		t.Errorf("Loaded activation rules differ: %+v", loaded)
+	}
+	err = SaveActivationRules("golangTest", &ActivationRules{})
+	if err != nil {
+		t.Fatalf("Unable to clear activation rules: %v", err)
+	}
+	loaded, err = LoadActivationRules("golangTest")
+	if err != nil || !loaded.IsEmpty() {
+		t.Errorf("Cleared activation rules were not empty: %+v, %v", loaded, err)
+	}
+}
@@ -0,0 +1,108 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// AdapterLogLevel is how much of what the driver has to say about a tunnel ends up in the log.
+// Verbose includes handshakes and rekeying, and is the default.
+type AdapterLogLevel uint32
+
+const (
+	AdapterLogVerbose AdapterLogLevel = iota
+	AdapterLogErrors
+	AdapterLogOff
+)
+
+func (level AdapterLogLevel) String() string {
+	switch level {
+	case AdapterLogVerbose:
+		return "verbose"
+	case AdapterLogErrors:
+		return "errors"
+	case AdapterLogOff:
+		return "off"
+	default:
+		return "unknown"
+	}
+}
+
+func adapterLogLevelPath(name string) (string, error) {
+	if !TunnelNameIsValid(name) {
+		return "", errors.New("Tunnel name is not valid")
+	}
+	root, err := RootDirectory(true)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, "Adapter Logging")
+	err = os.Mkdir(dir, os.ModeDir|0o700)
+	if err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// LoadAdapterLogLevel returns the adapter log level of the named tunnel, which is the default of
+// the settings, itself verbose unless changed, if none has been saved.
+func LoadAdapterLogLevel(name string) (AdapterLogLevel, error) {
+	path, err := adapterLogLevelPath(name)
+	if err != nil {
+		return AdapterLogVerbose, err
+	}
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return defaultAdapterLogLevel(), nil
+	} else if err != nil {
+		return AdapterLogVerbose, err
+	}
+	var level AdapterLogLevel
+	err = json.Unmarshal(bytes, &level)
+	if err != nil {
+		return AdapterLogVerbose, err
+	}
+	if level > AdapterLogOff {
+		return AdapterLogVerbose, errors.New("Invalid adapter log level")
+	}
+	return level, nil
+}
+
+// SaveAdapterLogLevel saves the adapter log level of the named tunnel, or removes it if it is the
+// default, so that the tunnel follows the default when it changes.
+func SaveAdapterLogLevel(name string, level AdapterLogLevel) error {
+	if level > AdapterLogOff {
+		return errors.New("Invalid adapter log level")
+	}
+	if level == defaultAdapterLogLevel() {
+		return DeleteAdapterLogLevel(name)
+	}
+	path, err := adapterLogLevelPath(name)
+	if err != nil {
+		return err
+	}
+	bytes, err := json.Marshal(level)
+	if err != nil {
+		return err
+	}
+	return writeLockedDownFile(path, true, bytes)
+}
+
+func DeleteAdapterLogLevel(name string) error {
+	path, err := adapterLogLevelPath(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
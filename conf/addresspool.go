@@ -0,0 +1,269 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.zx2c4.com/wireguard/windows/l18n"
+)
+
+// Scanning stops after this many addresses, which matters only for pools spanning an IPv6 /64.
+const maxAddressScan = 1 << 16
+
+// AddressPools are the subnets from which a hub tunnel gives addresses to the clients that it
+// provisions, along with which client holds which address. Without pools of their own, hubs
+// give out addresses from the subnets of their interface addresses.
+type AddressPools struct {
+	Pools       []netip.Prefix      `json:"pools,omitempty"`
+	Allocations []AddressAllocation `json:"allocations,omitempty"`
+}
+
+// AddressAllocation records that an address belongs to the peer with the public key, whose
+// name is only for the operator's reference.
+type AddressAllocation struct {
+	Address   netip.Prefix `json:"address"`
+	PublicKey string       `json:"public_key"`
+	Name      string       `json:"name,omitempty"`
+}
+
+// AddressCollision is an address in a pool that is routed to or allocated for more than one peer.
+type AddressCollision struct {
+	Address    netip.Prefix
+	PublicKeys []string
+}
+
+func (pools *AddressPools) IsEmpty() bool {
+	return len(pools.Pools) == 0 && len(pools.Allocations) == 0
+}
+
+// effectivePools returns the configured pools, or the subnets of the hub's own addresses.
+// Redact removes the public keys of the allocations, like Config.Redact.
+func (pools *AddressPools) Redact() {
+	for i := range pools.Allocations {
+		pools.Allocations[i].PublicKey = ""
+	}
+}
+
+func (pools *AddressPools) effectivePools(conf *Config) []netip.Prefix {
+	if pools != nil && len(pools.Pools) > 0 {
+		return pools.Pools
+	}
+	subnets := make([]netip.Prefix, 0, len(conf.Interface.Addresses))
+	for _, a := range conf.Interface.Addresses {
+		subnets = append(subnets, a.Masked())
+	}
+	return subnets
+}
+
+// Prune forgets the allocations of peers that are no longer part of the configuration.
+func (pools *AddressPools) Prune(conf *Config) {
+	peers := make(map[string]bool, len(conf.Peers))
+	for i := range conf.Peers {
+		peers[conf.Peers[i].PublicKey.String()] = true
+	}
+	kept := pools.Allocations[:0]
+	for _, allocation := range pools.Allocations {
+		if peers[allocation.PublicKey] {
+			kept = append(kept, allocation)
+		}
+	}
+	pools.Allocations = kept
+}
+
+// owners returns who holds or is routed addr: the hub itself as the empty string, and peers by
+// their public keys. Peers whose allowed IPs reach beyond the pool, like an upstream with
+// 0.0.0.0/0, do not count.
+func (pools *AddressPools) owners(conf *Config, pool netip.Prefix, addr netip.Addr) []string {
+	var owners []string
+	for _, a := range conf.Interface.Addresses {
+		if a.Addr() == addr {
+			owners = append(owners, "")
+		}
+	}
+	for i := range conf.Peers {
+		for _, a := range conf.Peers[i].AllowedIPs {
+			if a.Bits() >= pool.Bits() && pool.Contains(a.Addr()) && a.Contains(addr) {
+				owners = append(owners, conf.Peers[i].PublicKey.String())
+				break
+			}
+		}
+	}
+	if pools != nil {
+		for _, allocation := range pools.Allocations {
+			if allocation.Address.Contains(addr) {
+				owners = append(owners, allocation.PublicKey)
+			}
+		}
+	}
+	return owners
+}
+
+// Collisions finds the addresses in the pools that are held by more than one peer, either
+// because two peers route them, or because one peer routes an address allocated to another.
+func (pools *AddressPools) Collisions(conf *Config) []AddressCollision {
+	candidates := make(map[netip.Addr]netip.Prefix)
+	for _, pool := range pools.effectivePools(conf) {
+		for i := range conf.Peers {
+			for _, a := range conf.Peers[i].AllowedIPs {
+				if a.Bits() >= pool.Bits() && pool.Contains(a.Addr()) {
+					candidates[a.Addr()] = pool
+				}
+			}
+		}
+		if pools != nil {
+			for _, allocation := range pools.Allocations {
+				if pool.Contains(allocation.Address.Addr()) {
+					candidates[allocation.Address.Addr()] = pool
+				}
+			}
+		}
+	}
+	var collisions []AddressCollision
+	for addr, pool := range candidates {
+		owners := pools.owners(conf, pool, addr)
+		distinct := make([]string, 0, len(owners))
+		seen := make(map[string]bool, len(owners))
+		for _, owner := range owners {
+			if !seen[owner] {
+				seen[owner] = true
+				distinct = append(distinct, owner)
+			}
+		}
+		if len(distinct) > 1 {
+			collisions = append(collisions, AddressCollision{netip.PrefixFrom(addr, addr.BitLen()), distinct})
+		}
+	}
+	sort.Slice(collisions, func(i, j int) bool {
+		return collisions[i].Address.Addr().Less(collisions[j].Address.Addr())
+	})
+	return collisions
+}
+
+// NextFree returns the lowest free host address, one of each family, from the pools.
+func (pools *AddressPools) NextFree(conf *Config) ([]netip.Prefix, error) {
+	var addresses []netip.Prefix
+	seenFamilies := make(map[bool]bool, 2)
+	for _, pool := range pools.effectivePools(conf) {
+		if seenFamilies[pool.Addr().Is4()] {
+			continue
+		}
+		last := netip.Addr{}
+		if pool.Addr().Is4() && pool.Bits() < 31 {
+			// Skip the broadcast address.
+			broadcast := pool.Addr().As4()
+			for i := pool.Bits(); i < 32; i++ {
+				broadcast[i/8] |= 0x80 >> (i % 8)
+			}
+			last = netip.AddrFrom4(broadcast)
+		}
+		addr := pool.Addr().Next()
+		for i := 0; i < maxAddressScan && addr.IsValid() && pool.Contains(addr) && addr != last; i++ {
+			if len(pools.owners(conf, pool, addr)) == 0 {
+				addresses = append(addresses, netip.PrefixFrom(addr, addr.BitLen()))
+				seenFamilies[pool.Addr().Is4()] = true
+				break
+			}
+			addr = addr.Next()
+		}
+	}
+	if len(addresses) == 0 {
+		return nil, errors.New(l18n.Sprintf("No free addresses are left in the address pools"))
+	}
+	return addresses, nil
+}
+
+// Allocate records that the addresses belong to the peer, failing if any of them is held by
+// another peer already.
+func (pools *AddressPools) Allocate(conf *Config, publicKey *Key, name string, addresses []netip.Prefix) error {
+	owner := publicKey.String()
+	for _, address := range addresses {
+		for _, pool := range pools.effectivePools(conf) {
+			if !pool.Contains(address.Addr()) {
+				continue
+			}
+			for _, other := range pools.owners(conf, pool, address.Addr()) {
+				if other != owner {
+					return errors.New(l18n.Sprintf("Address %s is already in use", address.String()))
+				}
+			}
+		}
+	}
+	for _, address := range addresses {
+		pools.Allocations = append(pools.Allocations, AddressAllocation{address, owner, name})
+	}
+	return nil
+}
+
+func addressPoolsPath(name string) (string, error) {
+	if !TunnelNameIsValid(name) {
+		return "", errors.New("Tunnel name is not valid")
+	}
+	root, err := RootDirectory(true)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, "Address Pools")
+	err = os.Mkdir(dir, os.ModeDir|0o700)
+	if err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// LoadAddressPools returns the address pools of the named tunnel, which are empty if none have
+// been saved.
+func LoadAddressPools(name string) (*AddressPools, error) {
+	path, err := addressPoolsPath(name)
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &AddressPools{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var pools AddressPools
+	err = json.Unmarshal(bytes, &pools)
+	if err != nil {
+		return nil, err
+	}
+	return &pools, nil
+}
+
+// SaveAddressPools saves the address pools of the named tunnel, or removes them if empty.
+func SaveAddressPools(name string, pools *AddressPools) error {
+	if pools.IsEmpty() {
+		return DeleteAddressPools(name)
+	}
+	path, err := addressPoolsPath(name)
+	if err != nil {
+		return err
+	}
+	bytes, err := json.Marshal(pools)
+	if err != nil {
+		return err
+	}
+	return writeLockedDownFile(path, true, bytes)
+}
+
+func DeleteAddressPools(name string) error {
+	path, err := addressPoolsPath(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
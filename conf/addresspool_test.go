@@ -0,0 +1,105 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestAddressPoolsNextFree(t *testing.T) {
+	hub, err := FromWgQuick(testHubInput, "hub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var none *AddressPools
+	addresses, err := none.NextFree(hub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []netip.Prefix{netip.MustParsePrefix("10.0.0.3/32"), netip.MustParsePrefix("fd00::3/128")}
+	if len(addresses) != len(expected) || addresses[0] != expected[0] || addresses[1] != expected[1] {
+		t.Errorf("Allocated %v, expected %v", addresses, expected)
+	}
+
+	pools := &AddressPools{
+		Pools:       []netip.Prefix{netip.MustParsePrefix("10.0.0.0/29")},
+		Allocations: []AddressAllocation{{netip.MustParsePrefix("10.0.0.3/32"), "TrMvSoP4jYQlY6RIzBgbssQqY3vxI2Pi+y71lOWWXX0=", "phone"}},
+	}
+	addresses, err = pools.NextFree(hub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addresses) != 1 || addresses[0] != netip.MustParsePrefix("10.0.0.6/32") {
+		t.Errorf("Allocated %v, expected 10.0.0.6/32", addresses)
+	}
+
+	hub.Peers[0].AllowedIPs = append(hub.Peers[0].AllowedIPs, netip.MustParsePrefix("10.0.0.6/32"))
+	_, err = pools.NextFree(hub)
+	if err == nil {
+		t.Error("Allocating from a full pool should fail")
+	}
+}
+
+func TestAddressPoolsAllocate(t *testing.T) {
+	hub, err := FromWgQuick(testHubInput, "hub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pools := &AddressPools{}
+	err = pools.Allocate(hub, key.Public(), "laptop", []netip.Prefix{netip.MustParsePrefix("10.0.0.2/32")})
+	if err == nil {
+		t.Error("Allocating an address routed to another peer should fail")
+	}
+	err = pools.Allocate(hub, key.Public(), "laptop", []netip.Prefix{netip.MustParsePrefix("10.0.0.3/32")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collisions := pools.Collisions(hub); len(collisions) != 0 {
+		t.Errorf("Unexpected collisions %v", collisions)
+	}
+
+	hub.Peers[1].AllowedIPs = append(hub.Peers[1].AllowedIPs, netip.MustParsePrefix("10.0.0.3/32"))
+	collisions := pools.Collisions(hub)
+	if len(collisions) != 1 || collisions[0].Address != netip.MustParsePrefix("10.0.0.3/32") || len(collisions[0].PublicKeys) != 2 {
+		t.Errorf("Collisions are %v, expected one on 10.0.0.3/32", collisions)
+	}
+
+	pools.Prune(hub)
+	if len(pools.Allocations) != 0 {
+		t.Errorf("Allocations of missing peers were kept: %v", pools.Allocations)
+	}
+}
+
+func TestAddressPoolsStorage(t *testing.T) {
+	pools := &AddressPools{
+		Pools:       []netip.Prefix{netip.MustParsePrefix("10.0.0.0/24")},
+		Allocations: []AddressAllocation{{netip.MustParsePrefix("10.0.0.2/32"), "TrMvSoP4jYQlY6RIzBgbssQqY3vxI2Pi+y71lOWWXX0=", "phone"}},
+	}
+	err := SaveAddressPools("golangTest", pools)
+	if err != nil {
+		t.Fatalf("Unable to save address pools: %v", err)
+	}
+	loaded, err := LoadAddressPools("golangTest")
+	if err != nil {
+		t.Fatalf("Unable to load address pools: %v", err)
+	}
+	if len(loaded.Pools) != 1 || loaded.Pools[0] != pools.Pools[0] || len(loaded.Allocations) != 1 || loaded.Allocations[0] != pools.Allocations[0] {
+		t.Errorf("Loaded %+v, expected %+v", loaded, pools)
+	}
+	err = SaveAddressPools("golangTest", &AddressPools{})
+	if err != nil {
+		t.Fatalf("Unable to save empty address pools: %v", err)
+	}
+	loaded, err = LoadAddressPools("golangTest")
+	if err != nil || !loaded.IsEmpty() {
+		t.Errorf("Saving empty address pools did not remove them: %+v, %v", loaded, err)
+	}
+}
@@ -34,3 +34,33 @@ func AdminBool(name string) bool {
 	}
 	return val != 0
 }
+
+// AdminStrings returns the strings of a REG_MULTI_SZ value, or of a REG_SZ value as a single string.
+func AdminStrings(name string) []string {
+	key, err := openAdminKey()
+	if err != nil {
+		return nil
+	}
+	vals, _, err := key.GetStringsValue(name)
+	if err == registry.ErrUnexpectedType {
+		var val string
+		val, _, err = key.GetStringValue(name)
+		vals = []string{val}
+	}
+	if err != nil {
+		return nil
+	}
+	return vals
+}
+
+func AdminInteger(name string) uint64 {
+	key, err := openAdminKey()
+	if err != nil {
+		return 0
+	}
+	val, _, err := key.GetIntegerValue(name)
+	if err != nil {
+		return 0
+	}
+	return val
+}
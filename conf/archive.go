@@ -0,0 +1,267 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Archives of all tunnels are zips of their configurations, with each entry encrypted with a
+// passphrase in the WinZip AES format, which tools such as 7-Zip open as well. The private keys
+// in them are therefore only as safe as the passphrase, instead of being bound to this computer
+// with DPAPI.
+
+const (
+	// MaxArchiveSize is the largest archive that will be read.
+	MaxArchiveSize = 64 * 1024 * 1024
+	// MaxTunnelsPerImport is the most tunnels that one import, of an archive or of files, may add,
+	// since thousands at once are almost certainly a mistake, and would keep the manager service
+	// and the UI busy for a long time.
+	MaxTunnelsPerImport = 250
+
+	zipMethodStore     = 0
+	zipMethodDeflate   = 8
+	zipMethodWinZipAES = 99
+	zipExtraWinZipAES  = 0x9901
+
+	winZipAESVersion    = 2 // AE-2, which authenticates the data instead of storing its CRC.
+	winZipAESStrength   = 3 // AES-256.
+	winZipAESIterations = 1000
+	winZipAESVerifySize = 2
+	winZipAESAuthSize   = 10
+)
+
+var ErrArchivePassphrase = errors.New("The passphrase is wrong, or the archive is damaged")
+
+// winZipAESKeys derives the encryption key, authentication key, and passphrase verifier of an
+// entry from its salt.
+func winZipAESKeys(passphrase string, salt []byte, keySize int) (encryptionKey, authKey, verifier []byte) {
+	k := pbkdf2.Key([]byte(passphrase), salt, winZipAESIterations, 2*keySize+winZipAESVerifySize, sha1.New)
+	return k[:keySize], k[keySize : 2*keySize], k[2*keySize:]
+}
+
+// winZipAESCrypt encrypts or decrypts data in place with AES in counter mode, using the
+// little-endian counter, starting at one, of the WinZip format rather than that of cipher.NewCTR.
+func winZipAESCrypt(key, data []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	var counter, stream [aes.BlockSize]byte
+	for i := 0; i < len(data); i += aes.BlockSize {
+		for j := range counter {
+			counter[j]++
+			if counter[j] != 0 {
+				break
+			}
+		}
+		block.Encrypt(stream[:], counter[:])
+		n := len(data) - i
+		if n > aes.BlockSize {
+			n = aes.BlockSize
+		}
+		subtle.XORBytes(data[i:i+n], data[i:i+n], stream[:n])
+	}
+	return nil
+}
+
+// WriteArchive writes the configurations to w as a zip of .conf files encrypted with passphrase.
+func WriteArchive(w io.Writer, configs []*Config, passphrase string) error {
+	if len(passphrase) == 0 {
+		return errors.New("Passphrase is empty")
+	}
+	writer := zip.NewWriter(w)
+	for _, config := range configs {
+		text := []byte(config.ToWgQuick())
+		var data bytes.Buffer
+		compressor, err := flate.NewWriter(&data, flate.DefaultCompression)
+		if err != nil {
+			return err
+		}
+		if _, err = compressor.Write(text); err != nil {
+			return err
+		}
+		if err = compressor.Close(); err != nil {
+			return err
+		}
+
+		keySize := 8 + 8*winZipAESStrength
+		salt := make([]byte, keySize/2)
+		if _, err = rand.Read(salt); err != nil {
+			return err
+		}
+		encryptionKey, authKey, verifier := winZipAESKeys(passphrase, salt, keySize)
+		if err = winZipAESCrypt(encryptionKey, data.Bytes()); err != nil {
+			return err
+		}
+		mac := hmac.New(sha1.New, authKey)
+		mac.Write(data.Bytes())
+
+		extra := binary.LittleEndian.AppendUint16(nil, zipExtraWinZipAES)
+		extra = binary.LittleEndian.AppendUint16(extra, 7)
+		extra = binary.LittleEndian.AppendUint16(extra, winZipAESVersion)
+		extra = append(extra, 'A', 'E', winZipAESStrength)
+		extra = binary.LittleEndian.AppendUint16(extra, zipMethodDeflate)
+		header := &zip.FileHeader{
+			Name:               config.Name + ".conf",
+			Method:             zipMethodWinZipAES,
+			Flags:              0x1, // Encrypted
+			Extra:              extra,
+			CompressedSize64:   uint64(len(salt) + len(verifier) + data.Len() + winZipAESAuthSize),
+			UncompressedSize64: uint64(len(text)),
+		}
+		// Unlike CreateHeader, CreateRaw leaves the MS-DOS time fields alone.
+		header.SetModTime(time.Now())
+		entry, err := writer.CreateRaw(header)
+		if err != nil {
+			return err
+		}
+		for _, b := range [][]byte{salt, verifier, data.Bytes(), mac.Sum(nil)[:winZipAESAuthSize]} {
+			if _, err = entry.Write(b); err != nil {
+				return err
+			}
+		}
+	}
+	return writer.Close()
+}
+
+// winZipAESParameters returns the strength and the method of compression from the WinZip AES
+// extra field of an entry.
+func winZipAESParameters(extra []byte) (strength byte, method uint16, ok bool) {
+	for len(extra) >= 4 {
+		id, size := binary.LittleEndian.Uint16(extra), int(binary.LittleEndian.Uint16(extra[2:]))
+		extra = extra[4:]
+		if size > len(extra) {
+			break
+		}
+		if id == zipExtraWinZipAES && size == 7 && extra[2] == 'A' && extra[3] == 'E' {
+			return extra[4], binary.LittleEndian.Uint16(extra[5:]), true
+		}
+		extra = extra[size:]
+	}
+	return
+}
+
+// ArchiveIsEncrypted reports whether any of the .conf files in the zip are encrypted, in which
+// case it needs to be read with ReadArchive.
+func ArchiveIsEncrypted(r *zip.Reader) bool {
+	for _, f := range r.File {
+		if strings.ToLower(path.Ext(f.Name)) == ".conf" && f.Method == zipMethodWinZipAES {
+			return true
+		}
+	}
+	return false
+}
+
+func readArchiveEntry(f *zip.File, passphrase string) ([]byte, error) {
+	strength, method, ok := winZipAESParameters(f.Extra)
+	if f.Method != zipMethodWinZipAES || !ok || strength < 1 || strength > 3 {
+		return nil, errors.New("Configuration file is not encrypted with a passphrase")
+	}
+	if method != zipMethodStore && method != zipMethodDeflate {
+		return nil, zip.ErrAlgorithm
+	}
+	if f.CompressedSize64 > MaxConfigFileSize || f.UncompressedSize64 > MaxConfigFileSize {
+		return nil, errors.New("Configuration file is too large")
+	}
+	raw, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(raw)
+	if err != nil {
+		return nil, err
+	}
+	keySize := 8 + 8*int(strength)
+	saltSize := keySize / 2
+	if len(data) < saltSize+winZipAESVerifySize+winZipAESAuthSize {
+		return nil, ErrArchivePassphrase
+	}
+	salt, data := data[:saltSize], data[saltSize:]
+	encryptionKey, authKey, verifier := winZipAESKeys(passphrase, salt, keySize)
+	if subtle.ConstantTimeCompare(data[:winZipAESVerifySize], verifier) != 1 {
+		return nil, ErrArchivePassphrase
+	}
+	data, authCode := data[winZipAESVerifySize:len(data)-winZipAESAuthSize], data[len(data)-winZipAESAuthSize:]
+	mac := hmac.New(sha1.New, authKey)
+	mac.Write(data)
+	if !hmac.Equal(mac.Sum(nil)[:winZipAESAuthSize], authCode) {
+		return nil, ErrArchivePassphrase
+	}
+	if err = winZipAESCrypt(encryptionKey, data); err != nil {
+		return nil, err
+	}
+	if method == zipMethodStore {
+		return data, nil
+	}
+	decompressor := flate.NewReader(bytes.NewReader(data))
+	defer decompressor.Close()
+	text, err := io.ReadAll(io.LimitReader(decompressor, MaxConfigFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(text) > MaxConfigFileSize {
+		return nil, errors.New("Configuration file is too large")
+	}
+	return text, nil
+}
+
+// ReadArchive decrypts and parses the .conf files of a zip written by WriteArchive, or by other
+// tools that use the WinZip AES format, naming each configuration after its file.
+func ReadArchive(archive []byte, passphrase string) ([]*Config, error) {
+	if len(archive) > MaxArchiveSize {
+		return nil, errors.New("Archive is too large")
+	}
+	r, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, err
+	}
+	entries := 0
+	for _, f := range r.File {
+		if strings.ToLower(path.Ext(f.Name)) == ".conf" {
+			entries++
+		}
+	}
+	if entries > MaxTunnelsPerImport {
+		return nil, fmt.Errorf("Archive has more than %d configuration files", MaxTunnelsPerImport)
+	}
+	var configs []*Config
+	for _, f := range r.File {
+		if strings.ToLower(path.Ext(f.Name)) != ".conf" {
+			continue
+		}
+		text, err := readArchiveEntry(f, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		base := path.Base(f.Name)
+		config, err := FromWgQuickWithUnknownEncoding(string(text), strings.TrimSuffix(base, path.Ext(base)))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		configs = append(configs, config)
+	}
+	if len(configs) == 0 {
+		return nil, errors.New("No configuration files were found")
+	}
+	return configs, nil
+}
@@ -0,0 +1,78 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	var configs []*Config
+	for _, name := range []string{"office", "home"} {
+		config, err := FromWgQuick(testInput, name)
+		if err != nil {
+			t.Fatalf("Unable to parse configuration: %v", err)
+		}
+		configs = append(configs, config)
+	}
+	var archive bytes.Buffer
+	if err := WriteArchive(&archive, configs, "correct horse"); err != nil {
+		t.Fatalf("Unable to write archive: %v", err)
+	}
+	if bytes.Contains(archive.Bytes(), []byte(configs[0].Interface.PrivateKey.String())) {
+		t.Error("Archive contains a private key in the clear")
+	}
+
+	read, err := ReadArchive(archive.Bytes(), "correct horse")
+	if err != nil {
+		t.Fatalf("Unable to read archive: %v", err)
+	}
+	if len(read) != len(configs) {
+		t.Fatalf("Read %d configurations, want %d", len(read), len(configs))
+	}
+	for i := range read {
+		if read[i].Name != configs[i].Name || read[i].ToWgQuick() != configs[i].ToWgQuick() {
+			t.Errorf("Configuration %d does not survive the round trip", i)
+		}
+	}
+
+	if _, err = ReadArchive(archive.Bytes(), "wrong horse"); !errors.Is(err, ErrArchivePassphrase) {
+		t.Errorf("Wrong passphrase gives %v, want %v", err, ErrArchivePassphrase)
+	}
+	r, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatalf("Unable to open archive: %v", err)
+	}
+	offset, err := r.File[0].DataOffset()
+	if err != nil {
+		t.Fatalf("Unable to find entry data: %v", err)
+	}
+	damaged := bytes.Clone(archive.Bytes())
+	damaged[offset+20] ^= 0xff // Past the salt and verifier.
+	if _, err = ReadArchive(damaged, "correct horse"); err == nil {
+		t.Error("Damaged archive was read")
+	}
+}
+
+func TestArchiveTooManyConfigurations(t *testing.T) {
+	var archive bytes.Buffer
+	w := zip.NewWriter(&archive)
+	for i := 0; i <= MaxTunnelsPerImport; i++ {
+		if _, err := w.Create(fmt.Sprintf("tunnel%d.conf", i)); err != nil {
+			t.Fatalf("Unable to write archive: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Unable to write archive: %v", err)
+	}
+	if _, err := ReadArchive(archive.Bytes(), "correct horse"); err == nil {
+		t.Errorf("Archive with more than %d configurations was read", MaxTunnelsPerImport)
+	}
+}
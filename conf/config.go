@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"golang.org/x/crypto/curve25519"
+	"golang.org/x/sys/windows"
 
 	"golang.zx2c4.com/wireguard/windows/l18n"
 )
@@ -45,17 +46,76 @@ type Interface struct {
 	MTU        uint16
 	DNS        []netip.Addr
 	DNSSearch  []string
-	PreUp      string
-	PostUp     string
-	PreDown    string
-	PostDown   string
+	PreUp      []string // Commands, run in order, for each of which the key may be repeated.
+	PostUp     []string
+	PreDown    []string
+	PostDown   []string
 	TableOff   bool
+	KillSwitch bool
+
+	// How long each of the scripts above may run, in seconds, before it is killed along with
+	// everything it started. Zero is the default of 30 seconds.
+	ScriptTimeout uint16
+
+	DisableTemporaryAddresses bool
+	DisableDAD                bool
+
+	// Overrides of the metrics that Windows would choose, for telling it which of several tunnels
+	// with overlapping routes is to carry the traffic. Zero leaves the choice to Windows.
+	InterfaceMetric uint32
+	RouteMetric     uint32
+
+	// Traffic that stays outside of the tunnel: destinations that are not routed into it even
+	// though the allowed IPs cover them, and ports and programs that the firewall lets through.
+	ExcludeIPs   []netip.Prefix
+	ExcludePorts []uint16
+	ExcludeApps  []string
+
+	// Whether the private networks of the other interfaces, and local discovery with mDNS and SSDP,
+	// stay reachable outside of a tunnel with a default route.
+	AllowLocalNetwork bool
+
+	// The GUID, alias, or description of the interface that the encrypted packets are to leave
+	// through, whatever the default route says.
+	BindInterface string
+
+	// A host, with a port to connect to or without one to ping, that is to be reachable through the
+	// tunnel once it is up, and the seconds to keep trying for, zero being the default of 10.
+	CheckHost    string
+	CheckTimeout uint16
+
+	// The GUID that the adapter is to have, rather than the one derived from the configuration,
+	// for firewall and monitoring rules that must keep matching as the configuration changes.
+	AdapterGUID *windows.GUID
+
+	// An https URL of a signed document with more peers, which the tunnel service fetches
+	// periodically while the tunnel runs, and the signify public key that signs it, as described
+	// by ReadPeerSourceDocument.
+	PeerSource    string
+	PeerSourceKey string
+
+	Obfuscation Obfuscation
+}
+
+// Obfuscation holds the junk packet and header parameters of AmneziaWG, an obfuscating fork of
+// WireGuard. The driver does not implement them, but they are kept, so that such configurations
+// can be imported and saved without losing them.
+type Obfuscation struct {
+	Jc, Jmin, Jmax uint16
+	S1, S2         uint16
+	H1, H2, H3, H4 uint32
+}
+
+func (o *Obfuscation) IsEmpty() bool {
+	return *o == Obfuscation{}
 }
 
 type Peer struct {
+	Name                string // An alias, from a Name key or a "# Name = …" comment in the section.
 	PublicKey           Key
 	PresharedKey        Key
 	AllowedIPs          []netip.Prefix
+	DisallowedIPs       []netip.Prefix // Already taken out of AllowedIPs, and only kept to be written out again.
 	Endpoint            Endpoint
 	PersistentKeepalive uint16
 
@@ -64,6 +124,15 @@ type Peer struct {
 	LastHandshakeTime HandshakeTime
 }
 
+// DisplayName is the alias of the peer if it has one, and otherwise its public key, shortened.
+func (peer *Peer) DisplayName() string {
+	if len(peer.Name) > 0 {
+		return peer.Name
+	}
+	key := peer.PublicKey.String()
+	return key[:4] + "…" + key[len(key)-5:len(key)-1]
+}
+
 func (conf *Config) IntersectsWith(other *Config) bool {
 	allRoutes := make(map[netip.Prefix]bool, len(conf.Interface.Addresses)*2+len(conf.Peers)*3)
 	for _, a := range conf.Interface.Addresses {
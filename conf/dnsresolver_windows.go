@@ -2,7 +2,9 @@ package conf
 
 import (
     "context"
+    "errors"
     "log"
+    "net"
     "net/netip"
     "sync"
     "time"
@@ -18,8 +20,10 @@ var dnsCache = struct {
     entries map[string]cachedDNS
 }{entries: make(map[string]cachedDNS)}
 
+// Host names are cached with all of their addresses, so that the one to use is chosen anew for
+// the network at hand each time.
 type cachedDNS struct {
-    ip        string
+    addrs     []netip.Addr
     timestamp time.Time
 }
 
@@ -28,11 +32,34 @@ const (
     dnsTimeout       = 10 * time.Second
 )
 
-func resolveHostname(name string) (resolvedIPString string, err error) {
+// endpointRoutable reports whether the network has a route and a source address for the
+// endpoint, by connecting a UDP socket to it, which sends nothing.
+func endpointRoutable(endpoint netip.AddrPort) bool {
+    conn, err := net.DialUDP("udp", nil, net.UDPAddrFromAddrPort(endpoint))
+    if err != nil {
+        return false
+    }
+    conn.Close()
+    return true
+}
+
+func resolveHostname(name string, port uint16, routable func(netip.AddrPort) bool) (resolvedIPString string, err error) {
+    addrs, err := resolveHostnameAddrs(name)
+    if err != nil {
+        return "", err
+    }
+    addr, ok := selectEndpointAddr(addrs, port, routable)
+    if !ok {
+        return "", windows.WSAHOST_NOT_FOUND
+    }
+    return addr.String(), nil
+}
+
+func resolveHostnameAddrs(name string) ([]netip.Addr, error) {
     dnsCache.RLock()
     if entry, exists := dnsCache.entries[name]; exists && time.Since(entry.timestamp) < dnsCacheDuration {
         dnsCache.RUnlock()
-        return entry.ip, nil
+        return entry.addrs, nil
     }
     dnsCache.RUnlock()
 
@@ -51,16 +78,16 @@ func resolveHostname(name string) (resolvedIPString string, err error) {
         defer cancel()
 
         resultCh := make(chan struct {
-            ip  string
-            err error
+            addrs []netip.Addr
+            err   error
         }, 1)
 
         go func() {
-            ip, err := resolveHostnameOnce(name)
+            addrs, err := resolveHostnameOnce(name)
             resultCh <- struct {
-                ip  string
-                err error
-            }{ip, err}
+                addrs []netip.Addr
+                err   error
+            }{addrs, err}
         }()
 
         select {
@@ -68,11 +95,11 @@ func resolveHostname(name string) (resolvedIPString string, err error) {
             if result.err == nil {
                 dnsCache.Lock()
                 dnsCache.entries[name] = cachedDNS{
-                    ip:        result.ip,
+                    addrs:     result.addrs,
                     timestamp: time.Now(),
                 }
                 dnsCache.Unlock()
-                return result.ip, nil
+                return result.addrs, nil
             }
             log.Printf("DNS resolution failed for %s: %v (attempt %d/%d)", name, result.err, i+1, maxTries)
             if result.err == windows.WSATRY_AGAIN {
@@ -81,16 +108,79 @@ func resolveHostname(name string) (resolvedIPString string, err error) {
             if result.err == windows.WSAHOST_NOT_FOUND && services.StartedAtBoot() {
                 continue
             }
-            return "", result.err
+            return nil, result.err
         case <-resolveCtx.Done():
             log.Printf("DNS resolution timeout for %s (attempt %d/%d)", name, i+1, maxTries)
             continue
         }
     }
-    return "", windows.WSAHOST_NOT_FOUND
+    return nil, windows.WSAHOST_NOT_FOUND
+}
+
+// The DNS over HTTPS resolver is kept along with the registry values it was made from, so that
+// its connections are reused across lookups. The values are read again once they are older than
+// dnsCacheDuration, and the resolver is only replaced when they have changed.
+var dohResolverCache struct {
+    sync.Mutex
+    url, pin string
+    resolver *dohResolver
+    err      error
+    read     time.Time
 }
 
-func resolveHostnameOnce(name string) (resolvedIPString string, err error) {
+// configuredDoHResolver returns the DNS over HTTPS resolver set by the EndpointDoHURL admin
+// registry key, or nil if there is none.
+func configuredDoHResolver() (*dohResolver, error) {
+    cache := &dohResolverCache
+    cache.Lock()
+    defer cache.Unlock()
+    if !cache.read.IsZero() && time.Since(cache.read) < dnsCacheDuration {
+        return cache.resolver, cache.err
+    }
+    var url, pin string
+    if urls := AdminStrings("EndpointDoHURL"); len(urls) > 0 {
+        url = urls[0]
+    }
+    if pins := AdminStrings("EndpointDoHCertificate"); len(pins) > 0 {
+        pin = pins[0]
+    }
+    changed := cache.read.IsZero() || url != cache.url || pin != cache.pin
+    cache.read = time.Now()
+    if !changed {
+        return cache.resolver, cache.err
+    }
+    if cache.resolver != nil {
+        cache.resolver.client.CloseIdleConnections()
+    }
+    cache.url, cache.pin = url, pin
+    if len(url) == 0 {
+        cache.resolver, cache.err = nil, nil
+    } else {
+        cache.resolver, cache.err = newDoHResolver(url, pin)
+    }
+    return cache.resolver, cache.err
+}
+
+func resolveHostnameOnce(name string) (addrs []netip.Addr, err error) {
+    if addr, err := netip.ParseAddr(name); err == nil {
+        return []netip.Addr{addr}, nil
+    }
+    // A configured resolver replaces the system's, which is not fallen back to, since the point
+    // is not to trust the answers of the network.
+    resolver, err := configuredDoHResolver()
+    if err != nil {
+        return nil, err
+    }
+    if resolver != nil {
+        addrs, err = resolver.lookup(name)
+        if errors.Is(err, errDoHNotFound) {
+            return nil, windows.WSAHOST_NOT_FOUND
+        } else if errors.Is(err, errDoHTemporary) {
+            log.Printf("DNS over HTTPS lookup of %s failed: %v", name, err)
+            return nil, windows.WSATRY_AGAIN
+        }
+        return addrs, err
+    }
     hints := windows.AddrinfoW{
         Family:   windows.AF_UNSPEC,
         Socktype: windows.SOCK_DGRAM,
@@ -110,26 +200,32 @@ func resolveHostnameOnce(name string) (resolvedIPString string, err error) {
         return
     }
     defer windows.FreeAddrInfoW(result)
-    var v6 netip.Addr
+    seen := make(map[netip.Addr]bool)
     for ; result != nil; result = result.Next {
         if result.Family != windows.AF_INET && result.Family != windows.AF_INET6 {
             continue
         }
         addr := (*winipcfg.RawSockaddrInet)(unsafe.Pointer(result.Addr)).Addr()
-        if addr.Is4() {
-            return addr.String(), nil
-        } else if !v6.IsValid() && addr.Is6() {
-            v6 = addr
+        if addr.IsValid() && !seen[addr] {
+            seen[addr] = true
+            addrs = append(addrs, addr)
         }
     }
-    if v6.IsValid() {
-        return v6.String(), nil
+    if len(addrs) == 0 {
+        err = windows.WSAHOST_NOT_FOUND
     }
-    err = windows.WSAHOST_NOT_FOUND
     return
 }
 
+// ResolveEndpoints replaces the host names of endpoints with addresses, preferring those of a
+// family that the network can reach, in the manner of RFC 8305.
 func (config *Config) ResolveEndpoints() error {
+    return config.ResolveEndpointsWith(endpointRoutable)
+}
+
+// ResolveEndpointsWith is like ResolveEndpoints, but with routable telling which addresses the
+// network can reach, for callers whose own routes would otherwise mislead endpointRoutable.
+func (config *Config) ResolveEndpointsWith(routable func(netip.AddrPort) bool) error {
     var wg sync.WaitGroup
     var mu sync.Mutex
     var firstErr error
@@ -141,7 +237,7 @@ func (config *Config) ResolveEndpoints() error {
         wg.Add(1)
         go func(i int) {
             defer wg.Done()
-            resolved, err := resolveHostname(config.Peers[i].Endpoint.Host)
+            resolved, err := resolveHostname(config.Peers[i].Endpoint.Host, config.Peers[i].Endpoint.Port, routable)
             if err != nil {
                 mu.Lock()
                 if firstErr == nil {
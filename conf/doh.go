@@ -0,0 +1,228 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Endpoints may be resolved with DNS over HTTPS, as RFC 8484 describes, rather than with the
+// system's resolver, for networks that tamper with DNS before the tunnel is up. The resolver is
+// best given by address, since its own host name would be resolved by the system.
+
+const (
+	dohTimeout         = 10 * time.Second
+	dohIdleTimeout     = 90 * time.Second
+	maxDoHResponseSize = 64 * 1024
+
+	dnsTypeA    = 1
+	dnsTypeAAAA = 28
+	dnsClassIN  = 1
+
+	dnsRcodeNameError = 3
+)
+
+var (
+	errDoHNotFound  = errors.New("Host name does not exist")
+	errDoHTemporary = errors.New("DNS over HTTPS resolver is unavailable")
+)
+
+type dohResolver struct {
+	url    string
+	client *http.Client
+}
+
+// newDoHResolver returns a resolver that queries the https URL. If pin is not empty, it is the
+// hex SHA-256 digest of the certificate of the resolver, which is then trusted instead of the
+// certificate authorities of the system, so that resolvers with their own certificates work too.
+func newDoHResolver(rawURL, pin string) (*dohResolver, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" || len(u.Host) == 0 {
+		return nil, errors.New("DNS over HTTPS resolver must be an https URL")
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(pin) > 0 {
+		digest, err := hex.DecodeString(strings.ReplaceAll(strings.TrimSpace(pin), ":", ""))
+		if err != nil || len(digest) != sha256.Size {
+			return nil, errors.New("Pinned certificate digest is not valid")
+		}
+		tlsConfig.InsecureSkipVerify = true // The pin below stands in for the usual verification.
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("Resolver presented no certificate")
+			}
+			if leaf := sha256.Sum256(rawCerts[0]); !bytes.Equal(leaf[:], digest) {
+				return errors.New("Resolver certificate does not match the pinned digest")
+			}
+			return nil
+		}
+	}
+	return &dohResolver{
+		url: u.String(),
+		client: &http.Client{
+			Timeout:   dohTimeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, ForceAttemptHTTP2: true, IdleConnTimeout: dohIdleTimeout},
+		},
+	}, nil
+}
+
+// appendDNSQuery appends a recursive query for the name and type in the wire format of RFC 1035,
+// with an ID of zero, as RFC 8484 recommends.
+func appendDNSQuery(b []byte, name string, qtype uint16) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if len(name) == 0 || len(name) > 253 {
+		return nil, fmt.Errorf("Invalid host name: %q", name)
+	}
+	b = append(b, 0, 0, 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 0)
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, fmt.Errorf("Invalid host name: %q", name)
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	b = append(b, 0)
+	b = binary.BigEndian.AppendUint16(b, qtype)
+	return binary.BigEndian.AppendUint16(b, dnsClassIN), nil
+}
+
+// skipDNSName returns the offset after the possibly compressed name at offset.
+func skipDNSName(msg []byte, offset int) (int, error) {
+	for {
+		if offset >= len(msg) {
+			return 0, errors.New("DNS response is truncated")
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			return offset + 1, nil
+		case length&0xc0 == 0xc0:
+			return offset + 2, nil
+		case length&0xc0 != 0:
+			return 0, errors.New("DNS response has an invalid name")
+		}
+		offset += 1 + length
+	}
+}
+
+// parseDNSResponse returns the addresses of the given type in the answers of a response.
+func parseDNSResponse(msg []byte, qtype uint16) ([]netip.Addr, error) {
+	if len(msg) < 12 {
+		return nil, errors.New("DNS response is truncated")
+	}
+	if msg[2]&0x80 == 0 {
+		return nil, errors.New("DNS response is not a response")
+	}
+	switch rcode := msg[3] & 0x0f; rcode {
+	case 0:
+	case dnsRcodeNameError:
+		return nil, errDoHNotFound
+	default:
+		return nil, fmt.Errorf("%w: response code %d", errDoHTemporary, rcode)
+	}
+	questions, answers := int(binary.BigEndian.Uint16(msg[4:])), int(binary.BigEndian.Uint16(msg[6:]))
+	offset := 12
+	var err error
+	for i := 0; i < questions; i++ {
+		if offset, err = skipDNSName(msg, offset); err != nil {
+			return nil, err
+		}
+		offset += 4
+	}
+	var addrs []netip.Addr
+	for i := 0; i < answers; i++ {
+		if offset, err = skipDNSName(msg, offset); err != nil {
+			return nil, err
+		}
+		if offset+10 > len(msg) {
+			return nil, errors.New("DNS response is truncated")
+		}
+		rtype, class, length := binary.BigEndian.Uint16(msg[offset:]), binary.BigEndian.Uint16(msg[offset+2:]), int(binary.BigEndian.Uint16(msg[offset+8:]))
+		offset += 10
+		if offset+length > len(msg) {
+			return nil, errors.New("DNS response is truncated")
+		}
+		// Answers for other types are the CNAME records that lead to those asked for.
+		if rtype == qtype && class == dnsClassIN {
+			if addr, ok := netip.AddrFromSlice(msg[offset : offset+length]); ok && (length == 4) == (qtype == dnsTypeA) {
+				addrs = append(addrs, addr)
+			}
+		}
+		offset += length
+	}
+	return addrs, nil
+}
+
+func (resolver *dohResolver) query(name string, qtype uint16) ([]netip.Addr, error) {
+	query, err := appendDNSQuery(nil, name, qtype)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequest(http.MethodPost, resolver.url, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/dns-message")
+	request.Header.Set("Accept", "application/dns-message")
+	response, err := resolver.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errDoHTemporary, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %s", errDoHTemporary, response.Status)
+	}
+	msg, err := io.ReadAll(io.LimitReader(response.Body, maxDoHResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errDoHTemporary, err)
+	}
+	return parseDNSResponse(msg, qtype)
+}
+
+// lookup returns the IPv4 and IPv6 addresses of the name, asking for both at once.
+func (resolver *dohResolver) lookup(name string) ([]netip.Addr, error) {
+	type result struct {
+		addrs []netip.Addr
+		err   error
+	}
+	v4, v6 := make(chan result, 1), make(chan result, 1)
+	go func() {
+		addrs, err := resolver.query(name, dnsTypeA)
+		v4 <- result{addrs, err}
+	}()
+	go func() {
+		addrs, err := resolver.query(name, dnsTypeAAAA)
+		v6 <- result{addrs, err}
+	}()
+	r4, r6 := <-v4, <-v6
+	addrs := append(r4.addrs, r6.addrs...)
+	if len(addrs) > 0 {
+		return addrs, nil
+	}
+	if r4.err != nil {
+		return nil, r4.err
+	}
+	if r6.err != nil {
+		return nil, r6.err
+	}
+	return nil, errDoHNotFound
+}
@@ -0,0 +1,91 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+// answerDNSQuery answers a query with a CNAME followed by the addresses of its type, with names
+// compressed as resolvers do, or with a name error for nonexistent.example.
+func answerDNSQuery(query []byte, addrs map[uint16][]netip.Addr) []byte {
+	response := append([]byte(nil), query...)
+	response[2] |= 0x80
+	qtype := binary.BigEndian.Uint16(query[len(query)-4:])
+	if string(query[13:24]) == "nonexistent" {
+		response[3] |= dnsRcodeNameError
+		return response
+	}
+	response = append(response, 0xc0, 12)
+	response = binary.BigEndian.AppendUint16(response, 5)
+	response = binary.BigEndian.AppendUint16(response, dnsClassIN)
+	response = append(response, 0, 0, 0, 60, 0, 2, 0xc0, 12)
+	for _, addr := range addrs[qtype] {
+		response = append(response, 0xc0, 12)
+		response = binary.BigEndian.AppendUint16(response, qtype)
+		response = binary.BigEndian.AppendUint16(response, dnsClassIN)
+		response = append(response, 0, 0, 0, 60)
+		response = binary.BigEndian.AppendUint16(response, uint16(addr.BitLen()/8))
+		response = append(response, addr.AsSlice()...)
+	}
+	binary.BigEndian.PutUint16(response[6:], uint16(len(addrs[qtype])+1))
+	return response
+}
+
+func TestDoHResolver(t *testing.T) {
+	addrs := map[uint16][]netip.Addr{
+		dnsTypeA:    {netip.MustParseAddr("192.0.2.1")},
+		dnsTypeAAAA: {netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("2001:db8::2")},
+	}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Content-Type") != "application/dns-message" || len(query) < 17 {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(answerDNSQuery(query, addrs))
+	}))
+	defer server.Close()
+	pin := sha256.Sum256(server.Certificate().Raw)
+
+	resolver, err := newDoHResolver(server.URL+"/dns-query", hex.EncodeToString(pin[:]))
+	if err != nil {
+		t.Fatalf("Unable to create resolver: %v", err)
+	}
+	got, err := resolver.lookup("vpn.example.com")
+	if err != nil {
+		t.Fatalf("Unable to resolve: %v", err)
+	}
+	want := append(append([]netip.Addr(nil), addrs[dnsTypeA]...), addrs[dnsTypeAAAA]...)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Resolved to %v, want %v", got, want)
+	}
+	if _, err = resolver.lookup("nonexistent.example"); !errors.Is(err, errDoHNotFound) {
+		t.Errorf("Nonexistent name gives %v, want %v", err, errDoHNotFound)
+	}
+
+	wrongPin := sha256.Sum256(nil)
+	resolver, err = newDoHResolver(server.URL, hex.EncodeToString(wrongPin[:]))
+	if err != nil {
+		t.Fatalf("Unable to create resolver: %v", err)
+	}
+	if _, err = resolver.lookup("vpn.example.com"); !errors.Is(err, errDoHTemporary) {
+		t.Errorf("Wrong pin gives %v, want %v", err, errDoHTemporary)
+	}
+	if _, err = newDoHResolver("http://192.0.2.53/dns-query", ""); err == nil {
+		t.Error("Plain HTTP resolver was accepted")
+	}
+}
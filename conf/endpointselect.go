@@ -0,0 +1,54 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import "net/netip"
+
+// sortEndpointAddrs orders the addresses of a host name as RFC 8305 does, alternating between
+// the families and starting with IPv6, while keeping the order of each family.
+func sortEndpointAddrs(addrs []netip.Addr) []netip.Addr {
+	var v4, v6 []netip.Addr
+	for _, addr := range addrs {
+		if addr.Is4() || addr.Is4In6() {
+			v4 = append(v4, addr.Unmap())
+		} else if addr.Is6() {
+			v6 = append(v6, addr)
+		}
+	}
+	sorted := make([]netip.Addr, 0, len(v4)+len(v6))
+	for i := 0; i < len(v4) || i < len(v6); i++ {
+		if i < len(v6) {
+			sorted = append(sorted, v6[i])
+		}
+		if i < len(v4) {
+			sorted = append(sorted, v4[i])
+		}
+	}
+	return sorted
+}
+
+// selectEndpointAddr picks the first address, in the order of sortEndpointAddrs, that routable
+// says can be reached on the current network. A WireGuard endpoint does not answer anything but a
+// handshake, so routable can only tell whether the network has a way to it. If none can be
+// reached, it picks the first IPv4 address, else the first IPv6 address, as the resolver always
+// did, so that the tunnel can come up once the network does.
+func selectEndpointAddr(addrs []netip.Addr, port uint16, routable func(netip.AddrPort) bool) (netip.Addr, bool) {
+	sorted := sortEndpointAddrs(addrs)
+	if len(sorted) == 0 {
+		return netip.Addr{}, false
+	}
+	for _, addr := range sorted {
+		if routable(netip.AddrPortFrom(addr, port)) {
+			return addr, true
+		}
+	}
+	for _, addr := range sorted {
+		if addr.Is4() {
+			return addr, true
+		}
+	}
+	return sorted[0], true
+}
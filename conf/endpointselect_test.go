@@ -0,0 +1,52 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestSelectEndpointAddr(t *testing.T) {
+	addrs := []netip.Addr{
+		netip.MustParseAddr("192.0.2.1"),
+		netip.MustParseAddr("192.0.2.2"),
+		netip.MustParseAddr("2001:db8::1"),
+		netip.MustParseAddr("::ffff:192.0.2.3"),
+	}
+	sorted := []netip.Addr{
+		netip.MustParseAddr("2001:db8::1"),
+		netip.MustParseAddr("192.0.2.1"),
+		netip.MustParseAddr("192.0.2.2"),
+		netip.MustParseAddr("192.0.2.3"),
+	}
+	if got := sortEndpointAddrs(addrs); !reflect.DeepEqual(got, sorted) {
+		t.Errorf("Sorted addresses are %v, want %v", got, sorted)
+	}
+
+	onlyV4 := func(addrPort netip.AddrPort) bool { return addrPort.Addr().Is4() }
+	onlyV6 := func(addrPort netip.AddrPort) bool { return addrPort.Addr().Is6() }
+	neither := func(addrPort netip.AddrPort) bool { return false }
+	for _, test := range []struct {
+		routable func(netip.AddrPort) bool
+		want     string
+	}{
+		{onlyV4, "192.0.2.1"},
+		{onlyV6, "2001:db8::1"},
+		{neither, "192.0.2.1"},
+	} {
+		if got, ok := selectEndpointAddr(addrs, 51820, test.routable); !ok || got.String() != test.want {
+			t.Errorf("Selected %v, want %s", got, test.want)
+		}
+	}
+	if got, ok := selectEndpointAddr(addrs[2:3], 51820, neither); !ok || got != addrs[2] {
+		t.Errorf("Selected %v from IPv6 alone, want %v", got, addrs[2])
+	}
+	if _, ok := selectEndpointAddr(nil, 51820, onlyV4); ok {
+		t.Error("Selected an address from none")
+	}
+}
@@ -0,0 +1,36 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// MatchesFilter reports whether the configuration matches a filter typed by the user: its name
+// or the host of one of its endpoints contains the filter, ignoring case, or one of its addresses
+// starts with the filter or, if the filter is a prefix such as 10.0.0.0/8, lies within it.
+func (config *Config) MatchesFilter(filter string) bool {
+	filter = strings.ToLower(strings.TrimSpace(filter))
+	if len(filter) == 0 {
+		return true
+	}
+	if strings.Contains(strings.ToLower(config.Name), filter) {
+		return true
+	}
+	for i := range config.Peers {
+		if !config.Peers[i].Endpoint.IsEmpty() && strings.Contains(strings.ToLower(config.Peers[i].Endpoint.Host), filter) {
+			return true
+		}
+	}
+	prefix, prefixErr := netip.ParsePrefix(filter)
+	for _, address := range config.Interface.Addresses {
+		if strings.HasPrefix(address.String(), filter) || (prefixErr == nil && prefix.Contains(address.Addr())) {
+			return true
+		}
+	}
+	return false
+}
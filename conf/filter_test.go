@@ -0,0 +1,45 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"testing"
+)
+
+func TestMatchesFilter(t *testing.T) {
+	config, err := FromWgQuick(`[Interface]
+PrivateKey = yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=
+Address = 10.192.122.1/24, fd00:1::1/64
+
+[Peer]
+PublicKey = xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=
+Endpoint = vpn.Example.com:51820
+AllowedIPs = 0.0.0.0/0`, "Office-Frankfurt")
+	if err != nil {
+		t.Fatalf("Unable to parse test config: %v", err)
+	}
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{"", true},
+		{"  ", true},
+		{"frank", true},
+		{"OFFICE", true},
+		{"example.com", true},
+		{"10.192.", true},
+		{"fd00:1:", true},
+		{"10.0.0.0/8", true},
+		{"192.168.0.0/16", false},
+		{"berlin", false},
+		{"51820", false},
+	}
+	for _, test := range tests {
+		if got := config.MatchesFilter(test.filter); got != test.want {
+			t.Errorf("MatchesFilter(%q) = %v, want %v", test.filter, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+// ParseForFuzz is an entry point for go-fuzz compatible fuzzers. It parses data the same way
+// that imported files are parsed, and then checks that anything accepted survives being
+// written out and parsed again. It returns 1 for inputs that parse and 0 for those that don't.
+func ParseForFuzz(data []byte) int {
+	config, err := FromWgQuickWithUnknownEncoding(string(data), "fuzz")
+	if err != nil {
+		return 0
+	}
+	reparsed, err := FromWgQuick(config.ToWgQuick(), "fuzz")
+	if err != nil {
+		panic("unable to parse written configuration: " + err.Error())
+	}
+	if len(reparsed.Peers) != len(config.Peers) || reparsed.Interface.PrivateKey != config.Interface.PrivateKey {
+		panic("written configuration does not match parsed configuration")
+	}
+	return 1
+}
@@ -0,0 +1,149 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// TunnelGroups are named lists of tunnels, which are activated together, in the order listed,
+// and deactivated together, in the reverse order. A tunnel may be in several groups.
+type TunnelGroups struct {
+	Groups []TunnelGroup `json:"groups,omitempty"`
+}
+
+type TunnelGroup struct {
+	Name    string   `json:"name"`
+	Tunnels []string `json:"tunnels,omitempty"`
+}
+
+func (groups *TunnelGroups) IsEmpty() bool {
+	return len(groups.Groups) == 0
+}
+
+// Find returns the group with the given name, or nil if there is none.
+func (groups *TunnelGroups) Find(name string) *TunnelGroup {
+	for i := range groups.Groups {
+		if groups.Groups[i].Name == name {
+			return &groups.Groups[i]
+		}
+	}
+	return nil
+}
+
+// Validate checks that the groups have distinct names and list valid tunnel names, each once.
+func (groups *TunnelGroups) Validate() error {
+	seen := make(map[string]bool, len(groups.Groups))
+	for i := range groups.Groups {
+		group := &groups.Groups[i]
+		if len(group.Name) == 0 {
+			return errors.New("Group name must not be empty")
+		}
+		if seen[group.Name] {
+			return fmt.Errorf("Group ‘%s’ is defined twice", group.Name)
+		}
+		seen[group.Name] = true
+		inGroup := make(map[string]bool, len(group.Tunnels))
+		for _, tunnel := range group.Tunnels {
+			if !TunnelNameIsValid(tunnel) {
+				return fmt.Errorf("Group ‘%s’: tunnel name ‘%s’ is not valid", group.Name, tunnel)
+			}
+			if inGroup[tunnel] {
+				return fmt.Errorf("Group ‘%s’ lists tunnel ‘%s’ twice", group.Name, tunnel)
+			}
+			inGroup[tunnel] = true
+		}
+	}
+	return nil
+}
+
+// RemoveTunnel removes the named tunnel from all groups, and reports whether it was in any.
+func (groups *TunnelGroups) RemoveTunnel(tunnel string) bool {
+	removed := false
+	for i := range groups.Groups {
+		group := &groups.Groups[i]
+		tunnels := group.Tunnels[:0]
+		for _, t := range group.Tunnels {
+			if t == tunnel {
+				removed = true
+			} else {
+				tunnels = append(tunnels, t)
+			}
+		}
+		group.Tunnels = tunnels
+	}
+	return removed
+}
+
+// RenameTunnel replaces the named tunnel with newName in all groups, and reports whether it was in any.
+func (groups *TunnelGroups) RenameTunnel(tunnel, newName string) bool {
+	renamed := false
+	for i := range groups.Groups {
+		for j := range groups.Groups[i].Tunnels {
+			if groups.Groups[i].Tunnels[j] == tunnel {
+				groups.Groups[i].Tunnels[j] = newName
+				renamed = true
+			}
+		}
+	}
+	return renamed
+}
+
+func tunnelGroupsPath() (string, error) {
+	root, err := RootDirectory(true)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, "Groups.json"), nil
+}
+
+// LoadTunnelGroups returns the tunnel groups, which are empty if none have been saved.
+func LoadTunnelGroups() (*TunnelGroups, error) {
+	path, err := tunnelGroupsPath()
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &TunnelGroups{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var groups TunnelGroups
+	err = json.Unmarshal(bytes, &groups)
+	if err != nil {
+		return nil, err
+	}
+	return &groups, nil
+}
+
+// SaveTunnelGroups saves the tunnel groups, or removes them if empty.
+func SaveTunnelGroups(groups *TunnelGroups) error {
+	path, err := tunnelGroupsPath()
+	if err != nil {
+		return err
+	}
+	if groups.IsEmpty() {
+		err = os.Remove(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	err = groups.Validate()
+	if err != nil {
+		return err
+	}
+	bytes, err := json.Marshal(groups)
+	if err != nil {
+		return err
+	}
+	return writeLockedDownFile(path, true, bytes)
+}
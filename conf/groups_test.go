@@ -0,0 +1,47 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"testing"
+)
+
+func TestTunnelGroupsValidate(t *testing.T) {
+	tests := []struct {
+		groups TunnelGroups
+		valid  bool
+	}{
+		{TunnelGroups{Groups: []TunnelGroup{{Name: "work", Tunnels: []string{"office", "lab"}}, {Name: "home", Tunnels: []string{"office"}}}}, true},
+		{TunnelGroups{Groups: []TunnelGroup{{Name: "", Tunnels: []string{"office"}}}}, false},
+		{TunnelGroups{Groups: []TunnelGroup{{Name: "work"}, {Name: "work"}}}, false},
+		{TunnelGroups{Groups: []TunnelGroup{{Name: "work", Tunnels: []string{"office", "office"}}}}, false},
+		{TunnelGroups{Groups: []TunnelGroup{{Name: "work", Tunnels: []string{"not/valid"}}}}, false},
+	}
+	for i, test := range tests {
+		if err := test.groups.Validate(); (err == nil) != test.valid {
+			t.Errorf("%d: Validate() = %v, want valid %v", i, err, test.valid)
+		}
+	}
+}
+
+func TestTunnelGroupsRemoveTunnel(t *testing.T) {
+	groups := TunnelGroups{Groups: []TunnelGroup{{Name: "work", Tunnels: []string{"office", "lab", "home"}}, {Name: "home", Tunnels: []string{"home"}}}}
+	if !groups.RemoveTunnel("home") {
+		t.Fatal("Tunnel was not found in groups")
+	}
+	if work := groups.Find("work"); len(work.Tunnels) != 2 || work.Tunnels[0] != "office" || work.Tunnels[1] != "lab" {
+		t.Errorf("Order of remaining tunnels changed: %v", work.Tunnels)
+	}
+	if home := groups.Find("home"); len(home.Tunnels) != 0 {
+		t.Errorf("Tunnel remains in group: %v", home.Tunnels)
+	}
+	if groups.RemoveTunnel("home") {
+		t.Error("Removed tunnel was found again")
+	}
+	if !groups.RenameTunnel("lab", "lab2") || groups.Find("work").Tunnels[1] != "lab2" {
+		t.Errorf("Tunnel was not renamed: %v", groups.Find("work").Tunnels)
+	}
+}
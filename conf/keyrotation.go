@@ -0,0 +1,118 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+const defaultKeyRotationGracePeriod = 10 * time.Minute
+
+// KeyRotationPolicy is how often the manager replaces the private key of a tunnel. It is set by
+// administrators per tunnel, under HKLM\Software\WireGuard\KeyRotation\<tunnel>, since whoever
+// runs the peers has to be in on it, through PublishURL.
+type KeyRotationPolicy struct {
+	Interval    time.Duration
+	GracePeriod time.Duration
+	PublishURL  string
+}
+
+// KeyRotationState is when the key of a tunnel was last rotated, or rotation was last attempted.
+type KeyRotationState struct {
+	LastRotation time.Time `json:"last_rotation"`
+}
+
+// LoadKeyRotationPolicy returns the key rotation policy of the named tunnel, or nil if it has none.
+func LoadKeyRotationPolicy(name string) *KeyRotationPolicy {
+	if !TunnelNameIsValid(name) {
+		return nil
+	}
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, adminRegKey+`\KeyRotation\`+name, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if err != nil {
+		return nil
+	}
+	defer key.Close()
+	days, _, err := key.GetIntegerValue("IntervalDays")
+	if err != nil || days == 0 {
+		return nil
+	}
+	policy := &KeyRotationPolicy{
+		Interval:    time.Duration(days) * 24 * time.Hour,
+		GracePeriod: defaultKeyRotationGracePeriod,
+	}
+	if minutes, _, err := key.GetIntegerValue("GraceMinutes"); err == nil && minutes > 0 {
+		policy.GracePeriod = time.Duration(minutes) * time.Minute
+	}
+	policy.PublishURL, _, _ = key.GetStringValue("PublishURL")
+	return policy
+}
+
+func keyRotationStatePath(name string) (string, error) {
+	if !TunnelNameIsValid(name) {
+		return "", errors.New("Tunnel name is not valid")
+	}
+	root, err := RootDirectory(true)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, "Key Rotation")
+	err = os.Mkdir(dir, os.ModeDir|0o700)
+	if err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// LoadKeyRotationState returns the key rotation state of the named tunnel, which is zero if it
+// was never rotated.
+func LoadKeyRotationState(name string) (KeyRotationState, error) {
+	path, err := keyRotationStatePath(name)
+	if err != nil {
+		return KeyRotationState{}, err
+	}
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return KeyRotationState{}, nil
+	} else if err != nil {
+		return KeyRotationState{}, err
+	}
+	var state KeyRotationState
+	err = json.Unmarshal(bytes, &state)
+	if err != nil {
+		return KeyRotationState{}, err
+	}
+	return state, nil
+}
+
+func SaveKeyRotationState(name string, state KeyRotationState) error {
+	path, err := keyRotationStatePath(name)
+	if err != nil {
+		return err
+	}
+	bytes, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return writeLockedDownFile(path, true, bytes)
+}
+
+func DeleteKeyRotationState(name string) error {
+	path, err := keyRotationStatePath(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
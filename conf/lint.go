@@ -0,0 +1,104 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"net/netip"
+
+	"golang.zx2c4.com/wireguard/windows/l18n"
+)
+
+type WarningKind uint32
+
+const (
+	WarningOverlappingAllowedIPs WarningKind = iota + 1
+	WarningDefaultRouteWithoutDNS
+	WarningMissingKeepalive
+	WarningDuplicatePeer
+	WarningMTUTooLarge
+)
+
+// Warning is something about a configuration that is valid, but likely not what was meant.
+type Warning struct {
+	Kind    WarningKind
+	Peer    int // The index of the peer that the warning is about, or -1 if it is about the interface.
+	Message string
+}
+
+func (warning Warning) String() string {
+	return warning.Message
+}
+
+// The largest MTUs that fit in a 1500-byte Ethernet frame, after the 32 bytes of WireGuard
+// overhead, 8 of UDP, and 20 or 40 of IP.
+const (
+	maxMTUOverIPv4 = 1440
+	maxMTUOverIPv6 = 1420
+)
+
+// endpointLooksRemote returns whether the endpoint is a host name or a public address, rather than
+// one on the local network, and so probably reached through NAT.
+func endpointLooksRemote(endpoint *Endpoint) bool {
+	addr, err := netip.ParseAddr(endpoint.Host)
+	if err != nil {
+		return true
+	}
+	return !addr.IsPrivate() && !addr.IsLoopback() && !addr.IsLinkLocalUnicast()
+}
+
+// Lint returns warnings about the configuration, with what to do about them.
+func (conf *Config) Lint() []Warning {
+	var warnings []Warning
+	warn := func(kind WarningKind, peer int, message string) {
+		warnings = append(warnings, Warning{Kind: kind, Peer: peer, Message: message})
+	}
+
+	for i := range conf.Peers {
+		peer := &conf.Peers[i]
+		for j := 0; j < i; j++ {
+			if conf.Peers[j].PublicKey == peer.PublicKey {
+				warn(WarningDuplicatePeer, i, l18n.Sprintf("Peer %s has the same public key as peer %s; merge their allowed IPs into one of them.", peer.DisplayName(), conf.Peers[j].DisplayName()))
+				break
+			}
+		}
+	overlap:
+		for j := 0; j < i; j++ {
+			if conf.Peers[j].PublicKey == peer.PublicKey {
+				continue
+			}
+			for _, a := range conf.Peers[j].AllowedIPs {
+				for _, b := range peer.AllowedIPs {
+					if a.Overlaps(b) {
+						warn(WarningOverlappingAllowedIPs, i, l18n.Sprintf("Allowed IP %s of peer %s overlaps %s of peer %s; traffic for the overlap goes to only one of them.", b, peer.DisplayName(), a, conf.Peers[j].DisplayName()))
+						break overlap
+					}
+				}
+			}
+		}
+		if len(conf.Interface.DNS) == 0 {
+			for _, allowedIP := range peer.AllowedIPs {
+				if allowedIP.Bits() == 0 {
+					warn(WarningDefaultRouteWithoutDNS, i, l18n.Sprintf("Peer %s takes all traffic with %s, but no DNS servers are set, so name lookups leave the tunnel; add a DNS line to the interface.", peer.DisplayName(), allowedIP))
+					break
+				}
+			}
+		}
+		if peer.Endpoint.IsEmpty() {
+			continue
+		}
+		if conf.Interface.ListenPort == 0 && peer.PersistentKeepalive == 0 && endpointLooksRemote(&peer.Endpoint) {
+			warn(WarningMissingKeepalive, i, l18n.Sprintf("Peer %s is reached through its endpoint %s, likely through NAT, which forgets the tunnel when it is idle; add PersistentKeepalive = 25 to the peer.", peer.DisplayName(), peer.Endpoint.String()))
+		}
+		maxMTU := maxMTUOverIPv4
+		if addr, err := netip.ParseAddr(peer.Endpoint.Host); err == nil && addr.Is6() && !addr.Is4In6() {
+			maxMTU = maxMTUOverIPv6
+		}
+		if conf.Interface.MTU > uint16(maxMTU) {
+			warn(WarningMTUTooLarge, i, l18n.Sprintf("The MTU of %d is too large for packets to endpoint %s of peer %s to fit in a 1500-byte Ethernet frame; lower it to %d or leave it unset.", conf.Interface.MTU, peer.Endpoint.String(), peer.DisplayName(), maxMTU))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,37 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"testing"
+)
+
+func lintKinds(t *testing.T, input string) []WarningKind {
+	config, err := FromWgQuick(input, "test")
+	if !noError(t, err) {
+		return nil
+	}
+	var kinds []WarningKind
+	for _, warning := range config.Lint() {
+		kinds = append(kinds, warning.Kind)
+	}
+	return kinds
+}
+
+func TestLint(t *testing.T) {
+	const iface = "[Interface]\nPrivateKey = yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=\n"
+	const alice = "\n[Peer]\nPublicKey = xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=\n"
+	const bob = "\n[Peer]\nPublicKey = TrMvSoP4jYQlY6RIzBgbssQqY3vxI2Pi+y71lOWWXX0=\n"
+
+	equal(t, []WarningKind(nil), lintKinds(t, iface+"DNS = 1.1.1.1\n"+alice+"AllowedIPs = 0.0.0.0/0\nEndpoint = 192.168.1.1:51820\n"))
+	equal(t, []WarningKind{WarningDefaultRouteWithoutDNS}, lintKinds(t, iface+alice+"AllowedIPs = ::/0\n"))
+	equal(t, []WarningKind{WarningOverlappingAllowedIPs}, lintKinds(t, iface+alice+"AllowedIPs = 10.0.0.0/16\n"+bob+"AllowedIPs = 10.0.1.0/24\n"))
+	equal(t, []WarningKind{WarningDuplicatePeer}, lintKinds(t, iface+alice+"AllowedIPs = 10.0.0.0/24\n"+alice+"AllowedIPs = 10.0.0.0/24\n"))
+	equal(t, []WarningKind{WarningMissingKeepalive}, lintKinds(t, iface+alice+"Endpoint = demo.wireguard.com:51820\n"))
+	equal(t, []WarningKind(nil), lintKinds(t, iface+"ListenPort = 51820\n"+alice+"Endpoint = demo.wireguard.com:51820\n"))
+	equal(t, []WarningKind(nil), lintKinds(t, iface+"MTU = 1440\n"+alice+"Endpoint = 192.0.2.1:51820\nPersistentKeepalive = 25\n"))
+	equal(t, []WarningKind{WarningMTUTooLarge}, lintKinds(t, iface+"MTU = 1440\n"+alice+"Endpoint = [2001:db8::1]:51820\nPersistentKeepalive = 25\n"))
+}
@@ -0,0 +1,81 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// A listen port override is the port that the manager rebound a tunnel to, because the one in
+// its configuration was taken by another tunnel when it was activated. The configuration itself
+// is left alone, so that the tunnel gets its own port back once it is free again.
+
+func listenPortOverridePath(name string) (string, error) {
+	if !TunnelNameIsValid(name) {
+		return "", errors.New("Tunnel name is not valid")
+	}
+	root, err := RootDirectory(true)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, "Listen Ports")
+	err = os.Mkdir(dir, os.ModeDir|0o700)
+	if err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// LoadListenPortOverride returns the port that the named tunnel was rebound to, or 0 if none.
+func LoadListenPortOverride(name string) (uint16, error) {
+	path, err := listenPortOverridePath(name)
+	if err != nil {
+		return 0, err
+	}
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	var port uint16
+	err = json.Unmarshal(bytes, &port)
+	if err != nil {
+		return 0, err
+	}
+	return port, nil
+}
+
+// SaveListenPortOverride saves the port that the named tunnel was rebound to, or removes it if 0.
+func SaveListenPortOverride(name string, port uint16) error {
+	if port == 0 {
+		return DeleteListenPortOverride(name)
+	}
+	path, err := listenPortOverridePath(name)
+	if err != nil {
+		return err
+	}
+	bytes, err := json.Marshal(port)
+	if err != nil {
+		return err
+	}
+	return writeLockedDownFile(path, true, bytes)
+}
+
+func DeleteListenPortOverride(name string) error {
+	path, err := listenPortOverridePath(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
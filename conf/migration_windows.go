@@ -80,7 +80,7 @@ func migrateUnencryptedConfigs(sharingBase int, migrated MigrationCallback) {
 			}
 			goto error
 		}
-		bytes, err = io.ReadAll(f)
+		bytes, err = io.ReadAll(io.LimitReader(f, MaxConfigFileSize+1))
 		f.Close()
 		if err != nil {
 			goto error
@@ -0,0 +1,159 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"errors"
+	"math/bits"
+	"net/netip"
+	"strings"
+
+	"golang.zx2c4.com/wireguard/windows/l18n"
+)
+
+// OpenVPN profiles only contribute their addressing, DNS, and routing. Their certificates have no
+// bearing on WireGuard keys, so the converted configuration gets a new private key, and its peer
+// a public key that the user has to fill in, which keeps it from being saved until then. The port
+// of the server is that of OpenVPN, so the peer gets WireGuard's usual one instead.
+const openVPNDefaultWireGuardPort = 51820
+
+// parseOpenVPNNetmask returns the prefix length of a dotted netmask, such as 255.255.255.0.
+func parseOpenVPNNetmask(s string) (int, bool) {
+	mask, err := netip.ParseAddr(s)
+	if err != nil || !mask.Is4() {
+		return 0, false
+	}
+	b := mask.As4()
+	m := uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+	ones := bits.LeadingZeros32(^m)
+	return ones, m<<ones == 0
+}
+
+// FromOpenVPN converts an OpenVPN client profile into a skeleton configuration with the given name.
+func FromOpenVPN(s, name string) (*Config, error) {
+	privateKey, err := NewPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{Name: name, Interface: Interface{PrivateKey: *privateKey}}
+	peer := Peer{}
+	sawRemote := false
+	var inlineBlock string
+	addAllowedIP := func(prefix netip.Prefix) {
+		prefix = prefix.Masked()
+		for _, existing := range peer.AllowedIPs {
+			if existing == prefix {
+				return
+			}
+		}
+		peer.AllowedIPs = append(peer.AllowedIPs, prefix)
+	}
+	for _, line := range strings.Split(s, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], ";") {
+			continue
+		}
+		directive := strings.ToLower(fields[0])
+		// Inline files, such as <ca>, hold certificates and keys, which are skipped, while
+		// <connection> blocks hold directives of their own.
+		if len(inlineBlock) > 0 {
+			if directive == "</"+inlineBlock+">" {
+				inlineBlock = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(directive, "<") && strings.HasSuffix(directive, ">") {
+			if tag := strings.Trim(directive, "</>"); !strings.HasPrefix(directive, "</") && tag != "connection" {
+				inlineBlock = tag
+			}
+			continue
+		}
+		args := fields[1:]
+		switch directive {
+		case "remote":
+			if sawRemote || len(args) == 0 {
+				continue
+			}
+			sawRemote = true
+			peer.Endpoint = Endpoint{Host: strings.Trim(args[0], "[]"), Port: openVPNDefaultWireGuardPort}
+			peer.Name = peer.Endpoint.Host
+		case "ifconfig":
+			if len(args) < 2 {
+				continue
+			}
+			local, err := netip.ParseAddr(args[0])
+			if err != nil || !local.Is4() {
+				continue
+			}
+			// With topology subnet, the second argument is a netmask, and otherwise it is the
+			// address of the server on a point-to-point link.
+			if length, ok := parseOpenVPNNetmask(args[1]); ok && length > 0 {
+				config.Interface.Addresses = append(config.Interface.Addresses, netip.PrefixFrom(local, length))
+				addAllowedIP(netip.PrefixFrom(local, length))
+			} else {
+				config.Interface.Addresses = append(config.Interface.Addresses, netip.PrefixFrom(local, 32))
+				if remote, err := netip.ParseAddr(args[1]); err == nil && remote.Is4() {
+					addAllowedIP(netip.PrefixFrom(remote, 32))
+				}
+			}
+		case "ifconfig-ipv6":
+			if len(args) < 1 {
+				continue
+			}
+			if prefix, err := netip.ParsePrefix(args[0]); err == nil && prefix.Addr().Is6() {
+				config.Interface.Addresses = append(config.Interface.Addresses, prefix)
+				addAllowedIP(prefix)
+			}
+		case "route":
+			if len(args) < 1 {
+				continue
+			}
+			network, err := netip.ParseAddr(args[0])
+			if err != nil || !network.Is4() {
+				continue // Hostnames and keywords such as vpn_gateway are not routes to convert.
+			}
+			length := 32
+			if len(args) > 1 && args[1] != "default" {
+				var ok bool
+				if length, ok = parseOpenVPNNetmask(args[1]); !ok {
+					continue
+				}
+			}
+			addAllowedIP(netip.PrefixFrom(network, length))
+		case "route-ipv6":
+			if len(args) < 1 {
+				continue
+			}
+			if prefix, err := netip.ParsePrefix(args[0]); err == nil && prefix.Addr().Is6() {
+				addAllowedIP(prefix)
+			}
+		case "redirect-gateway":
+			addAllowedIP(netip.PrefixFrom(netip.IPv4Unspecified(), 0))
+			for _, flag := range args {
+				if strings.EqualFold(flag, "ipv6") {
+					addAllowedIP(netip.PrefixFrom(netip.IPv6Unspecified(), 0))
+				}
+			}
+		case "dhcp-option":
+			if len(args) < 2 {
+				continue
+			}
+			switch strings.ToUpper(args[0]) {
+			case "DNS", "DNS6":
+				if addr, err := netip.ParseAddr(args[1]); err == nil {
+					config.Interface.DNS = append(config.Interface.DNS, addr)
+				}
+			case "DOMAIN", "DOMAIN-SEARCH":
+				config.Interface.DNSSearch = append(config.Interface.DNSSearch, args[1])
+			}
+		}
+	}
+	if !sawRemote {
+		return nil, errors.New(l18n.Sprintf("OpenVPN profile has no remote server"))
+	}
+	config.Peers = []Peer{peer}
+	return config, nil
+}
@@ -0,0 +1,80 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+const openVPNTestProfile = `client
+dev tun
+proto udp
+remote vpn.example.com 1194
+remote backup.example.com 1194
+ifconfig 10.8.0.6 255.255.255.0
+ifconfig-ipv6 fd00:8::6/64 fd00:8::1
+route 192.168.10.0 255.255.255.0
+route 172.16.0.1
+route intranet.example.com
+route-ipv6 fd00:10::/48
+; route 10.99.0.0 255.255.0.0
+dhcp-option DNS 10.8.0.1
+dhcp-option DOMAIN corp.example
+<ca>
+-----BEGIN CERTIFICATE-----
+route 10.66.0.0 255.255.0.0
+-----END CERTIFICATE-----
+</ca>
+`
+
+func TestFromOpenVPN(t *testing.T) {
+	config, err := FromOpenVPN(openVPNTestProfile, "office")
+	if err != nil {
+		t.Fatalf("Unable to convert profile: %v", err)
+	}
+	if config.Name != "office" || config.Interface.PrivateKey.IsZero() {
+		t.Errorf("Converted configuration has name %q and private key %v", config.Name, config.Interface.PrivateKey.String())
+	}
+	wantAddresses := []netip.Prefix{netip.MustParsePrefix("10.8.0.6/24"), netip.MustParsePrefix("fd00:8::6/64")}
+	if !reflect.DeepEqual(config.Interface.Addresses, wantAddresses) {
+		t.Errorf("Addresses = %v, want %v", config.Interface.Addresses, wantAddresses)
+	}
+	if len(config.Interface.DNS) != 1 || config.Interface.DNS[0] != netip.MustParseAddr("10.8.0.1") || !reflect.DeepEqual(config.Interface.DNSSearch, []string{"corp.example"}) {
+		t.Errorf("DNS = %v, search = %v", config.Interface.DNS, config.Interface.DNSSearch)
+	}
+	if len(config.Peers) != 1 {
+		t.Fatalf("Converted configuration has %d peers", len(config.Peers))
+	}
+	peer := &config.Peers[0]
+	if peer.Endpoint != (Endpoint{Host: "vpn.example.com", Port: openVPNDefaultWireGuardPort}) || peer.Name != "vpn.example.com" || !peer.PublicKey.IsZero() {
+		t.Errorf("Peer has endpoint %v, name %q, and public key %v", peer.Endpoint, peer.Name, peer.PublicKey.String())
+	}
+	wantAllowedIPs := []netip.Prefix{
+		netip.MustParsePrefix("10.8.0.0/24"),
+		netip.MustParsePrefix("fd00:8::/64"),
+		netip.MustParsePrefix("192.168.10.0/24"),
+		netip.MustParsePrefix("172.16.0.1/32"),
+		netip.MustParsePrefix("fd00:10::/48"),
+	}
+	if !reflect.DeepEqual(peer.AllowedIPs, wantAllowedIPs) {
+		t.Errorf("AllowedIPs = %v, want %v", peer.AllowedIPs, wantAllowedIPs)
+	}
+
+	config, err = FromOpenVPN("client\nremote 198.51.100.1\nifconfig 10.9.0.2 10.9.0.1\nredirect-gateway def1 ipv6\n", "home")
+	if err != nil {
+		t.Fatalf("Unable to convert point-to-point profile: %v", err)
+	}
+	wantAllowedIPs = []netip.Prefix{netip.MustParsePrefix("10.9.0.1/32"), netip.MustParsePrefix("0.0.0.0/0"), netip.MustParsePrefix("::/0")}
+	if !reflect.DeepEqual(config.Interface.Addresses, []netip.Prefix{netip.MustParsePrefix("10.9.0.2/32")}) || !reflect.DeepEqual(config.Peers[0].AllowedIPs, wantAllowedIPs) {
+		t.Errorf("Point-to-point profile converted to addresses %v and allowed IPs %v", config.Interface.Addresses, config.Peers[0].AllowedIPs)
+	}
+
+	if _, err = FromOpenVPN("client\ndev tun\n", "none"); err == nil {
+		t.Error("Profile without a remote server should not convert")
+	}
+}
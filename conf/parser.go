@@ -7,9 +7,13 @@
 
  import (
 	 "encoding/base64"
+	 "errors"
+	 "net"
 	 "net/netip"
+	 "path/filepath"
 	 "strconv"
 	 "strings"
+	 "unicode/utf8"
  
 	 "golang.org/x/sys/windows"
 	 "golang.org/x/text/encoding/unicode"
@@ -21,12 +25,23 @@
  type ParseError struct {
 	 why      string
 	 offender string
+	 Line     int    // The line of the configuration with the error, counting from 1, or 0 if it has none.
+	 Column   int    // The column of the offender in its line, counting characters from 1, or 0 if unknown.
+	 Key      string // The key of the entry with the error, if the line has one.
  }
  
- func (e *ParseError) Error() string {
+ // Description is the error without its position.
+ func (e *ParseError) Description() string {
 	 return l18n.Sprintf("%s: %q", e.why, e.offender)
  }
  
+ func (e *ParseError) Error() string {
+	 if e.Line > 0 {
+		 return l18n.Sprintf("Line %d: %s", e.Line, e.Description())
+	 }
+	 return e.Description()
+ }
+ 
  func parseIPCidr(s string) (netip.Prefix, error) {
 	 ipcidr, err := netip.ParsePrefix(s)
 	 if err == nil {
@@ -34,7 +49,7 @@
 	 }
 	 addr, err := netip.ParseAddr(s)
 	 if err != nil {
-		 return netip.Prefix{}, &ParseError{l18n.Sprintf("Invalid IP address"), s}
+		 return netip.Prefix{}, &ParseError{why: l18n.Sprintf("Invalid IP address"), offender: s}
 	 }
 	 return netip.PrefixFrom(addr, addr.BitLen()), nil
  }
@@ -47,32 +62,32 @@
 		 // IPv6-Adresse muss in eckigen Klammern stehen.
 		 endIndex := strings.Index(s, "]")
 		 if endIndex == -1 {
-			 return nil, &ParseError{l18n.Sprintf("Missing closing bracket in endpoint"), s}
+			 return nil, &ParseError{why: l18n.Sprintf("Missing closing bracket in endpoint"), offender: s}
 		 }
 		 host = s[1:endIndex]
 		 remainder := s[endIndex+1:]
 		 if !strings.HasPrefix(remainder, ":") {
-			 return nil, &ParseError{l18n.Sprintf("Missing port separator after IPv6 address"), s}
+			 return nil, &ParseError{why: l18n.Sprintf("Missing port separator after IPv6 address"), offender: s}
 		 }
 		 portStr = remainder[1:]
 	 } else {
 		 // Ohne Klammern: Suche nach dem letzten Doppelpunkt als Porttrenner.
 		 i := strings.LastIndexByte(s, ':')
 		 if i < 0 {
-			 return nil, &ParseError{l18n.Sprintf("Missing port from endpoint"), s}
+			 return nil, &ParseError{why: l18n.Sprintf("Missing port from endpoint"), offender: s}
 		 }
 		 host = s[:i]
 		 portStr = s[i+1:]
 		 // Falls der Host selbst einen Doppelpunkt enthält, handelt es sich um eine IPv6-Adresse,
 		 // die aber nicht in Klammern angegeben wurde – das ist nicht erlaubt.
 		 if strings.Contains(host, ":") {
-			 return nil, &ParseError{l18n.Sprintf("IPv6 addresses must be enclosed in brackets"), s}
+			 return nil, &ParseError{why: l18n.Sprintf("IPv6 addresses must be enclosed in brackets"), offender: s}
 		 }
 	 }
 	 
 	 // Überprüfe, dass der Host nicht leer ist.
 	 if len(host) == 0 {
-		 return nil, &ParseError{l18n.Sprintf("Invalid endpoint host"), host}
+		 return nil, &ParseError{why: l18n.Sprintf("Invalid endpoint host"), offender: host}
 	 }
  
 	 port, err := parsePort(portStr)
@@ -85,21 +100,97 @@
  func parseMTU(s string) (uint16, error) {
 	 m, err := strconv.Atoi(s)
 	 if err != nil {
-		 return 0, err
+		 return 0, &ParseError{why: l18n.Sprintf("Invalid MTU"), offender: s}
 	 }
 	 if m < 576 || m > 65535 {
-		 return 0, &ParseError{l18n.Sprintf("Invalid MTU"), s}
+		 return 0, &ParseError{why: l18n.Sprintf("Invalid MTU"), offender: s}
+	 }
+	 return uint16(m), nil
+ }
+ 
+ // Windows takes interface and route metrics up to 9999, and a metric of zero here leaves it to
+ // choose them itself.
+ func parseMetric(s string) (uint32, error) {
+	 m, err := strconv.Atoi(s)
+	 if err != nil {
+		 return 0, &ParseError{why: l18n.Sprintf("Invalid metric"), offender: s}
+	 }
+	 if m < 1 || m > 9999 {
+		 return 0, &ParseError{why: l18n.Sprintf("Invalid metric"), offender: s}
+	 }
+	 return uint32(m), nil
+ }
+ 
+ func parseScriptTimeout(s string) (uint16, error) {
+	 m, err := strconv.Atoi(s)
+	 if err != nil {
+		 return 0, &ParseError{why: l18n.Sprintf("Invalid script timeout"), offender: s}
+	 }
+	 if m < 1 || m > 3600 {
+		 return 0, &ParseError{why: l18n.Sprintf("Invalid script timeout"), offender: s}
 	 }
 	 return uint16(m), nil
  }
  
+ func parseCheckTimeout(s string) (uint16, error) {
+	 m, err := strconv.Atoi(s)
+	 if err != nil {
+		 return 0, &ParseError{why: l18n.Sprintf("Invalid check timeout"), offender: s}
+	 }
+	 if m < 1 || m > 300 {
+		 return 0, &ParseError{why: l18n.Sprintf("Invalid check timeout"), offender: s}
+	 }
+	 return uint16(m), nil
+ }
+ 
+ // parseCheckHost accepts an address or host name to ping, or either of them with a port to connect
+ // to instead, the way endpoints are written.
+ func parseCheckHost(s string) (string, error) {
+	 if _, err := netip.ParseAddr(s); err == nil {
+		 return s, nil
+	 }
+	 host := s
+	 if strings.ContainsRune(s, ':') {
+		 var port string
+		 var err error
+		 host, port, err = net.SplitHostPort(s)
+		 if err != nil {
+			 return "", &ParseError{why: l18n.Sprintf("Invalid check host"), offender: s}
+		 }
+		 p, err := parsePort(port)
+		 if err != nil {
+			 return "", err
+		 }
+		 if p == 0 {
+			 return "", &ParseError{why: l18n.Sprintf("Invalid port"), offender: port}
+		 }
+	 }
+	 if len(host) == 0 || strings.ContainsAny(host, " \t/") {
+		 return "", &ParseError{why: l18n.Sprintf("Invalid check host"), offender: s}
+	 }
+	 return s, nil
+ }
+ 
+ // parseAdapterGUID accepts a GUID with or without its braces, other than the null GUID.
+ func parseAdapterGUID(s string) (*windows.GUID, error) {
+	 braced := s
+	 if !strings.HasPrefix(s, "{") {
+		 braced = "{" + s + "}"
+	 }
+	 guid, err := windows.GUIDFromString(braced)
+	 if err != nil || guid == (windows.GUID{}) {
+		 return nil, &ParseError{why: l18n.Sprintf("Invalid adapter GUID"), offender: s}
+	 }
+	 return &guid, nil
+ }
+ 
  func parsePort(s string) (uint16, error) {
 	 m, err := strconv.Atoi(s)
 	 if err != nil {
-		 return 0, err
+		 return 0, &ParseError{why: l18n.Sprintf("Invalid port"), offender: s}
 	 }
 	 if m < 0 || m > 65535 {
-		 return 0, &ParseError{l18n.Sprintf("Invalid port"), s}
+		 return 0, &ParseError{why: l18n.Sprintf("Invalid port"), offender: s}
 	 }
 	 return uint16(m), nil
  }
@@ -110,10 +201,10 @@
 	 }
 	 m, err := strconv.Atoi(s)
 	 if err != nil {
-		 return 0, err
+		 return 0, &ParseError{why: l18n.Sprintf("Invalid persistent keepalive"), offender: s}
 	 }
 	 if m < 0 || m > 65535 {
-		 return 0, &ParseError{l18n.Sprintf("Invalid persistent keepalive"), s}
+		 return 0, &ParseError{why: l18n.Sprintf("Invalid persistent keepalive"), offender: s}
 	 }
 	 return uint16(m), nil
  }
@@ -124,17 +215,38 @@
 	 } else if s == "auto" || s == "main" {
 		 return false, nil
 	 }
-	 _, err := strconv.ParseUint(s, 10, 32)
-	 return false, err
+	 if _, err := strconv.ParseUint(s, 10, 32); err != nil {
+		 return false, &ParseError{why: l18n.Sprintf("Invalid table"), offender: s}
+	 }
+	 return false, nil
+ }
+ 
+ // parseObfuscationValue parses one of the AmneziaWG parameters, whose ranges are those of its
+ // reference implementation.
+ func parseObfuscationValue(key, s string, max uint64) (uint64, error) {
+	 m, err := strconv.ParseUint(s, 10, 32)
+	 if err != nil || m > max {
+		 return 0, &ParseError{why: l18n.Sprintf("Invalid value for %s", key), offender: s}
+	 }
+	 return m, nil
+ }
+ 
+ func parseBool(s string) (bool, error) {
+	 if strings.EqualFold(s, "true") {
+		 return true, nil
+	 } else if strings.EqualFold(s, "false") {
+		 return false, nil
+	 }
+	 return false, &ParseError{why: l18n.Sprintf("Invalid boolean value"), offender: s}
  }
  
  func parseKeyBase64(s string) (*Key, error) {
 	 k, err := base64.StdEncoding.DecodeString(s)
 	 if err != nil {
-		 return nil, &ParseError{l18n.Sprintf("Invalid key: %v", err), s}
+		 return nil, &ParseError{why: l18n.Sprintf("Invalid key: %v", err), offender: s}
 	 }
 	 if len(k) != KeyLength {
-		 return nil, &ParseError{l18n.Sprintf("Keys must decode to exactly 32 bytes"), s}
+		 return nil, &ParseError{why: l18n.Sprintf("Keys must decode to exactly 32 bytes"), offender: s}
 	 }
 	 var key Key
 	 copy(key[:], k)
@@ -146,13 +258,26 @@
 	 for _, split := range strings.Split(s, ",") {
 		 trim := strings.TrimSpace(split)
 		 if len(trim) == 0 {
-			 return nil, &ParseError{l18n.Sprintf("Two commas in a row"), s}
+			 return nil, &ParseError{why: l18n.Sprintf("Two commas in a row"), offender: s}
 		 }
 		 out = append(out, trim)
 	 }
 	 return out, nil
  }
  
+ // Configurations arrive from zip files, QR codes, and the clipboard, so the parser refuses
+ // inputs that are far larger than any real configuration rather than allocating for them.
+ const (
+	 // MaxConfigSize is the largest configuration text that will be parsed.
+	 MaxConfigSize = 4 * 1024 * 1024
+	 // MaxConfigFileSize is the largest configuration file that will be parsed, since a UTF-32
+	 // encoding of MaxConfigSize bytes of text may be up to four times as long.
+	 MaxConfigFileSize = 4 * MaxConfigSize
+ 
+	 maxLineSize = 256 * 1024
+	 maxSections = 16384
+ )
+ 
  type parserState int
  
  const (
@@ -161,29 +286,80 @@
 	 notInASection
  )
  
+ // parsePeerNameComment recognizes the common convention of naming a peer with a comment line of
+ // the form "# Name = Alice's laptop" in its section.
+ func parsePeerNameComment(comment string) (string, bool) {
+	 key, val, ok := strings.Cut(comment, "=")
+	 if !ok || !strings.EqualFold(strings.TrimSpace(key), "name") {
+		 return "", false
+	 }
+	 val = strings.TrimSpace(val)
+	 return val, len(val) > 0
+ }
+ 
  func (c *Config) maybeAddPeer(p *Peer) {
 	 if p != nil {
+		 if len(p.DisallowedIPs) > 0 {
+			 p.AllowedIPs = CalculateAllowedIPs(p.AllowedIPs, p.DisallowedIPs)
+		 }
 		 c.Peers = append(c.Peers, *p)
 	 }
  }
  
- func FromWgQuick(s, name string) (*Config, error) {
+ func FromWgQuick(s, name string) (c *Config, err error) {
+	 // Errors found while parsing a line are attributed to it, and to its key, wherever they come from.
+	 var (
+		 lineNumber int
+		 rawLine    string
+		 lineKey    string
+	 )
+	 defer func() {
+		 var parseErr *ParseError
+		 if lineNumber == 0 || !errors.As(err, &parseErr) || parseErr.Line != 0 {
+			 return
+		 }
+		 parseErr.Line = lineNumber
+		 parseErr.Key = lineKey
+		 if column := strings.Index(rawLine, parseErr.offender); column >= 0 && len(parseErr.offender) > 0 {
+			 parseErr.Column = utf8.RuneCountInString(rawLine[:column]) + 1
+		 }
+	 }()
 	 if !TunnelNameIsValid(name) {
-		 return nil, &ParseError{l18n.Sprintf("Tunnel name is not valid"), name}
+		 return nil, &ParseError{why: l18n.Sprintf("Tunnel name is not valid"), offender: name}
+	 }
+	 if len(s) > MaxConfigSize {
+		 return nil, &ParseError{why: l18n.Sprintf("Configuration is too large"), offender: Bytes(len(s)).String()}
 	 }
 	 lines := strings.Split(s, "\n")
 	 state := notInASection
 	 conf := Config{Name: name}
 	 sawPrivateKey := false
+	 sections := 0
 	 var peer *Peer
-	 for _, line := range lines {
+	 for i, line := range lines {
+		 lineNumber, rawLine, lineKey = i+1, line, ""
+		 if len(line) > maxLineSize {
+			 return nil, &ParseError{why: l18n.Sprintf("Line is too long"), offender: line[:64] + "…"}
+		 }
 		 // Entferne Kommentare und trimme Leerzeichen
-		 line, _, _ = strings.Cut(line, "#")
+		 var comment string
+		 line, comment, _ = strings.Cut(line, "#")
 		 line = strings.TrimSpace(line)
 		 if len(line) == 0 {
+			 if state == inPeerSection {
+				 if name, ok := parsePeerNameComment(comment); ok {
+					 peer.Name = name
+				 }
+			 }
 			 continue
 		 }
 		 // Erkenne Abschnittsüberschriften (ohne zusätzlichen Speicher für Kleinbuchstaben)
+		 if line[0] == '[' {
+			 sections++
+			 if sections > maxSections {
+				 return nil, &ParseError{why: l18n.Sprintf("Too many sections"), offender: line}
+			 }
+		 }
 		 if strings.EqualFold(line, "[interface]") {
 			 conf.maybeAddPeer(peer)
 			 state = inInterfaceSection
@@ -196,16 +372,17 @@
 			 continue
 		 }
 		 if state == notInASection {
-			 return nil, &ParseError{l18n.Sprintf("Line must occur in a section"), line}
+			 return nil, &ParseError{why: l18n.Sprintf("Line must occur in a section"), offender: line}
 		 }
 		 equals := strings.IndexByte(line, '=')
 		 if equals < 0 {
-			 return nil, &ParseError{l18n.Sprintf("Config key is missing an equals separator"), line}
+			 return nil, &ParseError{why: l18n.Sprintf("Config key is missing an equals separator"), offender: line}
 		 }
 		 key := strings.TrimSpace(line[:equals])
 		 val := strings.TrimSpace(line[equals+1:])
+		 lineKey = key
 		 if len(val) == 0 {
-			 return nil, &ParseError{l18n.Sprintf("Key must have a value"), line}
+			 return nil, &ParseError{why: l18n.Sprintf("Key must have a value"), offender: line}
 		 }
 		 if state == inInterfaceSection {
 			 if strings.EqualFold(key, "privatekey") {
@@ -227,6 +404,18 @@
 					 return nil, err
 				 }
 				 conf.Interface.MTU = m
+			 } else if strings.EqualFold(key, "interfacemetric") {
+				 m, err := parseMetric(val)
+				 if err != nil {
+					 return nil, err
+				 }
+				 conf.Interface.InterfaceMetric = m
+			 } else if strings.EqualFold(key, "routemetric") {
+				 m, err := parseMetric(val)
+				 if err != nil {
+					 return nil, err
+				 }
+				 conf.Interface.RouteMetric = m
 			 } else if strings.EqualFold(key, "address") {
 				 addresses, err := splitList(val)
 				 if err != nil {
@@ -253,21 +442,180 @@
 					 }
 				 }
 			 } else if strings.EqualFold(key, "preup") {
-				 conf.Interface.PreUp = val
+				 conf.Interface.PreUp = append(conf.Interface.PreUp, val)
 			 } else if strings.EqualFold(key, "postup") {
-				 conf.Interface.PostUp = val
+				 conf.Interface.PostUp = append(conf.Interface.PostUp, val)
 			 } else if strings.EqualFold(key, "predown") {
-				 conf.Interface.PreDown = val
+				 conf.Interface.PreDown = append(conf.Interface.PreDown, val)
 			 } else if strings.EqualFold(key, "postdown") {
-				 conf.Interface.PostDown = val
+				 conf.Interface.PostDown = append(conf.Interface.PostDown, val)
+			 } else if strings.EqualFold(key, "scripttimeout") {
+				 t, err := parseScriptTimeout(val)
+				 if err != nil {
+					 return nil, err
+				 }
+				 conf.Interface.ScriptTimeout = t
 			 } else if strings.EqualFold(key, "table") {
 				 tableOff, err := parseTableOff(val)
 				 if err != nil {
 					 return nil, err
 				 }
 				 conf.Interface.TableOff = tableOff
+			 } else if strings.EqualFold(key, "killswitch") {
+				 killSwitch, err := parseBool(val)
+				 if err != nil {
+					 return nil, err
+				 }
+				 conf.Interface.KillSwitch = killSwitch
+			 } else if strings.EqualFold(key, "disabletemporaryaddresses") {
+				 disable, err := parseBool(val)
+				 if err != nil {
+					 return nil, err
+				 }
+				 conf.Interface.DisableTemporaryAddresses = disable
+			 } else if strings.EqualFold(key, "disabledad") {
+				 disable, err := parseBool(val)
+				 if err != nil {
+					 return nil, err
+				 }
+				 conf.Interface.DisableDAD = disable
+			 } else if strings.EqualFold(key, "excludeips") {
+				 addresses, err := splitList(val)
+				 if err != nil {
+					 return nil, err
+				 }
+				 for _, address := range addresses {
+					 a, err := parseIPCidr(address)
+					 if err != nil {
+						 return nil, err
+					 }
+					 conf.Interface.ExcludeIPs = append(conf.Interface.ExcludeIPs, a)
+				 }
+			 } else if strings.EqualFold(key, "excludeports") {
+				 ports, err := splitList(val)
+				 if err != nil {
+					 return nil, err
+				 }
+				 for _, port := range ports {
+					 p, err := parsePort(port)
+					 if err != nil {
+						 return nil, err
+					 }
+					 if p == 0 {
+						 return nil, &ParseError{why: l18n.Sprintf("Invalid port"), offender: port}
+					 }
+					 conf.Interface.ExcludePorts = append(conf.Interface.ExcludePorts, p)
+				 }
+			 } else if strings.EqualFold(key, "excludeapps") {
+				 apps, err := splitList(val)
+				 if err != nil {
+					 return nil, err
+				 }
+				 for _, app := range apps {
+					 if !filepath.IsAbs(app) {
+						 return nil, &ParseError{why: l18n.Sprintf("Excluded programs must be given by their full path"), offender: app}
+					 }
+					 conf.Interface.ExcludeApps = append(conf.Interface.ExcludeApps, app)
+				 }
+			 } else if strings.EqualFold(key, "allowlocalnetwork") {
+				 allow, err := parseBool(val)
+				 if err != nil {
+					 return nil, err
+				 }
+				 conf.Interface.AllowLocalNetwork = allow
+			 } else if strings.EqualFold(key, "bindinterface") {
+				 conf.Interface.BindInterface = val
+			 } else if strings.EqualFold(key, "checkhost") {
+				 host, err := parseCheckHost(val)
+				 if err != nil {
+					 return nil, err
+				 }
+				 conf.Interface.CheckHost = host
+			 } else if strings.EqualFold(key, "checktimeout") {
+				 t, err := parseCheckTimeout(val)
+				 if err != nil {
+					 return nil, err
+				 }
+				 conf.Interface.CheckTimeout = t
+			 } else if strings.EqualFold(key, "adapterguid") {
+				 guid, err := parseAdapterGUID(val)
+				 if err != nil {
+					 return nil, err
+				 }
+				 conf.Interface.AdapterGUID = guid
+			 } else if strings.EqualFold(key, "peersource") {
+				 source, err := parsePeerSource(val)
+				 if err != nil {
+					 return nil, err
+				 }
+				 conf.Interface.PeerSource = source
+			 } else if strings.EqualFold(key, "peersourcekey") {
+				 _, err := parsePeerSourceKey(val)
+				 if err != nil {
+					 return nil, err
+				 }
+				 conf.Interface.PeerSourceKey = val
+			 } else if strings.EqualFold(key, "jc") {
+				 m, err := parseObfuscationValue(key, val, 128)
+				 if err != nil {
+					 return nil, err
+				 }
+				 conf.Interface.Obfuscation.Jc = uint16(m)
+			 } else if strings.EqualFold(key, "jmin") {
+				 m, err := parseObfuscationValue(key, val, 1280)
+				 if err != nil {
+					 return nil, err
+				 }
+				 conf.Interface.Obfuscation.Jmin = uint16(m)
+			 } else if strings.EqualFold(key, "jmax") {
+				 m, err := parseObfuscationValue(key, val, 1280)
+				 if err != nil {
+					 return nil, err
+				 }
+				 conf.Interface.Obfuscation.Jmax = uint16(m)
+			 } else if strings.EqualFold(key, "s1") {
+				 m, err := parseObfuscationValue(key, val, 1132)
+				 if err != nil {
+					 return nil, err
+				 }
+				 conf.Interface.Obfuscation.S1 = uint16(m)
+			 } else if strings.EqualFold(key, "s2") {
+				 m, err := parseObfuscationValue(key, val, 1188)
+				 if err != nil {
+					 return nil, err
+				 }
+				 conf.Interface.Obfuscation.S2 = uint16(m)
+			 } else if strings.EqualFold(key, "h1") {
+				 m, err := parseObfuscationValue(key, val, 0xffffffff)
+				 if err != nil {
+					 return nil, err
+				 }
+				 conf.Interface.Obfuscation.H1 = uint32(m)
+			 } else if strings.EqualFold(key, "h2") {
+				 m, err := parseObfuscationValue(key, val, 0xffffffff)
+				 if err != nil {
+					 return nil, err
+				 }
+				 conf.Interface.Obfuscation.H2 = uint32(m)
+			 } else if strings.EqualFold(key, "h3") {
+				 m, err := parseObfuscationValue(key, val, 0xffffffff)
+				 if err != nil {
+					 return nil, err
+				 }
+				 conf.Interface.Obfuscation.H3 = uint32(m)
+			 } else if strings.EqualFold(key, "h4") {
+				 m, err := parseObfuscationValue(key, val, 0xffffffff)
+				 if err != nil {
+					 return nil, err
+				 }
+				 conf.Interface.Obfuscation.H4 = uint32(m)
+			 } else if strings.EqualFold(key, "privatekeyprovider") {
+				 // The driver performs handshakes in the kernel and must be given the raw private key,
+				 // and the Platform Crypto Provider has no X25519 anyway, so there is nothing to delegate
+				 // the operation to. Say so, rather than calling the key invalid.
+				 return nil, &ParseError{why: l18n.Sprintf("Private keys held by a key storage provider are not supported"), offender: key}
 			 } else {
-				 return nil, &ParseError{l18n.Sprintf("Invalid key for [Interface] section"), key}
+				 return nil, &ParseError{why: l18n.Sprintf("Invalid key for [Interface] section"), offender: key}
 			 }
 		 } else if state == inPeerSection {
 			 if strings.EqualFold(key, "publickey") {
@@ -294,6 +642,18 @@
 					 }
 					 peer.AllowedIPs = append(peer.AllowedIPs, a)
 				 }
+			 } else if strings.EqualFold(key, "disallowedips") {
+				 addresses, err := splitList(val)
+				 if err != nil {
+					 return nil, err
+				 }
+				 for _, address := range addresses {
+					 a, err := parseIPCidr(address)
+					 if err != nil {
+						 return nil, err
+					 }
+					 peer.DisallowedIPs = append(peer.DisallowedIPs, a)
+				 }
 			 } else if strings.EqualFold(key, "persistentkeepalive") {
 				 p, err := parsePersistentKeepalive(val)
 				 if err != nil {
@@ -306,31 +666,45 @@
 					 return nil, err
 				 }
 				 peer.Endpoint = *e
+			 } else if strings.EqualFold(key, "name") {
+				 peer.Name = val
 			 } else {
-				 return nil, &ParseError{l18n.Sprintf("Invalid key for [Peer] section"), key}
+				 return nil, &ParseError{why: l18n.Sprintf("Invalid key for [Peer] section"), offender: key}
 			 }
 		 }
 	 }
+	 lineNumber = 0
 	 conf.maybeAddPeer(peer)
 	 if !sawPrivateKey {
-		 return nil, &ParseError{l18n.Sprintf("An interface must have a private key"), l18n.Sprintf("[none specified]")}
+		 return nil, &ParseError{why: l18n.Sprintf("An interface must have a private key"), offender: l18n.Sprintf("[none specified]")}
 	 }
 	 for _, p := range conf.Peers {
 		 if p.PublicKey.IsZero() {
-			 return nil, &ParseError{l18n.Sprintf("All peers must have public keys"), l18n.Sprintf("[none specified]")}
+			 return nil, &ParseError{why: l18n.Sprintf("All peers must have public keys"), offender: l18n.Sprintf("[none specified]")}
 		 }
 	 }
+	 if len(conf.Interface.PeerSource) > 0 && len(conf.Interface.PeerSourceKey) == 0 {
+		 return nil, &ParseError{why: l18n.Sprintf("A peer source must have a key to verify it with"), offender: conf.Interface.PeerSource}
+	 }
+	 if conf.Interface.Obfuscation.Jmin > conf.Interface.Obfuscation.Jmax {
+		 return nil, &ParseError{why: l18n.Sprintf("Jmin must not be greater than Jmax"), offender: strconv.Itoa(int(conf.Interface.Obfuscation.Jmin))}
+	 }
 	 return &conf, nil
  }
  
  func FromWgQuickWithUnknownEncoding(s, name string) (*Config, error) {
+	 if len(s) > MaxConfigFileSize {
+		 return nil, &ParseError{why: l18n.Sprintf("Configuration is too large"), offender: Bytes(len(s)).String()}
+	 }
 	 c, firstErr := FromWgQuick(s, name)
 	 if firstErr == nil {
 		 return c, nil
 	 }
 	 for _, encoding := range unicode.All {
 		 decoded, err := encoding.NewDecoder().String(s)
-		 if err == nil {
+		 // Decoders substitute U+FFFD for invalid sequences, such as unpaired surrogates,
+		 // which means that the input was not in this encoding to begin with.
+		 if err == nil && utf8.ValidString(decoded) && !strings.ContainsRune(decoded, utf8.RuneError) {
 			 c, err := FromWgQuick(decoded, name)
 			 if err == nil {
 				 return c, nil
@@ -344,15 +718,39 @@
 	 conf := Config{
 		 Name: existingConfig.Name,
 		 Interface: Interface{
-			 Addresses: existingConfig.Interface.Addresses,
-			 DNS:       existingConfig.Interface.DNS,
-			 DNSSearch: existingConfig.Interface.DNSSearch,
-			 MTU:       existingConfig.Interface.MTU,
-			 PreUp:     existingConfig.Interface.PreUp,
-			 PostUp:    existingConfig.Interface.PostUp,
-			 PreDown:   existingConfig.Interface.PreDown,
-			 PostDown:  existingConfig.Interface.PostDown,
-			 TableOff:  existingConfig.Interface.TableOff,
+			 Addresses:  existingConfig.Interface.Addresses,
+			 DNS:        existingConfig.Interface.DNS,
+			 DNSSearch:  existingConfig.Interface.DNSSearch,
+			 MTU:        existingConfig.Interface.MTU,
+			 PreUp:      existingConfig.Interface.PreUp,
+			 PostUp:     existingConfig.Interface.PostUp,
+			 PreDown:    existingConfig.Interface.PreDown,
+			 PostDown:   existingConfig.Interface.PostDown,
+			 TableOff:   existingConfig.Interface.TableOff,
+			 KillSwitch: existingConfig.Interface.KillSwitch,
+
+			 ScriptTimeout: existingConfig.Interface.ScriptTimeout,
+
+			 DisableTemporaryAddresses: existingConfig.Interface.DisableTemporaryAddresses,
+			 DisableDAD:                existingConfig.Interface.DisableDAD,
+			 InterfaceMetric:           existingConfig.Interface.InterfaceMetric,
+			 RouteMetric:               existingConfig.Interface.RouteMetric,
+
+			 ExcludeIPs:   existingConfig.Interface.ExcludeIPs,
+			 ExcludePorts: existingConfig.Interface.ExcludePorts,
+			 ExcludeApps:  existingConfig.Interface.ExcludeApps,
+
+			 AllowLocalNetwork: existingConfig.Interface.AllowLocalNetwork,
+			 BindInterface:     existingConfig.Interface.BindInterface,
+ 
+			 CheckHost:    existingConfig.Interface.CheckHost,
+			 CheckTimeout: existingConfig.Interface.CheckTimeout,
+			 AdapterGUID:  existingConfig.Interface.AdapterGUID,
+ 
+			 PeerSource:    existingConfig.Interface.PeerSource,
+			 PeerSourceKey: existingConfig.Interface.PeerSourceKey,
+ 
+			 Obfuscation: existingConfig.Interface.Obfuscation,
 		 },
 	 }
 	 if interfaze.Flags&driver.InterfaceHasPrivateKey != 0 {
@@ -371,6 +769,13 @@
 		 peer := Peer{}
 		 if p.Flags&driver.PeerHasPublicKey != 0 {
 			 peer.PublicKey = p.PublicKey
+			 for j := range existingConfig.Peers {
+				 if existingConfig.Peers[j].PublicKey == peer.PublicKey {
+					 peer.Name = existingConfig.Peers[j].Name
+					 peer.DisallowedIPs = existingConfig.Peers[j].DisallowedIPs
+					 break
+				 }
+			 }
 		 }
 		 if p.Flags&driver.PeerHasPresharedKey != 0 {
 			 peer.PresharedKey = p.PresharedKey
@@ -6,9 +6,11 @@
 package conf
 
 import (
+	"errors"
 	"net/netip"
 	"reflect"
 	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -127,3 +129,282 @@ func TestParseEndpoint(t *testing.T) {
 		t.Error("Error was expected")
 	}
 }
+
+func TestAddressOptions(t *testing.T) {
+	conf, err := FromWgQuick(testInput, "test")
+	if noError(t, err) {
+		equal(t, false, conf.Interface.DisableTemporaryAddresses)
+		equal(t, false, conf.Interface.DisableDAD)
+	}
+	conf, err = FromWgQuick(testInput+"\n[Interface]\nDisableTemporaryAddresses = true\nDisableDAD = true", "test")
+	if noError(t, err) {
+		equal(t, true, conf.Interface.DisableTemporaryAddresses)
+		equal(t, true, conf.Interface.DisableDAD)
+		conf, err = FromWgQuick(conf.ToWgQuick(), "test")
+		if noError(t, err) {
+			equal(t, true, conf.Interface.DisableTemporaryAddresses)
+			equal(t, true, conf.Interface.DisableDAD)
+		}
+	}
+	_, err = FromWgQuick(testInput+"\n[Interface]\nDisableDAD = sometimes", "test")
+	if err == nil {
+		t.Error("Error was expected")
+	}
+}
+
+func TestKillSwitch(t *testing.T) {
+	conf, err := FromWgQuick(testInput, "test")
+	if noError(t, err) {
+		equal(t, false, conf.Interface.KillSwitch)
+	}
+	conf, err = FromWgQuick(testInput+"\n[Interface]\nKillSwitch = true", "test")
+	if noError(t, err) {
+		equal(t, true, conf.Interface.KillSwitch)
+		conf, err = FromWgQuick(conf.ToWgQuick(), "test")
+		if noError(t, err) {
+			equal(t, true, conf.Interface.KillSwitch)
+		}
+	}
+	_, err = FromWgQuick(testInput+"\n[Interface]\nKillSwitch = maybe", "test")
+	if err == nil {
+		t.Error("Error was expected")
+	}
+}
+
+func TestMultipleScripts(t *testing.T) {
+	conf, err := FromWgQuick(testInput+"\n[Interface]\nPreUp = echo one\nPostUp = echo up\nPreUp = echo two", "test")
+	if noError(t, err) {
+		equal(t, []string{"echo one", "echo two"}, conf.Interface.PreUp)
+		equal(t, []string{"echo up"}, conf.Interface.PostUp)
+		conf, err = FromWgQuick(conf.ToWgQuick(), "test")
+		if noError(t, err) {
+			equal(t, []string{"echo one", "echo two"}, conf.Interface.PreUp)
+			equal(t, []string{"echo up"}, conf.Interface.PostUp)
+		}
+	}
+}
+
+func TestScriptTimeout(t *testing.T) {
+	conf, err := FromWgQuick(testInput, "test")
+	if noError(t, err) {
+		equal(t, uint16(0), conf.Interface.ScriptTimeout)
+	}
+	conf, err = FromWgQuick(testInput+"\n[Interface]\nScriptTimeout = 120", "test")
+	if noError(t, err) {
+		equal(t, uint16(120), conf.Interface.ScriptTimeout)
+		conf, err = FromWgQuick(conf.ToWgQuick(), "test")
+		if noError(t, err) {
+			equal(t, uint16(120), conf.Interface.ScriptTimeout)
+		}
+	}
+	for _, invalid := range []string{"0", "3601", "soon"} {
+		_, err = FromWgQuick(testInput+"\n[Interface]\nScriptTimeout = "+invalid, "test")
+		if err == nil {
+			t.Errorf("Error was expected for %q", invalid)
+		}
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	for _, host := range []string{"10.0.0.1", "fd00::1", "intranet.example.com", "10.0.0.1:443", "[fd00::1]:443", "intranet.example.com:80"} {
+		conf, err := FromWgQuick(testInput+"\n[Interface]\nCheckHost = "+host+"\nCheckTimeout = 20", "test")
+		if noError(t, err) {
+			equal(t, host, conf.Interface.CheckHost)
+			equal(t, uint16(20), conf.Interface.CheckTimeout)
+			conf, err = FromWgQuick(conf.ToWgQuick(), "test")
+			if noError(t, err) {
+				equal(t, host, conf.Interface.CheckHost)
+				equal(t, uint16(20), conf.Interface.CheckTimeout)
+			}
+		}
+	}
+	for _, invalid := range []string{"10.0.0.1:0", "10.0.0.1:http", ":443", "fd00::1:443:x]", "intranet.example.com:99999"} {
+		_, err := FromWgQuick(testInput+"\n[Interface]\nCheckHost = "+invalid, "test")
+		if err == nil {
+			t.Errorf("Error was expected for %q", invalid)
+		}
+	}
+	for _, invalid := range []string{"0", "301", "soon"} {
+		_, err := FromWgQuick(testInput+"\n[Interface]\nCheckTimeout = "+invalid, "test")
+		if err == nil {
+			t.Errorf("Error was expected for %q", invalid)
+		}
+	}
+}
+
+func TestAdapterGUID(t *testing.T) {
+	conf, err := FromWgQuick(testInput, "test")
+	if noError(t, err) && conf.Interface.AdapterGUID != nil {
+		t.Errorf("Adapter GUID is %v without being configured", conf.Interface.AdapterGUID)
+	}
+	for _, guid := range []string{"{1B3D5F7A-0000-4000-8000-000000000001}", "1b3d5f7a-0000-4000-8000-000000000001"} {
+		conf, err = FromWgQuick(testInput+"\n[Interface]\nAdapterGUID = "+guid, "test")
+		if noError(t, err) {
+			equal(t, "{1B3D5F7A-0000-4000-8000-000000000001}", conf.Interface.AdapterGUID.String())
+			conf, err = FromWgQuick(conf.ToWgQuick(), "test")
+			if noError(t, err) {
+				equal(t, "{1B3D5F7A-0000-4000-8000-000000000001}", conf.Interface.AdapterGUID.String())
+			}
+		}
+	}
+	for _, invalid := range []string{"{00000000-0000-0000-0000-000000000000}", "{1b3d5f7a-0000-4000-8000}", "adapter"} {
+		_, err = FromWgQuick(testInput+"\n[Interface]\nAdapterGUID = "+invalid, "test")
+		if err == nil {
+			t.Errorf("Error was expected for %q", invalid)
+		}
+	}
+}
+
+func TestAllowLocalNetwork(t *testing.T) {
+	conf, err := FromWgQuick(testInput, "test")
+	if noError(t, err) {
+		equal(t, false, conf.Interface.AllowLocalNetwork)
+	}
+	conf, err = FromWgQuick(testInput+"\n[Interface]\nAllowLocalNetwork = true", "test")
+	if noError(t, err) {
+		equal(t, true, conf.Interface.AllowLocalNetwork)
+		conf, err = FromWgQuick(conf.ToWgQuick(), "test")
+		if noError(t, err) {
+			equal(t, true, conf.Interface.AllowLocalNetwork)
+		}
+	}
+	_, err = FromWgQuick(testInput+"\n[Interface]\nAllowLocalNetwork = sometimes", "test")
+	if err == nil {
+		t.Error("Error was expected")
+	}
+}
+
+func TestBindInterface(t *testing.T) {
+	conf, err := FromWgQuick(testInput+"\n[Interface]\nBindInterface = Mobile Broadband", "test")
+	if noError(t, err) {
+		equal(t, "Mobile Broadband", conf.Interface.BindInterface)
+		conf, err = FromWgQuick(conf.ToWgQuick(), "test")
+		if noError(t, err) {
+			equal(t, "Mobile Broadband", conf.Interface.BindInterface)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	conf, err := FromWgQuick(testInput, "test")
+	if noError(t, err) {
+		equal(t, uint32(0), conf.Interface.InterfaceMetric)
+		equal(t, uint32(0), conf.Interface.RouteMetric)
+	}
+	conf, err = FromWgQuick(testInput+"\n[Interface]\nInterfaceMetric = 5\nRouteMetric = 100", "test")
+	if noError(t, err) {
+		equal(t, uint32(5), conf.Interface.InterfaceMetric)
+		equal(t, uint32(100), conf.Interface.RouteMetric)
+		conf, err = FromWgQuick(conf.ToWgQuick(), "test")
+		if noError(t, err) {
+			equal(t, uint32(5), conf.Interface.InterfaceMetric)
+			equal(t, uint32(100), conf.Interface.RouteMetric)
+		}
+	}
+	for _, invalid := range []string{"InterfaceMetric = 0", "RouteMetric = 10000", "RouteMetric = low"} {
+		_, err = FromWgQuick(testInput+"\n[Interface]\n"+invalid, "test")
+		if err == nil {
+			t.Errorf("Error was expected for %q", invalid)
+		}
+	}
+}
+
+func TestObfuscation(t *testing.T) {
+	conf, err := FromWgQuick(testInput, "test")
+	if noError(t, err) {
+		equal(t, true, conf.Interface.Obfuscation.IsEmpty())
+	}
+	want := Obfuscation{Jc: 4, Jmin: 40, Jmax: 70, S1: 15, S2: 92, H1: 1, H2: 2, H3: 3, H4: 4294967295}
+	conf, err = FromWgQuick(testInput+"\n[Interface]\nJc = 4\nJmin = 40\nJmax = 70\nS1 = 15\nS2 = 92\nH1 = 1\nH2 = 2\nH3 = 3\nH4 = 4294967295", "test")
+	if noError(t, err) {
+		equal(t, want, conf.Interface.Obfuscation)
+		conf, err = FromWgQuick(conf.ToWgQuick(), "test")
+		if noError(t, err) {
+			equal(t, want, conf.Interface.Obfuscation)
+		}
+	}
+	_, err = FromWgQuick(testInput+"\n[Interface]\nPrivateKeyProvider = tpm:wireguard", "test")
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		equal(t, "PrivateKeyProvider", parseErr.Key)
+		if strings.Contains(parseErr.Error(), "tpm:wireguard") {
+			t.Errorf("Error quotes the provider instead of the key: %v", parseErr)
+		}
+	} else {
+		t.Errorf("Expected a parse error for a private key provider, got %v", err)
+	}
+	for _, invalid := range []string{"Jc = 129", "Jmin = 80\nJmax = 40", "S1 = -1", "H1 = 4294967296"} {
+		_, err = FromWgQuick(testInput+"\n[Interface]\n"+invalid, "test")
+		if err == nil {
+			t.Errorf("Error was expected for %q", invalid)
+		}
+	}
+}
+
+func TestPeerNames(t *testing.T) {
+	input := testInput + "\n# Name = not a peer\n" +
+		"\n[Peer]\n# Name = Alice's laptop\nPublicKey = xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=\n" +
+		"\n[Peer]\nName = Bob\nPublicKey = TrMvSoP4jYQlY6RIzBgbssQqY3vxI2Pi+y71lOWWXX0= # comment\n"
+	conf, err := FromWgQuick(input, "test")
+	if !noError(t, err) || !lenTest(t, conf.Peers, 5) {
+		return
+	}
+	equal(t, "", conf.Peers[2].Name)
+	equal(t, "Alice's laptop", conf.Peers[3].Name)
+	equal(t, "Bob", conf.Peers[4].Name)
+	equal(t, "gN65…z6EA", conf.Peers[2].DisplayName())
+	equal(t, "Bob", conf.Peers[4].DisplayName())
+	conf, err = FromWgQuick(conf.ToWgQuick(), "test")
+	if noError(t, err) && lenTest(t, conf.Peers, 5) {
+		equal(t, "Alice's laptop", conf.Peers[3].Name)
+		equal(t, "Bob", conf.Peers[4].Name)
+	}
+}
+
+func TestSizeLimits(t *testing.T) {
+	_, err := FromWgQuick(testInput+strings.Repeat("\n", MaxConfigSize), "test")
+	if err == nil {
+		t.Error("Expected oversized configuration to fail")
+	}
+	_, err = FromWgQuick(testInput+"\n[Peer]\nEndpoint = "+strings.Repeat("a", maxLineSize), "test")
+	if err == nil {
+		t.Error("Expected overlong line to fail")
+	}
+	_, err = FromWgQuick(testInput+strings.Repeat("\n[Interface]", maxSections), "test")
+	if err == nil {
+		t.Error("Expected too many sections to fail")
+	}
+	_, err = FromWgQuickWithUnknownEncoding(strings.Repeat(" ", MaxConfigFileSize+1), "test")
+	if err == nil {
+		t.Error("Expected oversized encoded configuration to fail")
+	}
+}
+
+func TestParseErrorLine(t *testing.T) {
+	var parseErr *ParseError
+	_, err := FromWgQuick("[Interface]\nPrivateKey = yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=\n\nListenPort = 70000\n", "test")
+	if errors.As(err, &parseErr) {
+		equal(t, 4, parseErr.Line)
+		equal(t, 14, parseErr.Column)
+		equal(t, "ListenPort", parseErr.Key)
+		equal(t, `Line 4: Invalid port: "70000"`, parseErr.Error())
+	} else {
+		t.Errorf("Expected a parse error, got %v", err)
+	}
+	_, err = FromWgQuick("[Interface]\nListenPort = 51820\n", "test")
+	if errors.As(err, &parseErr) {
+		equal(t, 0, parseErr.Line)
+		equal(t, "", parseErr.Key)
+	} else {
+		t.Errorf("Expected a parse error, got %v", err)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add([]byte(testInput))
+	f.Add([]byte("[Interface]\nPrivateKey = yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=\nKillSwitch = true\n"))
+	f.Add([]byte("\xff\xfe[\x00I\x00n\x00t\x00e\x00r\x00f\x00a\x00c\x00e\x00]\x00"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ParseForFuzz(data)
+	})
+}
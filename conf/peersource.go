@@ -0,0 +1,215 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"golang.zx2c4.com/wireguard/windows/l18n"
+)
+
+/*
+ * A tunnel with a PeerSource takes peers, beyond those of its configuration, from a document at
+ * that https URL, signed like the file list of the updater, with the key of PeerSourceKey:
+ *
+ *   $ signify -S -e -s peers.sec -m peers.json
+ *   $ upload ./peers.json.sig
+ *
+ * where peers.json is, for example:
+ *
+ *   {"Serial": 42, "Peers": [{"Name": "branch", "PublicKey": "…", "AllowedIPs": ["10.1.0.0/24"],
+ *     "Endpoint": "branch.example.com:51820", "PersistentKeepalive": 25}]}
+ *
+ * The serial must grow with each document, so that an old one cannot be served again in place of
+ * a newer one. The serial of the last document applied is kept in a file, so that this holds
+ * across restarts of the tunnel too, until the source or its key changes.
+ */
+
+const MaxPeerSourceSize = 4 * MaxConfigFileSize
+
+type peerSourcePeer struct {
+	Name                string
+	PublicKey           string
+	AllowedIPs          []string
+	Endpoint            string
+	PersistentKeepalive uint16
+}
+
+type PeerSourceDocument struct {
+	Serial uint64
+	Peers  []Peer
+}
+
+// PeerSourceState is the serial of the last document that a tunnel applied from its peer source.
+type PeerSourceState struct {
+	Source string `json:"source"`
+	Key    string `json:"key"`
+	Serial uint64 `json:"serial"`
+}
+
+// Accepts returns whether a document with serial, fetched from source and verified with key, may
+// be applied, which it may not if it is older than the last one applied from the same source.
+func (state *PeerSourceState) Accepts(source, key string, serial uint64) bool {
+	return state.Source != source || state.Key != key || serial >= state.Serial
+}
+
+func peerSourceStatePath(name string) (string, error) {
+	if !TunnelNameIsValid(name) {
+		return "", errors.New("Tunnel name is not valid")
+	}
+	root, err := RootDirectory(true)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, "Peer Sources")
+	err = os.Mkdir(dir, os.ModeDir|0o700)
+	if err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// LoadPeerSourceState returns the peer source state of the named tunnel, which is zero if it
+// never applied a document.
+func LoadPeerSourceState(name string) (PeerSourceState, error) {
+	path, err := peerSourceStatePath(name)
+	if err != nil {
+		return PeerSourceState{}, err
+	}
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return PeerSourceState{}, nil
+	} else if err != nil {
+		return PeerSourceState{}, err
+	}
+	var state PeerSourceState
+	err = json.Unmarshal(bytes, &state)
+	if err != nil {
+		return PeerSourceState{}, err
+	}
+	return state, nil
+}
+
+func SavePeerSourceState(name string, state PeerSourceState) error {
+	path, err := peerSourceStatePath(name)
+	if err != nil {
+		return err
+	}
+	bytes, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return writeLockedDownFile(path, true, bytes)
+}
+
+func DeletePeerSourceState(name string) error {
+	path, err := peerSourceStatePath(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func parsePeerSource(s string) (string, error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "https" || len(u.Host) == 0 {
+		return "", &ParseError{why: l18n.Sprintf("Peer sources must be https URLs"), offender: s}
+	}
+	return s, nil
+}
+
+// parsePeerSourceKey returns the Ed25519 key of a signify public key, with its algorithm and key
+// number in front, like the key of the updater.
+func parsePeerSourceKey(s string) ([]byte, error) {
+	publicKeyBytes, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(publicKeyBytes) != ed25519.PublicKeySize+10 || publicKeyBytes[0] != 'E' || publicKeyBytes[1] != 'd' {
+		return nil, &ParseError{why: l18n.Sprintf("Invalid peer source key"), offender: s}
+	}
+	return publicKeyBytes, nil
+}
+
+// ReadPeerSourceDocument verifies the signature of a document fetched from a peer source with
+// publicKey, and returns the peers that it describes.
+func ReadPeerSourceDocument(input []byte, publicKey string) (*PeerSourceDocument, error) {
+	publicKeyBytes, err := parsePeerSourceKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	lines := bytes.SplitN(input, []byte{'\n'}, 3)
+	if len(lines) != 3 {
+		return nil, errors.New("Signature input has too few lines")
+	}
+	if !bytes.HasPrefix(lines[0], []byte("untrusted comment: ")) {
+		return nil, errors.New("Signature input is missing untrusted comment")
+	}
+	signatureBytes, err := base64.StdEncoding.DecodeString(string(lines[1]))
+	if err != nil {
+		return nil, errors.New("Signature input is not valid base64")
+	}
+	if len(signatureBytes) != ed25519.SignatureSize+10 || !bytes.Equal(signatureBytes[:10], publicKeyBytes[:10]) {
+		return nil, errors.New("Signature input bytes are incorrect length, type, or keyid")
+	}
+	if !ed25519.Verify(publicKeyBytes[10:], lines[2], signatureBytes[10:]) {
+		return nil, errors.New("Signature is invalid")
+	}
+	var document struct {
+		Serial uint64
+		Peers  []peerSourcePeer
+	}
+	err = json.Unmarshal(lines[2], &document)
+	if err != nil {
+		return nil, err
+	}
+	result := &PeerSourceDocument{Serial: document.Serial, Peers: make([]Peer, 0, len(document.Peers))}
+	seen := make(map[Key]bool, len(document.Peers))
+	for i := range document.Peers {
+		peer, err := document.Peers[i].toPeer()
+		if err != nil {
+			return nil, fmt.Errorf("Peer %d: %w", i+1, err)
+		}
+		if seen[peer.PublicKey] {
+			return nil, fmt.Errorf("Peer %d: %w", i+1, errors.New("Two peers have the same public key"))
+		}
+		seen[peer.PublicKey] = true
+		result.Peers = append(result.Peers, *peer)
+	}
+	return result, nil
+}
+
+func (p *peerSourcePeer) toPeer() (*Peer, error) {
+	publicKey, err := parseKeyBase64(p.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	peer := &Peer{Name: p.Name, PublicKey: *publicKey, PersistentKeepalive: p.PersistentKeepalive}
+	for _, address := range p.AllowedIPs {
+		a, err := parseIPCidr(address)
+		if err != nil {
+			return nil, err
+		}
+		peer.AllowedIPs = append(peer.AllowedIPs, a)
+	}
+	if len(p.Endpoint) > 0 {
+		e, err := parseEndpoint(p.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		peer.Endpoint = *e
+	}
+	return peer, nil
+}
@@ -0,0 +1,103 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/netip"
+	"testing"
+)
+
+// signPeerSource signs document the way signify -S -e does, and returns the result with the
+// public key to verify it with.
+func signPeerSource(t *testing.T, document string) ([]byte, string) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := []byte{'E', 'd', 1, 2, 3, 4, 5, 6, 7, 8}
+	signature := append(append([]byte{}, header...), ed25519.Sign(privateKey, []byte(document))...)
+	signed := "untrusted comment: verify with peers.pub\n" + base64.StdEncoding.EncodeToString(signature) + "\n" + document
+	return []byte(signed), base64.StdEncoding.EncodeToString(append(header, publicKey...))
+}
+
+func TestReadPeerSourceDocument(t *testing.T) {
+	input, publicKey := signPeerSource(t, `{"Serial": 42, "Peers": [
+		{"Name": "branch", "PublicKey": "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=", "AllowedIPs": ["10.1.0.0/24"], "Endpoint": "192.95.5.67:1234", "PersistentKeepalive": 25},
+		{"PublicKey": "TrMvSoP4jYQlY6RIzBgbssQqY3vxI2Pi+y71lOWWXX0=", "AllowedIPs": ["10.2.0.0/24", "fd00:2::/64"]}]}`)
+	document, err := ReadPeerSourceDocument(input, publicKey)
+	if !noError(t, err) {
+		return
+	}
+	equal(t, uint64(42), document.Serial)
+	if len(document.Peers) != 2 {
+		t.Fatalf("Document has %d peers, expected 2", len(document.Peers))
+	}
+	equal(t, "branch", document.Peers[0].Name)
+	equal(t, "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=", document.Peers[0].PublicKey.String())
+	equal(t, []netip.Prefix{netip.MustParsePrefix("10.1.0.0/24")}, document.Peers[0].AllowedIPs)
+	equal(t, Endpoint{Host: "192.95.5.67", Port: 1234}, document.Peers[0].Endpoint)
+	equal(t, uint16(25), document.Peers[0].PersistentKeepalive)
+	equal(t, true, document.Peers[1].Endpoint.IsEmpty())
+	equal(t, 2, len(document.Peers[1].AllowedIPs))
+
+	tampered := append([]byte{}, input...)
+	tampered[len(tampered)-3] = '9'
+	if _, err := ReadPeerSourceDocument(tampered, publicKey); err == nil {
+		t.Error("Error was expected for a tampered document")
+	}
+	_, otherKey := signPeerSource(t, "{}")
+	if _, err := ReadPeerSourceDocument(input, otherKey); err == nil {
+		t.Error("Error was expected for another key")
+	}
+
+	for _, invalid := range []string{
+		`{"Serial": 1, "Peers": [{"PublicKey": "nonsense"}]}`,
+		`{"Serial": 1, "Peers": [{"PublicKey": "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=", "AllowedIPs": ["10.1.0.0/33"]}]}`,
+		`{"Serial": 1, "Peers": [{"PublicKey": "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg="}, {"PublicKey": "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg="}]}`,
+		`[]`,
+	} {
+		input, publicKey := signPeerSource(t, invalid)
+		if _, err := ReadPeerSourceDocument(input, publicKey); err == nil {
+			t.Errorf("Error was expected for %s", invalid)
+		}
+	}
+}
+
+func TestPeerSource(t *testing.T) {
+	_, publicKey := signPeerSource(t, "{}")
+	conf, err := FromWgQuick(testInput+"\n[Interface]\nPeerSource = https://fleet.example.com/peers.json.sig\nPeerSourceKey = "+publicKey, "test")
+	if noError(t, err) {
+		equal(t, "https://fleet.example.com/peers.json.sig", conf.Interface.PeerSource)
+		equal(t, publicKey, conf.Interface.PeerSourceKey)
+		conf, err = FromWgQuick(conf.ToWgQuick(), "test")
+		if noError(t, err) {
+			equal(t, "https://fleet.example.com/peers.json.sig", conf.Interface.PeerSource)
+			equal(t, publicKey, conf.Interface.PeerSourceKey)
+		}
+	}
+	for _, invalid := range []string{
+		"PeerSource = http://fleet.example.com/peers.json.sig\nPeerSourceKey = " + publicKey,
+		"PeerSource = https://fleet.example.com/peers.json.sig",
+		"PeerSource = https://fleet.example.com/peers.json.sig\nPeerSourceKey = xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=",
+	} {
+		_, err = FromWgQuick(testInput+"\n[Interface]\n"+invalid, "test")
+		if err == nil {
+			t.Errorf("Error was expected for %q", invalid)
+		}
+	}
+}
+
+func TestPeerSourceStateAccepts(t *testing.T) {
+	state := PeerSourceState{Source: "https://peers.example/peers.json.sig", Key: "key", Serial: 42}
+	equal(t, true, state.Accepts(state.Source, state.Key, 42))
+	equal(t, true, state.Accepts(state.Source, state.Key, 43))
+	equal(t, false, state.Accepts(state.Source, state.Key, 41))
+	equal(t, true, state.Accepts("https://other.example/peers.json.sig", state.Key, 1))
+	equal(t, true, state.Accepts(state.Source, "other key", 1))
+	equal(t, true, (&PeerSourceState{}).Accepts(state.Source, state.Key, 0))
+}
@@ -0,0 +1,122 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+type PitfallKind uint32
+
+const (
+	PitfallDNSCacheDisabled PitfallKind = iota + 1
+	PitfallOutdatedVirtioDriver
+	PitfallWeakHostSend
+	PitfallObfuscationUnsupported
+	PitfallEndpointPending
+	PitfallMTUTooLarge
+	PitfallMTULowered
+)
+
+// Pitfall is a problem with the system that the tunnel service found when it started. It does
+// not keep the tunnel from starting, but is likely to get in its way. Detail depends on the kind:
+// the offending interface for PitfallWeakHostSend, the unresolved host name for
+// PitfallEndpointPending, and the suggested or applied MTU for PitfallMTUTooLarge and
+// PitfallMTULowered.
+type Pitfall struct {
+	Kind   PitfallKind `json:"kind"`
+	Detail string      `json:"detail,omitempty"`
+}
+
+func (pitfall Pitfall) String() string {
+	switch pitfall.Kind {
+	case PitfallDNSCacheDisabled:
+		return `the "DNS Client" (dnscache) service is disabled; please re-enable it`
+	case PitfallOutdatedVirtioDriver:
+		return "the VirtIO network driver (NetKVM) is out of date and may cause known problems; please update to v100.85.104.20800 or later"
+	case PitfallWeakHostSend:
+		return fmt.Sprintf("the %q interface has Forwarding/WeakHostSend enabled, which will cause routing loops", pitfall.Detail)
+	case PitfallObfuscationUnsupported:
+		return "the configuration has AmneziaWG obfuscation parameters, which the driver does not support and ignores; peers that expect obfuscation will not be reachable"
+	case PitfallEndpointPending:
+		return fmt.Sprintf("the endpoint %q could not be resolved at boot; its peer is unreachable until it can be, which is retried in the background", pitfall.Detail)
+	case PitfallMTUTooLarge:
+		return fmt.Sprintf("many packets are too big for the path to the peers, which suggests that the MTU is too large; please try MTU = %s", pitfall.Detail)
+	case PitfallMTULowered:
+		return fmt.Sprintf("many packets were too big for the path to the peers, so the MTU was lowered to %s until the tunnel restarts", pitfall.Detail)
+	default:
+		return "Unknown pitfall"
+	}
+}
+
+func pitfallsPath(name string) (string, error) {
+	if !TunnelNameIsValid(name) {
+		return "", errors.New("Tunnel name is not valid")
+	}
+	root, err := RootDirectory(true)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, "Pitfalls")
+	err = os.Mkdir(dir, os.ModeDir|0o700)
+	if err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// LoadPitfalls returns the pitfalls that the service of the named tunnel found when it last
+// started, which are empty if it found none or never started.
+func LoadPitfalls(name string) ([]Pitfall, error) {
+	path, err := pitfallsPath(name)
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var pitfalls []Pitfall
+	err = json.Unmarshal(bytes, &pitfalls)
+	if err != nil {
+		return nil, err
+	}
+	return pitfalls, nil
+}
+
+// SavePitfalls saves the pitfalls found by the service of the named tunnel, or removes them if empty.
+func SavePitfalls(name string, pitfalls []Pitfall) error {
+	if len(pitfalls) == 0 {
+		return DeletePitfalls(name)
+	}
+	path, err := pitfallsPath(name)
+	if err != nil {
+		return err
+	}
+	bytes, err := json.Marshal(pitfalls)
+	if err != nil {
+		return err
+	}
+	return writeLockedDownFile(path, true, bytes)
+}
+
+func DeletePitfalls(name string) error {
+	path, err := pitfallsPath(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,33 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"testing"
+)
+
+func TestPitfallsStorage(t *testing.T) {
+	pitfalls := []Pitfall{{Kind: PitfallDNSCacheDisabled}, {Kind: PitfallWeakHostSend, Detail: "Ethernet"}}
+	err := SavePitfalls("golangTest", pitfalls)
+	if err != nil {
+		t.Fatalf("Unable to save pitfalls: %v", err)
+	}
+	loaded, err := LoadPitfalls("golangTest")
+	if err != nil {
+		t.Fatalf("Unable to load pitfalls: %v", err)
+	}
+	if len(loaded) != 2 || loaded[0] != pitfalls[0] || loaded[1] != pitfalls[1] {
+		t.Errorf("Loaded pitfalls differ: %+v", loaded)
+	}
+	err = SavePitfalls("golangTest", nil)
+	if err != nil {
+		t.Fatalf("Unable to clear pitfalls: %v", err)
+	}
+	loaded, err = LoadPitfalls("golangTest")
+	if err != nil || len(loaded) != 0 {
+		t.Errorf("Cleared pitfalls were not empty: %+v, %v", loaded, err)
+	}
+}
@@ -0,0 +1,45 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import "golang.org/x/sys/windows/registry"
+
+const policiesRegKey = `Software\Policies\WireGuard`
+
+// Policies are the restrictions that an organization places on WireGuard through Group Policy,
+// which bind administrators too.
+type Policies struct {
+	DisableConfigEditing     bool   // Tunnels may not be added, imported, or edited.
+	DisableTunnelDeletion    bool   // Tunnels may not be removed.
+	UpdateChannel            string // The update channel, which may not be changed, or empty.
+	ForceKillSwitch          bool   // Tunnels run with the kill switch, whatever their configuration says.
+	HideExitMenuItem         bool   // The tray menu has no item for quitting the manager.
+	RefuseConflictingTunnels bool   // Tunnels that conflict with running tunnels are not activated.
+}
+
+// LoadPolicies reads the policies from HKLM\Software\Policies\WireGuard. Unlike the values under
+// HKLM\Software\WireGuard, they are read anew each time, so that a Group Policy refresh takes
+// effect without restarting anything.
+func LoadPolicies() (policies Policies) {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, policiesRegKey, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if err != nil {
+		return
+	}
+	defer key.Close()
+	policyBool := func(name string) bool {
+		val, _, err := key.GetIntegerValue(name)
+		return err == nil && val != 0
+	}
+	policies.DisableConfigEditing = policyBool("DisableConfigEditing")
+	policies.DisableTunnelDeletion = policyBool("DisableTunnelDeletion")
+	policies.ForceKillSwitch = policyBool("ForceKillSwitch")
+	policies.HideExitMenuItem = policyBool("HideExitMenuItem")
+	policies.RefuseConflictingTunnels = policyBool("RefuseConflictingTunnels")
+	if channel, _, err := key.GetStringValue("UpdateChannel"); err == nil && UpdateChannelIsValid(channel) {
+		policies.UpdateChannel = channel
+	}
+	return
+}
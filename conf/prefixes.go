@@ -0,0 +1,113 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"net/netip"
+	"sort"
+)
+
+// prefixHalves splits a prefix into the two prefixes that are one bit longer.
+func prefixHalves(prefix netip.Prefix) (lower, upper netip.Prefix) {
+	bits := prefix.Bits()
+	b := prefix.Addr().AsSlice()
+	b[bits/8] |= 0x80 >> (bits % 8)
+	upperAddr, _ := netip.AddrFromSlice(b)
+	return netip.PrefixFrom(prefix.Addr(), bits+1), netip.PrefixFrom(upperAddr, bits+1)
+}
+
+// subtractPrefix returns the fewest prefixes that cover from but not exclude.
+func subtractPrefix(from, exclude netip.Prefix) []netip.Prefix {
+	if !from.Overlaps(exclude) {
+		return []netip.Prefix{from}
+	}
+	if exclude.Bits() <= from.Bits() {
+		return nil
+	}
+	// Walk down towards the excluded prefix, keeping the half that does not lead to it each time.
+	var remaining []netip.Prefix
+	for from.Bits() < exclude.Bits() {
+		lower, upper := prefixHalves(from)
+		if upper.Contains(exclude.Addr()) {
+			remaining = append(remaining, lower)
+			from = upper
+		} else {
+			remaining = append(remaining, upper)
+			from = lower
+		}
+	}
+	return remaining
+}
+
+// SubtractPrefixes returns prefixes that cover the addresses of from, except for those of
+// exclude. Prefixes of one family leave those of the other alone.
+func SubtractPrefixes(from, exclude []netip.Prefix) []netip.Prefix {
+	remaining := make([]netip.Prefix, 0, len(from))
+	for _, prefix := range from {
+		remaining = append(remaining, prefix.Masked())
+	}
+	for _, excluded := range exclude {
+		excluded = excluded.Masked()
+		next := make([]netip.Prefix, 0, len(remaining))
+		for _, prefix := range remaining {
+			next = append(next, subtractPrefix(prefix, excluded)...)
+		}
+		remaining = next
+	}
+	return remaining
+}
+
+// CompactPrefixes returns the fewest prefixes that cover the same addresses as the given ones,
+// sorted by family and address.
+func CompactPrefixes(prefixes []netip.Prefix) []netip.Prefix {
+	sorted := make([]netip.Prefix, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		sorted = append(sorted, prefix.Masked())
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if c := sorted[i].Addr().Compare(sorted[j].Addr()); c != 0 {
+			return c < 0
+		}
+		return sorted[i].Bits() < sorted[j].Bits()
+	})
+	compacted := make([]netip.Prefix, 0, len(sorted))
+	for _, prefix := range sorted {
+		if last := len(compacted) - 1; last >= 0 && compacted[last].Contains(prefix.Addr()) && compacted[last].Bits() <= prefix.Bits() {
+			continue
+		}
+		compacted = append(compacted, prefix)
+		// Sorted by address, the two halves of a prefix end up next to each other, and the prefix
+		// that replaces them may then pair up with the one before.
+		for last := len(compacted) - 1; last > 0; last = len(compacted) - 1 {
+			lower, upper := compacted[last-1], compacted[last]
+			if lower.Bits() != upper.Bits() || lower.Bits() == 0 {
+				break
+			}
+			parent := netip.PrefixFrom(lower.Addr(), lower.Bits()-1).Masked()
+			if parent.Addr() != lower.Addr() || !parent.Contains(upper.Addr()) {
+				break
+			}
+			compacted = append(compacted[:last-1], parent)
+		}
+	}
+	return compacted
+}
+
+// CalculateAllowedIPs returns the fewest prefixes that cover the allowed ones except for the
+// disallowed ones, such as all of 0.0.0.0/0 but 192.168.0.0/16, for peers that are to be sent
+// everything but a few ranges.
+func CalculateAllowedIPs(allowed, disallowed []netip.Prefix) []netip.Prefix {
+	return CompactPrefixes(SubtractPrefixes(allowed, disallowed))
+}
+
+// RoutedIPs returns the allowed IPs of the peer that are routed into the tunnel, which are all
+// of them except for the addresses of ExcludeIPs.
+func (config *Config) RoutedIPs(peer *Peer) []netip.Prefix {
+	if len(config.Interface.ExcludeIPs) == 0 {
+		return peer.AllowedIPs
+	}
+	return SubtractPrefixes(peer.AllowedIPs, config.Interface.ExcludeIPs)
+}
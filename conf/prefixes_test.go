@@ -0,0 +1,105 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func parsePrefixes(t *testing.T, s ...string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(s))
+	for _, p := range s {
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes
+}
+
+func TestSubtractPrefixes(t *testing.T) {
+	for _, test := range []struct {
+		from, exclude, want []string
+	}{
+		{[]string{"10.0.0.0/8"}, nil, []string{"10.0.0.0/8"}},
+		{[]string{"10.0.0.0/8"}, []string{"192.168.0.0/16"}, []string{"10.0.0.0/8"}},
+		{[]string{"10.1.0.0/16"}, []string{"10.0.0.0/8"}, []string{}},
+		{[]string{"10.0.0.0/30"}, []string{"10.0.0.1/32"}, []string{"10.0.0.2/31", "10.0.0.0/32"}},
+		{[]string{"0.0.0.0/0"}, []string{"128.0.0.0/1"}, []string{"0.0.0.0/1"}},
+		{[]string{"0.0.0.0/0", "::/0"}, []string{"192.168.0.0/16"}, []string{
+			"0.0.0.0/1", "128.0.0.0/2", "224.0.0.0/3", "208.0.0.0/4", "200.0.0.0/5", "196.0.0.0/6",
+			"194.0.0.0/7", "193.0.0.0/8", "192.0.0.0/9", "192.192.0.0/10", "192.128.0.0/11",
+			"192.176.0.0/12", "192.160.0.0/13", "192.172.0.0/14", "192.170.0.0/15", "192.169.0.0/16", "::/0",
+		}},
+		{[]string{"fd00::/8"}, []string{"fd00::/9", "fd80::/9"}, []string{}},
+	} {
+		got := SubtractPrefixes(parsePrefixes(t, test.from...), parsePrefixes(t, test.exclude...))
+		if want := parsePrefixes(t, test.want...); !reflect.DeepEqual(got, want) {
+			t.Errorf("%v minus %v is %v, want %v", test.from, test.exclude, got, want)
+		}
+	}
+}
+
+func TestCalculateAllowedIPs(t *testing.T) {
+	for _, test := range []struct {
+		allowed, disallowed, want []string
+	}{
+		{[]string{"10.0.0.0/31", "10.0.0.2/31"}, nil, []string{"10.0.0.0/30"}},
+		{[]string{"10.0.0.0/24", "10.0.0.128/25", "10.0.1.0/24"}, nil, []string{"10.0.0.0/23"}},
+		{[]string{"0.0.0.0/1", "128.0.0.0/1", "::/1", "8000::/1"}, nil, []string{"0.0.0.0/0", "::/0"}},
+		{[]string{"10.0.0.0/30"}, []string{"10.0.0.0/32", "10.0.0.1/32"}, []string{"10.0.0.2/31"}},
+		{[]string{"0.0.0.0/0"}, []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"}, []string{
+			"0.0.0.0/5", "8.0.0.0/7", "11.0.0.0/8", "12.0.0.0/6", "16.0.0.0/4", "32.0.0.0/3", "64.0.0.0/2",
+			"128.0.0.0/3", "160.0.0.0/5", "168.0.0.0/6", "172.0.0.0/12", "172.32.0.0/11", "172.64.0.0/10",
+			"172.128.0.0/9", "173.0.0.0/8", "174.0.0.0/7", "176.0.0.0/4", "192.0.0.0/9", "192.128.0.0/11",
+			"192.160.0.0/13", "192.169.0.0/16", "192.170.0.0/15", "192.172.0.0/14", "192.176.0.0/12",
+			"192.192.0.0/10", "193.0.0.0/8", "194.0.0.0/7", "196.0.0.0/6", "200.0.0.0/5", "208.0.0.0/4",
+			"224.0.0.0/3",
+		}},
+	} {
+		got := CalculateAllowedIPs(parsePrefixes(t, test.allowed...), parsePrefixes(t, test.disallowed...))
+		if want := parsePrefixes(t, test.want...); !reflect.DeepEqual(got, want) {
+			t.Errorf("%v but %v is %v, want %v", test.allowed, test.disallowed, got, want)
+		}
+	}
+
+	conf, err := FromWgQuick(testInput+"\n[Peer]\nPublicKey = sKMTnJjUrFW+EZHSCg2DsjKguWIbfTQ12uyb2DxpMNY=\nAllowedIPs = 0.0.0.0/0\nDisallowedIPs = 128.0.0.0/1", "test")
+	if noError(t, err) {
+		peer := &conf.Peers[len(conf.Peers)-1]
+		equal(t, parsePrefixes(t, "0.0.0.0/1"), peer.AllowedIPs)
+		equal(t, parsePrefixes(t, "128.0.0.0/1"), peer.DisallowedIPs)
+		conf, err = FromWgQuick(conf.ToWgQuick(), "test")
+		if noError(t, err) {
+			peer = &conf.Peers[len(conf.Peers)-1]
+			equal(t, parsePrefixes(t, "0.0.0.0/1"), peer.AllowedIPs)
+			equal(t, parsePrefixes(t, "128.0.0.0/1"), peer.DisallowedIPs)
+		}
+	}
+}
+
+func TestExclusions(t *testing.T) {
+	conf, err := FromWgQuick(testInput+"\n[Interface]\nExcludeIPs = 192.168.1.0/24, 10.0.0.1\nExcludePorts = 9100, 631\nExcludeApps = C:\\Program Files\\Agent\\agent.exe", "test")
+	if noError(t, err) {
+		equal(t, 2, len(conf.Interface.ExcludeIPs))
+		equal(t, []uint16{9100, 631}, conf.Interface.ExcludePorts)
+		equal(t, []string{`C:\Program Files\Agent\agent.exe`}, conf.Interface.ExcludeApps)
+		conf, err = FromWgQuick(conf.ToWgQuick(), "test")
+		if noError(t, err) {
+			equal(t, parsePrefixes(t, "192.168.1.0/24", "10.0.0.1/32"), conf.Interface.ExcludeIPs)
+			equal(t, []uint16{9100, 631}, conf.Interface.ExcludePorts)
+			equal(t, []string{`C:\Program Files\Agent\agent.exe`}, conf.Interface.ExcludeApps)
+		}
+	}
+	for _, invalid := range []string{"ExcludeIPs = 10.0.0.0/33", "ExcludePorts = 0", "ExcludePorts = 70000", "ExcludeApps = agent.exe"} {
+		_, err = FromWgQuick(testInput+"\n[Interface]\n"+invalid, "test")
+		if err == nil {
+			t.Errorf("Error was expected for %q", invalid)
+		}
+	}
+}
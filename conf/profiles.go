@@ -0,0 +1,232 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+)
+
+// Profiles let a tunnel reach its peers differently depending on where the computer is, such as
+// through an internal endpoint in the office and a public one elsewhere. Each profile overrides
+// the DNS servers of the interface and the endpoints and allowed IPs of some peers, given in
+// wg-quick syntax, and is selected by the networks it lists, unless the user picked one by hand.
+// When no profile matches, the configuration is used as it is.
+type Profiles struct {
+	Profiles []Profile `json:"profiles,omitempty"`
+	// Manual is the profile picked by the user, which overrides the networks, if any.
+	Manual string `json:"manual,omitempty"`
+	// Active is the profile in effect when the tunnel was last started, kept up to date by the
+	// manager, and applied by the tunnel service.
+	Active string `json:"active,omitempty"`
+}
+
+type Profile struct {
+	Name     string        `json:"name"`
+	SSIDs    []string      `json:"ssids,omitempty"`
+	Ethernet bool          `json:"ethernet,omitempty"`
+	DNS      []string      `json:"dns,omitempty"`
+	Peers    []ProfilePeer `json:"peers,omitempty"`
+}
+
+// ProfilePeer overrides the endpoint or allowed IPs of the peer with PublicKey, if not empty.
+type ProfilePeer struct {
+	PublicKey  string   `json:"public_key"`
+	Endpoint   string   `json:"endpoint,omitempty"`
+	AllowedIPs []string `json:"allowed_ips,omitempty"`
+}
+
+func (profiles *Profiles) IsEmpty() bool {
+	return len(profiles.Profiles) == 0
+}
+
+// Redact removes the public keys of the peers that the profiles override, like Config.Redact.
+func (profiles *Profiles) Redact() {
+	for i := range profiles.Profiles {
+		for j := range profiles.Profiles[i].Peers {
+			profiles.Profiles[i].Peers[j].PublicKey = ""
+		}
+	}
+}
+
+// Find returns the profile with the given name, or nil if there is none.
+func (profiles *Profiles) Find(name string) *Profile {
+	if len(name) == 0 {
+		return nil
+	}
+	for i := range profiles.Profiles {
+		if profiles.Profiles[i].Name == name {
+			return &profiles.Profiles[i]
+		}
+	}
+	return nil
+}
+
+// Select returns the profile to use on the given networks: the manual one if set, or otherwise
+// the first whose networks the computer is connected to, or nil if none matches.
+func (profiles *Profiles) Select(state *NetworkState) *Profile {
+	if profile := profiles.Find(profiles.Manual); profile != nil {
+		return profile
+	}
+	for i := range profiles.Profiles {
+		profile := &profiles.Profiles[i]
+		if profile.Ethernet && state.Ethernet {
+			return profile
+		}
+		for _, ssid := range state.SSIDs {
+			for _, profileSSID := range profile.SSIDs {
+				if ssid == profileSSID {
+					return profile
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Validate checks that the profiles have distinct names and that their overrides parse.
+func (profiles *Profiles) Validate() error {
+	seen := make(map[string]bool, len(profiles.Profiles))
+	for i := range profiles.Profiles {
+		profile := &profiles.Profiles[i]
+		if len(profile.Name) == 0 {
+			return errors.New("Profile name must not be empty")
+		}
+		if seen[profile.Name] {
+			return fmt.Errorf("Profile ‘%s’ is defined twice", profile.Name)
+		}
+		seen[profile.Name] = true
+		if err := profile.Apply(&Config{}); err != nil {
+			return fmt.Errorf("Profile ‘%s’: %w", profile.Name, err)
+		}
+	}
+	return nil
+}
+
+// Apply overrides the DNS servers and peers of config with those of the profile. Peers of the
+// profile that are not in config are skipped.
+func (profile *Profile) Apply(config *Config) error {
+	if len(profile.DNS) > 0 {
+		var dns []netip.Addr
+		var dnsSearch []string
+		for _, address := range profile.DNS {
+			a, err := netip.ParseAddr(address)
+			if err != nil {
+				dnsSearch = append(dnsSearch, address)
+			} else {
+				dns = append(dns, a)
+			}
+		}
+		config.Interface.DNS = dns
+		config.Interface.DNSSearch = dnsSearch
+	}
+	for _, profilePeer := range profile.Peers {
+		publicKey, err := parseKeyBase64(profilePeer.PublicKey)
+		if err != nil {
+			return err
+		}
+		var endpoint *Endpoint
+		if len(profilePeer.Endpoint) > 0 {
+			endpoint, err = parseEndpoint(profilePeer.Endpoint)
+			if err != nil {
+				return err
+			}
+		}
+		var allowedIPs []netip.Prefix
+		for _, allowedIP := range profilePeer.AllowedIPs {
+			prefix, err := parseIPCidr(allowedIP)
+			if err != nil {
+				return err
+			}
+			allowedIPs = append(allowedIPs, prefix)
+		}
+		for i := range config.Peers {
+			peer := &config.Peers[i]
+			if peer.PublicKey != *publicKey {
+				continue
+			}
+			if endpoint != nil {
+				peer.Endpoint = *endpoint
+			}
+			if allowedIPs != nil {
+				peer.AllowedIPs = allowedIPs
+			}
+		}
+	}
+	return nil
+}
+
+func profilesPath(name string) (string, error) {
+	if !TunnelNameIsValid(name) {
+		return "", errors.New("Tunnel name is not valid")
+	}
+	root, err := RootDirectory(true)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, "Profiles")
+	err = os.Mkdir(dir, os.ModeDir|0o700)
+	if err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// LoadProfiles returns the profiles of the named tunnel, which are empty if none have been saved.
+func LoadProfiles(name string) (*Profiles, error) {
+	path, err := profilesPath(name)
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Profiles{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var profiles Profiles
+	err = json.Unmarshal(bytes, &profiles)
+	if err != nil {
+		return nil, err
+	}
+	return &profiles, nil
+}
+
+// SaveProfiles saves the profiles of the named tunnel, or removes them if empty.
+func SaveProfiles(name string, profiles *Profiles) error {
+	if profiles.IsEmpty() {
+		return DeleteProfiles(name)
+	}
+	err := profiles.Validate()
+	if err != nil {
+		return err
+	}
+	path, err := profilesPath(name)
+	if err != nil {
+		return err
+	}
+	bytes, err := json.Marshal(profiles)
+	if err != nil {
+		return err
+	}
+	return writeLockedDownFile(path, true, bytes)
+}
+
+func DeleteProfiles(name string) error {
+	path, err := profilesPath(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"testing"
+)
+
+const profilesTestConfig = `[Interface]
+PrivateKey = yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=
+Address = 10.192.122.1/24
+DNS = 10.192.122.53
+
+[Peer]
+PublicKey = xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=
+Endpoint = 192.95.5.67:1234
+AllowedIPs = 0.0.0.0/0`
+
+func TestProfilesSelect(t *testing.T) {
+	profiles := Profiles{Profiles: []Profile{
+		{Name: "office", SSIDs: []string{"corp"}, Ethernet: true},
+		{Name: "home", SSIDs: []string{"home"}},
+	}}
+	tests := []struct {
+		state NetworkState
+		want  string
+	}{
+		{NetworkState{}, ""},
+		{NetworkState{SSIDs: []string{"cafe"}}, ""},
+		{NetworkState{SSIDs: []string{"home"}}, "home"},
+		{NetworkState{SSIDs: []string{"home"}, Ethernet: true}, "office"},
+		{NetworkState{SSIDs: []string{"corp"}}, "office"},
+	}
+	for _, test := range tests {
+		var got string
+		if profile := profiles.Select(&test.state); profile != nil {
+			got = profile.Name
+		}
+		if got != test.want {
+			t.Errorf("Select(%v) = %q, want %q", test.state.String(), got, test.want)
+		}
+	}
+
+	profiles.Manual = "home"
+	if profile := profiles.Select(&NetworkState{Ethernet: true}); profile == nil || profile.Name != "home" {
+		t.Errorf("Manual profile was not selected: %+v", profile)
+	}
+}
+
+func TestProfileApply(t *testing.T) {
+	config, err := FromWgQuick(profilesTestConfig, "golangTest")
+	if err != nil {
+		t.Fatalf("Unable to parse test config: %v", err)
+	}
+	profile := Profile{
+		Name: "office",
+		DNS:  []string{"10.0.0.53", "corp.example"},
+		Peers: []ProfilePeer{
+			{PublicKey: "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=", Endpoint: "10.1.1.1:51820", AllowedIPs: []string{"10.0.0.0/8"}},
+			{PublicKey: "TrMvSoP4jYQlY6RIzBgbssQqY3vxI2Pi+y71lOWWXX0=", Endpoint: "10.1.1.2:51820"},
+		},
+	}
+	err = profile.Apply(config)
+	if err != nil {
+		t.Fatalf("Unable to apply profile: %v", err)
+	}
+	if len(config.Interface.DNS) != 1 || config.Interface.DNS[0].String() != "10.0.0.53" || len(config.Interface.DNSSearch) != 1 || config.Interface.DNSSearch[0] != "corp.example" {
+		t.Errorf("DNS was not overridden: %v, %v", config.Interface.DNS, config.Interface.DNSSearch)
+	}
+	if len(config.Peers) != 1 {
+		t.Fatalf("Peers were added or removed: %d", len(config.Peers))
+	}
+	if config.Peers[0].Endpoint.String() != "10.1.1.1:51820" {
+		t.Errorf("Endpoint was not overridden: %s", config.Peers[0].Endpoint.String())
+	}
+	if len(config.Peers[0].AllowedIPs) != 1 || config.Peers[0].AllowedIPs[0].String() != "10.0.0.0/8" {
+		t.Errorf("Allowed IPs were not overridden: %v", config.Peers[0].AllowedIPs)
+	}
+
+	profile.Peers[0].Endpoint = "not an endpoint"
+	if profile.Apply(config) == nil {
+		t.Error("Invalid endpoint was applied")
+	}
+}
+
+func TestProfilesStorage(t *testing.T) {
+	profiles := &Profiles{Profiles: []Profile{{Name: "office", Ethernet: true}, {Name: "office"}}}
+	if SaveProfiles("golangTest", profiles) == nil {
+		t.Error("Profiles with duplicate names were saved")
+	}
+	profiles.Profiles[1].Name = "home"
+	profiles.Active = "home"
+	err := SaveProfiles("golangTest", profiles)
+	if err != nil {
+		t.Fatalf("Unable to save profiles: %v", err)
+	}
+	loaded, err := LoadProfiles("golangTest")
+	if err != nil {
+		t.Fatalf("Unable to load profiles: %v", err)
+	}
+	if len(loaded.Profiles) != 2 || loaded.Active != "home" || loaded.Find("office") == nil {
+		t.Errorf("Loaded profiles differ: %+v", loaded)
+	}
+	err = SaveProfiles("golangTest", &Profiles{})
+	if err != nil {
+		t.Fatalf("Unable to clear profiles: %v", err)
+	}
+	loaded, err = LoadProfiles("golangTest")
+	if err != nil || !loaded.IsEmpty() {
+		t.Errorf("Cleared profiles were not empty: %+v, %v", loaded, err)
+	}
+}
@@ -0,0 +1,100 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ProtectedTunnels are the tunnels that users must confirm their identity to activate from the
+// UI, with their password, PIN, or Windows Hello, which the manager verifies.
+type ProtectedTunnels struct {
+	Tunnels []string `json:"tunnels,omitempty"`
+}
+
+func (protected *ProtectedTunnels) Contains(tunnelName string) bool {
+	for _, t := range protected.Tunnels {
+		if t == tunnelName {
+			return true
+		}
+	}
+	return false
+}
+
+// Set protects or unprotects the named tunnel, and reports whether that changed anything.
+func (protected *ProtectedTunnels) Set(tunnelName string, protect bool) bool {
+	if protected.Contains(tunnelName) == protect {
+		return false
+	}
+	if protect {
+		protected.Tunnels = append(protected.Tunnels, tunnelName)
+		return true
+	}
+	tunnels := protected.Tunnels[:0]
+	for _, t := range protected.Tunnels {
+		if t != tunnelName {
+			tunnels = append(tunnels, t)
+		}
+	}
+	protected.Tunnels = tunnels
+	return true
+}
+
+func protectedTunnelsPath() (string, error) {
+	root, err := RootDirectory(true)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, "Protected.json"), nil
+}
+
+// LoadProtectedTunnels returns the protected tunnels, of which there are none if none were saved.
+func LoadProtectedTunnels() (*ProtectedTunnels, error) {
+	path, err := protectedTunnelsPath()
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &ProtectedTunnels{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var protected ProtectedTunnels
+	err = json.Unmarshal(bytes, &protected)
+	if err != nil {
+		return nil, err
+	}
+	return &protected, nil
+}
+
+// SaveProtectedTunnels saves the protected tunnels, or removes them if there are none.
+func SaveProtectedTunnels(protected *ProtectedTunnels) error {
+	path, err := protectedTunnelsPath()
+	if err != nil {
+		return err
+	}
+	if len(protected.Tunnels) == 0 {
+		err = os.Remove(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, t := range protected.Tunnels {
+		if !TunnelNameIsValid(t) {
+			return errors.New("Tunnel name is not valid")
+		}
+	}
+	bytes, err := json.Marshal(protected)
+	if err != nil {
+		return err
+	}
+	return writeLockedDownFile(path, true, bytes)
+}
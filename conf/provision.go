@@ -0,0 +1,71 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"errors"
+
+	"golang.zx2c4.com/wireguard/windows/l18n"
+)
+
+// Hubs are usually in the middle of a NAT, so clients keep the mapping open for the hub to reach them.
+const clientPersistentKeepalive = 25
+
+// IsHub reports whether other devices can be provisioned as clients of this tunnel, which takes a
+// listening port for them to connect to and addresses of its own to route their traffic to.
+func (conf *Config) IsHub() bool {
+	return conf.Interface.ListenPort != 0 && len(conf.Interface.Addresses) > 0
+}
+
+// NewClient provisions a new device that connects to the hub at endpoint, with addresses from
+// pools, which may be nil. It returns the complete configuration for the device, and the peer that
+// has to be added to the hub for it.
+func (conf *Config) NewClient(name, endpoint string, pools *AddressPools) (*Config, *Peer, error) {
+	if !conf.IsHub() {
+		return nil, nil, errors.New(l18n.Sprintf("Clients can only be created for tunnels with a listen port and addresses"))
+	}
+	if !TunnelNameIsValid(name) {
+		return nil, nil, errors.New(l18n.Sprintf("Invalid name"))
+	}
+	hubEndpoint, err := parseEndpoint(endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	addresses, err := pools.NextFree(conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	privateKey, err := NewPrivateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	presharedKey, err := NewPresharedKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	client := &Config{
+		Name: name,
+		Interface: Interface{
+			PrivateKey: *privateKey,
+			Addresses:  addresses,
+		},
+		Peers: []Peer{{
+			Name:                conf.Name,
+			PublicKey:           *conf.Interface.PrivateKey.Public(),
+			PresharedKey:        *presharedKey,
+			AllowedIPs:          pools.effectivePools(conf),
+			Endpoint:            *hubEndpoint,
+			PersistentKeepalive: clientPersistentKeepalive,
+		}},
+	}
+	peer := &Peer{
+		Name:         name,
+		PublicKey:    *privateKey.Public(),
+		PresharedKey: *presharedKey,
+		AllowedIPs:   addresses,
+	}
+	return client, peer, nil
+}
@@ -0,0 +1,59 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"testing"
+)
+
+const testHubInput = `
+[Interface]
+PrivateKey = yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=
+ListenPort = 51820
+Address = 10.0.0.1/29, fd00::1/64
+
+[Peer]
+PublicKey = xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=
+AllowedIPs = 10.0.0.2/32, 10.0.0.4/31, fd00::2/128
+
+[Peer]
+PublicKey = TrMvSoP4jYQlY6RIzBgbssQqY3vxI2Pi+y71lOWWXX0=
+AllowedIPs = 0.0.0.0/0
+`
+
+func TestNewClient(t *testing.T) {
+	hub, err := FromWgQuick(testHubInput, "hub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, peer, err := hub.NewClient("laptop", "vpn.example.com:51820", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Peers[0].PublicKey != *hub.Interface.PrivateKey.Public() {
+		t.Error("Client does not connect to the hub")
+	}
+	if peer.PublicKey != *client.Interface.PrivateKey.Public() || peer.PresharedKey != client.Peers[0].PresharedKey {
+		t.Error("Hub peer does not match client")
+	}
+	if len(peer.AllowedIPs) != 2 || peer.AllowedIPs[0] != client.Interface.Addresses[0] {
+		t.Errorf("Hub peer routes %v, client has %v", peer.AllowedIPs, client.Interface.Addresses)
+	}
+	if client.Peers[0].Endpoint.String() != "vpn.example.com:51820" {
+		t.Errorf("Client endpoint is %s", client.Peers[0].Endpoint.String())
+	}
+	if _, err = FromWgQuick(client.ToWgQuick(), client.Name); err != nil {
+		t.Errorf("Client configuration does not parse: %v", err)
+	}
+
+	if _, _, err = hub.NewClient("laptop", "vpn.example.com", nil); err == nil {
+		t.Error("Endpoint without port should fail")
+	}
+	hub.Interface.ListenPort = 0
+	if _, _, err = hub.NewClient("laptop", "vpn.example.com:51820", nil); err == nil {
+		t.Error("Tunnel without listen port should fail")
+	}
+}
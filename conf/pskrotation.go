@@ -0,0 +1,135 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+const maxPresharedKeyRotationHistory = 50
+
+// PresharedKeyRotationPolicy is how often the manager replaces the preshared keys of the peers of
+// a tunnel, and the command that hands each new key to the other side. It is set by administrators
+// per tunnel, under HKLM\Software\WireGuard\PresharedKeyRotation\<tunnel>.
+type PresharedKeyRotationPolicy struct {
+	Interval        time.Duration
+	ExchangeCommand string
+}
+
+// PresharedKeyRotationRecord is the outcome of rotating the preshared key of one peer.
+type PresharedKeyRotationRecord struct {
+	Time      time.Time `json:"time"`
+	PublicKey string    `json:"public_key"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// PresharedKeyRotationState is when the preshared keys of a tunnel were last rotated, along with
+// the most recent outcomes, oldest first.
+type PresharedKeyRotationState struct {
+	LastRotation time.Time                    `json:"last_rotation"`
+	History      []PresharedKeyRotationRecord `json:"history,omitempty"`
+}
+
+// Record appends the outcome of a rotation to the history, dropping the oldest beyond the limit.
+func (state *PresharedKeyRotationState) Record(record PresharedKeyRotationRecord) {
+	state.History = append(state.History, record)
+	if excess := len(state.History) - maxPresharedKeyRotationHistory; excess > 0 {
+		state.History = append(state.History[:0], state.History[excess:]...)
+	}
+}
+
+// LoadPresharedKeyRotationPolicy returns the preshared key rotation policy of the named tunnel, or
+// nil if it has none. A policy without an exchange command is no policy, since peers whose key is
+// changed on one side only can no longer handshake.
+func LoadPresharedKeyRotationPolicy(name string) *PresharedKeyRotationPolicy {
+	if !TunnelNameIsValid(name) {
+		return nil
+	}
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, adminRegKey+`\PresharedKeyRotation\`+name, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if err != nil {
+		return nil
+	}
+	defer key.Close()
+	days, _, err := key.GetIntegerValue("IntervalDays")
+	if err != nil || days == 0 {
+		return nil
+	}
+	command, _, err := key.GetStringValue("ExchangeCommand")
+	if err != nil || len(command) == 0 {
+		return nil
+	}
+	return &PresharedKeyRotationPolicy{
+		Interval:        time.Duration(days) * 24 * time.Hour,
+		ExchangeCommand: command,
+	}
+}
+
+func presharedKeyRotationStatePath(name string) (string, error) {
+	if !TunnelNameIsValid(name) {
+		return "", errors.New("Tunnel name is not valid")
+	}
+	root, err := RootDirectory(true)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, "Preshared Key Rotation")
+	err = os.Mkdir(dir, os.ModeDir|0o700)
+	if err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// LoadPresharedKeyRotationState returns the preshared key rotation state of the named tunnel,
+// which is zero if its keys were never rotated.
+func LoadPresharedKeyRotationState(name string) (PresharedKeyRotationState, error) {
+	path, err := presharedKeyRotationStatePath(name)
+	if err != nil {
+		return PresharedKeyRotationState{}, err
+	}
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return PresharedKeyRotationState{}, nil
+	} else if err != nil {
+		return PresharedKeyRotationState{}, err
+	}
+	var state PresharedKeyRotationState
+	err = json.Unmarshal(bytes, &state)
+	if err != nil {
+		return PresharedKeyRotationState{}, err
+	}
+	return state, nil
+}
+
+func SavePresharedKeyRotationState(name string, state PresharedKeyRotationState) error {
+	path, err := presharedKeyRotationStatePath(name)
+	if err != nil {
+		return err
+	}
+	bytes, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return writeLockedDownFile(path, true, bytes)
+}
+
+func DeletePresharedKeyRotationState(name string) error {
+	path, err := presharedKeyRotationStatePath(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
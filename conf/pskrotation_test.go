@@ -0,0 +1,24 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestPresharedKeyRotationHistory(t *testing.T) {
+	var state PresharedKeyRotationState
+	for i := 0; i < maxPresharedKeyRotationHistory+5; i++ {
+		state.Record(PresharedKeyRotationRecord{PublicKey: strconv.Itoa(i)})
+	}
+	if len(state.History) != maxPresharedKeyRotationHistory {
+		t.Fatalf("History has %d records, want %d", len(state.History), maxPresharedKeyRotationHistory)
+	}
+	if state.History[0].PublicKey != "5" || state.History[len(state.History)-1].PublicKey != strconv.Itoa(maxPresharedKeyRotationHistory+4) {
+		t.Errorf("History kept the wrong records: first %s, last %s", state.History[0].PublicKey, state.History[len(state.History)-1].PublicKey)
+	}
+}
@@ -0,0 +1,111 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"golang.zx2c4.com/wireguard/windows/conf/dpapi"
+)
+
+// tunnelStatePaths locate the files that belong to a tunnel besides its configuration, which are
+// all named after it.
+var tunnelStatePaths = [...]func(name string) (string, error){
+	activationRulesPath,
+	pitfallsPath,
+	addressPoolsPath,
+	adapterLogLevelPath,
+	listenPortOverridePath,
+	keyRotationStatePath,
+	presharedKeyRotationStatePath,
+	profilesPath,
+	schedulePath,
+	usagePath,
+	peerSourceStatePath,
+}
+
+// TunnelNameExists returns whether a configuration is stored under the name, encrypted or not.
+func TunnelNameExists(name string) bool {
+	configFileDir, err := tunnelConfigurationsDirectory()
+	if err != nil {
+		return false
+	}
+	for _, suffix := range [...]string{configFileSuffix, configFileUnencryptedSuffix} {
+		if _, err := os.Lstat(filepath.Join(configFileDir, name+suffix)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// RenameName stores the configuration of a tunnel under a new name, keeping its text as is, but
+// encrypting it anew, because the name is part of the encryption. The files that belong to the
+// tunnel, such as its activation rules and usage, are moved along, and the old configuration is
+// removed last. Should moving a file fail, the files already moved are moved back and the new
+// configuration is removed, so that the tunnel is left as it was, under the old name.
+func RenameName(oldName, newName string) error {
+	if !TunnelNameIsValid(oldName) || !TunnelNameIsValid(newName) {
+		return errors.New("Tunnel name is not valid")
+	}
+	if TunnelNameExists(newName) {
+		return errors.New("A tunnel with this name already exists")
+	}
+	configFileDir, err := tunnelConfigurationsDirectory()
+	if err != nil {
+		return err
+	}
+	oldPath := filepath.Join(configFileDir, oldName+configFileSuffix)
+	bytes, err := os.ReadFile(oldPath)
+	if errors.Is(err, os.ErrNotExist) {
+		oldPath = filepath.Join(configFileDir, oldName+configFileUnencryptedSuffix)
+		bytes, err = os.ReadFile(oldPath)
+	} else if err == nil {
+		bytes, err = dpapi.Decrypt(bytes, oldName)
+	}
+	if err != nil {
+		return err
+	}
+	encryptedBytes, err := dpapi.Encrypt(bytes, newName)
+	if err != nil {
+		return err
+	}
+	newPath := filepath.Join(configFileDir, newName+configFileSuffix)
+	err = writeLockedDownFile(newPath, false, encryptedBytes)
+	if err != nil {
+		return err
+	}
+	var moved [][2]string
+	err = func() error {
+		for _, statePath := range tunnelStatePaths {
+			from, err := statePath(oldName)
+			if err != nil {
+				return err
+			}
+			to, err := statePath(newName)
+			if err != nil {
+				return err
+			}
+			err = os.Rename(from, to)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			moved = append(moved, [2]string{from, to})
+		}
+		return nil
+	}()
+	if err != nil {
+		for i := len(moved) - 1; i >= 0; i-- {
+			os.Rename(moved[i][1], moved[i][0])
+		}
+		os.Remove(newPath)
+		return err
+	}
+	return os.Remove(oldPath)
+}
@@ -0,0 +1,282 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule describes the windows of local time during which the manager keeps a tunnel active.
+// The manager only acts at the boundaries of the windows, so that a tunnel toggled by hand stays
+// that way until the next one.
+type Schedule struct {
+	Windows []ScheduleWindow `json:"windows,omitempty"`
+}
+
+// ScheduleWindow is a span of time that starts on each of its days. An end that is not after
+// the start means that the window spans midnight, ending on the following day.
+type ScheduleWindow struct {
+	Days  uint8 `json:"days"`  // Bit n is set for time.Weekday(n).
+	Start int   `json:"start"` // Minutes after midnight.
+	End   int   `json:"end"`   // Minutes after midnight, up to and including 24:00.
+}
+
+const minutesPerDay = 24 * 60
+
+var scheduleDayNames = [...]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+// scheduleWeek lists the days in the order in which they are written, starting on Monday.
+var scheduleWeek = [...]time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}
+
+const allScheduleDays = 1<<7 - 1
+
+func (schedule *Schedule) IsEmpty() bool {
+	return len(schedule.Windows) == 0
+}
+
+func (schedule *Schedule) Validate() error {
+	for i := range schedule.Windows {
+		w := &schedule.Windows[i]
+		if w.Days == 0 || w.Days > allScheduleDays {
+			return fmt.Errorf("Schedule window %d has invalid days", i+1)
+		}
+		if w.Start < 0 || w.Start >= minutesPerDay || w.End < 0 || w.End > minutesPerDay {
+			return fmt.Errorf("Schedule window %d has an invalid time", i+1)
+		}
+	}
+	return nil
+}
+
+func (w *ScheduleWindow) length() int {
+	if w.End > w.Start {
+		return w.End - w.Start
+	}
+	return w.End + minutesPerDay - w.Start
+}
+
+// occurrences calls f with the start and end of each occurrence of the window that starts on
+// one of the days from the day before from up to and including the day of to.
+func (w *ScheduleWindow) occurrences(from, to time.Time, f func(start, end time.Time)) {
+	y, m, d := from.Date()
+	day := time.Date(y, m, d-1, 0, 0, 0, 0, from.Location())
+	for !day.After(to) {
+		if w.Days&(1<<day.Weekday()) != 0 {
+			y, m, d = day.Date()
+			start := time.Date(y, m, d, 0, w.Start, 0, 0, day.Location())
+			end := time.Date(y, m, d, 0, w.Start+w.length(), 0, 0, day.Location())
+			f(start, end)
+		}
+		y, m, d = day.Date()
+		day = time.Date(y, m, d+1, 0, 0, 0, 0, day.Location())
+	}
+}
+
+// WantsActive reports whether t falls into one of the windows.
+func (schedule *Schedule) WantsActive(t time.Time) bool {
+	active := false
+	for i := range schedule.Windows {
+		schedule.Windows[i].occurrences(t, t, func(start, end time.Time) {
+			if !t.Before(start) && t.Before(end) {
+				active = true
+			}
+		})
+	}
+	return active
+}
+
+// CrossesBoundary reports whether a window starts or ends after from and no later than to. A
+// window that ends when another starts is not a boundary.
+func (schedule *Schedule) CrossesBoundary(from, to time.Time) bool {
+	// The windows repeat every week, so a longer span crosses them all.
+	if week := 7 * 24 * time.Hour; to.Sub(from) > week {
+		from = to.Add(-week)
+	}
+	crosses := false
+	within := func(t time.Time) bool {
+		return t.After(from) && !t.After(to) && schedule.WantsActive(t) != schedule.WantsActive(t.Add(-time.Minute))
+	}
+	for i := range schedule.Windows {
+		schedule.Windows[i].occurrences(from, to, func(start, end time.Time) {
+			if within(start) || within(end) {
+				crosses = true
+			}
+		})
+	}
+	return crosses
+}
+
+func formatScheduleTime(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// String formats the window as its days, as names or ranges of names separated by commas, or
+// Daily, followed by the times, such as "Mon-Fri 09:00-17:00".
+func (w *ScheduleWindow) String() string {
+	times := formatScheduleTime(w.Start) + "-" + formatScheduleTime(w.End)
+	if w.Days&allScheduleDays == allScheduleDays {
+		return "Daily " + times
+	}
+	var days []string
+	for i := 0; i < len(scheduleWeek); i++ {
+		if w.Days&(1<<scheduleWeek[i]) == 0 {
+			continue
+		}
+		j := i
+		for j+1 < len(scheduleWeek) && w.Days&(1<<scheduleWeek[j+1]) != 0 {
+			j++
+		}
+		if j-i >= 2 {
+			days = append(days, scheduleDayNames[scheduleWeek[i]]+"-"+scheduleDayNames[scheduleWeek[j]])
+		} else {
+			for k := i; k <= j; k++ {
+				days = append(days, scheduleDayNames[scheduleWeek[k]])
+			}
+		}
+		i = j
+	}
+	return strings.Join(days, ",") + " " + times
+}
+
+func parseScheduleDay(s string) (int, error) {
+	for i := range scheduleWeek {
+		if strings.EqualFold(s, scheduleDayNames[scheduleWeek[i]]) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("Invalid day: %q", s)
+}
+
+func parseScheduleTime(s string) (int, error) {
+	hours, minutes, ok := strings.Cut(s, ":")
+	h, err := strconv.ParseUint(hours, 10, 8)
+	if !ok || err != nil || h > 24 || len(minutes) != 2 {
+		return 0, fmt.Errorf("Invalid time: %q", s)
+	}
+	m, err := strconv.ParseUint(minutes, 10, 8)
+	if err != nil || m > 59 || h == 24 && m != 0 {
+		return 0, fmt.Errorf("Invalid time: %q", s)
+	}
+	return int(h*60 + m), nil
+}
+
+// ParseScheduleWindow parses a window in the form written by String.
+func ParseScheduleWindow(s string) (ScheduleWindow, error) {
+	var w ScheduleWindow
+	days, times, ok := strings.Cut(strings.TrimSpace(s), " ")
+	if !ok {
+		return w, fmt.Errorf("Schedule window must have days and times: %q", s)
+	}
+	if strings.EqualFold(days, "Daily") {
+		w.Days = allScheduleDays
+	} else {
+		for _, field := range strings.Split(days, ",") {
+			first, last, isRange := strings.Cut(strings.TrimSpace(field), "-")
+			i, err := parseScheduleDay(first)
+			if err != nil {
+				return w, err
+			}
+			j := i
+			if isRange {
+				j, err = parseScheduleDay(last)
+				if err != nil {
+					return w, err
+				}
+			}
+			for k := i; ; k = (k + 1) % len(scheduleWeek) {
+				w.Days |= 1 << scheduleWeek[k]
+				if k == j {
+					break
+				}
+			}
+		}
+	}
+	start, end, ok := strings.Cut(strings.TrimSpace(times), "-")
+	if !ok {
+		return w, fmt.Errorf("Schedule window must have a start and end time: %q", s)
+	}
+	var err error
+	if w.Start, err = parseScheduleTime(strings.TrimSpace(start)); err != nil {
+		return w, err
+	}
+	if w.Start == minutesPerDay {
+		return w, fmt.Errorf("Schedule window cannot start at 24:00: %q", s)
+	}
+	if w.End, err = parseScheduleTime(strings.TrimSpace(end)); err != nil {
+		return w, err
+	}
+	return w, nil
+}
+
+func schedulePath(name string) (string, error) {
+	if !TunnelNameIsValid(name) {
+		return "", errors.New("Tunnel name is not valid")
+	}
+	root, err := RootDirectory(true)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, "Schedules")
+	err = os.Mkdir(dir, os.ModeDir|0o700)
+	if err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// LoadSchedule returns the schedule of the named tunnel, which is empty if none has been saved.
+func LoadSchedule(name string) (*Schedule, error) {
+	path, err := schedulePath(name)
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Schedule{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var schedule Schedule
+	err = json.Unmarshal(bytes, &schedule)
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// SaveSchedule saves the schedule of the named tunnel, or removes it if empty.
+func SaveSchedule(name string, schedule *Schedule) error {
+	if schedule.IsEmpty() {
+		return DeleteSchedule(name)
+	}
+	path, err := schedulePath(name)
+	if err != nil {
+		return err
+	}
+	bytes, err := json.Marshal(schedule)
+	if err != nil {
+		return err
+	}
+	return writeLockedDownFile(path, true, bytes)
+}
+
+func DeleteSchedule(name string) error {
+	path, err := schedulePath(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
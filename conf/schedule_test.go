@@ -0,0 +1,95 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleWindowFormat(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		{"Mon-Fri 09:00-17:00", "Mon-Fri 09:00-17:00"},
+		{"sat,sun 10:00-14:30", "Sat,Sun 10:00-14:30"},
+		{"Mon,Tue,Wed 08:00-12:00", "Mon-Wed 08:00-12:00"},
+		{"Fri-Mon 22:00-06:00", "Mon,Fri-Sun 22:00-06:00"},
+		{"Daily 00:00-24:00", "Daily 00:00-24:00"},
+		{"Mon-Sun 07:00-08:00", "Daily 07:00-08:00"},
+	}
+	for _, test := range tests {
+		w, err := ParseScheduleWindow(test.input)
+		if err != nil {
+			t.Errorf("ParseScheduleWindow(%q) failed: %v", test.input, err)
+			continue
+		}
+		if got := w.String(); got != test.want {
+			t.Errorf("ParseScheduleWindow(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+	for _, input := range []string{"", "Mon", "Mon 09:00", "Someday 09:00-17:00", "Mon 9-17", "Mon 24:00-01:00", "Mon 09:60-10:00", "Mon 25:00-01:00"} {
+		if _, err := ParseScheduleWindow(input); err == nil {
+			t.Errorf("ParseScheduleWindow(%q) should fail", input)
+		}
+	}
+}
+
+func TestScheduleWantsActive(t *testing.T) {
+	schedule := Schedule{Windows: []ScheduleWindow{
+		{Days: 1<<time.Monday | 1<<time.Tuesday, Start: 9 * 60, End: 17 * 60},
+		{Days: 1 << time.Friday, Start: 22 * 60, End: 2 * 60},
+	}}
+	at := func(day, hour, minute int) time.Time {
+		// January 1, 2024 was a Monday.
+		return time.Date(2024, time.January, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		t    time.Time
+		want bool
+	}{
+		{at(1, 8, 59), false},
+		{at(1, 9, 0), true},
+		{at(2, 16, 59), true},
+		{at(2, 17, 0), false},
+		{at(3, 12, 0), false},
+		{at(5, 23, 0), true},
+		{at(6, 1, 59), true},
+		{at(6, 2, 0), false},
+	}
+	for _, test := range tests {
+		if got := schedule.WantsActive(test.t); got != test.want {
+			t.Errorf("WantsActive(%v) = %v, want %v", test.t, got, test.want)
+		}
+	}
+
+	boundaries := []struct {
+		from, to time.Time
+		want     bool
+	}{
+		{at(1, 8, 0), at(1, 8, 59), false},
+		{at(1, 8, 59), at(1, 9, 0), true},
+		{at(1, 9, 0), at(1, 9, 30), false},
+		{at(1, 16, 0), at(2, 8, 0), true},
+		{at(3, 0, 0), at(5, 21, 0), false},
+		{at(6, 1, 0), at(6, 3, 0), true},
+		{at(6, 3, 0), at(1, 3, 0), false},
+		{at(6, 3, 0), at(20, 3, 0), true},
+	}
+	for _, test := range boundaries {
+		if got := schedule.CrossesBoundary(test.from, test.to); got != test.want {
+			t.Errorf("CrossesBoundary(%v, %v) = %v, want %v", test.from, test.to, got, test.want)
+		}
+	}
+
+	adjacent := Schedule{Windows: []ScheduleWindow{
+		{Days: 1 << time.Monday, Start: 9 * 60, End: 12 * 60},
+		{Days: 1 << time.Monday, Start: 12 * 60, End: 17 * 60},
+	}}
+	if adjacent.CrossesBoundary(at(1, 11, 0), at(1, 13, 0)) {
+		t.Error("Adjacent windows should not have a boundary between them")
+	}
+}
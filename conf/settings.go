@@ -0,0 +1,118 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Update check intervals, in hours. The manager checks hourly unless told otherwise.
+const (
+	UpdateCheckHourly = 1
+	UpdateCheckDaily  = 24
+	UpdateCheckWeekly = 24 * 7
+	UpdateCheckNever  = -1
+)
+
+// Settings are the machine-wide settings that administrators choose on the settings page of the
+// UI, as opposed to those of each user, which the UI keeps in the registry itself.
+type Settings struct {
+	// UpdateCheckHours is how often the manager checks for updates, or UpdateCheckNever. Zero
+	// means hourly.
+	UpdateCheckHours int `json:"update_check_hours,omitempty"`
+	// DefaultKillSwitch is whether tunnels created empty in the UI start out with KillSwitch = true.
+	DefaultKillSwitch bool `json:"default_kill_switch,omitempty"`
+	// DefaultAdapterLogLevel is the adapter log level of tunnels that have none of their own.
+	DefaultAdapterLogLevel AdapterLogLevel `json:"default_adapter_log_level,omitempty"`
+	// SilentUpdates is whether the manager installs the updates it finds by itself, without
+	// anyone signed in, during the maintenance window.
+	SilentUpdates bool `json:"silent_updates,omitempty"`
+	// MaintenanceWindow is when silent updates may be installed, or nil for any time.
+	MaintenanceWindow *ScheduleWindow `json:"maintenance_window,omitempty"`
+}
+
+func (settings *Settings) Validate() error {
+	switch settings.UpdateCheckHours {
+	case 0, UpdateCheckHourly, UpdateCheckDaily, UpdateCheckWeekly, UpdateCheckNever:
+	default:
+		return errors.New("Invalid update check interval")
+	}
+	if settings.DefaultAdapterLogLevel > AdapterLogOff {
+		return errors.New("Invalid adapter log level")
+	}
+	if settings.MaintenanceWindow != nil {
+		if err := (&Schedule{Windows: []ScheduleWindow{*settings.MaintenanceWindow}}).Validate(); err != nil {
+			return errors.New("Invalid maintenance window")
+		}
+	}
+	return nil
+}
+
+// InMaintenanceWindow reports whether silent updates may be installed at t.
+func (settings *Settings) InMaintenanceWindow(t time.Time) bool {
+	if settings.MaintenanceWindow == nil {
+		return true
+	}
+	return (&Schedule{Windows: []ScheduleWindow{*settings.MaintenanceWindow}}).WantsActive(t)
+}
+
+func settingsPath() (string, error) {
+	root, err := RootDirectory(true)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, "Settings.json"), nil
+}
+
+// LoadSettings returns the machine-wide settings, which are the defaults if none have been saved.
+func LoadSettings() (*Settings, error) {
+	path, err := settingsPath()
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Settings{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var settings Settings
+	err = json.Unmarshal(bytes, &settings)
+	if err != nil {
+		return nil, err
+	}
+	return &settings, settings.Validate()
+}
+
+func SaveSettings(settings *Settings) error {
+	err := settings.Validate()
+	if err != nil {
+		return err
+	}
+	path, err := settingsPath()
+	if err != nil {
+		return err
+	}
+	bytes, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	return writeLockedDownFile(path, true, bytes)
+}
+
+// defaultAdapterLogLevel is the adapter log level of tunnels that have none of their own, which is
+// verbose unless the settings say otherwise.
+func defaultAdapterLogLevel() AdapterLogLevel {
+	settings, err := LoadSettings()
+	if err != nil {
+		return AdapterLogVerbose
+	}
+	return settings.DefaultAdapterLogLevel
+}
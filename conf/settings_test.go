@@ -0,0 +1,44 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSettingsMaintenanceWindow(t *testing.T) {
+	var settings Settings
+	if !settings.InMaintenanceWindow(time.Now()) {
+		t.Error("Without a maintenance window, updates should be allowed at any time")
+	}
+	w, err := ParseScheduleWindow("Sat,Sun 23:00-04:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings.MaintenanceWindow = &w
+	if err = settings.Validate(); err != nil {
+		t.Errorf("Valid settings fail: %v", err)
+	}
+	tests := []struct {
+		t    time.Time
+		want bool
+	}{
+		{time.Date(2022, time.March, 5, 23, 30, 0, 0, time.Local), true},  // Saturday night
+		{time.Date(2022, time.March, 7, 3, 0, 0, 0, time.Local), true},    // Monday morning, after Sunday
+		{time.Date(2022, time.March, 7, 4, 0, 0, 0, time.Local), false},   // Monday, once it ends
+		{time.Date(2022, time.March, 8, 23, 30, 0, 0, time.Local), false}, // Tuesday night
+	}
+	for _, test := range tests {
+		if got := settings.InMaintenanceWindow(test.t); got != test.want {
+			t.Errorf("InMaintenanceWindow(%v) = %v, want %v", test.t, got, test.want)
+		}
+	}
+	settings.MaintenanceWindow.Days = 0
+	if err = settings.Validate(); err == nil {
+		t.Error("Maintenance window without days should fail")
+	}
+}
@@ -0,0 +1,169 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"golang.zx2c4.com/wireguard/windows/conf/dpapi"
+)
+
+// Update channels, as tagged in the signed list of releases. Releases without a tag are stable,
+// and the beta channel follows stable releases too, whichever is newer.
+const (
+	UpdateChannelStable = "stable"
+	UpdateChannelBeta   = "beta"
+)
+
+// UpdateSettings are how the updater was set up in the UI.
+type UpdateSettings struct {
+	Channel string       `json:"channel,omitempty"`
+	Proxy   *UpdateProxy `json:"proxy,omitempty"`
+}
+
+// UpdateProxy is a proxy for the updater to use instead of the system's WinHTTP proxy settings,
+// or, if Host is empty, the credentials to give the system's proxy.
+type UpdateProxy struct {
+	Host     string `json:"host,omitempty"`
+	Port     uint16 `json:"port,omitempty"`
+	Username string `json:"username,omitempty"`
+	// Password is encrypted with DPAPI when saved.
+	Password string `json:"password,omitempty"`
+}
+
+// Server returns the host and port of the proxy, or an empty string to use the system's proxy.
+func (proxy *UpdateProxy) Server() string {
+	if len(proxy.Host) == 0 {
+		return ""
+	}
+	return net.JoinHostPort(proxy.Host, strconv.FormatUint(uint64(proxy.Port), 10))
+}
+
+func (proxy *UpdateProxy) Validate() error {
+	if len(proxy.Host) > 0 && proxy.Port == 0 {
+		return errors.New("Proxy port must be set")
+	}
+	if len(proxy.Host) == 0 && len(proxy.Username) == 0 {
+		return errors.New("Proxy host or username must be set")
+	}
+	return nil
+}
+
+func UpdateChannelIsValid(channel string) bool {
+	return channel == UpdateChannelStable || channel == UpdateChannelBeta
+}
+
+const updateProxyPasswordName = "WireGuard Update Proxy"
+
+func updateSettingsPath() (string, error) {
+	root, err := RootDirectory(true)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, "Updates.json"), nil
+}
+
+// LoadUpdateSettings returns the update settings, which are empty if none have been saved.
+func LoadUpdateSettings() (*UpdateSettings, error) {
+	path, err := updateSettingsPath()
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &UpdateSettings{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var settings UpdateSettings
+	err = json.Unmarshal(bytes, &settings)
+	if err != nil {
+		return nil, err
+	}
+	if settings.Proxy != nil && len(settings.Proxy.Password) > 0 {
+		encrypted, err := base64.StdEncoding.DecodeString(settings.Proxy.Password)
+		if err != nil {
+			return nil, err
+		}
+		password, err := dpapi.Decrypt(encrypted, updateProxyPasswordName)
+		if err != nil {
+			return nil, err
+		}
+		settings.Proxy.Password = string(password)
+	}
+	return &settings, nil
+}
+
+func SaveUpdateSettings(settings *UpdateSettings) error {
+	path, err := updateSettingsPath()
+	if err != nil {
+		return err
+	}
+	stored := *settings
+	if settings.Proxy != nil {
+		err = settings.Proxy.Validate()
+		if err != nil {
+			return err
+		}
+		proxy := *settings.Proxy
+		if len(proxy.Password) > 0 {
+			encrypted, err := dpapi.Encrypt([]byte(proxy.Password), updateProxyPasswordName)
+			if err != nil {
+				return err
+			}
+			proxy.Password = base64.StdEncoding.EncodeToString(encrypted)
+		}
+		stored.Proxy = &proxy
+	}
+	bytes, err := json.Marshal(&stored)
+	if err != nil {
+		return err
+	}
+	return writeLockedDownFile(path, true, bytes)
+}
+
+// UpdateChannel returns the channel that the updater follows, which is the one pinned by Group
+// Policy or in the UpdateChannel policy if set, or else the one in the update settings, or else stable.
+func UpdateChannel() (channel string, byPolicy bool) {
+	if channel := LoadPolicies().UpdateChannel; len(channel) > 0 {
+		return channel, true
+	}
+	if values := AdminStrings("UpdateChannel"); len(values) > 0 && UpdateChannelIsValid(values[0]) {
+		return values[0], true
+	}
+	if settings, err := LoadUpdateSettings(); err == nil && UpdateChannelIsValid(settings.Channel) {
+		return settings.Channel, false
+	}
+	return UpdateChannelStable, false
+}
+
+// UpdateProxySettings returns the proxy that the updater uses, which is the host and port in the
+// UpdateProxy policy, if set, with the credentials from the update settings, or else the proxy in
+// the update settings, or nil to use the system's WinHTTP proxy settings.
+func UpdateProxySettings() (proxy *UpdateProxy, byPolicy bool) {
+	settings, err := LoadUpdateSettings()
+	if err != nil {
+		settings = &UpdateSettings{}
+	}
+	if values := AdminStrings("UpdateProxy"); len(values) > 0 && len(values[0]) > 0 {
+		host, portStr, err := net.SplitHostPort(values[0])
+		port, portErr := strconv.ParseUint(portStr, 10, 16)
+		if err == nil && portErr == nil && port > 0 {
+			proxy = &UpdateProxy{Host: host, Port: uint16(port)}
+			if settings.Proxy != nil {
+				proxy.Username, proxy.Password = settings.Proxy.Username, settings.Proxy.Password
+			}
+			return proxy, true
+		}
+	}
+	return settings.Proxy, false
+}
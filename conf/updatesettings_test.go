@@ -0,0 +1,32 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"testing"
+)
+
+func TestUpdateProxy(t *testing.T) {
+	tests := []struct {
+		proxy  UpdateProxy
+		server string
+		valid  bool
+	}{
+		{UpdateProxy{Host: "proxy.example", Port: 3128}, "proxy.example:3128", true},
+		{UpdateProxy{Host: "fd00::1", Port: 8080, Username: "user"}, "[fd00::1]:8080", true},
+		{UpdateProxy{Username: "user", Password: "secret"}, "", true},
+		{UpdateProxy{Host: "proxy.example"}, "proxy.example:0", false},
+		{UpdateProxy{}, "", false},
+	}
+	for _, test := range tests {
+		if server := test.proxy.Server(); server != test.server {
+			t.Errorf("Server() of %+v = %q, want %q", test.proxy, server, test.server)
+		}
+		if err := test.proxy.Validate(); (err == nil) != test.valid {
+			t.Errorf("Validate() of %+v = %v, want valid %v", test.proxy, err, test.valid)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Usage is the traffic of a tunnel during each of its recent days and months of local time,
+// oldest first. Periods without traffic are left out.
+type Usage struct {
+	Days   []UsagePeriod `json:"days,omitempty"`
+	Months []UsagePeriod `json:"months,omitempty"`
+}
+
+type UsagePeriod struct {
+	Start   string `json:"start"` // 2006-01-02 for days, 2006-01 for months.
+	RxBytes Bytes  `json:"rx"`
+	TxBytes Bytes  `json:"tx"`
+}
+
+const (
+	UsageDayLayout   = "2006-01-02"
+	UsageMonthLayout = "2006-01"
+
+	usageDaysKept   = 92
+	usageMonthsKept = 36
+)
+
+func (usage *Usage) IsEmpty() bool {
+	return len(usage.Days) == 0 && len(usage.Months) == 0
+}
+
+// addUsagePeriod adds traffic to the period that starts at start, keeping periods sorted, and
+// drops the oldest beyond keep.
+func addUsagePeriod(periods []UsagePeriod, start string, rx, tx Bytes, keep int) []UsagePeriod {
+	i := sort.Search(len(periods), func(i int) bool { return periods[i].Start >= start })
+	if i < len(periods) && periods[i].Start == start {
+		periods[i].RxBytes += rx
+		periods[i].TxBytes += tx
+	} else {
+		periods = append(periods, UsagePeriod{})
+		copy(periods[i+1:], periods[i:])
+		periods[i] = UsagePeriod{Start: start, RxBytes: rx, TxBytes: tx}
+	}
+	if len(periods) > keep {
+		periods = append([]UsagePeriod(nil), periods[len(periods)-keep:]...)
+	}
+	return periods
+}
+
+// Add counts traffic towards the day and month of t.
+func (usage *Usage) Add(t time.Time, rx, tx uint64) {
+	if rx == 0 && tx == 0 {
+		return
+	}
+	usage.Days = addUsagePeriod(usage.Days, t.Format(UsageDayLayout), Bytes(rx), Bytes(tx), usageDaysKept)
+	usage.Months = addUsagePeriod(usage.Months, t.Format(UsageMonthLayout), Bytes(rx), Bytes(tx), usageMonthsKept)
+}
+
+// Merge adds the traffic of other to that of usage.
+func (usage *Usage) Merge(other *Usage) {
+	for _, day := range other.Days {
+		usage.Days = addUsagePeriod(usage.Days, day.Start, day.RxBytes, day.TxBytes, usageDaysKept)
+	}
+	for _, month := range other.Months {
+		usage.Months = addUsagePeriod(usage.Months, month.Start, month.RxBytes, month.TxBytes, usageMonthsKept)
+	}
+}
+
+func usagePath(name string) (string, error) {
+	if !TunnelNameIsValid(name) {
+		return "", errors.New("Tunnel name is not valid")
+	}
+	root, err := RootDirectory(true)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, "Usage")
+	err = os.Mkdir(dir, os.ModeDir|0o700)
+	if err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// LoadUsage returns the stored usage of a tunnel, which is empty if none was stored.
+func LoadUsage(name string) (*Usage, error) {
+	path, err := usagePath(name)
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Usage{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var usage Usage
+	err = json.Unmarshal(bytes, &usage)
+	if err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// SaveUsage saves the usage of the named tunnel, or removes it if empty.
+func SaveUsage(name string, usage *Usage) error {
+	if usage.IsEmpty() {
+		return DeleteUsage(name)
+	}
+	path, err := usagePath(name)
+	if err != nil {
+		return err
+	}
+	bytes, err := json.Marshal(usage)
+	if err != nil {
+		return err
+	}
+	return writeLockedDownFile(path, true, bytes)
+}
+
+func DeleteUsage(name string) error {
+	path, err := usagePath(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUsage(t *testing.T) {
+	var usage Usage
+	usage.Add(time.Date(2024, time.January, 31, 23, 59, 0, 0, time.UTC), 100, 10)
+	usage.Add(time.Date(2024, time.February, 1, 0, 1, 0, 0, time.UTC), 200, 20)
+	usage.Add(time.Date(2024, time.January, 31, 12, 0, 0, 0, time.UTC), 1000, 0)
+	usage.Add(time.Date(2024, time.February, 1, 0, 2, 0, 0, time.UTC), 0, 0)
+	want := Usage{
+		Days:   []UsagePeriod{{"2024-01-31", 1100, 10}, {"2024-02-01", 200, 20}},
+		Months: []UsagePeriod{{"2024-01", 1100, 10}, {"2024-02", 200, 20}},
+	}
+	if !reflect.DeepEqual(usage, want) {
+		t.Errorf("Usage is %+v, want %+v", usage, want)
+	}
+
+	var merged Usage
+	merged.Add(time.Date(2023, time.December, 1, 0, 0, 0, 0, time.UTC), 5, 5)
+	merged.Merge(&usage)
+	merged.Merge(&usage)
+	want = Usage{
+		Days:   []UsagePeriod{{"2023-12-01", 5, 5}, {"2024-01-31", 2200, 20}, {"2024-02-01", 400, 40}},
+		Months: []UsagePeriod{{"2023-12", 5, 5}, {"2024-01", 2200, 20}, {"2024-02", 400, 40}},
+	}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("Merged usage is %+v, want %+v", merged, want)
+	}
+
+	var long Usage
+	start := time.Date(2020, time.January, 1, 12, 0, 0, 0, time.UTC)
+	for day := 0; day < 4*365; day++ {
+		long.Add(start.AddDate(0, 0, day), 1, 1)
+	}
+	if len(long.Days) != usageDaysKept || len(long.Months) != usageMonthsKept {
+		t.Errorf("Usage keeps %d days and %d months, want %d and %d", len(long.Days), len(long.Months), usageDaysKept, usageMonthsKept)
+	}
+	if last := start.AddDate(0, 0, 4*365-1).Format(UsageDayLayout); long.Days[len(long.Days)-1].Start != last {
+		t.Errorf("Latest day is %s, want %s", long.Days[len(long.Days)-1].Start, last)
+	}
+}
@@ -0,0 +1,51 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"net/netip"
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+const defaultWatchdogFailureThreshold = 3
+
+// WatchdogPolicy is how the manager checks that a running tunnel still works. It is set by
+// administrators per tunnel, under HKLM\Software\WireGuard\Watchdog\<tunnel>. A check fails if
+// PingAddress, an address on the far side of the tunnel, does not answer, or if no peer completed
+// a handshake within MaxHandshakeAge; either may be left unset.
+type WatchdogPolicy struct {
+	PingAddress      netip.Addr
+	MaxHandshakeAge  time.Duration
+	FailureThreshold int
+}
+
+// LoadWatchdogPolicy returns the watchdog policy of the named tunnel, or nil if it has none.
+func LoadWatchdogPolicy(name string) *WatchdogPolicy {
+	if !TunnelNameIsValid(name) {
+		return nil
+	}
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, adminRegKey+`\Watchdog\`+name, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if err != nil {
+		return nil
+	}
+	defer key.Close()
+	policy := &WatchdogPolicy{FailureThreshold: defaultWatchdogFailureThreshold}
+	if address, _, err := key.GetStringValue("PingAddress"); err == nil {
+		policy.PingAddress, _ = netip.ParseAddr(address)
+	}
+	if seconds, _, err := key.GetIntegerValue("MaxHandshakeAgeSeconds"); err == nil {
+		policy.MaxHandshakeAge = time.Duration(seconds) * time.Second
+	}
+	if threshold, _, err := key.GetIntegerValue("FailureThreshold"); err == nil && threshold > 0 {
+		policy.FailureThreshold = int(threshold)
+	}
+	if !policy.PingAddress.IsValid() && policy.MaxHandshakeAge == 0 {
+		return nil
+	}
+	return policy
+}
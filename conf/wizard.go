@@ -0,0 +1,154 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+
+	"golang.zx2c4.com/wireguard/windows/l18n"
+)
+
+// RoutingPreset chooses which traffic a tunnel made by guided setup sends to its server.
+type RoutingPreset int
+
+const (
+	FullTunnel  RoutingPreset = iota // All traffic, over IPv4 and IPv6.
+	SplitTunnel                      // Only traffic to the networks given.
+)
+
+// GuidedSetup holds the answers given in the steps of the new tunnel wizard, as typed, which make
+// a tunnel with one server as its only peer.
+type GuidedSetup struct {
+	Name       string
+	PrivateKey Key
+	Addresses  string // The addresses that the server assigned to this computer.
+
+	ServerPublicKey string
+	ServerEndpoint  string
+
+	Routing       RoutingPreset
+	SplitNetworks string // The networks behind the server, with SplitTunnel.
+	KillSwitch    bool   // Only possible with FullTunnel.
+
+	DNS string // Optional.
+}
+
+func parsePrefixList(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if len(field) == 0 {
+			continue
+		}
+		prefix, err := parseIPCidr(field)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// CheckInterface checks the answers of the first step, the name and the addresses.
+func (setup *GuidedSetup) CheckInterface() error {
+	if !TunnelNameIsValid(setup.Name) {
+		return errors.New(l18n.Sprintf("Tunnel name ‘%s’ is invalid.", setup.Name))
+	}
+	addresses, err := parsePrefixList(setup.Addresses)
+	if err != nil {
+		return err
+	}
+	if len(addresses) == 0 {
+		return errors.New(l18n.Sprintf("The address that the server assigned to this computer is required"))
+	}
+	return nil
+}
+
+// CheckServer checks the answers of the step about the server.
+func (setup *GuidedSetup) CheckServer() error {
+	key, err := parseKeyBase64(strings.TrimSpace(setup.ServerPublicKey))
+	if err != nil {
+		return err
+	}
+	if *key == *setup.PrivateKey.Public() {
+		return errors.New(l18n.Sprintf("The public key of the server is the one of this computer"))
+	}
+	_, err = parseEndpoint(strings.TrimSpace(setup.ServerEndpoint))
+	return err
+}
+
+// CheckRouting checks the answers of the step about which traffic goes through the tunnel.
+func (setup *GuidedSetup) CheckRouting() error {
+	if setup.Routing != SplitTunnel {
+		return nil
+	}
+	networks, err := parsePrefixList(setup.SplitNetworks)
+	if err != nil {
+		return err
+	}
+	if len(networks) == 0 {
+		return errors.New(l18n.Sprintf("At least one network is required for a split tunnel"))
+	}
+	return nil
+}
+
+// CheckDNS checks the DNS servers and search domains, of which there may be none.
+func (setup *GuidedSetup) CheckDNS() error {
+	for _, field := range strings.Split(setup.DNS, ",") {
+		field = strings.TrimSpace(field)
+		if len(field) == 0 {
+			continue
+		}
+		if _, err := netip.ParseAddr(field); err != nil && strings.ContainsAny(field, " :/\\") {
+			return errors.New(l18n.Sprintf("‘%s’ is neither the address of a DNS server nor a search domain", field))
+		}
+	}
+	return nil
+}
+
+// Config checks all answers and makes the configuration of the tunnel from them.
+func (setup *GuidedSetup) Config() (*Config, error) {
+	for _, check := range [...]func() error{setup.CheckInterface, setup.CheckServer, setup.CheckRouting, setup.CheckDNS} {
+		if err := check(); err != nil {
+			return nil, err
+		}
+	}
+	addresses, _ := parsePrefixList(setup.Addresses)
+	serverPublicKey, _ := parseKeyBase64(strings.TrimSpace(setup.ServerPublicKey))
+	serverEndpoint, _ := parseEndpoint(strings.TrimSpace(setup.ServerEndpoint))
+	config := &Config{
+		Name: setup.Name,
+		Interface: Interface{
+			PrivateKey: setup.PrivateKey,
+			Addresses:  addresses,
+		},
+		Peers: []Peer{{
+			PublicKey:           *serverPublicKey,
+			Endpoint:            *serverEndpoint,
+			PersistentKeepalive: clientPersistentKeepalive,
+		}},
+	}
+	if setup.Routing == SplitTunnel {
+		config.Peers[0].AllowedIPs, _ = parsePrefixList(setup.SplitNetworks)
+	} else {
+		config.Peers[0].AllowedIPs = []netip.Prefix{netip.MustParsePrefix("0.0.0.0/0"), netip.MustParsePrefix("::/0")}
+		config.Interface.KillSwitch = setup.KillSwitch
+	}
+	for _, field := range strings.Split(setup.DNS, ",") {
+		field = strings.TrimSpace(field)
+		if len(field) == 0 {
+			continue
+		}
+		if addr, err := netip.ParseAddr(field); err == nil {
+			config.Interface.DNS = append(config.Interface.DNS, addr)
+		} else {
+			config.Interface.DNSSearch = append(config.Interface.DNSSearch, field)
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,104 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"testing"
+)
+
+func testGuidedSetup(t *testing.T) *GuidedSetup {
+	privateKey, err := NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &GuidedSetup{
+		Name:            "office",
+		PrivateKey:      *privateKey,
+		Addresses:       "10.0.0.2/32, fd00::2",
+		ServerPublicKey: "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=",
+		ServerEndpoint:  " vpn.example.com:51820 ",
+		DNS:             "10.0.0.1, corp.example.com",
+	}
+}
+
+func TestGuidedSetupFullTunnel(t *testing.T) {
+	setup := testGuidedSetup(t)
+	setup.KillSwitch = true
+	config, err := setup.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Interface.Addresses) != 2 || config.Interface.Addresses[1].String() != "fd00::2/128" {
+		t.Errorf("Addresses are %v", config.Interface.Addresses)
+	}
+	if len(config.Peers) != 1 || len(config.Peers[0].AllowedIPs) != 2 || config.Peers[0].AllowedIPs[1].String() != "::/0" {
+		t.Errorf("Peers are %v", config.Peers)
+	}
+	if config.Peers[0].Endpoint.String() != "vpn.example.com:51820" {
+		t.Errorf("Endpoint is %s", config.Peers[0].Endpoint.String())
+	}
+	if !config.Interface.KillSwitch {
+		t.Error("Kill switch is off")
+	}
+	if len(config.Interface.DNS) != 1 || len(config.Interface.DNSSearch) != 1 || config.Interface.DNSSearch[0] != "corp.example.com" {
+		t.Errorf("DNS is %v, search is %v", config.Interface.DNS, config.Interface.DNSSearch)
+	}
+	if _, err = FromWgQuick(config.ToWgQuick(), config.Name); err != nil {
+		t.Errorf("Configuration does not parse: %v", err)
+	}
+}
+
+func TestGuidedSetupSplitTunnel(t *testing.T) {
+	setup := testGuidedSetup(t)
+	setup.Routing = SplitTunnel
+	setup.KillSwitch = true
+	if err := setup.CheckRouting(); err == nil {
+		t.Error("Split tunnel without networks should fail")
+	}
+	setup.SplitNetworks = "192.168.10.7/24,, 10.0.0.0/8"
+	config, err := setup.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Peers[0].AllowedIPs) != 2 || config.Peers[0].AllowedIPs[0].String() != "192.168.10.0/24" {
+		t.Errorf("Allowed IPs are %v", config.Peers[0].AllowedIPs)
+	}
+	if config.Interface.KillSwitch {
+		t.Error("Kill switch is on for a split tunnel")
+	}
+}
+
+func TestGuidedSetupChecks(t *testing.T) {
+	setup := testGuidedSetup(t)
+	setup.Name = "no/slash"
+	if err := setup.CheckInterface(); err == nil {
+		t.Error("Invalid name should fail")
+	}
+	setup = testGuidedSetup(t)
+	setup.Addresses = " "
+	if err := setup.CheckInterface(); err == nil {
+		t.Error("Missing address should fail")
+	}
+	setup = testGuidedSetup(t)
+	setup.ServerPublicKey = setup.PrivateKey.Public().String()
+	if err := setup.CheckServer(); err == nil {
+		t.Error("Own public key as server key should fail")
+	}
+	setup = testGuidedSetup(t)
+	setup.ServerEndpoint = "vpn.example.com"
+	if err := setup.CheckServer(); err == nil {
+		t.Error("Endpoint without port should fail")
+	}
+	setup = testGuidedSetup(t)
+	setup.DNS = "10.0.0.1/24"
+	if err := setup.CheckDNS(); err == nil {
+		t.Error("Prefix as DNS server should fail")
+	}
+	setup.DNS = ""
+	if _, err := setup.Config(); err != nil {
+		t.Errorf("Configuration without DNS fails: %v", err)
+	}
+}
@@ -8,6 +8,7 @@ package conf
 import (
 	"fmt"
 	"net/netip"
+	"strconv"
 	"strings"
 	"unsafe"
 
@@ -47,25 +48,106 @@ func (conf *Config) ToWgQuick() string {
 		output.WriteString(fmt.Sprintf("MTU = %d\n", conf.Interface.MTU))
 	}
 
-	if len(conf.Interface.PreUp) > 0 {
-		output.WriteString(fmt.Sprintf("PreUp = %s\n", conf.Interface.PreUp))
+	if conf.Interface.InterfaceMetric > 0 {
+		output.WriteString(fmt.Sprintf("InterfaceMetric = %d\n", conf.Interface.InterfaceMetric))
 	}
-	if len(conf.Interface.PostUp) > 0 {
-		output.WriteString(fmt.Sprintf("PostUp = %s\n", conf.Interface.PostUp))
+	if conf.Interface.RouteMetric > 0 {
+		output.WriteString(fmt.Sprintf("RouteMetric = %d\n", conf.Interface.RouteMetric))
 	}
-	if len(conf.Interface.PreDown) > 0 {
-		output.WriteString(fmt.Sprintf("PreDown = %s\n", conf.Interface.PreDown))
+
+	for _, command := range conf.Interface.PreUp {
+		output.WriteString(fmt.Sprintf("PreUp = %s\n", command))
+	}
+	for _, command := range conf.Interface.PostUp {
+		output.WriteString(fmt.Sprintf("PostUp = %s\n", command))
+	}
+	for _, command := range conf.Interface.PreDown {
+		output.WriteString(fmt.Sprintf("PreDown = %s\n", command))
 	}
-	if len(conf.Interface.PostDown) > 0 {
-		output.WriteString(fmt.Sprintf("PostDown = %s\n", conf.Interface.PostDown))
+	for _, command := range conf.Interface.PostDown {
+		output.WriteString(fmt.Sprintf("PostDown = %s\n", command))
+	}
+	if conf.Interface.ScriptTimeout > 0 {
+		output.WriteString(fmt.Sprintf("ScriptTimeout = %d\n", conf.Interface.ScriptTimeout))
 	}
 	if conf.Interface.TableOff {
 		output.WriteString("Table = off\n")
 	}
+	if conf.Interface.KillSwitch {
+		output.WriteString("KillSwitch = true\n")
+	}
+	if conf.Interface.DisableTemporaryAddresses {
+		output.WriteString("DisableTemporaryAddresses = true\n")
+	}
+	if conf.Interface.DisableDAD {
+		output.WriteString("DisableDAD = true\n")
+	}
+	if len(conf.Interface.ExcludeIPs) > 0 {
+		addrStrings := make([]string, len(conf.Interface.ExcludeIPs))
+		for i, address := range conf.Interface.ExcludeIPs {
+			addrStrings[i] = address.String()
+		}
+		output.WriteString(fmt.Sprintf("ExcludeIPs = %s\n", strings.Join(addrStrings, ", ")))
+	}
+	if len(conf.Interface.ExcludePorts) > 0 {
+		portStrings := make([]string, len(conf.Interface.ExcludePorts))
+		for i, port := range conf.Interface.ExcludePorts {
+			portStrings[i] = strconv.Itoa(int(port))
+		}
+		output.WriteString(fmt.Sprintf("ExcludePorts = %s\n", strings.Join(portStrings, ", ")))
+	}
+	if len(conf.Interface.ExcludeApps) > 0 {
+		output.WriteString(fmt.Sprintf("ExcludeApps = %s\n", strings.Join(conf.Interface.ExcludeApps, ", ")))
+	}
+	if conf.Interface.AllowLocalNetwork {
+		output.WriteString("AllowLocalNetwork = true\n")
+	}
+	if len(conf.Interface.BindInterface) > 0 {
+		output.WriteString(fmt.Sprintf("BindInterface = %s\n", conf.Interface.BindInterface))
+	}
+	if len(conf.Interface.CheckHost) > 0 {
+		output.WriteString(fmt.Sprintf("CheckHost = %s\n", conf.Interface.CheckHost))
+	}
+	if conf.Interface.CheckTimeout > 0 {
+		output.WriteString(fmt.Sprintf("CheckTimeout = %d\n", conf.Interface.CheckTimeout))
+	}
+	if conf.Interface.AdapterGUID != nil {
+		output.WriteString(fmt.Sprintf("AdapterGUID = %s\n", conf.Interface.AdapterGUID))
+	}
+	if len(conf.Interface.PeerSource) > 0 {
+		output.WriteString(fmt.Sprintf("PeerSource = %s\n", conf.Interface.PeerSource))
+	}
+	if len(conf.Interface.PeerSourceKey) > 0 {
+		output.WriteString(fmt.Sprintf("PeerSourceKey = %s\n", conf.Interface.PeerSourceKey))
+	}
+	obfuscation := &conf.Interface.Obfuscation
+	for _, parameter := range []struct {
+		key   string
+		value uint32
+	}{
+		{"Jc", uint32(obfuscation.Jc)},
+		{"Jmin", uint32(obfuscation.Jmin)},
+		{"Jmax", uint32(obfuscation.Jmax)},
+		{"S1", uint32(obfuscation.S1)},
+		{"S2", uint32(obfuscation.S2)},
+		{"H1", obfuscation.H1},
+		{"H2", obfuscation.H2},
+		{"H3", obfuscation.H3},
+		{"H4", obfuscation.H4},
+	} {
+		if parameter.value != 0 {
+			output.WriteString(fmt.Sprintf("%s = %d\n", parameter.key, parameter.value))
+		}
+	}
 
 	for _, peer := range conf.Peers {
 		output.WriteString("\n[Peer]\n")
 
+		// The alias is written as a comment, which wg-quick and other tools ignore.
+		if len(peer.Name) > 0 {
+			output.WriteString(fmt.Sprintf("# Name = %s\n", strings.Join(strings.Fields(peer.Name), " ")))
+		}
+
 		output.WriteString(fmt.Sprintf("PublicKey = %s\n", peer.PublicKey.String()))
 
 		if !peer.PresharedKey.IsZero() {
@@ -80,6 +162,14 @@ func (conf *Config) ToWgQuick() string {
 			output.WriteString(fmt.Sprintf("AllowedIPs = %s\n", strings.Join(addrStrings[:], ", ")))
 		}
 
+		if len(peer.DisallowedIPs) > 0 {
+			addrStrings := make([]string, len(peer.DisallowedIPs))
+			for i, address := range peer.DisallowedIPs {
+				addrStrings[i] = address.String()
+			}
+			output.WriteString(fmt.Sprintf("DisallowedIPs = %s\n", strings.Join(addrStrings, ", ")))
+		}
+
 		if !peer.Endpoint.IsEmpty() {
 			output.WriteString(fmt.Sprintf("Endpoint = %s\n", peer.Endpoint.String()))
 		}
@@ -92,12 +182,8 @@ func (conf *Config) ToWgQuick() string {
 }
 
 func (config *Config) ToDriverConfiguration() (*driver.Interface, uint32) {
-	preallocation := unsafe.Sizeof(driver.Interface{}) + uintptr(len(config.Peers))*unsafe.Sizeof(driver.Peer{})
-	for i := range config.Peers {
-		preallocation += uintptr(len(config.Peers[i].AllowedIPs)) * unsafe.Sizeof(driver.AllowedIP{})
-	}
 	var c driver.ConfigBuilder
-	c.Preallocate(uint32(preallocation))
+	c.Preallocate(config.driverPreallocation(0))
 	c.AppendInterface(&driver.Interface{
 		Flags:      driver.InterfaceHasPrivateKey | driver.InterfaceHasListenPort,
 		ListenPort: config.Interface.ListenPort,
@@ -105,36 +191,109 @@ func (config *Config) ToDriverConfiguration() (*driver.Interface, uint32) {
 		PeerCount:  uint32(len(config.Peers)),
 	})
 	for i := range config.Peers {
-		flags := driver.PeerHasPublicKey | driver.PeerHasPersistentKeepalive
-		if !config.Peers[i].PresharedKey.IsZero() {
-			flags |= driver.PeerHasPresharedKey
-		}
-		var endpoint winipcfg.RawSockaddrInet
-		if !config.Peers[i].Endpoint.IsEmpty() {
-			addr, err := netip.ParseAddr(config.Peers[i].Endpoint.Host)
-			if err == nil {
-				flags |= driver.PeerHasEndpoint
-				endpoint.SetAddrPort(netip.AddrPortFrom(addr, config.Peers[i].Endpoint.Port))
-			}
+		appendDriverPeer(&c, &config.Peers[i], 0)
+	}
+	return c.Interface()
+}
+
+// ToDriverSyncConfiguration returns a configuration that, when applied on top of runningConfig,
+// brings the peers of the adapter in line with config, leaving the interface's private key and
+// listen port alone. Peers missing from config are removed, and the allowed IPs of the rest are
+// replaced, so that existing sessions of unchanged peers survive. Preshared keys missing from
+// config are removed as well, as with wg syncconf.
+func (config *Config) ToDriverSyncConfiguration(runningConfig *Config) (*driver.Interface, uint32) {
+	wanted := make(map[Key]bool, len(config.Peers))
+	for i := range config.Peers {
+		wanted[config.Peers[i].PublicKey] = true
+	}
+	var removed []Key
+	for i := range runningConfig.Peers {
+		if !wanted[runningConfig.Peers[i].PublicKey] {
+			removed = append(removed, runningConfig.Peers[i].PublicKey)
 		}
+	}
+	var c driver.ConfigBuilder
+	c.Preallocate(config.driverPreallocation(len(removed)))
+	c.AppendInterface(&driver.Interface{
+		PeerCount: uint32(len(removed) + len(config.Peers)),
+	})
+	for i := range removed {
 		c.AppendPeer(&driver.Peer{
-			Flags:               flags,
-			PublicKey:           config.Peers[i].PublicKey,
-			PresharedKey:        config.Peers[i].PresharedKey,
-			PersistentKeepalive: config.Peers[i].PersistentKeepalive,
-			Endpoint:            endpoint,
-			AllowedIPsCount:     uint32(len(config.Peers[i].AllowedIPs)),
+			Flags:     driver.PeerHasPublicKey | driver.PeerRemove,
+			PublicKey: removed[i],
 		})
-		for j := range config.Peers[i].AllowedIPs {
-			a := &driver.AllowedIP{Cidr: uint8(config.Peers[i].AllowedIPs[j].Bits())}
-			copy(a.Address[:], config.Peers[i].AllowedIPs[j].Addr().AsSlice())
-			if config.Peers[i].AllowedIPs[j].Addr().Is4() {
-				a.AddressFamily = windows.AF_INET
-			} else if config.Peers[i].AllowedIPs[j].Addr().Is6() {
-				a.AddressFamily = windows.AF_INET6
-			}
-			c.AppendAllowedIP(a)
-		}
 	}
+	// The preshared key is always given, so that a zero key removes one that config dropped.
+	for i := range config.Peers {
+		appendDriverPeer(&c, &config.Peers[i], driver.PeerReplaceAllowedIPs|driver.PeerHasPresharedKey)
+	}
+	return c.Interface()
+}
+
+// ToDriverPrivateKeyConfiguration returns a configuration that replaces the private key of the
+// adapter with the one of config, leaving its listen port and peers alone.
+func (config *Config) ToDriverPrivateKeyConfiguration() (*driver.Interface, uint32) {
+	var c driver.ConfigBuilder
+	c.Preallocate(uint32(unsafe.Sizeof(driver.Interface{})))
+	c.AppendInterface(&driver.Interface{
+		Flags:      driver.InterfaceHasPrivateKey,
+		PrivateKey: config.Interface.PrivateKey,
+	})
 	return c.Interface()
 }
+
+// ToDriverPresharedKeyConfiguration returns a configuration that replaces the preshared key of the
+// adapter's peer with the one of config.Peers[i], leaving everything else alone.
+func (config *Config) ToDriverPresharedKeyConfiguration(i int) (*driver.Interface, uint32) {
+	peer := &config.Peers[i]
+	var c driver.ConfigBuilder
+	c.Preallocate(uint32(unsafe.Sizeof(driver.Interface{}) + unsafe.Sizeof(driver.Peer{})))
+	c.AppendInterface(&driver.Interface{PeerCount: 1})
+	c.AppendPeer(&driver.Peer{
+		Flags:        driver.PeerHasPublicKey | driver.PeerHasPresharedKey | driver.PeerUpdateOnly,
+		PublicKey:    peer.PublicKey,
+		PresharedKey: peer.PresharedKey,
+	})
+	return c.Interface()
+}
+
+func (config *Config) driverPreallocation(extraPeers int) uint32 {
+	preallocation := unsafe.Sizeof(driver.Interface{}) + uintptr(len(config.Peers)+extraPeers)*unsafe.Sizeof(driver.Peer{})
+	for i := range config.Peers {
+		preallocation += uintptr(len(config.Peers[i].AllowedIPs)) * unsafe.Sizeof(driver.AllowedIP{})
+	}
+	return uint32(preallocation)
+}
+
+func appendDriverPeer(c *driver.ConfigBuilder, peer *Peer, flags driver.PeerFlag) {
+	flags |= driver.PeerHasPublicKey | driver.PeerHasPersistentKeepalive
+	if !peer.PresharedKey.IsZero() {
+		flags |= driver.PeerHasPresharedKey
+	}
+	var endpoint winipcfg.RawSockaddrInet
+	if !peer.Endpoint.IsEmpty() {
+		addr, err := netip.ParseAddr(peer.Endpoint.Host)
+		if err == nil {
+			flags |= driver.PeerHasEndpoint
+			endpoint.SetAddrPort(netip.AddrPortFrom(addr, peer.Endpoint.Port))
+		}
+	}
+	c.AppendPeer(&driver.Peer{
+		Flags:               flags,
+		PublicKey:           peer.PublicKey,
+		PresharedKey:        peer.PresharedKey,
+		PersistentKeepalive: peer.PersistentKeepalive,
+		Endpoint:            endpoint,
+		AllowedIPsCount:     uint32(len(peer.AllowedIPs)),
+	})
+	for j := range peer.AllowedIPs {
+		a := &driver.AllowedIP{Cidr: uint8(peer.AllowedIPs[j].Bits())}
+		copy(a.Address[:], peer.AllowedIPs[j].Addr().AsSlice())
+		if peer.AllowedIPs[j].Addr().Is4() {
+			a.AddressFamily = windows.AF_INET
+		} else if peer.AllowedIPs[j].Addr().Is6() {
+			a.AddressFamily = windows.AF_INET6
+		}
+		c.AppendAllowedIP(a)
+	}
+}
@@ -0,0 +1,42 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package conf
+
+import (
+	"strings"
+	"testing"
+
+	"golang.zx2c4.com/wireguard/windows/driver"
+)
+
+func TestSyncConfigurationRemovesPresharedKey(t *testing.T) {
+	running, err := FromWgQuick(testInput, "test")
+	if !noError(t, err) {
+		return
+	}
+	synced, err := FromWgQuick(strings.Replace(testInput, "PresharedKey = TrMvSoP4jYQlY6RIzBgbssQqY3vxI2Pi+y71lOWWXX0= \n", "", 1), "test")
+	if !noError(t, err) {
+		return
+	}
+	lenTest(t, synced.Peers, 3)
+	if !synced.Peers[2].PresharedKey.IsZero() {
+		t.Fatal("Preshared key was not removed from the test configuration")
+	}
+
+	interfaze, _ := synced.ToDriverSyncConfiguration(running)
+	equal(t, uint32(3), interfaze.PeerCount)
+	peer := interfaze.FirstPeer()
+	for i := uint32(0); i < interfaze.PeerCount; i++ {
+		if Key(peer.PublicKey) == synced.Peers[2].PublicKey {
+			if peer.Flags&driver.PeerHasPresharedKey == 0 || peer.PresharedKey != [32]byte{} {
+				t.Errorf("Synchronized peer does not clear its preshared key: flags %#x", peer.Flags)
+			}
+			return
+		}
+		peer = peer.NextPeer()
+	}
+	t.Error("Synchronized configuration lacks the peer")
+}
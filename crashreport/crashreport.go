@@ -0,0 +1,193 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package crashreport
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/ringlogger"
+)
+
+// Crash reports are opt-in, by way of the CrashReporting admin registry key, because even a
+// small minidump contains stack memory, which might hold key material. The services write them
+// to the data directory, whereas the UI, whose token may not write there, writes them to the
+// local application data directory of its user.
+
+const (
+	maxLogTailLines = 256
+	maxReports      = 20
+
+	// MiniDumpNormal | MiniDumpWithUnloadedModules | MiniDumpWithThreadInfo
+	miniDumpType = 0x00000000 | 0x00000020 | 0x00001000
+)
+
+// Enabled reports whether the administrator has opted in to crash reports.
+func Enabled() bool {
+	return conf.AdminBool("CrashReporting")
+}
+
+// Directory returns the directory in which crash reports are written, creating it if needed.
+func Directory() (string, error) {
+	root, err := conf.RootDirectory(true)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, "Crashes")
+	err = os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// UserDirectory returns the directory in which crash reports of the UI are written for the
+// current user, creating it if needed.
+func UserDirectory() (string, error) {
+	root, err := windows.KnownFolderPath(windows.FOLDERID_LocalAppData, windows.KF_FLAG_DEFAULT)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, "WireGuard", "Crashes")
+	err = os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// Reports returns the paths of all crash reports and minidumps of the services, oldest first.
+func Reports() ([]string, error) {
+	dir, err := Directory()
+	if err != nil {
+		return nil, err
+	}
+	return reportsIn(dir)
+}
+
+func reportsIn(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type report struct {
+		path    string
+		modTime time.Time
+	}
+	reports := make([]report, 0, len(entries))
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".txt" && ext != ".dmp") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		reports = append(reports, report{filepath.Join(dir, entry.Name()), info.ModTime()})
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].modTime.Before(reports[j].modTime)
+	})
+	paths := make([]string, len(reports))
+	for i := range reports {
+		paths[i] = reports[i].path
+	}
+	return paths, nil
+}
+
+// Recover is meant to be deferred at the top of a process's main goroutine. If that goroutine
+// panics, it reports the crash, if enabled, and then continues panicking.
+func Recover(tag string) {
+	if err := recover(); err != nil {
+		Report(tag, err, debug.Stack())
+		panic(err)
+	}
+}
+
+// Report writes a minidump of the current process and a text report with the reason, the
+// stack, and the tail of the global log, if crash reporting is enabled.
+func Report(tag string, reason any, stack []byte) {
+	if !Enabled() {
+		return
+	}
+	report(Directory, tag, reason, stack)
+}
+
+// ReportForUser is like Report, but writes to UserDirectory, for the UI.
+func ReportForUser(tag string, reason any, stack []byte) {
+	if !Enabled() {
+		return
+	}
+	report(UserDirectory, tag, reason, stack)
+}
+
+func report(directory func() (string, error), tag string, reason any, stack []byte) {
+	dir, err := directory()
+	if err != nil {
+		log.Printf("Unable to write crash report: %v", err)
+		return
+	}
+	base := filepath.Join(dir, fmt.Sprintf("%s-%s-%d", tag, time.Now().Format("20060102-150405"), os.Getpid()))
+
+	var report bytes.Buffer
+	fmt.Fprintf(&report, "panic: %v\n\n%s\n", reason, stack)
+	if ringlogger.Global != nil {
+		var logBuffer bytes.Buffer
+		ringlogger.Global.WriteTo(&logBuffer)
+		lines := strings.SplitAfter(logBuffer.String(), "\n")
+		if len(lines) > maxLogTailLines {
+			lines = lines[len(lines)-maxLogTailLines:]
+		}
+		report.WriteString("Log:\n")
+		report.WriteString(strings.Join(lines, ""))
+	}
+	err = os.WriteFile(base+".txt", report.Bytes(), 0o600)
+	if err != nil {
+		log.Printf("Unable to write crash report: %v", err)
+	}
+
+	err = writeMinidump(base + ".dmp")
+	if err != nil {
+		log.Printf("Unable to write minidump: %v", err)
+	}
+	log.Printf("Wrote crash report to %#q", base+".txt")
+	pruneReports(dir)
+}
+
+func writeMinidump(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = miniDumpWriteDump(windows.CurrentProcess(), windows.GetCurrentProcessId(), windows.Handle(file.Fd()), miniDumpType, 0, 0, 0)
+	file.Close()
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+func pruneReports(dir string) {
+	reports, err := reportsIn(dir)
+	if err != nil {
+		return
+	}
+	for len(reports) > maxReports {
+		os.Remove(reports[0])
+		reports = reports[1:]
+	}
+}
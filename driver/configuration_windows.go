@@ -6,13 +6,25 @@
 package driver
 
 import (
-	"syscall"
 	"unsafe"
 
-	"golang.org/x/sys/windows"
 	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
 )
 
+const AdapterNameMax = 128
+
+type TimestampedWriter interface {
+	WriteWithTimestamp(p []byte, ts int64) (n int, err error)
+}
+
+type AdapterLogState uint32
+
+const (
+	AdapterLogOff          AdapterLogState = 0
+	AdapterLogOn           AdapterLogState = 1
+	AdapterLogOnWithPrefix AdapterLogState = 2
+)
+
 type AdapterState uint32
 
 const (
@@ -72,59 +84,6 @@ type Interface struct {
 	_          [4]byte
 }
 
-var (
-	procWireGuardSetAdapterState  = modwireguard.NewProc("WireGuardSetAdapterState")
-	procWireGuardGetAdapterState  = modwireguard.NewProc("WireGuardGetAdapterState")
-	procWireGuardSetConfiguration = modwireguard.NewProc("WireGuardSetConfiguration")
-	procWireGuardGetConfiguration = modwireguard.NewProc("WireGuardGetConfiguration")
-)
-
-// SetAdapterState sets the adapter either Up or Down.
-func (wireguard *Adapter) SetAdapterState(adapterState AdapterState) (err error) {
-	r0, _, e1 := syscall.SyscallN(procWireGuardSetAdapterState.Addr(), wireguard.handle, uintptr(adapterState))
-	if r0 == 0 {
-		err = e1
-	}
-	return
-}
-
-// AdapterState returns the current state of the adapter.
-func (wireguard *Adapter) AdapterState() (adapterState AdapterState, err error) {
-	r0, _, e1 := syscall.SyscallN(procWireGuardGetAdapterState.Addr(), wireguard.handle, uintptr(unsafe.Pointer(&adapterState)))
-	if r0 == 0 {
-		err = e1
-	}
-	return
-}
-
-// SetConfiguration sets the adapter configuration.
-func (wireguard *Adapter) SetConfiguration(interfaze *Interface, size uint32) (err error) {
-	r0, _, e1 := syscall.SyscallN(procWireGuardSetConfiguration.Addr(), wireguard.handle, uintptr(unsafe.Pointer(interfaze)), uintptr(size))
-	if r0 == 0 {
-		err = e1
-	}
-	return
-}
-
-// Configuration gets the adapter configuration.
-func (wireguard *Adapter) Configuration() (interfaze *Interface, err error) {
-	size := wireguard.lastGetGuessSize
-	if size == 0 {
-		size = 512
-	}
-	for {
-		buf := make([]byte, size)
-		r0, _, e1 := syscall.SyscallN(procWireGuardGetConfiguration.Addr(), wireguard.handle, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)))
-		if r0 != 0 {
-			wireguard.lastGetGuessSize = size
-			return (*Interface)(unsafe.Pointer(&buf[0])), nil
-		}
-		if e1 != windows.ERROR_MORE_DATA {
-			return nil, e1
-		}
-	}
-}
-
 // FirstPeer returns the first peer attached to the interface.
 func (interfaze *Interface) FirstPeer() *Peer {
 	return (*Peer)(unsafe.Add(unsafe.Pointer(interfaze), unsafe.Sizeof(*interfaze)))
@@ -0,0 +1,212 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package driver
+
+import (
+	"errors"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// When the driver cannot be loaded, Windows reports a setupapi or code integrity error that says
+// little about why. DiagnoseAdapterFailure looks for the usual causes, so that they can be
+// explained, in the language of whoever sees them, rather than the error itself.
+
+// AdapterProblem is a likely cause of a failure to create an adapter.
+type AdapterProblem uint32
+
+const (
+	AdapterProblemSignature       AdapterProblem = iota + 1 // Windows did not accept the signature of the driver.
+	AdapterProblemBlocked                                   // The driver was blocked by policy.
+	AdapterProblemMemoryIntegrity                           // Memory integrity (HVCI) is on.
+	AdapterProblemSecureBoot                                // Secure Boot is on, which refuses test-signed drivers.
+	AdapterProblemSHA2Support                               // Windows 7 lacks the update for SHA-2 signed drivers.
+	AdapterProblemFilterDrivers                             // Third-party network filter drivers are installed.
+)
+
+// AdapterDiagnosis is the result of DiagnoseAdapterFailure.
+type AdapterDiagnosis struct {
+	Problems      []AdapterProblem
+	FilterDrivers []string // Descriptions of the third-party network filter drivers.
+}
+
+func (problem AdapterProblem) String() string {
+	switch problem {
+	case AdapterProblemSignature:
+		return "driver signature not accepted"
+	case AdapterProblemBlocked:
+		return "driver blocked by policy"
+	case AdapterProblemMemoryIntegrity:
+		return "memory integrity enabled"
+	case AdapterProblemSecureBoot:
+		return "Secure Boot enabled"
+	case AdapterProblemSHA2Support:
+		return "SHA-2 code signing support (KB4474419) missing"
+	case AdapterProblemFilterDrivers:
+		return "third-party network filter drivers installed"
+	default:
+		return "unknown problem"
+	}
+}
+
+func (diagnosis *AdapterDiagnosis) String() string {
+	if len(diagnosis.Problems) == 0 {
+		return "no known cause found"
+	}
+	causes := make([]string, 0, len(diagnosis.Problems))
+	for _, problem := range diagnosis.Problems {
+		if problem == AdapterProblemFilterDrivers {
+			causes = append(causes, problem.String()+": "+strings.Join(diagnosis.FilterDrivers, ", "))
+		} else {
+			causes = append(causes, problem.String())
+		}
+	}
+	return strings.Join(causes, "; ")
+}
+
+func (diagnosis *AdapterDiagnosis) has(problem AdapterProblem) bool {
+	for _, p := range diagnosis.Problems {
+		if p == problem {
+			return true
+		}
+	}
+	return false
+}
+
+func (diagnosis *AdapterDiagnosis) add(problem AdapterProblem) {
+	if !diagnosis.has(problem) {
+		diagnosis.Problems = append(diagnosis.Problems, problem)
+	}
+}
+
+// Device problem codes, which x/sys/windows lacks.
+const (
+	cmProbDriverBlocked  = 48
+	cmProbUnsignedDriver = 52
+)
+
+// Code integrity options, as reported by SystemCodeIntegrityInformation.
+const (
+	codeIntegrityOptionTestSign        = 0x02
+	codeIntegrityOptionHVCIKMCIEnabled = 0x400
+)
+
+func codeIntegrityOptions() (uint32, bool) {
+	info := struct {
+		length  uint32
+		options uint32
+	}{length: 8}
+	err := windows.NtQuerySystemInformation(int32(windows.SystemCodeIntegrityInformation), unsafe.Pointer(&info), uint32(unsafe.Sizeof(info)), nil)
+	return info.options, err == nil
+}
+
+func secureBootEnabled() bool {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Control\SecureBoot\State`, registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	defer key.Close()
+	enabled, _, err := key.GetIntegerValue("UEFISecureBootEnabled")
+	return err == nil && enabled != 0
+}
+
+// lacksSHA2Support reports whether this is Windows 7 without KB4474419, which cannot load drivers
+// that are only signed with SHA-2, as all current ones are.
+func lacksSHA2Support() bool {
+	major, minor, _ := windows.RtlGetNtVersionNumbers()
+	if major != 6 || minor != 1 {
+		return false
+	}
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Windows\CurrentVersion\Component Based Servicing\Packages`, registry.ENUMERATE_SUB_KEYS)
+	if err != nil {
+		return false
+	}
+	defer key.Close()
+	names, err := key.ReadSubKeyNames(-1)
+	if err != nil {
+		return false
+	}
+	for _, name := range names {
+		if strings.HasPrefix(strings.ToUpper(name), "PACKAGE_FOR_KB4474419") {
+			return false
+		}
+	}
+	return true
+}
+
+// thirdPartyFilterDrivers returns the descriptions of the network filter drivers not made by
+// Microsoft, such as those of antivirus and firewall products, which sometimes get in the way of
+// new adapters.
+func thirdPartyFilterDrivers() []string {
+	const netServiceClass = `SYSTEM\CurrentControlSet\Control\Class\{4d36e974-e325-11ce-bfc1-08002be10318}`
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, netServiceClass, registry.ENUMERATE_SUB_KEYS)
+	if err != nil {
+		return nil
+	}
+	defer key.Close()
+	names, err := key.ReadSubKeyNames(-1)
+	if err != nil {
+		return nil
+	}
+	var filters []string
+	for _, name := range names {
+		subkey, err := registry.OpenKey(key, name, registry.QUERY_VALUE)
+		if err != nil {
+			continue
+		}
+		provider, _, _ := subkey.GetStringValue("ProviderName")
+		description, _, _ := subkey.GetStringValue("DriverDesc")
+		subkey.Close()
+		if len(description) == 0 || len(provider) == 0 || strings.EqualFold(provider, "Microsoft") {
+			continue
+		}
+		filters = append(filters, description)
+	}
+	return filters
+}
+
+// DiagnoseAdapterFailure looks for the likely causes of err, the failure to create an adapter, or
+// of such a failure in general if err is nil, most likely first.
+func DiagnoseAdapterFailure(err error) AdapterDiagnosis {
+	var diagnosis AdapterDiagnosis
+	switch {
+	case errors.Is(err, windows.ERROR_INVALID_IMAGE_HASH), errors.Is(err, windows.Errno(windows.TRUST_E_NOSIGNATURE)):
+		diagnosis.add(AdapterProblemSignature)
+	case errors.Is(err, windows.ERROR_DRIVER_BLOCKED):
+		diagnosis.add(AdapterProblemBlocked)
+	}
+	forEachAdapterDevice(func(devInfo windows.DevInfo, devInfoData *windows.DevInfoData, legacy bool) bool {
+		var status, problem uint32
+		if legacy || windows.CM_Get_DevNode_Status(&status, &problem, devInfoData.DevInst, 0) != nil || status&windows.DN_HAS_PROBLEM == 0 {
+			return true
+		}
+		switch problem {
+		case cmProbUnsignedDriver:
+			diagnosis.add(AdapterProblemSignature)
+		case cmProbDriverBlocked:
+			diagnosis.add(AdapterProblemBlocked)
+		}
+		return true
+	})
+	if lacksSHA2Support() {
+		diagnosis.add(AdapterProblemSHA2Support)
+	}
+	options, ok := codeIntegrityOptions()
+	if ok && options&codeIntegrityOptionHVCIKMCIEnabled != 0 && !diagnosis.has(AdapterProblemSignature) {
+		diagnosis.add(AdapterProblemMemoryIntegrity)
+	}
+	if secureBootEnabled() && diagnosis.has(AdapterProblemSignature) && (!ok || options&codeIntegrityOptionTestSign == 0) {
+		diagnosis.add(AdapterProblemSecureBoot)
+	}
+	if filters := thirdPartyFilterDrivers(); len(filters) > 0 {
+		diagnosis.FilterDrivers = filters
+		diagnosis.add(AdapterProblemFilterDrivers)
+	}
+	return diagnosis
+}
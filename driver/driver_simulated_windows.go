@@ -0,0 +1,264 @@
+//go:build simulated_driver
+
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package driver
+
+// This is an in-memory stand-in for WireGuardNT, selected with the simulated_driver build tag.
+// It keeps adapters and their configuration in process memory and never touches the network
+// stack, so that the tunnel service can be exercised on machines without the driver installed.
+
+import (
+	"bytes"
+	"log"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/sys/windows"
+	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
+)
+
+// SimulatedDriverVersion is what RunningVersion reports when the simulated driver is in use.
+const SimulatedDriverVersion = 0xffff<<16 | 0xffff
+
+type simulatedPeer struct {
+	Peer
+	allowedIPs []AllowedIP
+}
+
+type Adapter struct {
+	mu           sync.Mutex
+	name         string
+	luid         winipcfg.LUID
+	adapterState AdapterState
+	logState     AdapterLogState
+	listenPort   uint16
+	privateKey   [32]byte
+	publicKey    [32]byte
+	hasKey       bool
+	peers        []simulatedPeer
+}
+
+var (
+	// The real DLL is still consulted, if present, so that Version reports something meaningful.
+	modwireguard = newLazyDLL("wireguard.dll", nil)
+
+	simulatedAdapters     = make(map[string]*Adapter)
+	simulatedAdaptersLock sync.Mutex
+	simulatedLUIDIndex    uint64
+)
+
+// CreateAdapter creates a simulated adapter. tunnelType and requestedGUID are ignored.
+func CreateAdapter(name, tunnelType string, requestedGUID *windows.GUID) (wireguard *Adapter, err error) {
+	if len(name) >= AdapterNameMax {
+		return nil, windows.ERROR_INVALID_PARAMETER
+	}
+	simulatedAdaptersLock.Lock()
+	defer simulatedAdaptersLock.Unlock()
+	if _, exists := simulatedAdapters[name]; exists {
+		return nil, windows.ERROR_ALREADY_EXISTS
+	}
+	simulatedLUIDIndex++
+	const ifTypePropVirtual = 53
+	wireguard = &Adapter{
+		name: name,
+		luid: winipcfg.LUID(ifTypePropVirtual<<48 | simulatedLUIDIndex<<24),
+	}
+	simulatedAdapters[name] = wireguard
+	log.Printf("Simulated driver: created adapter %q", name)
+	return wireguard, nil
+}
+
+// OpenAdapter opens an existing simulated adapter by name.
+func OpenAdapter(name string) (wireguard *Adapter, err error) {
+	simulatedAdaptersLock.Lock()
+	defer simulatedAdaptersLock.Unlock()
+	wireguard, exists := simulatedAdapters[name]
+	if !exists {
+		return nil, windows.ERROR_FILE_NOT_FOUND
+	}
+	return wireguard, nil
+}
+
+// Close removes the simulated adapter.
+func (wireguard *Adapter) Close() (err error) {
+	simulatedAdaptersLock.Lock()
+	defer simulatedAdaptersLock.Unlock()
+	if simulatedAdapters[wireguard.name] == wireguard {
+		delete(simulatedAdapters, wireguard.name)
+		log.Printf("Simulated driver: closed adapter %q", wireguard.name)
+	}
+	return nil
+}
+
+// Uninstall fails if any simulated adapters are still in use, just like the real driver.
+func Uninstall() (err error) {
+	simulatedAdaptersLock.Lock()
+	defer simulatedAdaptersLock.Unlock()
+	if len(simulatedAdapters) > 0 {
+		return windows.ERROR_DEVICE_IN_USE
+	}
+	return nil
+}
+
+// SetLogErrorsOnly has no effect, as the simulated driver does not log.
+func SetLogErrorsOnly(errorsOnly bool) {}
+
+// SetLogObserver has no effect, as the simulated driver does not log.
+func SetLogObserver(observer func(msg string)) {}
+
+// SetLogging records the requested logging state.
+func (wireguard *Adapter) SetLogging(logState AdapterLogState) (err error) {
+	wireguard.mu.Lock()
+	defer wireguard.mu.Unlock()
+	wireguard.logState = logState
+	return nil
+}
+
+// RunningVersion returns SimulatedDriverVersion.
+func RunningVersion() (version uint32, err error) {
+	return SimulatedDriverVersion, nil
+}
+
+// Features reports only that the driver is simulated.
+func Features() []string {
+	return []string{"simulated"}
+}
+
+// LUID returns the made-up LUID of the adapter.
+func (wireguard *Adapter) LUID() (luid winipcfg.LUID) {
+	return wireguard.luid
+}
+
+// SetAdapterState sets the adapter either Up or Down.
+func (wireguard *Adapter) SetAdapterState(adapterState AdapterState) (err error) {
+	wireguard.mu.Lock()
+	defer wireguard.mu.Unlock()
+	wireguard.adapterState = adapterState
+	return nil
+}
+
+// AdapterState returns the current state of the adapter.
+func (wireguard *Adapter) AdapterState() (adapterState AdapterState, err error) {
+	wireguard.mu.Lock()
+	defer wireguard.mu.Unlock()
+	return wireguard.adapterState, nil
+}
+
+// SetConfiguration applies the configuration with the same semantics as the real driver,
+// honoring the update-only, removal, and replacement flags.
+func (wireguard *Adapter) SetConfiguration(interfaze *Interface, size uint32) (err error) {
+	if interfaze == nil || uintptr(size) < unsafe.Sizeof(*interfaze) {
+		return windows.ERROR_INVALID_PARAMETER
+	}
+	wireguard.mu.Lock()
+	defer wireguard.mu.Unlock()
+
+	if interfaze.Flags&InterfaceHasPrivateKey != 0 {
+		wireguard.privateKey = interfaze.PrivateKey
+		curve25519.ScalarBaseMult(&wireguard.publicKey, &wireguard.privateKey)
+		wireguard.hasKey = true
+	}
+	if interfaze.Flags&InterfaceHasListenPort != 0 {
+		wireguard.listenPort = interfaze.ListenPort
+	}
+	if interfaze.Flags&InterfaceReplacePeers != 0 {
+		wireguard.peers = nil
+	}
+
+	var p *Peer
+	for i := uint32(0); i < interfaze.PeerCount; i++ {
+		if p == nil {
+			p = interfaze.FirstPeer()
+		} else {
+			p = p.NextPeer()
+		}
+		if p.Flags&PeerHasPublicKey == 0 {
+			return windows.ERROR_INVALID_PARAMETER
+		}
+		index := -1
+		for j := range wireguard.peers {
+			if bytes.Equal(wireguard.peers[j].PublicKey[:], p.PublicKey[:]) {
+				index = j
+				break
+			}
+		}
+		if p.Flags&PeerRemove != 0 {
+			if index >= 0 {
+				wireguard.peers = append(wireguard.peers[:index], wireguard.peers[index+1:]...)
+			}
+			continue
+		}
+		if index < 0 {
+			if p.Flags&PeerUpdateOnly != 0 {
+				continue
+			}
+			wireguard.peers = append(wireguard.peers, simulatedPeer{Peer: Peer{PublicKey: p.PublicKey}})
+			index = len(wireguard.peers) - 1
+		}
+		peer := &wireguard.peers[index]
+		if p.Flags&PeerHasPresharedKey != 0 {
+			peer.PresharedKey = p.PresharedKey
+		}
+		if p.Flags&PeerHasPersistentKeepalive != 0 {
+			peer.PersistentKeepalive = p.PersistentKeepalive
+		}
+		if p.Flags&PeerHasEndpoint != 0 {
+			peer.Endpoint = p.Endpoint
+		}
+		if p.Flags&PeerReplaceAllowedIPs != 0 {
+			peer.allowedIPs = nil
+		}
+		var a *AllowedIP
+		for j := uint32(0); j < p.AllowedIPsCount; j++ {
+			if a == nil {
+				a = p.FirstAllowedIP()
+			} else {
+				a = a.NextAllowedIP()
+			}
+			peer.allowedIPs = append(peer.allowedIPs, *a)
+		}
+	}
+	return nil
+}
+
+// Configuration gets the adapter configuration.
+func (wireguard *Adapter) Configuration() (interfaze *Interface, err error) {
+	wireguard.mu.Lock()
+	defer wireguard.mu.Unlock()
+
+	var c ConfigBuilder
+	flags := InterfaceHasListenPort
+	if wireguard.hasKey {
+		flags |= InterfaceHasPrivateKey | InterfaceHasPublicKey
+	}
+	c.AppendInterface(&Interface{
+		Flags:      flags,
+		ListenPort: wireguard.listenPort,
+		PrivateKey: wireguard.privateKey,
+		PublicKey:  wireguard.publicKey,
+		PeerCount:  uint32(len(wireguard.peers)),
+	})
+	for i := range wireguard.peers {
+		peer := wireguard.peers[i].Peer
+		peer.Flags = PeerHasPublicKey | PeerHasPersistentKeepalive
+		var zero [32]byte
+		if peer.PresharedKey != zero {
+			peer.Flags |= PeerHasPresharedKey
+		}
+		if peer.Endpoint.Family != 0 {
+			peer.Flags |= PeerHasEndpoint
+		}
+		peer.AllowedIPsCount = uint32(len(wireguard.peers[i].allowedIPs))
+		c.AppendPeer(&peer)
+		for j := range wireguard.peers[i].allowedIPs {
+			c.AppendAllowedIP(&wireguard.peers[i].allowedIPs[j])
+		}
+	}
+	interfaze, _ = c.Interface()
+	return interfaze, nil
+}
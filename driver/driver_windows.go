@@ -1,3 +1,5 @@
+//go:build !simulated_driver
+
 /* SPDX-License-Identifier: MIT
  *
  * Copyright (C) 2017-2022 WireGuard LLC. All Rights Reserved.
@@ -8,6 +10,7 @@ package driver
 import (
 	"log"
 	"runtime"
+	"sync/atomic"
 	"syscall"
 	"unsafe"
 
@@ -23,8 +26,6 @@ const (
 	logErr
 )
 
-const AdapterNameMax = 128
-
 type Adapter struct {
 	handle           uintptr
 	lastGetGuessSize uint32
@@ -41,15 +42,42 @@ var (
 	procWireGuardSetAdapterLogging       = modwireguard.NewProc("WireGuardSetAdapterLogging")
 )
 
-type TimestampedWriter interface {
-	WriteWithTimestamp(p []byte, ts int64) (n int, err error)
+var logErrorsOnly atomic.Bool
+
+// SetLogErrorsOnly drops the informational messages of the driver and library, such as those about
+// handshakes, and keeps warnings and errors.
+func SetLogErrorsOnly(errorsOnly bool) {
+	logErrorsOnly.Store(errorsOnly)
+}
+
+var logObserver atomic.Pointer[func(msg string)]
+
+// SetLogObserver has observer called with every message of the driver and library, including
+// those that SetLogErrorsOnly keeps out of the log, or nil to stop.
+func SetLogObserver(observer func(msg string)) {
+	if observer == nil {
+		logObserver.Store(nil)
+	} else {
+		logObserver.Store(&observer)
+	}
 }
 
 func logMessage(level loggerLevel, timestamp uint64, msg *uint16) int {
+	observer := logObserver.Load()
+	if observer == nil && level < logWarn && logErrorsOnly.Load() {
+		return 0
+	}
+	message := windows.UTF16PtrToString(msg)
+	if observer != nil {
+		(*observer)(message)
+		if level < logWarn && logErrorsOnly.Load() {
+			return 0
+		}
+	}
 	if tw, ok := log.Default().Writer().(TimestampedWriter); ok {
-		tw.WriteWithTimestamp([]byte(log.Default().Prefix()+windows.UTF16PtrToString(msg)), (int64(timestamp)-116444736000000000)*100)
+		tw.WriteWithTimestamp([]byte(log.Default().Prefix()+message), (int64(timestamp)-116444736000000000)*100)
 	} else {
-		log.Println(windows.UTF16PtrToString(msg))
+		log.Println(message)
 	}
 	return 0
 }
@@ -136,14 +164,6 @@ func Uninstall() (err error) {
 	return
 }
 
-type AdapterLogState uint32
-
-const (
-	AdapterLogOff          AdapterLogState = 0
-	AdapterLogOn           AdapterLogState = 1
-	AdapterLogOnWithPrefix AdapterLogState = 2
-)
-
 // SetLogging enables or disables logging on the WireGuard adapter.
 func (wireguard *Adapter) SetLogging(logState AdapterLogState) (err error) {
 	r1, _, e1 := syscall.SyscallN(procWireGuardSetAdapterLogging.Addr(), wireguard.handle, uintptr(logState))
@@ -163,8 +183,81 @@ func RunningVersion() (version uint32, err error) {
 	return
 }
 
+// Features returns the optional capabilities of the loaded library, named after the exports
+// that provide them, so that bug reports show what the driver in use can do.
+func Features() []string {
+	optional := [...]struct {
+		name string
+		proc *lazyProc
+	}{
+		{"adapter-logging", procWireGuardSetAdapterLogging},
+		{"running-version", procWireGuardGetRunningDriverVersion},
+		{"driver-removal", procWireGuardDeleteDriver},
+	}
+	var features []string
+	for _, feature := range optional {
+		if feature.proc.Find() == nil {
+			features = append(features, feature.name)
+		}
+	}
+	return features
+}
+
 // LUID returns the LUID of the adapter.
 func (wireguard *Adapter) LUID() (luid winipcfg.LUID) {
 	syscall.SyscallN(procWireGuardGetAdapterLUID.Addr(), wireguard.handle, uintptr(unsafe.Pointer(&luid)))
 	return
 }
+
+var (
+	procWireGuardSetAdapterState  = modwireguard.NewProc("WireGuardSetAdapterState")
+	procWireGuardGetAdapterState  = modwireguard.NewProc("WireGuardGetAdapterState")
+	procWireGuardSetConfiguration = modwireguard.NewProc("WireGuardSetConfiguration")
+	procWireGuardGetConfiguration = modwireguard.NewProc("WireGuardGetConfiguration")
+)
+
+// SetAdapterState sets the adapter either Up or Down.
+func (wireguard *Adapter) SetAdapterState(adapterState AdapterState) (err error) {
+	r0, _, e1 := syscall.SyscallN(procWireGuardSetAdapterState.Addr(), wireguard.handle, uintptr(adapterState))
+	if r0 == 0 {
+		err = e1
+	}
+	return
+}
+
+// AdapterState returns the current state of the adapter.
+func (wireguard *Adapter) AdapterState() (adapterState AdapterState, err error) {
+	r0, _, e1 := syscall.SyscallN(procWireGuardGetAdapterState.Addr(), wireguard.handle, uintptr(unsafe.Pointer(&adapterState)))
+	if r0 == 0 {
+		err = e1
+	}
+	return
+}
+
+// SetConfiguration sets the adapter configuration.
+func (wireguard *Adapter) SetConfiguration(interfaze *Interface, size uint32) (err error) {
+	r0, _, e1 := syscall.SyscallN(procWireGuardSetConfiguration.Addr(), wireguard.handle, uintptr(unsafe.Pointer(interfaze)), uintptr(size))
+	if r0 == 0 {
+		err = e1
+	}
+	return
+}
+
+// Configuration gets the adapter configuration.
+func (wireguard *Adapter) Configuration() (interfaze *Interface, err error) {
+	size := wireguard.lastGetGuessSize
+	if size == 0 {
+		size = 512
+	}
+	for {
+		buf := make([]byte, size)
+		r0, _, e1 := syscall.SyscallN(procWireGuardGetConfiguration.Addr(), wireguard.handle, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)))
+		if r0 != 0 {
+			wireguard.lastGetGuessSize = size
+			return (*Interface)(unsafe.Pointer(&buf[0])), nil
+		}
+		if e1 != windows.ERROR_MORE_DATA {
+			return nil, e1
+		}
+	}
+}
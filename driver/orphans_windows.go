@@ -0,0 +1,163 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package driver
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+
+	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
+)
+
+// Adapters are normally removed when the tunnel service that created them stops, but those of a
+// crashed service, or of an uninstall gone wrong, may linger as "ghost" network adapters, which
+// pile up in the device manager and take the numbered names that new adapters would have.
+
+// OrphanedAdapter is a WireGuardNT adapter, or a legacy Wintun adapter of an old version, that no
+// tunnel service owns.
+type OrphanedAdapter struct {
+	Name       string // Interface alias, or the device's name if it is not present.
+	InstanceID string // Device instance ID.
+	Present    bool   // Whether the device is present, or only left in the registry.
+	Legacy     bool   // Whether it is a Wintun adapter.
+}
+
+func (adapter *OrphanedAdapter) String() string {
+	kind := "WireGuardNT"
+	if adapter.Legacy {
+		kind = "Wintun"
+	}
+	state := "present"
+	if !adapter.Present {
+		state = "not present"
+	}
+	return fmt.Sprintf("%s (%s adapter, %s, %s)", adapter.Name, kind, state, adapter.InstanceID)
+}
+
+var deviceClassNetGUID = windows.GUID{Data1: 0x4d36e972, Data2: 0xe325, Data3: 0x11ce, Data4: [8]byte{0xbf, 0xc1, 0x08, 0x00, 0x2b, 0xe1, 0x03, 0x18}}
+
+// Our adapters are created with the WireGuard tunnel type, which the driver puts in the device
+// description, and other programs that use WireGuardNT or Wintun use their own.
+const adapterDeviceDescription = "WireGuard Tunnel"
+
+// ourAdapter reports whether the device is one of our adapters, and whether it is a Wintun one.
+func ourAdapter(devInfo windows.DevInfo, devInfoData *windows.DevInfoData) (ours, legacy bool) {
+	description, _ := devInfo.DeviceRegistryProperty(devInfoData, windows.SPDRP_DEVICEDESC)
+	if s, ok := description.(string); !ok || !strings.HasPrefix(s, adapterDeviceDescription) {
+		return false, false
+	}
+	hardwareIDs, _ := devInfo.DeviceRegistryProperty(devInfoData, windows.SPDRP_HARDWAREID)
+	ids, _ := hardwareIDs.([]string)
+	for _, id := range ids {
+		switch {
+		case strings.EqualFold(id, "WireGuard"):
+			return true, false
+		case strings.EqualFold(id, "Wintun"):
+			return true, true
+		}
+	}
+	return false, false
+}
+
+// adapterName returns the interface alias of a present adapter, or else the name of the device.
+func adapterName(devInfo windows.DevInfo, devInfoData *windows.DevInfoData, present bool) string {
+	if present {
+		key, err := devInfo.OpenDevRegKey(devInfoData, windows.DICS_FLAG_GLOBAL, 0, windows.DIREG_DRV, windows.KEY_QUERY_VALUE)
+		if err == nil {
+			k := registry.Key(key)
+			instanceID, _, err := k.GetStringValue("NetCfgInstanceId")
+			k.Close()
+			if guid, err2 := windows.GUIDFromString(instanceID); err == nil && err2 == nil {
+				if luid, err := winipcfg.LUIDFromGUID(&guid); err == nil {
+					if row, err := luid.Interface(); err == nil {
+						return row.Alias()
+					}
+				}
+			}
+		}
+	}
+	for _, property := range [...]windows.SPDRP{windows.SPDRP_FRIENDLYNAME, windows.SPDRP_DEVICEDESC} {
+		if name, _ := devInfo.DeviceRegistryProperty(devInfoData, property); name != nil {
+			if s, ok := name.(string); ok && len(s) > 0 {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+func devicePresent(devInfoData *windows.DevInfoData) bool {
+	var status, problem uint32
+	return windows.CM_Get_DevNode_Status(&status, &problem, devInfoData.DevInst, 0) == nil
+}
+
+// forEachAdapterDevice calls f with each of our adapters, present or not, until it returns false.
+func forEachAdapterDevice(f func(devInfo windows.DevInfo, devInfoData *windows.DevInfoData, legacy bool) bool) error {
+	devInfo, err := windows.SetupDiGetClassDevsEx(&deviceClassNetGUID, "", 0, 0, 0, "")
+	if err != nil {
+		return err
+	}
+	defer devInfo.Close()
+	for i := 0; ; i++ {
+		devInfoData, err := devInfo.EnumDeviceInfo(i)
+		if err != nil {
+			if err == windows.ERROR_NO_MORE_ITEMS {
+				return nil
+			}
+			continue
+		}
+		if ours, legacy := ourAdapter(devInfo, devInfoData); ours && !f(devInfo, devInfoData, legacy) {
+			return nil
+		}
+	}
+}
+
+// OrphanedAdapters returns our adapters that are not present, which nothing can be using, and
+// those that are present but whose names hasTunnel does not know as those of tunnel services.
+func OrphanedAdapters(hasTunnel func(name string) bool) ([]OrphanedAdapter, error) {
+	var adapters []OrphanedAdapter
+	err := forEachAdapterDevice(func(devInfo windows.DevInfo, devInfoData *windows.DevInfoData, legacy bool) bool {
+		instanceID, err := devInfo.DeviceInstanceID(devInfoData)
+		if err != nil {
+			return true
+		}
+		present := devicePresent(devInfoData)
+		name := adapterName(devInfo, devInfoData, present)
+		if present && !legacy && hasTunnel(name) {
+			return true
+		}
+		adapters = append(adapters, OrphanedAdapter{Name: name, InstanceID: instanceID, Present: present, Legacy: legacy})
+		return true
+	})
+	return adapters, err
+}
+
+// Remove removes the adapter's device.
+func (adapter *OrphanedAdapter) Remove() error {
+	var err error = windows.ERROR_NOT_FOUND
+	enumErr := forEachAdapterDevice(func(devInfo windows.DevInfo, devInfoData *windows.DevInfoData, legacy bool) bool {
+		if instanceID, _ := devInfo.DeviceInstanceID(devInfoData); !strings.EqualFold(instanceID, adapter.InstanceID) {
+			return true
+		}
+		params := windows.RemoveDeviceParams{
+			ClassInstallHeader: *windows.MakeClassInstallHeader(windows.DIF_REMOVE),
+			Scope:              windows.DI_REMOVEDEVICE_GLOBAL,
+		}
+		err = devInfo.SetClassInstallParams(devInfoData, &params.ClassInstallHeader, uint32(unsafe.Sizeof(params)))
+		if err == nil {
+			err = devInfo.CallClassInstaller(windows.DIF_REMOVE, devInfoData)
+		}
+		return false
+	})
+	if enumErr != nil {
+		return enumErr
+	}
+	return err
+}
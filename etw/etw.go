@@ -0,0 +1,167 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+// Package etw writes the events of the WireGuard provider, which is described by wireguard.man,
+// to Event Tracing for Windows, so that traces taken with wpr can line up the handshakes, roaming,
+// routes, and DNS of tunnels with the events of the network stack. Events cost next to nothing
+// while no session has enabled the provider.
+package etw
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"strings"
+	"sync"
+	"unicode/utf16"
+)
+
+// ProviderGUID is the identifier of the provider, as in wireguard.man.
+const ProviderGUID = "{5b4c2e8a-3f17-4d96-a0c1-7e92d4b6f35c}"
+
+// Keywords, which sessions use to pick the events they want.
+const (
+	KeywordHandshake uint64 = 0x1
+	KeywordEndpoint  uint64 = 0x2
+	KeywordRoute     uint64 = 0x4
+	KeywordDNS       uint64 = 0x8
+	KeywordTunnel    uint64 = 0x10
+)
+
+const levelInformational = 4
+
+type eventDescriptor struct {
+	id      uint16
+	version uint8
+	channel uint8
+	level   uint8
+	opcode  uint8
+	task    uint16
+	keyword uint64
+}
+
+// The descriptors of the events, whose identifiers, tasks, and templates must match wireguard.man.
+var (
+	handshakeInitiationEvent = eventDescriptor{id: 1, level: levelInformational, task: 1, keyword: KeywordHandshake}
+	handshakeCompleteEvent   = eventDescriptor{id: 2, level: levelInformational, task: 1, keyword: KeywordHandshake}
+	endpointRoamedEvent      = eventDescriptor{id: 3, level: levelInformational, task: 2, keyword: KeywordEndpoint}
+	routesAppliedEvent       = eventDescriptor{id: 4, level: levelInformational, task: 3, keyword: KeywordRoute}
+	dnsConfiguredEvent       = eventDescriptor{id: 5, level: levelInformational, task: 4, keyword: KeywordDNS}
+	tunnelStateEvent         = eventDescriptor{id: 6, level: levelInformational, task: 5, keyword: KeywordTunnel}
+)
+
+// payload is the user data of an event, with its fields laid out one after another, as the
+// templates of the manifest expect.
+type payload []byte
+
+func (p payload) appendString(s string) payload {
+	for _, c := range utf16.Encode([]rune(s)) {
+		p = binary.LittleEndian.AppendUint16(p, c)
+	}
+	return binary.LittleEndian.AppendUint16(p, 0)
+}
+
+func (p payload) appendUint32(v uint32) payload {
+	return binary.LittleEndian.AppendUint32(p, v)
+}
+
+var (
+	providerLock sync.RWMutex
+	provider     uint64
+)
+
+// Register registers the provider for the process, after which events are written whenever a
+// session has enabled it. Events before Register, or after Unregister, are dropped.
+func Register() error {
+	providerLock.Lock()
+	defer providerLock.Unlock()
+	if provider != 0 {
+		return nil
+	}
+	return register(&provider)
+}
+
+func Unregister() {
+	providerLock.Lock()
+	defer providerLock.Unlock()
+	if provider == 0 {
+		return
+	}
+	unregister(provider)
+	provider = 0
+}
+
+// Enabled returns whether a session wants any of the events with the given keywords, so that
+// callers may avoid the work of gathering them otherwise.
+func Enabled(keyword uint64) bool {
+	providerLock.RLock()
+	defer providerLock.RUnlock()
+	return provider != 0 && enabled(provider, keyword)
+}
+
+func write(descriptor *eventDescriptor, build func(payload) payload) {
+	providerLock.RLock()
+	defer providerLock.RUnlock()
+	if provider == 0 || !enabled(provider, descriptor.keyword) {
+		return
+	}
+	writeEvent(provider, descriptor, build(nil))
+}
+
+// HandshakeInitiation records that a handshake initiation was sent to a peer, which is named as
+// the data path names it in its log.
+func HandshakeInitiation(tunnel, peer string) {
+	write(&handshakeInitiationEvent, func(p payload) payload {
+		return p.appendString(tunnel).appendString(peer)
+	})
+}
+
+// HandshakeComplete records that a peer has a new session, at the given endpoint.
+func HandshakeComplete(tunnel, peer string, endpoint netip.AddrPort) {
+	write(&handshakeCompleteEvent, func(p payload) payload {
+		return p.appendString(tunnel).appendString(peer).appendString(formatEndpoint(endpoint))
+	})
+}
+
+// EndpointRoamed records that the endpoint of a peer changed, because the peer roamed, or
+// because the endpoint was set again.
+func EndpointRoamed(tunnel, peer string, from, to netip.AddrPort) {
+	write(&endpointRoamedEvent, func(p payload) payload {
+		return p.appendString(tunnel).appendString(peer).appendString(formatEndpoint(from)).appendString(formatEndpoint(to))
+	})
+}
+
+// RoutesApplied records that the routes of an address family were set on the interface of a
+// tunnel.
+func RoutesApplied(tunnel string, family uint16, count int) {
+	write(&routesAppliedEvent, func(p payload) payload {
+		return p.appendString(tunnel).appendUint32(uint32(family)).appendUint32(uint32(count))
+	})
+}
+
+// DNSConfigured records that the DNS servers and search domains of an address family were set on
+// the interface of a tunnel.
+func DNSConfigured(tunnel string, family uint16, servers []netip.Addr, search []string) {
+	write(&dnsConfiguredEvent, func(p payload) payload {
+		addrs := make([]string, len(servers))
+		for i := range servers {
+			addrs[i] = servers[i].String()
+		}
+		return p.appendString(tunnel).appendUint32(uint32(family)).appendString(strings.Join(addrs, ", ")).appendString(strings.Join(search, ", "))
+	})
+}
+
+// TunnelStateChanged records a change in the state of a tunnel service, as the manager sees it.
+func TunnelStateChanged(tunnel, state, err string) {
+	write(&tunnelStateEvent, func(p payload) payload {
+		return p.appendString(tunnel).appendString(state).appendString(err)
+	})
+}
+
+func formatEndpoint(endpoint netip.AddrPort) string {
+	if !endpoint.IsValid() {
+		return ""
+	}
+	return endpoint.String()
+}
@@ -0,0 +1,31 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package etw
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestPayload(t *testing.T) {
+	p := payload(nil).appendString("wg0").appendUint32(23).appendString("")
+	expected := []byte{'w', 0, 'g', 0, '0', 0, 0, 0, 23, 0, 0, 0, 0, 0}
+	if !bytes.Equal(p, expected) {
+		t.Errorf("payload = %v, expected %v", []byte(p), expected)
+	}
+}
+
+func TestProviderGUID(t *testing.T) {
+	guid, err := windows.GUIDFromString(ProviderGUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if guid != providerGUID {
+		t.Errorf("providerGUID = %v, expected %s", providerGUID, ProviderGUID)
+	}
+}
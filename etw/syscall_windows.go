@@ -0,0 +1,84 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package etw
+
+import (
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	modadvapi32         = windows.NewLazySystemDLL("advapi32.dll")
+	procEventRegister   = modadvapi32.NewProc("EventRegister")
+	procEventUnregister = modadvapi32.NewProc("EventUnregister")
+	procEventEnabled    = modadvapi32.NewProc("EventProviderEnabled")
+	procEventWrite      = modadvapi32.NewProc("EventWrite")
+)
+
+// providerGUID is ProviderGUID, as EventRegister takes it.
+var providerGUID = windows.GUID{
+	Data1: 0x5b4c2e8a,
+	Data2: 0x3f17,
+	Data3: 0x4d96,
+	Data4: [8]byte{0xa0, 0xc1, 0x7e, 0x92, 0xd4, 0xb6, 0xf3, 0x5c},
+}
+
+type eventDataDescriptor struct {
+	ptr      uint64
+	size     uint32
+	reserved uint32
+}
+
+// handleArgs passes a REGHANDLE, which is 64 bits wide everywhere, as the arguments that it takes
+// up on the current architecture. It is always the first argument, so it needs no padding on arm.
+func handleArgs(handle uint64, args ...uintptr) []uintptr {
+	if unsafe.Sizeof(uintptr(0)) == 4 {
+		return append([]uintptr{uintptr(handle), uintptr(handle >> 32)}, args...)
+	}
+	return append([]uintptr{uintptr(handle)}, args...)
+}
+
+func register(handle *uint64) error {
+	r0, _, _ := syscall.SyscallN(procEventRegister.Addr(), uintptr(unsafe.Pointer(&providerGUID)), 0, 0, uintptr(unsafe.Pointer(handle)))
+	if r0 != 0 {
+		return syscall.Errno(r0)
+	}
+	return nil
+}
+
+func unregister(handle uint64) {
+	syscall.SyscallN(procEventUnregister.Addr(), handleArgs(handle)...)
+}
+
+func enabled(handle uint64, keyword uint64) bool {
+	var args []uintptr
+	if unsafe.Sizeof(uintptr(0)) == 4 {
+		args = handleArgs(handle, levelInformational, uintptr(keyword), uintptr(keyword>>32))
+		if runtime.GOARCH == "arm" {
+			// The keyword is 64 bits wide, and so starts on an even register, after padding.
+			args = handleArgs(handle, levelInformational, 0, uintptr(keyword), uintptr(keyword>>32))
+		}
+	} else {
+		args = handleArgs(handle, levelInformational, uintptr(keyword))
+	}
+	r0, _, _ := syscall.SyscallN(procEventEnabled.Addr(), args...)
+	return byte(r0) != 0
+}
+
+func writeEvent(handle uint64, descriptor *eventDescriptor, data []byte) {
+	var userData eventDataDescriptor
+	var userDataCount uintptr
+	if len(data) > 0 {
+		userData.ptr = uint64(uintptr(unsafe.Pointer(&data[0])))
+		userData.size = uint32(len(data))
+		userDataCount = 1
+	}
+	syscall.SyscallN(procEventWrite.Addr(), handleArgs(handle, uintptr(unsafe.Pointer(descriptor)), userDataCount, uintptr(unsafe.Pointer(&userData)))...)
+	runtime.KeepAlive(data)
+}
@@ -10,8 +10,8 @@ cd /d %BUILDDIR% || exit /b 1
 for /f "tokens=3" %%a in ('findstr /r "Number.*=.*[0-9.]*" ..\version\version.go') do set WIREGUARD_VERSION=%%a
 set WIREGUARD_VERSION=%WIREGUARD_VERSION:"=%
 
-set WIX_CANDLE_FLAGS=-nologo -dWIREGUARD_VERSION="%WIREGUARD_VERSION%"
-set WIX_LIGHT_FLAGS=-nologo -spdb
+set WIX_CANDLE_FLAGS=-nologo -ext WixUtilExtension -dWIREGUARD_VERSION="%WIREGUARD_VERSION%"
+set WIX_LIGHT_FLAGS=-nologo -ext WixUtilExtension -spdb
 set WIX_LIGHT_FLAGS=%WIX_LIGHT_FLAGS% -sice:ICE39
 set WIX_LIGHT_FLAGS=%WIX_LIGHT_FLAGS% -sice:ICE61
 set WIX_LIGHT_FLAGS=%WIX_LIGHT_FLAGS% -sice:ICE03
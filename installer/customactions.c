@@ -16,6 +16,8 @@
 
 #define MANAGER_SERVICE_NAME TEXT("WireGuardManager")
 #define TUNNEL_SERVICE_PREFIX TEXT("WireGuardTunnel$")
+#define SERVICE_CONTROL_UPGRADE_RESTART 128 /* Mirrors serviceControlUpgradeRestart in tunnel/service.go. */
+#define UPGRADE_RESTART_TIMEOUT 5000
 
 enum log_level { LOG_LEVEL_INFO, LOG_LEVEL_WARN, LOG_LEVEL_ERR, LOG_LEVEL_MSIERR };
 
@@ -122,7 +124,22 @@ out:
 	return ret;
 }
 
-static UINT insert_service_control(MSIHANDLE installer, MSIHANDLE view, const TCHAR *service_name, bool start)
+static bool is_tunnel_service(const TCHAR *service_name)
+{
+	return !_tcsnicmp(service_name, TUNNEL_SERVICE_PREFIX, _countof(TUNNEL_SERVICE_PREFIX) - 1);
+}
+
+static bool is_running(const SERVICE_STATUS_PROCESS *status)
+{
+	return status->dwCurrentState != SERVICE_STOPPED && status->dwCurrentState != SERVICE_STOP_PENDING;
+}
+
+/*
+ * Running tunnel services are handed off rather than stopped on upgrade: they keep forwarding
+ * traffic from their renamed executable while the new files are installed, and are then
+ * restarted at once by RestartWireGuardTunnels.
+ */
+static UINT insert_service_control(MSIHANDLE installer, MSIHANDLE view, const TCHAR *service_name, bool start, bool hand_off)
 {
 	static unsigned int index = 0;
 	UINT ret;
@@ -137,17 +154,20 @@ static UINT insert_service_control(MSIHANDLE installer, MSIHANDLE view, const TC
 
 	MsiRecordSetString (record, 1/*ServiceControl*/, row_identifier);
 	MsiRecordSetString (record, 2/*Name          */, service_name);
-	MsiRecordSetInteger(record, 3/*Event         */, msidbServiceControlEventStop | msidbServiceControlEventUninstallStop | msidbServiceControlEventUninstallDelete);
+	MsiRecordSetInteger(record, 3/*Event         */, (hand_off ? 0 : msidbServiceControlEventStop) | msidbServiceControlEventUninstallStop | msidbServiceControlEventUninstallDelete);
 	MsiRecordSetString (record, 4/*Component_    */, TEXT("WireGuardExecutable"));
 	MsiRecordSetInteger(record, 5/*Wait          */, 1); /* Waits 30 seconds. */
-	log_messagef(installer, LOG_LEVEL_INFO, TEXT("Scheduling stop on upgrade or removal on uninstall of service %1"), service_name);
+	if (hand_off)
+		log_messagef(installer, LOG_LEVEL_INFO, TEXT("Scheduling hand-off on upgrade or removal on uninstall of service %1"), service_name);
+	else
+		log_messagef(installer, LOG_LEVEL_INFO, TEXT("Scheduling stop on upgrade or removal on uninstall of service %1"), service_name);
 	ret = MsiViewExecute(view, record);
 	if (ret != ERROR_SUCCESS) {
 		log_errorf(installer, LOG_LEVEL_ERR, ret, TEXT("MsiViewExecute failed for service %1"), service_name);
 		goto out;
 	}
 
-	if (!start)
+	if (!start || hand_off)
 		goto out;
 
 	ret = ERROR_INSTALL_FAILURE;
@@ -220,12 +240,11 @@ __declspec(dllexport) UINT __stdcall EvaluateWireGuardServices(MSIHANDLE install
 		}
 
 		for (DWORD i = 0; i < service_status_count; ++i) {
-			if (_tcsicmp(service_status[i].lpServiceName, MANAGER_SERVICE_NAME) &&
-			    _tcsnicmp(service_status[i].lpServiceName, TUNNEL_SERVICE_PREFIX, _countof(TUNNEL_SERVICE_PREFIX) - 1))
+			bool is_tunnel = is_tunnel_service(service_status[i].lpServiceName);
+			bool running = is_running(&service_status[i].ServiceStatusProcess);
+			if (_tcsicmp(service_status[i].lpServiceName, MANAGER_SERVICE_NAME) && !is_tunnel)
 				continue;
-			insert_service_control(installer, view, service_status[i].lpServiceName,
-					       service_status[i].ServiceStatusProcess.dwCurrentState != SERVICE_STOPPED &&
-					       service_status[i].ServiceStatusProcess.dwCurrentState != SERVICE_STOP_PENDING);
+			insert_service_control(installer, view, service_status[i].lpServiceName, running, is_tunnel && running);
 		}
 	}
 	ret = ERROR_SUCCESS;
@@ -295,6 +314,21 @@ __declspec(dllexport) UINT __stdcall EvaluateWireGuardComponents(MSIHANDLE insta
 			log_errorf(installer, LOG_LEVEL_ERR, ret, TEXT("MsiSetProperty(\"KillWireGuardProcesses\") failed"));
 			goto out;
 		}
+		ret = MsiSetProperty(installer, TEXT("RollbackHandOffWireGuardTunnels"), path);
+		if (ret != ERROR_SUCCESS) {
+			log_errorf(installer, LOG_LEVEL_ERR, ret, TEXT("MsiSetProperty(\"RollbackHandOffWireGuardTunnels\") failed"));
+			goto out;
+		}
+		ret = MsiSetProperty(installer, TEXT("HandOffWireGuardTunnels"), path);
+		if (ret != ERROR_SUCCESS) {
+			log_errorf(installer, LOG_LEVEL_ERR, ret, TEXT("MsiSetProperty(\"HandOffWireGuardTunnels\") failed"));
+			goto out;
+		}
+		ret = MsiSetProperty(installer, TEXT("RestartWireGuardTunnels"), path);
+		if (ret != ERROR_SUCCESS) {
+			log_errorf(installer, LOG_LEVEL_ERR, ret, TEXT("MsiSetProperty(\"RestartWireGuardTunnels\") failed"));
+			goto out;
+		}
 	} else if (component_action >= INSTALLSTATE_REMOVED) {
 		/* WireGuardExecutable component shall be uninstalled. */
 		ret = MsiSetProperty(installer, TEXT("KillWireGuardProcesses"), path);
@@ -321,6 +355,63 @@ out:
 	return ret == ERROR_SUCCESS ? ret : ERROR_INSTALL_FAILURE;
 }
 
+typedef void (*running_tunnel_service_fn)(MSIHANDLE installer, SC_HANDLE scm, const ENUM_SERVICE_STATUS_PROCESS *service, void *ctx);
+
+static bool for_each_running_tunnel_service(MSIHANDLE installer, running_tunnel_service_fn fn, void *ctx)
+{
+	SC_HANDLE scm;
+	ENUM_SERVICE_STATUS_PROCESS *service_status;
+	DWORD service_status_resume = 0;
+	bool ret = true;
+	enum { SERVICE_STATUS_PROCESS_SIZE = 0x10000 };
+
+	scm = OpenSCManager(NULL, SERVICES_ACTIVE_DATABASE, SC_MANAGER_CONNECT | SC_MANAGER_ENUMERATE_SERVICE);
+	if (!scm) {
+		log_errorf(installer, LOG_LEVEL_WARN, GetLastError(), TEXT("OpenSCManager failed"));
+		return false;
+	}
+	service_status = LocalAlloc(LMEM_FIXED, SERVICE_STATUS_PROCESS_SIZE);
+	if (!service_status) {
+		log_errorf(installer, LOG_LEVEL_WARN, GetLastError(), TEXT("LocalAlloc failed"));
+		CloseServiceHandle(scm);
+		return false;
+	}
+	for (bool more_services = true; more_services;) {
+		DWORD service_status_size = 0, service_status_count = 0;
+		if (EnumServicesStatusEx(scm, SC_ENUM_PROCESS_INFO, SERVICE_WIN32, SERVICE_ACTIVE, (LPBYTE)service_status,
+					 SERVICE_STATUS_PROCESS_SIZE, &service_status_size, &service_status_count,
+					 &service_status_resume, NULL))
+			more_services = false;
+		else if (GetLastError() != ERROR_MORE_DATA) {
+			log_errorf(installer, LOG_LEVEL_WARN, GetLastError(), TEXT("EnumServicesStatusEx failed"));
+			ret = false;
+			break;
+		}
+		for (DWORD i = 0; i < service_status_count; ++i) {
+			if (is_tunnel_service(service_status[i].lpServiceName) && is_running(&service_status[i].ServiceStatusProcess))
+				fn(installer, scm, &service_status[i], ctx);
+		}
+	}
+	LocalFree(service_status);
+	CloseServiceHandle(scm);
+	return ret;
+}
+
+struct process_ids { DWORD ids[0x400]; size_t len; };
+
+static void collect_tunnel_process_id(MSIHANDLE installer, SC_HANDLE scm, const ENUM_SERVICE_STATUS_PROCESS *service, void *ctx)
+{
+	struct process_ids *process_ids = ctx;
+
+	if (process_ids->len < _countof(process_ids->ids))
+		process_ids->ids[process_ids->len++] = service->ServiceStatusProcess.dwProcessId;
+}
+
+static void count_tunnel_service(MSIHANDLE installer, SC_HANDLE scm, const ENUM_SERVICE_STATUS_PROCESS *service, void *ctx)
+{
+	++*(size_t *)ctx;
+}
+
 struct file_id { DWORD volume, index_high, index_low; };
 
 static bool calculate_file_id(const TCHAR *path, struct file_id *id)
@@ -350,6 +441,7 @@ __declspec(dllexport) UINT __stdcall KillWireGuardProcesses(MSIHANDLE installer)
 	DWORD process_path_len = _countof(process_path);
 	struct file_id file_ids[3], file_id;
 	size_t file_ids_len = 0;
+	struct process_ids tunnel_process_ids = { .len = 0 };
 	bool is_com_initialized = SUCCEEDED(CoInitialize(NULL));
 	LSTATUS mret;
 
@@ -370,6 +462,9 @@ __declspec(dllexport) UINT __stdcall KillWireGuardProcesses(MSIHANDLE installer)
 	if (!file_ids_len)
 		goto out;
 
+	/* Running tunnel services are handed off rather than killed; see insert_service_control. */
+	for_each_running_tunnel_service(installer, collect_tunnel_process_id, &tunnel_process_ids);
+
 	snapshot = CreateToolhelp32Snapshot(TH32CS_SNAPPROCESS, 0);
 	if (snapshot == INVALID_HANDLE_VALUE)
 		goto out;
@@ -377,6 +472,17 @@ __declspec(dllexport) UINT __stdcall KillWireGuardProcesses(MSIHANDLE installer)
 	for (bool ret = Process32First(snapshot, &entry); ret; ret = Process32Next(snapshot, &entry)) {
 		if (_tcsicmp(entry.szExeFile, TEXT("wireguard.exe")) && _tcsicmp(entry.szExeFile, TEXT("wg.exe")))
 			continue;
+		bool is_tunnel_process = false;
+		for (size_t i = 0; i < tunnel_process_ids.len; ++i) {
+			if (entry.th32ProcessID == tunnel_process_ids.ids[i]) {
+				is_tunnel_process = true;
+				break;
+			}
+		}
+		if (is_tunnel_process) {
+			log_messagef(installer, LOG_LEVEL_INFO, TEXT("Handing off tunnel process (pid %1!d!)"), entry.th32ProcessID);
+			continue;
+		}
 		process = OpenProcess(PROCESS_TERMINATE | PROCESS_QUERY_LIMITED_INFORMATION, false, entry.th32ProcessID);
 		if (!process)
 			continue;
@@ -409,6 +515,204 @@ out:
 	return ERROR_SUCCESS;
 }
 
+__declspec(dllexport) UINT __stdcall HandOffWireGuardTunnels(MSIHANDLE installer)
+{
+	LSTATUS ret;
+	TCHAR path[MAX_PATH], executable[MAX_PATH], old_executable[MAX_PATH];
+	DWORD path_len = _countof(path);
+	size_t running_tunnels = 0;
+	bool is_com_initialized = SUCCEEDED(CoInitialize(NULL));
+
+	ret = MsiGetProperty(installer, TEXT("CustomActionData"), path, &path_len);
+	if (ret != ERROR_SUCCESS) {
+		log_errorf(installer, LOG_LEVEL_WARN, ret, TEXT("MsiGetProperty(\"CustomActionData\") failed"));
+		goto out;
+	}
+	if (!path[0] || !PathCombine(executable, path, TEXT("wireguard.exe")) || !PathCombine(old_executable, path, TEXT("wireguard.exe.old")))
+		goto out;
+	if (!for_each_running_tunnel_service(installer, count_tunnel_service, &running_tunnels) || !running_tunnels)
+		goto out;
+
+	/* A running image may be renamed but not replaced, so move it out of the way of InstallFiles. */
+	DeleteFile(old_executable);
+	if (!MoveFileEx(executable, old_executable, MOVEFILE_REPLACE_EXISTING)) {
+		log_errorf(installer, LOG_LEVEL_WARN, GetLastError(), TEXT("MoveFileEx(\"%1\", \"%2\") failed, so tunnels will be upgraded on reboot"), executable, old_executable);
+		goto out;
+	}
+	log_messagef(installer, LOG_LEVEL_INFO, TEXT("Moved \"%1\" to \"%2\" for %3!d! running tunnels"), executable, old_executable, (int)running_tunnels);
+	if (!MoveFileEx(old_executable, NULL, MOVEFILE_DELAY_UNTIL_REBOOT))
+		log_errorf(installer, LOG_LEVEL_WARN, GetLastError(), TEXT("MoveFileEx(\"%1\", NULL) failed"), old_executable);
+
+out:
+	if (is_com_initialized)
+		CoUninitialize();
+	return ERROR_SUCCESS;
+}
+
+/* Removes a delete of path scheduled by MoveFileEx(path, NULL, MOVEFILE_DELAY_UNTIL_REBOOT). */
+static void cancel_pending_delete(MSIHANDLE installer, const TCHAR *path)
+{
+	static const TCHAR value_name[] = TEXT("PendingFileRenameOperations");
+	TCHAR nt_path[MAX_PATH + 4], *operations = NULL, *from, *next, *to, *end, *out;
+	DWORD type, size = 0;
+	bool removed = false;
+	LSTATUS ret;
+	HKEY key;
+
+	if (_tcslen(path) >= MAX_PATH)
+		return;
+	_tcscpy(nt_path, TEXT("\\??\\"));
+	_tcscat(nt_path, path);
+	ret = RegOpenKeyEx(HKEY_LOCAL_MACHINE, TEXT("SYSTEM\\CurrentControlSet\\Control\\Session Manager"), 0, KEY_QUERY_VALUE | KEY_SET_VALUE, &key);
+	if (ret != ERROR_SUCCESS) {
+		log_errorf(installer, LOG_LEVEL_WARN, ret, TEXT("RegOpenKeyEx(\"Session Manager\") failed"));
+		return;
+	}
+	ret = RegQueryValueEx(key, value_name, NULL, &type, NULL, &size);
+	if (ret != ERROR_SUCCESS || type != REG_MULTI_SZ || !size)
+		goto out;
+	operations = LocalAlloc(LMEM_FIXED, size + 2 * sizeof(TCHAR));
+	if (!operations) {
+		log_errorf(installer, LOG_LEVEL_WARN, GetLastError(), TEXT("LocalAlloc failed"));
+		goto out;
+	}
+	ret = RegQueryValueEx(key, value_name, NULL, &type, (LPBYTE)operations, &size);
+	if (ret != ERROR_SUCCESS) {
+		log_errorf(installer, LOG_LEVEL_WARN, ret, TEXT("RegQueryValueEx(\"%1\") failed"), value_name);
+		goto out;
+	}
+	end = operations + size / sizeof(TCHAR);
+	end[0] = end[1] = TEXT('\0');
+
+	/* The value is a list of pairs of source and destination, with an empty destination meaning delete. */
+	for (from = out = operations; from < end && *from; from = next) {
+		to = from + _tcslen(from) + 1;
+		next = to + _tcslen(to) + 1;
+		if (!*to && !_tcsicmp(from, nt_path)) {
+			removed = true;
+			continue;
+		}
+		memmove(out, from, (next - from) * sizeof(TCHAR));
+		out += next - from;
+	}
+	if (!removed)
+		goto out;
+	if (out == operations)
+		ret = RegDeleteValue(key, value_name);
+	else {
+		*out++ = TEXT('\0');
+		ret = RegSetValueEx(key, value_name, 0, REG_MULTI_SZ, (LPBYTE)operations, (DWORD)((out - operations) * sizeof(TCHAR)));
+	}
+	if (ret != ERROR_SUCCESS)
+		log_errorf(installer, LOG_LEVEL_WARN, ret, TEXT("Unable to cancel deletion of \"%1\" on reboot"), path);
+
+out:
+	if (operations)
+		LocalFree(operations);
+	RegCloseKey(key);
+}
+
+__declspec(dllexport) UINT __stdcall RollbackHandOffWireGuardTunnels(MSIHANDLE installer)
+{
+	LSTATUS ret;
+	TCHAR path[MAX_PATH], executable[MAX_PATH], old_executable[MAX_PATH];
+	DWORD path_len = _countof(path);
+	bool is_com_initialized = SUCCEEDED(CoInitialize(NULL));
+
+	ret = MsiGetProperty(installer, TEXT("CustomActionData"), path, &path_len);
+	if (ret != ERROR_SUCCESS) {
+		log_errorf(installer, LOG_LEVEL_WARN, ret, TEXT("MsiGetProperty(\"CustomActionData\") failed"));
+		goto out;
+	}
+	if (!path[0] || !PathCombine(executable, path, TEXT("wireguard.exe")) || !PathCombine(old_executable, path, TEXT("wireguard.exe.old")))
+		goto out;
+
+	/* Rolling back InstallFiles removes the new executable, which MSI never knew had replaced the old one. */
+	if (GetFileAttributes(old_executable) == INVALID_FILE_ATTRIBUTES || GetFileAttributes(executable) != INVALID_FILE_ATTRIBUTES)
+		goto out;
+	cancel_pending_delete(installer, old_executable);
+	if (MoveFileEx(old_executable, executable, MOVEFILE_REPLACE_EXISTING)) {
+		log_messagef(installer, LOG_LEVEL_INFO, TEXT("Moved \"%1\" back to \"%2\""), old_executable, executable);
+		goto out;
+	}
+	log_errorf(installer, LOG_LEVEL_WARN, GetLastError(), TEXT("MoveFileEx(\"%1\", \"%2\") failed, so it will be moved back on reboot"), old_executable, executable);
+	if (!MoveFileEx(old_executable, executable, MOVEFILE_REPLACE_EXISTING | MOVEFILE_DELAY_UNTIL_REBOOT))
+		log_errorf(installer, LOG_LEVEL_ERR, GetLastError(), TEXT("MoveFileEx(\"%1\", \"%2\") on reboot failed"), old_executable, executable);
+
+out:
+	if (is_com_initialized)
+		CoUninitialize();
+	return ERROR_SUCCESS;
+}
+
+static void restart_tunnel_service(MSIHANDLE installer, SC_HANDLE scm, const ENUM_SERVICE_STATUS_PROCESS *service, void *ctx)
+{
+	SC_HANDLE handle;
+	SERVICE_STATUS status;
+	SERVICE_STATUS_PROCESS status_process;
+	DWORD status_size;
+	ULONGLONG start = GetTickCount64();
+
+	handle = OpenService(scm, service->lpServiceName, SERVICE_USER_DEFINED_CONTROL | SERVICE_QUERY_STATUS | SERVICE_START);
+	if (!handle) {
+		log_errorf(installer, LOG_LEVEL_WARN, GetLastError(), TEXT("OpenService(\"%1\") failed"), service->lpServiceName);
+		return;
+	}
+	if (!ControlService(handle, SERVICE_CONTROL_UPGRADE_RESTART, &status)) {
+		log_errorf(installer, LOG_LEVEL_WARN, GetLastError(), TEXT("ControlService(\"%1\") failed"), service->lpServiceName);
+		goto out;
+	}
+	for (;;) {
+		if (!QueryServiceStatusEx(handle, SC_STATUS_PROCESS_INFO, (LPBYTE)&status_process, sizeof(status_process), &status_size)) {
+			log_errorf(installer, LOG_LEVEL_WARN, GetLastError(), TEXT("QueryServiceStatusEx(\"%1\") failed"), service->lpServiceName);
+			goto out;
+		}
+		if (status_process.dwCurrentState == SERVICE_STOPPED)
+			break;
+		if (GetTickCount64() - start > UPGRADE_RESTART_TIMEOUT) {
+			log_messagef(installer, LOG_LEVEL_WARN, TEXT("Service %1 did not stop in time, so it will be upgraded on reboot"), service->lpServiceName);
+			goto out;
+		}
+		Sleep(10);
+	}
+	if (!StartService(handle, 0, NULL)) {
+		log_errorf(installer, LOG_LEVEL_WARN, GetLastError(), TEXT("StartService(\"%1\") failed"), service->lpServiceName);
+		goto out;
+	}
+	log_messagef(installer, LOG_LEVEL_INFO, TEXT("Restarted service %1 in %2!d! ms"), service->lpServiceName, (int)(GetTickCount64() - start));
+
+out:
+	CloseServiceHandle(handle);
+}
+
+__declspec(dllexport) UINT __stdcall RestartWireGuardTunnels(MSIHANDLE installer)
+{
+	LSTATUS ret;
+	TCHAR path[MAX_PATH], old_executable[MAX_PATH];
+	DWORD path_len = _countof(path);
+	bool is_com_initialized = SUCCEEDED(CoInitialize(NULL));
+
+	ret = MsiGetProperty(installer, TEXT("CustomActionData"), path, &path_len);
+	if (ret != ERROR_SUCCESS) {
+		log_errorf(installer, LOG_LEVEL_WARN, ret, TEXT("MsiGetProperty(\"CustomActionData\") failed"));
+		goto out;
+	}
+	if (!path[0] || !PathCombine(old_executable, path, TEXT("wireguard.exe.old")))
+		goto out;
+	if (GetFileAttributes(old_executable) == INVALID_FILE_ATTRIBUTES)
+		goto out; /* Nothing was handed off. */
+
+	log_messagef(installer, LOG_LEVEL_INFO, TEXT("Restarting handed off tunnels"));
+	for_each_running_tunnel_service(installer, restart_tunnel_service, NULL);
+	if (DeleteFile(old_executable))
+		log_messagef(installer, LOG_LEVEL_INFO, TEXT("Deleted \"%1\""), old_executable);
+
+out:
+	if (is_com_initialized)
+		CoUninitialize();
+	return ERROR_SUCCESS;
+}
+
 static bool remove_directory_recursive(MSIHANDLE installer, TCHAR path[MAX_PATH], unsigned int max_depth)
 {
 	HANDLE find_handle;
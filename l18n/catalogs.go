@@ -0,0 +1,216 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package l18n
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+)
+
+// External catalogs let translations ship apart from releases. They are read from a locales
+// directory next to the executable, which only administrators can write to, laid out like the
+// locales directory of the source tree: a messages.gotext.json file in a directory per language.
+// A message found in an external catalog takes precedence over the built-in one, so that a
+// catalog may add a language or just correct a few messages of one.
+
+type gotextPlaceholder struct {
+	ID     string `json:"id"`
+	String string `json:"string"`
+}
+
+type gotextCase struct {
+	Msg string `json:"msg"`
+}
+
+type gotextSelect struct {
+	Feature string                `json:"feature"`
+	Arg     string                `json:"arg"`
+	Cases   map[string]gotextCase `json:"cases"`
+}
+
+type gotextMessage struct {
+	Message      string              `json:"message"`
+	Translation  json.RawMessage     `json:"translation"`
+	Placeholders []gotextPlaceholder `json:"placeholders"`
+}
+
+type gotextCatalog struct {
+	Language string          `json:"language"`
+	Messages []gotextMessage `json:"messages"`
+}
+
+var (
+	externalCatalog     *catalog.Builder
+	externalCatalogKeys map[language.Tag]map[string]bool
+	externalCatalogOnce sync.Once
+)
+
+var (
+	placeholderRegex = regexp.MustCompile(`\{(\w+)\}`)
+	argIndexRegex    = regexp.MustCompile(`^%([^\[]*)\[(\d+)\](.*)$`)
+)
+
+// key returns the format string that the message is looked up by, which is the message with its
+// placeholders replaced by their verbs, written without argument indices where the arguments
+// come in order, as in the source.
+func (m *gotextMessage) key() string {
+	n := 0
+	return placeholderRegex.ReplaceAllStringFunc(m.Message, func(s string) string {
+		verb, ok := m.placeholder(s[1 : len(s)-1])
+		if !ok {
+			return s
+		}
+		n++
+		if parts := argIndexRegex.FindStringSubmatch(verb); parts != nil && parts[2] == strconv.Itoa(n) {
+			return "%" + parts[1] + parts[3]
+		}
+		return verb
+	})
+}
+
+func (m *gotextMessage) placeholder(id string) (string, bool) {
+	for i := range m.Placeholders {
+		if m.Placeholders[i].ID == id {
+			return m.Placeholders[i].String, true
+		}
+	}
+	return "", false
+}
+
+func (m *gotextMessage) expand(s string) string {
+	return placeholderRegex.ReplaceAllStringFunc(s, func(s string) string {
+		if verb, ok := m.placeholder(s[1 : len(s)-1]); ok {
+			return verb
+		}
+		return s
+	})
+}
+
+// translation returns the translated message as the catalog takes it, or nil if there is none.
+func (m *gotextMessage) translation() catalog.Message {
+	var s string
+	if json.Unmarshal(m.Translation, &s) == nil {
+		if len(s) == 0 {
+			return nil
+		}
+		return catalog.String(m.expand(s))
+	}
+	var translation struct {
+		Select gotextSelect `json:"select"`
+	}
+	if json.Unmarshal(m.Translation, &translation) != nil || translation.Select.Feature != "plural" || len(translation.Select.Cases) == 0 {
+		return nil
+	}
+	verb, ok := m.placeholder(translation.Select.Arg)
+	if !ok {
+		return nil
+	}
+	arg := 1
+	if parts := argIndexRegex.FindStringSubmatch(verb); parts != nil {
+		arg, _ = strconv.Atoi(parts[2])
+	}
+	// Cases are tried in order, so exact numbers come first and "other" last.
+	selectors := make([]string, 0, len(translation.Select.Cases))
+	for selector := range translation.Select.Cases {
+		if len(selector) > 0 && (selector[0] == '=' || selector[0] == '<') {
+			selectors = append(selectors, selector)
+		}
+	}
+	sort.Strings(selectors)
+	for _, selector := range [...]string{"zero", "one", "two", "few", "many", "other"} {
+		if _, ok := translation.Select.Cases[selector]; ok {
+			selectors = append(selectors, selector)
+		}
+	}
+	cases := make([]any, 0, len(selectors)*2)
+	for _, selector := range selectors {
+		cases = append(cases, selector, m.expand(translation.Select.Cases[selector].Msg))
+	}
+	return plural.Selectf(arg, verb, cases...)
+}
+
+func localesDirectory() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(exe), "locales"), nil
+}
+
+func loadExternalCatalogs() {
+	externalCatalog = catalog.NewBuilder(catalog.Fallback(language.English))
+	externalCatalogKeys = make(map[language.Tag]map[string]bool)
+	dir, err := localesDirectory()
+	if err != nil {
+		return
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*", "messages.gotext.json"))
+	if err != nil {
+		return
+	}
+	for _, path := range paths {
+		bytes, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var file gotextCatalog
+		if json.Unmarshal(bytes, &file) != nil {
+			continue
+		}
+		tag, err := language.Parse(file.Language)
+		if err != nil {
+			continue
+		}
+		keys := externalCatalogKeys[tag]
+		if keys == nil {
+			keys = make(map[string]bool)
+		}
+		for i := range file.Messages {
+			translation := file.Messages[i].translation()
+			if translation == nil {
+				continue
+			}
+			key := file.Messages[i].key()
+			if externalCatalog.Set(tag, key, translation) == nil {
+				keys[key] = true
+			}
+		}
+		if len(keys) > 0 {
+			externalCatalogKeys[tag] = keys
+		}
+	}
+}
+
+// externalCatalogPrinter returns a printer of the external catalogs for the language, and the messages
+// that they have for it, or nil if they have none.
+func externalCatalogPrinter(tag language.Tag) (*message.Printer, map[string]bool) {
+	externalCatalogOnce.Do(loadExternalCatalogs)
+	keys := externalCatalogKeys[tag]
+	if keys == nil {
+		return nil, nil
+	}
+	return message.NewPrinter(tag, message.Catalog(externalCatalog)), keys
+}
+
+// externalLanguages returns the languages that the external catalogs have messages for.
+func externalLanguages() []language.Tag {
+	externalCatalogOnce.Do(loadExternalCatalogs)
+	tags := make([]language.Tag, 0, len(externalCatalogKeys))
+	for tag := range externalCatalogKeys {
+		tags = append(tags, tag)
+	}
+	return tags
+}
@@ -9,32 +9,111 @@ import (
 	"sync"
 
 	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
 )
 
 var (
-	printer     *message.Printer
-	printerLock sync.Mutex
+	printer         *message.Printer
+	externalPrinter *message.Printer
+	externalKeys    map[string]bool
+	printerLock     sync.Mutex
 )
 
-// prn returns the printer for user preferred UI language.
-func prn() *message.Printer {
-	if printer != nil {
-		return printer
-	}
+// printers returns the printer for the language of the UI, and, if external catalogs have
+// messages for it, their printer and which messages they have.
+func printers() (*message.Printer, *message.Printer, map[string]bool) {
 	printerLock.Lock()
-	if printer != nil {
+	defer printerLock.Unlock()
+	if printer == nil {
+		tag := lang()
+		printer = message.NewPrinter(tag)
+		// The tag may carry the region of the user, whereas catalogs are by bare language.
+		languages := Languages()
+		_, i, _ := language.NewMatcher(languages).Match(tag)
+		externalPrinter, externalKeys = externalCatalogPrinter(languages[i])
+	}
+	return printer, externalPrinter, externalKeys
+}
+
+const (
+	languageRegKey   = `Software\WireGuard`
+	languageRegValue = "Language"
+)
+
+// LanguageOverride returns the language that the user chose instead of that of Windows, or an
+// empty string if they did not.
+func LanguageOverride() string {
+	key, err := registry.OpenKey(registry.CURRENT_USER, languageRegKey, registry.QUERY_VALUE)
+	if err != nil {
+		return ""
+	}
+	defer key.Close()
+	value, _, err := key.GetStringValue(languageRegValue)
+	if err != nil {
+		return ""
+	}
+	return value
+}
+
+// SetLanguageOverride makes the UI use the given language instead of that of Windows, or, if
+// empty, stops doing so. Strings formatted from then on are in the new language, so whatever
+// shows text has to be made anew to switch over.
+func SetLanguageOverride(name string) error {
+	defer func() {
+		printerLock.Lock()
+		printer = nil
 		printerLock.Unlock()
-		return printer
+	}()
+	if len(name) == 0 {
+		key, err := registry.OpenKey(registry.CURRENT_USER, languageRegKey, registry.SET_VALUE)
+		if err != nil {
+			return nil
+		}
+		defer key.Close()
+		err = key.DeleteValue(languageRegValue)
+		if err == registry.ErrNotExist {
+			return nil
+		}
+		return err
+	}
+	key, _, err := registry.CreateKey(registry.CURRENT_USER, languageRegKey, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	return key.SetStringValue(languageRegValue, name)
+}
+
+// Languages returns the languages that there are translations for, built in or in external
+// catalogs, with the fallback language first.
+func Languages() []language.Tag {
+	tags := message.DefaultCatalog.Languages()
+	for _, tag := range externalLanguages() {
+		known := false
+		for _, t := range tags {
+			if t == tag {
+				known = true
+				break
+			}
+		}
+		if !known {
+			tags = append(tags, tag)
+		}
 	}
-	printer = message.NewPrinter(lang())
-	printerLock.Unlock()
-	return printer
+	return tags
 }
 
-// lang returns the user preferred UI language we have most confident translation in the default catalog available.
+// lang returns the language the user chose, or else the user preferred UI language we have most
+// confident translation in the default or external catalogs available.
 func lang() (tag language.Tag) {
+	matcher := language.NewMatcher(Languages())
+	if override := LanguageOverride(); len(override) > 0 {
+		if t, _, c := matcher.Match(language.Make(override)); c != language.No {
+			return t
+		}
+	}
 	tag = language.English
 	confidence := language.No
 	languages, err := windows.GetUserPreferredUILanguages(windows.MUI_LANGUAGE_NAME)
@@ -42,7 +121,7 @@ func lang() (tag language.Tag) {
 		return
 	}
 	for i := range languages {
-		t, _, c := message.DefaultCatalog.Matcher().Match(message.MatchLanguage(languages[i]))
+		t, _, c := matcher.Match(message.MatchLanguage(languages[i]))
 		if c > confidence {
 			tag = t
 			confidence = c
@@ -53,7 +132,11 @@ func lang() (tag language.Tag) {
 
 // Sprintf is like fmt.Sprintf, but using language-specific formatting.
 func Sprintf(key message.Reference, a ...any) string {
-	return prn().Sprintf(key, a...)
+	p, external, externalKeys := printers()
+	if s, ok := key.(string); ok && externalKeys[s] {
+		return external.Sprintf(key, a...)
+	}
+	return p.Sprintf(key, a...)
 }
 
 // EnumerationSeparator returns enumeration separator. For English and western languages,
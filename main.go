@@ -6,8 +6,6 @@
 package main
 
 import (
-	"debug/pe"
-	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -27,6 +25,7 @@ import (
 	"golang.zx2c4.com/wireguard/windows/tunnel"
 	"golang.zx2c4.com/wireguard/windows/ui"
 	"golang.zx2c4.com/wireguard/windows/updater"
+	"golang.zx2c4.com/wireguard/windows/version"
 )
 
 const (
@@ -78,9 +77,25 @@ func usage() {
 		"/managerservice",
 		"/tunnelservice CONFIG_PATH",
 		"/ui CMD_READ_HANDLE CMD_WRITE_HANDLE CMD_EVENT_HANDLE LOG_MAPPING_HANDLE",
-		"/dumplog [/tail]",
+		"/dumplog [/follow] [/level debug|info|warning|error] [/since DURATION] [/tunnel TUNNEL_NAME] [/contains TEXT]",
+		"/diagnostics OUTPUT.zip",
+		"/list [/json]",
+		"/status [TUNNEL_NAME] [/json]",
+		"/up TUNNEL_NAME",
+		"/down TUNNEL_NAME",
+		"/syncconf TUNNEL_NAME CONFIG_PATH",
+		"/validate CONFIG_PATH [/json]",
+		"/importfromurl URL [/sha256 DIGEST] [/json]",
+		"/shutdownmanager [/stoptunnels | /keeptunnels]",
+		"/genkey",
+		"/genpsk",
+		"/pubkey",
 		"/update",
+		"/rollback",
 		"/removedriver",
+		"/cleanupadapters [/dryrun]",
+		"/activatetunnel TUNNEL_NAME",
+		"/importtunnel",
 	}
 	
 	// Pre-allocate capacity for better performance
@@ -94,26 +109,32 @@ func usage() {
 	os.Exit(1)
 }
 
-func checkForWow64() {
-	var b bool
-	var err error
-	var processMachine, nativeMachine uint16
-	err = windows.IsWow64Process2(windows.CurrentProcess(), &processMachine, &nativeMachine)
-	if err == nil {
-		b = processMachine != pe.IMAGE_FILE_MACHINE_UNKNOWN
-	} else if !errors.Is(err, windows.ERROR_PROC_NOT_FOUND) {
-		fatalf("Konnte nicht feststellen, ob der Prozess unter WOW64 läuft: %v", err)
+// IDYES, which x/sys/windows lacks.
+const idYes = 6
+
+func checkPlatform() {
+	problem, err := version.CheckPlatform()
+	if err != nil {
+		fatalf("Konnte die Plattform nicht überprüfen: %v", err)
+	}
+	if problem == nil {
 		return
-	} else {
-		var wow64Err error
-		wow64Err = windows.IsWow64Process(windows.CurrentProcess(), &b)
-		if wow64Err != nil {
-			fatalf("Konnte nicht feststellen, ob der Prozess unter WOW64 läuft: %v", wow64Err)
+	}
+	if log.Writer() != io.Discard {
+		if problem.DownloadURL != "" {
+			log.Fatalf("%s%s\n%s\n%s", l18n.Sprintf("Error: "), problem.Description, problem.Remedy, problem.DownloadURL)
 		}
+		log.Fatalf("%s%s\n%s", l18n.Sprintf("Error: "), problem.Description, problem.Remedy)
 	}
-	if b {
-		fatalf("Sie müssen die native Version von WireGuard auf diesem Computer verwenden.")
+	if problem.DownloadURL == "" {
+		fatal(problem.Description + "\n\n" + problem.Remedy)
 	}
+	text := problem.Description + "\n\n" + problem.Remedy + "\n\n" + l18n.Sprintf("Möchten Sie die passende Version jetzt herunterladen?")
+	ret, _ := windows.MessageBox(0, windows.StringToUTF16Ptr(text), windows.StringToUTF16Ptr(l18n.Sprintf("Error")), windows.MB_ICONERROR|windows.MB_YESNO)
+	if ret == idYes {
+		windows.ShellExecute(0, nil, windows.StringToUTF16Ptr(problem.DownloadURL), nil, nil, windows.SW_SHOWNORMAL)
+	}
+	os.Exit(1)
 }
 
 func checkForAdminGroup() {
@@ -149,6 +170,16 @@ func execElevatedManagerServiceInstaller() error {
 	return windows.ERROR_UNHANDLED_EXCEPTION // Not reached
 }
 
+// runJumpListTask passes a task of the jump list to the running UI, or starts the UI if it is not
+// running, in which case the task is dropped, as there is no manager to carry it out yet.
+func runJumpListTask(task, tunnelName string) error {
+	if ui.RunJumpListTask(task, tunnelName) {
+		return nil
+	}
+	checkForAdminGroup()
+	return execElevatedManagerServiceInstaller()
+}
+
 func pipeFromHandleArgument(handleStr string) (*os.File, error) {
 	handleInt, err := strconv.ParseUint(handleStr, 10, 64)
 	if err != nil {
@@ -173,7 +204,7 @@ func main() {
 	if err := setLogFile(); err != nil {
 		panic(fmt.Sprintf("Fehler beim Setzen der Log-Datei: %v", err))
 	}
-	checkForWow64()
+	checkPlatform()
 
 	if len(os.Args) <= 1 {
 		if ui.RaiseUI() {
@@ -308,8 +339,38 @@ func main() {
 			return nil
 		},
 		"/dumplog": func() error {
-			if len(os.Args) != 2 && len(os.Args) != 3 {
-				usage()
+			tail := false
+			filter := ringlogger.DumpFilter{MinLevel: ringlogger.LevelDebug}
+			for i := 2; i < len(os.Args); i++ {
+				switch {
+				case os.Args[i] == "/tail" || os.Args[i] == "/follow":
+					tail = true
+				case os.Args[i] == "/level" && i+1 < len(os.Args):
+					level, err := ringlogger.ParseLevel(os.Args[i+1])
+					if err != nil {
+						return err
+					}
+					filter.MinLevel = level
+					i++
+				case os.Args[i] == "/since" && i+1 < len(os.Args):
+					since, err := time.ParseDuration(os.Args[i+1])
+					if err != nil || since <= 0 {
+						return fmt.Errorf("Ungültige Dauer: %q", os.Args[i+1])
+					}
+					filter.Since = time.Now().Add(-since)
+					i++
+				case os.Args[i] == "/tunnel" && i+1 < len(os.Args):
+					if !conf.TunnelNameIsValid(os.Args[i+1]) {
+						return fmt.Errorf("Ungültiger Tunnelname: %q", os.Args[i+1])
+					}
+					filter.Tunnel = os.Args[i+1]
+					i++
+				case os.Args[i] == "/contains" && i+1 < len(os.Args):
+					filter.Contains = os.Args[i+1]
+					i++
+				default:
+					usage()
+				}
 			}
 			outputHandle, err := windows.GetStdHandle(windows.STD_OUTPUT_HANDLE)
 			if err != nil {
@@ -325,32 +386,48 @@ func main() {
 			if err != nil {
 				return fmt.Errorf("Fehler beim Abrufen des Log-Dateipfads: %w", err)
 			}
-			return ringlogger.DumpTo(logPath, file, len(os.Args) == 3 && os.Args[2] == "/tail")
+			return ringlogger.DumpTo(logPath, file, tail, &filter)
+		},
+		"/diagnostics": cliDiagnostics,
+		"/list":        cliList,
+		"/status":      cliStatus,
+		"/up": func() error {
+			return cliSetState("start")
 		},
+		"/down": func() error {
+			return cliSetState("stop")
+		},
+		"/syncconf":        cliSyncConf,
+		"/validate":        cliValidate,
+		"/importfromurl":   cliImportFromURL,
+		"/shutdownmanager": cliShutdownManager,
+		"/genkey":          cliGenKey,
+		"/genpsk":          cliGenPSK,
+		"/pubkey":          cliPubKey,
+		"/cleanupadapters": cliCleanupAdapters,
 		"/update": func() error {
 			if len(os.Args) != 2 {
 				usage()
 			}
-			for progress := range updater.DownloadVerifyAndExecute(0) {
-				if len(progress.Activity) > 0 {
-					if progress.BytesTotal > 0 || progress.BytesDownloaded > 0 {
-						var percent float64
-						if progress.BytesTotal > 0 {
-							percent = float64(progress.BytesDownloaded) / float64(progress.BytesTotal) * 100.0
-						}
-						log.Printf("%s: %d/%d (%.2f%%)\n", progress.Activity, progress.BytesDownloaded, progress.BytesTotal, percent)
-					} else {
-						log.Println(progress.Activity)
-					}
-				}
-				if progress.Error != nil {
-					log.Printf("Fehler: %v\n", progress.Error)
-				}
-				if progress.Complete || progress.Error != nil {
-					return progress.Error
-				}
+			return cliUpdateProgress(updater.DownloadVerifyAndExecute(0))
+		},
+		"/rollback": func() error {
+			if len(os.Args) != 2 {
+				usage()
 			}
-			return nil
+			return cliUpdateProgress(updater.Rollback(0))
+		},
+		"/activatetunnel": func() error {
+			if len(os.Args) != 3 || !conf.TunnelNameIsValid(os.Args[2]) {
+				usage()
+			}
+			return runJumpListTask(ui.JumpListActivateTask, os.Args[2])
+		},
+		"/importtunnel": func() error {
+			if len(os.Args) != 2 {
+				usage()
+			}
+			return runJumpListTask(ui.JumpListImportTask, "")
 		},
 		"/removedriver": func() error {
 			if len(os.Args) != 2 {
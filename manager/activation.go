@@ -0,0 +1,172 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
+)
+
+// Activation rules and profiles are only evaluated when the set of connected networks changes,
+// so that a user who manually toggles a tunnel is not immediately overruled.
+
+const activationSettleDelay = 3 * time.Second
+
+type wlanInterfaceInfo struct {
+	interfaceGUID windows.GUID
+	description   [256]uint16
+	state         uint32
+}
+
+type wlanInterfaceInfoList struct {
+	numberOfItems uint32
+	index         uint32
+	interfaceInfo [1]wlanInterfaceInfo
+}
+
+// wlanConnectionAttributes is the beginning of WLAN_CONNECTION_ATTRIBUTES, up to and including the SSID.
+type wlanConnectionAttributes struct {
+	state          uint32
+	connectionMode uint32
+	profileName    [256]uint16
+	ssidLength     uint32
+	ssid           [32]byte
+}
+
+const (
+	wlanClientVersion2              = 2
+	wlanInterfaceStateConnected     = 1
+	wlanIntfOpcodeCurrentConnection = 7
+)
+
+var (
+	activationLock         sync.Mutex
+	lastActivationNetworks string
+	activationTimer        *time.Timer
+	activationTimerLock    sync.Mutex
+)
+
+func connectedSSIDs() []string {
+	if procWlanOpenHandle.Find() != nil {
+		return nil // No WLAN API, as on Server Core.
+	}
+	var negotiatedVersion uint32
+	var handle windows.Handle
+	if wlanOpenHandle(wlanClientVersion2, 0, &negotiatedVersion, &handle) != nil {
+		return nil // The WLAN AutoConfig service is not running.
+	}
+	defer wlanCloseHandle(handle, 0)
+	var list *wlanInterfaceInfoList
+	if wlanEnumInterfaces(handle, 0, &list) != nil {
+		return nil
+	}
+	defer wlanFreeMemory(unsafe.Pointer(list))
+	var ssids []string
+	for _, iface := range unsafe.Slice(&list.interfaceInfo[0], list.numberOfItems) {
+		if iface.state != wlanInterfaceStateConnected {
+			continue
+		}
+		var size uint32
+		var data unsafe.Pointer
+		if wlanQueryInterface(handle, &iface.interfaceGUID, wlanIntfOpcodeCurrentConnection, 0, &size, &data, nil) != nil {
+			continue
+		}
+		if uintptr(size) >= unsafe.Sizeof(wlanConnectionAttributes{}) {
+			attributes := (*wlanConnectionAttributes)(data)
+			if attributes.ssidLength <= uint32(len(attributes.ssid)) {
+				ssids = append(ssids, string(attributes.ssid[:attributes.ssidLength]))
+			}
+		}
+		wlanFreeMemory(data)
+	}
+	sort.Strings(ssids)
+	return ssids
+}
+
+func ethernetConnected() bool {
+	adapters, err := winipcfg.GetAdaptersAddresses(windows.AF_UNSPEC, winipcfg.GAAFlagIncludeGateways)
+	if err != nil {
+		return false
+	}
+	for _, adapter := range adapters {
+		if adapter.IfType == winipcfg.IfTypeEthernetCSMACD && adapter.OperStatus == winipcfg.IfOperStatusUp && adapter.FirstGatewayAddress != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func currentNetworkState() *conf.NetworkState {
+	return &conf.NetworkState{SSIDs: connectedSSIDs(), Ethernet: ethernetConnected()}
+}
+
+func evaluateActivationRules() {
+	activationLock.Lock()
+	defer activationLock.Unlock()
+
+	state := currentNetworkState()
+	networks := state.String()
+	if networks == lastActivationNetworks {
+		return
+	}
+	lastActivationNetworks = networks
+	switchProfiles(state)
+
+	names, err := conf.ListConfigNames()
+	if err != nil {
+		log.Printf("Unable to list tunnels for activation rules: %v", err)
+		return
+	}
+	s := &ManagerService{}
+	for _, name := range names {
+		rules, err := conf.LoadActivationRules(name)
+		if err != nil {
+			log.Printf("[%s] Unable to load activation rules: %v", name, err)
+			continue
+		}
+		if rules.IsEmpty() {
+			continue
+		}
+		tunnelState, err := s.State(name)
+		if err != nil {
+			continue
+		}
+		if rules.WantsActive(state) {
+			if tunnelState == TunnelStopped {
+				log.Printf("[%s] Activating tunnel on %s", name, networks)
+				err = s.Start(name)
+			}
+		} else if tunnelState == TunnelStarted {
+			log.Printf("[%s] Deactivating tunnel on %s", name, networks)
+			err = s.Stop(name)
+		}
+		if err != nil {
+			log.Printf("[%s] Unable to apply activation rules: %v", name, err)
+		}
+	}
+}
+
+// watchActivationRules evaluates activation rules now and whenever interfaces come and go,
+// once things have settled down.
+func watchActivationRules() (*winipcfg.InterfaceChangeCallback, error) {
+	go evaluateActivationRules()
+	return winipcfg.RegisterInterfaceChangeCallback(func(notificationType winipcfg.MibNotificationType, iface *winipcfg.MibIPInterfaceRow) {
+		activationTimerLock.Lock()
+		defer activationTimerLock.Unlock()
+		if activationTimer != nil {
+			activationTimer.Stop()
+		}
+		activationTimer = time.AfterFunc(activationSettleDelay, evaluateActivationRules)
+	})
+}
@@ -0,0 +1,108 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+// Archives move every tunnel to another computer at once. Since the configurations in the store
+// are encrypted with DPAPI for this computer, the manager decrypts them and encrypts the archive
+// with a passphrase instead, so the UI never handles the stored files itself.
+
+// uniqueTunnelName returns name, or if it is taken, name with the lowest numbered suffix that
+// is not, shortening name as needed to keep it valid.
+func uniqueTunnelName(name string, taken map[string]bool) string {
+	if !taken[strings.ToLower(name)] {
+		return name
+	}
+	for i := 2; ; i++ {
+		suffix := "-" + strconv.Itoa(i)
+		base := name
+		if len(base)+len(suffix) > 32 {
+			base = base[:32-len(suffix)]
+		}
+		if candidate := base + suffix; conf.TunnelNameIsValid(candidate) && !taken[strings.ToLower(candidate)] {
+			return candidate
+		}
+	}
+}
+
+// ExportArchive returns a zip of the configurations of all tunnels, encrypted with passphrase.
+func (s *ManagerService) ExportArchive(passphrase string) ([]byte, error) {
+	if s.elevatedToken == 0 {
+		return nil, windows.ERROR_ACCESS_DENIED
+	}
+	names, err := conf.ListConfigNames()
+	if err != nil {
+		return nil, err
+	}
+	configs := make([]*conf.Config, 0, len(names))
+	for _, name := range names {
+		config, err := conf.LoadFromName(name)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, config)
+	}
+	var archive bytes.Buffer
+	err = conf.WriteArchive(&archive, configs, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if archive.Len() > conf.MaxArchiveSize {
+		return nil, errors.New("Archive is too large")
+	}
+	return archive.Bytes(), nil
+}
+
+// ImportArchive creates a tunnel from each configuration in an archive made by ExportArchive,
+// renaming those whose names are taken, and returns the names of the tunnels that it created.
+// Configurations are only created once the whole archive has been read.
+func (s *ManagerService) ImportArchive(archive []byte, passphrase string) ([]string, error) {
+	if s.elevatedToken == 0 {
+		return nil, windows.ERROR_ACCESS_DENIED
+	}
+	if conf.LoadPolicies().DisableConfigEditing {
+		return nil, errConfigEditingDisabled
+	}
+	configs, err := conf.ReadArchive(archive, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(configs) > conf.MaxTunnelsPerImport {
+		return nil, fmt.Errorf("No more than %d tunnels may be imported at once", conf.MaxTunnelsPerImport)
+	}
+	names, err := conf.ListConfigNames()
+	if err != nil {
+		return nil, err
+	}
+	taken := make(map[string]bool, len(names)+len(configs))
+	for _, name := range names {
+		taken[strings.ToLower(name)] = true
+	}
+	var created []string
+	var lastErr error
+	for _, config := range configs {
+		config.Name = uniqueTunnelName(config.Name, taken)
+		taken[strings.ToLower(config.Name)] = true
+		_, err = s.Create(config)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		created = append(created, config.Name)
+	}
+	return created, lastErr
+}
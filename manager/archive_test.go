@@ -0,0 +1,24 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUniqueTunnelName(t *testing.T) {
+	taken := map[string]bool{"office": true, "office-2": true, strings.Repeat("a", 32): true}
+	for name, want := range map[string]string{
+		"home":                  "home",
+		"Office":                "Office-3",
+		strings.Repeat("a", 32): strings.Repeat("a", 30) + "-2",
+	} {
+		if got := uniqueTunnelName(name, taken); got != want {
+			t.Errorf("uniqueTunnelName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+// The automation pipe is a small, documented, line-based JSON protocol for scripts and
+// monitoring agents. Unlike the gob IPC used by the UI, it is not tied to inherited handles,
+// and its wire format is versioned, so that it can be kept stable. See docs/automation.md.
+
+const (
+	AutomationPipeName        = `\\.\pipe\ProtectedPrefix\Administrators\WireGuard\Automation`
+	AutomationProtocolVersion = 1
+
+	maxAutomationRequestSize = 1024 * 1024
+)
+
+type AutomationRequest struct {
+	Version int    `json:"version"`
+	Method  string `json:"method"`
+	Tunnel  string `json:"tunnel,omitempty"`
+	Config  string `json:"config,omitempty"`
+
+	StopTunnels *bool `json:"stop_tunnels,omitempty"`
+}
+
+type AutomationResponse struct {
+	Version     int                `json:"version"`
+	Error       string             `json:"error,omitempty"`
+	Tunnels     []AutomationTunnel `json:"tunnels,omitempty"`
+	Diagnostics []byte             `json:"diagnostics,omitempty"`
+	Driver      *AutomationDriver  `json:"driver,omitempty"`
+}
+
+type AutomationTunnel struct {
+	Name  string           `json:"name"`
+	State string           `json:"state"`
+	LUID  uint64           `json:"luid,omitempty"`
+	GUID  string           `json:"guid,omitempty"`
+	Peers []AutomationPeer `json:"peers,omitempty"`
+}
+
+type AutomationDriver struct {
+	Version  string   `json:"version,omitempty"`
+	Features []string `json:"features"`
+}
+
+type AutomationPeer struct {
+	Endpoint      string   `json:"endpoint,omitempty"`
+	AllowedIPs    []string `json:"allowed_ips,omitempty"`
+	LastHandshake int64    `json:"last_handshake,omitempty"`
+	RxBytes       uint64   `json:"rx_bytes"`
+	TxBytes       uint64   `json:"tx_bytes"`
+}
+
+func automationStateName(state TunnelState) string {
+	switch state {
+	case TunnelStarted:
+		return "started"
+	case TunnelStopped:
+		return "stopped"
+	case TunnelStarting:
+		return "starting"
+	case TunnelStopping:
+		return "stopping"
+	default:
+		return "unknown"
+	}
+}
+
+func serveAutomation() {
+	name16, err := windows.UTF16PtrFromString(AutomationPipeName)
+	if err != nil {
+		log.Printf("Unable to start automation pipe: %v", err)
+		return
+	}
+	sd := automationPipeSecurityDescriptor()
+	if sd == nil {
+		log.Println("Unable to start automation pipe without a security descriptor")
+		return
+	}
+	sa := securityAttributes(sd)
+	first := uint32(windows.FILE_FLAG_FIRST_PIPE_INSTANCE)
+	for {
+		pipe, err := windows.CreateNamedPipe(name16, windows.PIPE_ACCESS_DUPLEX|first,
+			windows.PIPE_TYPE_BYTE|windows.PIPE_READMODE_BYTE|windows.PIPE_WAIT|windows.PIPE_REJECT_REMOTE_CLIENTS,
+			windows.PIPE_UNLIMITED_INSTANCES, 4096, 4096, 0, sa)
+		if err != nil {
+			log.Printf("Unable to create automation pipe: %v", err)
+			return
+		}
+		first = 0
+		err = windows.ConnectNamedPipe(pipe, nil)
+		if err != nil && err != windows.ERROR_PIPE_CONNECTED {
+			windows.CloseHandle(pipe)
+			continue
+		}
+		go serveAutomationConn(os.NewFile(uintptr(pipe), AutomationPipeName))
+	}
+}
+
+// automationClientToken returns the token of the client connected to pipe if it is an elevated
+// administrator or Local System, and 0 otherwise.
+func automationClientToken(pipe windows.Handle) windows.Token {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	err := impersonateNamedPipeClient(pipe)
+	if err != nil {
+		return 0
+	}
+	var token windows.Token
+	err = windows.OpenThreadToken(windows.CurrentThread(), windows.TOKEN_QUERY|windows.TOKEN_DUPLICATE, true, &token)
+	windows.RevertToSelf()
+	if err != nil {
+		return 0
+	}
+	adminSid, err := windows.CreateWellKnownSid(windows.WinBuiltinAdministratorsSid)
+	if err != nil {
+		token.Close()
+		return 0
+	}
+	isAdmin, err := token.IsMember(adminSid)
+	if err != nil || !isAdmin {
+		token.Close()
+		return 0
+	}
+	return token
+}
+
+func serveAutomationConn(pipe *os.File) {
+	defer pipe.Close()
+	elevatedToken := automationClientToken(windows.Handle(pipe.Fd()))
+	if elevatedToken != 0 {
+		defer elevatedToken.Close()
+	}
+	serveAutomationRequests(pipe, pipe, elevatedToken)
+}
+
+// serveAutomationRequests answers requests read from reader until it fails, for a client who is
+// an administrator if elevatedToken is not zero.
+func serveAutomationRequests(reader io.Reader, writer io.Writer, elevatedToken windows.Token) {
+	// Automation clients get the same view of tunnels as a limited UI: no keys, no editing.
+	// Only administrators may additionally synchronize the peers of running tunnels.
+	s := &ManagerService{elevatedToken: elevatedToken}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 4096), maxAutomationRequestSize)
+	encoder := json.NewEncoder(writer)
+	for scanner.Scan() {
+		var request AutomationRequest
+		response := AutomationResponse{Version: AutomationProtocolVersion}
+		err := json.Unmarshal(scanner.Bytes(), &request)
+		if err == nil && request.Method == "diagnostics" {
+			response.Diagnostics, err = s.automationDiagnostics(&request)
+		} else if err == nil && request.Method == "driver" {
+			response.Driver, err = s.automationDriver(&request)
+		} else if err == nil {
+			response.Tunnels, err = s.automationCall(&request)
+		}
+		if err != nil {
+			response.Error = err.Error()
+		}
+		if encoder.Encode(&response) != nil {
+			return
+		}
+	}
+}
+
+func (s *ManagerService) automationTunnel(name string, withPeers bool) (AutomationTunnel, error) {
+	state, err := s.State(name)
+	if err != nil {
+		return AutomationTunnel{}, err
+	}
+	tunnel := AutomationTunnel{Name: name, State: automationStateName(state)}
+	if !withPeers || state != TunnelStarted {
+		return tunnel, nil
+	}
+	config, err := s.RuntimeConfig(name)
+	if err != nil {
+		return AutomationTunnel{}, err
+	}
+	if adapter, err := adapterIdentity(name); err == nil {
+		tunnel.LUID, tunnel.GUID = adapter.LUID, adapter.GUID.String()
+	}
+	for _, peer := range config.Peers {
+		p := AutomationPeer{
+			RxBytes: uint64(peer.RxBytes),
+			TxBytes: uint64(peer.TxBytes),
+		}
+		if !peer.Endpoint.IsEmpty() {
+			p.Endpoint = peer.Endpoint.String()
+		}
+		for _, a := range peer.AllowedIPs {
+			p.AllowedIPs = append(p.AllowedIPs, a.String())
+		}
+		if !peer.LastHandshakeTime.IsEmpty() {
+			p.LastHandshake = int64(time.Duration(peer.LastHandshakeTime) / time.Second)
+		}
+		tunnel.Peers = append(tunnel.Peers, p)
+	}
+	return tunnel, nil
+}
+
+func (s *ManagerService) automationCall(request *AutomationRequest) ([]AutomationTunnel, error) {
+	if request.Version != AutomationProtocolVersion {
+		return nil, fmt.Errorf("Unsupported protocol version %d", request.Version)
+	}
+	if request.Method != "list" && request.Method != "shutdown" && !conf.TunnelNameIsValid(request.Tunnel) {
+		return nil, errors.New("Tunnel name is not valid")
+	}
+	switch request.Method {
+	case "list":
+		names, err := conf.ListConfigNames()
+		if err != nil {
+			return nil, err
+		}
+		tunnels := make([]AutomationTunnel, 0, len(names))
+		for _, name := range names {
+			tunnel, err := s.automationTunnel(name, false)
+			if err != nil {
+				return nil, err
+			}
+			tunnels = append(tunnels, tunnel)
+		}
+		return tunnels, nil
+	case "state", "stats":
+		if _, err := conf.LoadFromName(request.Tunnel); err != nil {
+			return nil, err
+		}
+		tunnel, err := s.automationTunnel(request.Tunnel, request.Method == "stats")
+		if err != nil {
+			return nil, err
+		}
+		return []AutomationTunnel{tunnel}, nil
+	case "start":
+		err := s.checkProtectedAutomation(request.Tunnel)
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.Start(request.Tunnel)
+	case "stop":
+		return nil, s.Stop(request.Tunnel)
+	case "shutdown":
+		stopTunnels := conf.AdminBool("StopTunnelsOnShutdown")
+		if request.StopTunnels != nil {
+			stopTunnels = *request.StopTunnels
+		}
+		_, err := s.Shutdown(stopTunnels)
+		return nil, err
+	case "syncconf":
+		config, err := conf.FromWgQuickWithUnknownEncoding(request.Config, request.Tunnel)
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.SyncConfig(config)
+	case "import":
+		config, err := conf.FromWgQuickWithUnknownEncoding(request.Config, request.Tunnel)
+		if err != nil {
+			return nil, err
+		}
+		names, err := conf.ListConfigNames()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if strings.EqualFold(name, config.Name) {
+				return nil, fmt.Errorf("Another tunnel already exists with the name ‘%s’", name)
+			}
+		}
+		_, err = s.Create(config)
+		if err != nil {
+			return nil, err
+		}
+		tunnel, err := s.automationTunnel(config.Name, false)
+		if err != nil {
+			return nil, err
+		}
+		return []AutomationTunnel{tunnel}, nil
+	default:
+		return nil, fmt.Errorf("Unknown method %q", request.Method)
+	}
+}
+
+// automationDiagnostics answers the diagnostics method, which, unlike the others, returns a
+// bundle rather than tunnels.
+func (s *ManagerService) automationDiagnostics(request *AutomationRequest) ([]byte, error) {
+	if request.Version != AutomationProtocolVersion {
+		return nil, fmt.Errorf("Unsupported protocol version %d", request.Version)
+	}
+	return s.Diagnostics()
+}
+
+// automationDriver answers the driver method, which returns the driver rather than tunnels.
+func (s *ManagerService) automationDriver(request *AutomationRequest) (*AutomationDriver, error) {
+	if request.Version != AutomationProtocolVersion {
+		return nil, fmt.Errorf("Unsupported protocol version %d", request.Version)
+	}
+	info := s.DriverInfo()
+	driver := &AutomationDriver{Version: info.Version, Features: info.Features}
+	if driver.Features == nil {
+		driver.Features = []string{}
+	}
+	return driver, nil
+}
+
+// AutomationCall sends a single request over the automation pipe and returns the response.
+// A non-empty Error in the response is returned as an error.
+func AutomationCall(request AutomationRequest) (*AutomationResponse, error) {
+	pipe, err := os.OpenFile(AutomationPipeName, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer pipe.Close()
+	request.Version = AutomationProtocolVersion
+	err = json.NewEncoder(pipe).Encode(&request)
+	if err != nil {
+		return nil, err
+	}
+	var response AutomationResponse
+	err = json.NewDecoder(pipe).Decode(&response)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Error) > 0 {
+		return &response, errors.New(response.Error)
+	}
+	return &response, nil
+}
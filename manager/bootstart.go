@@ -0,0 +1,111 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc/mgr"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/services"
+)
+
+// On gateways with many tunnels, how long it takes for all of them to come up after boot matters,
+// so the manager holds the objects through which the tunnel services take turns creating their
+// adapters, and reports how many of them have started until all have, or until bootStartTimeout.
+const (
+	bootStartTimeout      = time.Minute * 10
+	bootStartPollInterval = time.Second
+)
+
+// bootTunnels returns the names of the tunnels whose services start at boot.
+func bootTunnels() []string {
+	m, err := serviceManager()
+	if err != nil {
+		return nil
+	}
+	names, err := conf.ListConfigNames()
+	if err != nil {
+		return nil
+	}
+	var tunnels []string
+	for _, name := range names {
+		serviceName, err := conf.ServiceNameOfTunnel(name)
+		if err != nil {
+			continue
+		}
+		service, err := m.OpenService(serviceName)
+		if err != nil {
+			continue
+		}
+		config, err := service.Config()
+		service.Close()
+		if err == nil && config.StartType == mgr.StartAutomatic {
+			tunnels = append(tunnels, name)
+		}
+	}
+	sort.Strings(tunnels)
+	return tunnels
+}
+
+func coordinateBootStart() {
+	if !services.StartedAtBoot() {
+		return
+	}
+	coordination, err := services.OpenBootCoordination()
+	if err != nil {
+		log.Printf("Unable to coordinate tunnel adapter creation at boot: %v", err)
+	} else {
+		defer coordination.Close()
+	}
+	tunnels := bootTunnels()
+	if len(tunnels) == 0 {
+		return
+	}
+	log.Printf("Waiting for %d tunnels to start at boot", len(tunnels))
+	deadline := time.Now().Add(bootStartTimeout)
+	lastStarted, lastStopped := -1, -1
+	var started, stopped int
+	var pending []string
+	for {
+		started, stopped, pending = 0, 0, pending[:0]
+		trackedTunnelsLock.Lock()
+		for _, name := range tunnels {
+			switch trackedTunnels[name] {
+			case TunnelStarted:
+				started++
+			case TunnelStopped:
+				stopped++
+			default:
+				pending = append(pending, name)
+			}
+		}
+		trackedTunnelsLock.Unlock()
+		if started != lastStarted || stopped != lastStopped {
+			log.Printf("Started %d of %d tunnels at boot, %d stopped, %v after boot", started, len(tunnels), stopped, windows.DurationSinceBoot().Round(time.Second))
+			lastStarted, lastStopped = started, stopped
+		}
+		if len(pending) == 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(bootStartPollInterval)
+	}
+	message := fmt.Sprintf("Started %d of %d tunnels at boot in %v", started, len(tunnels), windows.DurationSinceBoot().Round(time.Second))
+	if stopped > 0 {
+		message += fmt.Sprintf("; %d stopped", stopped)
+	}
+	if len(pending) > 0 {
+		message += fmt.Sprintf("; still starting: %s", strings.Join(pending, ", "))
+	}
+	log.Print(message)
+	reportEvent(eventBootTunnelsStarted, stopped > 0 || len(pending) > 0, message)
+}
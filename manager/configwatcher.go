@@ -0,0 +1,158 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+// Deployment tooling often writes a configuration in more than one step, so changes are only
+// acted upon once the configuration directory has been quiet for a while.
+const configChangeSettleDelay = 2 * time.Second
+
+// configFingerprint tells whether a configuration file changed without reading it, since reading
+// it would itself trigger another change notification on volumes that track access times.
+type configFingerprint struct {
+	modTime time.Time
+	size    int64
+}
+
+var (
+	configFingerprints     map[string]configFingerprint
+	configFingerprintsLock sync.Mutex
+	configChangeTimer      *time.Timer
+)
+
+func fingerprintConfig(name string) (configFingerprint, bool) {
+	path, err := (&conf.Config{Name: name}).Path()
+	if err != nil {
+		return configFingerprint{}, false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return configFingerprint{}, false
+	}
+	return configFingerprint{info.ModTime(), info.Size()}, true
+}
+
+func fingerprintConfigs() (map[string]configFingerprint, error) {
+	names, err := conf.ListConfigNames()
+	if err != nil {
+		return nil, err
+	}
+	fingerprints := make(map[string]configFingerprint, len(names))
+	for _, name := range names {
+		if fingerprint, ok := fingerprintConfig(name); ok {
+			fingerprints[name] = fingerprint
+		}
+	}
+	return fingerprints, nil
+}
+
+// diffConfigFingerprints returns which tunnels were added, changed, and removed between two
+// fingerprints of the configuration directory.
+func diffConfigFingerprints(old, current map[string]configFingerprint) (added, changed, removed []string) {
+	for name, fingerprint := range current {
+		oldFingerprint, ok := old[name]
+		if !ok {
+			added = append(added, name)
+		} else if !oldFingerprint.modTime.Equal(fingerprint.modTime) || oldFingerprint.size != fingerprint.size {
+			changed = append(changed, name)
+		}
+	}
+	for name := range old {
+		if _, ok := current[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	return
+}
+
+// noteConfigChange records a change that the manager made to a configuration itself, so that it
+// is not mistaken for an external one.
+func noteConfigChange(name string) {
+	configFingerprintsLock.Lock()
+	defer configFingerprintsLock.Unlock()
+	if configFingerprints == nil {
+		return
+	}
+	if fingerprint, ok := fingerprintConfig(name); ok {
+		configFingerprints[name] = fingerprint
+	} else {
+		delete(configFingerprints, name)
+	}
+}
+
+// onConfigDirectoryChange is a store change callback, which schedules looking for configurations
+// that were added, changed, or removed by something other than the manager.
+func onConfigDirectoryChange() {
+	configFingerprintsLock.Lock()
+	defer configFingerprintsLock.Unlock()
+	if configFingerprints == nil {
+		fingerprints, err := fingerprintConfigs()
+		if err != nil {
+			log.Printf("Unable to list tunnels for the configuration watcher: %v", err)
+			return
+		}
+		configFingerprints = fingerprints
+		return
+	}
+	if configChangeTimer != nil {
+		configChangeTimer.Stop()
+	}
+	configChangeTimer = time.AfterFunc(configChangeSettleDelay, evaluateConfigChanges)
+}
+
+// evaluateConfigChanges logs external changes to configurations and, if the
+// RestartTunnelsOnConfigChange policy is set, restarts running tunnels whose configuration changed.
+func evaluateConfigChanges() {
+	fingerprints, err := fingerprintConfigs()
+	if err != nil {
+		log.Printf("Unable to list tunnels for the configuration watcher: %v", err)
+		return
+	}
+	configFingerprintsLock.Lock()
+	added, changed, removed := diffConfigFingerprints(configFingerprints, fingerprints)
+	configFingerprints = fingerprints
+	configFingerprintsLock.Unlock()
+
+	for _, name := range added {
+		log.Printf("[%s] Configuration was added externally", name)
+	}
+	for _, name := range removed {
+		log.Printf("[%s] Configuration was removed externally", name)
+	}
+	restart := conf.AdminBool("RestartTunnelsOnConfigChange")
+	s := &ManagerService{}
+	for _, name := range changed {
+		log.Printf("[%s] Configuration was changed externally", name)
+		if !restart {
+			continue
+		}
+		state, err := s.State(name)
+		if err != nil || state != TunnelStarted {
+			continue
+		}
+		go func(name string) {
+			log.Printf("[%s] Restarting tunnel for changed configuration", name)
+			err := s.Stop(name)
+			if err == nil {
+				err = s.WaitForStop(name)
+			}
+			if err == nil {
+				err = s.Start(name)
+			}
+			if err != nil {
+				log.Printf("[%s] Unable to restart tunnel: %v", name, err)
+			}
+		}(name)
+	}
+}
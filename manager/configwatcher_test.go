@@ -0,0 +1,38 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffConfigFingerprints(t *testing.T) {
+	now := time.Now()
+	old := map[string]configFingerprint{
+		"same":    {now, 100},
+		"touched": {now, 100},
+		"resized": {now, 100},
+		"gone":    {now, 100},
+	}
+	current := map[string]configFingerprint{
+		"same":    {now, 100},
+		"touched": {now.Add(time.Second), 100},
+		"resized": {now, 200},
+		"new":     {now, 100},
+	}
+	added, changed, removed := diffConfigFingerprints(old, current)
+	if !reflect.DeepEqual(added, []string{"new"}) {
+		t.Errorf("Added %v", added)
+	}
+	if len(changed) != 2 || (changed[0] != "touched" && changed[1] != "touched") || (changed[0] != "resized" && changed[1] != "resized") {
+		t.Errorf("Changed %v", changed)
+	}
+	if !reflect.DeepEqual(removed, []string{"gone"}) {
+		t.Errorf("Removed %v", removed)
+	}
+}
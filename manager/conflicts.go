@@ -0,0 +1,251 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+type ConflictKind uint32
+
+const (
+	ConflictDefaultRoute ConflictKind = iota + 1
+	ConflictOverlappingRoutes
+	ConflictOverlappingAddresses
+	ConflictListenPort
+	ConflictAdapterGUID
+)
+
+// Conflict is something that a tunnel being activated shares with another running tunnel, which
+// keeps one of them from working as configured. Detail depends on the kind: the address family
+// for ConflictDefaultRoute, the two overlapping prefixes for ConflictOverlappingRoutes and
+// ConflictOverlappingAddresses, the port for ConflictListenPort, and the GUID for
+// ConflictAdapterGUID.
+type Conflict struct {
+	Kind   ConflictKind
+	Tunnel string
+	Detail string
+}
+
+func (conflict Conflict) String() string {
+	switch conflict.Kind {
+	case ConflictDefaultRoute:
+		return fmt.Sprintf("the tunnel ‘%s’ also routes all %s traffic", conflict.Tunnel, conflict.Detail)
+	case ConflictOverlappingRoutes:
+		return fmt.Sprintf("the allowed IPs %s overlap with those of the tunnel ‘%s’", conflict.Detail, conflict.Tunnel)
+	case ConflictOverlappingAddresses:
+		return fmt.Sprintf("the addresses %s overlap with those of the tunnel ‘%s’", conflict.Detail, conflict.Tunnel)
+	case ConflictListenPort:
+		return fmt.Sprintf("the tunnel ‘%s’ is already listening on port %s", conflict.Tunnel, conflict.Detail)
+	case ConflictAdapterGUID:
+		return fmt.Sprintf("the tunnel ‘%s’ already has the adapter GUID %s", conflict.Tunnel, conflict.Detail)
+	default:
+		return "Unknown conflict"
+	}
+}
+
+// ConflictError is returned when a tunnel is not activated because of conflicts with running
+// tunnels, which Conflicts lists so that they can be shown in detail.
+type ConflictError struct {
+	Tunnel    string
+	Conflicts []Conflict
+}
+
+func (e *ConflictError) Error() string {
+	if len(e.Conflicts) == 1 && e.Conflicts[0].Kind == ConflictListenPort {
+		return fmt.Sprintf("The tunnel ‘%s’ is already listening on port %s", e.Conflicts[0].Tunnel, e.Conflicts[0].Detail)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "The tunnel ‘%s’ conflicts with running tunnels:", e.Tunnel)
+	for _, conflict := range e.Conflicts {
+		b.WriteString("\n- ")
+		b.WriteString(conflict.String())
+	}
+	return b.String()
+}
+
+// conflictsOfError returns the conflicts listed by err if it is a ConflictError, so that they can
+// be sent over IPC next to the error message.
+func conflictsOfError(err error) []Conflict {
+	var conflictErr *ConflictError
+	if errors.As(err, &conflictErr) {
+		return conflictErr.Conflicts
+	}
+	return []Conflict{}
+}
+
+// routesAll returns whether the prefixes send all traffic of the family of which zero is the
+// unspecified address, either with a /0 route or with the two /1 routes that wg-quick style
+// configurations use to take precedence over the existing default route.
+func routesAll(prefixes []netip.Prefix, zero netip.Addr) bool {
+	lower, upper := false, false
+	for _, p := range prefixes {
+		if p.Addr().Is4() != zero.Is4() {
+			continue
+		}
+		switch p.Bits() {
+		case 0:
+			return true
+		case 1:
+			if p.Masked().Addr() == zero {
+				lower = true
+			} else {
+				upper = true
+			}
+		}
+	}
+	return lower && upper
+}
+
+func allowedIPs(config *conf.Config) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for i := range config.Peers {
+		prefixes = append(prefixes, config.Peers[i].AllowedIPs...)
+	}
+	return prefixes
+}
+
+// firstOverlap returns the first pair of overlapping but not identical prefixes, skipping those
+// that send all traffic when skipDefault is set.
+func firstOverlap(a, b []netip.Prefix, skipDefault bool) (netip.Prefix, netip.Prefix, bool) {
+	for _, p := range a {
+		if skipDefault && p.Bits() <= 1 {
+			continue
+		}
+		for _, q := range b {
+			if skipDefault && q.Bits() <= 1 {
+				continue
+			}
+			if p.Masked() != q.Masked() && p.Overlaps(q) {
+				return p, q, true
+			}
+		}
+	}
+	return netip.Prefix{}, netip.Prefix{}, false
+}
+
+// findConflicts compares the configuration of a tunnel being activated with those of running
+// tunnels, keyed by name. Identical routes and addresses are not conflicts, because the manager
+// stops the tunnels that have them, and listen ports are left to resolveListenPortConflict.
+func findConflicts(config *conf.Config, running map[string]*conf.Config) []Conflict {
+	var conflicts []Conflict
+	routes := allowedIPs(config)
+	addresses := make([]netip.Prefix, 0, len(config.Interface.Addresses))
+	for _, a := range config.Interface.Addresses {
+		addresses = append(addresses, a.Masked())
+	}
+	names := make([]string, 0, len(running))
+	for name := range running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		other := running[name]
+		otherRoutes := allowedIPs(other)
+		for _, zero := range [...]netip.Addr{netip.IPv4Unspecified(), netip.IPv6Unspecified()} {
+			if routesAll(routes, zero) && routesAll(otherRoutes, zero) {
+				family := "IPv4"
+				if zero.Is6() {
+					family = "IPv6"
+				}
+				conflicts = append(conflicts, Conflict{ConflictDefaultRoute, name, family})
+			}
+		}
+		if p, q, ok := firstOverlap(routes, otherRoutes, true); ok {
+			conflicts = append(conflicts, Conflict{ConflictOverlappingRoutes, name, fmt.Sprintf("%s and %s", p, q)})
+		}
+		otherAddresses := make([]netip.Prefix, 0, len(other.Interface.Addresses))
+		for _, a := range other.Interface.Addresses {
+			otherAddresses = append(otherAddresses, a.Masked())
+		}
+		if p, q, ok := firstOverlap(addresses, otherAddresses, false); ok {
+			conflicts = append(conflicts, Conflict{ConflictOverlappingAddresses, name, fmt.Sprintf("%s and %s", p, q)})
+		}
+		if guid := config.Interface.AdapterGUID; guid != nil && other.Interface.AdapterGUID != nil && *guid == *other.Interface.AdapterGUID {
+			conflicts = append(conflicts, Conflict{ConflictAdapterGUID, name, guid.String()})
+		}
+	}
+	return conflicts
+}
+
+// runningConfigs loads the configurations of the running tunnels, other than the one being
+// activated and those about to be stopped.
+func runningConfigs(config *conf.Config, stopping []string) map[string]*conf.Config {
+	skip := make(map[string]bool, len(stopping)+1)
+	skip[config.Name] = true
+	for _, name := range stopping {
+		skip[name] = true
+	}
+	trackedTunnelsLock.Lock()
+	names := make([]string, 0, len(trackedTunnels))
+	for name, state := range trackedTunnels {
+		if !skip[name] && state != TunnelStopped && state != TunnelStopping {
+			names = append(names, name)
+		}
+	}
+	trackedTunnelsLock.Unlock()
+	configs := make(map[string]*conf.Config, len(names))
+	for _, name := range names {
+		config, err := conf.LoadFromName(name)
+		if err != nil {
+			continue
+		}
+		configs[name] = config
+	}
+	return configs
+}
+
+// checkConflicts looks for conflicts with the running tunnels, other than those about to be
+// stopped. It logs them, and, with the RefuseConflictingTunnels policy, fails with a
+// ConflictError listing them.
+func checkConflicts(config *conf.Config, stopping []string) error {
+	conflicts := findConflicts(config, runningConfigs(config, stopping))
+	if len(conflicts) == 0 {
+		return nil
+	}
+	for _, conflict := range conflicts {
+		log.Printf("[%s] Conflict: %v", config.Name, conflict)
+	}
+	if conf.LoadPolicies().RefuseConflictingTunnels {
+		return &ConflictError{Tunnel: config.Name, Conflicts: conflicts}
+	}
+	return nil
+}
+
+// Conflicts returns the conflicts that activating the tunnel would have with the running tunnels,
+// so that the UI can warn before activating it.
+func (s *ManagerService) Conflicts(tunnelName string) ([]Conflict, error) {
+	c, err := conf.LoadFromName(tunnelName)
+	if err != nil {
+		return nil, err
+	}
+	var stopping []string
+	trackedTunnelsLock.Lock()
+	for t := range trackedTunnels {
+		if c2, err := conf.LoadFromName(t); err == nil && c.IntersectsWith(c2) {
+			stopping = append(stopping, t)
+		}
+	}
+	trackedTunnelsLock.Unlock()
+	running := runningConfigs(c, stopping)
+	conflicts := findConflicts(c, running)
+	ports := make(map[string]uint16, len(running))
+	for name, other := range running {
+		ports[name] = effectiveListenPort(other)
+	}
+	if holder := listenPortHolder(c.Interface.ListenPort, ports); len(holder) != 0 && !conf.AdminBool("RebindConflictingListenPorts") {
+		conflicts = append(conflicts, Conflict{ConflictListenPort, holder, strconv.Itoa(int(c.Interface.ListenPort))})
+	}
+	return conflicts, nil
+}
@@ -0,0 +1,93 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"net/netip"
+	"testing"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+func conflictTestConfig(name string, addresses []string, allowedIPs ...string) *conf.Config {
+	config := &conf.Config{Name: name, Peers: []conf.Peer{{}}}
+	for _, a := range addresses {
+		config.Interface.Addresses = append(config.Interface.Addresses, netip.MustParsePrefix(a))
+	}
+	for _, a := range allowedIPs {
+		config.Peers[0].AllowedIPs = append(config.Peers[0].AllowedIPs, netip.MustParsePrefix(a))
+	}
+	return config
+}
+
+func TestFindConflicts(t *testing.T) {
+	config := conflictTestConfig("work", []string{"10.8.0.2/24"}, "0.0.0.0/0", "10.0.0.0/8")
+	running := map[string]*conf.Config{
+		"home":  conflictTestConfig("home", []string{"192.168.77.2/24"}, "0.0.0.0/1", "128.0.0.0/1"),
+		"lab":   conflictTestConfig("lab", []string{"10.8.0.0/16"}, "10.20.0.0/16"),
+		"other": conflictTestConfig("other", []string{"172.16.0.2/32"}, "172.16.0.0/24", "::/0"),
+	}
+	conflicts := findConflicts(config, running)
+	expected := []Conflict{
+		{ConflictDefaultRoute, "home", "IPv4"},
+		{ConflictOverlappingRoutes, "lab", "10.0.0.0/8 and 10.20.0.0/16"},
+		{ConflictOverlappingAddresses, "lab", "10.8.0.0/24 and 10.8.0.0/16"},
+	}
+	if len(conflicts) != len(expected) {
+		t.Fatalf("Found conflicts %v, expected %v", conflicts, expected)
+	}
+	for i := range expected {
+		if conflicts[i] != expected[i] {
+			t.Errorf("Conflict %d is %+v, expected %+v", i, conflicts[i], expected[i])
+		}
+	}
+
+	// Identical routes are not conflicts, because the manager stops the other tunnel.
+	same := conflictTestConfig("same", []string{"10.9.0.2/24"}, "0.0.0.0/0")
+	if conflicts := findConflicts(same, map[string]*conf.Config{"work": config}); len(conflicts) != 1 || conflicts[0].Kind != ConflictDefaultRoute {
+		t.Errorf("Found conflicts %v, expected only the default route", conflicts)
+	}
+}
+
+func TestAdapterGUIDConflicts(t *testing.T) {
+	guid := windows.GUID{Data1: 0x1b3d5f7a, Data4: [8]byte{0x80, 0, 0, 0, 0, 0, 0, 1}}
+	otherGUID := guid
+	otherGUID.Data1++
+	config := conflictTestConfig("work", []string{"10.8.0.2/24"}, "10.8.0.0/24")
+	config.Interface.AdapterGUID = &guid
+	running := map[string]*conf.Config{
+		"home": conflictTestConfig("home", []string{"10.9.0.2/24"}, "10.9.0.0/24"),
+		"lab":  conflictTestConfig("lab", []string{"10.10.0.2/24"}, "10.10.0.0/24"),
+		"test": conflictTestConfig("test", []string{"10.11.0.2/24"}, "10.11.0.0/24"),
+	}
+	sameGUID := guid
+	running["lab"].Interface.AdapterGUID = &sameGUID
+	running["test"].Interface.AdapterGUID = &otherGUID
+	conflicts := findConflicts(config, running)
+	if len(conflicts) != 1 || conflicts[0] != (Conflict{ConflictAdapterGUID, "lab", guid.String()}) {
+		t.Errorf("Found conflicts %v, expected only the adapter GUID of lab", conflicts)
+	}
+	config.Interface.AdapterGUID = nil
+	if conflicts := findConflicts(config, running); len(conflicts) != 0 {
+		t.Errorf("Found conflicts %v without an adapter GUID", conflicts)
+	}
+}
+
+func TestConflictError(t *testing.T) {
+	err := &ConflictError{Tunnel: "work", Conflicts: []Conflict{{ConflictListenPort, "home", "51820"}}}
+	if err.Error() != "The tunnel ‘home’ is already listening on port 51820" {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+	err.Conflicts = append(err.Conflicts, Conflict{ConflictDefaultRoute, "lab", "IPv6"})
+	if err.Error() != "The tunnel ‘work’ conflicts with running tunnels:\n- the tunnel ‘home’ is already listening on port 51820\n- the tunnel ‘lab’ also routes all IPv6 traffic" {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+	if conflicts := conflictsOfError(err); len(conflicts) != 2 {
+		t.Errorf("Conflicts of error are %v", conflicts)
+	}
+}
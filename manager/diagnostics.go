@@ -0,0 +1,180 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/crashreport"
+	"golang.zx2c4.com/wireguard/windows/driver"
+	"golang.zx2c4.com/wireguard/windows/ringlogger"
+	"golang.zx2c4.com/wireguard/windows/tunnel/firewall"
+	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
+	"golang.zx2c4.com/wireguard/windows/version"
+)
+
+// A diagnostics bundle is a zip file for attaching to support requests. It is put together by the
+// manager, since only it can read the configurations, whose keys are stripped, and the firewall
+// rules. A part that cannot be collected is replaced by the reason, rather than failing the lot.
+
+func diagnosticsVersion(w io.Writer) error {
+	fmt.Fprintf(w, "WireGuard version: %s\n", version.Number)
+	major, minor, build := windows.RtlGetNtVersionNumbers()
+	fmt.Fprintf(w, "Windows version: %d.%d.%d\n", major, minor, build)
+	driverVersion, err := driver.RunningVersion()
+	if err != nil {
+		fmt.Fprintf(w, "Driver version: unknown (%v)\n", err)
+	} else {
+		fmt.Fprintf(w, "Driver version: %s\n", formatDriverVersion(driverVersion))
+	}
+	fmt.Fprintf(w, "Driver features: %s\n", strings.Join(driver.Features(), ", "))
+	return nil
+}
+
+func diagnosticsAdapters(w io.Writer) error {
+	adapters, err := winipcfg.GetAdaptersAddresses(windows.AF_UNSPEC, winipcfg.GAAFlagIncludeAllInterfaces)
+	if err != nil {
+		return err
+	}
+	for _, adapter := range adapters {
+		fmt.Fprintf(w, "%s (%s)\n", adapter.FriendlyName(), adapter.Description())
+		fmt.Fprintf(w, "  luid: %d, index: %d, type: %d, status: %d, mtu: %d\n", adapter.LUID, adapter.IfIndex, adapter.IfType, adapter.OperStatus, adapter.MTU)
+		for address := adapter.FirstUnicastAddress; address != nil; address = address.Next {
+			fmt.Fprintf(w, "  address: %s/%d\n", address.Address.IP(), address.OnLinkPrefixLength)
+		}
+		for server := adapter.FirstDNSServerAddress; server != nil; server = server.Next {
+			fmt.Fprintf(w, "  dns: %s\n", server.Address.IP())
+		}
+	}
+	return nil
+}
+
+func diagnosticsRoutes(w io.Writer) error {
+	routes, err := winipcfg.GetIPForwardTable2(windows.AF_UNSPEC)
+	if err != nil {
+		return err
+	}
+	for i := range routes {
+		fmt.Fprintf(w, "%s via %s, luid %d, metric %d\n", routes[i].DestinationPrefix.Prefix(), routes[i].NextHop.Addr(), routes[i].InterfaceLUID, routes[i].Metric)
+	}
+	return nil
+}
+
+func diagnosticsFirewall(w io.Writer) error {
+	filters, err := firewall.DescribeFilters()
+	if err != nil {
+		return err
+	}
+	if len(filters) == 0 {
+		_, err = io.WriteString(w, "No filters are installed.\n")
+		return err
+	}
+	_, err = io.WriteString(w, strings.Join(filters, "\n")+"\n")
+	return err
+}
+
+func diagnosticsLog(w io.Writer) error {
+	if ringlogger.Global == nil {
+		return nil
+	}
+	_, err := ringlogger.Global.WriteTo(w)
+	return err
+}
+
+// writeDiagnostics writes a diagnostics bundle as a zip file to w. Minidumps are only included
+// when withMinidumps is set, since they may contain keys.
+func writeDiagnostics(w io.Writer, withMinidumps bool) error {
+	archive := zip.NewWriter(w)
+	now := time.Now()
+	add := func(name string, write func(io.Writer) error) error {
+		file, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		if err := write(file); err != nil {
+			fmt.Fprintf(file, "\nUnable to collect %s: %v\n", name, err)
+		}
+		return nil
+	}
+	for _, part := range []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"version.txt", diagnosticsVersion},
+		{"log.txt", diagnosticsLog},
+		{"adapters.txt", diagnosticsAdapters},
+		{"routes.txt", diagnosticsRoutes},
+		{"firewall.txt", diagnosticsFirewall},
+	} {
+		if err := add(part.name, part.write); err != nil {
+			return err
+		}
+	}
+	names, err := conf.ListConfigNames()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		err := add("configs/"+name+".conf", func(w io.Writer) error {
+			config, err := conf.LoadFromName(name)
+			if err != nil {
+				return err
+			}
+			config.Redact()
+			_, err = io.WriteString(w, config.ToWgQuick())
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	reports, err := crashreport.Reports()
+	if err != nil {
+		if err := add("crashes.txt", func(io.Writer) error { return err }); err != nil {
+			return err
+		}
+	}
+	for _, path := range reports {
+		if !withMinidumps && strings.EqualFold(filepath.Ext(path), ".dmp") {
+			continue
+		}
+		err := add("crashes/"+filepath.Base(path), func(w io.Writer) error {
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			_, err = io.Copy(w, file)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// Diagnostics returns a diagnostics bundle, a zip file with the log, the driver version, the
+// adapters, routes, and WireGuard firewall rules, all configurations, with their keys stripped,
+// and the crash reports of the services, with their minidumps only for elevated clients.
+func (s *ManagerService) Diagnostics() ([]byte, error) {
+	var buf bytes.Buffer
+	err := writeDiagnostics(&buf, s.elevatedToken != 0)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
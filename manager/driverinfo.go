@@ -0,0 +1,89 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"fmt"
+	"sort"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/driver"
+	"golang.zx2c4.com/wireguard/windows/services"
+)
+
+// DriverInfo is what bug reports need to know about the driver, which otherwise is only found
+// scattered through the log.
+type DriverInfo struct {
+	Version      string // Version of the running driver, or empty if it is not loaded.
+	VersionError string // Why the version is unknown, if it is.
+	Features     []string
+	Adapters     []DriverAdapterInfo
+}
+
+// DriverAdapterInfo identifies the adapter of a running tunnel.
+type DriverAdapterInfo struct {
+	Tunnel string
+	LUID   uint64
+	GUID   windows.GUID
+}
+
+func formatDriverVersion(version uint32) string {
+	return fmt.Sprintf("%d.%d", (version>>16)&0xffff, version&0xffff)
+}
+
+// adapterIdentity returns the LUID and GUID of the adapter of a running tunnel.
+func adapterIdentity(tunnelName string) (DriverAdapterInfo, error) {
+	adapter, err := findDriverAdapter(tunnelName)
+	if err != nil {
+		return DriverAdapterInfo{}, err
+	}
+	luid := adapter.LUID()
+	adapter.Unlock()
+	guid, err := luid.GUID()
+	if err != nil {
+		return DriverAdapterInfo{}, err
+	}
+	return DriverAdapterInfo{Tunnel: tunnelName, LUID: uint64(luid), GUID: *guid}, nil
+}
+
+// DriverInfo returns the version and features of the driver, and the adapters of the running
+// tunnels.
+func (s *ManagerService) DriverInfo() *DriverInfo {
+	info := &DriverInfo{Features: driver.Features()}
+	if version, err := driver.RunningVersion(); err == nil {
+		info.Version = formatDriverVersion(version)
+	} else {
+		info.VersionError = err.Error()
+	}
+	var names []string
+	trackedTunnelsLock.Lock()
+	for name, state := range trackedTunnels {
+		if state == TunnelStarted {
+			names = append(names, name)
+		}
+	}
+	trackedTunnelsLock.Unlock()
+	sort.Strings(names)
+	for _, name := range names {
+		if adapter, err := adapterIdentity(name); err == nil {
+			info.Adapters = append(info.Adapters, adapter)
+		}
+	}
+	return info
+}
+
+// IsAdapterCreationError reports whether a tunnel error, as passed to tunnel change callbacks, is
+// the failure of a tunnel service to create its adapter.
+func IsAdapterCreationError(err error) bool {
+	return err != nil && err.Error() == services.ErrorCreateNetworkAdapter.Error()
+}
+
+// AdapterDiagnosis looks for the likely causes of a failure to create an adapter. The error itself
+// is only known to the tunnel service, which logs its own diagnosis, so this one is of the system.
+func (s *ManagerService) AdapterDiagnosis() driver.AdapterDiagnosis {
+	return driver.DiagnoseAdapterFailure(nil)
+}
@@ -0,0 +1,49 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"log"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// The manager reports what it does on its own, without anyone signed in to watch, to the
+// Application event log, where administrators and their monitoring look, besides its own log.
+const eventLogSource = "WireGuard"
+
+// Event IDs, which EventCreate.exe, as the message file of the source, allows up to 1000 of.
+const (
+	eventSilentUpdateInstalling = 100
+	eventSilentUpdateFailed     = 101
+	eventBootTunnelsStarted     = 110
+)
+
+func installEventLogSource() {
+	eventlog.Remove(eventLogSource)
+	err := eventlog.InstallAsEventCreate(eventLogSource, eventlog.Error|eventlog.Warning|eventlog.Info)
+	if err != nil {
+		log.Printf("Unable to register event log source: %v", err)
+	}
+}
+
+func uninstallEventLogSource() {
+	eventlog.Remove(eventLogSource)
+}
+
+// reportEvent writes to the event log, failing silently, since the message is also in the log.
+func reportEvent(eventID uint32, isError bool, message string) {
+	l, err := eventlog.Open(eventLogSource)
+	if err != nil {
+		return
+	}
+	defer l.Close()
+	if isError {
+		l.Error(eventID, message)
+	} else {
+		l.Info(eventID, message)
+	}
+}
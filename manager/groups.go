@@ -0,0 +1,161 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+// The tunnels of a group are started one after the other, each being given time to come up before
+// the next is installed, so that a tunnel that reaches its peers through the one before it finds
+// that one's routes in place. They are stopped the other way round. Since that takes a while, it
+// happens in the background, and only one group is started or stopped at a time, lest two groups
+// with tunnels in common fight over them. Note that starting a tunnel still stops those whose
+// addresses intersect with it, even if they are in the same group.
+
+const groupTunnelTimeout = time.Second * 30
+
+var groupLock sync.Mutex
+
+func (s *ManagerService) TunnelGroups() (*conf.TunnelGroups, error) {
+	return conf.LoadTunnelGroups()
+}
+
+func (s *ManagerService) SetTunnelGroups(groups *conf.TunnelGroups) error {
+	if s.elevatedToken == 0 {
+		return windows.ERROR_ACCESS_DENIED
+	}
+	return conf.SaveTunnelGroups(groups)
+}
+
+func findTunnelGroup(groupName string) (*conf.TunnelGroup, error) {
+	groups, err := conf.LoadTunnelGroups()
+	if err != nil {
+		return nil, err
+	}
+	group := groups.Find(groupName)
+	if group == nil {
+		return nil, errors.New("Group does not exist")
+	}
+	return group, nil
+}
+
+// waitForStart waits for the service of a tunnel that was just installed to finish starting.
+func waitForStart(s *ManagerService, tunnelName string) error {
+	deadline := time.Now().Add(groupTunnelTimeout)
+	for time.Now().Before(deadline) {
+		state, err := s.State(tunnelName)
+		if err != nil {
+			return err
+		}
+		switch state {
+		case TunnelStarted:
+			return nil
+		case TunnelStopped, TunnelStopping:
+			return errors.New("Tunnel stopped while starting")
+		}
+		time.Sleep(time.Second / 5)
+	}
+	return fmt.Errorf("Tunnel did not start within %v", groupTunnelTimeout)
+}
+
+func startTunnelGroup(group *conf.TunnelGroup) {
+	groupLock.Lock()
+	defer groupLock.Unlock()
+
+	s := &ManagerService{}
+	log.Printf("Activating group ‘%s’", group.Name)
+	for _, tunnelName := range group.Tunnels {
+		state, err := s.State(tunnelName)
+		if err == nil && state == TunnelStarted {
+			continue
+		}
+		err = s.Start(tunnelName)
+		if err == nil {
+			err = waitForStart(s, tunnelName)
+		}
+		if err != nil {
+			log.Printf("[%s] Unable to activate as part of group ‘%s’: %v", tunnelName, group.Name, err)
+		}
+	}
+}
+
+func stopTunnelGroup(group *conf.TunnelGroup) {
+	groupLock.Lock()
+	defer groupLock.Unlock()
+
+	s := &ManagerService{}
+	log.Printf("Deactivating group ‘%s’", group.Name)
+	for i := len(group.Tunnels) - 1; i >= 0; i-- {
+		tunnelName := group.Tunnels[i]
+		err := s.Stop(tunnelName)
+		if err == nil {
+			err = s.WaitForStop(tunnelName)
+		}
+		if err != nil {
+			log.Printf("[%s] Unable to deactivate as part of group ‘%s’: %v", tunnelName, group.Name, err)
+		}
+	}
+}
+
+// StartGroup starts the tunnels of a group in the background, in order.
+func (s *ManagerService) StartGroup(groupName string) error {
+	group, err := findTunnelGroup(groupName)
+	if err != nil {
+		return err
+	}
+	// A group would otherwise be a way around confirming the activation of a protected tunnel.
+	for _, tunnelName := range group.Tunnels {
+		err = s.checkProtected(tunnelName)
+		if err != nil {
+			return fmt.Errorf("%s: %w", tunnelName, err)
+		}
+	}
+	go startTunnelGroup(group)
+	return nil
+}
+
+// StopGroup stops the tunnels of a group in the background, in reverse order.
+func (s *ManagerService) StopGroup(groupName string) error {
+	group, err := findTunnelGroup(groupName)
+	if err != nil {
+		return err
+	}
+	go stopTunnelGroup(group)
+	return nil
+}
+
+// removeFromTunnelGroups removes a deleted tunnel from the groups that list it.
+func removeFromTunnelGroups(tunnelName string) error {
+	groups, err := conf.LoadTunnelGroups()
+	if err != nil {
+		return err
+	}
+	if !groups.RemoveTunnel(tunnelName) {
+		return nil
+	}
+	return conf.SaveTunnelGroups(groups)
+}
+
+// renameInTunnelGroups puts a renamed tunnel back into the groups that list it, under its new name.
+func renameInTunnelGroups(tunnelName, newName string) error {
+	groups, err := conf.LoadTunnelGroups()
+	if err != nil {
+		return err
+	}
+	if !groups.RenameTunnel(tunnelName, newName) {
+		return nil
+	}
+	return conf.SaveTunnelGroups(groups)
+}
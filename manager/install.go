@@ -17,6 +17,7 @@ import (
 	"golang.org/x/sys/windows/svc/mgr"
 
 	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/tunnel/firewall"
 )
 
 var cachedServiceManager *mgr.Mgr
@@ -91,10 +92,37 @@ func InstallManager() error {
 	if err != nil {
 		return err
 	}
+	installEventLogSource()
 	service.Start()
 	return service.Close()
 }
 
+// WaitForManagerStop waits for the manager service to stop, after having been asked to.
+func WaitForManagerStop(timeout time.Duration) error {
+	m, err := serviceManager()
+	if err != nil {
+		return err
+	}
+	service, err := m.OpenService("WireGuardManager")
+	if err != nil {
+		return err
+	}
+	defer service.Close()
+	for deadline := time.Now().Add(timeout); ; {
+		status, err := service.Query()
+		if err != nil {
+			return err
+		}
+		if status.State == svc.Stopped {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.New("Manager did not stop in time")
+		}
+		time.Sleep(time.Second / 4)
+	}
+}
+
 func UninstallManager() error {
 	m, err := serviceManager()
 	if err != nil {
@@ -108,6 +136,7 @@ func UninstallManager() error {
 	service.Control(svc.Stop)
 	err = service.Delete()
 	err2 := service.Close()
+	uninstallEventLogSource()
 	if err != nil {
 		return err
 	}
@@ -186,6 +215,10 @@ func UninstallTunnel(name string) error {
 	if err != nil {
 		return err
 	}
+	// The kill switch outlives a crashed tunnel service, so an explicit deactivation must lift it here.
+	if err := firewall.DisableKillSwitch(name); err != nil {
+		log.Printf("[%s] Unable to remove kill switch: %v", name, err)
+	}
 	service, err := m.OpenService(serviceName)
 	if err != nil {
 		return err
@@ -12,6 +12,7 @@ import (
 	"sync"
 
 	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/driver"
 	"golang.zx2c4.com/wireguard/windows/updater"
 )
 
@@ -37,6 +38,7 @@ const (
 	ManagerStoppingNotificationType
 	UpdateFoundNotificationType
 	UpdateProgressNotificationType
+	TransferRateNotificationType
 )
 
 type MethodType int
@@ -55,6 +57,49 @@ const (
 	QuitMethodType
 	UpdateStateMethodType
 	UpdateMethodType
+	KillSwitchActiveMethodType
+	SyncConfigMethodType
+	ActivationRulesMethodType
+	SetActivationRulesMethodType
+	PitfallsMethodType
+	AddPeerMethodType
+	AddressPoolsMethodType
+	SetAddressPoolsMethodType
+	PeerStatusesMethodType
+	AdapterLogLevelMethodType
+	SetAdapterLogLevelMethodType
+	WatchdogStatusMethodType
+	DiagnosticsMethodType
+	ProfilesMethodType
+	SetProfilesMethodType
+	SelectProfileMethodType
+	UpdateChannelMethodType
+	SetUpdateChannelMethodType
+	UpdateProxyMethodType
+	SetUpdateProxyMethodType
+	TunnelGroupsMethodType
+	SetTunnelGroupsMethodType
+	StartGroupMethodType
+	StopGroupMethodType
+	TunnelStatsMethodType
+	ProtectedMethodType
+	SetProtectedMethodType
+	StartConfirmedMethodType
+	ScheduleMethodType
+	SetScheduleMethodType
+	ExportArchiveMethodType
+	ImportArchiveMethodType
+	UsageMethodType
+	ConflictsMethodType
+	RenameMethodType
+	DuplicateMethodType
+	SettingsMethodType
+	SetSettingsMethodType
+	RollbackVersionMethodType
+	RollbackMethodType
+	DriverInfoMethodType
+	AdapterDiagnosisMethodType
+	ReplaceConfigMethodType
 )
 
 var (
@@ -70,7 +115,7 @@ type TunnelChangeCallback struct {
 var tunnelChangeCallbacks = make(map[*TunnelChangeCallback]bool)
 
 type TunnelsChangeCallback struct {
-	cb func()
+	cb func(added, removed []Tunnel)
 }
 
 var tunnelsChangeCallbacks = make(map[*TunnelsChangeCallback]bool)
@@ -87,6 +132,12 @@ type UpdateFoundCallback struct {
 
 var updateFoundCallbacks = make(map[*UpdateFoundCallback]bool)
 
+type TransferRateCallback struct {
+	cb func(tunnel *Tunnel, rate TransferRate)
+}
+
+var transferRateCallbacks = make(map[*TransferRateCallback]bool)
+
 type UpdateProgressCallback struct {
 	cb func(dp updater.DownloadProgress)
 }
@@ -138,8 +189,25 @@ func InitializeIPCClient(reader, writer, events *os.File) {
 					cb.cb(t, state, globalState, retErr)
 				}
 			case TunnelsChangeNotificationType:
+				var addedNames, removedNames []string
+				err = decoder.Decode(&addedNames)
+				if err != nil {
+					continue
+				}
+				err = decoder.Decode(&removedNames)
+				if err != nil {
+					continue
+				}
+				added := make([]Tunnel, len(addedNames))
+				for i := range addedNames {
+					added[i].Name = addedNames[i]
+				}
+				removed := make([]Tunnel, len(removedNames))
+				for i := range removedNames {
+					removed[i].Name = removedNames[i]
+				}
 				for cb := range tunnelsChangeCallbacks {
-					cb.cb()
+					cb.cb(added, removed)
 				}
 			case ManagerStoppingNotificationType:
 				for cb := range managerStoppingCallbacks {
@@ -183,6 +251,21 @@ func InitializeIPCClient(reader, writer, events *os.File) {
 				for cb := range updateProgressCallbacks {
 					cb.cb(dp)
 				}
+			case TransferRateNotificationType:
+				var tunnel string
+				err := decoder.Decode(&tunnel)
+				if err != nil || len(tunnel) == 0 {
+					continue
+				}
+				var rate TransferRate
+				err = decoder.Decode(&rate)
+				if err != nil {
+					continue
+				}
+				t := &Tunnel{tunnel}
+				for cb := range transferRateCallbacks {
+					cb.cb(t, rate)
+				}
 			}
 		}
 	}()
@@ -252,6 +335,169 @@ func (t *Tunnel) Start() (err error) {
 	if err != nil {
 		return
 	}
+	err = t.rpcDecodeStartError()
+	return
+}
+
+// StartConfirmed starts the tunnel, even if it is protected, with credentials of the user packed by
+// CredUIPromptForWindowsCredentials, which the manager verifies.
+func (t *Tunnel) StartConfirmed(authPackage uint32, authBuffer []byte) (err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(StartConfirmedMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(authPackage)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(authBuffer)
+	if err != nil {
+		return
+	}
+	err = t.rpcDecodeStartError()
+	return
+}
+
+// rpcDecodeStartError decodes the error of starting the tunnel, which is a *ConflictError if the
+// manager refused because of conflicts with running tunnels.
+func (t *Tunnel) rpcDecodeStartError() error {
+	var conflicts []Conflict
+	err := rpcDecoder.Decode(&conflicts)
+	if err != nil {
+		return err
+	}
+	err = rpcDecodeError()
+	if err != nil && len(conflicts) > 0 {
+		return &ConflictError{Tunnel: t.Name, Conflicts: conflicts}
+	}
+	return err
+}
+
+// Conflicts returns what activating the tunnel would conflict with among the running tunnels.
+func (t *Tunnel) Conflicts() (conflicts []Conflict, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(ConflictsMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&conflicts)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+func (t *Tunnel) Protected() (protected bool, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(ProtectedMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&protected)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+func (t *Tunnel) SetProtected(protected bool) (err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(SetProtectedMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(protected)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+func (t *Tunnel) Schedule() (schedule conf.Schedule, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(ScheduleMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&schedule)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+func (t *Tunnel) SetSchedule(schedule *conf.Schedule) (err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(SetScheduleMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(*schedule)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+// Usage returns the traffic of the tunnel during each of its recent days and months.
+func (t *Tunnel) Usage() (usage conf.Usage, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(UsageMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&usage)
+	if err != nil {
+		return
+	}
 	err = rpcDecodeError()
 	return
 }
@@ -318,11 +564,12 @@ func (t *Tunnel) Delete() (err error) {
 	return
 }
 
-func (t *Tunnel) State() (tunnelState TunnelState, err error) {
+// Rename renames the tunnel, keeping it running if it is.
+func (t *Tunnel) Rename(newName string) (err error) {
 	rpcMutex.Lock()
 	defer rpcMutex.Unlock()
 
-	err = rpcEncoder.Encode(StateMethodType)
+	err = rpcEncoder.Encode(RenameMethodType)
 	if err != nil {
 		return
 	}
@@ -330,7 +577,7 @@ func (t *Tunnel) State() (tunnelState TunnelState, err error) {
 	if err != nil {
 		return
 	}
-	err = rpcDecoder.Decode(&tunnelState)
+	err = rpcEncoder.Encode(newName)
 	if err != nil {
 		return
 	}
@@ -338,30 +585,46 @@ func (t *Tunnel) State() (tunnelState TunnelState, err error) {
 	return
 }
 
-func IPCClientGlobalState() (tunnelState TunnelState, err error) {
+// ReplaceConfig overwrites the configuration of the tunnel, and renames it to the name of config,
+// keeping everything else that belongs to it.
+func (t *Tunnel) ReplaceConfig(config *conf.Config) (err error) {
 	rpcMutex.Lock()
 	defer rpcMutex.Unlock()
 
-	err = rpcEncoder.Encode(GlobalStateMethodType)
+	err = rpcEncoder.Encode(ReplaceConfigMethodType)
 	if err != nil {
 		return
 	}
-	err = rpcDecoder.Decode(&tunnelState)
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(*config)
 	if err != nil {
 		return
 	}
+	err = rpcDecodeError()
 	return
 }
 
-func IPCClientNewTunnel(conf *conf.Config) (tunnel Tunnel, err error) {
+// Duplicate copies the configuration of the tunnel to a new tunnel, optionally with a new private key.
+func (t *Tunnel) Duplicate(newName string, newPrivateKey bool) (tunnel Tunnel, err error) {
 	rpcMutex.Lock()
 	defer rpcMutex.Unlock()
 
-	err = rpcEncoder.Encode(CreateMethodType)
+	err = rpcEncoder.Encode(DuplicateMethodType)
 	if err != nil {
 		return
 	}
-	err = rpcEncoder.Encode(*conf)
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(newName)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(newPrivateKey)
 	if err != nil {
 		return
 	}
@@ -373,15 +636,19 @@ func IPCClientNewTunnel(conf *conf.Config) (tunnel Tunnel, err error) {
 	return
 }
 
-func IPCClientTunnels() (tunnels []Tunnel, err error) {
+func (t *Tunnel) State() (tunnelState TunnelState, err error) {
 	rpcMutex.Lock()
 	defer rpcMutex.Unlock()
 
-	err = rpcEncoder.Encode(TunnelsMethodType)
+	err = rpcEncoder.Encode(StateMethodType)
 	if err != nil {
 		return
 	}
-	err = rpcDecoder.Decode(&tunnels)
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&tunnelState)
 	if err != nil {
 		return
 	}
@@ -389,19 +656,19 @@ func IPCClientTunnels() (tunnels []Tunnel, err error) {
 	return
 }
 
-func IPCClientQuit(stopTunnelsOnQuit bool) (alreadyQuit bool, err error) {
+func (t *Tunnel) KillSwitchActive() (active bool, err error) {
 	rpcMutex.Lock()
 	defer rpcMutex.Unlock()
 
-	err = rpcEncoder.Encode(QuitMethodType)
+	err = rpcEncoder.Encode(KillSwitchActiveMethodType)
 	if err != nil {
 		return
 	}
-	err = rpcEncoder.Encode(stopTunnelsOnQuit)
+	err = rpcEncoder.Encode(t.Name)
 	if err != nil {
 		return
 	}
-	err = rpcDecoder.Decode(&alreadyQuit)
+	err = rpcDecoder.Decode(&active)
 	if err != nil {
 		return
 	}
@@ -409,55 +676,710 @@ func IPCClientQuit(stopTunnelsOnQuit bool) (alreadyQuit bool, err error) {
 	return
 }
 
-func IPCClientUpdateState() (updateState UpdateState, err error) {
+func (t *Tunnel) SyncConfig(config *conf.Config) (err error) {
 	rpcMutex.Lock()
 	defer rpcMutex.Unlock()
 
-	err = rpcEncoder.Encode(UpdateStateMethodType)
+	err = rpcEncoder.Encode(SyncConfigMethodType)
 	if err != nil {
 		return
 	}
-	err = rpcDecoder.Decode(&updateState)
+	err = rpcEncoder.Encode(*config)
 	if err != nil {
 		return
 	}
+	err = rpcDecodeError()
 	return
 }
 
-func IPCClientUpdate() error {
+func (t *Tunnel) AddPeer(peer *conf.Peer, name string) (err error) {
 	rpcMutex.Lock()
 	defer rpcMutex.Unlock()
 
-	return rpcEncoder.Encode(UpdateMethodType)
+	err = rpcEncoder.Encode(AddPeerMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(*peer)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(name)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
 }
 
-func IPCClientRegisterTunnelChange(cb func(tunnel *Tunnel, state, globalState TunnelState, err error)) *TunnelChangeCallback {
-	s := &TunnelChangeCallback{cb}
-	tunnelChangeCallbacks[s] = true
-	return s
-}
+func (t *Tunnel) AddressPools() (pools conf.AddressPools, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
 
-func (cb *TunnelChangeCallback) Unregister() {
-	delete(tunnelChangeCallbacks, cb)
+	err = rpcEncoder.Encode(AddressPoolsMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&pools)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
 }
 
-func IPCClientRegisterTunnelsChange(cb func()) *TunnelsChangeCallback {
-	s := &TunnelsChangeCallback{cb}
-	tunnelsChangeCallbacks[s] = true
-	return s
-}
+func (t *Tunnel) PeerStatuses() (statuses []PeerStatus, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
 
-func (cb *TunnelsChangeCallback) Unregister() {
-	delete(tunnelsChangeCallbacks, cb)
+	err = rpcEncoder.Encode(PeerStatusesMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&statuses)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
 }
 
-func IPCClientRegisterManagerStopping(cb func()) *ManagerStoppingCallback {
-	s := &ManagerStoppingCallback{cb}
-	managerStoppingCallbacks[s] = true
-	return s
-}
+func (t *Tunnel) WatchdogStatus() (status WatchdogStatus, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
 
-func (cb *ManagerStoppingCallback) Unregister() {
+	err = rpcEncoder.Encode(WatchdogStatusMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&status)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+func (t *Tunnel) SetAddressPools(pools *conf.AddressPools) (err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(SetAddressPoolsMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(*pools)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+func (t *Tunnel) ActivationRules() (rules conf.ActivationRules, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(ActivationRulesMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&rules)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+func (t *Tunnel) SetActivationRules(rules *conf.ActivationRules) (err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(SetActivationRulesMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(*rules)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+func (t *Tunnel) Profiles() (profiles conf.Profiles, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(ProfilesMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&profiles)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+func (t *Tunnel) SetProfiles(profiles *conf.Profiles) (err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(SetProfilesMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(*profiles)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+// SelectProfile picks a profile by hand, or goes back to picking it by network if profileName is empty.
+func (t *Tunnel) SelectProfile(profileName string) (err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(SelectProfileMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(profileName)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+func (t *Tunnel) AdapterLogLevel() (level conf.AdapterLogLevel, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(AdapterLogLevelMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&level)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+func (t *Tunnel) SetAdapterLogLevel(level conf.AdapterLogLevel) (err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(SetAdapterLogLevelMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(level)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+func (t *Tunnel) Pitfalls() (pitfalls []conf.Pitfall, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(PitfallsMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(t.Name)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&pitfalls)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+func IPCClientGlobalState() (tunnelState TunnelState, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(GlobalStateMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&tunnelState)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func IPCClientDiagnostics() (bundle []byte, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(DiagnosticsMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&bundle)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+func IPCClientTunnelGroups() (groups conf.TunnelGroups, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(TunnelGroupsMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&groups)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+func IPCClientSetTunnelGroups(groups *conf.TunnelGroups) (err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(SetTunnelGroupsMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(*groups)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+// IPCClientStartGroup starts the tunnels of a group one after the other, which happens after it returns.
+func IPCClientStartGroup(groupName string) (err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(StartGroupMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(groupName)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+// IPCClientStopGroup stops the tunnels of a group in reverse order, which happens after it returns.
+func IPCClientStopGroup(groupName string) (err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(StopGroupMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(groupName)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+func IPCClientTunnelStats() (stats []TunnelStats, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(TunnelStatsMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&stats)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+func IPCClientNewTunnel(conf *conf.Config) (tunnel Tunnel, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(CreateMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(*conf)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&tunnel)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+func IPCClientTunnels() (tunnels []Tunnel, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(TunnelsMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&tunnels)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+func IPCClientQuit(stopTunnelsOnQuit bool) (alreadyQuit bool, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(QuitMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(stopTunnelsOnQuit)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&alreadyQuit)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+func IPCClientUpdateState() (updateState UpdateState, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(UpdateStateMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&updateState)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func IPCClientUpdate() error {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	return rpcEncoder.Encode(UpdateMethodType)
+}
+
+// IPCClientRollbackVersion returns the version that a rollback would reinstall, or an empty
+// string if there is none.
+func IPCClientRollbackVersion() (version string, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(RollbackVersionMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&version)
+	return
+}
+
+// IPCClientRollback starts reinstalling the previous version, whose progress is reported as
+// that of an update.
+func IPCClientRollback() error {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	return rpcEncoder.Encode(RollbackMethodType)
+}
+
+// IPCClientDriverInfo returns the version and features of the driver, and the adapters of the
+// running tunnels.
+func IPCClientDriverInfo() (info DriverInfo, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(DriverInfoMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&info)
+	return
+}
+
+// IPCClientAdapterDiagnosis returns the likely causes of a failure to create an adapter.
+func IPCClientAdapterDiagnosis() (diagnosis driver.AdapterDiagnosis, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(AdapterDiagnosisMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&diagnosis)
+	return
+}
+
+// IPCClientUpdateChannel returns the channel that the updater follows, and whether it is set by policy.
+func IPCClientUpdateChannel() (channel string, byPolicy bool, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(UpdateChannelMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&channel)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&byPolicy)
+	return
+}
+
+func IPCClientSetUpdateChannel(channel string) (err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(SetUpdateChannelMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(channel)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+// IPCClientSettings returns the machine-wide settings.
+func IPCClientSettings() (settings conf.Settings, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(SettingsMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&settings)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+func IPCClientSetSettings(settings *conf.Settings) (err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(SetSettingsMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(*settings)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+// IPCClientUpdateProxy returns the proxy that the updater uses, without its password, which is
+// empty if the system's proxy settings are used, and whether its server is set by policy.
+func IPCClientUpdateProxy() (proxy conf.UpdateProxy, byPolicy bool, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(UpdateProxyMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&proxy)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&byPolicy)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+// IPCClientSetUpdateProxy changes the proxy that the updater uses, keeping the stored password
+// if proxy has none and the username is unchanged, or goes back to the system's proxy settings
+// if proxy is empty.
+func IPCClientSetUpdateProxy(proxy conf.UpdateProxy) (err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(SetUpdateProxyMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(proxy)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+// IPCClientExportArchive returns a zip of the configurations of all tunnels, encrypted with
+// passphrase, for importing on another computer.
+func IPCClientExportArchive(passphrase string) (archive []byte, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(ExportArchiveMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(passphrase)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&archive)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+// IPCClientImportArchive creates the tunnels of an archive made by IPCClientExportArchive,
+// renaming those whose names are taken, and returns the names of the tunnels that it created.
+func IPCClientImportArchive(archive []byte, passphrase string) (created []string, err error) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+
+	err = rpcEncoder.Encode(ImportArchiveMethodType)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(archive)
+	if err != nil {
+		return
+	}
+	err = rpcEncoder.Encode(passphrase)
+	if err != nil {
+		return
+	}
+	err = rpcDecoder.Decode(&created)
+	if err != nil {
+		return
+	}
+	err = rpcDecodeError()
+	return
+}
+
+func IPCClientRegisterTunnelChange(cb func(tunnel *Tunnel, state, globalState TunnelState, err error)) *TunnelChangeCallback {
+	s := &TunnelChangeCallback{cb}
+	tunnelChangeCallbacks[s] = true
+	return s
+}
+
+func (cb *TunnelChangeCallback) Unregister() {
+	delete(tunnelChangeCallbacks, cb)
+}
+
+func IPCClientRegisterTunnelsChange(cb func(added, removed []Tunnel)) *TunnelsChangeCallback {
+	s := &TunnelsChangeCallback{cb}
+	tunnelsChangeCallbacks[s] = true
+	return s
+}
+
+func (cb *TunnelsChangeCallback) Unregister() {
+	delete(tunnelsChangeCallbacks, cb)
+}
+
+func IPCClientRegisterManagerStopping(cb func()) *ManagerStoppingCallback {
+	s := &ManagerStoppingCallback{cb}
+	managerStoppingCallbacks[s] = true
+	return s
+}
+
+func (cb *ManagerStoppingCallback) Unregister() {
 	delete(managerStoppingCallbacks, cb)
 }
 
@@ -480,3 +1402,13 @@ func IPCClientRegisterUpdateProgress(cb func(dp updater.DownloadProgress)) *Upda
 func (cb *UpdateProgressCallback) Unregister() {
 	delete(updateProgressCallbacks, cb)
 }
+
+func IPCClientRegisterTransferRate(cb func(tunnel *Tunnel, rate TransferRate)) *TransferRateCallback {
+	s := &TransferRateCallback{cb}
+	transferRateCallbacks[s] = true
+	return s
+}
+
+func (cb *TransferRateCallback) Unregister() {
+	delete(transferRateCallbacks, cb)
+}
@@ -0,0 +1,135 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+// startIPCHarness connects an in-process IPC server to this package's IPC client over
+// anonymous pipes, the same way the manager service wires up a UI process. A zero
+// elevatedToken gives the client the view of a limited user, who is the user of this process. The pipes are torn down
+// when the test finishes, which ends the server's connection loop.
+func startIPCHarness(t *testing.T, elevatedToken windows.Token) {
+	t.Helper()
+
+	var files []*os.File
+	pipe := func() (*os.File, *os.File) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatalf("Unable to create pipe: %v", err)
+		}
+		files = append(files, r, w)
+		return r, w
+	}
+	serverReader, clientWriter := pipe()
+	clientReader, serverWriter := pipe()
+	eventReader, eventWriter := pipe()
+	t.Cleanup(func() {
+		for _, f := range files {
+			f.Close()
+		}
+	})
+
+	managerServicesLock.RLock()
+	before := len(managerServices)
+	managerServicesLock.RUnlock()
+
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		t.Fatalf("Unable to get user of process: %v", err)
+	}
+	IPCServerListen(serverReader, serverWriter, eventWriter, elevatedToken, user.User.Sid)
+	InitializeIPCClient(clientReader, clientWriter, eventReader)
+
+	// The server registers itself for notifications asynchronously.
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		managerServicesLock.RLock()
+		registered := len(managerServices) > before
+		managerServicesLock.RUnlock()
+		if registered {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("IPC server did not register in time")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+// startAutomationHarness serves the automation protocol in process over anonymous pipes, as the
+// automation pipe does for a client with elevatedToken, and returns a function that sends a
+// request and returns the response, like AutomationCall.
+func startAutomationHarness(t *testing.T, elevatedToken windows.Token) func(AutomationRequest) (*AutomationResponse, error) {
+	t.Helper()
+
+	serverReader, clientWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Unable to create pipe: %v", err)
+	}
+	clientReader, serverWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Unable to create pipe: %v", err)
+	}
+	t.Cleanup(func() {
+		clientWriter.Close()
+		serverReader.Close()
+		serverWriter.Close()
+		clientReader.Close()
+	})
+	go serveAutomationRequests(serverReader, serverWriter, elevatedToken)
+
+	encoder := json.NewEncoder(clientWriter)
+	decoder := json.NewDecoder(clientReader)
+	return func(request AutomationRequest) (*AutomationResponse, error) {
+		request.Version = AutomationProtocolVersion
+		err := encoder.Encode(&request)
+		if err != nil {
+			return nil, err
+		}
+		var response AutomationResponse
+		err = decoder.Decode(&response)
+		if err != nil {
+			return nil, err
+		}
+		if len(response.Error) > 0 {
+			return &response, errors.New(response.Error)
+		}
+		return &response, nil
+	}
+}
+
+// waitFor returns the next value from c, failing the test if none arrives in time.
+func waitFor[T any](t *testing.T, c <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-c:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for notification")
+	}
+	var zero T
+	return zero
+}
+
+func TestMain(m *testing.M) {
+	root, err := os.MkdirTemp("", "wireguard-ipc-test")
+	if err != nil {
+		panic(err)
+	}
+	conf.PresetRootDirectory(root)
+	code := m.Run()
+	os.RemoveAll(root)
+	os.Exit(code)
+}
@@ -8,10 +8,12 @@ package manager
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"reflect"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -20,6 +22,9 @@ import (
 	"golang.org/x/sys/windows/svc"
 
 	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/driver"
+	"golang.zx2c4.com/wireguard/windows/services"
+	"golang.zx2c4.com/wireguard/windows/tunnel/firewall"
 	"golang.zx2c4.com/wireguard/windows/updater"
 )
 
@@ -27,13 +32,51 @@ var (
 	managerServices     = make(map[*ManagerService]bool)
 	managerServicesLock sync.RWMutex
 	haveQuit            uint32
-	quitManagersChan    = make(chan struct{}, 1)
+	quitManagersChan    = make(chan bool, 1) // true to uninstall the manager service after stopping
+	knownTunnels        map[string]bool
+	knownTunnelsLock    sync.Mutex
 )
 
+// Tunnel creation is rate limited, so that a runaway or malicious provisioning source cannot
+// flood the configuration store, or the UIs that are notified of every change to it. The largest
+// import allowed fits into a single interval.
+const (
+	maxCreatesPerInterval = conf.MaxTunnelsPerImport
+	createRateInterval    = time.Minute
+)
+
+var (
+	recentCreates     []time.Time
+	recentCreatesLock sync.Mutex
+)
+
+// Group Policy can forbid changes to tunnels, to administrators as well.
+var (
+	errConfigEditingDisabled  = errors.New("Editing tunnels is disabled by your organization")
+	errTunnelDeletionDisabled = errors.New("Removing tunnels is disabled by your organization")
+)
+
+func createRateLimitExceeded() bool {
+	recentCreatesLock.Lock()
+	defer recentCreatesLock.Unlock()
+	now := time.Now()
+	i := 0
+	for i < len(recentCreates) && now.Sub(recentCreates[i]) >= createRateInterval {
+		i++
+	}
+	recentCreates = recentCreates[i:]
+	if len(recentCreates) >= maxCreatesPerInterval {
+		return true
+	}
+	recentCreates = append(recentCreates, now)
+	return false
+}
+
 type ManagerService struct {
 	events        *os.File
 	eventLock     sync.Mutex
 	elevatedToken windows.Token
+	userSid       *windows.SID // The user of the UI session, or nil if not serving one.
 }
 
 func (s *ManagerService) StoredConfig(tunnelName string) (*conf.Config, error) {
@@ -48,6 +91,17 @@ func (s *ManagerService) StoredConfig(tunnelName string) (*conf.Config, error) {
 }
 
 func (s *ManagerService) RuntimeConfig(tunnelName string) (*conf.Config, error) {
+	conf, err := runtimeConfig(tunnelName)
+	if err != nil {
+		return nil, err
+	}
+	if s.elevatedToken == 0 {
+		conf.Redact()
+	}
+	return conf, nil
+}
+
+func runtimeConfig(tunnelName string) (*conf.Config, error) {
 	storedConfig, err := conf.LoadFromName(tunnelName)
 	if err != nil {
 		return nil, err
@@ -64,13 +118,14 @@ func (s *ManagerService) RuntimeConfig(tunnelName string) (*conf.Config, error)
 	}
 	conf := conf.FromDriverConfiguration(runtimeConfig, storedConfig)
 	driverAdapter.Unlock()
-	if s.elevatedToken == 0 {
-		conf.Redact()
-	}
 	return conf, nil
 }
 
 func (s *ManagerService) Start(tunnelName string) error {
+	err := s.checkProtected(tunnelName)
+	if err != nil {
+		return err
+	}
 	c, err := conf.LoadFromName(tunnelName)
 	if err != nil {
 		return err
@@ -96,6 +151,18 @@ func (s *ManagerService) Start(tunnelName string) error {
 	if len(inTransition) != 0 {
 		return fmt.Errorf("Please allow the tunnel ‘%s’ to finish activating", inTransition)
 	}
+	err = checkConflicts(c, tt)
+	if err != nil {
+		return err
+	}
+	err = resolveListenPortConflict(c, tt)
+	if err != nil {
+		return err
+	}
+	_, err = selectProfile(tunnelName, nil)
+	if err != nil {
+		log.Printf("[%s] Unable to select profile: %v", tunnelName, err)
+	}
 
 	// Stop those intersecting tunnels asynchronously.
 	go func() {
@@ -150,15 +217,148 @@ func (s *ManagerService) WaitForStop(tunnelName string) error {
 	}
 }
 
+// restartTunnel stops a tunnel, waits for its service to go away, and starts it again.
+func restartTunnel(tunnelName string) error {
+	s := &ManagerService{}
+	err := s.Stop(tunnelName)
+	if err == nil {
+		err = s.WaitForStop(tunnelName)
+	}
+	if err == nil {
+		err = s.Start(tunnelName)
+	}
+	return err
+}
+
 func (s *ManagerService) Delete(tunnelName string) error {
 	if s.elevatedToken == 0 {
 		return windows.ERROR_ACCESS_DENIED
 	}
+	if conf.LoadPolicies().DisableTunnelDeletion {
+		return errTunnelDeletionDisabled
+	}
 	err := s.Stop(tunnelName)
 	if err != nil {
 		return err
 	}
-	return conf.DeleteName(tunnelName)
+	err = conf.DeleteActivationRules(tunnelName)
+	if err != nil {
+		log.Printf("[%s] Unable to delete activation rules: %v", tunnelName, err)
+	}
+	err = conf.DeletePitfalls(tunnelName)
+	if err != nil {
+		log.Printf("[%s] Unable to delete pitfalls: %v", tunnelName, err)
+	}
+	err = conf.DeleteAddressPools(tunnelName)
+	if err != nil {
+		log.Printf("[%s] Unable to delete address pools: %v", tunnelName, err)
+	}
+	err = conf.DeleteAdapterLogLevel(tunnelName)
+	if err != nil {
+		log.Printf("[%s] Unable to delete adapter log level: %v", tunnelName, err)
+	}
+	err = conf.DeleteListenPortOverride(tunnelName)
+	if err != nil {
+		log.Printf("[%s] Unable to delete listen port override: %v", tunnelName, err)
+	}
+	err = conf.DeleteKeyRotationState(tunnelName)
+	if err != nil {
+		log.Printf("[%s] Unable to delete key rotation state: %v", tunnelName, err)
+	}
+	err = conf.DeletePresharedKeyRotationState(tunnelName)
+	if err != nil {
+		log.Printf("[%s] Unable to delete preshared key rotation state: %v", tunnelName, err)
+	}
+	err = conf.DeleteProfiles(tunnelName)
+	if err != nil {
+		log.Printf("[%s] Unable to delete profiles: %v", tunnelName, err)
+	}
+	err = conf.DeleteSchedule(tunnelName)
+	if err != nil {
+		log.Printf("[%s] Unable to delete schedule: %v", tunnelName, err)
+	}
+	err = deleteUsage(tunnelName)
+	if err != nil {
+		log.Printf("[%s] Unable to delete usage: %v", tunnelName, err)
+	}
+	err = conf.DeletePeerSourceState(tunnelName)
+	if err != nil {
+		log.Printf("[%s] Unable to delete peer source state: %v", tunnelName, err)
+	}
+	err = removeFromTunnelGroups(tunnelName)
+	if err != nil {
+		log.Printf("[%s] Unable to remove from groups: %v", tunnelName, err)
+	}
+	err = removeProtection(tunnelName)
+	if err != nil {
+		log.Printf("[%s] Unable to remove protection: %v", tunnelName, err)
+	}
+	err = conf.DeleteName(tunnelName)
+	if err != nil {
+		return err
+	}
+	noteConfigChange(tunnelName)
+	return nil
+}
+
+func (s *ManagerService) ActivationRules(tunnelName string) (*conf.ActivationRules, error) {
+	return conf.LoadActivationRules(tunnelName)
+}
+
+// Pitfalls returns what the service of the tunnel found wrong with the system when it last started.
+func (s *ManagerService) Pitfalls(tunnelName string) ([]conf.Pitfall, error) {
+	return conf.LoadPitfalls(tunnelName)
+}
+
+func (s *ManagerService) SetActivationRules(tunnelName string, rules *conf.ActivationRules) error {
+	if s.elevatedToken == 0 {
+		return windows.ERROR_ACCESS_DENIED
+	}
+	_, err := conf.LoadFromName(tunnelName)
+	if err != nil {
+		return err
+	}
+	return conf.SaveActivationRules(tunnelName, rules)
+}
+
+func (s *ManagerService) AdapterLogLevel(tunnelName string) (conf.AdapterLogLevel, error) {
+	return conf.LoadAdapterLogLevel(tunnelName)
+}
+
+// SetAdapterLogLevel saves how much the driver logs about a tunnel and, if the tunnel is running,
+// has its service apply the new level right away.
+func (s *ManagerService) SetAdapterLogLevel(tunnelName string, level conf.AdapterLogLevel) error {
+	if s.elevatedToken == 0 {
+		return windows.ERROR_ACCESS_DENIED
+	}
+	_, err := conf.LoadFromName(tunnelName)
+	if err != nil {
+		return err
+	}
+	err = conf.SaveAdapterLogLevel(tunnelName, level)
+	if err != nil {
+		return err
+	}
+	log.Printf("[%s] Adapter logging set to %s", tunnelName, level)
+	state, err := s.State(tunnelName)
+	if err != nil || state != TunnelStarted {
+		return err
+	}
+	serviceName, err := conf.ServiceNameOfTunnel(tunnelName)
+	if err != nil {
+		return err
+	}
+	m, err := serviceManager()
+	if err != nil {
+		return err
+	}
+	service, err := m.OpenService(serviceName)
+	if err != nil {
+		return err
+	}
+	defer service.Close()
+	_, err = service.Control(services.ControlReloadAdapterLog)
+	return err
 }
 
 func (s *ManagerService) State(tunnelName string) (TunnelState, error) {
@@ -193,6 +393,176 @@ func (s *ManagerService) State(tunnelName string) (TunnelState, error) {
 	}
 }
 
+func (s *ManagerService) KillSwitchActive(tunnelName string) (bool, error) {
+	return firewall.KillSwitchActive(tunnelName)
+}
+
+// SyncConfig brings the peers of a running tunnel in line with tunnelConfig, without restarting
+// it, in the manner of wg syncconf. The stored configuration is left as it is.
+func (s *ManagerService) SyncConfig(tunnelConfig *conf.Config) error {
+	if s.elevatedToken == 0 {
+		return windows.ERROR_ACCESS_DENIED
+	}
+	if conf.LoadPolicies().DisableConfigEditing {
+		return errConfigEditingDisabled
+	}
+	storedConfig, err := conf.LoadFromName(tunnelConfig.Name)
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(storedConfig.Interface, tunnelConfig.Interface) {
+		return errors.New("Changes to the [Interface] section require restarting the tunnel")
+	}
+	state, err := s.State(tunnelConfig.Name)
+	if err != nil {
+		return err
+	}
+	if state != TunnelStarted {
+		return errors.New("Tunnel is not running")
+	}
+	return syncPeers(tunnelConfig, storedConfig)
+}
+
+// syncPeers resolves the endpoints of tunnelConfig and brings the peers of its running adapter
+// in line with it.
+func syncPeers(tunnelConfig, storedConfig *conf.Config) error {
+	err := tunnelConfig.ResolveEndpoints()
+	if err != nil {
+		return err
+	}
+	driverAdapter, err := findDriverAdapter(tunnelConfig.Name)
+	if err != nil {
+		return err
+	}
+	runtimeConfig, err := driverAdapter.Configuration()
+	if err != nil {
+		driverAdapter.Unlock()
+		releaseDriverAdapter(tunnelConfig.Name)
+		return err
+	}
+	err = driverAdapter.SetConfiguration(peerSyncConfiguration(tunnelConfig, storedConfig, conf.FromDriverConfiguration(runtimeConfig, storedConfig)))
+	driverAdapter.Unlock()
+	if err != nil {
+		releaseDriverAdapter(tunnelConfig.Name)
+		return err
+	}
+	log.Printf("[%s] Synchronized %d peers from new configuration", tunnelConfig.Name, len(tunnelConfig.Peers))
+	return nil
+}
+
+// peerSyncConfiguration returns the driver configuration that brings the peers of running in line
+// with tunnelConfig. The tunnel service adds the peers of a peer source to the adapter by itself,
+// so for a tunnel with one, only the peers of tunnelConfig or storedConfig are removed, and the
+// others are left for the tunnel service to manage.
+func peerSyncConfiguration(tunnelConfig, storedConfig, running *conf.Config) (*driver.Interface, uint32) {
+	if len(storedConfig.Interface.PeerSource) == 0 {
+		return tunnelConfig.ToDriverSyncConfiguration(running)
+	}
+	configured := make(map[conf.Key]bool, len(tunnelConfig.Peers)+len(storedConfig.Peers))
+	for _, config := range []*conf.Config{tunnelConfig, storedConfig} {
+		for i := range config.Peers {
+			configured[config.Peers[i].PublicKey] = true
+		}
+	}
+	managed := *running
+	managed.Peers = nil
+	for i := range running.Peers {
+		if configured[running.Peers[i].PublicKey] {
+			managed.Peers = append(managed.Peers, running.Peers[i])
+		}
+	}
+	return tunnelConfig.ToDriverSyncConfiguration(&managed)
+}
+
+// AddPeer appends a peer to the stored configuration of a tunnel and, if the tunnel is running,
+// to its adapter as well, without restarting it. Its allowed IPs that fall into the address pools
+// of the tunnel are recorded as allocated to it, under name, which is its alias too unless it has one.
+func (s *ManagerService) AddPeer(tunnelName string, peer *conf.Peer, name string) error {
+	if s.elevatedToken == 0 {
+		return windows.ERROR_ACCESS_DENIED
+	}
+	if conf.LoadPolicies().DisableConfigEditing {
+		return errConfigEditingDisabled
+	}
+	config, err := conf.LoadFromName(tunnelName)
+	if err != nil {
+		return err
+	}
+	if len(peer.Name) == 0 {
+		peer.Name = name
+	}
+	for i := range config.Peers {
+		if config.Peers[i].PublicKey == peer.PublicKey {
+			return errors.New("Tunnel already has a peer with this public key")
+		}
+		for _, a := range config.Peers[i].AllowedIPs {
+			for _, b := range peer.AllowedIPs {
+				if a.Overlaps(b) && a.Bits() >= b.Bits() {
+					return fmt.Errorf("Allowed IP %s is already routed to another peer", a.String())
+				}
+			}
+		}
+	}
+	pools, err := conf.LoadAddressPools(tunnelName)
+	if err != nil {
+		return err
+	}
+	pools.Prune(config)
+	err = pools.Allocate(config, &peer.PublicKey, name, peer.AllowedIPs)
+	if err != nil {
+		return err
+	}
+	config.Peers = append(config.Peers, *peer)
+	if len(config.ToWgQuick()) > conf.MaxConfigSize {
+		return errors.New("Configuration is too large")
+	}
+	err = config.Save(true)
+	if err != nil {
+		return err
+	}
+	noteConfigChange(tunnelName)
+	err = conf.SaveAddressPools(tunnelName, pools)
+	if err != nil {
+		log.Printf("[%s] Unable to save address pools: %v", tunnelName, err)
+	}
+	log.Printf("[%s] Added peer %s", tunnelName, peer.DisplayName())
+	state, err := s.State(tunnelName)
+	if err != nil || state != TunnelStarted {
+		return err
+	}
+	return s.SyncConfig(config)
+}
+
+// AddressPools returns the address pools of a tunnel, without the allocations of peers that have
+// since been removed from it. Limited clients do not receive the public keys of the allocations.
+func (s *ManagerService) AddressPools(tunnelName string) (*conf.AddressPools, error) {
+	config, err := conf.LoadFromName(tunnelName)
+	if err != nil {
+		return nil, err
+	}
+	pools, err := conf.LoadAddressPools(tunnelName)
+	if err != nil {
+		return nil, err
+	}
+	pools.Prune(config)
+	if s.elevatedToken == 0 {
+		pools.Redact()
+	}
+	return pools, nil
+}
+
+func (s *ManagerService) SetAddressPools(tunnelName string, pools *conf.AddressPools) error {
+	if s.elevatedToken == 0 {
+		return windows.ERROR_ACCESS_DENIED
+	}
+	config, err := conf.LoadFromName(tunnelName)
+	if err != nil {
+		return err
+	}
+	pools.Prune(config)
+	return conf.SaveAddressPools(tunnelName, pools)
+}
+
 func (s *ManagerService) GlobalState() TunnelState {
 	return trackedTunnelsGlobalState()
 }
@@ -201,10 +571,20 @@ func (s *ManagerService) Create(tunnelConfig *conf.Config) (*Tunnel, error) {
 	if s.elevatedToken == 0 {
 		return nil, windows.ERROR_ACCESS_DENIED
 	}
+	if conf.LoadPolicies().DisableConfigEditing {
+		return nil, errConfigEditingDisabled
+	}
+	if len(tunnelConfig.ToWgQuick()) > conf.MaxConfigSize {
+		return nil, errors.New("Configuration is too large")
+	}
+	if createRateLimitExceeded() {
+		return nil, fmt.Errorf("Too many tunnels were imported in the last %v; please try again later", createRateInterval)
+	}
 	err := tunnelConfig.Save(true)
 	if err != nil {
 		return nil, err
 	}
+	noteConfigChange(tunnelConfig.Name)
 	return &Tunnel{tunnelConfig.Name}, nil
 	// TODO: handle already existing situation
 	// TODO: handle already running and existing situation
@@ -240,19 +620,47 @@ func (s *ManagerService) Quit(stopTunnelsOnQuit bool) (alreadyQuit bool, err err
 	managerServicesLock.Unlock()
 
 	if stopTunnelsOnQuit {
-		names, err := conf.ListConfigNames()
+		err = stopAllTunnels()
 		if err != nil {
 			return false, err
 		}
-		for _, name := range names {
-			UninstallTunnel(name)
-		}
 	}
 
-	quitManagersChan <- struct{}{}
+	quitManagersChan <- true
+	return false, nil
+}
+
+// Shutdown stops the manager service, without uninstalling it, so that it starts again on the
+// next boot. Running tunnels are deactivated if stopTunnels is set, and otherwise keep running.
+func (s *ManagerService) Shutdown(stopTunnels bool) (alreadyQuit bool, err error) {
+	if s.elevatedToken == 0 {
+		return false, windows.ERROR_ACCESS_DENIED
+	}
+	if !atomic.CompareAndSwapUint32(&haveQuit, 0, 1) {
+		return true, nil
+	}
+	if stopTunnels {
+		err = stopAllTunnels()
+		if err != nil {
+			return false, err
+		}
+	}
+	log.Printf("Shutting down manager (stopping tunnels: %v)", stopTunnels)
+	quitManagersChan <- false
 	return false, nil
 }
 
+func stopAllTunnels() error {
+	names, err := conf.ListConfigNames()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		UninstallTunnel(name)
+	}
+	return nil
+}
+
 func (s *ManagerService) UpdateState() UpdateState {
 	return updateState
 }
@@ -273,25 +681,128 @@ func (s *ManagerService) Update() {
 	}()
 }
 
-func (s *ManagerService) ServeConn(reader io.Reader, writer io.Writer) {
-	decoder := gob.NewDecoder(reader)
-	encoder := gob.NewEncoder(writer)
-	for {
-		var methodType MethodType
-		err := decoder.Decode(&methodType)
-		if err != nil {
-			return
-		}
-		switch methodType {
-		case StoredConfigMethodType:
-			var tunnelName string
-			err := decoder.Decode(&tunnelName)
-			if err != nil {
-				return
+// RollbackVersion returns the version that a rollback would reinstall, or an empty string.
+func (s *ManagerService) RollbackVersion() string {
+	if s.elevatedToken == 0 {
+		return ""
+	}
+	return updater.RollbackVersion()
+}
+
+// Rollback reinstalls the version before the running one, reporting progress as an update does.
+func (s *ManagerService) Rollback() {
+	if s.elevatedToken == 0 {
+		return
+	}
+	log.Println("Rolling back to the previous version")
+	progress := updater.Rollback(uintptr(s.elevatedToken))
+	go func() {
+		for {
+			dp := <-progress
+			IPCServerNotifyUpdateProgress(dp)
+			if dp.Error != nil {
+				log.Printf("Unable to roll back: %v", dp.Error)
 			}
-			config, retErr := s.StoredConfig(tunnelName)
-			if config == nil {
-				config = &conf.Config{}
+			if dp.Complete || dp.Error != nil {
+				return
+			}
+		}
+	}()
+}
+
+func (s *ManagerService) UpdateChannel() (string, bool) {
+	return conf.UpdateChannel()
+}
+
+// SetUpdateChannel changes the channel that the updater follows, unless it is set by policy.
+func (s *ManagerService) SetUpdateChannel(channel string) error {
+	if s.elevatedToken == 0 {
+		return windows.ERROR_ACCESS_DENIED
+	}
+	if !conf.UpdateChannelIsValid(channel) {
+		return errors.New("Update channel is not valid")
+	}
+	if _, byPolicy := conf.UpdateChannel(); byPolicy {
+		return errors.New("Update channel is set by policy")
+	}
+	settings, err := conf.LoadUpdateSettings()
+	if err != nil {
+		return err
+	}
+	settings.Channel = channel
+	err = conf.SaveUpdateSettings(settings)
+	if err != nil {
+		return err
+	}
+	log.Printf("Following the %s update channel", channel)
+	return nil
+}
+
+// UpdateProxy returns the proxy that the updater uses, without its password.
+func (s *ManagerService) UpdateProxy() (conf.UpdateProxy, bool, error) {
+	if s.elevatedToken == 0 {
+		return conf.UpdateProxy{}, false, windows.ERROR_ACCESS_DENIED
+	}
+	proxy, byPolicy := conf.UpdateProxySettings()
+	if proxy == nil {
+		return conf.UpdateProxy{}, byPolicy, nil
+	}
+	redacted := *proxy
+	redacted.Password = ""
+	return redacted, byPolicy, nil
+}
+
+// SetUpdateProxy changes the proxy that the updater uses, or removes it if empty. A missing
+// password is taken from the stored proxy if the username is the same.
+func (s *ManagerService) SetUpdateProxy(proxy conf.UpdateProxy) error {
+	if s.elevatedToken == 0 {
+		return windows.ERROR_ACCESS_DENIED
+	}
+	settings, err := conf.LoadUpdateSettings()
+	if err != nil {
+		return err
+	}
+	if proxy == (conf.UpdateProxy{}) {
+		settings.Proxy = nil
+	} else {
+		if len(proxy.Password) == 0 && settings.Proxy != nil && settings.Proxy.Username == proxy.Username {
+			proxy.Password = settings.Proxy.Password
+		}
+		settings.Proxy = &proxy
+	}
+	err = conf.SaveUpdateSettings(settings)
+	if err != nil {
+		return err
+	}
+	if settings.Proxy == nil {
+		log.Println("Updater uses the system proxy settings")
+	} else if server := settings.Proxy.Server(); len(server) > 0 {
+		log.Printf("Updater uses proxy %s", server)
+	} else {
+		log.Println("Updater uses the system proxy settings with credentials")
+	}
+	return nil
+}
+
+func (s *ManagerService) ServeConn(reader io.Reader, writer io.Writer) {
+	decoder := gob.NewDecoder(reader)
+	encoder := gob.NewEncoder(writer)
+	for {
+		var methodType MethodType
+		err := decoder.Decode(&methodType)
+		if err != nil {
+			return
+		}
+		switch methodType {
+		case StoredConfigMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			config, retErr := s.StoredConfig(tunnelName)
+			if config == nil {
+				config = &conf.Config{}
 			}
 			err = encoder.Encode(*config)
 			if err != nil {
@@ -301,17 +812,318 @@ func (s *ManagerService) ServeConn(reader io.Reader, writer io.Writer) {
 			if err != nil {
 				return
 			}
-		case RuntimeConfigMethodType:
+		case RuntimeConfigMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			config, retErr := s.RuntimeConfig(tunnelName)
+			if config == nil {
+				config = &conf.Config{}
+			}
+			err = encoder.Encode(*config)
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case StartMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			retErr := s.Start(tunnelName)
+			err = encoder.Encode(conflictsOfError(retErr))
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case StopMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			retErr := s.Stop(tunnelName)
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case WaitForStopMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			retErr := s.WaitForStop(tunnelName)
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case DeleteMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			retErr := s.Delete(tunnelName)
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case RenameMethodType:
+			var tunnelName, newName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			err = decoder.Decode(&newName)
+			if err != nil {
+				return
+			}
+			retErr := s.Rename(tunnelName, newName)
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case ReplaceConfigMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			var config conf.Config
+			err = decoder.Decode(&config)
+			if err != nil {
+				return
+			}
+			retErr := s.ReplaceConfig(tunnelName, &config)
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case DuplicateMethodType:
+			var tunnelName, newName string
+			var newPrivateKey bool
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			err = decoder.Decode(&newName)
+			if err != nil {
+				return
+			}
+			err = decoder.Decode(&newPrivateKey)
+			if err != nil {
+				return
+			}
+			tunnel, retErr := s.Duplicate(tunnelName, newName, newPrivateKey)
+			if tunnel == nil {
+				tunnel = &Tunnel{}
+			}
+			err = encoder.Encode(tunnel)
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case StateMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			state, retErr := s.State(tunnelName)
+			err = encoder.Encode(state)
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case KillSwitchActiveMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			active, retErr := s.KillSwitchActive(tunnelName)
+			err = encoder.Encode(active)
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case ActivationRulesMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			rules, retErr := s.ActivationRules(tunnelName)
+			if rules == nil {
+				rules = &conf.ActivationRules{}
+			}
+			err = encoder.Encode(rules)
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case SetActivationRulesMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			var rules conf.ActivationRules
+			err = decoder.Decode(&rules)
+			if err != nil {
+				return
+			}
+			retErr := s.SetActivationRules(tunnelName, &rules)
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case ProfilesMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			profiles, retErr := s.Profiles(tunnelName)
+			if profiles == nil {
+				profiles = &conf.Profiles{}
+			}
+			err = encoder.Encode(profiles)
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case SetProfilesMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			var profiles conf.Profiles
+			err = decoder.Decode(&profiles)
+			if err != nil {
+				return
+			}
+			retErr := s.SetProfiles(tunnelName, &profiles)
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case SelectProfileMethodType:
+			var tunnelName, profileName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			err = decoder.Decode(&profileName)
+			if err != nil {
+				return
+			}
+			retErr := s.SelectProfile(tunnelName, profileName)
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case AdapterLogLevelMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			level, retErr := s.AdapterLogLevel(tunnelName)
+			err = encoder.Encode(level)
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case SetAdapterLogLevelMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			var level conf.AdapterLogLevel
+			err = decoder.Decode(&level)
+			if err != nil {
+				return
+			}
+			retErr := s.SetAdapterLogLevel(tunnelName, level)
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case PitfallsMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			pitfalls, retErr := s.Pitfalls(tunnelName)
+			if pitfalls == nil {
+				pitfalls = []conf.Pitfall{}
+			}
+			err = encoder.Encode(pitfalls)
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case AddressPoolsMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			pools, retErr := s.AddressPools(tunnelName)
+			if pools == nil {
+				pools = &conf.AddressPools{}
+			}
+			err = encoder.Encode(*pools)
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case WatchdogStatusMethodType:
 			var tunnelName string
 			err := decoder.Decode(&tunnelName)
 			if err != nil {
 				return
 			}
-			config, retErr := s.RuntimeConfig(tunnelName)
-			if config == nil {
-				config = &conf.Config{}
-			}
-			err = encoder.Encode(*config)
+			status, retErr := s.WatchdogStatus(tunnelName)
+			err = encoder.Encode(status)
 			if err != nil {
 				return
 			}
@@ -319,61 +1131,78 @@ func (s *ManagerService) ServeConn(reader io.Reader, writer io.Writer) {
 			if err != nil {
 				return
 			}
-		case StartMethodType:
-			var tunnelName string
-			err := decoder.Decode(&tunnelName)
+		case DiagnosticsMethodType:
+			bundle, retErr := s.Diagnostics()
+			err = encoder.Encode(bundle)
 			if err != nil {
 				return
 			}
-			retErr := s.Start(tunnelName)
 			err = encoder.Encode(errToString(retErr))
 			if err != nil {
 				return
 			}
-		case StopMethodType:
+		case PeerStatusesMethodType:
 			var tunnelName string
 			err := decoder.Decode(&tunnelName)
 			if err != nil {
 				return
 			}
-			retErr := s.Stop(tunnelName)
+			statuses, retErr := s.PeerStatuses(tunnelName)
+			if statuses == nil {
+				statuses = []PeerStatus{}
+			}
+			err = encoder.Encode(statuses)
+			if err != nil {
+				return
+			}
 			err = encoder.Encode(errToString(retErr))
 			if err != nil {
 				return
 			}
-		case WaitForStopMethodType:
+		case SetAddressPoolsMethodType:
 			var tunnelName string
 			err := decoder.Decode(&tunnelName)
 			if err != nil {
 				return
 			}
-			retErr := s.WaitForStop(tunnelName)
+			var pools conf.AddressPools
+			err = decoder.Decode(&pools)
+			if err != nil {
+				return
+			}
+			retErr := s.SetAddressPools(tunnelName, &pools)
 			err = encoder.Encode(errToString(retErr))
 			if err != nil {
 				return
 			}
-		case DeleteMethodType:
+		case AddPeerMethodType:
 			var tunnelName string
 			err := decoder.Decode(&tunnelName)
 			if err != nil {
 				return
 			}
-			retErr := s.Delete(tunnelName)
-			err = encoder.Encode(errToString(retErr))
+			var peer conf.Peer
+			err = decoder.Decode(&peer)
 			if err != nil {
 				return
 			}
-		case StateMethodType:
-			var tunnelName string
-			err := decoder.Decode(&tunnelName)
+			var name string
+			err = decoder.Decode(&name)
 			if err != nil {
 				return
 			}
-			state, retErr := s.State(tunnelName)
-			err = encoder.Encode(state)
+			retErr := s.AddPeer(tunnelName, &peer, name)
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case SyncConfigMethodType:
+			var config conf.Config
+			err := decoder.Decode(&config)
 			if err != nil {
 				return
 			}
+			retErr := s.SyncConfig(&config)
 			err = encoder.Encode(errToString(retErr))
 			if err != nil {
 				return
@@ -435,16 +1264,324 @@ func (s *ManagerService) ServeConn(reader io.Reader, writer io.Writer) {
 			}
 		case UpdateMethodType:
 			s.Update()
+		case UpdateChannelMethodType:
+			channel, byPolicy := s.UpdateChannel()
+			err = encoder.Encode(channel)
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(byPolicy)
+			if err != nil {
+				return
+			}
+		case TunnelGroupsMethodType:
+			groups, retErr := s.TunnelGroups()
+			if groups == nil {
+				groups = &conf.TunnelGroups{}
+			}
+			err = encoder.Encode(*groups)
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case SetTunnelGroupsMethodType:
+			var groups conf.TunnelGroups
+			err := decoder.Decode(&groups)
+			if err != nil {
+				return
+			}
+			retErr := s.SetTunnelGroups(&groups)
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case StartGroupMethodType:
+			var groupName string
+			err := decoder.Decode(&groupName)
+			if err != nil {
+				return
+			}
+			retErr := s.StartGroup(groupName)
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case StopGroupMethodType:
+			var groupName string
+			err := decoder.Decode(&groupName)
+			if err != nil {
+				return
+			}
+			retErr := s.StopGroup(groupName)
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case ProtectedMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			protected, retErr := s.Protected(tunnelName)
+			err = encoder.Encode(protected)
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case SetProtectedMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			var protected bool
+			err = decoder.Decode(&protected)
+			if err != nil {
+				return
+			}
+			retErr := s.SetProtected(tunnelName, protected)
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case StartConfirmedMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			var authPackage uint32
+			err = decoder.Decode(&authPackage)
+			if err != nil {
+				return
+			}
+			var authBuffer []byte
+			err = decoder.Decode(&authBuffer)
+			if err != nil {
+				return
+			}
+			retErr := s.StartConfirmed(tunnelName, authPackage, authBuffer)
+			err = encoder.Encode(conflictsOfError(retErr))
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case ScheduleMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			schedule, retErr := s.Schedule(tunnelName)
+			if schedule == nil {
+				schedule = &conf.Schedule{}
+			}
+			err = encoder.Encode(schedule)
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case UsageMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			usage, retErr := s.Usage(tunnelName)
+			if usage == nil {
+				usage = &conf.Usage{}
+			}
+			err = encoder.Encode(usage)
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case ConflictsMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			conflicts, retErr := s.Conflicts(tunnelName)
+			if conflicts == nil {
+				conflicts = []Conflict{}
+			}
+			err = encoder.Encode(conflicts)
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case SetScheduleMethodType:
+			var tunnelName string
+			err := decoder.Decode(&tunnelName)
+			if err != nil {
+				return
+			}
+			var schedule conf.Schedule
+			err = decoder.Decode(&schedule)
+			if err != nil {
+				return
+			}
+			retErr := s.SetSchedule(tunnelName, &schedule)
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case TunnelStatsMethodType:
+			stats, retErr := s.TunnelStats()
+			if stats == nil {
+				stats = []TunnelStats{}
+			}
+			err = encoder.Encode(stats)
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case UpdateProxyMethodType:
+			proxy, byPolicy, retErr := s.UpdateProxy()
+			err = encoder.Encode(proxy)
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(byPolicy)
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case SetUpdateProxyMethodType:
+			var proxy conf.UpdateProxy
+			err := decoder.Decode(&proxy)
+			if err != nil {
+				return
+			}
+			retErr := s.SetUpdateProxy(proxy)
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case ExportArchiveMethodType:
+			var passphrase string
+			err := decoder.Decode(&passphrase)
+			if err != nil {
+				return
+			}
+			archive, retErr := s.ExportArchive(passphrase)
+			err = encoder.Encode(archive)
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case ImportArchiveMethodType:
+			var archive []byte
+			err := decoder.Decode(&archive)
+			if err != nil {
+				return
+			}
+			var passphrase string
+			err = decoder.Decode(&passphrase)
+			if err != nil {
+				return
+			}
+			created, retErr := s.ImportArchive(archive, passphrase)
+			err = encoder.Encode(created)
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case SetUpdateChannelMethodType:
+			var channel string
+			err := decoder.Decode(&channel)
+			if err != nil {
+				return
+			}
+			retErr := s.SetUpdateChannel(channel)
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case SettingsMethodType:
+			settings, retErr := s.Settings()
+			if settings == nil {
+				settings = &conf.Settings{}
+			}
+			err := encoder.Encode(settings)
+			if err != nil {
+				return
+			}
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case SetSettingsMethodType:
+			var settings conf.Settings
+			err := decoder.Decode(&settings)
+			if err != nil {
+				return
+			}
+			retErr := s.SetSettings(&settings)
+			err = encoder.Encode(errToString(retErr))
+			if err != nil {
+				return
+			}
+		case RollbackVersionMethodType:
+			err := encoder.Encode(s.RollbackVersion())
+			if err != nil {
+				return
+			}
+		case RollbackMethodType:
+			s.Rollback()
+		case DriverInfoMethodType:
+			err := encoder.Encode(s.DriverInfo())
+			if err != nil {
+				return
+			}
+		case AdapterDiagnosisMethodType:
+			err := encoder.Encode(s.AdapterDiagnosis())
+			if err != nil {
+				return
+			}
 		default:
 			return
 		}
 	}
 }
 
-func IPCServerListen(reader, writer, events *os.File, elevatedToken windows.Token) {
+func IPCServerListen(reader, writer, events *os.File, elevatedToken windows.Token, userSid *windows.SID) {
 	service := &ManagerService{
 		events:        events,
 		elevatedToken: elevatedToken,
+		userSid:       userSid,
 	}
 
 	go func() {
@@ -507,8 +1644,39 @@ func IPCServerNotifyTunnelChange(name string, state TunnelState, err error) {
 	notifyAll(TunnelChangeNotificationType, false, name, state, trackedTunnelsGlobalState(), errToString(err))
 }
 
+func tunnelsChangedSinceLastCall() (added, removed []string, err error) {
+	knownTunnelsLock.Lock()
+	defer knownTunnelsLock.Unlock()
+	names, err := conf.ListConfigNames()
+	if err != nil {
+		return nil, nil, err
+	}
+	current := make(map[string]bool, len(names))
+	added = make([]string, 0, 1)
+	removed = make([]string, 0, 1)
+	for _, name := range names {
+		current[name] = true
+		if !knownTunnels[name] {
+			added = append(added, name)
+		}
+	}
+	for name := range knownTunnels {
+		if !current[name] {
+			removed = append(removed, name)
+		}
+	}
+	knownTunnels = current
+	return added, removed, nil
+}
+
+// IPCServerNotifyTunnelsChange tells clients which tunnels have been added to or removed from
+// the store since the last call, if any, so that they need not reload every tunnel.
 func IPCServerNotifyTunnelsChange() {
-	notifyAll(TunnelsChangeNotificationType, false)
+	added, removed, err := tunnelsChangedSinceLastCall()
+	if err != nil || (len(added) == 0 && len(removed) == 0) {
+		return
+	}
+	notifyAll(TunnelsChangeNotificationType, false, added, removed)
 }
 
 func IPCServerNotifyUpdateFound(state UpdateState) {
@@ -519,6 +1687,10 @@ func IPCServerNotifyUpdateProgress(dp updater.DownloadProgress) {
 	notifyAll(UpdateProgressNotificationType, true, dp.Activity, dp.BytesDownloaded, dp.BytesTotal, errToString(dp.Error), dp.Complete)
 }
 
+func IPCServerNotifyTransferRate(name string, rate TransferRate) {
+	notifyAll(TransferRateNotificationType, false, name, rate)
+}
+
 func IPCServerNotifyManagerStopping() {
 	notifyAll(ManagerStoppingNotificationType, false)
 	time.Sleep(time.Millisecond * 200)
@@ -0,0 +1,831 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/driver"
+)
+
+const ipcTestConfig = `[Interface]
+PrivateKey = yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=
+Address = 10.192.122.1/24
+
+[Peer]
+PublicKey = xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=
+Endpoint = 192.95.5.67:1234
+AllowedIPs = 10.192.122.3/32`
+
+func saveTestTunnel(t *testing.T, name string) *conf.Config {
+	t.Helper()
+	c, err := conf.FromWgQuick(ipcTestConfig, name)
+	if err != nil {
+		t.Fatalf("Unable to parse test config: %v", err)
+	}
+	err = c.Save(true)
+	if err != nil {
+		t.Fatalf("Unable to save test config: %v", err)
+	}
+	t.Cleanup(func() { conf.DeleteName(name) })
+	return c
+}
+
+func TestIPCTunnels(t *testing.T) {
+	startIPCHarness(t, windows.GetCurrentProcessToken())
+	c := saveTestTunnel(t, "ipcTest")
+
+	tunnels, err := IPCClientTunnels()
+	if err != nil {
+		t.Fatalf("Unable to list tunnels: %v", err)
+	}
+	found := false
+	for _, tunnel := range tunnels {
+		if tunnel.Name == c.Name {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Tunnel %q missing from %v", c.Name, tunnels)
+	}
+
+	tunnel := Tunnel{c.Name}
+	stored, err := tunnel.StoredConfig()
+	if err != nil {
+		t.Fatalf("Unable to load stored config: %v", err)
+	}
+	if stored.Interface.PrivateKey != c.Interface.PrivateKey {
+		t.Error("Elevated client received a redacted private key")
+	}
+
+	_, err = (&Tunnel{"ipcTestMissing"}).StoredConfig()
+	if err == nil {
+		t.Error("Loading a missing tunnel should fail")
+	}
+}
+
+func TestIPCAddPeer(t *testing.T) {
+	startIPCHarness(t, windows.GetCurrentProcessToken())
+	c := saveTestTunnel(t, "ipcTestAddPeer")
+
+	key, err := conf.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer := conf.Peer{PublicKey: *key.Public(), AllowedIPs: []netip.Prefix{netip.MustParsePrefix("10.192.122.4/32")}}
+	tunnel := Tunnel{c.Name}
+	err = tunnel.AddPeer(&peer, "ipcTestPeer")
+	if err != nil {
+		t.Fatalf("Unable to add peer: %v", err)
+	}
+	stored, err := tunnel.StoredConfig()
+	if err != nil {
+		t.Fatalf("Unable to load stored config: %v", err)
+	}
+	if len(stored.Peers) != 2 || stored.Peers[1].PublicKey != peer.PublicKey {
+		t.Errorf("Stored config has peers %v", stored.Peers)
+	}
+	t.Cleanup(func() { conf.DeleteAddressPools(c.Name) })
+	pools, err := tunnel.AddressPools()
+	if err != nil {
+		t.Fatalf("Unable to load address pools: %v", err)
+	}
+	if len(pools.Allocations) != 1 || pools.Allocations[0].Name != "ipcTestPeer" || pools.Allocations[0].Address != peer.AllowedIPs[0] {
+		t.Errorf("Address pools have allocations %v", pools.Allocations)
+	}
+
+	err = tunnel.AddPeer(&peer, "ipcTestPeer")
+	if err == nil {
+		t.Error("Adding the same peer twice should fail")
+	}
+	peer.PublicKey = *key
+	peer.AllowedIPs = []netip.Prefix{netip.MustParsePrefix("10.192.122.3/32")}
+	err = tunnel.AddPeer(&peer, "ipcTestPeer")
+	if err == nil {
+		t.Error("Adding a peer with an address routed to another peer should fail")
+	}
+}
+
+// removedPeers returns the peers that a driver configuration removes from the adapter.
+func removedPeers(interfaze *driver.Interface, _ uint32) map[conf.Key]bool {
+	removed := make(map[conf.Key]bool)
+	peer := interfaze.FirstPeer()
+	for i := uint32(0); i < interfaze.PeerCount; i++ {
+		if peer.Flags&driver.PeerRemove != 0 {
+			removed[conf.Key(peer.PublicKey)] = true
+		}
+		peer = peer.NextPeer()
+	}
+	return removed
+}
+
+func TestAddPeerKeepsPeerSourcePeers(t *testing.T) {
+	stored, err := conf.FromWgQuick(ipcTestConfig, "ipcTestPeerSource")
+	if err != nil {
+		t.Fatalf("Unable to parse test config: %v", err)
+	}
+	stored.Interface.PeerSource = "https://example.com/peers.json.sig"
+	peer := func(address string) conf.Peer {
+		key, err := conf.NewPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conf.Peer{PublicKey: *key.Public(), AllowedIPs: []netip.Prefix{netip.MustParsePrefix(address)}}
+	}
+	fetched := []conf.Peer{peer("10.192.123.1/32"), peer("10.192.123.2/32")}
+	running := *stored
+	running.Peers = append(append([]conf.Peer{}, stored.Peers...), fetched...)
+
+	// AddPeer saves the configuration before syncing it, so the added peer is already stored.
+	added := *stored
+	added.Peers = append(append([]conf.Peer{}, stored.Peers...), peer("10.192.122.4/32"))
+	removed := removedPeers(peerSyncConfiguration(&added, &added, &running))
+	if len(removed) != 0 {
+		t.Errorf("Adding a peer removed %d peers", len(removed))
+	}
+
+	// Peers taken out of the configuration are still removed.
+	replaced := *stored
+	replaced.Peers = added.Peers[1:]
+	removed = removedPeers(peerSyncConfiguration(&replaced, stored, &running))
+	if len(removed) != 1 || !removed[stored.Peers[0].PublicKey] {
+		t.Errorf("Replacing a peer removed %d peers", len(removed))
+	}
+	for i := range fetched {
+		if removed[fetched[i].PublicKey] {
+			t.Errorf("Peer %d of peer source was removed", i)
+		}
+	}
+
+	stored.Interface.PeerSource = ""
+	removed = removedPeers(peerSyncConfiguration(stored, stored, &running))
+	if len(removed) != len(fetched) {
+		t.Errorf("Syncing a tunnel without peer source removed %d peers instead of %d", len(removed), len(fetched))
+	}
+}
+
+func TestIPCAdapterLogLevel(t *testing.T) {
+	startIPCHarness(t, windows.GetCurrentProcessToken())
+	c := saveTestTunnel(t, "ipcTestAdapterLog")
+	t.Cleanup(func() { conf.DeleteAdapterLogLevel(c.Name) })
+
+	tunnel := Tunnel{c.Name}
+	level, err := tunnel.AdapterLogLevel()
+	if err != nil || level != conf.AdapterLogVerbose {
+		t.Errorf("Default adapter log level is %v, %v", level, err)
+	}
+	err = tunnel.SetAdapterLogLevel(conf.AdapterLogErrors)
+	if err != nil {
+		t.Fatalf("Unable to set adapter log level: %v", err)
+	}
+	level, err = tunnel.AdapterLogLevel()
+	if err != nil || level != conf.AdapterLogErrors {
+		t.Errorf("Adapter log level is %v, %v", level, err)
+	}
+	err = tunnel.ReplaceConfig(c)
+	if err != nil {
+		t.Fatalf("Unable to replace configuration: %v", err)
+	}
+	level, err = tunnel.AdapterLogLevel()
+	if err != nil || level != conf.AdapterLogErrors {
+		t.Errorf("Adapter log level after editing is %v, %v", level, err)
+	}
+	err = tunnel.SetAdapterLogLevel(conf.AdapterLogOff + 1)
+	if err == nil {
+		t.Error("Setting an invalid adapter log level should fail")
+	}
+}
+
+func TestIPCTunnelGroups(t *testing.T) {
+	startIPCHarness(t, windows.GetCurrentProcessToken())
+	c := saveTestTunnel(t, "ipcTestGroup")
+	original, err := conf.LoadTunnelGroups()
+	if err != nil {
+		t.Fatalf("Unable to load groups: %v", err)
+	}
+	t.Cleanup(func() { conf.SaveTunnelGroups(original) })
+
+	groups := &conf.TunnelGroups{Groups: []conf.TunnelGroup{{Name: "ipcTestGroup", Tunnels: []string{c.Name, "ipcTestGroupOther"}}}}
+	err = IPCClientSetTunnelGroups(groups)
+	if err != nil {
+		t.Fatalf("Unable to set groups: %v", err)
+	}
+	loaded, err := IPCClientTunnelGroups()
+	if err != nil {
+		t.Fatalf("Unable to get groups: %v", err)
+	}
+	if group := loaded.Find("ipcTestGroup"); group == nil || len(group.Tunnels) != 2 || group.Tunnels[0] != c.Name {
+		t.Errorf("Groups differ: %+v", loaded)
+	}
+	err = IPCClientStartGroup("ipcTestNoSuchGroup")
+	if err == nil {
+		t.Error("Starting a group that does not exist should fail")
+	}
+	err = (&Tunnel{c.Name}).Delete()
+	if err != nil {
+		t.Fatalf("Unable to delete tunnel: %v", err)
+	}
+	loaded, err = IPCClientTunnelGroups()
+	if group := loaded.Find("ipcTestGroup"); err != nil || group == nil || len(group.Tunnels) != 1 {
+		t.Errorf("Deleted tunnel was not removed from its group: %+v, %v", loaded, err)
+	}
+}
+
+func TestIPCTunnelStats(t *testing.T) {
+	startIPCHarness(t, 0)
+	setTunnelStatsSnapshot(map[string]transferTotals{
+		"ipcTestStatsB": {rx: 1, tx: 2},
+		"ipcTestStatsA": {rx: 3, tx: 4, lastHandshake: 5},
+	})
+	t.Cleanup(func() { setTunnelStatsSnapshot(nil) })
+
+	stats, err := IPCClientTunnelStats()
+	if err != nil {
+		t.Fatalf("Unable to get tunnel stats: %v", err)
+	}
+	if len(stats) != 2 || stats[0].Name != "ipcTestStatsA" || stats[0].RxBytes != 3 || stats[0].TxBytes != 4 || stats[0].LastHandshakeTime != 5 {
+		t.Errorf("Tunnel stats differ: %+v", stats)
+	}
+}
+
+func TestIPCProtected(t *testing.T) {
+	startIPCHarness(t, windows.GetCurrentProcessToken())
+	c := saveTestTunnel(t, "ipcTestProtected")
+
+	tunnel := Tunnel{c.Name}
+	err := tunnel.SetProtected(true)
+	if err != nil {
+		t.Fatalf("Unable to protect tunnel: %v", err)
+	}
+	if protected, err := tunnel.Protected(); err != nil || !protected {
+		t.Errorf("Tunnel is not protected: %v", err)
+	}
+	err = tunnel.Start()
+	if err == nil || err.Error() != errConfirmationRequired.Error() {
+		t.Errorf("Starting a protected tunnel without confirmation returned %v", err)
+	}
+	err = tunnel.StartConfirmed(0, []byte("not credentials"))
+	if err == nil {
+		t.Error("Starting a protected tunnel with invalid credentials should fail")
+	}
+	err = tunnel.Delete()
+	if err != nil {
+		t.Fatalf("Unable to delete tunnel: %v", err)
+	}
+	if protected, err := (&ManagerService{}).Protected(c.Name); err != nil || protected {
+		t.Errorf("Deleted tunnel is still protected: %v", err)
+	}
+}
+
+func TestAutomationProtected(t *testing.T) {
+	c := saveTestTunnel(t, "ipcTestAutomationProtected")
+	t.Cleanup(func() { removeProtection(c.Name) })
+	err := (&ManagerService{elevatedToken: windows.GetCurrentProcessToken()}).SetProtected(c.Name, true)
+	if err != nil {
+		t.Fatalf("Unable to protect tunnel: %v", err)
+	}
+
+	call := startAutomationHarness(t, 0)
+	_, err = call(AutomationRequest{Method: "start", Tunnel: c.Name})
+	if err == nil || err.Error() != errProtectedAutomation.Error() {
+		t.Errorf("Starting a protected tunnel over the automation pipe as a limited user returned %v", err)
+	}
+	if state, err := (&ManagerService{}).State(c.Name); err != nil || state != TunnelStopped {
+		t.Errorf("Protected tunnel is %v: %v", state, err)
+	}
+}
+
+func TestIPCSchedule(t *testing.T) {
+	startIPCHarness(t, windows.GetCurrentProcessToken())
+	c := saveTestTunnel(t, "ipcTestSchedule")
+
+	tunnel := Tunnel{c.Name}
+	schedule := conf.Schedule{Windows: []conf.ScheduleWindow{{Days: 1 << time.Monday, Start: 9 * 60, End: 17 * 60}}}
+	err := tunnel.SetSchedule(&schedule)
+	if err != nil {
+		t.Fatalf("Unable to set schedule: %v", err)
+	}
+	got, err := tunnel.Schedule()
+	if err != nil || !reflect.DeepEqual(got, schedule) {
+		t.Errorf("Schedule is %+v, want %+v: %v", got, schedule, err)
+	}
+	err = tunnel.SetSchedule(&conf.Schedule{Windows: []conf.ScheduleWindow{{Days: 1 << time.Monday, Start: 25 * 60}}})
+	if err == nil {
+		t.Error("Setting an invalid schedule should fail")
+	}
+	err = tunnel.Delete()
+	if err != nil {
+		t.Fatalf("Unable to delete tunnel: %v", err)
+	}
+	if stored, err := conf.LoadSchedule(c.Name); err != nil || !stored.IsEmpty() {
+		t.Errorf("Deleted tunnel still has a schedule: %v", err)
+	}
+}
+
+func TestIPCRenameDuplicate(t *testing.T) {
+	startIPCHarness(t, windows.GetCurrentProcessToken())
+	c := saveTestTunnel(t, "ipcTestRename")
+	t.Cleanup(func() {
+		conf.DeleteName("ipcTestRenamed")
+		conf.DeleteSchedule("ipcTestRenamed")
+		conf.DeletePeerSourceState("ipcTestRenamed")
+		conf.DeleteName("ipcTestCopy")
+	})
+
+	tunnel := Tunnel{c.Name}
+	schedule := conf.Schedule{Windows: []conf.ScheduleWindow{{Days: 1 << time.Monday, Start: 9 * 60, End: 17 * 60}}}
+	err := tunnel.SetSchedule(&schedule)
+	if err != nil {
+		t.Fatalf("Unable to set schedule: %v", err)
+	}
+	peerSource := conf.PeerSourceState{Source: "https://peers.example/peers.json.sig", Serial: 42}
+	err = conf.SavePeerSourceState(c.Name, peerSource)
+	if err != nil {
+		t.Fatalf("Unable to save peer source state: %v", err)
+	}
+	err = tunnel.Rename("ipcTestRenamed")
+	if err != nil {
+		t.Fatalf("Unable to rename tunnel: %v", err)
+	}
+	if _, err := conf.LoadFromName(c.Name); err == nil {
+		t.Error("Renamed tunnel is still stored under its old name")
+	}
+	renamed := Tunnel{"ipcTestRenamed"}
+	stored, err := renamed.StoredConfig()
+	if err != nil || stored.Interface.PrivateKey != c.Interface.PrivateKey {
+		t.Errorf("Renamed tunnel has a different configuration: %v", err)
+	}
+	if got, err := renamed.Schedule(); err != nil || !reflect.DeepEqual(got, schedule) {
+		t.Errorf("Renamed tunnel has schedule %+v, want %+v: %v", got, schedule, err)
+	}
+	if got, err := conf.LoadPeerSourceState("ipcTestRenamed"); err != nil || got != peerSource {
+		t.Errorf("Renamed tunnel has peer source state %+v, want %+v: %v", got, peerSource, err)
+	}
+
+	duplicate, err := renamed.Duplicate("ipcTestCopy", true)
+	if err != nil || duplicate.Name != "ipcTestCopy" {
+		t.Fatalf("Unable to duplicate tunnel: %v", err)
+	}
+	copied, err := duplicate.StoredConfig()
+	if err != nil {
+		t.Fatalf("Unable to load duplicate: %v", err)
+	}
+	if copied.Interface.PrivateKey == c.Interface.PrivateKey {
+		t.Error("Duplicate kept the private key")
+	}
+	if len(copied.Peers) != 1 || copied.Peers[0].PublicKey != c.Peers[0].PublicKey {
+		t.Error("Duplicate has different peers")
+	}
+	if _, err := renamed.Duplicate("ipcTestCopy", false); err == nil {
+		t.Error("Duplicating onto an existing tunnel should fail")
+	}
+	if err := duplicate.Rename("ipcTestRenamed"); err == nil {
+		t.Error("Renaming onto an existing tunnel should fail")
+	}
+	err = renamed.Delete()
+	if err != nil {
+		t.Fatalf("Unable to delete tunnel: %v", err)
+	}
+	if got, err := conf.LoadPeerSourceState("ipcTestRenamed"); err != nil || got != (conf.PeerSourceState{}) {
+		t.Errorf("Deleted tunnel has peer source state %+v: %v", got, err)
+	}
+}
+
+func TestIPCRenameRollback(t *testing.T) {
+	startIPCHarness(t, windows.GetCurrentProcessToken())
+	c := saveTestTunnel(t, "ipcTestRollback")
+	t.Cleanup(func() {
+		conf.DeleteSchedule(c.Name)
+		conf.DeletePeerSourceState(c.Name)
+	})
+
+	tunnel := Tunnel{c.Name}
+	schedule := conf.Schedule{Windows: []conf.ScheduleWindow{{Days: 1 << time.Friday, Start: 8 * 60, End: 12 * 60}}}
+	err := tunnel.SetSchedule(&schedule)
+	if err != nil {
+		t.Fatalf("Unable to set schedule: %v", err)
+	}
+	err = conf.SavePeerSourceState(c.Name, conf.PeerSourceState{Source: "https://peers.example/peers.json.sig", Serial: 7})
+	if err != nil {
+		t.Fatalf("Unable to save peer source state: %v", err)
+	}
+	// A directory in the way of the peer source state makes the move fail after the schedule's.
+	root, err := conf.RootDirectory(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocker := filepath.Join(root, "Peer Sources", "ipcTestRollbackNew.json")
+	err = os.MkdirAll(filepath.Join(blocker, "blocker"), 0o700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(blocker) })
+
+	if err := tunnel.Rename("ipcTestRollbackNew"); err == nil {
+		t.Fatal("Rename succeeded despite a state file that could not be moved")
+	}
+	if conf.TunnelNameExists("ipcTestRollbackNew") {
+		t.Error("Failed rename left a configuration under the new name")
+	}
+	if _, err := conf.LoadFromName(c.Name); err != nil {
+		t.Errorf("Failed rename lost the configuration under the old name: %v", err)
+	}
+	if got, err := tunnel.Schedule(); err != nil || !reflect.DeepEqual(got, schedule) {
+		t.Errorf("Failed rename left schedule %+v, want %+v: %v", got, schedule, err)
+	}
+}
+
+func TestIPCUsage(t *testing.T) {
+	startIPCHarness(t, windows.GetCurrentProcessToken())
+	c := saveTestTunnel(t, "ipcTestUsage")
+
+	tunnel := Tunnel{c.Name}
+	now := time.Now()
+	recordUsage(c.Name, now, 1000, 100)
+	got, err := tunnel.Usage()
+	if err != nil || len(got.Days) != 1 || got.Days[0].RxBytes != 1000 || got.Days[0].TxBytes != 100 {
+		t.Errorf("Unsaved usage is %+v: %v", got, err)
+	}
+	saveUsage()
+	recordUsage(c.Name, now, 1000, 100)
+	got, err = tunnel.Usage()
+	if err != nil || len(got.Months) != 1 || got.Months[0].RxBytes != 2000 || got.Months[0].TxBytes != 200 {
+		t.Errorf("Saved and unsaved usage is %+v: %v", got, err)
+	}
+	err = tunnel.Delete()
+	if err != nil {
+		t.Fatalf("Unable to delete tunnel: %v", err)
+	}
+	saveUsage()
+	if stored, err := conf.LoadUsage(c.Name); err != nil || !stored.IsEmpty() {
+		t.Errorf("Deleted tunnel still has usage: %v", err)
+	}
+}
+
+func TestIPCReplaceConfig(t *testing.T) {
+	startIPCHarness(t, windows.GetCurrentProcessToken())
+	c := saveTestTunnel(t, "ipcTestReplace")
+	t.Cleanup(func() {
+		deleteUsage("ipcTestReplaced")
+		conf.DeleteName("ipcTestReplaced")
+	})
+
+	tunnel := Tunnel{c.Name}
+	recordUsage(c.Name, time.Now(), 1000, 100)
+	saveUsage()
+	rotatedAt := time.Now().Add(-time.Hour).Round(time.Second)
+	err := conf.SaveKeyRotationState(c.Name, conf.KeyRotationState{LastRotation: rotatedAt})
+	if err != nil {
+		t.Fatalf("Unable to save key rotation state: %v", err)
+	}
+	err = conf.SavePresharedKeyRotationState(c.Name, conf.PresharedKeyRotationState{LastRotation: rotatedAt})
+	if err != nil {
+		t.Fatalf("Unable to save preshared key rotation state: %v", err)
+	}
+	t.Cleanup(func() {
+		conf.DeleteKeyRotationState("ipcTestReplaced")
+		conf.DeletePresharedKeyRotationState("ipcTestReplaced")
+	})
+	edited := *c
+	edited.Interface.ListenPort = 51821
+	err = tunnel.ReplaceConfig(&edited)
+	if err != nil {
+		t.Fatalf("Unable to replace configuration: %v", err)
+	}
+	stored, err := tunnel.StoredConfig()
+	if err != nil || stored.Interface.ListenPort != 51821 {
+		t.Errorf("Replaced configuration was not stored: %v", err)
+	}
+	if got, err := tunnel.Usage(); err != nil || len(got.Days) != 1 || got.Days[0].RxBytes != 1000 {
+		t.Errorf("Usage after replacing configuration is %+v: %v", got, err)
+	}
+	if state, err := conf.LoadKeyRotationState(c.Name); err != nil || !state.LastRotation.Equal(rotatedAt) {
+		t.Errorf("Key rotation state after replacing configuration is %+v: %v", state, err)
+	}
+	if state, err := conf.LoadPresharedKeyRotationState(c.Name); err != nil || !state.LastRotation.Equal(rotatedAt) {
+		t.Errorf("Preshared key rotation state after replacing configuration is %+v: %v", state, err)
+	}
+
+	edited.Name = "ipcTestReplaced"
+	err = tunnel.ReplaceConfig(&edited)
+	if err != nil {
+		t.Fatalf("Unable to replace and rename configuration: %v", err)
+	}
+	if _, err := conf.LoadFromName(c.Name); err == nil {
+		t.Error("Replaced tunnel is still stored under its old name")
+	}
+	renamed := Tunnel{edited.Name}
+	if got, err := renamed.Usage(); err != nil || len(got.Days) != 1 || got.Days[0].RxBytes != 1000 {
+		t.Errorf("Usage after renaming is %+v: %v", got, err)
+	}
+	if err := (&Tunnel{"ipcTestReplaceMissing"}).ReplaceConfig(&edited); err == nil {
+		t.Error("Replacing the configuration of a missing tunnel should fail")
+	}
+}
+
+func TestIPCArchive(t *testing.T) {
+	startIPCHarness(t, windows.GetCurrentProcessToken())
+	c := saveTestTunnel(t, "ipcTestArchive")
+
+	archive, err := IPCClientExportArchive("test passphrase")
+	if err != nil {
+		t.Fatalf("Unable to export archive: %v", err)
+	}
+	_, err = IPCClientImportArchive(archive, "wrong passphrase")
+	if err == nil {
+		t.Error("Importing with the wrong passphrase should fail")
+	}
+	created, err := IPCClientImportArchive(archive, "test passphrase")
+	defer func() {
+		for _, name := range created {
+			(&Tunnel{name}).Delete()
+		}
+	}()
+	if err != nil {
+		t.Fatalf("Unable to import archive: %v", err)
+	}
+	found := false
+	for _, name := range created {
+		if name == c.Name+"-2" {
+			found = true
+			imported, err := (&Tunnel{name}).StoredConfig()
+			if err != nil || imported.Interface.PrivateKey != c.Interface.PrivateKey {
+				t.Errorf("Imported tunnel does not have the exported key: %v", err)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Imported tunnels %v do not include a renamed copy of %s", created, c.Name)
+	}
+}
+
+func TestIPCWatchdogStatus(t *testing.T) {
+	startIPCHarness(t, 0)
+	c := saveTestTunnel(t, "ipcTestWatchdog")
+
+	tunnel := Tunnel{c.Name}
+	status, err := tunnel.WatchdogStatus()
+	if err != nil {
+		t.Fatalf("Unable to get watchdog status: %v", err)
+	}
+	if status.Enabled || status.ConsecutiveFailures != 0 {
+		t.Errorf("Tunnel without a watchdog policy has status %+v", status)
+	}
+	_, err = (&Tunnel{"ipcTestMissing"}).WatchdogStatus()
+	if err == nil {
+		t.Error("Watchdog status of a missing tunnel should fail")
+	}
+}
+
+func TestIPCDiagnostics(t *testing.T) {
+	startIPCHarness(t, 0)
+	c := saveTestTunnel(t, "ipcTestDiagnostics")
+
+	bundle, err := IPCClientDiagnostics()
+	if err != nil {
+		t.Fatalf("Unable to collect diagnostics: %v", err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(bundle), int64(len(bundle)))
+	if err != nil {
+		t.Fatalf("Diagnostics bundle is not a zip file: %v", err)
+	}
+	found := false
+	for _, file := range archive.File {
+		if file.Name != "configs/"+c.Name+".conf" {
+			continue
+		}
+		found = true
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatalf("Unable to open configuration in bundle: %v", err)
+		}
+		text, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatalf("Unable to read configuration in bundle: %v", err)
+		}
+		if strings.Contains(string(text), c.Interface.PrivateKey.String()) || strings.Contains(string(text), c.Peers[0].PublicKey.String()) {
+			t.Error("Diagnostics bundle contains unredacted keys")
+		}
+	}
+	if !found {
+		t.Errorf("Diagnostics bundle lacks configuration of %s", c.Name)
+	}
+}
+
+func TestIPCLimitedUser(t *testing.T) {
+	startIPCHarness(t, 0)
+	c := saveTestTunnel(t, "ipcTestLimited")
+
+	tunnel := Tunnel{c.Name}
+	stored, err := tunnel.StoredConfig()
+	if err != nil {
+		t.Fatalf("Unable to load stored config: %v", err)
+	}
+	if !stored.Interface.PrivateKey.IsZero() || !stored.Peers[0].PublicKey.IsZero() {
+		t.Error("Limited client received unredacted keys")
+	}
+	t.Cleanup(func() { conf.DeleteAddressPools(c.Name) })
+	err = conf.SaveAddressPools(c.Name, &conf.AddressPools{Allocations: []conf.AddressAllocation{{
+		Address:   c.Peers[0].AllowedIPs[0],
+		PublicKey: c.Peers[0].PublicKey.String(),
+		Name:      "ipcTestPeer",
+	}}})
+	if err != nil {
+		t.Fatalf("Unable to save address pools: %v", err)
+	}
+	pools, err := tunnel.AddressPools()
+	if err != nil {
+		t.Fatalf("Unable to load address pools: %v", err)
+	}
+	if len(pools.Allocations) != 1 || pools.Allocations[0].PublicKey != "" {
+		t.Errorf("Limited client received allocations %v", pools.Allocations)
+	}
+	t.Cleanup(func() { conf.DeleteProfiles(c.Name) })
+	err = conf.SaveProfiles(c.Name, &conf.Profiles{Profiles: []conf.Profile{{
+		Name:  "office",
+		Peers: []conf.ProfilePeer{{PublicKey: c.Peers[0].PublicKey.String(), Endpoint: "10.0.0.1:51820"}},
+	}}})
+	if err != nil {
+		t.Fatalf("Unable to save profiles: %v", err)
+	}
+	profiles, err := tunnel.Profiles()
+	if err != nil {
+		t.Fatalf("Unable to load profiles: %v", err)
+	}
+	if len(profiles.Profiles) != 1 || len(profiles.Profiles[0].Peers) != 1 || profiles.Profiles[0].Peers[0].PublicKey != "" {
+		t.Errorf("Limited client received profiles %+v", profiles.Profiles)
+	}
+
+	_, err = IPCClientNewTunnel(c)
+	if err == nil || err.Error() != windows.ERROR_ACCESS_DENIED.Error() {
+		t.Errorf("Creating a tunnel as a limited user returned %v", err)
+	}
+	err = tunnel.Delete()
+	if err == nil || err.Error() != windows.ERROR_ACCESS_DENIED.Error() {
+		t.Errorf("Deleting a tunnel as a limited user returned %v", err)
+	}
+	_, err = tunnel.Duplicate("ipcTestLimitedCopy", true)
+	if err == nil || err.Error() != windows.ERROR_ACCESS_DENIED.Error() {
+		t.Errorf("Duplicating a tunnel as a limited user returned %v", err)
+	}
+	err = tunnel.SyncConfig(c)
+	if err == nil || err.Error() != windows.ERROR_ACCESS_DENIED.Error() {
+		t.Errorf("Synchronizing a tunnel as a limited user returned %v", err)
+	}
+	err = tunnel.AddPeer(&c.Peers[0], "")
+	if err == nil || err.Error() != windows.ERROR_ACCESS_DENIED.Error() {
+		t.Errorf("Adding a peer as a limited user returned %v", err)
+	}
+	err = tunnel.SetAddressPools(&conf.AddressPools{})
+	if err == nil || err.Error() != windows.ERROR_ACCESS_DENIED.Error() {
+		t.Errorf("Setting address pools as a limited user returned %v", err)
+	}
+	err = tunnel.SetAdapterLogLevel(conf.AdapterLogOff)
+	if err == nil || err.Error() != windows.ERROR_ACCESS_DENIED.Error() {
+		t.Errorf("Setting the adapter log level as a limited user returned %v", err)
+	}
+	err = IPCClientSetUpdateChannel(conf.UpdateChannelBeta)
+	if err == nil || err.Error() != windows.ERROR_ACCESS_DENIED.Error() {
+		t.Errorf("Setting the update channel as a limited user returned %v", err)
+	}
+	err = IPCClientSetTunnelGroups(&conf.TunnelGroups{})
+	if err == nil || err.Error() != windows.ERROR_ACCESS_DENIED.Error() {
+		t.Errorf("Setting groups as a limited user returned %v", err)
+	}
+	err = IPCClientSetUpdateProxy(conf.UpdateProxy{Host: "proxy.example", Port: 3128})
+	if err == nil || err.Error() != windows.ERROR_ACCESS_DENIED.Error() {
+		t.Errorf("Setting the update proxy as a limited user returned %v", err)
+	}
+	err = tunnel.SetProtected(true)
+	if err == nil || err.Error() != windows.ERROR_ACCESS_DENIED.Error() {
+		t.Errorf("Protecting a tunnel as a limited user returned %v", err)
+	}
+	err = tunnel.SetSchedule(&conf.Schedule{})
+	if err == nil || err.Error() != windows.ERROR_ACCESS_DENIED.Error() {
+		t.Errorf("Setting a schedule as a limited user returned %v", err)
+	}
+	_, err = IPCClientExportArchive("test passphrase")
+	if err == nil || err.Error() != windows.ERROR_ACCESS_DENIED.Error() {
+		t.Errorf("Exporting an archive as a limited user returned %v", err)
+	}
+	if channel, _, err := IPCClientUpdateChannel(); err != nil || !conf.UpdateChannelIsValid(channel) {
+		t.Errorf("Update channel is %q: %v", channel, err)
+	}
+	_, err = IPCClientQuit(false)
+	if err == nil || err.Error() != windows.ERROR_ACCESS_DENIED.Error() {
+		t.Errorf("Quitting as a limited user returned %v", err)
+	}
+}
+
+func TestIPCGlobalState(t *testing.T) {
+	startIPCHarness(t, windows.GetCurrentProcessToken())
+
+	trackedTunnelsLock.Lock()
+	trackedTunnels["ipcTestState"] = TunnelStarting
+	trackedTunnelsLock.Unlock()
+	defer func() {
+		trackedTunnelsLock.Lock()
+		delete(trackedTunnels, "ipcTestState")
+		trackedTunnelsLock.Unlock()
+	}()
+
+	state, err := IPCClientGlobalState()
+	if err != nil {
+		t.Fatalf("Unable to query global state: %v", err)
+	}
+	if state != TunnelStarting {
+		t.Errorf("Global state is %v, expected %v", state, TunnelStarting)
+	}
+}
+
+func TestIPCNotifications(t *testing.T) {
+	startIPCHarness(t, windows.GetCurrentProcessToken())
+
+	type tunnelChange struct {
+		name               string
+		state, globalState TunnelState
+		err                error
+	}
+	tunnelChanges := make(chan tunnelChange, 1)
+	tunnelChangeCB := IPCClientRegisterTunnelChange(func(tunnel *Tunnel, state, globalState TunnelState, err error) {
+		tunnelChanges <- tunnelChange{tunnel.Name, state, globalState, err}
+	})
+	defer tunnelChangeCB.Unregister()
+	type tunnelsChange struct {
+		added, removed []Tunnel
+	}
+	tunnelsChanges := make(chan tunnelsChange, 1)
+	tunnelsChangeCB := IPCClientRegisterTunnelsChange(func(added, removed []Tunnel) {
+		tunnelsChanges <- tunnelsChange{added, removed}
+	})
+	defer tunnelsChangeCB.Unregister()
+	updateFounds := make(chan UpdateState, 1)
+	updateFoundCB := IPCClientRegisterUpdateFound(func(updateState UpdateState) {
+		updateFounds <- updateState
+	})
+	defer updateFoundCB.Unregister()
+
+	IPCServerNotifyTunnelChange("ipcTestNotify", TunnelStarted, errors.New("test error"))
+	change := waitFor(t, tunnelChanges)
+	if change.name != "ipcTestNotify" || change.state != TunnelStarted || change.err == nil || change.err.Error() != "test error" {
+		t.Errorf("Unexpected tunnel change notification: %+v", change)
+	}
+
+	// Notifications for an unknown state are dropped by the client.
+	IPCServerNotifyTunnelChange("ipcTestNotify", TunnelUnknown, nil)
+	IPCServerNotifyTunnelChange("ipcTestNotify", TunnelStopped, nil)
+	change = waitFor(t, tunnelChanges)
+	if change.state != TunnelStopped || change.err != nil {
+		t.Errorf("Unexpected tunnel change notification: %+v", change)
+	}
+
+	tunnelsChangedSinceLastCall()
+	saveTestTunnel(t, "ipcTestNotify")
+	IPCServerNotifyTunnelsChange()
+	if changes := waitFor(t, tunnelsChanges); len(changes.added) != 1 || changes.added[0].Name != "ipcTestNotify" || len(changes.removed) != 0 {
+		t.Errorf("Unexpected tunnels change notification: %+v", changes)
+	}
+	conf.DeleteName("ipcTestNotify")
+	IPCServerNotifyTunnelsChange()
+	if changes := waitFor(t, tunnelsChanges); len(changes.removed) != 1 || changes.removed[0].Name != "ipcTestNotify" || len(changes.added) != 0 {
+		t.Errorf("Unexpected tunnels change notification: %+v", changes)
+	}
+
+	IPCServerNotifyUpdateFound(UpdateStateFoundUpdate)
+	if state := waitFor(t, updateFounds); state != UpdateStateFoundUpdate {
+		t.Errorf("Update found notification carried %v", state)
+	}
+}
+
+func TestAutomationRequestValidation(t *testing.T) {
+	s := &ManagerService{}
+	_, err := s.automationCall(&AutomationRequest{Version: AutomationProtocolVersion + 1, Method: "list"})
+	if err == nil {
+		t.Error("A mismatched protocol version should be rejected")
+	}
+	_, err = s.automationCall(&AutomationRequest{Version: AutomationProtocolVersion, Method: "start", Tunnel: "bad name!"})
+	if err == nil {
+		t.Error("An invalid tunnel name should be rejected")
+	}
+	_, err = s.automationCall(&AutomationRequest{Version: AutomationProtocolVersion, Method: "reboot", Tunnel: "ipcTest"})
+	if err == nil {
+		t.Error("An unknown method should be rejected")
+	}
+}
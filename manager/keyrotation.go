@@ -0,0 +1,223 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+// Keys are only rotated while a tunnel is running, so that it can be checked right away whether
+// the peers took up the new key. If none of them completes a handshake with it within the grace
+// period, the old key is put back, since the tunnel would otherwise be cut off for good.
+
+const (
+	keyRotationCheckInterval  = time.Hour
+	keyRotationPollInterval   = 10 * time.Second
+	keyRotationPublishTimeout = 30 * time.Second
+)
+
+var (
+	rotatingKeys     = make(map[string]bool)
+	rotatingKeysLock sync.Mutex
+)
+
+// keyRotationPublication is what is posted to the PublishURL of a key rotation policy, both when
+// the key is rotated and when it is rolled back, with the keys swapped.
+type keyRotationPublication struct {
+	Tunnel            string `json:"tunnel"`
+	PublicKey         string `json:"public_key"`
+	PreviousPublicKey string `json:"previous_public_key"`
+}
+
+// keyRotationDue reports whether the key of a tunnel is to be rotated at now.
+func keyRotationDue(policy *conf.KeyRotationPolicy, state conf.KeyRotationState, now time.Time) bool {
+	return !state.LastRotation.IsZero() && now.Sub(state.LastRotation) >= policy.Interval
+}
+
+// handshakeSince reports whether any peer of config completed a handshake after t.
+func handshakeSince(config *conf.Config, t time.Time) bool {
+	for i := range config.Peers {
+		handshake := config.Peers[i].LastHandshakeTime
+		if !handshake.IsEmpty() && time.Unix(0, 0).Add(time.Duration(handshake)).After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func publishKey(url, tunnelName string, publicKey, previousPublicKey *conf.Key) error {
+	if len(url) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(keyRotationPublication{tunnelName, publicKey.String(), previousPublicKey.String()})
+	if err != nil {
+		return err
+	}
+	client := http.Client{Timeout: keyRotationPublishTimeout}
+	response, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("Publishing key failed with status %s", response.Status)
+	}
+	return nil
+}
+
+func setAdapterPrivateKey(config *conf.Config) error {
+	driverAdapter, err := findDriverAdapter(config.Name)
+	if err != nil {
+		return err
+	}
+	err = driverAdapter.SetConfiguration(config.ToDriverPrivateKeyConfiguration())
+	driverAdapter.Unlock()
+	if err != nil {
+		releaseDriverAdapter(config.Name)
+	}
+	return err
+}
+
+// saveRotatedConfig stores config as the manager's own change, so that the configuration watcher
+// does not restart the tunnel over it.
+func saveRotatedConfig(config *conf.Config) error {
+	err := config.Save(true)
+	if err == nil {
+		noteConfigChange(config.Name)
+	}
+	return err
+}
+
+// rotateKey replaces the private key of a running tunnel, publishes it, and puts the old one back
+// if no peer completes a handshake with the new one within the grace period.
+func rotateKey(tunnelName string, policy *conf.KeyRotationPolicy) error {
+	config, err := conf.LoadFromName(tunnelName)
+	if err != nil {
+		return err
+	}
+	oldKey := config.Interface.PrivateKey
+	newKey, err := conf.NewPrivateKey()
+	if err != nil {
+		return err
+	}
+	rotatedAt := time.Now()
+	err = conf.SaveKeyRotationState(tunnelName, conf.KeyRotationState{LastRotation: rotatedAt})
+	if err != nil {
+		return err
+	}
+
+	config.Interface.PrivateKey = *newKey
+	err = setAdapterPrivateKey(config)
+	if err != nil {
+		return err
+	}
+	err = saveRotatedConfig(config)
+	if err == nil {
+		err = publishKey(policy.PublishURL, tunnelName, newKey.Public(), oldKey.Public())
+	}
+	if err == nil {
+		log.Printf("[%s] Rotated private key, new public key is %s", tunnelName, newKey.Public().String())
+		for deadline := rotatedAt.Add(policy.GracePeriod); time.Now().Before(deadline); {
+			time.Sleep(keyRotationPollInterval)
+			current, err := runtimeConfig(tunnelName)
+			if err != nil {
+				break
+			}
+			if handshakeSince(current, rotatedAt) {
+				return nil
+			}
+		}
+		err = fmt.Errorf("No peer completed a handshake within %v", policy.GracePeriod)
+	}
+
+	log.Printf("[%s] Rolling back key rotation: %v", tunnelName, err)
+	// Peers may have been added or the tunnel edited during the grace period, so only the key of
+	// the configuration as it is now is put back, unless it was replaced by yet another one.
+	config, loadErr := conf.LoadFromName(tunnelName)
+	if loadErr != nil {
+		log.Printf("[%s] Unable to reload configuration to restore previous private key: %v", tunnelName, loadErr)
+		return err
+	}
+	if config.Interface.PrivateKey != *newKey && config.Interface.PrivateKey != oldKey {
+		log.Printf("[%s] Private key was changed during key rotation, so the previous one is not restored", tunnelName)
+		return err
+	}
+	config.Interface.PrivateKey = oldKey
+	if rollbackErr := setAdapterPrivateKey(config); rollbackErr != nil {
+		log.Printf("[%s] Unable to restore previous private key on adapter: %v", tunnelName, rollbackErr)
+	}
+	if rollbackErr := saveRotatedConfig(config); rollbackErr != nil {
+		log.Printf("[%s] Unable to save previous private key: %v", tunnelName, rollbackErr)
+	}
+	if rollbackErr := publishKey(policy.PublishURL, tunnelName, oldKey.Public(), newKey.Public()); rollbackErr != nil {
+		log.Printf("[%s] Unable to publish previous public key: %v", tunnelName, rollbackErr)
+	}
+	return err
+}
+
+// checkKeyRotations starts rotating the keys of running tunnels whose policy says it is time.
+// Tunnels seen with a policy for the first time have their clock started instead.
+func checkKeyRotations() {
+	names, err := conf.ListConfigNames()
+	if err != nil {
+		return
+	}
+	s := &ManagerService{}
+	now := time.Now()
+	for _, name := range names {
+		policy := conf.LoadKeyRotationPolicy(name)
+		if policy == nil {
+			continue
+		}
+		state, err := conf.LoadKeyRotationState(name)
+		if err != nil {
+			log.Printf("[%s] Unable to load key rotation state: %v", name, err)
+			continue
+		}
+		if state.LastRotation.IsZero() {
+			conf.SaveKeyRotationState(name, conf.KeyRotationState{LastRotation: now})
+			continue
+		}
+		if !keyRotationDue(policy, state, now) {
+			continue
+		}
+		if tunnelState, err := s.State(name); err != nil || tunnelState != TunnelStarted {
+			continue
+		}
+		rotatingKeysLock.Lock()
+		if rotatingKeys[name] {
+			rotatingKeysLock.Unlock()
+			continue
+		}
+		rotatingKeys[name] = true
+		rotatingKeysLock.Unlock()
+		go func(name string, policy *conf.KeyRotationPolicy) {
+			err := rotateKey(name, policy)
+			if err != nil {
+				log.Printf("[%s] Unable to rotate private key: %v", name, err)
+			}
+			rotatingKeysLock.Lock()
+			delete(rotatingKeys, name)
+			rotatingKeysLock.Unlock()
+		}(name, policy)
+	}
+}
+
+func rotateKeysPeriodically() {
+	for {
+		checkKeyRotations()
+		checkPresharedKeyRotations()
+		time.Sleep(keyRotationCheckInterval)
+	}
+}
@@ -0,0 +1,42 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+func TestKeyRotationDue(t *testing.T) {
+	policy := &conf.KeyRotationPolicy{Interval: 7 * 24 * time.Hour}
+	now := time.Now()
+	if keyRotationDue(policy, conf.KeyRotationState{}, now) {
+		t.Error("Rotation without a prior rotation should not be due")
+	}
+	if keyRotationDue(policy, conf.KeyRotationState{LastRotation: now.Add(-6 * 24 * time.Hour)}, now) {
+		t.Error("Rotation six days after the last one should not be due")
+	}
+	if !keyRotationDue(policy, conf.KeyRotationState{LastRotation: now.Add(-7 * 24 * time.Hour)}, now) {
+		t.Error("Rotation seven days after the last one should be due")
+	}
+}
+
+func TestHandshakeSince(t *testing.T) {
+	rotatedAt := time.Now()
+	config := &conf.Config{Peers: []conf.Peer{
+		{},
+		{LastHandshakeTime: conf.HandshakeTime(rotatedAt.Add(-time.Minute).Sub(time.Unix(0, 0)))},
+	}}
+	if handshakeSince(config, rotatedAt) {
+		t.Error("Handshakes before the rotation should not count")
+	}
+	config.Peers[0].LastHandshakeTime = conf.HandshakeTime(rotatedAt.Add(time.Second).Sub(time.Unix(0, 0)))
+	if !handshakeSince(config, rotatedAt) {
+		t.Error("Handshake after the rotation should count")
+	}
+}
@@ -0,0 +1,73 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+// effectiveListenPort is the port that a tunnel listens on when running, which is the one in its
+// configuration unless the manager rebound it.
+func effectiveListenPort(config *conf.Config) uint16 {
+	if port, err := conf.LoadListenPortOverride(config.Name); err == nil && port != 0 {
+		return port
+	}
+	return config.Interface.ListenPort
+}
+
+// listenPortHolder returns which of the given tunnels listens on port, or "" if none does.
+// A port of 0 is chosen randomly by the driver and so never conflicts.
+func listenPortHolder(port uint16, ports map[string]uint16) string {
+	if port == 0 {
+		return ""
+	}
+	for name, other := range ports {
+		if other == port {
+			return name
+		}
+	}
+	return ""
+}
+
+// freeListenPort asks the system for a UDP port that nothing is listening on.
+func freeListenPort() (uint16, error) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{})
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	return uint16(conn.LocalAddr().(*net.UDPAddr).Port), nil
+}
+
+// resolveListenPortConflict checks whether a running tunnel, other than those about to be
+// stopped, already listens on the port of the tunnel being activated. If so, it either fails, or,
+// with the RebindConflictingListenPorts policy, rebinds the tunnel being activated to a free port.
+func resolveListenPortConflict(config *conf.Config, stopping []string) error {
+	running := runningConfigs(config, stopping)
+	ports := make(map[string]uint16, len(running))
+	for name, other := range running {
+		ports[name] = effectiveListenPort(other)
+	}
+
+	holder := listenPortHolder(config.Interface.ListenPort, ports)
+	if len(holder) == 0 {
+		return conf.DeleteListenPortOverride(config.Name)
+	}
+	if !conf.AdminBool("RebindConflictingListenPorts") {
+		return &ConflictError{Tunnel: config.Name, Conflicts: []Conflict{{ConflictListenPort, holder, strconv.Itoa(int(config.Interface.ListenPort))}}}
+	}
+	port, err := freeListenPort()
+	if err != nil {
+		return fmt.Errorf("Unable to find a free port instead of port %d: %w", config.Interface.ListenPort, err)
+	}
+	log.Printf("[%s] Port %d is used by tunnel ‘%s’, so listening on port %d instead", config.Name, config.Interface.ListenPort, holder, port)
+	return conf.SaveListenPortOverride(config.Name, port)
+}
@@ -0,0 +1,21 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import "testing"
+
+func TestListenPortHolder(t *testing.T) {
+	ports := map[string]uint16{"office": 51820, "home": 0, "lab": 51821}
+	if holder := listenPortHolder(51820, ports); holder != "office" {
+		t.Errorf("Port 51820 is held by %q, expected office", holder)
+	}
+	if holder := listenPortHolder(51822, ports); holder != "" {
+		t.Errorf("Port 51822 is held by %q, expected nobody", holder)
+	}
+	if holder := listenPortHolder(0, ports); holder != "" {
+		t.Errorf("Random port is held by %q, expected nobody", holder)
+	}
+}
@@ -0,0 +1,245 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/ringlogger"
+)
+
+// The log forwarder is off unless the LogForwarding admin registry key is set. It follows the
+// ring like /dumplog /tail does, so it ships the lines of the tunnel services as well as those
+// of the manager, and keeps lines that could not be sent for a later attempt, up to a limit.
+const (
+	logForwardInterval   = time.Second
+	logForwardBatchSize  = 512
+	logForwardMaxPending = 8192
+	logForwardMaxBackoff = 5 * time.Minute
+	logForwardTimeout    = 10 * time.Second
+
+	syslogFacilityDaemon = 3
+)
+
+type logForwarder struct {
+	target   *url.URL
+	hostname string
+	conn     net.Conn     // For syslog, kept open between batches.
+	client   *http.Client // For HTTP.
+}
+
+func newLogForwarder(rawURL string) (*logForwarder, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	forwarder := &logForwarder{target: target}
+	forwarder.hostname, _ = os.Hostname()
+	switch target.Scheme {
+	case "udp", "tcp":
+		if len(target.Port()) == 0 {
+			return nil, errors.New("Syslog address has no port")
+		}
+	case "http", "https":
+		forwarder.client = &http.Client{Timeout: logForwardTimeout}
+	default:
+		return nil, fmt.Errorf("Unsupported scheme %q", target.Scheme)
+	}
+	return forwarder, nil
+}
+
+func syslogSeverity(level ringlogger.Level) int {
+	switch level {
+	case ringlogger.LevelDebug:
+		return 7
+	case ringlogger.LevelWarning:
+		return 4
+	case ringlogger.LevelError:
+		return 3
+	}
+	return 6
+}
+
+// formatSyslogMessage formats a line as an RFC 5424 message, with the tag of the process that
+// wrote it, such as MGR, as its message ID.
+func formatSyslogMessage(line *ringlogger.FollowLine, hostname string) string {
+	if len(hostname) == 0 {
+		hostname = "-"
+	}
+	msgID, _ := line.Tags()
+	if len(msgID) == 0 {
+		msgID = "-"
+	}
+	return fmt.Sprintf("<%d>1 %s %s WireGuard - %s - %s", syslogFacilityDaemon*8+syslogSeverity(line.Level),
+		line.Stamp.UTC().Format("2006-01-02T15:04:05.000000Z"), hostname, msgID, line.String())
+}
+
+// forwardedLine is a line as it is posted to HTTP endpoints, in a JSON array per batch.
+type forwardedLine struct {
+	Time      time.Time `json:"time"`
+	Host      string    `json:"host"`
+	Level     string    `json:"level"`
+	Tag       string    `json:"tag,omitempty"`
+	Tunnel    string    `json:"tunnel,omitempty"`
+	Component string    `json:"component,omitempty"`
+	Message   string    `json:"message"`
+}
+
+func (forwarder *logForwarder) send(lines []ringlogger.FollowLine) error {
+	if forwarder.client != nil {
+		return forwarder.post(lines)
+	}
+	if forwarder.conn == nil {
+		conn, err := net.DialTimeout(forwarder.target.Scheme, forwarder.target.Host, logForwardTimeout)
+		if err != nil {
+			return err
+		}
+		forwarder.conn = conn
+	}
+	forwarder.conn.SetWriteDeadline(time.Now().Add(logForwardTimeout))
+	var err error
+	if forwarder.target.Scheme == "udp" {
+		for i := range lines {
+			if _, err = forwarder.conn.Write([]byte(formatSyslogMessage(&lines[i], forwarder.hostname))); err != nil {
+				break
+			}
+		}
+	} else {
+		// Messages over TCP are framed by their length, as RFC 6587 describes.
+		var batch bytes.Buffer
+		for i := range lines {
+			message := formatSyslogMessage(&lines[i], forwarder.hostname)
+			batch.WriteString(strconv.Itoa(len(message)))
+			batch.WriteByte(' ')
+			batch.WriteString(message)
+		}
+		_, err = forwarder.conn.Write(batch.Bytes())
+	}
+	if err != nil {
+		forwarder.conn.Close()
+		forwarder.conn = nil
+	}
+	return err
+}
+
+func (forwarder *logForwarder) post(lines []ringlogger.FollowLine) error {
+	batch := make([]forwardedLine, len(lines))
+	for i := range lines {
+		tag, tunnel := lines[i].Tags()
+		if !conf.TunnelNameIsValid(tunnel) {
+			tunnel = ""
+		}
+		batch[i] = forwardedLine{
+			Time:      lines[i].Stamp,
+			Host:      forwarder.hostname,
+			Level:     lines[i].Level.String(),
+			Tag:       tag,
+			Tunnel:    tunnel,
+			Component: lines[i].Component,
+			Message:   lines[i].String(),
+		}
+	}
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	response, err := forwarder.client.Post(forwarder.target.String(), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("Server responded with status %s", response.Status)
+	}
+	return nil
+}
+
+func forwardLogs() {
+	targets := conf.AdminStrings("LogForwarding")
+	if len(targets) == 0 || len(targets[0]) == 0 {
+		return
+	}
+	forwarder, err := newLogForwarder(strings.TrimSpace(targets[0]))
+	if err != nil {
+		log.Printf("Invalid log forwarding address: %v", err)
+		return
+	}
+	minLevel := ringlogger.LevelDebug
+	if levels := conf.AdminStrings("LogForwardingLevel"); len(levels) > 0 {
+		if minLevel, err = ringlogger.ParseLevel(levels[0]); err != nil {
+			log.Printf("Invalid log forwarding level: %v", err)
+			return
+		}
+	}
+	log.Printf("Forwarding log to %s", forwarder.target.Redacted())
+
+	// Lines from before the manager started were forwarded by its previous run, if at all.
+	_, cursor := ringlogger.Global.FollowFromCursor(ringlogger.CursorAll)
+	var (
+		pending     []ringlogger.FollowLine
+		dropped     int
+		backoff     time.Duration
+		nextAttempt time.Time
+	)
+	ticker := time.NewTicker(logForwardInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		var lines []ringlogger.FollowLine
+		lines, cursor = ringlogger.Global.FollowFromCursor(cursor)
+		for i := range lines {
+			if lines[i].Level >= minLevel {
+				pending = append(pending, lines[i])
+			}
+		}
+		if len(pending) > logForwardMaxPending {
+			dropped += len(pending) - logForwardMaxPending
+			pending = pending[len(pending)-logForwardMaxPending:]
+		}
+		for len(pending) > 0 && !time.Now().Before(nextAttempt) {
+			batch := pending
+			if len(batch) > logForwardBatchSize {
+				batch = batch[:logForwardBatchSize]
+			}
+			err = forwarder.send(batch)
+			if err != nil {
+				// Only the first failure is logged, since each log line would itself be queued.
+				if backoff == 0 {
+					log.Printf("Unable to forward log, retrying with backoff: %v", err)
+					backoff = logForwardInterval
+				} else if backoff *= 2; backoff > logForwardMaxBackoff {
+					backoff = logForwardMaxBackoff
+				}
+				nextAttempt = time.Now().Add(backoff)
+				break
+			}
+			pending = pending[len(batch):]
+			if backoff != 0 {
+				backoff = 0
+				if dropped > 0 {
+					log.Printf("Forwarding log again, after dropping %d lines", dropped)
+				} else {
+					log.Printf("Forwarding log again")
+				}
+				dropped = 0
+			}
+		}
+		if len(pending) == 0 {
+			pending = nil
+		}
+	}
+}
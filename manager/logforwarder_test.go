@@ -0,0 +1,43 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"golang.zx2c4.com/wireguard/windows/ringlogger"
+)
+
+func TestFormatSyslogMessage(t *testing.T) {
+	stamp := time.Date(2022, 3, 4, 5, 6, 7, 8000, time.UTC)
+	for _, test := range []struct {
+		line ringlogger.FollowLine
+		want string
+	}{
+		{ringlogger.FollowLine{Line: "[TUN] [office] Interface up", Stamp: stamp, Level: ringlogger.LevelInfo}, "<30>1 2022-03-04T05:06:07.000008Z host WireGuard - TUN - [TUN] [office] Interface up"},
+		{ringlogger.FollowLine{Line: "[MGR] Unable to bind", Stamp: stamp, Level: ringlogger.LevelError}, "<27>1 2022-03-04T05:06:07.000008Z host WireGuard - MGR - [MGR] [ERROR] Unable to bind"},
+		{ringlogger.FollowLine{Line: "untagged", Stamp: stamp, Level: ringlogger.LevelDebug}, "<31>1 2022-03-04T05:06:07.000008Z host WireGuard - - - [DEBUG] untagged"},
+	} {
+		if got := formatSyslogMessage(&test.line, "host"); got != test.want {
+			t.Errorf("Message is %q, want %q", got, test.want)
+		}
+	}
+}
+
+func TestNewLogForwarder(t *testing.T) {
+	for rawURL, valid := range map[string]bool{
+		"udp://logs.example.com:514":      true,
+		"tcp://10.0.0.1:601":              true,
+		"https://logs.example.com/ingest": true,
+		"udp://logs.example.com":          false,
+		"ftp://logs.example.com:21":       false,
+	} {
+		if _, err := newLogForwarder(rawURL); (err == nil) != valid {
+			t.Errorf("newLogForwarder(%q) returned %v", rawURL, err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+// The metrics exporter is off unless the PrometheusMetricsPort admin registry key is set, and
+// it only ever listens on the loopback interface. Since any local user can read it, peers are
+// labeled by their index and alias, and only by public key if PrometheusMetricsPublicKeys is set.
+
+type metricFamily struct {
+	name    string
+	help    string
+	kind    string
+	samples strings.Builder
+}
+
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (family *metricFamily) add(value any, labels ...string) {
+	family.samples.WriteString(family.name)
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i == 0 {
+			family.samples.WriteByte('{')
+		} else {
+			family.samples.WriteByte(',')
+		}
+		fmt.Fprintf(&family.samples, `%s="%s"`, labels[i], metricLabelEscaper.Replace(labels[i+1]))
+	}
+	if len(labels) > 1 {
+		family.samples.WriteByte('}')
+	}
+	fmt.Fprintf(&family.samples, " %v\n", value)
+}
+
+func (family *metricFamily) writeTo(out *strings.Builder) {
+	if family.samples.Len() == 0 {
+		return
+	}
+	fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", family.name, family.help, family.name, family.kind)
+	out.WriteString(family.samples.String())
+}
+
+// peerLabels returns the labels of a peer's samples.
+func peerLabels(tunnelName string, index int, peer *conf.Peer, withPublicKeys bool) []string {
+	labels := []string{"tunnel", tunnelName, "peer", strconv.Itoa(index)}
+	if len(peer.Name) > 0 {
+		labels = append(labels, "name", peer.Name)
+	}
+	if withPublicKeys {
+		labels = append(labels, "public_key", peer.PublicKey.String())
+	}
+	return labels
+}
+
+func writeMetrics(out *strings.Builder, withPublicKeys bool) error {
+	names, err := conf.ListConfigNames()
+	if err != nil {
+		return err
+	}
+	var (
+		up        = metricFamily{name: "wireguard_tunnel_up", help: "Whether the tunnel is active.", kind: "gauge"}
+		starts    = metricFamily{name: "wireguard_tunnel_service_starts_total", help: "Times the tunnel service has started since the manager started.", kind: "counter"}
+		failures  = metricFamily{name: "wireguard_tunnel_service_failures_total", help: "Times the tunnel service has stopped with an error since the manager started.", kind: "counter"}
+		rx        = metricFamily{name: "wireguard_peer_receive_bytes_total", help: "Bytes received from the peer.", kind: "counter"}
+		tx        = metricFamily{name: "wireguard_peer_transmit_bytes_total", help: "Bytes sent to the peer.", kind: "counter"}
+		handshake = metricFamily{name: "wireguard_peer_last_handshake_age_seconds", help: "Seconds since the latest handshake with the peer.", kind: "gauge"}
+	)
+	s := &ManagerService{}
+	for _, name := range names {
+		state, err := s.State(name)
+		if err != nil {
+			continue
+		}
+		if state == TunnelStarted {
+			up.add(1, "tunnel", name)
+		} else {
+			up.add(0, "tunnel", name)
+		}
+		trackedTunnelsLock.Lock()
+		starts.add(tunnelServiceStarts[name], "tunnel", name)
+		failures.add(tunnelServiceFailures[name], "tunnel", name)
+		trackedTunnelsLock.Unlock()
+		if state != TunnelStarted {
+			continue
+		}
+		config, err := runtimeConfig(name)
+		if err != nil {
+			continue
+		}
+		for i := range config.Peers {
+			peer := &config.Peers[i]
+			labels := peerLabels(name, i, peer, withPublicKeys)
+			rx.add(uint64(peer.RxBytes), labels...)
+			tx.add(uint64(peer.TxBytes), labels...)
+			if !peer.LastHandshakeTime.IsEmpty() {
+				age := time.Since(time.Unix(0, int64(peer.LastHandshakeTime)))
+				handshake.add(int64(age/time.Second), labels...)
+			}
+		}
+	}
+	for _, family := range []*metricFamily{&up, &starts, &failures, &rx, &tx, &handshake} {
+		family.writeTo(out)
+	}
+	return nil
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request, withPublicKeys bool) {
+	if r.URL.Path != "/metrics" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var out strings.Builder
+	err := writeMetrics(&out, withPublicKeys)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(out.String()))
+}
+
+func serveMetrics() {
+	port := conf.AdminInteger("PrometheusMetricsPort")
+	if port == 0 {
+		return
+	}
+	if port > 0xffff {
+		log.Printf("Invalid metrics port %d", port)
+		return
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.FormatUint(port, 10)))
+	if err != nil {
+		log.Printf("Unable to start metrics exporter: %v", err)
+		return
+	}
+	log.Printf("Serving metrics on http://%s/metrics", listener.Addr())
+	withPublicKeys := conf.AdminBool("PrometheusMetricsPublicKeys")
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handleMetrics(w, r, withPublicKeys)
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	err = server.Serve(listener)
+	if err != nil {
+		log.Printf("Metrics exporter stopped: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"strings"
+	"testing"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+func TestMetricFamily(t *testing.T) {
+	family := metricFamily{name: "wireguard_test", help: "A test.", kind: "gauge"}
+	var out strings.Builder
+	family.writeTo(&out)
+	if out.Len() != 0 {
+		t.Errorf("Empty family was written: %q", out.String())
+	}
+	family.add(1, "tunnel", `a"b\c`)
+	family.add(2)
+	family.writeTo(&out)
+	const expected = "# HELP wireguard_test A test.\n# TYPE wireguard_test gauge\nwireguard_test{tunnel=\"a\\\"b\\\\c\"} 1\nwireguard_test 2\n"
+	if out.String() != expected {
+		t.Errorf("Unexpected output:\n%s\nexpected:\n%s", out.String(), expected)
+	}
+}
+
+func TestPeerLabels(t *testing.T) {
+	peer := &conf.Peer{PublicKey: conf.Key{1}}
+	labels := strings.Join(peerLabels("office", 2, peer, false), ",")
+	if labels != "tunnel,office,peer,2" {
+		t.Errorf("Unexpected labels without public keys: %s", labels)
+	}
+	peer.Name = "gateway"
+	labels = strings.Join(peerLabels("office", 2, peer, true), ",")
+	if labels != "tunnel,office,peer,2,name,gateway,public_key,"+peer.PublicKey.String() {
+		t.Errorf("Unexpected labels with public keys: %s", labels)
+	}
+}
@@ -0,0 +1,80 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"time"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+// Peers rekey every two minutes while there is traffic, and sessions expire after three, so a peer
+// without a handshake for longer than that is no longer connected.
+const StaleHandshakeAge = 3 * time.Minute
+
+// PeerStatus is the liveness of a single peer of a running tunnel, as `wg show` reports it.
+type PeerStatus struct {
+	Name              string
+	PublicKey         conf.Key
+	Endpoint          conf.Endpoint
+	LastHandshakeTime conf.HandshakeTime
+	RxBytes           conf.Bytes
+	TxBytes           conf.Bytes
+	Stale             bool
+}
+
+// peerStatuses lists the peers of a runtime configuration, named after their address allocations,
+// or else their aliases.
+func peerStatuses(config *conf.Config, pools *conf.AddressPools, now time.Time) []PeerStatus {
+	names := make(map[string]string, len(pools.Allocations))
+	for _, allocation := range pools.Allocations {
+		names[allocation.PublicKey] = allocation.Name
+	}
+	statuses := make([]PeerStatus, 0, len(config.Peers))
+	for i := range config.Peers {
+		peer := &config.Peers[i]
+		name, ok := names[peer.PublicKey.String()]
+		if !ok {
+			name = peer.Name
+		}
+		status := PeerStatus{
+			Name:              name,
+			PublicKey:         peer.PublicKey,
+			Endpoint:          peer.Endpoint,
+			LastHandshakeTime: peer.LastHandshakeTime,
+			RxBytes:           peer.RxBytes,
+			TxBytes:           peer.TxBytes,
+		}
+		if peer.LastHandshakeTime.IsEmpty() {
+			status.Stale = true
+		} else {
+			lastHandshake := time.Unix(0, 0).Add(time.Duration(peer.LastHandshakeTime))
+			status.Stale = now.Sub(lastHandshake) > StaleHandshakeAge
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// PeerStatuses returns the liveness of every peer of a running tunnel. Limited clients do not
+// receive the public keys of the peers, as with RuntimeConfig.
+func (s *ManagerService) PeerStatuses(tunnelName string) ([]PeerStatus, error) {
+	config, err := runtimeConfig(tunnelName)
+	if err != nil {
+		return nil, err
+	}
+	pools, err := conf.LoadAddressPools(tunnelName)
+	if err != nil {
+		return nil, err
+	}
+	statuses := peerStatuses(config, pools, time.Now())
+	if s.elevatedToken == 0 {
+		for i := range statuses {
+			statuses[i].PublicKey = conf.Key{}
+		}
+	}
+	return statuses, nil
+}
@@ -0,0 +1,39 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+func TestPeerStatuses(t *testing.T) {
+	config, err := conf.FromWgQuick(ipcTestConfig+"\n\n[Peer]\nPublicKey = TrMvSoP4jYQlY6RIzBgbssQqY3vxI2Pi+y71lOWWXX0=\nAllowedIPs = 10.192.122.4/32", "peerStatusTest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Peers = append(config.Peers, conf.Peer{PublicKey: config.Peers[0].PublicKey})
+	now := time.Now()
+	config.Peers[0].LastHandshakeTime = conf.HandshakeTime(now.Add(-time.Minute).Sub(time.Unix(0, 0)))
+	config.Peers[1].LastHandshakeTime = conf.HandshakeTime(now.Add(-time.Hour).Sub(time.Unix(0, 0)))
+	pools := conf.AddressPools{Allocations: []conf.AddressAllocation{{PublicKey: config.Peers[1].PublicKey.String(), Name: "laptop"}}}
+
+	statuses := peerStatuses(config, &pools, now)
+	if len(statuses) != 3 {
+		t.Fatalf("Expected 3 statuses, got %d", len(statuses))
+	}
+	if statuses[0].Stale || !statuses[1].Stale || !statuses[2].Stale {
+		t.Errorf("Unexpected staleness %v, %v, %v", statuses[0].Stale, statuses[1].Stale, statuses[2].Stale)
+	}
+	if statuses[0].Name != "" || statuses[1].Name != "laptop" {
+		t.Errorf("Unexpected names %q, %q", statuses[0].Name, statuses[1].Name)
+	}
+	if statuses[0].Endpoint != config.Peers[0].Endpoint {
+		t.Errorf("Endpoint is %s", statuses[0].Endpoint.String())
+	}
+}
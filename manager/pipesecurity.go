@@ -0,0 +1,92 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"log"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+// Security teams may replace the security descriptors of the automation pipe and of the pipes
+// handed to UI processes with their own, given as SDDL strings in the AutomationPipeSecurity and
+// UIHandleSecurity policies. A policy that does not parse is ignored, so that a typo leaves the
+// defaults in place rather than opening things up or locking everyone out.
+
+// policySecurityDescriptor returns the security descriptor in the named policy, or the one in
+// fallback if the policy is unset or invalid. An empty fallback yields nil.
+func policySecurityDescriptor(name, fallback string) *windows.SECURITY_DESCRIPTOR {
+	if values := conf.AdminStrings(name); len(values) > 0 && len(values[0]) > 0 {
+		sd, err := windows.SecurityDescriptorFromString(values[0])
+		if err == nil {
+			return sd
+		}
+		log.Printf("Ignoring invalid %s policy: %v", name, err)
+	}
+	if len(fallback) == 0 {
+		return nil
+	}
+	sd, err := windows.SecurityDescriptorFromString(fallback)
+	if err != nil {
+		log.Printf("Unable to parse default security descriptor for %s: %v", name, err)
+		return nil
+	}
+	return sd
+}
+
+func securityAttributes(sd *windows.SECURITY_DESCRIPTOR) *windows.SecurityAttributes {
+	if sd == nil {
+		return nil
+	}
+	return &windows.SecurityAttributes{
+		Length:             uint32(unsafe.Sizeof(windows.SecurityAttributes{})),
+		SecurityDescriptor: sd,
+	}
+}
+
+// automationPipeSecurityDescriptor grants access to SYSTEM and elevated administrators and, if
+// the LimitedOperatorUI policy is set, to Network Configuration Operators, mirroring who gets a
+// UI, unless the AutomationPipeSecurity policy says otherwise.
+func automationPipeSecurityDescriptor() *windows.SECURITY_DESCRIPTOR {
+	sddl := "O:SYD:P(A;;GA;;;SY)(A;;GA;;;BA)"
+	if conf.AdminBool("LimitedOperatorUI") {
+		sddl += "(A;;GRGW;;;NO)"
+	}
+	return policySecurityDescriptor("AutomationPipeSecurity", sddl)
+}
+
+// uiHandleSecurityDescriptor is the UIHandleSecurity policy, or nil for the default security of
+// objects created by the manager service.
+func uiHandleSecurityDescriptor() *windows.SECURITY_DESCRIPTOR {
+	return policySecurityDescriptor("UIHandleSecurity", "")
+}
+
+// uiPipe is os.Pipe, except that the pipe is created with sa.
+func uiPipe(sa *windows.SecurityAttributes) (r, w *os.File, err error) {
+	var p [2]windows.Handle
+	err = windows.CreatePipe(&p[0], &p[1], sa, 0)
+	if err != nil {
+		return nil, nil, os.NewSyscallError("CreatePipe", err)
+	}
+	return os.NewFile(uintptr(p[0]), "|0"), os.NewFile(uintptr(p[1]), "|1"), nil
+}
+
+// logEffectiveSecurity records the security descriptors in effect, so that they can be checked
+// in the log after changing the policies.
+func logEffectiveSecurity() {
+	if sd := automationPipeSecurityDescriptor(); sd != nil {
+		log.Printf("Automation pipe security: %s", sd)
+	}
+	if sd := uiHandleSecurityDescriptor(); sd != nil {
+		log.Printf("UI handle security: %s", sd)
+	} else {
+		log.Println("UI handle security: default")
+	}
+}
@@ -0,0 +1,169 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"errors"
+	"log"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+// The manager decides which profile of a tunnel is in effect, since it is the one watching the
+// networks, and records it as the active profile, which the tunnel service applies when it
+// starts. A running tunnel whose profile changes is restarted, since the DNS servers cannot be
+// swapped underneath it.
+
+// selectProfile records the profile of the named tunnel that is to be used on state, or on the
+// current networks if state is nil, and reports whether it differs from the one recorded before.
+func selectProfile(tunnelName string, state *conf.NetworkState) (bool, error) {
+	profiles, err := conf.LoadProfiles(tunnelName)
+	if err != nil {
+		return false, err
+	}
+	if profiles.IsEmpty() {
+		return false, nil
+	}
+	if state == nil {
+		state = currentNetworkState()
+	}
+	var active string
+	if profile := profiles.Select(state); profile != nil {
+		active = profile.Name
+	}
+	if active == profiles.Active {
+		return false, nil
+	}
+	profiles.Active = active
+	return true, conf.SaveProfiles(tunnelName, profiles)
+}
+
+// restartForProfile restarts the named tunnel in the background if it is running.
+func restartForProfile(tunnelName string) {
+	state, err := (&ManagerService{}).State(tunnelName)
+	if err != nil || state != TunnelStarted {
+		return
+	}
+	go func() {
+		err := restartTunnel(tunnelName)
+		if err != nil {
+			log.Printf("[%s] Unable to restart tunnel for new profile: %v", tunnelName, err)
+		}
+	}()
+}
+
+// switchProfiles selects the profiles of all tunnels for state, and restarts those running
+// tunnels whose profile changed.
+func switchProfiles(state *conf.NetworkState) {
+	names, err := conf.ListConfigNames()
+	if err != nil {
+		return
+	}
+	for _, name := range names {
+		changed, err := selectProfile(name, state)
+		if err != nil {
+			log.Printf("[%s] Unable to select profile: %v", name, err)
+			continue
+		}
+		if changed {
+			log.Printf("[%s] Switching profile on %s", name, state)
+			restartForProfile(name)
+		}
+	}
+}
+
+// loadWithActiveProfile returns the stored configuration of a tunnel, as amended by its active
+// profile, which is what its service is running with.
+func loadWithActiveProfile(tunnelName string) (*conf.Config, error) {
+	config, err := conf.LoadFromName(tunnelName)
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := conf.LoadProfiles(tunnelName)
+	if err != nil {
+		return nil, err
+	}
+	if profile := profiles.Find(profiles.Active); profile != nil {
+		err = profile.Apply(config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// Profiles returns the profiles of a tunnel. Limited clients do not receive the public keys of
+// the peers that they override.
+func (s *ManagerService) Profiles(tunnelName string) (*conf.Profiles, error) {
+	_, err := conf.LoadFromName(tunnelName)
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := conf.LoadProfiles(tunnelName)
+	if err != nil {
+		return nil, err
+	}
+	if s.elevatedToken == 0 {
+		profiles.Redact()
+	}
+	return profiles, nil
+}
+
+// SetProfiles replaces the profiles of a tunnel, keeping track of the active one itself.
+func (s *ManagerService) SetProfiles(tunnelName string, profiles *conf.Profiles) error {
+	if s.elevatedToken == 0 {
+		return windows.ERROR_ACCESS_DENIED
+	}
+	_, err := conf.LoadFromName(tunnelName)
+	if err != nil {
+		return err
+	}
+	stored, err := conf.LoadProfiles(tunnelName)
+	if err != nil {
+		return err
+	}
+	profiles.Active = stored.Active
+	if profiles.Find(profiles.Manual) == nil {
+		profiles.Manual = ""
+	}
+	err = conf.SaveProfiles(tunnelName, profiles)
+	if err != nil {
+		return err
+	}
+	changed, err := selectProfile(tunnelName, nil)
+	if changed {
+		restartForProfile(tunnelName)
+	}
+	return err
+}
+
+// SelectProfile makes the named profile of a tunnel the one in effect, regardless of networks,
+// or, if profileName is empty, goes back to selecting it by network.
+func (s *ManagerService) SelectProfile(tunnelName, profileName string) error {
+	_, err := conf.LoadFromName(tunnelName)
+	if err != nil {
+		return err
+	}
+	profiles, err := conf.LoadProfiles(tunnelName)
+	if err != nil {
+		return err
+	}
+	if len(profileName) > 0 && profiles.Find(profileName) == nil {
+		return errors.New("Profile does not exist")
+	}
+	profiles.Manual = profileName
+	err = conf.SaveProfiles(tunnelName, profiles)
+	if err != nil {
+		return err
+	}
+	changed, err := selectProfile(tunnelName, nil)
+	if changed {
+		restartForProfile(tunnelName)
+	}
+	return err
+}
@@ -0,0 +1,194 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"errors"
+	"log"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+// A protected tunnel is only started for a UI session if the UI passes along credentials that the
+// user just entered, which are checked by logging them on, and must belong to the user of that
+// session. The credentials come packed by CredUIPromptForWindowsCredentials, so that a password,
+// a PIN, or Windows Hello all work, as long as LSA can log them on. Tunnels started by the manager
+// itself, through activation rules or groups that the administrator set up, are not affected.
+// The automation pipe has no way to carry credentials, so it only starts protected tunnels for
+// Local System and elevated administrators.
+
+var (
+	errConfirmationRequired = errors.New("Activating this tunnel requires confirming your identity")
+	errProtectedAutomation  = errors.New("Only administrators may activate this tunnel through the automation pipe")
+)
+
+const logon32LogonInteractive = 2
+
+type lsaString struct {
+	Length        uint16
+	MaximumLength uint16
+	Buffer        *byte
+}
+
+type tokenSource struct {
+	SourceName       [8]byte
+	SourceIdentifier windows.LUID
+}
+
+type quotaLimits struct {
+	PagedPoolLimit        uintptr
+	NonPagedPoolLimit     uintptr
+	MinimumWorkingSetSize uintptr
+	MaximumWorkingSetSize uintptr
+	PagefileLimit         uintptr
+	TimeLimit             int64
+}
+
+// verifyCredentials logs on the packed credentials, and checks that they are those of user.
+func verifyCredentials(user *windows.SID, authPackage uint32, authBuffer []byte) error {
+	if len(authBuffer) == 0 {
+		return errors.New("No credentials were given")
+	}
+	var lsa windows.Handle
+	err := lsaConnectUntrusted(&lsa)
+	if err != nil {
+		return err
+	}
+	defer lsaDeregisterLogonProcess(lsa)
+
+	origin := []byte("WireGuard")
+	originName := lsaString{uint16(len(origin)), uint16(len(origin)), &origin[0]}
+	source := tokenSource{SourceName: [8]byte{'W', 'i', 'r', 'e', 'G', 'u', 'a', 'r'}}
+	var (
+		profile       unsafe.Pointer
+		profileLength uint32
+		logonID       windows.LUID
+		token         windows.Token
+		quotas        quotaLimits
+		subStatus     windows.NTStatus
+	)
+	err = lsaLogonUser(lsa, &originName, logon32LogonInteractive, authPackage, &authBuffer[0], uint32(len(authBuffer)), nil, &source, &profile, &profileLength, &logonID, &token, &quotas, &subStatus)
+	if err != nil {
+		return err
+	}
+	if profile != nil {
+		lsaFreeReturnBuffer(profile)
+	}
+	defer token.Close()
+	tokenUser, err := token.GetTokenUser()
+	if err != nil {
+		return err
+	}
+	if !windows.EqualSid(tokenUser.User.Sid, user) {
+		return errors.New("Credentials are those of another user")
+	}
+	return nil
+}
+
+// checkProtected refuses to start a protected tunnel for a UI session without confirmation.
+func (s *ManagerService) checkProtected(tunnelName string) error {
+	if s.userSid == nil {
+		return nil
+	}
+	protected, err := conf.LoadProtectedTunnels()
+	if err != nil {
+		return err
+	}
+	if protected.Contains(tunnelName) {
+		return errConfirmationRequired
+	}
+	return nil
+}
+
+// checkProtectedAutomation refuses to start a protected tunnel for an automation client who is
+// not an administrator.
+func (s *ManagerService) checkProtectedAutomation(tunnelName string) error {
+	if s.elevatedToken != 0 {
+		return nil
+	}
+	protected, err := conf.LoadProtectedTunnels()
+	if err != nil {
+		return err
+	}
+	if protected.Contains(tunnelName) {
+		return errProtectedAutomation
+	}
+	return nil
+}
+
+func (s *ManagerService) Protected(tunnelName string) (bool, error) {
+	protected, err := conf.LoadProtectedTunnels()
+	if err != nil {
+		return false, err
+	}
+	return protected.Contains(tunnelName), nil
+}
+
+func (s *ManagerService) SetProtected(tunnelName string, protect bool) error {
+	if s.elevatedToken == 0 {
+		return windows.ERROR_ACCESS_DENIED
+	}
+	if !conf.TunnelNameIsValid(tunnelName) {
+		return errors.New("Tunnel name is not valid")
+	}
+	protected, err := conf.LoadProtectedTunnels()
+	if err != nil {
+		return err
+	}
+	if !protected.Set(tunnelName, protect) {
+		return nil
+	}
+	return conf.SaveProtectedTunnels(protected)
+}
+
+// StartConfirmed starts a tunnel, protected or not, once the credentials that the user of the
+// UI session entered have been verified.
+func (s *ManagerService) StartConfirmed(tunnelName string, authPackage uint32, authBuffer []byte) error {
+	defer func() {
+		for i := range authBuffer {
+			authBuffer[i] = 0
+		}
+	}()
+	if s.userSid == nil {
+		return windows.ERROR_ACCESS_DENIED
+	}
+	err := verifyCredentials(s.userSid, authPackage, authBuffer)
+	if err != nil {
+		log.Printf("[%s] Unable to confirm identity of user to activate tunnel: %v", tunnelName, err)
+		return errors.New("Your identity could not be confirmed")
+	}
+	log.Printf("[%s] User confirmed their identity to activate tunnel", tunnelName)
+	return (&ManagerService{}).Start(tunnelName)
+}
+
+// removeProtection forgets that a deleted tunnel was protected.
+func removeProtection(tunnelName string) error {
+	protected, err := conf.LoadProtectedTunnels()
+	if err != nil {
+		return err
+	}
+	if !protected.Set(tunnelName, false) {
+		return nil
+	}
+	return conf.SaveProtectedTunnels(protected)
+}
+
+// renameProtection keeps a renamed tunnel protected if it was.
+func renameProtection(tunnelName, newName string) error {
+	protected, err := conf.LoadProtectedTunnels()
+	if err != nil {
+		return err
+	}
+	if !protected.Contains(tunnelName) {
+		return nil
+	}
+	protected.Set(tunnelName, false)
+	protected.Set(newName, true)
+	return conf.SaveProtectedTunnels(protected)
+}
@@ -0,0 +1,175 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+// Preshared keys are rotated one peer at a time. Each new key is first handed to the exchange
+// command of the policy, which is expected to install it on the other side and to succeed only
+// once it has. Only then is the key applied to the adapter, if the tunnel is running, and saved.
+// Since a preshared key only enters new handshakes, sessions established with the old key go on
+// until the next rekey, so there is no need for both sides to switch at exactly the same moment.
+
+const presharedKeyExchangeTimeout = time.Minute
+
+// runPresharedKeyExchange runs the exchange command of a policy as cmd /c would, with the new key
+// on its standard input, rather than in its environment or command line, where other processes
+// could see it.
+func runPresharedKeyExchange(command, tunnelName string, peer *conf.Peer, presharedKey *conf.Key) error {
+	ctx, cancel := context.WithTimeout(context.Background(), presharedKeyExchangeTimeout)
+	defer cancel()
+
+	comspec, ok := os.LookupEnv("COMSPEC")
+	if !ok || len(comspec) == 0 {
+		system32, err := windows.GetSystemDirectory()
+		if err != nil {
+			return err
+		}
+		comspec = filepath.Join(system32, "cmd.exe")
+	}
+	cmd := exec.CommandContext(ctx, comspec)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow:    true,
+		CmdLine:       fmt.Sprintf("cmd /c %s", command),
+		CreationFlags: windows.CREATE_NO_WINDOW,
+	}
+	cmd.Env = append(os.Environ(),
+		"WIREGUARD_TUNNEL_NAME="+tunnelName,
+		"WIREGUARD_PEER_PUBLIC_KEY="+peer.PublicKey.String(),
+		"WIREGUARD_PEER_NAME="+peer.Name,
+	)
+	cmd.Stdin = strings.NewReader(presharedKey.String() + "\n")
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	scanner := bufio.NewScanner(&output)
+	for scanner.Scan() {
+		log.Printf("[%s] cmd> %s", tunnelName, scanner.Text())
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("Exchange command did not finish within %v", presharedKeyExchangeTimeout)
+	}
+	return err
+}
+
+func setAdapterPresharedKey(config *conf.Config, i int) error {
+	driverAdapter, err := findDriverAdapter(config.Name)
+	if err != nil {
+		return err
+	}
+	err = driverAdapter.SetConfiguration(config.ToDriverPresharedKeyConfiguration(i))
+	driverAdapter.Unlock()
+	if err != nil {
+		releaseDriverAdapter(config.Name)
+	}
+	return err
+}
+
+// rotatePresharedKeys gives each peer of a tunnel a new preshared key, recording the outcome of
+// each in state. Peers whose exchange fails keep their old key.
+func rotatePresharedKeys(tunnelName string, policy *conf.PresharedKeyRotationPolicy, state *conf.PresharedKeyRotationState) error {
+	config, err := conf.LoadFromName(tunnelName)
+	if err != nil {
+		return err
+	}
+	tunnelState, err := (&ManagerService{}).State(tunnelName)
+	running := err == nil && tunnelState == TunnelStarted
+	rotated := 0
+	for i := range config.Peers {
+		peer := &config.Peers[i]
+		record := conf.PresharedKeyRotationRecord{Time: time.Now(), PublicKey: peer.PublicKey.String()}
+		presharedKey, err := conf.NewPresharedKey()
+		if err == nil {
+			err = runPresharedKeyExchange(policy.ExchangeCommand, tunnelName, peer, presharedKey)
+		}
+		if err == nil {
+			peer.PresharedKey = *presharedKey
+			rotated++
+			if running {
+				if err := setAdapterPresharedKey(config, i); err != nil {
+					// The other side has the new key already, so it is saved regardless, and taken up
+					// when the tunnel next starts.
+					log.Printf("[%s] Unable to apply new preshared key of peer %s to adapter: %v", tunnelName, peer.DisplayName(), err)
+				}
+			}
+			log.Printf("[%s] Rotated preshared key of peer %s", tunnelName, peer.DisplayName())
+		} else {
+			record.Error = err.Error()
+			log.Printf("[%s] Unable to rotate preshared key of peer %s: %v", tunnelName, peer.DisplayName(), err)
+		}
+		state.Record(record)
+	}
+	if rotated == 0 {
+		return nil
+	}
+	return saveRotatedConfig(config)
+}
+
+// checkPresharedKeyRotations rotates the preshared keys of tunnels whose policy says it is time.
+// Tunnels seen with a policy for the first time have their clock started instead.
+func checkPresharedKeyRotations() {
+	names, err := conf.ListConfigNames()
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, name := range names {
+		policy := conf.LoadPresharedKeyRotationPolicy(name)
+		if policy == nil {
+			continue
+		}
+		state, err := conf.LoadPresharedKeyRotationState(name)
+		if err != nil {
+			log.Printf("[%s] Unable to load preshared key rotation state: %v", name, err)
+			continue
+		}
+		if state.LastRotation.IsZero() {
+			state.LastRotation = now
+			conf.SavePresharedKeyRotationState(name, state)
+			continue
+		}
+		if now.Sub(state.LastRotation) < policy.Interval {
+			continue
+		}
+		// A private key rotation in progress would have its change to the configuration undone.
+		rotatingKeysLock.Lock()
+		if rotatingKeys[name] {
+			rotatingKeysLock.Unlock()
+			continue
+		}
+		rotatingKeys[name] = true
+		rotatingKeysLock.Unlock()
+		state.LastRotation = now
+		err = rotatePresharedKeys(name, policy, &state)
+		rotatingKeysLock.Lock()
+		delete(rotatingKeys, name)
+		rotatingKeysLock.Unlock()
+		if err != nil {
+			log.Printf("[%s] Unable to rotate preshared keys: %v", name, err)
+		}
+		err = conf.SavePresharedKeyRotationState(name, state)
+		if err != nil {
+			log.Printf("[%s] Unable to save preshared key rotation state: %v", name, err)
+		}
+	}
+}
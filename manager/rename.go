@@ -0,0 +1,205 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"errors"
+	"log"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc/mgr"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+var errTunnelNameExists = errors.New("A tunnel with this name already exists")
+
+// tunnelServiceStartType returns the start type of the service of a tunnel, and whether it is
+// installed at all, which is what makes a tunnel come back after a reboot.
+func tunnelServiceStartType(tunnelName string) (startType uint32, installed bool) {
+	m, err := serviceManager()
+	if err != nil {
+		return
+	}
+	serviceName, err := conf.ServiceNameOfTunnel(tunnelName)
+	if err != nil {
+		return
+	}
+	service, err := m.OpenService(serviceName)
+	if err != nil {
+		return
+	}
+	defer service.Close()
+	config, err := service.Config()
+	if err != nil {
+		return mgr.StartAutomatic, true
+	}
+	return config.StartType, true
+}
+
+func setTunnelServiceStartType(tunnelName string, startType uint32) error {
+	m, err := serviceManager()
+	if err != nil {
+		return err
+	}
+	serviceName, err := conf.ServiceNameOfTunnel(tunnelName)
+	if err != nil {
+		return err
+	}
+	service, err := m.OpenService(serviceName)
+	if err != nil {
+		return err
+	}
+	defer service.Close()
+	config, err := service.Config()
+	if err != nil {
+		return err
+	}
+	config.StartType = startType
+	return service.UpdateConfig(config)
+}
+
+// Rename renames a tunnel along with what belongs to it: its stored configuration and the files
+// next to it, its place in groups, and its protection. If its service is installed, it is
+// reinstalled under the new name with the same start type, so that a running tunnel keeps running
+// and is still started at boot.
+func (s *ManagerService) Rename(tunnelName, newName string) error {
+	if s.elevatedToken == 0 {
+		return windows.ERROR_ACCESS_DENIED
+	}
+	policies := conf.LoadPolicies()
+	if policies.DisableTunnelDeletion {
+		return errTunnelDeletionDisabled
+	}
+	if policies.DisableConfigEditing {
+		return errConfigEditingDisabled
+	}
+	if newName == tunnelName {
+		return nil
+	}
+	if !conf.TunnelNameIsValid(newName) {
+		return errors.New("Tunnel name is not valid")
+	}
+	if conf.TunnelNameExists(newName) {
+		return errTunnelNameExists
+	}
+	_, err := conf.LoadFromName(tunnelName)
+	if err != nil {
+		return err
+	}
+
+	startType, installed := tunnelServiceStartType(tunnelName)
+	if installed {
+		err = s.Stop(tunnelName)
+		if err != nil {
+			return err
+		}
+		err = s.WaitForStop(tunnelName)
+		if err != nil {
+			return err
+		}
+	}
+	renamePendingUsage(tunnelName, newName)
+	err = conf.RenameName(tunnelName, newName)
+	if err != nil {
+		if installed {
+			if path, err := (&conf.Config{Name: tunnelName}).Path(); err == nil {
+				InstallTunnel(path)
+			}
+		}
+		return err
+	}
+	noteConfigChange(tunnelName)
+	noteConfigChange(newName)
+	log.Printf("[%s] Renamed tunnel to ‘%s’", tunnelName, newName)
+	err = renameInTunnelGroups(tunnelName, newName)
+	if err != nil {
+		log.Printf("[%s] Unable to rename in groups: %v", newName, err)
+	}
+	err = renameProtection(tunnelName, newName)
+	if err != nil {
+		log.Printf("[%s] Unable to keep protection: %v", newName, err)
+	}
+	if !installed {
+		return nil
+	}
+
+	// The tunnel was already allowed to run, so it is reinstalled without confirmation or
+	// looking for conflicts again.
+	path, err := (&conf.Config{Name: newName}).Path()
+	if err != nil {
+		return err
+	}
+	err = InstallTunnel(path)
+	if err != nil {
+		return err
+	}
+	if startType != mgr.StartAutomatic {
+		err = setTunnelServiceStartType(newName, startType)
+		if err != nil {
+			log.Printf("[%s] Unable to restore start type of service: %v", newName, err)
+		}
+	}
+	return nil
+}
+
+// ReplaceConfig overwrites the stored configuration of a tunnel with tunnelConfig, renaming the
+// tunnel first if tunnelConfig has another name. Unlike deleting the tunnel and creating it anew,
+// this keeps the files next to the configuration, such as its usage and its key rotation state.
+// The tunnel is not restarted, so a running tunnel keeps its previous configuration until it is.
+func (s *ManagerService) ReplaceConfig(tunnelName string, tunnelConfig *conf.Config) error {
+	if s.elevatedToken == 0 {
+		return windows.ERROR_ACCESS_DENIED
+	}
+	if conf.LoadPolicies().DisableConfigEditing {
+		return errConfigEditingDisabled
+	}
+	if len(tunnelConfig.ToWgQuick()) > conf.MaxConfigSize {
+		return errors.New("Configuration is too large")
+	}
+	_, err := conf.LoadFromName(tunnelName)
+	if err != nil {
+		return err
+	}
+	if tunnelConfig.Name != tunnelName {
+		err = s.Rename(tunnelName, tunnelConfig.Name)
+		if err != nil {
+			return err
+		}
+	}
+	err = tunnelConfig.Save(true)
+	if err != nil {
+		return err
+	}
+	noteConfigChange(tunnelConfig.Name)
+	log.Printf("[%s] Replaced configuration", tunnelConfig.Name)
+	return nil
+}
+
+// Duplicate saves a copy of the configuration of a tunnel under a new name, and, with
+// newPrivateKey, a freshly generated private key, so that the copy is a new peer to the same
+// servers rather than one that fights with the original over its key.
+func (s *ManagerService) Duplicate(tunnelName, newName string, newPrivateKey bool) (*Tunnel, error) {
+	if s.elevatedToken == 0 {
+		return nil, windows.ERROR_ACCESS_DENIED
+	}
+	if conf.TunnelNameExists(newName) {
+		return nil, errTunnelNameExists
+	}
+	c, err := conf.LoadFromName(tunnelName)
+	if err != nil {
+		return nil, err
+	}
+	c.Name = newName
+	if newPrivateKey {
+		key, err := conf.NewPrivateKey()
+		if err != nil {
+			return nil, err
+		}
+		c.Interface.PrivateKey = *key
+	}
+	return s.Create(c)
+}
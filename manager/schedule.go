@@ -0,0 +1,106 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+// Schedules are checked against the wall clock every so often, rather than with a timer set for
+// each boundary, since such timers do not count the time that the computer spends asleep, nor
+// notice changes to the clock. Boundaries that pass during sleep are caught up with after resuming.
+const scheduleCheckInterval = 30 * time.Second
+
+var scheduleLock sync.Mutex
+
+// applySchedule brings a tunnel in line with its schedule at now.
+func applySchedule(name string, schedule *conf.Schedule, now time.Time) {
+	s := &ManagerService{}
+	state, err := s.State(name)
+	if err != nil {
+		return
+	}
+	if schedule.WantsActive(now) {
+		if state == TunnelStopped {
+			log.Printf("[%s] Activating tunnel on schedule", name)
+			err = s.Start(name)
+		}
+	} else if state == TunnelStarted {
+		log.Printf("[%s] Deactivating tunnel on schedule", name)
+		err = s.Stop(name)
+	}
+	if err != nil {
+		log.Printf("[%s] Unable to apply schedule: %v", name, err)
+	}
+}
+
+// checkSchedules applies the schedules that have a boundary after from and no later than to, or
+// all of them if from is zero.
+func checkSchedules(from, to time.Time) {
+	scheduleLock.Lock()
+	defer scheduleLock.Unlock()
+
+	names, err := conf.ListConfigNames()
+	if err != nil {
+		log.Printf("Unable to list tunnels for schedules: %v", err)
+		return
+	}
+	for _, name := range names {
+		schedule, err := conf.LoadSchedule(name)
+		if err != nil {
+			log.Printf("[%s] Unable to load schedule: %v", name, err)
+			continue
+		}
+		if schedule.IsEmpty() || !from.IsZero() && !schedule.CrossesBoundary(from, to) {
+			continue
+		}
+		applySchedule(name, schedule, to)
+	}
+}
+
+// runSchedules applies schedules at their boundaries, so that a tunnel toggled by hand is left
+// alone until the next one. The manager starting counts as a boundary, since the last one may
+// have passed while the computer was off.
+func runSchedules() {
+	var last time.Time
+	for {
+		now := time.Now().Round(0) // The wall clock, rather than the monotonic one.
+		checkSchedules(last, now)
+		last = now
+		time.Sleep(scheduleCheckInterval)
+	}
+}
+
+func (s *ManagerService) Schedule(tunnelName string) (*conf.Schedule, error) {
+	_, err := conf.LoadFromName(tunnelName)
+	if err != nil {
+		return nil, err
+	}
+	return conf.LoadSchedule(tunnelName)
+}
+
+// SetSchedule replaces the schedule of a tunnel, which takes effect at its next boundary, like
+// any other change to the state of the tunnel.
+func (s *ManagerService) SetSchedule(tunnelName string, schedule *conf.Schedule) error {
+	if s.elevatedToken == 0 {
+		return windows.ERROR_ACCESS_DENIED
+	}
+	_, err := conf.LoadFromName(tunnelName)
+	if err != nil {
+		return err
+	}
+	err = schedule.Validate()
+	if err != nil {
+		return err
+	}
+	return conf.SaveSchedule(tunnelName, schedule)
+}
@@ -20,7 +20,9 @@ import (
 	"golang.zx2c4.com/wireguard/windows/driver"
 
 	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/crashreport"
 	"golang.zx2c4.com/wireguard/windows/elevate"
+	"golang.zx2c4.com/wireguard/windows/etw"
 	"golang.zx2c4.com/wireguard/windows/ringlogger"
 	"golang.zx2c4.com/wireguard/windows/services"
 )
@@ -28,6 +30,7 @@ import (
 type managerService struct{}
 
 func (service *managerService) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (svcSpecificEC bool, exitCode uint32) {
+	defer crashreport.Recover("MGR")
 	changes <- svc.Status{State: svc.StartPending}
 
 	var err error
@@ -62,14 +65,41 @@ func (service *managerService) Execute(args []string, r <-chan svc.ChangeRequest
 		return
 	}
 
+	if err := etw.Register(); err != nil {
+		log.Printf("Unable to register ETW provider: %v", err)
+	} else {
+		defer etw.Unregister()
+	}
+	startStateHooks()
 	err = watchNewTunnelServices()
 	if err != nil {
 		serviceError = services.ErrorTrackTunnels
 		return
 	}
+	go coordinateBootStart()
 
 	conf.RegisterStoreChangeCallback(func() { conf.MigrateUnencryptedConfigs(changeTunnelServiceConfigFilePath) })
+	IPCServerNotifyTunnelsChange() // Learns the initial set of tunnels, before there are clients to notify.
 	conf.RegisterStoreChangeCallback(IPCServerNotifyTunnelsChange)
+	conf.RegisterStoreChangeCallback(onConfigDirectoryChange)
+
+	logEffectiveSecurity()
+	go serveAutomation()
+	go serveUserScripts()
+	go serveMetrics()
+	go forwardLogs()
+	go sampleTransferRates()
+	go rotateKeysPeriodically()
+	go runWatchdogs()
+	go runSchedules()
+	go publishWMI()
+
+	activationCallback, activationErr := watchActivationRules()
+	if activationErr != nil {
+		log.Printf("Unable to watch networks for activation rules: %v", activationErr)
+	} else {
+		defer activationCallback.Unregister()
+	}
 
 	procs := make(map[uint32]*uiProcess)
 	aliveSessions := make(map[uint32]bool)
@@ -170,22 +200,23 @@ func (service *managerService) Execute(args []string, r <-chan svc.ChangeRequest
 				first = false
 			}
 
-			ourReader, theirWriter, err := os.Pipe()
+			uiSA := securityAttributes(uiHandleSecurityDescriptor())
+			ourReader, theirWriter, err := uiPipe(uiSA)
 			if err != nil {
 				log.Printf("Unable to create pipe: %v", err)
 				return
 			}
-			theirReader, ourWriter, err := os.Pipe()
+			theirReader, ourWriter, err := uiPipe(uiSA)
 			if err != nil {
 				log.Printf("Unable to create pipe: %v", err)
 				return
 			}
-			theirEvents, ourEvents, err := os.Pipe()
+			theirEvents, ourEvents, err := uiPipe(uiSA)
 			if err != nil {
 				log.Printf("Unable to create pipe: %v", err)
 				return
 			}
-			IPCServerListen(ourReader, ourWriter, ourEvents, elevatedToken)
+			IPCServerListen(ourReader, ourWriter, ourEvents, elevatedToken, user.User.Sid)
 			theirLogMapping, err := ringlogger.Global.ExportInheritableMappingHandle()
 			if err != nil {
 				log.Printf("Unable to export inheritable mapping handle for logging: %v", err)
@@ -290,8 +321,7 @@ func (service *managerService) Execute(args []string, r <-chan svc.ChangeRequest
 loop:
 	for {
 		select {
-		case <-quitManagersChan:
-			uninstall = true
+		case uninstall = <-quitManagersChan:
 			break loop
 		case c := <-r:
 			switch c.Cmd {
@@ -341,6 +371,7 @@ loop:
 	}
 	procsLock.Unlock()
 	procsGroup.Wait()
+	saveUsage()
 	if uninstall {
 		err = UninstallManager()
 		if err != nil {
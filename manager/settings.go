@@ -0,0 +1,43 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"log"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+// Settings returns the machine-wide settings.
+func (s *ManagerService) Settings() (*conf.Settings, error) {
+	return conf.LoadSettings()
+}
+
+// SetSettings changes the machine-wide settings, which the manager and tunnel services read anew
+// whenever they need them.
+func (s *ManagerService) SetSettings(settings *conf.Settings) error {
+	if s.elevatedToken == 0 {
+		return windows.ERROR_ACCESS_DENIED
+	}
+	err := conf.SaveSettings(settings)
+	if err != nil {
+		return err
+	}
+	log.Printf("Changed settings: update check every %d hours, kill switch by default %t, adapter logging by default %v", settings.UpdateCheckHours, settings.DefaultKillSwitch, settings.DefaultAdapterLogLevel)
+	return nil
+}
+
+// updateCheckInterval returns how many hours to wait between update checks, or
+// conf.UpdateCheckNever.
+func updateCheckInterval() int {
+	settings, err := conf.LoadSettings()
+	if err != nil || settings.UpdateCheckHours == 0 {
+		return conf.UpdateCheckHourly
+	}
+	return settings.UpdateCheckHours
+}
@@ -0,0 +1,67 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/updater"
+)
+
+var silentUpdateQueued uint32
+
+// queueSilentUpdate installs the update that was found, if silent updates are on, once the
+// maintenance window opens and no tunnel is in the middle of starting or stopping. Running
+// tunnels are handed over to the new version by the installer, which then restarts the manager.
+func queueSilentUpdate() {
+	if !atomic.CompareAndSwapUint32(&silentUpdateQueued, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreUint32(&silentUpdateQueued, 0)
+		for {
+			settings, err := conf.LoadSettings()
+			if err != nil || !settings.SilentUpdates {
+				return
+			}
+			globalState := trackedTunnelsGlobalState()
+			if !settings.InMaintenanceWindow(time.Now()) || globalState == TunnelStarting || globalState == TunnelStopping {
+				jitterSleep(time.Minute*4, time.Minute*6)
+				continue
+			}
+			err = installUpdateSilently()
+			if err == nil {
+				return
+			}
+			log.Printf("Unable to install update silently: %v", err)
+			reportEvent(eventSilentUpdateFailed, true, fmt.Sprintf("WireGuard was unable to install an update automatically: %v", err))
+			// Try again later in the window, or in the next one, rather than over and over.
+			jitterSleep(time.Hour-time.Minute*3, time.Hour+time.Minute*3)
+		}
+	}()
+}
+
+func installUpdateSilently() error {
+	log.Println("Installing update silently")
+	reportEvent(eventSilentUpdateInstalling, false, "WireGuard is installing an update automatically. The outcome of the installation itself is reported by MsiInstaller.")
+	for dp := range updater.DownloadVerifyAndInstallQuietly() {
+		IPCServerNotifyUpdateProgress(dp)
+		if len(dp.Activity) > 0 {
+			log.Printf("Silent update: %s", dp.Activity)
+		}
+		if dp.Error != nil {
+			return dp.Error
+		}
+		if dp.Complete {
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+// State hooks are off unless the StateChangeWebhook or StateChangeCommand admin registry keys
+// are set. Unlike the scripts of a tunnel, they run in the manager, so they also learn of tunnel
+// services that fail to start at all. Events are handled one at a time, in order, by a single
+// goroutine, so that a slow hook holds up later events rather than the tracking of tunnels.
+const (
+	stateHookQueueSize = 64
+	stateHookTimeout   = 30 * time.Second
+)
+
+// stateHookEvent is what hooks are given, as the body of webhook requests and on the standard
+// input of commands.
+type stateHookEvent struct {
+	Tunnel string    `json:"tunnel"`
+	State  string    `json:"state"` // started, stopped, or error.
+	Error  string    `json:"error,omitempty"`
+	Time   time.Time `json:"time"`
+	Host   string    `json:"host"`
+}
+
+type stateHooks struct {
+	webhook  *url.URL
+	command  string
+	client   *http.Client
+	hostname string
+	events   chan stateHookEvent
+}
+
+var runningStateHooks *stateHooks
+
+func newStateHooks(webhook, command string) (*stateHooks, error) {
+	hooks := &stateHooks{command: command, events: make(chan stateHookEvent, stateHookQueueSize)}
+	if len(webhook) > 0 {
+		target, err := url.Parse(webhook)
+		if err != nil {
+			return nil, err
+		}
+		if target.Scheme != "http" && target.Scheme != "https" {
+			return nil, fmt.Errorf("Unsupported scheme %q", target.Scheme)
+		}
+		hooks.webhook = target
+		hooks.client = &http.Client{Timeout: stateHookTimeout}
+	}
+	hooks.hostname, _ = os.Hostname()
+	return hooks, nil
+}
+
+// stateHookEventName returns the name of the event of a change to state, or an empty string for
+// states that hooks are not told of, such as starting and stopping.
+func stateHookEventName(state TunnelState, err error) string {
+	switch {
+	case err != nil:
+		return "error"
+	case state == TunnelStarted:
+		return "started"
+	case state == TunnelStopped:
+		return "stopped"
+	}
+	return ""
+}
+
+// notifyStateHooks queues an event for the hooks, if there are any, without waiting for them.
+func notifyStateHooks(tunnelName string, state TunnelState, err error) {
+	hooks := runningStateHooks
+	if hooks == nil {
+		return
+	}
+	name := stateHookEventName(state, err)
+	if len(name) == 0 {
+		return
+	}
+	event := stateHookEvent{
+		Tunnel: tunnelName,
+		State:  name,
+		Error:  errToString(err),
+		Time:   time.Now().UTC(),
+		Host:   hooks.hostname,
+	}
+	select {
+	case hooks.events <- event:
+	default:
+		log.Printf("[%s] Dropping %s event, because state change hooks are falling behind", tunnelName, name)
+	}
+}
+
+func (hooks *stateHooks) post(body []byte) error {
+	response, err := hooks.client.Post(hooks.webhook.String(), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("Server responded with status %s", response.Status)
+	}
+	return nil
+}
+
+// run runs the command as cmd /c would, with the event in its environment as well as on its
+// standard input.
+func (hooks *stateHooks) run(event *stateHookEvent, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), stateHookTimeout)
+	defer cancel()
+
+	comspec, ok := os.LookupEnv("COMSPEC")
+	if !ok || len(comspec) == 0 {
+		system32, err := windows.GetSystemDirectory()
+		if err != nil {
+			return err
+		}
+		comspec = filepath.Join(system32, "cmd.exe")
+	}
+	cmd := exec.CommandContext(ctx, comspec)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow:    true,
+		CmdLine:       fmt.Sprintf("cmd /c %s", hooks.command),
+		CreationFlags: windows.CREATE_NO_WINDOW,
+	}
+	cmd.Env = append(os.Environ(),
+		"WIREGUARD_TUNNEL_NAME="+event.Tunnel,
+		"WIREGUARD_TUNNEL_STATE="+event.State,
+		"WIREGUARD_TUNNEL_ERROR="+event.Error,
+	)
+	cmd.Stdin = bytes.NewReader(append(body, '\n'))
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	scanner := bufio.NewScanner(&output)
+	for scanner.Scan() {
+		log.Printf("[%s] cmd> %s", event.Tunnel, scanner.Text())
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("State change command did not finish within %v", stateHookTimeout)
+	}
+	return err
+}
+
+func (hooks *stateHooks) handle(event *stateHookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	if hooks.webhook != nil {
+		if err := hooks.post(body); err != nil {
+			errs = append(errs, fmt.Errorf("webhook: %w", err))
+		}
+	}
+	if len(hooks.command) > 0 {
+		if err := hooks.run(event, body); err != nil {
+			errs = append(errs, fmt.Errorf("command: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// startStateHooks reads the hooks from the registry and starts handling their events. It is to
+// be called before tunnels are tracked, so that no change of state is missed.
+func startStateHooks() {
+	var webhook, command string
+	if values := conf.AdminStrings("StateChangeWebhook"); len(values) > 0 {
+		webhook = strings.TrimSpace(values[0])
+	}
+	if values := conf.AdminStrings("StateChangeCommand"); len(values) > 0 {
+		command = strings.TrimSpace(values[0])
+	}
+	if len(webhook) == 0 && len(command) == 0 {
+		return
+	}
+	hooks, err := newStateHooks(webhook, command)
+	if err != nil {
+		log.Printf("Invalid state change webhook: %v", err)
+		return
+	}
+	if hooks.webhook != nil {
+		log.Printf("Posting tunnel state changes to %s", hooks.webhook.Redacted())
+	}
+	if len(hooks.command) > 0 {
+		log.Printf("Running command on tunnel state changes: %#q", hooks.command)
+	}
+	runningStateHooks = hooks
+	go func() {
+		for event := range hooks.events {
+			if err := hooks.handle(&event); err != nil {
+				log.Printf("[%s] Unable to run state change hooks for %s event: %v", event.Tunnel, event.State, err)
+			}
+		}
+	}()
+}
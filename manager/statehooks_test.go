@@ -0,0 +1,64 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStateHookEventName(t *testing.T) {
+	for _, test := range []struct {
+		state TunnelState
+		err   error
+		want  string
+	}{
+		{TunnelStarted, nil, "started"},
+		{TunnelStopped, nil, "stopped"},
+		{TunnelStopped, errors.New("failed"), "error"},
+		{TunnelStarting, nil, ""},
+		{TunnelStopping, nil, ""},
+		{TunnelUnknown, nil, ""},
+	} {
+		if got := stateHookEventName(test.state, test.err); got != test.want {
+			t.Errorf("Event of %v with %v is %q, want %q", test.state, test.err, got, test.want)
+		}
+	}
+}
+
+func TestStateHookWebhook(t *testing.T) {
+	received := make(chan stateHookEvent, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event stateHookEvent
+		if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&event) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- event
+	}))
+	defer server.Close()
+
+	hooks, err := newStateHooks(server.URL, "")
+	if err != nil {
+		t.Fatalf("Unable to create hooks: %v", err)
+	}
+	event := stateHookEvent{Tunnel: "office", State: "error", Error: "failed", Time: time.Now().UTC().Truncate(time.Second), Host: "host"}
+	err = hooks.handle(&event)
+	if err != nil {
+		t.Fatalf("Unable to post event: %v", err)
+	}
+	if got := <-received; got != event {
+		t.Errorf("Posted event is %+v, want %+v", got, event)
+	}
+
+	if _, err = newStateHooks("ftp://example.com/", ""); err == nil {
+		t.Error("Unsupported webhook scheme should fail")
+	}
+}
@@ -0,0 +1,39 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+// https://docs.microsoft.com/en-us/windows/win32/api/namedpipeapi/nf-namedpipeapi-impersonatenamedpipeclient
+//sys	impersonateNamedPipeClient(pipe windows.Handle) (err error) = advapi32.ImpersonateNamedPipeClient
+
+// https://docs.microsoft.com/en-us/windows/win32/api/wlanapi/nf-wlanapi-wlanopenhandle
+//sys	wlanOpenHandle(clientVersion uint32, reserved uintptr, negotiatedVersion *uint32, handle *windows.Handle) (ret error) = wlanapi.WlanOpenHandle
+
+// https://docs.microsoft.com/en-us/windows/win32/api/wlanapi/nf-wlanapi-wlanclosehandle
+//sys	wlanCloseHandle(handle windows.Handle, reserved uintptr) (ret error) = wlanapi.WlanCloseHandle
+
+// https://docs.microsoft.com/en-us/windows/win32/api/wlanapi/nf-wlanapi-wlanenuminterfaces
+//sys	wlanEnumInterfaces(handle windows.Handle, reserved uintptr, interfaceList **wlanInterfaceInfoList) (ret error) = wlanapi.WlanEnumInterfaces
+
+// https://docs.microsoft.com/en-us/windows/win32/api/wlanapi/nf-wlanapi-wlanqueryinterface
+//sys	wlanQueryInterface(handle windows.Handle, interfaceGUID *windows.GUID, opCode uint32, reserved uintptr, dataSize *uint32, data *unsafe.Pointer, opcodeValueType *uint32) (ret error) = wlanapi.WlanQueryInterface
+
+// https://docs.microsoft.com/en-us/windows/win32/api/wlanapi/nf-wlanapi-wlanfreememory
+//sys	wlanFreeMemory(memory unsafe.Pointer) = wlanapi.WlanFreeMemory
+
+// https://docs.microsoft.com/en-us/windows/win32/api/ntsecapi/nf-ntsecapi-lsaconnectuntrusted
+//sys	lsaConnectUntrusted(handle *windows.Handle) (ntstatus error) = secur32.LsaConnectUntrusted
+
+// https://docs.microsoft.com/en-us/windows/win32/api/ntsecapi/nf-ntsecapi-lsaderegisterlogonprocess
+//sys	lsaDeregisterLogonProcess(handle windows.Handle) (ntstatus error) = secur32.LsaDeregisterLogonProcess
+
+// https://docs.microsoft.com/en-us/windows/win32/api/ntsecapi/nf-ntsecapi-lsalogonuser
+//sys	lsaLogonUser(handle windows.Handle, originName *lsaString, logonType uint32, authenticationPackage uint32, authenticationInformation *byte, authenticationInformationLength uint32, localGroups *windows.Tokengroups, sourceContext *tokenSource, profileBuffer *unsafe.Pointer, profileBufferLength *uint32, logonID *windows.LUID, token *windows.Token, quotas *quotaLimits, subStatus *windows.NTStatus) (ntstatus error) = secur32.LsaLogonUser
+
+// https://docs.microsoft.com/en-us/windows/win32/api/ntsecapi/nf-ntsecapi-lsafreereturnbuffer
+//sys	lsaFreeReturnBuffer(buffer unsafe.Pointer) (ntstatus error) = secur32.LsaFreeReturnBuffer
+
+// https://docs.microsoft.com/en-us/windows/win32/api/securitybaseapi/nf-securitybaseapi-createrestrictedtoken
+//sys	createRestrictedToken(existingToken windows.Token, flags uint32, disableSidCount uint32, sidsToDisable *windows.SIDAndAttributes, deletePrivilegeCount uint32, privilegesToDelete *windows.LUIDAndAttributes, restrictedSidCount uint32, sidsToRestrict *windows.SIDAndAttributes, newToken *windows.Token) (err error) = advapi32.CreateRestrictedToken
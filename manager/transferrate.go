@@ -0,0 +1,125 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"time"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/driver"
+)
+
+const transferRateInterval = time.Second
+
+// TransferRate is the traffic of all peers of a tunnel during one sampling interval.
+type TransferRate struct {
+	RxBytes  uint64
+	TxBytes  uint64
+	Interval time.Duration
+}
+
+type transferTotals struct {
+	rx, tx        uint64
+	lastHandshake conf.HandshakeTime
+	when          time.Time
+}
+
+// readTransferTotals sums the counters of all peers straight from the driver, without loading
+// the stored configuration, which would mean decrypting it every second.
+func readTransferTotals(tunnelName string) (transferTotals, error) {
+	driverAdapter, err := findDriverAdapter(tunnelName)
+	if err != nil {
+		return transferTotals{}, err
+	}
+	interfaze, err := driverAdapter.Configuration()
+	if err != nil {
+		driverAdapter.Unlock()
+		releaseDriverAdapter(tunnelName)
+		return transferTotals{}, err
+	}
+	totals := transferTotals{when: time.Now()}
+	var p *driver.Peer
+	for i := uint32(0); i < interfaze.PeerCount; i++ {
+		if p == nil {
+			p = interfaze.FirstPeer()
+		} else {
+			p = p.NextPeer()
+		}
+		totals.rx += p.RxBytes
+		totals.tx += p.TxBytes
+		if p.LastHandshake != 0 {
+			// The driver counts in 100ns intervals since 1601, as FILETIME does.
+			if lastHandshake := conf.HandshakeTime((p.LastHandshake - 116444736000000000) * 100); lastHandshake > totals.lastHandshake {
+				totals.lastHandshake = lastHandshake
+			}
+		}
+	}
+	driverAdapter.Unlock()
+	return totals, nil
+}
+
+// sampleTransferRates reads the counters of running tunnels from the driver once per interval, and
+// sends the differences to the UIs, so that each UI does not have to poll for full runtime configurations.
+// The totals are kept as well, as the snapshot returned by TunnelStats, and the differences are
+// counted as usage. While no UI is listening, the counters are only read for usage, and less often.
+func sampleTransferRates() {
+	previous := make(map[string]transferTotals)
+	var lastSample, lastSave time.Time
+	ticker := time.NewTicker(transferRateInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		managerServicesLock.RLock()
+		listening := len(managerServices) > 0
+		managerServicesLock.RUnlock()
+		if !listening {
+			setTunnelStatsSnapshot(nil)
+			if now.Sub(lastSample) < usageSampleInterval {
+				continue
+			}
+		}
+		lastSample = now
+
+		var running []string
+		trackedTunnelsLock.Lock()
+		for name, state := range trackedTunnels {
+			if state == TunnelStarted {
+				running = append(running, name)
+			}
+		}
+		trackedTunnelsLock.Unlock()
+
+		current := make(map[string]transferTotals, len(running))
+		for _, name := range running {
+			totals, err := readTransferTotals(name)
+			if err != nil {
+				continue
+			}
+			current[name] = totals
+			last, ok := previous[name]
+			// Counters restart along with the tunnel, and peers may have been removed since.
+			if !ok || totals.rx < last.rx || totals.tx < last.tx {
+				continue
+			}
+			rate := TransferRate{
+				RxBytes:  totals.rx - last.rx,
+				TxBytes:  totals.tx - last.tx,
+				Interval: totals.when.Sub(last.when),
+			}
+			recordUsage(name, totals.when, rate.RxBytes, rate.TxBytes)
+			if listening {
+				IPCServerNotifyTransferRate(name, rate)
+			}
+		}
+		previous = current
+		if listening {
+			setTunnelStatsSnapshot(current)
+		}
+		if now.Sub(lastSave) >= usageSaveInterval {
+			saveUsage()
+			lastSave = now
+		}
+	}
+}
@@ -0,0 +1,53 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"sort"
+	"sync"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+// TunnelStats is the traffic of a running tunnel, summed over all of its peers, along with the
+// most recent handshake of any of them.
+type TunnelStats struct {
+	Name              string
+	LastHandshakeTime conf.HandshakeTime
+	RxBytes           conf.Bytes
+	TxBytes           conf.Bytes
+}
+
+var (
+	tunnelStatsSnapshot     []TunnelStats
+	tunnelStatsSnapshotLock sync.RWMutex
+)
+
+func setTunnelStatsSnapshot(totals map[string]transferTotals) {
+	snapshot := make([]TunnelStats, 0, len(totals))
+	for name, t := range totals {
+		snapshot = append(snapshot, TunnelStats{
+			Name:              name,
+			LastHandshakeTime: t.lastHandshake,
+			RxBytes:           conf.Bytes(t.rx),
+			TxBytes:           conf.Bytes(t.tx),
+		})
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		return snapshot[i].Name < snapshot[j].Name
+	})
+	tunnelStatsSnapshotLock.Lock()
+	tunnelStatsSnapshot = snapshot
+	tunnelStatsSnapshotLock.Unlock()
+}
+
+// TunnelStats returns the statistics of all running tunnels as of the last sampling interval, so
+// that the tunnel list does not need to fetch the runtime configuration of each of them.
+func (s *ManagerService) TunnelStats() ([]TunnelStats, error) {
+	tunnelStatsSnapshotLock.RLock()
+	defer tunnelStatsSnapshotLock.RUnlock()
+	return append([]TunnelStats(nil), tunnelStatsSnapshot...), nil
+}
@@ -21,12 +21,17 @@ import (
 	"golang.org/x/sys/windows/svc/mgr"
 
 	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/etw"
 	"golang.zx2c4.com/wireguard/windows/services"
 )
 
 var (
 	trackedTunnels     = make(map[string]TunnelState)
 	trackedTunnelsLock = sync.Mutex{}
+
+	// Counters since the manager started, by tunnel name, guarded by trackedTunnelsLock.
+	tunnelServiceStarts   = make(map[string]uint64)
+	tunnelServiceFailures = make(map[string]uint64)
 )
 
 func trackedTunnelsGlobalState() (state TunnelState) {
@@ -212,6 +217,8 @@ func trackTunnelService(tunnelName string, service *mgr.Service) {
 			trackedTunnels[tunnelName] = TunnelStopped
 			trackedTunnelsLock.Unlock()
 			IPCServerNotifyTunnelChange(tunnelName, TunnelStopped, nil)
+			notifyStateHooks(tunnelName, TunnelStopped, nil)
+			etw.TunnelStateChanged(tunnelName, automationStateName(TunnelStopped), "")
 			return true
 		}
 		return false
@@ -246,8 +253,15 @@ func trackTunnelService(tunnelName string, service *mgr.Service) {
 		if state != lastState {
 			trackedTunnelsLock.Lock()
 			trackedTunnels[tunnelName] = state
+			if state == TunnelStarted {
+				tunnelServiceStarts[tunnelName]++
+			} else if tunnelError != nil {
+				tunnelServiceFailures[tunnelName]++
+			}
 			trackedTunnelsLock.Unlock()
 			IPCServerNotifyTunnelChange(tunnelName, state, tunnelError)
+			notifyStateHooks(tunnelName, state, tunnelError)
+			etw.TunnelStateChanged(tunnelName, automationStateName(state), errToString(tunnelError))
 			lastState = state
 		}
 		if state == TunnelUnknown && checkForDisabled() {
@@ -259,7 +273,10 @@ func trackTunnelService(tunnelName string, service *mgr.Service) {
 		trackedTunnelsLock.Lock()
 		trackedTunnels[tunnelName] = TunnelStopped
 		trackedTunnelsLock.Unlock()
-		IPCServerNotifyTunnelChange(tunnelName, TunnelStopped, fmt.Errorf("Unable to continue monitoring service, so stopping: %w", err))
+		err = fmt.Errorf("Unable to continue monitoring service, so stopping: %w", err)
+		IPCServerNotifyTunnelChange(tunnelName, TunnelStopped, err)
+		notifyStateHooks(tunnelName, TunnelStopped, err)
+		etw.TunnelStateChanged(tunnelName, automationStateName(TunnelStopped), err.Error())
 		service.Control(svc.Stop)
 	}
 }
@@ -7,6 +7,7 @@
 
  import (
 	 "errors"
+	 "log"
 	 "runtime"
 	 "sync/atomic"
 	 "syscall"
@@ -43,6 +44,15 @@
 	 }
 	 defer windows.DestroyEnvironmentBlock(environmentBlock)
  
+	 // Beschränke das Token auf Schreibzugriffe, die dem Benutzer selbst gewährt sind
+	 restrictedToken, err := restrictUIToken(token)
+	 if err != nil {
+		 log.Printf("Unable to restrict token of UI process: %v", err)
+		 restrictedToken = token
+	 } else {
+		 defer restrictedToken.Close()
+	 }
+ 
 	 // Erstelle und verwalte Prozessattributliste
 	 attributeList, err := windows.NewProcThreadAttributeList(1)
 	 if err != nil {
@@ -66,11 +76,11 @@
 	 attributeList.Update(windows.PROC_THREAD_ATTRIBUTE_HANDLE_LIST, handlePtr, uintptr(len(handles))*unsafe.Sizeof(windows.Handle(0)))
  
 	 // Erstelle Prozess mit optimierten Flags; creationFlags als uint32 deklariert
-	 var creationFlags uint32 = windows.CREATE_DEFAULT_ERROR_MODE | windows.CREATE_UNICODE_ENVIRONMENT | windows.EXTENDED_STARTUPINFO_PRESENT
+	 var creationFlags uint32 = windows.CREATE_DEFAULT_ERROR_MODE | windows.CREATE_UNICODE_ENVIRONMENT | windows.EXTENDED_STARTUPINFO_PRESENT | windows.CREATE_SUSPENDED
  
 	 pi := new(windows.ProcessInformation)
 	 err = windows.CreateProcessAsUser(
-		 token,
+		 restrictedToken,
 		 executable16,
 		 args16,
 		 nil,
@@ -86,6 +96,16 @@
 		 return nil, err
 	 }
  
+	 // Binde den Prozess an das Job-Objekt, bevor er läuft, damit er den Manager nicht überlebt
+	 job, err := uiJobObject()
+	 if err == nil {
+		 err = windows.AssignProcessToJobObject(job, pi.Process)
+	 }
+	 if err != nil {
+		 log.Printf("Unable to assign UI process to job object: %v", err)
+	 }
+	 windows.ResumeThread(pi.Thread)
+ 
 	 // Schließe Thread-Handle sofort, da er nicht benötigt wird
 	 windows.CloseHandle(pi.Thread)
  
@@ -0,0 +1,82 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// The UI of an administrator runs with their elevated token, but it does everything privileged
+// through the IPC handles that it inherits, so it is given a write-restricted copy of that token,
+// with which it may only write where its user or logon session is granted access, such as its
+// profile, and not where only administrators are, such as the program and system directories. It
+// also runs in a job object that the manager holds open for as long as it runs, so that the UI does
+// not outlive it, even if the manager crashes.
+
+const (
+	disableMaxPrivilege = 0x1
+	writeRestricted     = 0x8
+)
+
+// restrictUIToken returns a write-restricted copy of token, with all of its privileges removed.
+func restrictUIToken(token windows.Token) (windows.Token, error) {
+	user, err := token.GetTokenUser()
+	if err != nil {
+		return 0, err
+	}
+	restrictedCode, err := windows.CreateWellKnownSid(windows.WinRestrictedCodeSid)
+	if err != nil {
+		return 0, err
+	}
+	restrictingSids := []windows.SIDAndAttributes{{Sid: user.User.Sid}, {Sid: restrictedCode}}
+	groups, err := token.GetTokenGroups()
+	if err != nil {
+		return 0, err
+	}
+	// The logon session is what the window station and desktop grant access to.
+	for _, group := range groups.AllGroups() {
+		if group.Attributes&windows.SE_GROUP_LOGON_ID == windows.SE_GROUP_LOGON_ID {
+			restrictingSids = append(restrictingSids, windows.SIDAndAttributes{Sid: group.Sid})
+		}
+	}
+	var restrictedToken windows.Token
+	err = createRestrictedToken(token, disableMaxPrivilege|writeRestricted, 0, nil, 0, nil, uint32(len(restrictingSids)), &restrictingSids[0], &restrictedToken)
+	if err != nil {
+		return 0, err
+	}
+	return restrictedToken, nil
+}
+
+var (
+	uiJob     windows.Handle
+	uiJobErr  error
+	uiJobOnce sync.Once
+)
+
+// uiJobObject returns the job object of UI processes, which kills them once the manager exits and
+// the job's only handle is closed with it.
+func uiJobObject() (windows.Handle, error) {
+	uiJobOnce.Do(func() {
+		uiJob, uiJobErr = windows.CreateJobObject(nil, nil)
+		if uiJobErr != nil {
+			return
+		}
+		info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{
+			BasicLimitInformation: windows.JOBOBJECT_BASIC_LIMIT_INFORMATION{
+				LimitFlags: windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE,
+			},
+		}
+		_, uiJobErr = windows.SetInformationJobObject(uiJob, windows.JobObjectExtendedLimitInformation, uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)))
+		if uiJobErr != nil {
+			windows.CloseHandle(uiJob)
+			uiJob = 0
+		}
+	})
+	return uiJob, uiJobErr
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 	_ "unsafe"
 
+	"golang.zx2c4.com/wireguard/windows/conf"
 	"golang.zx2c4.com/wireguard/windows/services"
 	"golang.zx2c4.com/wireguard/windows/updater"
 	"golang.zx2c4.com/wireguard/windows/version"
@@ -44,9 +45,19 @@ func checkForUpdates() {
 	}
 	noError, didNotify := true, false
 	for {
+		hours := updateCheckInterval()
+		if hours == conf.UpdateCheckNever {
+			// The setting is looked at again every so often, in case checking is turned back on.
+			jitterSleep(time.Hour-time.Minute*3, time.Hour+time.Minute*3)
+			continue
+		}
 		update, err := updater.CheckForUpdate()
+		if err == nil && update != nil && !update.RolledBack() {
+			// The setting may have been turned on since the update was first found.
+			queueSilentUpdate()
+		}
 		if err == nil && update != nil && !didNotify {
-			log.Println("An update is available")
+			log.Printf("An update is available on the %s channel", update.Channel())
 			updateState = UpdateStateFoundUpdate
 			IPCServerNotifyUpdateFound(updateState)
 			didNotify = true
@@ -59,7 +70,8 @@ func checkForUpdates() {
 				jitterSleep(time.Minute*25, time.Minute*30)
 			}
 		} else {
-			jitterSleep(time.Hour-time.Minute*3, time.Hour+time.Minute*3)
+			interval := time.Hour * time.Duration(hours)
+			jitterSleep(interval-time.Minute*3, interval+time.Minute*3)
 		}
 	}
 }
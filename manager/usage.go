@@ -0,0 +1,99 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+// Usage is counted from the same samples as the transfer rates, which are taken less often while
+// no UI is listening. Traffic between the last sample and the stopping of a tunnel is not counted.
+// What has been counted is kept in memory and added to what is on disk every so often, rather than
+// rewriting the files every sample.
+const (
+	usageSampleInterval = time.Minute
+	usageSaveInterval   = 5 * time.Minute
+)
+
+var (
+	pendingUsage     = make(map[string]*conf.Usage)
+	pendingUsageLock sync.Mutex
+)
+
+func recordUsage(tunnelName string, when time.Time, rx, tx uint64) {
+	pendingUsageLock.Lock()
+	defer pendingUsageLock.Unlock()
+	usage := pendingUsage[tunnelName]
+	if usage == nil {
+		usage = &conf.Usage{}
+		pendingUsage[tunnelName] = usage
+	}
+	usage.Add(when, rx, tx)
+}
+
+// saveUsage adds the usage counted since the last save to that on disk.
+func saveUsage() {
+	pendingUsageLock.Lock()
+	defer pendingUsageLock.Unlock()
+	for tunnelName, usage := range pendingUsage {
+		if usage.IsEmpty() {
+			continue
+		}
+		stored, err := conf.LoadUsage(tunnelName)
+		if err != nil {
+			log.Printf("[%s] Unable to load usage: %v", tunnelName, err)
+			continue
+		}
+		stored.Merge(usage)
+		err = conf.SaveUsage(tunnelName, stored)
+		if err != nil {
+			log.Printf("[%s] Unable to save usage: %v", tunnelName, err)
+			continue
+		}
+	}
+	pendingUsage = make(map[string]*conf.Usage)
+}
+
+// deleteUsage forgets the usage of a tunnel, including that which has not been saved yet.
+func deleteUsage(tunnelName string) error {
+	pendingUsageLock.Lock()
+	defer pendingUsageLock.Unlock()
+	delete(pendingUsage, tunnelName)
+	return conf.DeleteUsage(tunnelName)
+}
+
+// renamePendingUsage counts the usage of a renamed tunnel that has not been saved yet under its new
+// name.
+func renamePendingUsage(tunnelName, newName string) {
+	pendingUsageLock.Lock()
+	defer pendingUsageLock.Unlock()
+	if usage := pendingUsage[tunnelName]; usage != nil {
+		delete(pendingUsage, tunnelName)
+		pendingUsage[newName] = usage
+	}
+}
+
+// Usage returns the daily and monthly traffic of a tunnel, up to the latest sample.
+func (s *ManagerService) Usage(tunnelName string) (*conf.Usage, error) {
+	_, err := conf.LoadFromName(tunnelName)
+	if err != nil {
+		return nil, err
+	}
+	pendingUsageLock.Lock()
+	defer pendingUsageLock.Unlock()
+	usage, err := conf.LoadUsage(tunnelName)
+	if err != nil {
+		return nil, err
+	}
+	if pending := pendingUsage[tunnelName]; pending != nil {
+		usage.Merge(pending)
+	}
+	return usage, nil
+}
@@ -0,0 +1,257 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/services"
+)
+
+// Scripts of tunnels that are to run as the signed-in user, rather than as Local System, are
+// started here, on behalf of the tunnel services, as described by services.RunUserScript.
+const (
+	userScriptPipeSDDL   = "O:SYD:P(A;;GA;;;SY)"
+	maxUserScriptOutput  = 1000
+	userScriptDesktop    = `winsta0\default`
+	noActiveConsoleUser  = 0xffffffff
+	userScriptWaitMargin = 5000
+)
+
+func serveUserScripts() {
+	name16, err := windows.UTF16PtrFromString(services.UserScriptPipeName)
+	if err != nil {
+		log.Printf("Unable to start user script pipe: %v", err)
+		return
+	}
+	sd, err := windows.SecurityDescriptorFromString(userScriptPipeSDDL)
+	if err != nil {
+		log.Printf("Unable to start user script pipe: %v", err)
+		return
+	}
+	sa := securityAttributes(sd)
+	first := uint32(windows.FILE_FLAG_FIRST_PIPE_INSTANCE)
+	for {
+		pipe, err := windows.CreateNamedPipe(name16, windows.PIPE_ACCESS_DUPLEX|first,
+			windows.PIPE_TYPE_BYTE|windows.PIPE_READMODE_BYTE|windows.PIPE_WAIT|windows.PIPE_REJECT_REMOTE_CLIENTS,
+			windows.PIPE_UNLIMITED_INSTANCES, 4096, 4096, 0, sa)
+		if err != nil {
+			log.Printf("Unable to create user script pipe: %v", err)
+			return
+		}
+		first = 0
+		err = windows.ConnectNamedPipe(pipe, nil)
+		if err != nil && err != windows.ERROR_PIPE_CONNECTED {
+			windows.CloseHandle(pipe)
+			continue
+		}
+		go serveUserScriptConn(os.NewFile(uintptr(pipe), services.UserScriptPipeName))
+	}
+}
+
+func serveUserScriptConn(pipe *os.File) {
+	defer pipe.Close()
+	var request services.UserScriptRequest
+	if json.NewDecoder(pipe).Decode(&request) != nil {
+		return
+	}
+	var response services.UserScriptResponse
+	if !conf.TunnelNameIsValid(request.Tunnel) {
+		response.Error = "Tunnel name is not valid"
+	} else if err := runUserScript(&request, &response); err != nil {
+		log.Printf("[%s] Unable to run script as user: %v", request.Tunnel, err)
+		response.Error = err.Error()
+	}
+	json.NewEncoder(pipe).Encode(&response)
+}
+
+// userScriptToken returns the token of the user signed in to the console, or its elevated
+// counterpart.
+func userScriptToken(elevated bool) (windows.Token, error) {
+	session := windows.WTSGetActiveConsoleSessionId()
+	if session == noActiveConsoleUser {
+		return 0, errors.New("No user is signed in")
+	}
+	var token windows.Token
+	err := windows.WTSQueryUserToken(session, &token)
+	if err != nil {
+		return 0, errors.New("No user is signed in")
+	}
+	if !elevated || token.IsElevated() {
+		return token, nil
+	}
+	linkedToken, err := token.GetLinkedToken()
+	token.Close()
+	if err != nil || !linkedToken.IsElevated() {
+		if err == nil {
+			linkedToken.Close()
+		}
+		return 0, errors.New("The signed-in user is not an administrator")
+	}
+	return linkedToken, nil
+}
+
+func environmentBlock(env []string) ([]uint16, error) {
+	var block []uint16
+	for _, variable := range env {
+		variable16, err := windows.UTF16FromString(variable)
+		if err != nil {
+			return nil, err
+		}
+		block = append(block, variable16...)
+	}
+	return append(block, 0), nil
+}
+
+func runUserScript(request *services.UserScriptRequest, response *services.UserScriptResponse) error {
+	token, err := userScriptToken(request.Elevated)
+	if err != nil {
+		return err
+	}
+	defer token.Close()
+	env, err := token.Environ(false)
+	if err != nil {
+		return err
+	}
+	env16, err := environmentBlock(append(env, request.Environment...))
+	if err != nil {
+		return err
+	}
+	program16, err := windows.UTF16PtrFromString(request.Program)
+	if err != nil {
+		return err
+	}
+	commandLine16, err := windows.UTF16PtrFromString(request.CommandLine)
+	if err != nil {
+		return err
+	}
+	var workingDirectory16 *uint16
+	if profileDirectory, err := token.GetUserProfileDirectory(); err == nil {
+		workingDirectory16, _ = windows.UTF16PtrFromString(profileDirectory)
+	}
+
+	var reader, writer windows.Handle
+	err = windows.CreatePipe(&reader, &writer, &windows.SecurityAttributes{Length: uint32(unsafe.Sizeof(windows.SecurityAttributes{})), InheritHandle: 1}, 0)
+	if err != nil {
+		return err
+	}
+	output := os.NewFile(uintptr(reader), "script output")
+	windows.SetHandleInformation(reader, windows.HANDLE_FLAG_INHERIT, 0)
+	devNull, err := windows.CreateFile(windows.StringToUTF16Ptr("NUL"), windows.GENERIC_READ, windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE, &windows.SecurityAttributes{Length: uint32(unsafe.Sizeof(windows.SecurityAttributes{})), InheritHandle: 1}, windows.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		output.Close()
+		windows.CloseHandle(writer)
+		return err
+	}
+	defer windows.CloseHandle(devNull)
+
+	handles := []windows.Handle{devNull, writer}
+	attributeList, err := windows.NewProcThreadAttributeList(1)
+	if err != nil {
+		output.Close()
+		windows.CloseHandle(writer)
+		return err
+	}
+	defer attributeList.Delete()
+	attributeList.Update(windows.PROC_THREAD_ATTRIBUTE_HANDLE_LIST, unsafe.Pointer(&handles[0]), uintptr(len(handles))*unsafe.Sizeof(handles[0]))
+	si := &windows.StartupInfoEx{
+		StartupInfo: windows.StartupInfo{
+			Cb:         uint32(unsafe.Sizeof(windows.StartupInfoEx{})),
+			Desktop:    windows.StringToUTF16Ptr(userScriptDesktop),
+			Flags:      windows.STARTF_USESTDHANDLES | windows.STARTF_USESHOWWINDOW,
+			ShowWindow: windows.SW_HIDE,
+			StdInput:   devNull,
+			StdOutput:  writer,
+			StdErr:     writer,
+		},
+		ProcThreadAttributeList: attributeList.List(),
+	}
+	pi := new(windows.ProcessInformation)
+	err = windows.CreateProcessAsUser(token, program16, commandLine16, nil, nil, true,
+		windows.CREATE_UNICODE_ENVIRONMENT|windows.CREATE_NO_WINDOW|windows.CREATE_SUSPENDED|windows.EXTENDED_STARTUPINFO_PRESENT,
+		&env16[0], workingDirectory16, &si.StartupInfo, pi)
+	windows.CloseHandle(writer)
+	if err != nil {
+		output.Close()
+		return err
+	}
+	defer windows.CloseHandle(pi.Process)
+
+	// Unlike the job of the scripts of tunnel services, this one does not kill the processes when
+	// closed, so that programs that a script leaves running for the user keep running, and is only
+	// there for killing all of them when the script times out.
+	job, err := windows.CreateJobObject(nil, nil)
+	if err == nil {
+		err = windows.AssignProcessToJobObject(job, pi.Process)
+		if err != nil {
+			windows.CloseHandle(job)
+			job = 0
+		}
+	}
+	if err != nil {
+		log.Printf("[%s] Unable to supervise user script with job object: %v", request.Tunnel, err)
+	}
+	windows.ResumeThread(pi.Thread)
+	windows.CloseHandle(pi.Thread)
+	if job != 0 {
+		defer windows.CloseHandle(job)
+	}
+
+	// Output may be held open by what the script leaves running, so it is read until then, but
+	// only waited on for a moment after the script exits.
+	var outputLines []string
+	var outputLock sync.Mutex
+	outputDone := make(chan struct{})
+	go func() {
+		defer close(outputDone)
+		defer output.Close()
+		scanner := bufio.NewScanner(output)
+		for scanner.Scan() {
+			outputLock.Lock()
+			if len(outputLines) < maxUserScriptOutput {
+				outputLines = append(outputLines, scanner.Text())
+			}
+			outputLock.Unlock()
+		}
+	}()
+
+	event, err := windows.WaitForSingleObject(pi.Process, uint32(request.Timeout.Milliseconds()))
+	if err != nil {
+		return err
+	}
+	if event == uint32(windows.WAIT_TIMEOUT) {
+		response.TimedOut = true
+		if job != 0 {
+			windows.TerminateJobObject(job, 1)
+		} else {
+			windows.TerminateProcess(pi.Process, 1)
+		}
+		windows.WaitForSingleObject(pi.Process, userScriptWaitMargin)
+	} else {
+		err = windows.GetExitCodeProcess(pi.Process, &response.ExitCode)
+		if err != nil {
+			return err
+		}
+	}
+	select {
+	case <-outputDone:
+	case <-time.After(time.Second):
+	}
+	outputLock.Lock()
+	response.Output = append([]string(nil), outputLines...)
+	outputLock.Unlock()
+	return nil
+}
@@ -0,0 +1,225 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"fmt"
+	"log"
+	"net/netip"
+	"sync"
+	"time"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/driver"
+	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
+)
+
+// The watchdog escalates: once a tunnel has failed as many checks in a row as its policy allows,
+// the endpoints are resolved again, which helps when a peer moved to another address. If that
+// does not help, the adapter is bounced, which drops all sessions and starts new handshakes,
+// and after that, the tunnel service is restarted, after which the count starts over.
+
+const (
+	watchdogInterval     = 30 * time.Second
+	watchdogPingAttempts = 3
+	watchdogPingTimeout  = 2 * time.Second
+	watchdogPingPayload  = 32
+)
+
+type WatchdogAction int
+
+const (
+	WatchdogNoAction WatchdogAction = iota
+	WatchdogResolveEndpoints
+	WatchdogBounceAdapter
+	WatchdogRestartTunnel
+)
+
+func (action WatchdogAction) String() string {
+	switch action {
+	case WatchdogNoAction:
+		return "none"
+	case WatchdogResolveEndpoints:
+		return "resolve endpoints"
+	case WatchdogBounceAdapter:
+		return "bounce adapter"
+	case WatchdogRestartTunnel:
+		return "restart tunnel"
+	default:
+		return "unknown"
+	}
+}
+
+// WatchdogStatus is what the watchdog of a running tunnel last found and did. Enabled is false
+// if the tunnel has no watchdog policy.
+type WatchdogStatus struct {
+	Enabled             bool
+	ConsecutiveFailures int
+	LastCheck           time.Time
+	LastFailure         string
+	LastAction          WatchdogAction
+	LastActionTime      time.Time
+}
+
+var (
+	watchdogStatuses     = make(map[string]*WatchdogStatus)
+	watchdogStatusesLock sync.Mutex
+)
+
+// watchdogAction returns what to do after the given number of consecutive failed checks.
+func watchdogAction(failures, threshold int) WatchdogAction {
+	if threshold <= 0 || failures == 0 || failures%threshold != 0 {
+		return WatchdogNoAction
+	}
+	switch failures / threshold {
+	case 1:
+		return WatchdogResolveEndpoints
+	case 2:
+		return WatchdogBounceAdapter
+	default:
+		return WatchdogRestartTunnel
+	}
+}
+
+func ping(addr netip.Addr) error {
+	icmp, err := winipcfg.OpenICMP(netip.Addr{}, addr)
+	if err != nil {
+		return err
+	}
+	defer icmp.Close()
+	for i := 0; i < watchdogPingAttempts; i++ {
+		err = icmp.Echo(watchdogPingPayload, false, watchdogPingTimeout)
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func checkTunnelHealth(tunnelName string, policy *conf.WatchdogPolicy) error {
+	if policy.MaxHandshakeAge > 0 {
+		config, err := runtimeConfig(tunnelName)
+		if err != nil {
+			return err
+		}
+		if !handshakeSince(config, time.Now().Add(-policy.MaxHandshakeAge)) {
+			return fmt.Errorf("No handshake within %v", policy.MaxHandshakeAge)
+		}
+	}
+	if policy.PingAddress.IsValid() {
+		err := ping(policy.PingAddress)
+		if err != nil {
+			return fmt.Errorf("%s did not answer: %w", policy.PingAddress, err)
+		}
+	}
+	return nil
+}
+
+func bounceAdapter(tunnelName string) error {
+	driverAdapter, err := findDriverAdapter(tunnelName)
+	if err != nil {
+		return err
+	}
+	err = driverAdapter.SetAdapterState(driver.AdapterStateDown)
+	if err == nil {
+		err = driverAdapter.SetAdapterState(driver.AdapterStateUp)
+	}
+	driverAdapter.Unlock()
+	if err != nil {
+		releaseDriverAdapter(tunnelName)
+	}
+	return err
+}
+
+func recoverTunnel(tunnelName string, action WatchdogAction) error {
+	switch action {
+	case WatchdogResolveEndpoints:
+		config, err := loadWithActiveProfile(tunnelName)
+		if err != nil {
+			return err
+		}
+		return syncPeers(config, config)
+	case WatchdogBounceAdapter:
+		return bounceAdapter(tunnelName)
+	case WatchdogRestartTunnel:
+		return restartTunnel(tunnelName)
+	}
+	return nil
+}
+
+// watchTunnel runs one check of a running tunnel with a watchdog policy, and recovers it if needed.
+func watchTunnel(tunnelName string, policy *conf.WatchdogPolicy) {
+	err := checkTunnelHealth(tunnelName, policy)
+	watchdogStatusesLock.Lock()
+	status := watchdogStatuses[tunnelName]
+	if status == nil {
+		status = &WatchdogStatus{Enabled: true}
+		watchdogStatuses[tunnelName] = status
+	}
+	status.LastCheck = time.Now()
+	if err == nil {
+		status.ConsecutiveFailures = 0
+		watchdogStatusesLock.Unlock()
+		return
+	}
+	status.ConsecutiveFailures++
+	status.LastFailure = err.Error()
+	action := watchdogAction(status.ConsecutiveFailures, policy.FailureThreshold)
+	if action != WatchdogNoAction {
+		status.LastAction = action
+		status.LastActionTime = time.Now()
+	}
+	if action == WatchdogRestartTunnel {
+		status.ConsecutiveFailures = 0
+	}
+	failures := status.ConsecutiveFailures
+	watchdogStatusesLock.Unlock()
+
+	log.Printf("[%s] Watchdog check failed (%d in a row): %v", tunnelName, failures, err)
+	if action == WatchdogNoAction {
+		return
+	}
+	log.Printf("[%s] Watchdog recovering tunnel: %v", tunnelName, action)
+	err = recoverTunnel(tunnelName, action)
+	if err != nil {
+		log.Printf("[%s] Watchdog unable to %v: %v", tunnelName, action, err)
+	}
+}
+
+func runWatchdogs() {
+	s := &ManagerService{}
+	for {
+		time.Sleep(watchdogInterval)
+		names, err := conf.ListConfigNames()
+		if err != nil {
+			continue
+		}
+		for _, name := range names {
+			policy := conf.LoadWatchdogPolicy(name)
+			state, err := s.State(name)
+			if policy == nil || err != nil || state != TunnelStarted {
+				watchdogStatusesLock.Lock()
+				delete(watchdogStatuses, name)
+				watchdogStatusesLock.Unlock()
+				continue
+			}
+			watchTunnel(name, policy)
+		}
+	}
+}
+
+// WatchdogStatus returns what the watchdog of a tunnel last found and did.
+func (s *ManagerService) WatchdogStatus(tunnelName string) (WatchdogStatus, error) {
+	if _, err := conf.LoadFromName(tunnelName); err != nil {
+		return WatchdogStatus{}, err
+	}
+	watchdogStatusesLock.Lock()
+	defer watchdogStatusesLock.Unlock()
+	if status := watchdogStatuses[tunnelName]; status != nil {
+		return *status, nil
+	}
+	return WatchdogStatus{Enabled: conf.LoadWatchdogPolicy(tunnelName) != nil}, nil
+}
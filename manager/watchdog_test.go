@@ -0,0 +1,24 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import "testing"
+
+func TestWatchdogAction(t *testing.T) {
+	expected := []WatchdogAction{
+		WatchdogNoAction, WatchdogNoAction, WatchdogNoAction, WatchdogResolveEndpoints,
+		WatchdogNoAction, WatchdogNoAction, WatchdogBounceAdapter,
+		WatchdogNoAction, WatchdogNoAction, WatchdogRestartTunnel,
+	}
+	for failures, action := range expected {
+		if got := watchdogAction(failures, 3); got != action {
+			t.Errorf("After %d failures, action is %v, expected %v", failures, got, action)
+		}
+	}
+	if got := watchdogAction(1, 0); got != WatchdogNoAction {
+		t.Errorf("Without a threshold, action is %v", got)
+	}
+}
@@ -0,0 +1,77 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package wmi
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// Error is an HRESULT returned by WMI, most of which are described by the WBEM_E_ constants of
+// wbemcli.h rather than by system messages.
+type Error uint32
+
+const (
+	_WBEM_E_NOT_FOUND         Error = 0x80041002
+	_WBEM_E_INVALID_NAMESPACE Error = 0x8004100e
+	_WBEM_E_ALREADY_EXISTS    Error = 0x80041019
+
+	_WBEM_FLAG_CREATE_OR_UPDATE = 0
+	_WBEM_FLAG_CREATE_ONLY      = 2
+
+	_CLSCTX_INPROC_SERVER = 1
+
+	_RPC_C_AUTHN_WINNT           = 10
+	_RPC_C_AUTHZ_NONE            = 0
+	_RPC_C_AUTHN_LEVEL_CALL      = 3
+	_RPC_C_IMP_LEVEL_IMPERSONATE = 3
+	_EOAC_NONE                   = 0
+
+	_VT_NULL = 1
+	_VT_I4   = 3
+	_VT_BSTR = 8
+	_VT_BOOL = 11
+
+	_VARIANT_TRUE = 0xffff
+)
+
+// Methods are numbered by their place in the vtables of wbemcli.h, after the three of IUnknown.
+const (
+	_IUnknown_Release = 2
+
+	_IWbemLocator_ConnectServer = 3
+
+	_IWbemServices_GetObject      = 6
+	_IWbemServices_PutClass       = 8
+	_IWbemServices_DeleteClass    = 10
+	_IWbemServices_PutInstance    = 14
+	_IWbemServices_DeleteInstance = 16
+
+	_IWbemClassObject_Put                     = 5
+	_IWbemClassObject_GetPropertyQualifierSet = 11
+	_IWbemClassObject_SpawnInstance           = 15
+
+	_IWbemQualifierSet_Put = 4
+)
+
+var (
+	_CLSID_WbemLocator = windows.GUID{Data1: 0x4590f811, Data2: 0x1d3a, Data3: 0x11d0, Data4: [8]byte{0x89, 0x1f, 0x00, 0xaa, 0x00, 0x4b, 0x2e, 0x24}}
+	_IID_IWbemLocator  = windows.GUID{Data1: 0xdc12a687, Data2: 0x737f, Data3: 0x11cf, Data4: [8]byte{0x88, 0x4d, 0x00, 0xaa, 0x00, 0x4b, 0x2e, 0x24}}
+)
+
+// variant is a VARIANT, whose union is as large as two pointers, for BRECORD.
+type variant struct {
+	vt        uint16
+	reserved1 uint16
+	reserved2 uint16
+	reserved3 uint16
+	val       [2]uintptr
+}
+
+//sys	coCreateInstance(clsid *windows.GUID, outer unsafe.Pointer, context uint32, iid *windows.GUID, object *unsafe.Pointer) (ret error) = ole32.CoCreateInstance
+//sys	coSetProxyBlanket(proxy unsafe.Pointer, authnSvc uint32, authzSvc uint32, serverPrincName *uint16, authnLevel uint32, impLevel uint32, authInfo uintptr, capabilities uint32) (ret error) = ole32.CoSetProxyBlanket
+//sys	sysAllocString(s *uint16) (bstr uintptr) = oleaut32.SysAllocString
+//sys	sysFreeString(bstr uintptr) = oleaut32.SysFreeString
+//sys	variantClear(v *variant) (ret error) = oleaut32.VariantClear
@@ -0,0 +1,368 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+// Package wmi publishes static classes and instances to the WMI repository, for the benefit of
+// inventory tools that query WMI. It is not a provider: the repository keeps the instances, and
+// serves them to consumers without calling back into the process that put them there.
+//
+// COM must be initialized for the multithreaded apartment on any thread that calls into a
+// Namespace.
+package wmi
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+type CIMType uint32
+
+const (
+	String   CIMType = 8
+	Boolean  CIMType = 11
+	Uint32   CIMType = 19
+	Uint64   CIMType = 21
+	DateTime CIMType = 101
+)
+
+type Property struct {
+	Name string
+	Type CIMType
+	Key  bool
+}
+
+type Class struct {
+	Name       string
+	Properties []Property
+}
+
+type Namespace struct {
+	services unsafe.Pointer // IWbemServices
+	classes  map[string]unsafe.Pointer
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("WMI error 0x%08x", uint32(e))
+}
+
+func hresult(r uintptr) error {
+	if int32(r) < 0 {
+		return Error(r)
+	}
+	return nil
+}
+
+func method(object unsafe.Pointer, index int) uintptr {
+	vtbl := *(*unsafe.Pointer)(object)
+	return *(*uintptr)(unsafe.Add(vtbl, uintptr(index)*unsafe.Sizeof(uintptr(0))))
+}
+
+func release(object unsafe.Pointer) {
+	if object != nil {
+		syscall.SyscallN(method(object, _IUnknown_Release), uintptr(object))
+	}
+}
+
+// allocString returns s as a BSTR, which is allocated outside of Go, and must be freed with
+// sysFreeString.
+func allocString(s string) (uintptr, error) {
+	s16, err := windows.UTF16PtrFromString(s)
+	if err != nil {
+		return 0, err
+	}
+	bstr := sysAllocString(s16)
+	if bstr == 0 {
+		return 0, windows.ERROR_NOT_ENOUGH_MEMORY
+	}
+	return bstr, nil
+}
+
+// FormatDateTime formats t as a CIM DATETIME, in UTC.
+func FormatDateTime(t time.Time) string {
+	return t.UTC().Format("20060102150405.000000") + "+000"
+}
+
+// InstancePath returns the path of the instance of a class with the given key values, which are
+// given as pairs of property names and values.
+func InstancePath(className string, keys ...string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	var path strings.Builder
+	path.WriteString(className)
+	for i := 0; i+1 < len(keys); i += 2 {
+		if i == 0 {
+			path.WriteByte('.')
+		} else {
+			path.WriteByte(',')
+		}
+		fmt.Fprintf(&path, `%s="%s"`, keys[i], escaper.Replace(keys[i+1]))
+	}
+	return path.String()
+}
+
+// newVariant makes a VARIANT of a value, as WMI expects it for the given type, which is a string
+// for 64-bit integers and dates. It must be freed with variantClear.
+func newVariant(value any, cimType CIMType) (v variant, err error) {
+	var s string
+	switch value := value.(type) {
+	case nil:
+		v.vt = _VT_NULL
+		return
+	case string:
+		s = value
+	case bool:
+		v.vt = _VT_BOOL
+		if value {
+			v.val[0] = _VARIANT_TRUE
+		}
+		return
+	case uint32:
+		v.vt = _VT_I4
+		v.val[0] = uintptr(value)
+		return
+	case uint64:
+		s = strconv.FormatUint(value, 10)
+	case time.Time:
+		if value.IsZero() {
+			v.vt = _VT_NULL
+			return
+		}
+		s = FormatDateTime(value)
+	default:
+		return v, fmt.Errorf("Unsupported type %T for CIM type %d", value, cimType)
+	}
+	bstr, err := allocString(s)
+	if err != nil {
+		return
+	}
+	v.vt = _VT_BSTR
+	v.val[0] = bstr
+	return
+}
+
+func connect(locator unsafe.Pointer, path string) (unsafe.Pointer, error) {
+	bstr, err := allocString(path)
+	if err != nil {
+		return nil, err
+	}
+	defer sysFreeString(bstr)
+	var services unsafe.Pointer
+	r, _, _ := syscall.SyscallN(method(locator, _IWbemLocator_ConnectServer), uintptr(locator), bstr, 0, 0, 0, 0, 0, 0, uintptr(unsafe.Pointer(&services)))
+	if err = hresult(r); err != nil {
+		return nil, err
+	}
+	err = coSetProxyBlanket(services, _RPC_C_AUTHN_WINNT, _RPC_C_AUTHZ_NONE, nil, _RPC_C_AUTHN_LEVEL_CALL, _RPC_C_IMP_LEVEL_IMPERSONATE, 0, _EOAC_NONE)
+	if err != nil {
+		release(services)
+		return nil, err
+	}
+	return services, nil
+}
+
+func getObject(services unsafe.Pointer, path string) (unsafe.Pointer, error) {
+	var bstr uintptr
+	if len(path) > 0 {
+		var err error
+		if bstr, err = allocString(path); err != nil {
+			return nil, err
+		}
+		defer sysFreeString(bstr)
+	}
+	var object unsafe.Pointer
+	r, _, _ := syscall.SyscallN(method(services, _IWbemServices_GetObject), uintptr(services), bstr, 0, 0, uintptr(unsafe.Pointer(&object)), 0)
+	if err := hresult(r); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+func put(object unsafe.Pointer, name string, value any, cimType CIMType) error {
+	name16, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	v, err := newVariant(value, cimType)
+	if err != nil {
+		return err
+	}
+	defer variantClear(&v)
+	r, _, _ := syscall.SyscallN(method(object, _IWbemClassObject_Put), uintptr(object), uintptr(unsafe.Pointer(name16)), 0, uintptr(unsafe.Pointer(&v)), uintptr(cimType))
+	return hresult(r)
+}
+
+func spawnInstance(class unsafe.Pointer) (unsafe.Pointer, error) {
+	var instance unsafe.Pointer
+	r, _, _ := syscall.SyscallN(method(class, _IWbemClassObject_SpawnInstance), uintptr(class), 0, uintptr(unsafe.Pointer(&instance)))
+	if err := hresult(r); err != nil {
+		return nil, err
+	}
+	return instance, nil
+}
+
+func putInstance(services, instance unsafe.Pointer, flags uint32) error {
+	r, _, _ := syscall.SyscallN(method(services, _IWbemServices_PutInstance), uintptr(services), uintptr(instance), uintptr(flags), 0, 0)
+	return hresult(r)
+}
+
+// OpenNamespace connects to the namespace with the given name under parent, such as root,
+// creating it if it does not exist yet.
+func OpenNamespace(parent, name string) (*Namespace, error) {
+	var locator unsafe.Pointer
+	err := coCreateInstance(&_CLSID_WbemLocator, nil, _CLSCTX_INPROC_SERVER, &_IID_IWbemLocator, &locator)
+	if err != nil {
+		return nil, err
+	}
+	defer release(locator)
+
+	services, err := connect(locator, parent+`\`+name)
+	if err == _WBEM_E_INVALID_NAMESPACE {
+		parentServices, err := connect(locator, parent)
+		if err != nil {
+			return nil, err
+		}
+		defer release(parentServices)
+		namespaceClass, err := getObject(parentServices, "__Namespace")
+		if err != nil {
+			return nil, err
+		}
+		defer release(namespaceClass)
+		namespace, err := spawnInstance(namespaceClass)
+		if err != nil {
+			return nil, err
+		}
+		defer release(namespace)
+		err = put(namespace, "Name", name, String)
+		if err != nil {
+			return nil, err
+		}
+		err = putInstance(parentServices, namespace, _WBEM_FLAG_CREATE_ONLY)
+		if err != nil && err != _WBEM_E_ALREADY_EXISTS {
+			return nil, err
+		}
+		services, err = connect(locator, parent+`\`+name)
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return &Namespace{services: services, classes: make(map[string]unsafe.Pointer)}, nil
+}
+
+func (ns *Namespace) Close() {
+	for _, class := range ns.classes {
+		release(class)
+	}
+	ns.classes = nil
+	release(ns.services)
+	ns.services = nil
+}
+
+// DefineClass replaces the class of the given name, if any, along with all of its instances.
+func (ns *Namespace) DefineClass(class *Class) error {
+	name, err := allocString(class.Name)
+	if err != nil {
+		return err
+	}
+	defer sysFreeString(name)
+	r, _, _ := syscall.SyscallN(method(ns.services, _IWbemServices_DeleteClass), uintptr(ns.services), name, 0, 0, 0)
+	if err = hresult(r); err != nil && err != _WBEM_E_NOT_FOUND {
+		return err
+	}
+	if old := ns.classes[class.Name]; old != nil {
+		release(old)
+		delete(ns.classes, class.Name)
+	}
+
+	object, err := getObject(ns.services, "")
+	if err != nil {
+		return err
+	}
+	defer release(object)
+	err = put(object, "__CLASS", class.Name, String)
+	if err != nil {
+		return err
+	}
+	for _, property := range class.Properties {
+		err = put(object, property.Name, nil, property.Type)
+		if err != nil {
+			return fmt.Errorf("%s: %w", property.Name, err)
+		}
+		if !property.Key {
+			continue
+		}
+		name16, err := windows.UTF16PtrFromString(property.Name)
+		if err != nil {
+			return err
+		}
+		var qualifiers unsafe.Pointer
+		r, _, _ = syscall.SyscallN(method(object, _IWbemClassObject_GetPropertyQualifierSet), uintptr(object), uintptr(unsafe.Pointer(name16)), uintptr(unsafe.Pointer(&qualifiers)))
+		if err = hresult(r); err != nil {
+			return fmt.Errorf("%s: %w", property.Name, err)
+		}
+		key, _ := windows.UTF16PtrFromString("key")
+		v, _ := newVariant(true, Boolean)
+		r, _, _ = syscall.SyscallN(method(qualifiers, _IWbemQualifierSet_Put), uintptr(qualifiers), uintptr(unsafe.Pointer(key)), uintptr(unsafe.Pointer(&v)), 0)
+		release(qualifiers)
+		if err = hresult(r); err != nil {
+			return fmt.Errorf("%s: %w", property.Name, err)
+		}
+	}
+	r, _, _ = syscall.SyscallN(method(ns.services, _IWbemServices_PutClass), uintptr(ns.services), uintptr(object), _WBEM_FLAG_CREATE_OR_UPDATE, 0, 0)
+	return hresult(r)
+}
+
+// PutInstance creates or replaces the instance of a class with the given property values, of
+// which those of the keys determine the instance. Values are strings, bools, uint32s, uint64s,
+// or times, with nil or the zero time for no value.
+func (ns *Namespace) PutInstance(className string, values map[string]any) error {
+	class := ns.classes[className]
+	if class == nil {
+		var err error
+		class, err = getObject(ns.services, className)
+		if err != nil {
+			return err
+		}
+		ns.classes[className] = class
+	}
+	instance, err := spawnInstance(class)
+	if err != nil {
+		return err
+	}
+	defer release(instance)
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// A type of 0 lets WMI take the type from the class.
+		err = put(instance, name, values[name], 0)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return putInstance(ns.services, instance, _WBEM_FLAG_CREATE_OR_UPDATE)
+}
+
+// DeleteInstance deletes the instance with the given path, as returned by InstancePath. It is not
+// an error if there is no such instance.
+func (ns *Namespace) DeleteInstance(path string) error {
+	bstr, err := allocString(path)
+	if err != nil {
+		return err
+	}
+	defer sysFreeString(bstr)
+	r, _, _ := syscall.SyscallN(method(ns.services, _IWbemServices_DeleteInstance), uintptr(ns.services), bstr, 0, 0, 0)
+	if err = hresult(r); err != nil && err != _WBEM_E_NOT_FOUND {
+		return err
+	}
+	return nil
+}
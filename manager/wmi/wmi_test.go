@@ -0,0 +1,34 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package wmi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInstancePath(t *testing.T) {
+	for _, test := range []struct {
+		class string
+		keys  []string
+		want  string
+	}{
+		{"WireGuard_Tunnel", []string{"Name", "office"}, `WireGuard_Tunnel.Name="office"`},
+		{"WireGuard_Peer", []string{"Tunnel", "office", "PublicKey", "a/b+c="}, `WireGuard_Peer.Tunnel="office",PublicKey="a/b+c="`},
+		{"WireGuard_Tunnel", []string{"Name", `a"b\c`}, `WireGuard_Tunnel.Name="a\"b\\c"`},
+	} {
+		if got := InstancePath(test.class, test.keys...); got != test.want {
+			t.Errorf("InstancePath(%q, %q) = %s, want %s", test.class, test.keys, got, test.want)
+		}
+	}
+}
+
+func TestFormatDateTime(t *testing.T) {
+	stamp := time.Date(2022, 3, 4, 5, 6, 7, 8000, time.FixedZone("", 3600))
+	if got, want := FormatDateTime(stamp), "20220304040607.000008+000"; got != want {
+		t.Errorf("FormatDateTime is %s, want %s", got, want)
+	}
+}
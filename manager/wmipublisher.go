@@ -0,0 +1,186 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package manager
+
+import (
+	"log"
+	"runtime"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/manager/wmi"
+)
+
+// The WMI publisher is off unless the PublishWMI admin registry key is set. It keeps static
+// instances of the tunnels and their peers in the root\WireGuard namespace up to date, replacing
+// its classes when it starts, so that instances from an earlier run, or of an older schema, do not
+// linger. Since the repository serves the instances without asking the manager, they may be as
+// old as the publishing interval, which each instance records as Updated.
+const wmiPublishInterval = 15 * time.Second
+
+const (
+	wmiTunnelClass = "WireGuard_Tunnel"
+	wmiPeerClass   = "WireGuard_Peer"
+)
+
+var wmiClasses = []wmi.Class{
+	{Name: wmiTunnelClass, Properties: []wmi.Property{
+		{Name: "Name", Type: wmi.String, Key: true},
+		{Name: "State", Type: wmi.String},
+		{Name: "Peers", Type: wmi.Uint32},
+		{Name: "LastHandshake", Type: wmi.DateTime},
+		{Name: "RxBytes", Type: wmi.Uint64},
+		{Name: "TxBytes", Type: wmi.Uint64},
+		{Name: "Updated", Type: wmi.DateTime},
+	}},
+	{Name: wmiPeerClass, Properties: []wmi.Property{
+		{Name: "Tunnel", Type: wmi.String, Key: true},
+		{Name: "PublicKey", Type: wmi.String, Key: true},
+		{Name: "Name", Type: wmi.String},
+		{Name: "Endpoint", Type: wmi.String},
+		{Name: "LastHandshake", Type: wmi.DateTime},
+		{Name: "RxBytes", Type: wmi.Uint64},
+		{Name: "TxBytes", Type: wmi.Uint64},
+		{Name: "Updated", Type: wmi.DateTime},
+	}},
+}
+
+func handshakeTime(t conf.HandshakeTime) time.Time {
+	if t.IsEmpty() {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(t))
+}
+
+// publishWMIInstances puts the instances of all tunnels and the peers of running ones, and adds
+// their paths to published.
+func publishWMIInstances(ns *wmi.Namespace, published map[string]bool) error {
+	names, err := conf.ListConfigNames()
+	if err != nil {
+		return err
+	}
+	s := &ManagerService{}
+	now := time.Now()
+	for _, name := range names {
+		state, err := s.State(name)
+		if err != nil {
+			continue
+		}
+		tunnel := map[string]any{
+			"Name":    name,
+			"State":   automationStateName(state),
+			"Updated": now,
+		}
+		if state == TunnelStarted {
+			if config, err := runtimeConfig(name); err == nil {
+				var rx, tx uint64
+				var lastHandshake conf.HandshakeTime
+				for i := range config.Peers {
+					peer := &config.Peers[i]
+					rx += uint64(peer.RxBytes)
+					tx += uint64(peer.TxBytes)
+					if peer.LastHandshakeTime > lastHandshake {
+						lastHandshake = peer.LastHandshakeTime
+					}
+					publicKey := peer.PublicKey.String()
+					var endpoint string
+					if !peer.Endpoint.IsEmpty() {
+						endpoint = peer.Endpoint.String()
+					}
+					err = ns.PutInstance(wmiPeerClass, map[string]any{
+						"Tunnel":        name,
+						"PublicKey":     publicKey,
+						"Name":          peer.Name,
+						"Endpoint":      endpoint,
+						"LastHandshake": handshakeTime(peer.LastHandshakeTime),
+						"RxBytes":       uint64(peer.RxBytes),
+						"TxBytes":       uint64(peer.TxBytes),
+						"Updated":       now,
+					})
+					if err != nil {
+						return err
+					}
+					published[wmi.InstancePath(wmiPeerClass, "Tunnel", name, "PublicKey", publicKey)] = true
+				}
+				tunnel["Peers"] = uint32(len(config.Peers))
+				tunnel["LastHandshake"] = handshakeTime(lastHandshake)
+				tunnel["RxBytes"] = rx
+				tunnel["TxBytes"] = tx
+			}
+		}
+		err = ns.PutInstance(wmiTunnelClass, tunnel)
+		if err != nil {
+			return err
+		}
+		published[wmi.InstancePath(wmiTunnelClass, "Name", name)] = true
+	}
+	return nil
+}
+
+func publishWMI() {
+	if !conf.AdminBool("PublishWMI") {
+		return
+	}
+	// COM is initialized for each thread, so this goroutine keeps to one.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	err := windows.CoInitializeEx(0, windows.COINIT_MULTITHREADED)
+	if err != nil {
+		log.Printf("Unable to initialize COM for WMI: %v", err)
+		return
+	}
+	defer windows.CoUninitialize()
+
+	ns, err := wmi.OpenNamespace("root", "WireGuard")
+	if err != nil {
+		log.Printf("Unable to open WMI namespace: %v", err)
+		return
+	}
+	defer ns.Close()
+	for i := range wmiClasses {
+		err = ns.DefineClass(&wmiClasses[i])
+		if err != nil {
+			log.Printf("Unable to define WMI class %s: %v", wmiClasses[i].Name, err)
+			return
+		}
+	}
+	log.Printf("Publishing tunnels to WMI namespace root\\WireGuard")
+
+	published := make(map[string]bool)
+	var lastErr string
+	ticker := time.NewTicker(wmiPublishInterval)
+	defer ticker.Stop()
+	for {
+		current := make(map[string]bool, len(published))
+		err = publishWMIInstances(ns, current)
+		if err != nil {
+			// Only changes are logged, since a failure tends to repeat every interval.
+			if err.Error() != lastErr {
+				log.Printf("Unable to publish tunnels to WMI: %v", err)
+			}
+			lastErr = err.Error()
+			// Instances that could not be put this time may still be there from the last.
+			for path := range published {
+				current[path] = true
+			}
+		} else {
+			lastErr = ""
+		}
+		for path := range published {
+			if current[path] {
+				continue
+			}
+			err = ns.DeleteInstance(path)
+			if err != nil {
+				log.Printf("Unable to remove %s from WMI: %v", path, err)
+			}
+		}
+		published = current
+		<-ticker.C
+	}
+}
@@ -65,6 +65,33 @@ func TestDump(t *testing.T) {
 	rl.Close()
 }
 
+func TestLevels(t *testing.T) {
+	rl, err := NewRinglogger("ringlogger_test.bin", "LVL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rl.Close()
+	_, cursor := rl.FollowFromCursor(CursorAll)
+	fmt.Fprintf(rl, "plain")
+	rl.WriteLevel(LevelWarning, "component", []byte("warned"))
+	rl.WriteLevel(LevelError, "", []byte("failed"))
+	lines, _ := rl.FollowFromCursor(cursor)
+	if len(lines) != 3 {
+		t.Fatalf("Got %d lines, want 3", len(lines))
+	}
+	for i, want := range []string{"[LVL] plain", "[LVL] [WARN] [component] warned", "[LVL] [ERROR] failed"} {
+		if got := lines[i].String(); got != want {
+			t.Errorf("Line %d is %q, want %q", i, got, want)
+		}
+	}
+	if lines[1].Sequence != lines[0].Sequence+1 || lines[2].Sequence != lines[1].Sequence+1 {
+		t.Errorf("Sequence numbers are not consecutive: %d, %d, %d", lines[0].Sequence, lines[1].Sequence, lines[2].Sequence)
+	}
+	if level, err := ParseLevel("Warning"); err != nil || level != LevelWarning {
+		t.Errorf("ParseLevel(\"Warning\") = %v, %v", level, err)
+	}
+}
+
 func TestFollow(t *testing.T) {
 	rl, err := NewRinglogger("ringlogger_test.bin", "FOL")
 	if err != nil {
@@ -80,3 +107,35 @@ func TestFollow(t *testing.T) {
 		time.Sleep(300 * time.Millisecond)
 	}
 }
+
+func TestDumpFilter(t *testing.T) {
+	now := time.Now()
+	lines := []FollowLine{
+		{Line: "[TUN] [office] Handshake did not complete", Stamp: now, Level: LevelWarning},
+		{Line: "[MGR] [Office] Activating tunnel on schedule", Stamp: now.Add(-time.Hour), Level: LevelInfo},
+		{Line: "[GUI] Starting UI process", Stamp: now, Level: LevelInfo},
+	}
+	if tag, subject := lines[0].Tags(); tag != "TUN" || subject != "office" {
+		t.Errorf("Tags are %q and %q, want TUN and office", tag, subject)
+	}
+	if tag, subject := lines[2].Tags(); tag != "GUI" || subject != "" {
+		t.Errorf("Tags are %q and %q, want GUI and none", tag, subject)
+	}
+	for _, test := range []struct {
+		filter DumpFilter
+		want   []bool
+	}{
+		{DumpFilter{}, []bool{true, true, true}},
+		{DumpFilter{MinLevel: LevelWarning}, []bool{true, false, false}},
+		{DumpFilter{Since: now.Add(-time.Minute)}, []bool{true, false, true}},
+		{DumpFilter{Tunnel: "office"}, []bool{true, true, false}},
+		{DumpFilter{Contains: "STARTING"}, []bool{false, false, true}},
+		{DumpFilter{Contains: "warn"}, []bool{true, false, false}},
+	} {
+		for i := range lines {
+			if got := test.filter.Matches(&lines[i]); got != test.want[i] {
+				t.Errorf("%+v matches line %d: %v, want %v", test.filter, i, got, test.want[i])
+			}
+		}
+	}
+}
@@ -15,7 +15,9 @@ import (
 	"golang.org/x/sys/windows"
 )
 
-func DumpTo(inPath string, out io.Writer, continuous bool) error {
+// DumpTo writes the lines of the log at inPath that the filter matches, and keeps following it
+// if continuous.
+func DumpTo(inPath string, out io.Writer, continuous bool, filter *DumpFilter) error {
 	file, err := os.Open(inPath)
 	if err != nil {
 		return err
@@ -32,7 +34,7 @@ func DumpTo(inPath string, out io.Writer, continuous bool) error {
 	}
 	defer rl.Close()
 	if !continuous {
-		_, err = rl.WriteTo(out)
+		_, err = rl.WriteFilteredTo(out, filter)
 		if err != nil {
 			return err
 		}
@@ -42,7 +44,10 @@ func DumpTo(inPath string, out io.Writer, continuous bool) error {
 			var items []FollowLine
 			items, cursor = rl.FollowFromCursor(cursor)
 			for _, item := range items {
-				_, err = fmt.Fprintf(out, "%s: %s\n", item.Stamp.Format("2006-01-02 15:04:05.000000"), item.Line)
+				if !filter.Matches(&item) {
+					continue
+				}
+				_, err = fmt.Fprintf(out, "%s: %s\n", item.Stamp.Format("2006-01-02 15:04:05.000000"), item.String())
 				if errors.Is(err, io.EOF) {
 					return nil
 				} else if err != nil {
@@ -6,6 +6,7 @@
 package ringlogger
 
 import (
+	"fmt"
 	"log"
 	"unsafe"
 )
@@ -27,6 +28,16 @@ func InitGlobalLogger(file, tag string) error {
 	return nil
 }
 
+// Logf logs a line with a severity and component. Plain log.Printf calls are informational and
+// belong to no component in particular.
+func Logf(level Level, component, format string, args ...any) {
+	if Global == nil {
+		log.Printf(format, args...)
+		return
+	}
+	Global.WriteLevel(level, component, []byte(fmt.Sprintf(format, args...)))
+}
+
 //go:linkname overrideWrite runtime.overrideWrite
 var overrideWrite func(fd uintptr, p unsafe.Pointer, n int32) int32
 
@@ -55,7 +66,8 @@ func globalWrite(fd uintptr, p unsafe.Pointer, n int32) int32 {
 			}
 		}
 		if foundNl || len(b) > 0 {
-			Global.Write(globalBuffer[:globalBufferLocation])
+			// The runtime only writes on its own when something has gone badly wrong.
+			Global.WriteLevel(LevelError, "runtime", globalBuffer[:globalBufferLocation])
 			globalBufferLocation = 0
 		}
 	}
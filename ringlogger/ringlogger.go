@@ -12,6 +12,7 @@ import (
 	"os"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 	"unsafe"
@@ -20,15 +21,63 @@ import (
 )
 
 const (
-	maxLogLineLength = 512
-	maxTagLength     = 5
-	maxLines         = 2048
-	magic            = 0xbadbabe
+	maxLogLineLength   = 496
+	maxTagLength       = 5
+	maxComponentLength = 11
+	maxLines           = 2048
+	magic              = 0xbadbabf
+	magicV1            = 0xbadbabe
 )
 
+// Level is the severity of a log entry. Entries written before there were levels are informational.
+type Level uint8
+
+const (
+	LevelDebug Level = iota + 1
+	LevelInfo
+	LevelWarning
+	LevelError
+)
+
+func (level Level) String() string {
+	switch level {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelWarning:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return "INFO"
+}
+
+// ParseLevel accepts the names returned by String, in any case, as well as "warning".
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarning, nil
+	case "error":
+		return LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// A logLine has the same size as the logLineV1 it replaced, so that both fit the same file.
 type logLine struct {
+	timeNs    int64
+	sequence  uint32
+	level     Level
+	component [maxComponentLength]byte
+	line      [maxLogLineLength]byte
+}
+
+type logLineV1 struct {
 	timeNs int64
-	line   [maxLogLineLength]byte
+	line   [512]byte
 }
 
 type logMem struct {
@@ -84,7 +133,8 @@ func newRingloggerFromMappingHandle(mappingHandle windows.Handle, tag string, ac
 		return nil, err
 	}
 	log := (*logMem)(unsafe.Pointer(view))
-	if log.magic != magic {
+	readOnly := access&windows.FILE_MAP_WRITE == 0
+	if log.magic != magic && !(readOnly && log.magic == magicV1) {
 		bytes := (*[unsafe.Sizeof(logMem{})]byte)(unsafe.Pointer(log))
 		for i := range bytes {
 			bytes[i] = 0
@@ -97,7 +147,7 @@ func newRingloggerFromMappingHandle(mappingHandle windows.Handle, tag string, ac
 		tag:      tag,
 		mapping:  mappingHandle,
 		log:      log,
-		readOnly: access&windows.FILE_MAP_WRITE == 0,
+		readOnly: readOnly,
 	}
 	runtime.SetFinalizer(rl, (*Ringlogger).Close)
 	return rl, nil
@@ -110,6 +160,15 @@ func (rl *Ringlogger) Write(p []byte) (n int, err error) {
 }
 
 func (rl *Ringlogger) WriteWithTimestamp(p []byte, ts int64) (n int, err error) {
+	return rl.WriteEntry(LevelInfo, "", p, ts)
+}
+
+// WriteLevel writes a line with a severity, and optionally the name of the component it is from.
+func (rl *Ringlogger) WriteLevel(level Level, component string, p []byte) (n int, err error) {
+	return rl.WriteEntry(level, component, p, time.Now().UnixNano())
+}
+
+func (rl *Ringlogger) WriteEntry(level Level, component string, p []byte, ts int64) (n int, err error) {
 	if rl.readOnly {
 		return 0, io.ErrShortWrite
 	}
@@ -132,6 +191,12 @@ func (rl *Ringlogger) WriteWithTimestamp(p []byte, ts int64) (n int, err error)
 	for i := range line.line {
 		line.line[i] = 0
 	}
+	for i := range line.component {
+		line.component[i] = 0
+	}
+	line.sequence = index + 1
+	line.level = level
+	copy(line.component[:], component)
 
 	textLen := 3 + len(p) + len(rl.tag)
 	if textLen > maxLogLineLength-1 {
@@ -150,23 +215,64 @@ func (rl *Ringlogger) WriteWithTimestamp(p []byte, ts int64) (n int, err error)
 	return ret, nil
 }
 
+// entry returns the line at the index, or false if it is empty. Its stamp is zero if nothing
+// has been written there yet.
+func (log *logMem) entry(index uint32) (FollowLine, bool) {
+	var timeNs int64
+	var text []byte
+	item := FollowLine{Level: LevelInfo}
+	if log.magic == magicV1 {
+		line := &(*[maxLines]logLineV1)(unsafe.Pointer(&log.lines))[index%maxLines]
+		timeNs, text = line.timeNs, line.line[:]
+	} else {
+		line := &log.lines[index%maxLines]
+		timeNs, text = line.timeNs, line.line[:]
+		item.Sequence = line.sequence
+		if line.level != 0 {
+			item.Level = line.level
+		}
+		if end := bytes.IndexByte(line.component[:], 0); end != 0 {
+			if end < 0 {
+				end = len(line.component)
+			}
+			item.Component = string(line.component[:end])
+		}
+	}
+	if timeNs == 0 {
+		return item, false
+	}
+	item.Stamp = time.Unix(0, timeNs)
+	end := bytes.IndexByte(text, 0)
+	if end < 1 {
+		return item, false
+	}
+	item.Line = string(text[:end])
+	return item, true
+}
+
 func (rl *Ringlogger) WriteTo(out io.Writer) (n int64, err error) {
+	return rl.WriteLevelTo(out, LevelDebug)
+}
+
+// WriteLevelTo writes the lines with at least the given severity.
+func (rl *Ringlogger) WriteLevelTo(out io.Writer, minLevel Level) (n int64, err error) {
+	return rl.WriteFilteredTo(out, &DumpFilter{MinLevel: minLevel})
+}
+
+// WriteFilteredTo writes the lines that the filter matches.
+func (rl *Ringlogger) WriteFilteredTo(out io.Writer, filter *DumpFilter) (n int64, err error) {
 	if rl.log == nil {
 		return 0, io.EOF
 	}
 	log := *rl.log
 	i := log.nextIndex
 	for l := uint32(0); l < maxLines; l++ {
-		line := &log.lines[(i+l)%maxLines]
-		if line.timeNs == 0 {
-			continue
-		}
-		index := bytes.IndexByte(line.line[:], 0)
-		if index < 1 {
+		line, ok := log.entry(i + l)
+		if !ok || !filter.Matches(&line) {
 			continue
 		}
 		var bytes int
-		bytes, err = fmt.Fprintf(out, "%s: %s\n", time.Unix(0, line.timeNs).Format("2006-01-02 15:04:05.000000"), line.line[:index])
+		bytes, err = fmt.Fprintf(out, "%s: %s\n", line.Stamp.Format("2006-01-02 15:04:05.000000"), line.String())
 		if err != nil {
 			return
 		}
@@ -178,8 +284,70 @@ func (rl *Ringlogger) WriteTo(out io.Writer) (n int64, err error) {
 const CursorAll = ^uint32(0)
 
 type FollowLine struct {
-	Line  string
-	Stamp time.Time
+	Line      string
+	Stamp     time.Time
+	Level     Level
+	Component string
+	Sequence  uint32 // Zero for lines from before there were sequence numbers.
+}
+
+// String returns the line with its severity, unless informational, and its component after the
+// tag, so that lines without either read as they always did.
+func (line *FollowLine) String() string {
+	if line.Level == LevelInfo && len(line.Component) == 0 {
+		return line.Line
+	}
+	var prefix strings.Builder
+	if line.Level != LevelInfo {
+		fmt.Fprintf(&prefix, "[%s] ", line.Level)
+	}
+	if len(line.Component) > 0 {
+		fmt.Fprintf(&prefix, "[%s] ", line.Component)
+	}
+	tagEnd := strings.Index(line.Line, "] ")
+	if !strings.HasPrefix(line.Line, "[") || tagEnd < 0 {
+		return prefix.String() + line.Line
+	}
+	return line.Line[:tagEnd+2] + prefix.String() + line.Line[tagEnd+2:]
+}
+
+// Tags returns the tag of the process that wrote the line, such as MGR, and the bracketed name
+// that follows it in lines about a tunnel, such as "[TUN] [office] ", or else empty strings.
+func (line *FollowLine) Tags() (tag, subject string) {
+	rest, ok := strings.CutPrefix(line.Line, "[")
+	if !ok {
+		return
+	}
+	tag, rest, ok = strings.Cut(rest, "] ")
+	if !ok {
+		return "", ""
+	}
+	if rest, ok = strings.CutPrefix(rest, "["); ok {
+		if name, _, ok := strings.Cut(rest, "] "); ok {
+			subject = name
+		}
+	}
+	return
+}
+
+// DumpFilter selects lines by their severity, time, tunnel, and text. Its zero value selects all.
+type DumpFilter struct {
+	MinLevel Level
+	Since    time.Time // Earlier lines are skipped, unless it is zero.
+	Tunnel   string    // Only lines about this tunnel are selected, unless it is empty.
+	Contains string    // Only lines that contain this, in any case, are selected, unless it is empty.
+}
+
+func (filter *DumpFilter) Matches(line *FollowLine) bool {
+	if line.Level < filter.MinLevel || line.Stamp.Before(filter.Since) {
+		return false
+	}
+	if len(filter.Tunnel) > 0 {
+		if _, subject := line.Tags(); !strings.EqualFold(subject, filter.Tunnel) {
+			return false
+		}
+	}
+	return len(filter.Contains) == 0 || strings.Contains(strings.ToLower(line.String()), strings.ToLower(filter.Contains))
 }
 
 func (rl *Ringlogger) FollowFromCursor(cursor uint32) (followLines []FollowLine, nextCursor uint32) {
@@ -197,11 +365,11 @@ func (rl *Ringlogger) FollowFromCursor(cursor uint32) (followLines []FollowLine,
 	}
 
 	for l := 0; l < maxLines; l++ {
-		line := &log.lines[i%maxLines]
 		if cursor != CursorAll && i%maxLines == log.nextIndex%maxLines {
 			break
 		}
-		if line.timeNs == 0 {
+		line, ok := log.entry(i)
+		if line.Stamp.IsZero() {
 			if cursor == CursorAll {
 				i++
 				continue
@@ -209,9 +377,8 @@ func (rl *Ringlogger) FollowFromCursor(cursor uint32) (followLines []FollowLine,
 				break
 			}
 		}
-		index := bytes.IndexByte(line.line[:], 0)
-		if index > 0 {
-			followLines = append(followLines, FollowLine{string(line.line[:index]), time.Unix(0, line.timeNs)})
+		if ok {
+			followLines = append(followLines, line)
 		}
 		i++
 		nextCursor = i % maxLines
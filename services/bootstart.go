@@ -0,0 +1,110 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package services
+
+import (
+	"log"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// At boot, every tunnel service starts at once, while the driver and the network stack may still
+// be coming up, and so their first attempts to create adapters fail. Rather than each one backing
+// off on its own for up to half a minute, they take turns through a semaphore, so that only a few
+// contend for the device installer at a time, and wake up as soon as any one of them succeeds,
+// through an event, since that means the driver is ready. The manager creates both objects when it
+// starts at boot and holds them until the tunnels have settled, so that they outlive the services
+// that use them; services that start before it create them on their own.
+const (
+	BootAdapterSlots = 4
+
+	bootAdapterSlotsName = `Global\WireGuardBootAdapterSlots`
+	bootAdapterReadyName = `Global\WireGuardBootAdapterReady`
+
+	// Only services running as SYSTEM, as ours do, may use the objects.
+	bootCoordinationSDDL = "O:SYD:P(A;;GA;;;SY)"
+)
+
+// BootCoordination holds the objects through which tunnel services coordinate at boot.
+type BootCoordination struct {
+	slots windows.Handle
+	ready windows.Handle
+}
+
+// OpenBootCoordination creates the objects through which tunnel services coordinate at boot, or
+// opens them if another process already has.
+func OpenBootCoordination() (*BootCoordination, error) {
+	sd, err := windows.SecurityDescriptorFromString(bootCoordinationSDDL)
+	if err != nil {
+		return nil, err
+	}
+	sa := &windows.SecurityAttributes{Length: uint32(unsafe.Sizeof(windows.SecurityAttributes{})), SecurityDescriptor: sd}
+	slots, err := createSemaphore(sa, BootAdapterSlots, BootAdapterSlots, windows.StringToUTF16Ptr(bootAdapterSlotsName))
+	if err != nil {
+		return nil, err
+	}
+	ready, err := windows.CreateEvent(sa, 1, 0, windows.StringToUTF16Ptr(bootAdapterReadyName))
+	if err != nil {
+		windows.CloseHandle(slots)
+		return nil, err
+	}
+	return &BootCoordination{slots: slots, ready: ready}, nil
+}
+
+func (coordination *BootCoordination) Close() {
+	windows.CloseHandle(coordination.slots)
+	windows.CloseHandle(coordination.ready)
+}
+
+// AcquireAdapterSlot waits for a turn to create an adapter, for at most timeout, and returns
+// whether it got one, which must then be given back with ReleaseAdapterSlot.
+func (coordination *BootCoordination) AcquireAdapterSlot(timeout time.Duration) bool {
+	event, err := windows.WaitForSingleObject(coordination.slots, uint32(timeout.Milliseconds()))
+	return err == nil && event == windows.WAIT_OBJECT_0
+}
+
+func (coordination *BootCoordination) ReleaseAdapterSlot() {
+	releaseSemaphore(coordination.slots, 1, nil)
+}
+
+// AdapterReady reports whether any tunnel has created its adapter since boot.
+func (coordination *BootCoordination) AdapterReady() bool {
+	event, err := windows.WaitForSingleObject(coordination.ready, 0)
+	return err == nil && event == windows.WAIT_OBJECT_0
+}
+
+// WaitForAdapterReady waits for at most timeout for any tunnel to create its adapter.
+func (coordination *BootCoordination) WaitForAdapterReady(timeout time.Duration) {
+	windows.WaitForSingleObject(coordination.ready, uint32(timeout.Milliseconds()))
+}
+
+// SignalAdapterReady wakes up the tunnels waiting to try again to create their adapters.
+func (coordination *BootCoordination) SignalAdapterReady() {
+	windows.SetEvent(coordination.ready)
+}
+
+// SCMLockedAtBoot reports whether the service control manager is still locked, starting services
+// at boot, in which case services that wait on the network must report themselves as running
+// early, lest they hold up the rest of boot.
+func SCMLockedAtBoot() bool {
+	if !StartedAtBoot() {
+		return false
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return false
+	}
+	defer m.Disconnect()
+	lockStatus, err := m.LockStatus()
+	if err != nil || !lockStatus.IsLocked {
+		return false
+	}
+	log.Printf("SCM locked for %v by %s", lockStatus.Age, lockStatus.Owner)
+	return true
+}
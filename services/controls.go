@@ -0,0 +1,13 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package services
+
+import "golang.org/x/sys/windows/svc"
+
+// ControlReloadAdapterLog is sent by the manager to a running tunnel service after the adapter
+// log level of its tunnel changed, so that it applies the new level without restarting. Custom
+// control codes start at 128, which is taken by the upgrade restart of the installer.
+const ControlReloadAdapterLog = svc.Cmd(129)
@@ -31,6 +31,7 @@ const (
 	ErrorDropPrivileges
 	ErrorRunScript
 	ErrorWin32
+	ErrorConnectivityCheck
 )
 
 func (e Error) Error() string {
@@ -69,6 +70,8 @@ func (e Error) Error() string {
 		return "An error occurred while running a configuration script command"
 	case ErrorWin32:
 		return "An internal Windows error has occurred"
+	case ErrorConnectivityCheck:
+		return "Unable to reach the check host through the tunnel"
 	default:
 		return "An unknown error has occurred"
 	}
@@ -0,0 +1,12 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package services
+
+// https://docs.microsoft.com/en-us/windows/win32/api/synchapi/nf-synchapi-createsemaphorew
+//sys	createSemaphore(semaphoreAttrs *windows.SecurityAttributes, initialCount int32, maximumCount int32, name *uint16) (handle windows.Handle, err error) [failretval==0] = kernel32.CreateSemaphoreW
+
+// https://docs.microsoft.com/en-us/windows/win32/api/synchapi/nf-synchapi-releasesemaphore
+//sys	releaseSemaphore(semaphore windows.Handle, releaseCount int32, previousCount *int32) (err error) = kernel32.ReleaseSemaphore
@@ -0,0 +1,62 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// Tunnel services run as Local System in session 0, and, since they keep only the privileges they
+// need, cannot start processes as anyone else. So scripts that need the mapped drives, the
+// notifications, or the elevation of whoever is signed in are handed to the manager, which starts
+// them in the active console session with that user's token, over a pipe that only Local System
+// may open.
+const UserScriptPipeName = `\\.\pipe\ProtectedPrefix\Administrators\WireGuard\UserScripts`
+
+type UserScriptRequest struct {
+	Tunnel      string
+	Program     string
+	CommandLine string
+	Environment []string // Added to the environment of the user.
+	Elevated    bool     // Whether to use the elevated token of the user, who must be an administrator.
+	Timeout     time.Duration
+}
+
+type UserScriptResponse struct {
+	Output   []string
+	ExitCode uint32
+	TimedOut bool
+	Error    string
+}
+
+// RunUserScript has the manager run a script as the user signed in to the console, and waits for
+// it to finish.
+func RunUserScript(request *UserScriptRequest) (*UserScriptResponse, error) {
+	name16, err := windows.UTF16PtrFromString(UserScriptPipeName)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := windows.CreateFile(name16, windows.GENERIC_READ|windows.GENERIC_WRITE, 0, nil, windows.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	pipe := os.NewFile(uintptr(handle), UserScriptPipeName)
+	defer pipe.Close()
+	err = json.NewEncoder(pipe).Encode(request)
+	if err != nil {
+		return nil, err
+	}
+	var response UserScriptResponse
+	err = json.NewDecoder(pipe).Decode(&response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
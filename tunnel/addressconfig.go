@@ -9,10 +9,13 @@ import (
 	"fmt"
 	"log"
 	"net/netip"
+	"os"
+	"strings"
 	"time"
 
 	"golang.org/x/sys/windows"
 	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/etw"
 	"golang.zx2c4.com/wireguard/windows/services"
 	"golang.zx2c4.com/wireguard/windows/tunnel/firewall"
 	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
@@ -56,40 +59,17 @@ startOver:
 	}
 	tryTimes++
 
-	estimatedRouteCount := 0
-	for _, peer := range conf.Peers {
-		estimatedRouteCount += len(peer.AllowedIPs)
-	}
-	routes := make(map[winipcfg.RouteData]bool, estimatedRouteCount)
-
 	foundDefault4 := false
 	foundDefault6 := false
-	for _, peer := range conf.Peers {
-		for _, allowedip := range peer.AllowedIPs {
-			route := winipcfg.RouteData{
-				Destination: allowedip.Masked(),
-				Metric:      0,
+	for i := range conf.Peers {
+		for _, allowedip := range conf.Peers[i].AllowedIPs {
+			if allowedip.Bits() == 0 {
+				foundDefault4 = foundDefault4 || allowedip.Addr().Is4()
+				foundDefault6 = foundDefault6 || allowedip.Addr().Is6()
 			}
-			if allowedip.Addr().Is4() {
-				if allowedip.Bits() == 0 {
-					foundDefault4 = true
-				}
-				route.NextHop = netip.IPv4Unspecified()
-			} else if allowedip.Addr().Is6() {
-				if allowedip.Bits() == 0 {
-					foundDefault6 = true
-				}
-				route.NextHop = netip.IPv6Unspecified()
-			}
-			routes[route] = true
 		}
 	}
-
-	deduplicatedRoutes := make([]*winipcfg.RouteData, 0, len(routes))
-	for route := range routes {
-		r := route
-		deduplicatedRoutes = append(deduplicatedRoutes, &r)
-	}
+	deduplicatedRoutes := tunnelRoutes(conf)
 
 	if !conf.Interface.TableOff {
 		err = luid.SetRoutesForFamily(family, deduplicatedRoutes)
@@ -98,6 +78,16 @@ startOver:
 		} else if err != nil {
 			return fmt.Errorf("unable to set routes: %w", err)
 		}
+		etw.RoutesApplied(conf.Name, uint16(family), len(deduplicatedRoutes))
+	}
+
+	if conf.Interface.DisableDAD {
+		err = disableDAD(family, luid)
+		if err == windows.ERROR_NOT_FOUND && retryOnFailure {
+			goto startOver
+		} else if err != nil {
+			return fmt.Errorf("unable to disable duplicate address detection: %w", err)
+		}
 	}
 
 	err = luid.SetIPAddressesForFamily(family, conf.Interface.Addresses)
@@ -123,7 +113,10 @@ startOver:
 	if conf.Interface.MTU > 0 {
 		ipif.NLMTU = uint32(conf.Interface.MTU)
 	}
-	if (family == windows.AF_INET && foundDefault4) || (family == windows.AF_INET6 && foundDefault6) {
+	if conf.Interface.InterfaceMetric > 0 {
+		ipif.UseAutomaticMetric = false
+		ipif.Metric = conf.Interface.InterfaceMetric
+	} else if (family == windows.AF_INET && foundDefault4) || (family == windows.AF_INET6 && foundDefault6) {
 		ipif.UseAutomaticMetric = false
 		ipif.Metric = 0
 	}
@@ -134,15 +127,95 @@ startOver:
 		return fmt.Errorf("unable to set metric and MTU: %w", err)
 	}
 
-	err = luid.SetDNS(family, conf.Interface.DNS, conf.Interface.DNSSearch)
+	if family == windows.AF_INET6 && conf.Interface.DisableTemporaryAddresses {
+		removeTemporaryAddresses(luid)
+	}
+
+	dnsServers := conf.Interface.DNS
+	if usesSplitDNS(conf) {
+		// The NRPT rule sends the search domains to these servers, and everything else stays as it was.
+		dnsServers = nil
+	}
+	err = luid.SetDNS(family, dnsServers, conf.Interface.DNSSearch)
 	if err == windows.ERROR_NOT_FOUND && retryOnFailure {
 		goto startOver
 	} else if err != nil {
 		return fmt.Errorf("unable to set DNS: %w", err)
 	}
+	etw.DNSConfigured(conf.Name, uint16(family), dnsServers, conf.Interface.DNSSearch)
 	return nil
 }
 
+// tunnelRoutes returns the routes of the allowed IPs of the peers, without duplicates.
+func tunnelRoutes(conf *conf.Config) []*winipcfg.RouteData {
+	estimatedRouteCount := 0
+	for _, peer := range conf.Peers {
+		estimatedRouteCount += len(peer.AllowedIPs)
+	}
+	routes := make(map[winipcfg.RouteData]bool, estimatedRouteCount)
+	for i := range conf.Peers {
+		// Excluded addresses are left to the routes of the other interfaces.
+		for _, allowedip := range conf.RoutedIPs(&conf.Peers[i]) {
+			route := winipcfg.RouteData{
+				Destination: allowedip.Masked(),
+				Metric:      conf.Interface.RouteMetric,
+			}
+			if allowedip.Addr().Is4() {
+				route.NextHop = netip.IPv4Unspecified()
+			} else if allowedip.Addr().Is6() {
+				route.NextHop = netip.IPv6Unspecified()
+			}
+			routes[route] = true
+		}
+	}
+
+	deduplicatedRoutes := make([]*winipcfg.RouteData, 0, len(routes))
+	for route := range routes {
+		r := route
+		deduplicatedRoutes = append(deduplicatedRoutes, &r)
+	}
+	return deduplicatedRoutes
+}
+
+// disableDAD turns off duplicate address detection before any addresses are added, since the
+// interface settings below come too late to keep new addresses from being tentative for a while.
+func disableDAD(family winipcfg.AddressFamily, luid winipcfg.LUID) error {
+	ipif, err := luid.IPInterface(family)
+	if err != nil {
+		return err
+	}
+	if ipif.DadTransmits == 0 {
+		return nil
+	}
+	ipif.DadTransmits = 0
+	return ipif.Set()
+}
+
+// removeTemporaryAddresses deletes the privacy addresses that Windows derives from prefixes
+// it learns, leaving only the configured addresses and the link-local one.
+func removeTemporaryAddresses(luid winipcfg.LUID) {
+	addresses, err := winipcfg.GetUnicastIPAddressTable(windows.AF_INET6)
+	if err != nil {
+		log.Printf("Unable to list addresses to remove temporary ones: %v", err)
+		return
+	}
+	for i := range addresses {
+		address := &addresses[i]
+		if address.InterfaceLUID != luid || address.SuffixOrigin != winipcfg.SuffixOriginRandom {
+			continue
+		}
+		ip := address.Address.Addr()
+		if ip.IsLinkLocalUnicast() {
+			continue
+		}
+		log.Printf("Removing temporary address %s", ip)
+		err = address.Delete()
+		if err != nil {
+			log.Printf("Unable to remove temporary address %s: %v", ip, err)
+		}
+	}
+}
+
 func enableFirewall(conf *conf.Config, luid winipcfg.LUID) error {
 	doNotRestrict := true
 	if len(conf.Peers) == 1 && !conf.Interface.TableOff {
@@ -153,6 +226,63 @@ func enableFirewall(conf *conf.Config, luid winipcfg.LUID) error {
 			}
 		}
 	}
+	exemptions := append(firewallExemptions(), conf.Interface.ExcludeIPs...)
+	if conf.Interface.AllowLocalNetwork {
+		exemptions = append(exemptions, discoveryPrefixes...)
+	}
+	bypass := firewallBypass(conf)
+	if conf.Interface.KillSwitch {
+		log.Println("Enabling kill switch")
+		err := firewall.EnableKillSwitch(conf.Name, uint64(luid), exemptions, bypass)
+		if err != nil {
+			return err
+		}
+	}
 	log.Println("Enabling firewall rules")
-	return firewall.EnableFirewall(uint64(luid), doNotRestrict, conf.Interface.DNS)
+	return firewall.EnableFirewall(uint64(luid), doNotRestrict, conf.Interface.DNS, exemptions, bypass)
+}
+
+// firewallBypass returns the ports and programs whose traffic the configuration lets through
+// outside of the tunnel. Programs that are not installed are skipped, since the firewall can only
+// name programs that exist.
+func firewallBypass(config *conf.Config) *firewall.Bypass {
+	bypass := &firewall.Bypass{Ports: config.Interface.ExcludePorts}
+	for _, app := range config.Interface.ExcludeApps {
+		if _, err := os.Stat(app); err != nil {
+			log.Printf("Not excluding program %q: %v", app, err)
+			continue
+		}
+		bypass.Apps = append(bypass.Apps, app)
+	}
+	if len(bypass.Ports) > 0 {
+		log.Printf("Excluding ports %v from firewall rules", bypass.Ports)
+	}
+	for _, app := range bypass.Apps {
+		log.Printf("Excluding program %q from firewall rules", app)
+	}
+	return bypass
+}
+
+// firewallExemptions returns the destinations that admins have exempted from the kill switch and
+// from the blocking of untunneled traffic, given either as addresses or as prefixes.
+func firewallExemptions() []netip.Prefix {
+	var exemptions []netip.Prefix
+	for _, exemption := range conf.AdminStrings("FirewallExemptions") {
+		exemption = strings.TrimSpace(exemption)
+		if len(exemption) == 0 {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(exemption)
+		if err != nil {
+			addr, err2 := netip.ParseAddr(exemption)
+			if err2 != nil {
+				log.Printf("Ignoring invalid firewall exemption %q: %v", exemption, err)
+				continue
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		log.Printf("Exempting %s from firewall rules", prefix)
+		exemptions = append(exemptions, prefix)
+	}
+	return exemptions
 }
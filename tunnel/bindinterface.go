@@ -0,0 +1,201 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
+)
+
+// A tunnel with a BindInterface sends its encrypted packets through that interface, whatever the
+// default route says, by way of a host route to each endpoint through the gateway of the
+// interface. The routes are placed again whenever default routes or endpoints change, and are
+// removed when the tunnel stops.
+
+type pinnedRoute struct {
+	luid    winipcfg.LUID
+	nextHop netip.Addr
+}
+
+type endpointPins struct {
+	bindName string
+	mutex    sync.Mutex
+	routes   map[netip.Prefix]pinnedRoute
+	lastErr  string
+	closed   bool
+}
+
+// findBindInterface returns the LUID of the interface with the given GUID, alias, or description.
+func findBindInterface(name string) (winipcfg.LUID, error) {
+	interfaces, err := winipcfg.GetAdaptersAddresses(windows.AF_UNSPEC, winipcfg.GAAFlagDefault)
+	if err != nil {
+		return 0, err
+	}
+	guid := strings.Trim(name, "{}")
+	for _, iface := range interfaces {
+		if strings.EqualFold(strings.Trim(iface.AdapterName(), "{}"), guid) {
+			return iface.LUID, nil
+		}
+	}
+	for _, iface := range interfaces {
+		if strings.EqualFold(iface.FriendlyName(), name) || strings.EqualFold(iface.Description(), name) {
+			return iface.LUID, nil
+		}
+	}
+	return 0, errors.New("no such interface")
+}
+
+// defaultGateway returns the next hop of the default route of the interface with the lowest metric.
+func defaultGateway(family winipcfg.AddressFamily, luid winipcfg.LUID) (netip.Addr, error) {
+	routes, err := winipcfg.GetIPForwardTable2(family)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	var gateway netip.Addr
+	lowestMetric := ^uint32(0)
+	for i := range routes {
+		if routes[i].InterfaceLUID != luid || routes[i].DestinationPrefix.PrefixLength != 0 || routes[i].Metric >= lowestMetric {
+			continue
+		}
+		gateway = routes[i].NextHop.Addr()
+		lowestMetric = routes[i].Metric
+	}
+	if !gateway.IsValid() {
+		return netip.Addr{}, errors.New("interface has no default route")
+	}
+	return gateway, nil
+}
+
+func (pins *endpointPins) desiredRoutes(endpoints []netip.Addr) (map[netip.Prefix]pinnedRoute, error) {
+	luid, err := findBindInterface(pins.bindName)
+	if err != nil {
+		return nil, err
+	}
+	desired := make(map[netip.Prefix]pinnedRoute, len(endpoints))
+	var errs []string
+	for _, endpoint := range endpoints {
+		family := winipcfg.AddressFamily(windows.AF_INET)
+		if endpoint.Is6() {
+			family = windows.AF_INET6
+		}
+		gateway, err := defaultGateway(family, luid)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", endpoint, err))
+			continue
+		}
+		desired[netip.PrefixFrom(endpoint, endpoint.BitLen())] = pinnedRoute{luid, gateway}
+	}
+	if len(errs) > 0 {
+		return desired, errors.New(strings.Join(errs, "; "))
+	}
+	return desired, nil
+}
+
+// refresh places the routes for the given endpoints, and removes those of former ones.
+func (pins *endpointPins) refresh(endpoints []netip.Addr) {
+	pins.mutex.Lock()
+	defer pins.mutex.Unlock()
+	if pins.closed {
+		return
+	}
+	desired, err := pins.desiredRoutes(endpoints)
+	if err != nil {
+		// Only changes are logged, since a missing interface stays missing for many refreshes.
+		if err.Error() != pins.lastErr {
+			log.Printf("Unable to bind endpoints to interface %q: %v", pins.bindName, err)
+		}
+		pins.lastErr = err.Error()
+	} else {
+		pins.lastErr = ""
+	}
+	for destination, route := range pins.routes {
+		if desired[destination] == route {
+			continue
+		}
+		err := route.luid.DeleteRoute(destination, route.nextHop)
+		if err != nil && err != windows.ERROR_NOT_FOUND {
+			log.Printf("Unable to remove route to endpoint %s: %v", destination.Addr(), err)
+		}
+		delete(pins.routes, destination)
+	}
+	for destination, route := range desired {
+		if _, ok := pins.routes[destination]; ok {
+			continue
+		}
+		err := route.luid.AddRoute(destination, route.nextHop, 0)
+		if err != nil && err != windows.ERROR_OBJECT_ALREADY_EXISTS {
+			log.Printf("Unable to route endpoint %s through interface %q: %v", destination.Addr(), pins.bindName, err)
+			continue
+		}
+		log.Printf("Routing endpoint %s through interface %q via %s", destination.Addr(), pins.bindName, route.nextHop)
+		pins.routes[destination] = route
+	}
+}
+
+// Close removes the routes, and keeps further refreshes from placing new ones.
+func (pins *endpointPins) Close() {
+	pins.mutex.Lock()
+	defer pins.mutex.Unlock()
+	pins.closed = true
+	for destination, route := range pins.routes {
+		route.luid.DeleteRoute(destination, route.nextHop)
+	}
+	pins.routes = nil
+}
+
+// bindEndpoints routes the endpoints of the tunnel through the named interface until the context
+// is done. The returned pins are to be closed when the tunnel stops.
+func bindEndpoints(ctx context.Context, watcher *interfaceWatcher, luid winipcfg.LUID, bindName string) *endpointPins {
+	pins := &endpointPins{bindName: bindName, routes: make(map[netip.Prefix]pinnedRoute)}
+	changed := make(chan struct{}, 1)
+	cb, err := winipcfg.RegisterRouteChangeCallback(func(notificationType winipcfg.MibNotificationType, route *winipcfg.MibIPforwardRow2) {
+		if route == nil || route.DestinationPrefix.PrefixLength != 0 || route.InterfaceLUID == luid {
+			return
+		}
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+	if err != nil {
+		log.Printf("Unable to monitor default routes for binding endpoints: %v", err)
+	}
+	pins.refresh(watcher.EndpointAddrs())
+	go func() {
+		if cb != nil {
+			defer cb.Unregister()
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+			case <-watcher.endpointChanged:
+			}
+			for settled := false; !settled; {
+				select {
+				case <-ctx.Done():
+					return
+				case <-changed:
+				case <-watcher.endpointChanged:
+				case <-time.After(endpointReselectDelay):
+					settled = true
+				}
+			}
+			pins.refresh(watcher.EndpointAddrs())
+		}
+	}()
+	return pins
+}
@@ -0,0 +1,92 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package tunnel
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/driver"
+	"golang.zx2c4.com/wireguard/windows/services"
+	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
+)
+
+const (
+	adapterAttemptsAtBoot = 15
+	adapterSlotTimeout    = time.Second * 30
+	maxAdapterRetryDelay  = time.Second * 30
+)
+
+// adapterGUID returns the GUID that the adapter of the tunnel is to have: the one that its
+// configuration gives, unless another interface already has it, or else one derived from the
+// configuration.
+func adapterGUID(config *conf.Config) (*windows.GUID, error) {
+	guid := config.Interface.AdapterGUID
+	if guid == nil {
+		return deterministicGUID(config), nil
+	}
+	if luid, err := winipcfg.LUIDFromGUID(guid); err == nil {
+		// An adapter left behind by this tunnel, such as after a crash, has its name.
+		if row, err := luid.Interface(); err != nil || row.Alias() != config.Name {
+			alias := "another interface"
+			if err == nil {
+				alias = fmt.Sprintf("the interface ‘%s’", row.Alias())
+			}
+			return nil, fmt.Errorf("The adapter GUID %v is already used by %s", guid, alias)
+		}
+	}
+	log.Printf("Using configured adapter GUID %v", guid)
+	return guid, nil
+}
+
+// createAdapterAtBoot creates the adapter of the tunnel, retrying at boot while the driver is not
+// yet ready, in turns with the other tunnels, as arranged by services.BootCoordination.
+func createAdapterAtBoot(config *conf.Config, guid *windows.GUID) (adapter *driver.Adapter, err error) {
+	if !services.StartedAtBoot() {
+		return driver.CreateAdapter(config.Name, "WireGuard", guid)
+	}
+	coordination, coordinationErr := services.OpenBootCoordination()
+	if coordinationErr != nil {
+		log.Printf("Unable to coordinate adapter creation with other tunnels: %v", coordinationErr)
+		coordination = nil
+	} else {
+		defer coordination.Close()
+	}
+	retryDelay := time.Second
+	for i := 0; i < adapterAttemptsAtBoot; i++ {
+		if i > 0 {
+			retryDelay *= 2
+			if retryDelay > maxAdapterRetryDelay {
+				retryDelay = maxAdapterRetryDelay
+			}
+			waitStart := time.Now()
+			if coordination != nil && !coordination.AdapterReady() {
+				// Another tunnel creating its adapter means that the driver is ready, so there is no
+				// point in waiting out the rest of the delay.
+				coordination.WaitForAdapterReady(retryDelay)
+			} else {
+				time.Sleep(retryDelay)
+			}
+			log.Printf("Retrying adapter creation (attempt %d, waited %v): %v", i+1, time.Since(waitStart).Round(time.Millisecond), err)
+		}
+		haveSlot := coordination != nil && coordination.AcquireAdapterSlot(adapterSlotTimeout)
+		adapter, err = driver.CreateAdapter(config.Name, "WireGuard", guid)
+		if haveSlot {
+			coordination.ReleaseAdapterSlot()
+		}
+		if err == nil {
+			if coordination != nil {
+				coordination.SignalAdapterReady()
+			}
+			return adapter, nil
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,121 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
+)
+
+// A tunnel whose handshakes succeed may still carry no traffic, when the allowed IPs, routes, or
+// firewall on the other side are wrong, so a tunnel with a CheckHost is only reported as running
+// once that host answers through it: an echo request when no port is given, and a TCP connection
+// to the port otherwise. Both are sent from an address of the tunnel, so that they cannot take
+// another interface.
+
+const (
+	defaultCheckTimeout  = 10 * time.Second
+	checkRetryInterval   = time.Second
+	checkEchoPayloadSize = 32
+)
+
+// checkTimeout returns how long the connectivity check of config is to keep trying.
+func checkTimeout(config *conf.Config) time.Duration {
+	if config.Interface.CheckTimeout > 0 {
+		return time.Duration(config.Interface.CheckTimeout) * time.Second
+	}
+	return defaultCheckTimeout
+}
+
+// checkConnectivity tries to reach host through the tunnel with the given LUID until it answers,
+// the timeout passes, or ctx is done.
+func checkConnectivity(ctx context.Context, host string, timeout time.Duration, luid winipcfg.LUID) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	name, port := host, ""
+	if strings.ContainsRune(host, ':') {
+		if _, err := netip.ParseAddr(host); err != nil {
+			name, port, err = net.SplitHostPort(host)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		lastErr = checkConnectivityOnce(ctx, name, port, luid)
+		if lastErr == nil {
+			log.Printf("Reached check host %s after %d attempt(s)", host, attempt)
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s did not answer within %v: %w", host, timeout, lastErr)
+		case <-time.After(checkRetryInterval):
+		}
+	}
+}
+
+func checkConnectivityOnce(ctx context.Context, name, port string, luid winipcfg.LUID) error {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", name)
+	if err != nil {
+		return err
+	}
+	err = errors.New("no addresses")
+	for _, addr := range addrs {
+		addr = addr.Unmap()
+		family := winipcfg.AddressFamily(windows.AF_INET)
+		if addr.Is6() {
+			family = windows.AF_INET6
+		}
+		source := sourceAddress(family, luid)
+		if !source.IsValid() {
+			err = fmt.Errorf("the tunnel has no address to reach %v from", addr)
+			continue
+		}
+		if len(port) == 0 {
+			err = echoThroughTunnel(source, addr)
+		} else {
+			err = connectThroughTunnel(ctx, source, addr, port)
+		}
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func echoThroughTunnel(source, destination netip.Addr) error {
+	icmp, err := winipcfg.OpenICMP(source, destination)
+	if err != nil {
+		return err
+	}
+	defer icmp.Close()
+	if err = icmp.Echo(checkEchoPayloadSize, true, mtuProbeTimeout); err != nil {
+		return fmt.Errorf("no echo reply from %v: %w", destination, err)
+	}
+	return nil
+}
+
+func connectThroughTunnel(ctx context.Context, source, destination netip.Addr, port string) error {
+	dialer := net.Dialer{LocalAddr: &net.TCPAddr{IP: source.AsSlice(), Zone: source.Zone()}}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(destination.String(), port))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
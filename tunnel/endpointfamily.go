@@ -0,0 +1,100 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package tunnel
+
+import (
+	"context"
+	"log"
+	"net/netip"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
+)
+
+// Routes change in bursts while a network comes up, so endpoints are chosen again only once they
+// have settled for this long.
+const endpointReselectDelay = 2 * time.Second
+
+// hasDefaultRouteAvoiding returns a test of whether the family of an endpoint has a default route
+// through an interface other than that of the tunnel. Once a tunnel routes everything, the socket
+// test of the resolver would find a way to any address through the tunnel itself.
+func hasDefaultRouteAvoiding(luid winipcfg.LUID) func(netip.AddrPort) bool {
+	return func(endpoint netip.AddrPort) bool {
+		family := winipcfg.AddressFamily(windows.AF_INET)
+		if endpoint.Addr().Is6() {
+			family = windows.AF_INET6
+		}
+		var defaultLUID winipcfg.LUID
+		defaultIndex := ^uint32(0)
+		return findDefaultLUID(family, luid, &defaultLUID, &defaultIndex) == nil && defaultLUID != 0
+	}
+}
+
+// monitorEndpointFamilies chooses the addresses of the endpoints that are given as host names
+// again whenever a default route of another interface comes or goes, so that a tunnel that came
+// up on a network with IPv6 moves to IPv4 on one without, and back.
+func monitorEndpointFamilies(ctx context.Context, watcher *interfaceWatcher, luid winipcfg.LUID, config *conf.Config, endpoints []pendingEndpoint) {
+	if len(endpoints) == 0 {
+		return
+	}
+	current := make([]string, len(endpoints))
+	for i := range endpoints {
+		current[i] = config.Peers[endpoints[i].peer].Endpoint.Host
+	}
+	changed := make(chan struct{}, 1)
+	cb, err := winipcfg.RegisterRouteChangeCallback(func(notificationType winipcfg.MibNotificationType, route *winipcfg.MibIPforwardRow2) {
+		if route == nil || route.DestinationPrefix.PrefixLength != 0 || route.InterfaceLUID == luid {
+			return
+		}
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+	if err != nil {
+		log.Printf("Unable to monitor default routes for choosing endpoint addresses: %v", err)
+		return
+	}
+	defer cb.Unregister()
+
+	routable := hasDefaultRouteAvoiding(luid)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		}
+		for settled := false; !settled; {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+			case <-time.After(endpointReselectDelay):
+				settled = true
+			}
+		}
+		lookup := conf.Config{Peers: make([]conf.Peer, len(endpoints))}
+		for i := range endpoints {
+			lookup.Peers[i].Endpoint = endpoints[i].endpoint
+		}
+		lookup.ResolveEndpointsWith(routable)
+		for i := range endpoints {
+			host := lookup.Peers[i].Endpoint.Host
+			if _, err := netip.ParseAddr(host); err != nil || host == current[i] {
+				continue
+			}
+			log.Printf("Network changed, switching endpoint %s of peer %s from %s to %s", endpoints[i].endpoint.Host, endpoints[i].peerName, current[i], host)
+			err = watcher.SetEndpointHost(endpoints[i].peer, host)
+			if err != nil {
+				log.Printf("Unable to set endpoint of peer %s: %v", endpoints[i].peerName, err)
+				continue
+			}
+			current[i] = host
+		}
+	}
+}
@@ -0,0 +1,100 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package tunnel
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"time"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/driver"
+	"golang.zx2c4.com/wireguard/windows/etw"
+)
+
+// The driver does not tell of completed handshakes or roaming, so they are found by polling its
+// peers, but only while a trace session wants them.
+const etwPeerPollInterval = time.Second
+
+// handshakeInitiationPeer returns the peer of a log message of the data path about sending a
+// handshake initiation, as the message names it, such as "peer 3 (192.0.2.1:51820)".
+func handshakeInitiationPeer(msg string) (string, bool) {
+	const marker = "Sending handshake initiation"
+	i := strings.Index(msg, marker)
+	if i < 0 {
+		return "", false
+	}
+	if rest := msg[i+len(marker):]; strings.HasPrefix(rest, " to ") {
+		return strings.TrimSpace(rest[len(" to "):]), true
+	}
+	return strings.TrimSuffix(strings.TrimSpace(msg[:i]), " -"), true
+}
+
+func traceHandshakeInitiations(tunnelName string) {
+	driver.SetLogObserver(func(msg string) {
+		if peer, ok := handshakeInitiationPeer(msg); ok {
+			etw.HandshakeInitiation(tunnelName, peer)
+		}
+	})
+}
+
+type etwPeerState struct {
+	lastHandshake uint64
+	endpoint      netip.AddrPort
+}
+
+// tracePeers writes events for the handshakes that peers complete and for the changes of their
+// endpoints, until ctx is done.
+func tracePeers(ctx context.Context, adapter *driver.Adapter, config *conf.Config) {
+	names := make(map[conf.Key]string, len(config.Peers))
+	for i := range config.Peers {
+		names[config.Peers[i].PublicKey] = config.Peers[i].DisplayName()
+	}
+	states := make(map[conf.Key]etwPeerState)
+	ticker := time.NewTicker(etwPeerPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !etw.Enabled(etw.KeywordHandshake | etw.KeywordEndpoint) {
+			// Whatever happened while no one was listening is not news later.
+			if len(states) > 0 {
+				states = make(map[conf.Key]etwPeerState)
+			}
+			continue
+		}
+		iface, err := adapter.Configuration()
+		if err != nil {
+			continue
+		}
+		peer := iface.FirstPeer()
+		for i := uint32(0); i < iface.PeerCount; i++ {
+			key := conf.Key(peer.PublicKey)
+			current := etwPeerState{lastHandshake: peer.LastHandshake}
+			if peer.Flags&driver.PeerHasEndpoint != 0 {
+				current.endpoint = peer.Endpoint.AddrPort()
+			}
+			name, ok := names[key]
+			if !ok {
+				name = key.String()
+			}
+			if last, seen := states[key]; seen {
+				if current.lastHandshake > last.lastHandshake {
+					etw.HandshakeComplete(config.Name, name, current.endpoint)
+				}
+				if current.endpoint != last.endpoint {
+					etw.EndpointRoamed(config.Name, name, last.endpoint, current.endpoint)
+				}
+			}
+			states[key] = current
+			peer = peer.NextPeer()
+		}
+	}
+}
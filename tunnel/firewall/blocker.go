@@ -26,14 +26,18 @@ type baseObjects struct {
 var wfpSession uintptr
 
 func createWfpSession() (uintptr, error) {
-	sessionDisplayData, err := createWtFwpmDisplayData0("WireGuard", "WireGuard dynamic session")
+	return openWfpSession("WireGuard dynamic session", cFWPM_SESSION_FLAG_DYNAMIC)
+}
+
+func openWfpSession(description string, flags wtFwpmSessionFlagsValue) (uintptr, error) {
+	sessionDisplayData, err := createWtFwpmDisplayData0("WireGuard", description)
 	if err != nil {
 		return 0, wrapErr(err)
 	}
 
 	session := wtFwpmSession0{
 		displayData:          *sessionDisplayData,
-		flags:                cFWPM_SESSION_FLAG_DYNAMIC,
+		flags:                flags,
 		txnWaitTimeoutInMSec: windows.INFINITE,
 	}
 
@@ -58,14 +62,21 @@ func registerBaseObjects(session uintptr) (*baseObjects, error) {
 	if err != nil {
 		return nil, wrapErr(err)
 	}
+	err = addBaseObjects(session, bo)
+	if err != nil {
+		return nil, err
+	}
+	return bo, nil
+}
 
+func addBaseObjects(session uintptr, bo *baseObjects) error {
 	//
 	// Register provider.
 	//
 	{
 		displayData, err := createWtFwpmDisplayData0("WireGuard", "WireGuard provider")
 		if err != nil {
-			return nil, wrapErr(err)
+			return wrapErr(err)
 		}
 		provider := wtFwpmProvider0{
 			providerKey: bo.provider,
@@ -74,7 +85,7 @@ func registerBaseObjects(session uintptr) (*baseObjects, error) {
 		err = fwpmProviderAdd0(session, &provider, 0)
 		if err != nil {
 			// TODO: cleanup entire call chain of these if failure?
-			return nil, wrapErr(err)
+			return wrapErr(err)
 		}
 	}
 
@@ -84,7 +95,7 @@ func registerBaseObjects(session uintptr) (*baseObjects, error) {
 	{
 		displayData, err := createWtFwpmDisplayData0("WireGuard filters", "Permissive and blocking filters")
 		if err != nil {
-			return nil, wrapErr(err)
+			return wrapErr(err)
 		}
 		sublayer := wtFwpmSublayer0{
 			subLayerKey: bo.filters,
@@ -94,14 +105,25 @@ func registerBaseObjects(session uintptr) (*baseObjects, error) {
 		}
 		err = fwpmSubLayerAdd0(session, &sublayer, 0)
 		if err != nil {
-			return nil, wrapErr(err)
+			return wrapErr(err)
 		}
 	}
 
-	return bo, nil
+	return nil
+}
+
+// Bypass is traffic that is let through outside of the tunnel, by its ports or by the programs,
+// given by their full paths, that send and receive it.
+type Bypass struct {
+	Ports []uint16
+	Apps  []string
+}
+
+func (bypass *Bypass) isEmpty() bool {
+	return bypass == nil || len(bypass.Ports)+len(bypass.Apps) == 0
 }
 
-func EnableFirewall(luid uint64, doNotRestrict bool, restrictToDNSServers []netip.Addr) error {
+func EnableFirewall(luid uint64, doNotRestrict bool, restrictToDNSServers []netip.Addr, exemptions []netip.Prefix, bypass *Bypass) error {
 	if wfpSession != 0 {
 		return errors.New("The firewall has already been enabled")
 	}
@@ -123,6 +145,20 @@ func EnableFirewall(luid uint64, doNotRestrict bool, restrictToDNSServers []neti
 		}
 
 		if !doNotRestrict {
+			if len(exemptions) > 0 {
+				err = permitExemptions(exemptions, session, baseObjects, 15)
+				if err != nil {
+					return wrapErr(err)
+				}
+			}
+
+			if !bypass.isEmpty() {
+				err = permitBypass(bypass, session, baseObjects, 15)
+				if err != nil {
+					return wrapErr(err)
+				}
+			}
+
 			if len(restrictToDNSServers) > 0 {
 				err = blockDNS(restrictToDNSServers, session, baseObjects, 15, 14)
 				if err != nil {
@@ -0,0 +1,80 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package firewall
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+func actionName(action wtFwpActionType) string {
+	switch action {
+	case cFWP_ACTION_BLOCK:
+		return "block"
+	case cFWP_ACTION_PERMIT:
+		return "permit"
+	default:
+		return fmt.Sprintf("action 0x%x", uint32(action))
+	}
+}
+
+// DescribeFilters returns a line for each filter of a WireGuard provider, whether installed by a
+// tunnel service or as a kill switch, so that they can be looked at when something is blocked.
+func DescribeFilters() ([]string, error) {
+	session, err := openWfpSession("WireGuard diagnostics session", 0)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	defer fwpmEngineClose0(session)
+
+	ours := make(map[windows.GUID]bool)
+	isOurs := func(providerKey *windows.GUID) bool {
+		if providerKey == nil {
+			return false
+		}
+		if known, ok := ours[*providerKey]; ok {
+			return known
+		}
+		var provider *wtFwpmProvider0
+		if fwpmProviderGetByKey0(session, providerKey, &provider) != nil {
+			ours[*providerKey] = false
+			return false
+		}
+		ours[*providerKey] = windows.UTF16PtrToString(provider.displayData.name) == "WireGuard"
+		fwpmFreeMemory0(unsafe.Pointer(&provider))
+		return ours[*providerKey]
+	}
+
+	var enumHandle uintptr
+	err = fwpmFilterCreateEnumHandle0(session, 0, &enumHandle)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	defer fwpmFilterDestroyEnumHandle0(session, enumHandle)
+	var lines []string
+	for {
+		var entries **wtFwpmFilter0
+		var numEntries uint32
+		err = fwpmFilterEnum0(session, enumHandle, 128, &entries, &numEntries)
+		if err != nil {
+			return nil, wrapErr(err)
+		}
+		if numEntries == 0 {
+			break
+		}
+		for _, filter := range unsafe.Slice(entries, numEntries) {
+			if !isOurs(filter.providerKey) {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%d: %s: %s (layer %v, sublayer %v)", filter.filterID, actionName(filter.action._type),
+				windows.UTF16PtrToString(filter.displayData.name), filter.layerKey, filter.subLayerKey))
+		}
+		fwpmFreeMemory0(unsafe.Pointer(&entries))
+	}
+	return lines, nil
+}
@@ -133,8 +133,13 @@ func getCurrentProcessAppID() (*wtFwpByteBlob, error) {
 	if err != nil {
 		return nil, wrapErr(err)
 	}
+	return getAppID(currentFile)
+}
 
-	curFilePtr, err := windows.UTF16PtrFromString(currentFile)
+// getAppID returns the application identifier of the program at the given path, which is to be
+// freed with fwpmFreeMemory0.
+func getAppID(fileName string) (*wtFwpByteBlob, error) {
+	curFilePtr, err := windows.UTF16PtrFromString(fileName)
 	if err != nil {
 		return nil, wrapErr(err)
 	}
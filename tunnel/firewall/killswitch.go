@@ -0,0 +1,190 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package firewall
+
+import (
+	"net/netip"
+	"unsafe"
+
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/sys/windows"
+)
+
+// The kill switch is installed from a non-dynamic session, so its filters outlive the tunnel
+// service process: if the service crashes or the adapter vanishes, traffic stays blocked until
+// DisableKillSwitch is called or the base filtering engine restarts. The provider and sublayer
+// keys are derived from the tunnel name, so that any process can find and remove them later.
+
+const killSwitchGUIDLabel = "WireGuard Windows kill switch GUID v1"
+
+func killSwitchGUID(tunnelName, kind string) windows.GUID {
+	b2, _ := blake2s.New256(nil)
+	b2.Write([]byte(killSwitchGUIDLabel))
+	b2.Write([]byte(kind))
+	b2.Write([]byte{0})
+	b2.Write([]byte(tunnelName))
+	return *(*windows.GUID)(unsafe.Pointer(&b2.Sum(nil)[0]))
+}
+
+func killSwitchBaseObjects(tunnelName string) *baseObjects {
+	return &baseObjects{
+		provider: killSwitchGUID(tunnelName, "provider"),
+		filters:  killSwitchGUID(tunnelName, "sublayer"),
+	}
+}
+
+// EnableKillSwitch blocks all traffic that does not go through the tunnel interface with the given LUID,
+// except to and from the exempt destinations and the bypassed traffic. Any existing kill switch for the same tunnel is replaced,
+// which matters when the adapter has been recreated.
+func EnableKillSwitch(tunnelName string, luid uint64, exemptions []netip.Prefix, bypass *Bypass) error {
+	session, err := openWfpSession("WireGuard kill switch session", 0)
+	if err != nil {
+		return wrapErr(err)
+	}
+	defer fwpmEngineClose0(session)
+
+	baseObjects := killSwitchBaseObjects(tunnelName)
+	return runTransaction(session, func(session uintptr) error {
+		err := removeKillSwitchObjects(session, baseObjects)
+		if err != nil {
+			return wrapErr(err)
+		}
+
+		err = addBaseObjects(session, baseObjects)
+		if err != nil {
+			return wrapErr(err)
+		}
+
+		err = permitWireGuardService(session, baseObjects, 15)
+		if err != nil {
+			return wrapErr(err)
+		}
+
+		err = permitLoopback(session, baseObjects, 13)
+		if err != nil {
+			return wrapErr(err)
+		}
+
+		err = permitTunInterface(session, baseObjects, 12, luid)
+		if err != nil {
+			return wrapErr(err)
+		}
+
+		err = permitDHCPIPv4(session, baseObjects, 12)
+		if err != nil {
+			return wrapErr(err)
+		}
+
+		err = permitDHCPIPv6(session, baseObjects, 12)
+		if err != nil {
+			return wrapErr(err)
+		}
+
+		err = permitNdp(session, baseObjects, 12)
+		if err != nil {
+			return wrapErr(err)
+		}
+
+		if len(exemptions) > 0 {
+			err = permitExemptions(exemptions, session, baseObjects, 12)
+			if err != nil {
+				return wrapErr(err)
+			}
+		}
+
+		if !bypass.isEmpty() {
+			err = permitBypass(bypass, session, baseObjects, 12)
+			if err != nil {
+				return wrapErr(err)
+			}
+		}
+
+		err = blockAll(session, baseObjects, 0)
+		if err != nil {
+			return wrapErr(err)
+		}
+
+		return nil
+	})
+}
+
+// DisableKillSwitch removes the kill switch of the given tunnel, if there is one.
+func DisableKillSwitch(tunnelName string) error {
+	session, err := openWfpSession("WireGuard kill switch session", 0)
+	if err != nil {
+		return wrapErr(err)
+	}
+	defer fwpmEngineClose0(session)
+
+	baseObjects := killSwitchBaseObjects(tunnelName)
+	return runTransaction(session, func(session uintptr) error {
+		return removeKillSwitchObjects(session, baseObjects)
+	})
+}
+
+// KillSwitchActive reports whether the kill switch of the given tunnel is currently installed.
+func KillSwitchActive(tunnelName string) (bool, error) {
+	session, err := createWfpSession()
+	if err != nil {
+		return false, wrapErr(err)
+	}
+	defer fwpmEngineClose0(session)
+
+	baseObjects := killSwitchBaseObjects(tunnelName)
+	var sublayer *wtFwpmSublayer0
+	err = fwpmSubLayerGetByKey0(session, &baseObjects.filters, &sublayer)
+	if err == cFWP_E_SUBLAYER_NOT_FOUND {
+		return false, nil
+	} else if err != nil {
+		return false, wrapErr(err)
+	}
+	fwpmFreeMemory0(unsafe.Pointer(&sublayer))
+	return true, nil
+}
+
+func removeKillSwitchObjects(session uintptr, baseObjects *baseObjects) error {
+	var enumHandle uintptr
+	err := fwpmFilterCreateEnumHandle0(session, 0, &enumHandle)
+	if err != nil {
+		return wrapErr(err)
+	}
+	var filterKeys []windows.GUID
+	for {
+		var entries **wtFwpmFilter0
+		var numEntries uint32
+		err = fwpmFilterEnum0(session, enumHandle, 128, &entries, &numEntries)
+		if err != nil {
+			fwpmFilterDestroyEnumHandle0(session, enumHandle)
+			return wrapErr(err)
+		}
+		if numEntries == 0 {
+			break
+		}
+		for _, filter := range unsafe.Slice(entries, numEntries) {
+			if filter.subLayerKey == baseObjects.filters {
+				filterKeys = append(filterKeys, filter.filterKey)
+			}
+		}
+		fwpmFreeMemory0(unsafe.Pointer(&entries))
+	}
+	fwpmFilterDestroyEnumHandle0(session, enumHandle)
+
+	for i := range filterKeys {
+		err = fwpmFilterDeleteByKey0(session, &filterKeys[i])
+		if err != nil && err != cFWP_E_FILTER_NOT_FOUND {
+			return wrapErr(err)
+		}
+	}
+	err = fwpmSubLayerDeleteByKey0(session, &baseObjects.filters)
+	if err != nil && err != cFWP_E_SUBLAYER_NOT_FOUND {
+		return wrapErr(err)
+	}
+	err = fwpmProviderDeleteByKey0(session, &baseObjects.provider)
+	if err != nil && err != cFWP_E_PROVIDER_NOT_FOUND {
+		return wrapErr(err)
+	}
+	return nil
+}
@@ -854,6 +854,205 @@ func permitHyperV(session uintptr, baseObjects *baseObjects, weight uint8) error
 	return nil
 }
 
+// Permit all traffic to and from the given destinations, which admins exempt so that things like
+// network access control agents keep working.
+func permitExemptions(exemptions []netip.Prefix, session uintptr, baseObjects *baseObjects, weight uint8) error {
+	v4Addresses := make([]wtFwpV4AddrAndMask, 0, len(exemptions))
+	v6Addresses := make([]wtFwpV6AddrAndMask, 0, len(exemptions))
+	for _, prefix := range exemptions {
+		prefix = prefix.Masked()
+		if prefix.Addr().Is4() {
+			v4Addresses = append(v4Addresses, wtFwpV4AddrAndMask{
+				addr: binary.BigEndian.Uint32(prefix.Addr().AsSlice()),
+				mask: ^uint32(0) << (32 - prefix.Bits()),
+			})
+		} else {
+			v6Addresses = append(v6Addresses, wtFwpV6AddrAndMask{
+				addr:         prefix.Addr().As16(),
+				prefixLength: uint8(prefix.Bits()),
+			})
+		}
+	}
+
+	// Repeating the condition type makes for a logical OR.
+	v4Conditions := make([]wtFwpmFilterCondition0, len(v4Addresses))
+	for i := range v4Addresses {
+		v4Conditions[i] = wtFwpmFilterCondition0{
+			fieldKey:  cFWPM_CONDITION_IP_REMOTE_ADDRESS,
+			matchType: cFWP_MATCH_EQUAL,
+			conditionValue: wtFwpConditionValue0{
+				_type: cFWP_V4_ADDR_MASK,
+				value: uintptr(unsafe.Pointer(&v4Addresses[i])),
+			},
+		}
+	}
+	v6Conditions := make([]wtFwpmFilterCondition0, len(v6Addresses))
+	for i := range v6Addresses {
+		v6Conditions[i] = wtFwpmFilterCondition0{
+			fieldKey:  cFWPM_CONDITION_IP_REMOTE_ADDRESS,
+			matchType: cFWP_MATCH_EQUAL,
+			conditionValue: wtFwpConditionValue0{
+				_type: cFWP_V6_ADDR_MASK,
+				value: uintptr(unsafe.Pointer(&v6Addresses[i])),
+			},
+		}
+	}
+
+	filter := wtFwpmFilter0{
+		providerKey: &baseObjects.provider,
+		subLayerKey: baseObjects.filters,
+		weight:      filterWeight(weight),
+		action: wtFwpmAction0{
+			_type: cFWP_ACTION_PERMIT,
+		},
+	}
+
+	if len(v4Conditions) > 0 {
+		filter.numFilterConditions = uint32(len(v4Conditions))
+		filter.filterCondition = &v4Conditions[0]
+
+		//
+		// #1 Permit outbound IPv4 to exempt destinations.
+		//
+		err := addFilter(session, &filter, cFWPM_LAYER_ALE_AUTH_CONNECT_V4, "Permit outbound to exempt destinations (IPv4)")
+		if err != nil {
+			return err
+		}
+
+		//
+		// #2 Permit inbound IPv4 from exempt destinations.
+		//
+		err = addFilter(session, &filter, cFWPM_LAYER_ALE_AUTH_RECV_ACCEPT_V4, "Permit inbound from exempt destinations (IPv4)")
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(v6Conditions) > 0 {
+		filter.numFilterConditions = uint32(len(v6Conditions))
+		filter.filterCondition = &v6Conditions[0]
+
+		//
+		// #3 Permit outbound IPv6 to exempt destinations.
+		//
+		err := addFilter(session, &filter, cFWPM_LAYER_ALE_AUTH_CONNECT_V6, "Permit outbound to exempt destinations (IPv6)")
+		if err != nil {
+			return err
+		}
+
+		//
+		// #4 Permit inbound IPv6 from exempt destinations.
+		//
+		err = addFilter(session, &filter, cFWPM_LAYER_ALE_AUTH_RECV_ACCEPT_V6, "Permit inbound from exempt destinations (IPv6)")
+		if err != nil {
+			return err
+		}
+	}
+
+	runtime.KeepAlive(v4Addresses)
+	runtime.KeepAlive(v6Addresses)
+
+	return nil
+}
+
+// Permit TCP and UDP traffic to and from the bypassed ports, and all traffic of the bypassed programs.
+func permitBypass(bypass *Bypass, session uintptr, baseObjects *baseObjects, weight uint8) error {
+	filter := wtFwpmFilter0{
+		providerKey: &baseObjects.provider,
+		subLayerKey: baseObjects.filters,
+		weight:      filterWeight(weight),
+		action: wtFwpmAction0{
+			_type: cFWP_ACTION_PERMIT,
+		},
+	}
+
+	if len(bypass.Ports) > 0 {
+		// Conditions on different fields must all match, while those on the same field are alternatives.
+		protocols := []wtFwpmFilterCondition0{
+			{
+				fieldKey:       cFWPM_CONDITION_IP_PROTOCOL,
+				matchType:      cFWP_MATCH_EQUAL,
+				conditionValue: wtFwpConditionValue0{_type: cFWP_UINT8, value: uintptr(cIPPROTO_TCP)},
+			},
+			{
+				fieldKey:       cFWPM_CONDITION_IP_PROTOCOL,
+				matchType:      cFWP_MATCH_EQUAL,
+				conditionValue: wtFwpConditionValue0{_type: cFWP_UINT8, value: uintptr(cIPPROTO_UDP)},
+			},
+		}
+		remotePorts := append([]wtFwpmFilterCondition0{}, protocols...)
+		localPorts := append([]wtFwpmFilterCondition0{}, protocols...)
+		for _, port := range bypass.Ports {
+			remotePorts = append(remotePorts, wtFwpmFilterCondition0{
+				fieldKey:       cFWPM_CONDITION_IP_REMOTE_PORT,
+				matchType:      cFWP_MATCH_EQUAL,
+				conditionValue: wtFwpConditionValue0{_type: cFWP_UINT16, value: uintptr(port)},
+			})
+			localPorts = append(localPorts, wtFwpmFilterCondition0{
+				fieldKey:       cFWPM_CONDITION_IP_LOCAL_PORT,
+				matchType:      cFWP_MATCH_EQUAL,
+				conditionValue: wtFwpConditionValue0{_type: cFWP_UINT16, value: uintptr(port)},
+			})
+		}
+
+		filters := []struct {
+			layer      windows.GUID
+			conditions []wtFwpmFilterCondition0
+			msg        string
+		}{
+			{cFWPM_LAYER_ALE_AUTH_CONNECT_V4, remotePorts, "Permit outbound to bypassed ports (IPv4)"},
+			{cFWPM_LAYER_ALE_AUTH_RECV_ACCEPT_V4, localPorts, "Permit inbound on bypassed ports (IPv4)"},
+			{cFWPM_LAYER_ALE_AUTH_CONNECT_V6, remotePorts, "Permit outbound to bypassed ports (IPv6)"},
+			{cFWPM_LAYER_ALE_AUTH_RECV_ACCEPT_V6, localPorts, "Permit inbound on bypassed ports (IPv6)"},
+		}
+		for _, f := range filters {
+			filter.numFilterConditions = uint32(len(f.conditions))
+			filter.filterCondition = &f.conditions[0]
+			if err := addFilter(session, &filter, f.layer, f.msg); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(bypass.Apps) > 0 {
+		apps := make([]wtFwpmFilterCondition0, 0, len(bypass.Apps))
+		for _, app := range bypass.Apps {
+			appID, err := getAppID(app)
+			if err != nil {
+				return err
+			}
+			defer fwpmFreeMemory0(unsafe.Pointer(&appID))
+			apps = append(apps, wtFwpmFilterCondition0{
+				fieldKey:  cFWPM_CONDITION_ALE_APP_ID,
+				matchType: cFWP_MATCH_EQUAL,
+				conditionValue: wtFwpConditionValue0{
+					_type: cFWP_BYTE_BLOB_TYPE,
+					value: uintptr(unsafe.Pointer(appID)),
+				},
+			})
+		}
+		filter.numFilterConditions = uint32(len(apps))
+		filter.filterCondition = &apps[0]
+
+		filters := []struct {
+			layer windows.GUID
+			msg   string
+		}{
+			{cFWPM_LAYER_ALE_AUTH_CONNECT_V4, "Permit outbound of bypassed programs (IPv4)"},
+			{cFWPM_LAYER_ALE_AUTH_RECV_ACCEPT_V4, "Permit inbound of bypassed programs (IPv4)"},
+			{cFWPM_LAYER_ALE_AUTH_CONNECT_V6, "Permit outbound of bypassed programs (IPv6)"},
+			{cFWPM_LAYER_ALE_AUTH_RECV_ACCEPT_V6, "Permit inbound of bypassed programs (IPv6)"},
+		}
+		for _, f := range filters {
+			if err := addFilter(session, &filter, f.layer, f.msg); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 // Block all traffic except what is explicitly permitted by other rules.
 func blockAll(session uintptr, baseObjects *baseObjects, weight uint8) error {
 	filter := wtFwpmFilter0{
@@ -34,3 +34,27 @@ package firewall
 
 // https://docs.microsoft.com/en-us/windows/desktop/api/fwpmu/nf-fwpmu-fwpmprovideradd0
 //sys	fwpmProviderAdd0(engineHandle uintptr, provider *wtFwpmProvider0, sd uintptr) (err error) [failretval!=0] = fwpuclnt.FwpmProviderAdd0
+
+// https://docs.microsoft.com/en-us/windows/desktop/api/fwpmu/nf-fwpmu-fwpmproviderdeletebykey0
+//sys	fwpmProviderDeleteByKey0(engineHandle uintptr, key *windows.GUID) (ret error) = fwpuclnt.FwpmProviderDeleteByKey0
+
+// https://docs.microsoft.com/en-us/windows/desktop/api/fwpmu/nf-fwpmu-fwpmsublayerdeletebykey0
+//sys	fwpmSubLayerDeleteByKey0(engineHandle uintptr, key *windows.GUID) (ret error) = fwpuclnt.FwpmSubLayerDeleteByKey0
+
+// https://docs.microsoft.com/en-us/windows/desktop/api/fwpmu/nf-fwpmu-fwpmsublayergetbykey0
+//sys	fwpmSubLayerGetByKey0(engineHandle uintptr, key *windows.GUID, subLayer **wtFwpmSublayer0) (ret error) = fwpuclnt.FwpmSubLayerGetByKey0
+
+// https://docs.microsoft.com/en-us/windows/desktop/api/fwpmu/nf-fwpmu-fwpmfiltercreateenumhandle0
+//sys	fwpmFilterCreateEnumHandle0(engineHandle uintptr, enumTemplate uintptr, enumHandle *uintptr) (ret error) = fwpuclnt.FwpmFilterCreateEnumHandle0
+
+// https://docs.microsoft.com/en-us/windows/desktop/api/fwpmu/nf-fwpmu-fwpmfilterenum0
+//sys	fwpmFilterEnum0(engineHandle uintptr, enumHandle uintptr, numEntriesRequested uint32, entries ***wtFwpmFilter0, numEntriesReturned *uint32) (ret error) = fwpuclnt.FwpmFilterEnum0
+
+// https://docs.microsoft.com/en-us/windows/desktop/api/fwpmu/nf-fwpmu-fwpmfilterdestroyenumhandle0
+//sys	fwpmFilterDestroyEnumHandle0(engineHandle uintptr, enumHandle uintptr) (ret error) = fwpuclnt.FwpmFilterDestroyEnumHandle0
+
+// https://docs.microsoft.com/en-us/windows/desktop/api/fwpmu/nf-fwpmu-fwpmfilterdeletebykey0
+//sys	fwpmFilterDeleteByKey0(engineHandle uintptr, key *windows.GUID) (ret error) = fwpuclnt.FwpmFilterDeleteByKey0
+
+// https://docs.microsoft.com/en-us/windows/desktop/api/fwpmu/nf-fwpmu-fwpmprovidergetbykey0
+//sys	fwpmProviderGetByKey0(engineHandle uintptr, key *windows.GUID, provider **wtFwpmProvider0) (ret error) = fwpuclnt.FwpmProviderGetByKey0
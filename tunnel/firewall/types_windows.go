@@ -343,6 +343,13 @@ type wtFwpProvider0 struct {
 	serviceName  *uint16 // Windows type: *wchar_t
 }
 
+// Defined in fwpmu.h
+const (
+	cFWP_E_PROVIDER_NOT_FOUND windows.Errno = 0x80320005
+	cFWP_E_SUBLAYER_NOT_FOUND windows.Errno = 0x80320007
+	cFWP_E_FILTER_NOT_FOUND   windows.Errno = 0x80320003
+)
+
 type wtFwpmSessionFlagsValue uint32
 
 const (
@@ -40,16 +40,24 @@ func errnoErr(e syscall.Errno) error {
 var (
 	modfwpuclnt = windows.NewLazySystemDLL("fwpuclnt.dll")
 
-	procFwpmEngineClose0          = modfwpuclnt.NewProc("FwpmEngineClose0")
-	procFwpmEngineOpen0           = modfwpuclnt.NewProc("FwpmEngineOpen0")
-	procFwpmFilterAdd0            = modfwpuclnt.NewProc("FwpmFilterAdd0")
-	procFwpmFreeMemory0           = modfwpuclnt.NewProc("FwpmFreeMemory0")
-	procFwpmGetAppIdFromFileName0 = modfwpuclnt.NewProc("FwpmGetAppIdFromFileName0")
-	procFwpmProviderAdd0          = modfwpuclnt.NewProc("FwpmProviderAdd0")
-	procFwpmSubLayerAdd0          = modfwpuclnt.NewProc("FwpmSubLayerAdd0")
-	procFwpmTransactionAbort0     = modfwpuclnt.NewProc("FwpmTransactionAbort0")
-	procFwpmTransactionBegin0     = modfwpuclnt.NewProc("FwpmTransactionBegin0")
-	procFwpmTransactionCommit0    = modfwpuclnt.NewProc("FwpmTransactionCommit0")
+	procFwpmEngineClose0             = modfwpuclnt.NewProc("FwpmEngineClose0")
+	procFwpmEngineOpen0              = modfwpuclnt.NewProc("FwpmEngineOpen0")
+	procFwpmFilterAdd0               = modfwpuclnt.NewProc("FwpmFilterAdd0")
+	procFwpmFilterCreateEnumHandle0  = modfwpuclnt.NewProc("FwpmFilterCreateEnumHandle0")
+	procFwpmFilterDeleteByKey0       = modfwpuclnt.NewProc("FwpmFilterDeleteByKey0")
+	procFwpmFilterDestroyEnumHandle0 = modfwpuclnt.NewProc("FwpmFilterDestroyEnumHandle0")
+	procFwpmFilterEnum0              = modfwpuclnt.NewProc("FwpmFilterEnum0")
+	procFwpmFreeMemory0              = modfwpuclnt.NewProc("FwpmFreeMemory0")
+	procFwpmGetAppIdFromFileName0    = modfwpuclnt.NewProc("FwpmGetAppIdFromFileName0")
+	procFwpmProviderAdd0             = modfwpuclnt.NewProc("FwpmProviderAdd0")
+	procFwpmProviderDeleteByKey0     = modfwpuclnt.NewProc("FwpmProviderDeleteByKey0")
+	procFwpmProviderGetByKey0        = modfwpuclnt.NewProc("FwpmProviderGetByKey0")
+	procFwpmSubLayerAdd0             = modfwpuclnt.NewProc("FwpmSubLayerAdd0")
+	procFwpmSubLayerDeleteByKey0     = modfwpuclnt.NewProc("FwpmSubLayerDeleteByKey0")
+	procFwpmSubLayerGetByKey0        = modfwpuclnt.NewProc("FwpmSubLayerGetByKey0")
+	procFwpmTransactionAbort0        = modfwpuclnt.NewProc("FwpmTransactionAbort0")
+	procFwpmTransactionBegin0        = modfwpuclnt.NewProc("FwpmTransactionBegin0")
+	procFwpmTransactionCommit0       = modfwpuclnt.NewProc("FwpmTransactionCommit0")
 )
 
 func fwpmEngineClose0(engineHandle uintptr) (err error) {
@@ -76,6 +84,38 @@ func fwpmFilterAdd0(engineHandle uintptr, filter *wtFwpmFilter0, sd uintptr, id
 	return
 }
 
+func fwpmFilterCreateEnumHandle0(engineHandle uintptr, enumTemplate uintptr, enumHandle *uintptr) (ret error) {
+	r0, _, _ := syscall.Syscall(procFwpmFilterCreateEnumHandle0.Addr(), 3, uintptr(engineHandle), uintptr(enumTemplate), uintptr(unsafe.Pointer(enumHandle)))
+	if r0 != 0 {
+		ret = syscall.Errno(r0)
+	}
+	return
+}
+
+func fwpmFilterDeleteByKey0(engineHandle uintptr, key *windows.GUID) (ret error) {
+	r0, _, _ := syscall.Syscall(procFwpmFilterDeleteByKey0.Addr(), 2, uintptr(engineHandle), uintptr(unsafe.Pointer(key)), 0)
+	if r0 != 0 {
+		ret = syscall.Errno(r0)
+	}
+	return
+}
+
+func fwpmFilterDestroyEnumHandle0(engineHandle uintptr, enumHandle uintptr) (ret error) {
+	r0, _, _ := syscall.Syscall(procFwpmFilterDestroyEnumHandle0.Addr(), 2, uintptr(engineHandle), uintptr(enumHandle), 0)
+	if r0 != 0 {
+		ret = syscall.Errno(r0)
+	}
+	return
+}
+
+func fwpmFilterEnum0(engineHandle uintptr, enumHandle uintptr, numEntriesRequested uint32, entries ***wtFwpmFilter0, numEntriesReturned *uint32) (ret error) {
+	r0, _, _ := syscall.Syscall6(procFwpmFilterEnum0.Addr(), 5, uintptr(engineHandle), uintptr(enumHandle), uintptr(numEntriesRequested), uintptr(unsafe.Pointer(entries)), uintptr(unsafe.Pointer(numEntriesReturned)), 0)
+	if r0 != 0 {
+		ret = syscall.Errno(r0)
+	}
+	return
+}
+
 func fwpmFreeMemory0(p unsafe.Pointer) {
 	syscall.Syscall(procFwpmFreeMemory0.Addr(), 1, uintptr(p), 0, 0)
 	return
@@ -97,6 +137,22 @@ func fwpmProviderAdd0(engineHandle uintptr, provider *wtFwpmProvider0, sd uintpt
 	return
 }
 
+func fwpmProviderDeleteByKey0(engineHandle uintptr, key *windows.GUID) (ret error) {
+	r0, _, _ := syscall.Syscall(procFwpmProviderDeleteByKey0.Addr(), 2, uintptr(engineHandle), uintptr(unsafe.Pointer(key)), 0)
+	if r0 != 0 {
+		ret = syscall.Errno(r0)
+	}
+	return
+}
+
+func fwpmProviderGetByKey0(engineHandle uintptr, key *windows.GUID, provider **wtFwpmProvider0) (ret error) {
+	r0, _, _ := syscall.Syscall(procFwpmProviderGetByKey0.Addr(), 3, uintptr(engineHandle), uintptr(unsafe.Pointer(key)), uintptr(unsafe.Pointer(provider)))
+	if r0 != 0 {
+		ret = syscall.Errno(r0)
+	}
+	return
+}
+
 func fwpmSubLayerAdd0(engineHandle uintptr, subLayer *wtFwpmSublayer0, sd uintptr) (err error) {
 	r1, _, e1 := syscall.Syscall(procFwpmSubLayerAdd0.Addr(), 3, uintptr(engineHandle), uintptr(unsafe.Pointer(subLayer)), uintptr(sd))
 	if r1 != 0 {
@@ -105,6 +161,22 @@ func fwpmSubLayerAdd0(engineHandle uintptr, subLayer *wtFwpmSublayer0, sd uintpt
 	return
 }
 
+func fwpmSubLayerDeleteByKey0(engineHandle uintptr, key *windows.GUID) (ret error) {
+	r0, _, _ := syscall.Syscall(procFwpmSubLayerDeleteByKey0.Addr(), 2, uintptr(engineHandle), uintptr(unsafe.Pointer(key)), 0)
+	if r0 != 0 {
+		ret = syscall.Errno(r0)
+	}
+	return
+}
+
+func fwpmSubLayerGetByKey0(engineHandle uintptr, key *windows.GUID, subLayer **wtFwpmSublayer0) (ret error) {
+	r0, _, _ := syscall.Syscall(procFwpmSubLayerGetByKey0.Addr(), 3, uintptr(engineHandle), uintptr(unsafe.Pointer(key)), uintptr(unsafe.Pointer(subLayer)))
+	if r0 != 0 {
+		ret = syscall.Errno(r0)
+	}
+	return
+}
+
 func fwpmTransactionAbort0(engineHandle uintptr) (err error) {
 	r1, _, e1 := syscall.Syscall(procFwpmTransactionAbort0.Addr(), 1, uintptr(engineHandle), 0, 0)
 	if r1 != 0 {
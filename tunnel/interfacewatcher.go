@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/netip"
 	"sync"
 	"time"
 
@@ -31,8 +32,9 @@ type interfaceWatcherEvent struct {
 }
 
 type interfaceWatcher struct {
-	errors  chan interfaceWatcherError
-	started chan winipcfg.AddressFamily
+	errors          chan interfaceWatcherError
+	started         chan winipcfg.AddressFamily
+	endpointChanged chan struct{}
 
 	conf    *conf.Config
 	adapter *driver.Adapter
@@ -69,7 +71,7 @@ func (iw *interfaceWatcher) setup(family winipcfg.AddressFamily) {
 
 	if iw.conf.Interface.MTU == 0 {
 		log.Printf("Monitoring MTU of default %s routes", ipversion)
-		*changeCallbacks, err = monitorMTU(family, iw.luid)
+		*changeCallbacks, err = monitorMTU(family, iw.luid, endpointAddrs(family, iw.conf))
 		if err != nil {
 			iw.errors <- interfaceWatcherError{services.ErrorMonitorMTUChanges, err}
 			return
@@ -89,8 +91,9 @@ func (iw *interfaceWatcher) setup(family winipcfg.AddressFamily) {
 
 func watchInterface() (*interfaceWatcher, error) {
 	iw := &interfaceWatcher{
-		errors:  make(chan interfaceWatcherError, 2),
-		started: make(chan winipcfg.AddressFamily, 4),
+		errors:          make(chan interfaceWatcherError, 2),
+		started:         make(chan winipcfg.AddressFamily, 4),
+		endpointChanged: make(chan struct{}, 1),
 	}
 	iw.watchdog = time.AfterFunc(time.Duration(1<<63-1), func() {
 		iw.errors <- interfaceWatcherError{services.ErrorCreateNetworkAdapter, errors.New("TCP/IP interface for adapter did not appear after one minute")}
@@ -145,6 +148,78 @@ func (iw *interfaceWatcher) Configure(adapter *driver.Adapter, conf *conf.Config
 	iw.storedEvents = nil
 }
 
+// SetEndpointHost sets the endpoint of a peer that was configured without one because its host
+// name could not be resolved, once it has been, or changes the address that its host name was
+// resolved to.
+func (iw *interfaceWatcher) SetEndpointHost(peer int, host string) error {
+	iw.setupMutex.Lock()
+	defer iw.setupMutex.Unlock()
+	iw.conf.Peers[peer].Endpoint.Host = host
+	select {
+	case iw.endpointChanged <- struct{}{}:
+	default:
+	}
+	return iw.adapter.SetConfiguration(iw.conf.ToDriverSyncConfiguration(iw.conf))
+}
+
+// ReplacePeers replaces the peers of the tunnel from the index first on, which are those of its
+// peer source, bringing the adapter and the routes in line with them. The peers before first are
+// left alone, so that their indices stay valid for SetEndpointHost.
+func (iw *interfaceWatcher) ReplacePeers(first int, peers []conf.Peer) error {
+	iw.setupMutex.Lock()
+	defer iw.setupMutex.Unlock()
+	running := *iw.conf
+	iw.conf.Peers = append(iw.conf.Peers[:first:first], peers...)
+	err := iw.adapter.SetConfiguration(iw.conf.ToDriverSyncConfiguration(&running))
+	if err != nil {
+		return err
+	}
+	select {
+	case iw.endpointChanged <- struct{}{}:
+	default:
+	}
+	if iw.conf.Interface.TableOff {
+		return nil
+	}
+	// Only the routes that changed are touched, so that traffic to the others is not interrupted.
+	oldRoutes := make(map[winipcfg.RouteData]bool)
+	for _, route := range tunnelRoutes(&running) {
+		oldRoutes[*route] = true
+	}
+	newRoutes := make(map[winipcfg.RouteData]bool)
+	for _, route := range tunnelRoutes(iw.conf) {
+		newRoutes[*route] = true
+	}
+	for route := range oldRoutes {
+		if !newRoutes[route] {
+			if routeErr := iw.luid.DeleteRoute(route.Destination, route.NextHop); routeErr != nil && err == nil {
+				err = fmt.Errorf("unable to remove route to %v: %w", route.Destination, routeErr)
+			}
+		}
+	}
+	for route := range newRoutes {
+		if !oldRoutes[route] {
+			if routeErr := iw.luid.AddRoute(route.Destination, route.NextHop, route.Metric); routeErr != nil && err == nil {
+				err = fmt.Errorf("unable to add route to %v: %w", route.Destination, routeErr)
+			}
+		}
+	}
+	return err
+}
+
+// EndpointAddrs returns the addresses of the endpoints that are currently set.
+func (iw *interfaceWatcher) EndpointAddrs() []netip.Addr {
+	iw.setupMutex.Lock()
+	defer iw.setupMutex.Unlock()
+	var addrs []netip.Addr
+	for i := range iw.conf.Peers {
+		if addr, err := netip.ParseAddr(iw.conf.Peers[i].Endpoint.Host); err == nil {
+			addrs = append(addrs, addr.Unmap())
+		}
+	}
+	return addrs
+}
+
 func (iw *interfaceWatcher) Destroy() {
 	iw.setupMutex.Lock()
 	iw.watchdog.Stop()
@@ -0,0 +1,98 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package tunnel
+
+import (
+	"log"
+	"net/netip"
+
+	"golang.org/x/sys/windows"
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
+)
+
+var (
+	privatePrefixes = []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("172.16.0.0/12"),
+		netip.MustParsePrefix("192.168.0.0/16"),
+		netip.MustParsePrefix("fc00::/7"),
+	}
+
+	// The multicast groups of mDNS and SSDP, with which printers and media players are found. Their
+	// traffic is sent on each interface regardless of routes, so it only needs to be let through.
+	discoveryPrefixes = []netip.Prefix{
+		netip.MustParsePrefix("224.0.0.251/32"),
+		netip.MustParsePrefix("ff02::fb/128"),
+		netip.MustParsePrefix("239.255.255.250/32"),
+		netip.MustParsePrefix("ff02::c/128"),
+	}
+)
+
+// allowLocalNetwork leaves the private networks that the other interfaces are attached to outside
+// of a tunnel with a default route, by adding them to its excluded addresses, which are routed
+// elsewhere and exempt from the firewall rules. The networks are those of when the tunnel is
+// activated. Networks that the tunnel routes on purpose, with allowed IPs other than the default
+// route, or that overlap its own addresses, are left in the tunnel.
+func allowLocalNetwork(config *conf.Config, luid winipcfg.LUID) {
+	if config.Interface.TableOff {
+		return
+	}
+	var hasDefaultRoute bool
+	var tunneled []netip.Prefix
+	for _, peer := range config.Peers {
+		for _, allowedip := range peer.AllowedIPs {
+			if allowedip.Bits() == 0 {
+				hasDefaultRoute = true
+			} else {
+				tunneled = append(tunneled, allowedip.Masked())
+			}
+		}
+	}
+	if !hasDefaultRoute {
+		return
+	}
+	for _, address := range config.Interface.Addresses {
+		tunneled = append(tunneled, address.Masked())
+	}
+
+	addresses, err := winipcfg.GetUnicastIPAddressTable(windows.AF_UNSPEC)
+	if err != nil {
+		log.Printf("Unable to list addresses to find local networks: %v", err)
+	}
+	local := make(map[netip.Prefix]bool)
+	for i := range addresses {
+		if addresses[i].InterfaceLUID == luid {
+			continue
+		}
+		addr := addresses[i].Address.Addr().Unmap()
+		if !addr.IsValid() {
+			continue
+		}
+		prefix, err := addr.Prefix(int(addresses[i].OnLinkPrefixLength))
+		if err != nil || local[prefix] {
+			continue
+		}
+		for _, private := range privatePrefixes {
+			if private.Contains(addr) && private.Bits() <= prefix.Bits() {
+				local[prefix] = true
+				break
+			}
+		}
+	}
+
+next:
+	for prefix := range local {
+		for _, other := range tunneled {
+			if prefix.Overlaps(other) {
+				log.Printf("Not allowing local network %s, which the tunnel uses itself", prefix)
+				continue next
+			}
+		}
+		log.Printf("Allowing local network %s", prefix)
+		config.Interface.ExcludeIPs = append(config.Interface.ExcludeIPs, prefix)
+	}
+}
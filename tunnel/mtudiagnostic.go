@@ -0,0 +1,166 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package tunnel
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
+)
+
+// When the MTU of the tunnel is larger than what fits through the path to a peer, the
+// encapsulated packets that are too big are fragmented or dropped along the way, which shows up as
+// failures to fragment or reassemble, and, for IPv6, as Packet Too Big messages. If these pile up
+// relative to the traffic of the tunnel, the tunnel suggests, or with the AutoAdjustMTU policy
+// applies, the next of the values that are commonly used instead. Windows only counts them for
+// the whole system, so they are only weighed against the tunnel's own packets. An MTU that is set
+// in the configuration is never overridden, only suggested against.
+
+const (
+	mtuDiagnosticInterval = 30 * time.Second
+	mtuDropMinimum        = 50
+	mtuDropPercent        = 1
+
+	icmp6PacketTooBig = 2
+)
+
+var saferMTUs = []uint32{1420, 1380, 1280}
+
+// loweredMTU is the MTU that diagnoseMTU lowered the adapter to, which monitorMTU does not go above
+// when it recalculates the MTU for a new default route.
+var loweredMTU uint32
+
+type dropCounters struct {
+	packets uint64
+	dropped uint32 // Wraps around like the system counters it is the sum of.
+}
+
+func readDropCounters(luid winipcfg.LUID) (dropCounters, error) {
+	row, err := luid.Interface()
+	if err != nil {
+		return dropCounters{}, err
+	}
+	counters := dropCounters{packets: row.InUcastPkts + row.InNUcastPkts + row.OutUcastPkts + row.OutNUcastPkts}
+	for _, family := range []winipcfg.AddressFamily{windows.AF_INET, windows.AF_INET6} {
+		ip, err := winipcfg.GetIPStatistics(family)
+		if err != nil {
+			return dropCounters{}, err
+		}
+		counters.dropped += ip.FragFails + ip.ReasmFails
+	}
+	icmp, err := winipcfg.GetICMPStatistics(windows.AF_INET6)
+	if err != nil {
+		return dropCounters{}, err
+	}
+	counters.dropped += icmp.In.TypeCount[icmp6PacketTooBig]
+	return counters, nil
+}
+
+// excessiveSince reports whether enough packets were dropped since last to blame the MTU.
+func (counters dropCounters) excessiveSince(last dropCounters) bool {
+	dropped := uint64(counters.dropped - last.dropped)
+	packets := counters.packets - last.packets
+	return dropped >= mtuDropMinimum && dropped*100 >= (packets+dropped)*mtuDropPercent
+}
+
+// saferMTU returns the next common MTU below current, or 0 if there is none.
+func saferMTU(current uint32) uint32 {
+	for _, mtu := range saferMTUs {
+		if mtu < current {
+			return mtu
+		}
+	}
+	return 0
+}
+
+func currentMTU(luid winipcfg.LUID) uint32 {
+	for _, family := range []winipcfg.AddressFamily{windows.AF_INET, windows.AF_INET6} {
+		if iface, err := luid.IPInterface(family); err == nil {
+			return iface.NLMTU
+		}
+	}
+	return 0
+}
+
+func setMTU(luid winipcfg.LUID, mtu uint32) error {
+	var lastErr error
+	set := false
+	for _, family := range []winipcfg.AddressFamily{windows.AF_INET, windows.AF_INET6} {
+		iface, err := luid.IPInterface(family)
+		if err != nil {
+			continue
+		}
+		iface.NLMTU = mtu
+		err = iface.Set()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		set = true
+	}
+	if !set {
+		return lastErr
+	}
+	return nil
+}
+
+// diagnoseMTU watches the drop counters of the adapter until ctx is done. With an explicit MTU in
+// config, it only ever suggests a lower one.
+func diagnoseMTU(ctx context.Context, config *conf.Config, luid winipcfg.LUID) {
+	last, err := readDropCounters(luid)
+	if err != nil {
+		log.Printf("Unable to read drop counters, not diagnosing MTU: %v", err)
+		return
+	}
+	var lowered conf.Pitfall
+	ticker := time.NewTicker(mtuDiagnosticInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		counters, err := readDropCounters(luid)
+		if err != nil {
+			continue
+		}
+		excessive := counters.excessiveSince(last)
+		dropped := counters.dropped - last.dropped
+		last = counters
+		if !excessive {
+			continue
+		}
+		mtu := currentMTU(luid)
+		safer := saferMTU(mtu)
+		if safer == 0 {
+			continue
+		}
+		if config.Interface.MTU != 0 || !conf.AdminBool("AutoAdjustMTU") {
+			recordPitfalls(config.Name, []conf.Pitfall{{Kind: conf.PitfallMTUTooLarge, Detail: strconv.FormatUint(uint64(safer), 10)}})
+			continue
+		}
+		atomic.StoreUint32(&loweredMTU, safer)
+		err = setMTU(luid, safer)
+		if err != nil {
+			log.Printf("Unable to lower MTU to %d: %v", safer, err)
+			continue
+		}
+		log.Printf("Lowered MTU from %d to %d after %d packets were too big for the path", mtu, safer, dropped)
+		if lowered.Kind != 0 {
+			forgetPitfall(config.Name, lowered)
+		}
+		lowered = conf.Pitfall{Kind: conf.PitfallMTULowered, Detail: strconv.FormatUint(uint64(safer), 10)}
+		recordPitfalls(config.Name, []conf.Pitfall{lowered})
+	}
+}
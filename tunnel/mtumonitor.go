@@ -7,7 +7,9 @@
 
  import (
 	 "log"
+	 "net/netip"
 	 "sync"
+	 "sync/atomic"
 	 "time"
  
 	 "golang.org/x/sys/windows"
@@ -71,11 +73,19 @@
 	 lastMTU    uint32
 	 lastUpdate time.Time
 	 minMTU     uint32
+
+	 // Der zuletzt ermittelte Pfad-MTU zu den Endpunkten, und wofür er ermittelt wurde.
+	 pathMTU     uint32
+	 lastPathMTU uint32
+	 probedLUID  winipcfg.LUID
+	 probedMTU   uint32
+	 probing     bool
  }
  
  // monitorMTU überwacht Änderungen der MTU und passt das Tunnel-Interface entsprechend an.
  // Es registriert Callback-Funktionen, die bei Änderungen der Routingtabelle oder Interface-Parameter aufgerufen werden.
- func monitorMTU(family winipcfg.AddressFamily, ourLUID winipcfg.LUID) ([]winipcfg.ChangeCallback, error) {
+ // Zusätzlich wird der Pfad-MTU zu den Endpunkten gemessen, sobald sich das Standard-Interface oder seine MTU ändert.
+ func monitorMTU(family winipcfg.AddressFamily, ourLUID winipcfg.LUID, endpoints []netip.Addr) ([]winipcfg.ChangeCallback, error) {
 	 state := &mtuState{
 		 lastIndex: ^uint32(0),
 	 }
@@ -88,7 +98,8 @@
 	 }
  
 	 // updateMTU führt die Aktualisierung der MTU durch, wenn nötig.
-	 updateMTU := func() error {
+	 var updateMTU func() error
+	 updateMTU = func() error {
 		 state.mutex.Lock()
 		 defer state.mutex.Unlock()
  
@@ -114,17 +125,45 @@
 			 }
 		 }
  
-		 // Nur wenn sich die MTU des Standard-Interfaces geändert hat, wird der Tunnel angepasst.
-		 if mtu > 0 && state.lastMTU != mtu {
+		 // Messung im Hintergrund starten, da sie mehrere Sekunden dauern kann.
+		 if mtu > 0 && len(endpoints) > 0 && !state.probing && (state.probedLUID != state.lastLUID || state.probedMTU != mtu) {
+			 state.probing = true
+			 state.probedLUID = state.lastLUID
+			 state.probedMTU = mtu
+			 go func(luid winipcfg.LUID, ceiling uint32) {
+				 pathMTU := probeEndpointsMTU(family, luid, endpoints, ceiling)
+				 state.mutex.Lock()
+				 state.probing = false
+				 state.pathMTU = pathMTU
+				 state.lastUpdate = time.Time{}
+				 state.mutex.Unlock()
+				 if pathMTU != 0 && pathMTU < ceiling {
+					 log.Printf("Path MTU to endpoints is %d, below the default interface MTU of %d", pathMTU, ceiling)
+				 }
+				 if err := updateMTU(); err != nil {
+					 log.Printf("Error applying path MTU: %v", err)
+				 }
+			 }(state.lastLUID, mtu)
+		 }
+
+		 // Nur wenn sich die MTU des Standard-Interfaces oder der Pfad-MTU geändert hat, wird der Tunnel angepasst.
+		 if mtu > 0 && (state.lastMTU != mtu || state.lastPathMTU != state.pathMTU) {
 			 iface, err := ourLUID.IPInterface(family)
 			 if err != nil {
 				 return err
 			 }
  
 			 newMTU := mtu - 80
+			 if state.pathMTU != 0 && state.pathMTU < mtu {
+				 newMTU = state.pathMTU - 80
+			 }
 			 if newMTU < state.minMTU {
 				 newMTU = state.minMTU
 			 }
+			 // Eine von diagnoseMTU wegen verworfener Pakete gesenkte MTU wird nicht wieder angehoben.
+			 if lowered := atomic.LoadUint32(&loweredMTU); lowered != 0 && newMTU > lowered {
+				 newMTU = lowered
+			 }
  
 			 // Änderung vornehmen, wenn der neue MTU-Wert sich unterscheidet.
 			 if iface.NLMTU != newMTU {
@@ -135,6 +174,7 @@
 			 }
  
 			 state.lastMTU = mtu
+			 state.lastPathMTU = state.pathMTU
 		 }
 		 return nil
 	 }
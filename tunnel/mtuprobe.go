@@ -0,0 +1,131 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package tunnel
+
+import (
+	"net/netip"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
+)
+
+// The MTU of the default interface says nothing about the links further along the path to a
+// peer, so the path MTU to each endpoint is also probed, by binary search with echo requests that
+// may not be fragmented. Peers do not answer UDP that is not WireGuard, so echo requests are the
+// only probes whose arrival can be confirmed. They are sent from an address of the default
+// interface, so that they do not take the tunnel's own routes. Endpoints that do not answer at
+// all are left out.
+
+const (
+	mtuProbeTimeout = time.Second
+
+	ipv4EchoHeaderSize = 20 + 8
+	ipv6EchoHeaderSize = 40 + 8
+)
+
+var mtuProbeMinimumSizes = map[winipcfg.AddressFamily]uint32{windows.AF_INET: 576, windows.AF_INET6: 1280}
+
+// echoSize reports whether an unfragmented packet of size bytes, headers included, gets from the
+// source of icmp to its destination and is answered.
+func echoSize(icmp *winipcfg.ICMPHandle, destination netip.Addr, size uint32) bool {
+	headerSize := uint32(ipv4EchoHeaderSize)
+	if destination.Is6() {
+		headerSize = ipv6EchoHeaderSize
+	}
+	if size <= headerSize {
+		return false
+	}
+	return icmp.Echo(int(size-headerSize), true, mtuProbeTimeout) == nil
+}
+
+// probePathMTU returns the largest packet size up to ceiling that gets from source to destination
+// unfragmented, or 0 if the destination does not answer even the smallest.
+func probePathMTU(source, destination netip.Addr, ceiling uint32) uint32 {
+	family := winipcfg.AddressFamily(windows.AF_INET)
+	if destination.Is6() {
+		family = windows.AF_INET6
+	}
+	low := mtuProbeMinimumSizes[family]
+	if ceiling <= low {
+		return 0
+	}
+	icmp, err := winipcfg.OpenICMP(source, destination)
+	if err != nil {
+		return 0
+	}
+	defer icmp.Close()
+	if !echoSize(icmp, destination, low) {
+		return 0
+	}
+	if echoSize(icmp, destination, ceiling) {
+		return ceiling
+	}
+	high := ceiling
+	for high-low > 1 {
+		middle := low + (high-low)/2
+		if echoSize(icmp, destination, middle) {
+			low = middle
+		} else {
+			high = middle
+		}
+	}
+	return low
+}
+
+// sourceAddress returns an address of the interface to send probes from, or an invalid one if it
+// has none that is usable.
+func sourceAddress(family winipcfg.AddressFamily, luid winipcfg.LUID) netip.Addr {
+	rows, err := winipcfg.GetUnicastIPAddressTable(family)
+	if err != nil {
+		return netip.Addr{}
+	}
+	for i := range rows {
+		if rows[i].InterfaceLUID != luid {
+			continue
+		}
+		addr := rows[i].Address.Addr()
+		if addr.IsValid() && !addr.IsLinkLocalUnicast() && !addr.IsLoopback() {
+			return addr
+		}
+	}
+	return netip.Addr{}
+}
+
+// probeEndpointsMTU returns the smallest path MTU to the endpoints, sent from the interface with
+// the given LUID, or 0 if none of them answered.
+func probeEndpointsMTU(family winipcfg.AddressFamily, luid winipcfg.LUID, endpoints []netip.Addr, ceiling uint32) uint32 {
+	source := sourceAddress(family, luid)
+	if !source.IsValid() {
+		return 0
+	}
+	var smallest uint32
+	for _, endpoint := range endpoints {
+		mtu := probePathMTU(source, endpoint, ceiling)
+		if mtu != 0 && (smallest == 0 || mtu < smallest) {
+			smallest = mtu
+		}
+	}
+	return smallest
+}
+
+// endpointAddrs returns the resolved endpoints of the configuration that belong to family.
+func endpointAddrs(family winipcfg.AddressFamily, config *conf.Config) []netip.Addr {
+	var addrs []netip.Addr
+	for _, peer := range config.Peers {
+		addr, err := netip.ParseAddr(peer.Endpoint.Host)
+		if err != nil {
+			continue
+		}
+		addr = addr.Unmap()
+		if (addr.Is4() && family == windows.AF_INET) || (addr.Is6() && family == windows.AF_INET6) {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
@@ -0,0 +1,106 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package tunnel
+
+import (
+	"strings"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+// Tunnels that do not route everything, but have DNS search domains, get split DNS through the
+// Name Resolution Policy Table: queries for names in those domains go to the DNS servers of the
+// tunnel, and all others to the DNS servers of the system, as if the tunnel were not there.
+
+const nrptKeyPath = `SYSTEM\CurrentControlSet\Services\Dnscache\Parameters\DnsPolicyConfig`
+
+const (
+	nrptRuleVersion             = 2
+	nrptConfigOptionsDNSServers = 0x8
+)
+
+var procDnsFlushResolverCache = windows.NewLazySystemDLL("dnsapi.dll").NewProc("DnsFlushResolverCache")
+
+// usesSplitDNS reports whether the DNS servers of the tunnel are only to be used for its search
+// domains, which is the case when it has both, and no default route.
+func usesSplitDNS(config *conf.Config) bool {
+	if len(config.Interface.DNS) == 0 || len(config.Interface.DNSSearch) == 0 {
+		return false
+	}
+	for _, peer := range config.Peers {
+		for _, allowedip := range peer.AllowedIPs {
+			if allowedip.Bits() == 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// nrptRulePath is the key of the rule of a tunnel. It is named after the tunnel, so that a rule
+// left behind by a crashed tunnel service is replaced when the tunnel starts again.
+func nrptRulePath(tunnelName string) string {
+	return nrptKeyPath + `\WireGuard-` + tunnelName
+}
+
+func installNRPTRule(config *conf.Config) error {
+	namespaces := make([]string, 0, len(config.Interface.DNSSearch))
+	for _, domain := range config.Interface.DNSSearch {
+		namespaces = append(namespaces, "."+strings.Trim(domain, "."))
+	}
+	servers := make([]string, 0, len(config.Interface.DNS))
+	for _, server := range config.Interface.DNS {
+		servers = append(servers, server.String())
+	}
+	key, _, err := registry.CreateKey(registry.LOCAL_MACHINE, nrptRulePath(config.Name), registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	err = key.SetDWordValue("Version", nrptRuleVersion)
+	if err != nil {
+		return err
+	}
+	err = key.SetStringsValue("Name", namespaces)
+	if err != nil {
+		return err
+	}
+	err = key.SetStringValue("GenericDNSServers", strings.Join(servers, ";"))
+	if err != nil {
+		return err
+	}
+	err = key.SetDWordValue("ConfigOptions", nrptConfigOptionsDNSServers)
+	if err != nil {
+		return err
+	}
+	err = key.SetStringValue("IPSECCARestriction", "")
+	if err != nil {
+		return err
+	}
+	flushResolverCache()
+	return nil
+}
+
+func removeNRPTRule(tunnelName string) error {
+	err := registry.DeleteKey(registry.LOCAL_MACHINE, nrptRulePath(tunnelName))
+	if err == windows.ERROR_FILE_NOT_FOUND {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	flushResolverCache()
+	return nil
+}
+
+// flushResolverCache makes the DNS client forget answers that it got before the rules changed.
+func flushResolverCache() {
+	if procDnsFlushResolverCache.Find() == nil {
+		procDnsFlushResolverCache.Call()
+	}
+}
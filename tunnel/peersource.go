@@ -0,0 +1,151 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+// A tunnel with a PeerSource fetches the signed document of its peers when it starts and every
+// few minutes after, and adds and removes peers to match it, next to those of its configuration,
+// so that the spokes of a fleet learn about each other without new configurations being pushed to
+// them. A document that fails to download or verify leaves the peers as they are, and so does one
+// older than the last one applied, even before the tunnel restarted.
+
+const (
+	peerSourceInterval      = 5 * time.Minute
+	peerSourceRetryInterval = time.Minute
+	peerSourceTimeout       = 30 * time.Second
+)
+
+func followPeerSource(ctx context.Context, watcher *interfaceWatcher, config *conf.Config) {
+	first := len(config.Peers)
+	configured := make(map[conf.Key]bool, first)
+	for i := range config.Peers {
+		configured[config.Peers[i].PublicKey] = true
+	}
+	current := make(map[conf.Key]bool)
+	source, key := config.Interface.PeerSource, config.Interface.PeerSourceKey
+	state, err := conf.LoadPeerSourceState(config.Name)
+	if err != nil {
+		log.Printf("Unable to load serial of last peer source document: %v", err)
+	}
+	applied := false
+	log.Printf("Following peer source %s", source)
+	for {
+		interval := peerSourceInterval
+		document, err := fetchPeerSource(ctx, source, key)
+		if err != nil {
+			log.Printf("Unable to fetch peers from peer source: %v", err)
+			interval = peerSourceRetryInterval
+		} else if !state.Accepts(source, key, document.Serial) {
+			log.Printf("Ignoring peer source document with serial %d, older than %d", document.Serial, state.Serial)
+		} else if !applied || document.Serial > state.Serial {
+			err = applyPeerSource(watcher, first, configured, current, document)
+			if err != nil {
+				log.Printf("Unable to apply peers from peer source: %v", err)
+				interval = peerSourceRetryInterval
+			} else {
+				applied = true
+				if next := (conf.PeerSourceState{Source: source, Key: key, Serial: document.Serial}); next != state {
+					state = next
+					if err := conf.SavePeerSourceState(config.Name, state); err != nil {
+						log.Printf("Unable to save serial of peer source document: %v", err)
+					}
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// applyPeerSource replaces the peers that came from the peer source with those of document, and
+// records them in current.
+func applyPeerSource(watcher *interfaceWatcher, first int, configured, current map[conf.Key]bool, document *conf.PeerSourceDocument) error {
+	peers := make([]conf.Peer, 0, len(document.Peers))
+	for i := range document.Peers {
+		if configured[document.Peers[i].PublicKey] {
+			log.Printf("Ignoring peer %s of peer source, which is already configured", document.Peers[i].PublicKey.String())
+			continue
+		}
+		peers = append(peers, document.Peers[i])
+	}
+	resolved := conf.Config{Peers: peers}
+	if err := resolved.ResolveEndpoints(); err != nil {
+		log.Printf("Unable to resolve some endpoints of peer source, adding their peers without them: %v", err)
+	}
+	err := watcher.ReplacePeers(first, resolved.Peers)
+	if err != nil {
+		return err
+	}
+	added, removed := 0, 0
+	wanted := make(map[conf.Key]bool, len(peers))
+	for i := range peers {
+		wanted[peers[i].PublicKey] = true
+		if !current[peers[i].PublicKey] {
+			added++
+		}
+	}
+	for key := range current {
+		if !wanted[key] {
+			removed++
+			delete(current, key)
+		}
+	}
+	for key := range wanted {
+		current[key] = true
+	}
+	log.Printf("Applied %d peers of peer source document with serial %d: %d added, %d removed", len(peers), document.Serial, added, removed)
+	return nil
+}
+
+func fetchPeerSource(ctx context.Context, source, publicKey string) (*conf.PeerSourceDocument, error) {
+	ctx, cancel := context.WithTimeout(ctx, peerSourceTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := http.Client{
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if request.URL.Scheme != "https" {
+				return errors.New("Redirects away from https are not followed")
+			}
+			if len(via) >= 10 {
+				return errors.New("Too many redirects")
+			}
+			return nil
+		},
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Download failed with status %s", response.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, conf.MaxPeerSourceSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > conf.MaxPeerSourceSize {
+		return nil, errors.New("Download is too large")
+	}
+	return conf.ReadPeerSourceDocument(body, publicKey)
+}
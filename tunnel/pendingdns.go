@@ -0,0 +1,92 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package tunnel
+
+import (
+	"context"
+	"log"
+	"net/netip"
+	"time"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/services"
+)
+
+const (
+	pendingDNSMinInterval = 5 * time.Second
+	pendingDNSMaxInterval = 5 * time.Minute
+)
+
+// pendingEndpoint is the endpoint of a peer whose host name could not be resolved when the
+// tunnel started, so that the peer was configured without one.
+type pendingEndpoint struct {
+	peer     int
+	peerName string
+	endpoint conf.Endpoint
+}
+
+// pendingDNSAllowed reports whether a tunnel may start without the endpoints that it could not
+// resolve, rather than fail, which is only the case at boot, when the network may not be up yet,
+// and if the RetryDNSAtBoot policy is set.
+func pendingDNSAllowed() bool {
+	return services.StartedAtBoot() && conf.AdminBool("RetryDNSAtBoot")
+}
+
+func unresolvedEndpoints(config *conf.Config) []pendingEndpoint {
+	var pending []pendingEndpoint
+	for i := range config.Peers {
+		if config.Peers[i].Endpoint.IsEmpty() {
+			continue
+		}
+		if _, err := netip.ParseAddr(config.Peers[i].Endpoint.Host); err != nil {
+			pending = append(pending, pendingEndpoint{i, config.Peers[i].DisplayName(), config.Peers[i].Endpoint})
+		}
+	}
+	return pending
+}
+
+// resolvePendingEndpoints keeps trying to resolve the pending endpoints, backing off up to
+// pendingDNSMaxInterval between attempts, and hands each to the adapter once it resolves.
+func resolvePendingEndpoints(ctx context.Context, watcher *interfaceWatcher, tunnelName string, pending []pendingEndpoint) {
+	for _, p := range pending {
+		recordPitfalls(tunnelName, []conf.Pitfall{{Kind: conf.PitfallEndpointPending, Detail: p.endpoint.Host}})
+	}
+	interval := pendingDNSMinInterval
+	for len(pending) > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		lookup := conf.Config{Peers: make([]conf.Peer, len(pending))}
+		for i := range pending {
+			lookup.Peers[i].Endpoint = pending[i].endpoint
+		}
+		lookup.ResolveEndpoints()
+		remaining := pending[:0]
+		for i := range pending {
+			host := lookup.Peers[i].Endpoint.Host
+			if _, err := netip.ParseAddr(host); err != nil {
+				remaining = append(remaining, pending[i])
+				continue
+			}
+			log.Printf("Resolved pending endpoint %s of peer %s to %s", pending[i].endpoint.Host, pending[i].peerName, host)
+			err := watcher.SetEndpointHost(pending[i].peer, host)
+			if err != nil {
+				log.Printf("Unable to set endpoint of peer %s: %v", pending[i].peerName, err)
+				remaining = append(remaining, pending[i])
+				continue
+			}
+			forgetPitfall(tunnelName, conf.Pitfall{Kind: conf.PitfallEndpointPending, Detail: pending[i].endpoint.Host})
+		}
+		pending = remaining
+		interval *= 2
+		if interval > pendingDNSMaxInterval {
+			interval = pendingDNSMaxInterval
+		}
+	}
+	log.Println("All pending endpoints have been resolved")
+}
@@ -16,6 +16,7 @@
 	 "golang.org/x/sys/windows"
 	 "golang.org/x/sys/windows/svc/mgr"
 	 "golang.zx2c4.com/wireguard/windows/conf"
+	 "golang.zx2c4.com/wireguard/windows/ringlogger"
 	 "golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
  )
  
@@ -24,22 +25,85 @@
 	 dnsCacheDisabled  bool
 	 dnsCacheTime      time.Time
 	 dnsCacheDuration  = 5 * time.Minute
+	 foundPitfalls     []conf.Pitfall
+	 foundPitfallsLock sync.Mutex
  )
  
- func evaluateStaticPitfalls() {
+ func evaluateStaticPitfalls(config *conf.Config) {
+	 // Drop what an earlier run of the service found, since it may since have been fixed.
+	 err := conf.DeletePitfalls(config.Name)
+	 if err != nil {
+		 log.Printf("Unable to delete previous pitfalls: %v", err)
+	 }
+	 recordPitfalls(config.Name, pitfallObfuscationUnsupported(config))
 	 go func() {
-		 pitfallDnsCacheDisabled()
-		 pitfallVirtioNetworkDriver()
+		 recordPitfalls(config.Name, pitfallDnsCacheDisabled())
+		 recordPitfalls(config.Name, pitfallVirtioNetworkDriver())
 	 }()
  }
  
+ // pitfallObfuscationUnsupported notes that the AmneziaWG parameters of the configuration are
+ // ignored, because the driver speaks plain WireGuard, which obfuscating peers will not understand.
+ func pitfallObfuscationUnsupported(config *conf.Config) []conf.Pitfall {
+	 if config.Interface.Obfuscation.IsEmpty() {
+		 return nil
+	 }
+	 return []conf.Pitfall{{Kind: conf.PitfallObfuscationUnsupported}}
+ }
+ 
  func evaluateDynamicPitfalls(family winipcfg.AddressFamily, conf *conf.Config, luid winipcfg.LUID) {
 	 go func() {
-		 pitfallWeakHostSend(family, conf, luid)
+		 recordPitfalls(conf.Name, pitfallWeakHostSend(family, conf, luid))
 	 }()
  }
  
- func pitfallDnsCacheDisabled() {
+ // recordPitfalls logs the pitfalls that were not found before, and saves all of them so that the
+ // manager can pass them on to the UI.
+ func recordPitfalls(tunnelName string, pitfalls []conf.Pitfall) {
+	 foundPitfallsLock.Lock()
+	 defer foundPitfallsLock.Unlock()
+	 changed := false
+ nextPitfall:
+	 for _, pitfall := range pitfalls {
+		 for _, found := range foundPitfalls {
+			 if found == pitfall {
+				 continue nextPitfall
+			 }
+		 }
+		 ringlogger.Logf(ringlogger.LevelWarning, "pitfalls", "Warning: %v", pitfall)
+		 foundPitfalls = append(foundPitfalls, pitfall)
+		 changed = true
+	 }
+	 if !changed {
+		 return
+	 }
+	 err := conf.SavePitfalls(tunnelName, foundPitfalls)
+	 if err != nil {
+		 ringlogger.Logf(ringlogger.LevelError, "pitfalls", "Unable to save pitfalls: %v", err)
+	 }
+ }
+ 
+ // forgetPitfall drops a pitfall that no longer applies while the service is running.
+ func forgetPitfall(tunnelName string, pitfall conf.Pitfall) {
+	 foundPitfallsLock.Lock()
+	 defer foundPitfallsLock.Unlock()
+	 kept := foundPitfalls[:0]
+	 for _, found := range foundPitfalls {
+		 if found != pitfall {
+			 kept = append(kept, found)
+		 }
+	 }
+	 if len(kept) == len(foundPitfalls) {
+		 return
+	 }
+	 foundPitfalls = kept
+	 err := conf.SavePitfalls(tunnelName, foundPitfalls)
+	 if err != nil {
+		 ringlogger.Logf(ringlogger.LevelError, "pitfalls", "Unable to save pitfalls: %v", err)
+	 }
+ }
+ 
+ func pitfallDnsCacheDisabled() []conf.Pitfall {
 	 dnsCacheCheckOnce.Do(func() {
 		 scm, err := mgr.Connect()
 		 if err != nil {
@@ -63,22 +127,22 @@
 	 if time.Since(dnsCacheTime) > dnsCacheDuration {
 		 // Reset Once, damit die Prüfung nach Ablauf der Dauer erneut ausgeführt wird.
 		 dnsCacheCheckOnce = sync.Once{}
-		 pitfallDnsCacheDisabled()
-		 return
+		 return pitfallDnsCacheDisabled()
 	 }
  
 	 if dnsCacheDisabled {
-		 log.Printf("Warning: the %q (dnscache) service is disabled; please re-enable it", "DNS Client")
+		 return []conf.Pitfall{{Kind: conf.PitfallDNSCacheDisabled}}
 	 }
+	 return nil
  }
  
- func pitfallVirtioNetworkDriver() {
+ func pitfallVirtioNetworkDriver() []conf.Pitfall {
 	 var modules []windows.RTL_PROCESS_MODULE_INFORMATION
 	 bufferSize := uint32(128 * 1024)
 	 moduleBuffer := make([]byte, bufferSize)
 	 err := windows.NtQuerySystemInformation(windows.SystemModuleInformation, unsafe.Pointer(&moduleBuffer[0]), bufferSize, &bufferSize)
 	 if err != nil {
-		 return
+		 return nil
 	 }
 	 mods := (*windows.RTL_PROCESS_MODULES)(unsafe.Pointer(&moduleBuffer[0]))
 	 modules = unsafe.Slice(&mods.Modules[0], mods.NumberOfModules)
@@ -92,34 +156,34 @@
 		 var zero windows.Handle
 		 infoSize, err := windows.GetFileVersionInfoSize(driverPath, &zero)
 		 if err != nil {
-			 return
+			 return nil
 		 }
 		 versionInfo := make([]byte, infoSize)
 		 err = windows.GetFileVersionInfo(driverPath, 0, infoSize, unsafe.Pointer(&versionInfo[0]))
 		 if err != nil {
-			 return
+			 return nil
 		 }
 		 // Fehlerbehebung: Verwende eine lokale Variable statt eines nicht initialisierten Zeigers.
 		 var fixedInfo windows.VS_FIXEDFILEINFO
 		 fixedInfoLen := uint32(unsafe.Sizeof(fixedInfo))
 		 err = windows.VerQueryValue(unsafe.Pointer(&versionInfo[0]), `\`, unsafe.Pointer(&fixedInfo), &fixedInfoLen)
 		 if err != nil {
-			 return
+			 return nil
 		 }
 		 version := (uint64(fixedInfo.FileVersionMS) << 32) | uint64(fixedInfo.FileVersionLS)
 		 // Es wird nun gewarnt, wenn die Version im problematischen Bereich liegt.
 		 if version >= 0x6400556800005140 || version < 0x2800000000000000 {
-			 return
+			 return nil
 		 }
-		 log.Println("Warning: the VirtIO network driver (NetKVM) is out of date and may cause known problems; please update to v100.85.104.20800 or later")
-		 return
+		 return []conf.Pitfall{{Kind: conf.PitfallOutdatedVirtioDriver}}
 	 }
+	 return nil
  }
  
- func pitfallWeakHostSend(family winipcfg.AddressFamily, conf *conf.Config, ourLUID winipcfg.LUID) {
+ func pitfallWeakHostSend(family winipcfg.AddressFamily, config *conf.Config, ourLUID winipcfg.LUID) []conf.Pitfall {
 	 routingTable, err := winipcfg.GetIPForwardTable2(family)
 	 if err != nil {
-		 return
+		 return nil
 	 }
  
 	 type endpointRoute struct {
@@ -131,8 +195,8 @@
 		 finalIsOurs  bool
 	 }
  
-	 endpoints := make([]endpointRoute, 0, len(conf.Peers))
-	 for _, peer := range conf.Peers {
+	 endpoints := make([]endpointRoute, 0, len(config.Peers))
+	 for _, peer := range config.Peers {
 		 addr, err := netip.ParseAddr(peer.Endpoint.Host)
 		 if err != nil || (addr.Is4() && family != windows.AF_INET) || (addr.Is6() && family != windows.AF_INET6) {
 			 continue
@@ -200,8 +264,10 @@
 		 }
 	 }
  
+	 var pitfalls []conf.Pitfall
 	 for iface := range problematicInterfaces {
-		 log.Printf("Warning: the %q interface has Forwarding/WeakHostSend enabled, which will cause routing loops", iface)
+		 pitfalls = append(pitfalls, conf.Pitfall{Kind: conf.PitfallWeakHostSend, Detail: iface})
 	 }
+	 return pitfalls
  }
  
\ No newline at end of file
@@ -0,0 +1,114 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package tunnel
+
+import (
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
+)
+
+// Scripts given as "powershell: C:\path\to\script.ps1 arguments…" are run as PowerShell script
+// files, and those given as "powershell: { … }" as PowerShell script blocks, rather than by cmd.
+const powerShellScriptPrefix = "powershell:"
+
+// Scripts given as "user: …" are run by the manager as the user signed in to the console, and
+// those given as "user-elevated: …" with that user's elevated token, which requires them to be an
+// administrator, rather than as Local System. Either may be followed by "powershell:".
+const (
+	userScriptPrefix         = "user:"
+	elevatedUserScriptPrefix = "user-elevated:"
+)
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// scriptUser removes the prefix from command that says which user it is to run as, if any.
+func scriptUser(command string) (rest string, asUser, elevated bool) {
+	switch {
+	case hasPrefixFold(command, userScriptPrefix):
+		return strings.TrimSpace(command[len(userScriptPrefix):]), true, false
+	case hasPrefixFold(command, elevatedUserScriptPrefix):
+		return strings.TrimSpace(command[len(elevatedUserScriptPrefix):]), true, true
+	}
+	return command, false, false
+}
+
+// scriptCommandLine returns the program that runs command, and its command line.
+func scriptCommandLine(command, comspec string) (program, commandLine string, err error) {
+	if !hasPrefixFold(command, powerShellScriptPrefix) {
+		return comspec, "cmd /c " + command, nil
+	}
+	script := strings.TrimSpace(command[len(powerShellScriptPrefix):])
+	system32, err := windows.GetSystemDirectory()
+	if err != nil {
+		return "", "", err
+	}
+	program = filepath.Join(system32, "WindowsPowerShell", "v1.0", "powershell.exe")
+	args := []string{program, "-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass"}
+	if strings.HasPrefix(script, "{") && strings.HasSuffix(script, "}") {
+		// Encoding the block spares it from the quoting rules of the command line.
+		encoded := utf16.Encode([]rune("& " + script))
+		raw := make([]byte, len(encoded)*2)
+		for i, c := range encoded {
+			raw[i*2], raw[i*2+1] = byte(c), byte(c>>8)
+		}
+		args = append(args, "-EncodedCommand", base64.StdEncoding.EncodeToString(raw))
+	} else {
+		fileArgs, err := windows.DecomposeCommandLine(script)
+		if err != nil {
+			return "", "", err
+		}
+		if len(fileArgs) == 0 {
+			return "", "", errors.New("no PowerShell script given")
+		}
+		args = append(args, "-File")
+		args = append(args, fileArgs...)
+	}
+	return program, windows.ComposeCommandLine(args), nil
+}
+
+// scriptEnvironment returns the variables that describe the tunnel to its scripts, so that they
+// need not look up its interface themselves. Lists are separated by commas.
+func scriptEnvironment(config *conf.Config, luid winipcfg.LUID) []string {
+	env := []string{"WIREGUARD_TUNNEL_NAME=" + config.Name}
+	if luid != 0 {
+		env = append(env, "WIREGUARD_INTERFACE_LUID="+strconv.FormatUint(uint64(luid), 10))
+		if guid, err := luid.GUID(); err == nil {
+			env = append(env, "WIREGUARD_INTERFACE_GUID="+guid.String())
+		}
+		if row, err := luid.Interface(); err == nil {
+			env = append(env, "WIREGUARD_INTERFACE_INDEX="+strconv.FormatUint(uint64(row.InterfaceIndex), 10))
+		}
+	}
+	addresses := make([]string, 0, len(config.Interface.Addresses))
+	for _, address := range config.Interface.Addresses {
+		addresses = append(addresses, address.String())
+	}
+	env = append(env, "WIREGUARD_ADDRESSES="+strings.Join(addresses, ","))
+	dns := make([]string, 0, len(config.Interface.DNS))
+	for _, server := range config.Interface.DNS {
+		dns = append(dns, server.String())
+	}
+	env = append(env, "WIREGUARD_DNS="+strings.Join(dns, ","))
+	var endpoints []string
+	for _, peer := range config.Peers {
+		if !peer.Endpoint.IsEmpty() {
+			endpoints = append(endpoints, peer.Endpoint.String())
+		}
+	}
+	env = append(env, "WIREGUARD_ENDPOINTS="+strings.Join(endpoints, ","))
+	return env
+}
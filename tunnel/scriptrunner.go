@@ -18,9 +18,30 @@ import (
 	"golang.org/x/sys/windows"
 
 	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/services"
+	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
 )
 
-func runScriptCommand(command, interfaceName string) error {
+// defaultScriptTimeout is how long scripts may run when their configuration does not say.
+const defaultScriptTimeout = 30 * time.Second
+
+// runScriptCommands runs commands one after the other, stopping at the first that fails.
+func runScriptCommands(commands []string, config *conf.Config, luid winipcfg.LUID) error {
+	for i, command := range commands {
+		if len(commands) > 1 {
+			log.Printf("Running command %d of %d", i+1, len(commands))
+		}
+		if err := runScriptCommand(command, config, luid); err != nil {
+			if len(commands) > 1 {
+				return fmt.Errorf("command %d of %d failed: %w", i+1, len(commands), err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func runScriptCommand(command string, config *conf.Config, luid winipcfg.LUID) error {
 	if len(command) == 0 {
 		return nil
 	}
@@ -30,8 +51,13 @@ func runScriptCommand(command, interfaceName string) error {
 	}
 	log.Printf("Executing: %#q", command)
 
+	timeout := defaultScriptTimeout
+	if config.Interface.ScriptTimeout > 0 {
+		timeout = time.Duration(config.Interface.ScriptTimeout) * time.Second
+	}
+
 	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	// Get COMSPEC environment variable with fallback
@@ -43,6 +69,14 @@ func runScriptCommand(command, interfaceName string) error {
 		}
 		comspec = filepath.Join(system32, "cmd.exe")
 	}
+	command, asUser, elevated := scriptUser(command)
+	program, commandLine, err := scriptCommandLine(command, comspec)
+	if err != nil {
+		return fmt.Errorf("failed to prepare command line: %w", err)
+	}
+	if asUser {
+		return runUserScriptCommand(program, commandLine, elevated, timeout, config, luid)
+	}
 
 	// Open devNull with proper error handling
 	devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
@@ -62,22 +96,36 @@ func runScriptCommand(command, interfaceName string) error {
 	// Prepare process attributes with improved security
 	procAttr := &os.ProcAttr{
 		Files: []*os.File{devNull, writer, writer},
-		Env:   append(os.Environ(), "WIREGUARD_TUNNEL_NAME="+interfaceName),
+		Env:   append(os.Environ(), scriptEnvironment(config, luid)...),
 		Sys: &syscall.SysProcAttr{
 			HideWindow:    true,
-			CmdLine:       fmt.Sprintf("cmd /c %s", command),
-			CreationFlags: windows.CREATE_NO_WINDOW | windows.CREATE_NEW_PROCESS_GROUP,
+			CmdLine:       commandLine,
+			CreationFlags: windows.CREATE_NO_WINDOW | windows.CREATE_NEW_PROCESS_GROUP | windows.CREATE_SUSPENDED,
 		},
 	}
 
 	// Start process with context
-	process, err := os.StartProcess(comspec, nil, procAttr)
+	process, err := os.StartProcess(program, nil, procAttr)
 	if err != nil {
 		writer.Close()
 		return fmt.Errorf("failed to start process: %w", err)
 	}
 	writer.Close()
 
+	// Put the process in a job object before it runs, so that what it starts can be killed with it
+	job, err := newScriptJob()
+	if err == nil {
+		err = assignToJobAndResume(job, process.Pid)
+		if err != nil {
+			windows.CloseHandle(job)
+			job = 0
+		}
+	}
+	if err != nil {
+		log.Printf("Warning: unable to supervise script with job object: %v", err)
+		resumeProcess(process.Pid)
+	}
+
 	// Launch a goroutine to read process output with context
 	outputChan := make(chan string, 100)
 	go func() {
@@ -124,11 +172,17 @@ func runScriptCommand(command, interfaceName string) error {
 			return fmt.Errorf("process wait failed: %w", err)
 		}
 	case <-ctx.Done():
-		// Kill process group to ensure all child processes are terminated
-		if err := process.Kill(); err != nil {
+		// Kill the job to ensure all child processes are terminated
+		if job != 0 {
+			err = windows.TerminateJobObject(job, 1)
+			windows.CloseHandle(job)
+		} else {
+			err = process.Kill()
+		}
+		if err != nil {
 			log.Printf("Warning: failed to kill process: %v", err)
 		}
-		return fmt.Errorf("process timed out after 30 seconds")
+		return fmt.Errorf("process timed out after %v", timeout)
 	}
 
 	if procState.ExitCode() == 0 {
@@ -138,3 +192,32 @@ func runScriptCommand(command, interfaceName string) error {
 	log.Printf("Command error exit status: %d", procState.ExitCode())
 	return windows.ERROR_GENERIC_COMMAND_FAILED
 }
+
+// runUserScriptCommand has the manager run the script as the user signed in to the console.
+func runUserScriptCommand(program, commandLine string, elevated bool, timeout time.Duration, config *conf.Config, luid winipcfg.LUID) error {
+	response, err := services.RunUserScript(&services.UserScriptRequest{
+		Tunnel:      config.Name,
+		Program:     program,
+		CommandLine: commandLine,
+		Environment: scriptEnvironment(config, luid),
+		Elevated:    elevated,
+		Timeout:     timeout,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to run script as user: %w", err)
+	}
+	for _, output := range response.Output {
+		log.Printf("cmd> %s", output)
+	}
+	if len(response.Error) > 0 {
+		return fmt.Errorf("failed to run script as user: %s", response.Error)
+	}
+	if response.TimedOut {
+		return fmt.Errorf("process timed out after %v", timeout)
+	}
+	if response.ExitCode == 0 {
+		return nil
+	}
+	log.Printf("Command error exit status: %d", response.ExitCode)
+	return windows.ERROR_GENERIC_COMMAND_FAILED
+}
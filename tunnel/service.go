@@ -10,19 +10,21 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/netip"
 	"os"
 	"runtime"
 	"time"
-	"math"
 	"sync"
 
 	"golang.org/x/sys/windows/svc"
-	"golang.org/x/sys/windows/svc/mgr"
 	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/crashreport"
 	"golang.zx2c4.com/wireguard/windows/driver"
 	"golang.zx2c4.com/wireguard/windows/elevate"
+	"golang.zx2c4.com/wireguard/windows/etw"
 	"golang.zx2c4.com/wireguard/windows/ringlogger"
 	"golang.zx2c4.com/wireguard/windows/services"
+	"golang.zx2c4.com/wireguard/windows/tunnel/firewall"
 	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
 )
 
@@ -30,15 +32,23 @@ type tunnelService struct {
 	Path string
 }
 
+// serviceControlUpgradeRestart is sent by the installer right after it has replaced the
+// executable, and stops the service like svc.Stop, except that the kill switch stays in place
+// while the installer starts the service again. It is mirrored in installer/customactions.c.
+const serviceControlUpgradeRestart = svc.Cmd(128)
+
 func (service *tunnelService) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (svcSpecificEC bool, exitCode uint32) {
+	defer crashreport.Recover("TUN")
 	serviceState := svc.StartPending
 	changes <- svc.Status{State: serviceState}
 
 	var watcher *interfaceWatcher
+	var pins *endpointPins
 	var adapter *driver.Adapter
 	var luid winipcfg.LUID
 	var config *conf.Config
 	var err error
+	var stopRequested bool
 	serviceError := services.ErrorSuccess
 
 	// Create context for graceful shutdown
@@ -97,7 +107,7 @@ func (service *tunnelService) Execute(args []string, r <-chan svc.ChangeRequest,
 			cleanupWg.Add(1)
 			go func() {
 				defer cleanupWg.Done()
-				if err := runScriptCommand(config.Interface.PreDown, config.Name); err != nil {
+				if err := runScriptCommands(config.Interface.PreDown, config, luid); err != nil {
 					log.Printf("Warning: PreDown script failed: %v", err)
 				}
 			}()
@@ -123,12 +133,29 @@ func (service *tunnelService) Execute(args []string, r <-chan svc.ChangeRequest,
 			cleanupWg.Add(1)
 			go func() {
 				defer cleanupWg.Done()
-				if err := runScriptCommand(config.Interface.PostDown, config.Name); err != nil {
+				if err := runScriptCommands(config.Interface.PostDown, config, luid); err != nil {
 					log.Printf("Warning: PostDown script failed: %v", err)
 				}
 			}()
 		}
 
+		if pins != nil {
+			pins.Close()
+		}
+
+		if config != nil && usesSplitDNS(config) {
+			if err := removeNRPTRule(config.Name); err != nil {
+				log.Printf("Warning: unable to remove split DNS rule: %v", err)
+			}
+		}
+
+		// Only an explicit stop lifts the kill switch; crashes and adapter loss leave it in place.
+		if stopRequested && config != nil && config.Interface.KillSwitch {
+			if err := firewall.DisableKillSwitch(config.Name); err != nil {
+				log.Printf("Warning: unable to remove kill switch: %v", err)
+			}
+		}
+
 		log.Println("Shutting down")
 	}()
 
@@ -149,24 +176,45 @@ func (service *tunnelService) Execute(args []string, r <-chan svc.ChangeRequest,
 		serviceError = services.ErrorLoadConfiguration
 		return
 	}
+	var profile *conf.Profile
+	if profiles, err := conf.LoadProfiles(config.Name); err == nil {
+		profile = profiles.Find(profiles.Active)
+	}
+	if profile != nil {
+		err = profile.Apply(config)
+		if err != nil {
+			serviceError = services.ErrorLoadConfiguration
+			return
+		}
+	}
+	if conf.LoadPolicies().ForceKillSwitch {
+		config.Interface.KillSwitch = true
+	}
 	config.DeduplicateNetworkEntries()
+	if port, err := conf.LoadListenPortOverride(config.Name); err == nil && port != 0 {
+		config.Interface.ListenPort = port
+	}
 
 	log.SetPrefix(fmt.Sprintf("[%s] ", config.Name))
+	if err := etw.Register(); err != nil {
+		log.Printf("Unable to register ETW provider: %v", err)
+	} else {
+		defer etw.Unregister()
+		traceHandshakeInitiations(config.Name)
+	}
+	if profile != nil {
+		log.Printf("Using profile ‘%s’", profile.Name)
+	}
 
 	services.PrintStarting()
 
-	if services.StartedAtBoot() {
-		if m, err := mgr.Connect(); err == nil {
-			if lockStatus, err := m.LockStatus(); err == nil && lockStatus.IsLocked {
-				log.Printf("SCM locked for %v by %s, marking service as started", lockStatus.Age, lockStatus.Owner)
-				serviceState = svc.Running
-				changes <- svc.Status{State: serviceState}
-			}
-			m.Disconnect()
-		}
+	if services.SCMLockedAtBoot() {
+		log.Println("Marking service as started")
+		serviceState = svc.Running
+		changes <- svc.Status{State: serviceState}
 	}
 
-	evaluateStaticPitfalls()
+	evaluateStaticPitfalls(config)
 
 	log.Println("Watching network interfaces")
 	watcher, err = watchInterface()
@@ -176,29 +224,37 @@ func (service *tunnelService) Execute(args []string, r <-chan svc.ChangeRequest,
 	}
 
 	log.Println("Resolving DNS names")
+	namedEndpoints := unresolvedEndpoints(config)
 	err = config.ResolveEndpoints()
+	var pendingEndpoints []pendingEndpoint
+	if err != nil {
+		if !pendingDNSAllowed() {
+			serviceError = services.ErrorDNSLookup
+			return
+		}
+		pendingEndpoints = unresolvedEndpoints(config)
+		log.Printf("Unable to resolve endpoints, continuing without them and retrying in the background: %v", err)
+		err = nil
+	}
+	// Endpoints given as host names that did resolve are chosen again as the network changes.
+	resolvedEndpoints := namedEndpoints[:0:0]
+	for _, endpoint := range namedEndpoints {
+		if _, err := netip.ParseAddr(config.Peers[endpoint.peer].Endpoint.Host); err == nil {
+			resolvedEndpoints = append(resolvedEndpoints, endpoint)
+		}
+	}
+
+	guid, err := adapterGUID(config)
 	if err != nil {
-		serviceError = services.ErrorDNSLookup
+		serviceError = services.ErrorCreateNetworkAdapter
 		return
 	}
 
 	log.Println("Creating network adapter")
-	for i := 0; i < 15; i++ {
-		if i > 0 {
-			sleepSeconds := math.Pow(2, float64(i))
-			if sleepSeconds > 30 {
-				sleepSeconds = 30
-			}
-			sleepTime := time.Duration(sleepSeconds) * time.Second
-			time.Sleep(sleepTime)
-			log.Printf("Retrying adapter creation (attempt %d, waited %v): %v", i+1, sleepTime, err)
-		}
-		adapter, err = driver.CreateAdapter(config.Name, "WireGuard", deterministicGUID(config))
-		if err == nil || !services.StartedAtBoot() {
-			break
-		}
-	}
+	adapter, err = createAdapterAtBoot(config, guid)
 	if err != nil {
+		diagnosis := driver.DiagnoseAdapterFailure(err)
+		log.Printf("Likely causes of adapter failure: %v", &diagnosis)
 		err = fmt.Errorf("Error creating adapter: %w", err)
 		serviceError = services.ErrorCreateNetworkAdapter
 		return
@@ -210,25 +266,41 @@ func (service *tunnelService) Execute(args []string, r <-chan svc.ChangeRequest,
 	} else {
 		log.Printf("Using WireGuardNT/%d.%d", (driverVersion>>16)&0xffff, driverVersion&0xffff)
 	}
-	err = adapter.SetLogging(driver.AdapterLogOn)
+	err = applyAdapterLogLevel(adapter, config.Name)
 	if err != nil {
 		err = fmt.Errorf("Error enabling adapter logging: %w", err)
 		serviceError = services.ErrorCreateNetworkAdapter
 		return
 	}
 
-	err = runScriptCommand(config.Interface.PreUp, config.Name)
+	err = runScriptCommands(config.Interface.PreUp, config, luid)
 	if err != nil {
 		serviceError = services.ErrorRunScript
 		return
 	}
 
+	if config.Interface.AllowLocalNetwork {
+		allowLocalNetwork(config, luid)
+	}
+
 	err = enableFirewall(config, luid)
 	if err != nil {
 		serviceError = services.ErrorFirewall
 		return
 	}
 
+	if usesSplitDNS(config) {
+		log.Println("Installing split DNS rule")
+		err = installNRPTRule(config)
+		if err != nil {
+			err = fmt.Errorf("Error installing split DNS rule: %w", err)
+			serviceError = services.ErrorSetNetConfig
+			return
+		}
+	} else {
+		removeNRPTRule(config.Name)
+	}
+
 	log.Println("Dropping privileges")
 	err = elevate.DropAllPrivileges(true)
 	if err != nil {
@@ -252,8 +324,20 @@ func (service *tunnelService) Execute(args []string, r <-chan svc.ChangeRequest,
 		return
 	}
 	watcher.Configure(adapter, config, luid)
+	if len(pendingEndpoints) > 0 {
+		go resolvePendingEndpoints(ctx, watcher, config.Name, pendingEndpoints)
+	}
+	go monitorEndpointFamilies(ctx, watcher, luid, config, resolvedEndpoints)
+	if len(config.Interface.BindInterface) > 0 {
+		pins = bindEndpoints(ctx, watcher, luid, config.Interface.BindInterface)
+	}
+	go diagnoseMTU(ctx, config, luid)
+	go tracePeers(ctx, adapter, config)
+	if len(config.Interface.PeerSource) > 0 {
+		go followPeerSource(ctx, watcher, config)
+	}
 
-	err = runScriptCommand(config.Interface.PostUp, config.Name)
+	err = runScriptCommands(config.Interface.PostUp, config, luid)
 	if err != nil {
 		serviceError = services.ErrorRunScript
 		return
@@ -262,25 +346,56 @@ func (service *tunnelService) Execute(args []string, r <-chan svc.ChangeRequest,
 	changes <- svc.Status{State: serviceState, Accepts: svc.AcceptStop | svc.AcceptShutdown}
 
 	var started bool
+	var checked chan error
 	for {
 		select {
 		case c := <-r:
 			switch c.Cmd {
 			case svc.Stop, svc.Shutdown:
+				stopRequested = c.Cmd == svc.Stop
 				cancel() // Cancel context to initiate graceful shutdown
 				return
+			case serviceControlUpgradeRestart:
+				log.Println("Restarting for upgrade")
+				cancel()
+				return
+			case services.ControlReloadAdapterLog:
+				err := applyAdapterLogLevel(adapter, config.Name)
+				if err != nil {
+					log.Printf("Unable to change adapter logging: %v", err)
+				}
 			case svc.Interrogate:
 				changes <- c.CurrentStatus
 			default:
 				log.Printf("Unexpected service control request #%d\n", c)
 			}
 		case <-watcher.started:
-			if !started {
+			if started || checked != nil {
+				break
+			}
+			if len(config.Interface.CheckHost) == 0 {
 				serviceState = svc.Running
 				changes <- svc.Status{State: serviceState, Accepts: svc.AcceptStop | svc.AcceptShutdown}
 				log.Println("Startup complete")
 				started = true
+				break
+			}
+			log.Printf("Checking connectivity to %s", config.Interface.CheckHost)
+			checked = make(chan error, 1)
+			go func() {
+				checked <- checkConnectivity(ctx, config.Interface.CheckHost, checkTimeout(config), luid)
+			}()
+		case e := <-checked:
+			if e != nil {
+				err = e
+				serviceError = services.ErrorConnectivityCheck
+				return
 			}
+			serviceState = svc.Running
+			changes <- svc.Status{State: serviceState, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+			log.Println("Startup complete")
+			started = true
+			checked = nil
 		case e := <-watcher.errors:
 			serviceError, err = e.serviceError, e.err
 			return
@@ -290,6 +405,20 @@ func (service *tunnelService) Execute(args []string, r <-chan svc.ChangeRequest,
 	}
 }
 
+// applyAdapterLogLevel sets the adapter logging to the level that the tunnel is configured for.
+func applyAdapterLogLevel(adapter *driver.Adapter, tunnelName string) error {
+	level, err := conf.LoadAdapterLogLevel(tunnelName)
+	if err != nil {
+		log.Printf("Unable to load adapter log level, logging verbosely: %v", err)
+	}
+	log.Printf("Setting adapter logging to %s", level)
+	driver.SetLogErrorsOnly(level == conf.AdapterLogErrors)
+	if level == conf.AdapterLogOff {
+		return adapter.SetLogging(driver.AdapterLogOff)
+	}
+	return adapter.SetLogging(driver.AdapterLogOn)
+}
+
 func Run(confPath string) error {
 	name, err := conf.NameFromPath(confPath)
 	if err != nil {
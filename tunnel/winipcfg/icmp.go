@@ -0,0 +1,110 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package winipcfg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	ipFlagDontFragment = 0x2
+	ipSuccess          = 0
+
+	// Status follows the address in ICMP_ECHO_REPLY, and the packed IPV6_ADDRESS_EX in ICMPV6_ECHO_REPLY.
+	icmpEchoReplyStatusOffset  = 4
+	icmp6EchoReplyStatusOffset = 28
+
+	// Room in the reply buffer beyond the echoed payload, for the reply structure and an ICMP error.
+	icmpReplyOverhead = 256
+)
+
+// ipOptionInformation is IP_OPTION_INFORMATION.
+// https://docs.microsoft.com/en-us/windows/win32/api/ipexport/ns-ipexport-ip_option_information
+type ipOptionInformation struct {
+	ttl         uint8
+	tos         uint8
+	flags       uint8
+	optionsSize uint8
+	optionsData *byte
+}
+
+// ICMPHandle sends echo requests to a destination, the way ping does.
+type ICMPHandle struct {
+	handle      windows.Handle
+	source      netip.Addr
+	destination netip.Addr
+}
+
+// OpenICMP returns a handle for sending echo requests from source to destination. If source is
+// not valid, the system chooses the address to send from.
+func OpenICMP(source, destination netip.Addr) (*ICMPHandle, error) {
+	var handle windows.Handle
+	var err error
+	if destination.Is4() {
+		handle, err = icmpCreateFile()
+	} else {
+		handle, err = icmp6CreateFile()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ICMPHandle{handle, source, destination}, nil
+}
+
+// Close closes the handle.
+func (icmp *ICMPHandle) Close() error {
+	return icmpCloseHandle(icmp.handle)
+}
+
+// Echo sends an echo request with payloadSize bytes of payload, which is not fragmented on the way
+// if dontFragment is set, and waits up to timeout for the reply. It returns an error if no reply
+// arrives, or if it reports that the request did not reach the destination.
+func (icmp *ICMPHandle) Echo(payloadSize int, dontFragment bool, timeout time.Duration) error {
+	var requestData *byte
+	if payloadSize > 0 {
+		requestData = &make([]byte, payloadSize)[0]
+	}
+	reply := make([]byte, payloadSize+icmpReplyOverhead)
+	options := ipOptionInformation{ttl: 128}
+	if dontFragment {
+		options.flags = ipFlagDontFragment
+	}
+	milliseconds := uint32(timeout / time.Millisecond)
+	var err error
+	var statusOffset int
+	if icmp.destination.Is4() {
+		var source [4]byte
+		if icmp.source.Is4() {
+			source = icmp.source.As4()
+		}
+		destination := icmp.destination.As4()
+		_, err = icmpSendEcho2Ex(icmp.handle, 0, 0, 0, *(*uint32)(unsafe.Pointer(&source[0])), *(*uint32)(unsafe.Pointer(&destination[0])),
+			requestData, uint16(payloadSize), &options, &reply[0], uint32(len(reply)), milliseconds)
+		statusOffset = icmpEchoReplyStatusOffset
+	} else {
+		source := windows.RawSockaddrInet6{Family: windows.AF_INET6}
+		if icmp.source.Is6() {
+			source.Addr = icmp.source.As16()
+		}
+		destination := windows.RawSockaddrInet6{Family: windows.AF_INET6, Addr: icmp.destination.As16()}
+		_, err = icmp6SendEcho2(icmp.handle, 0, 0, 0, &source, &destination,
+			requestData, uint16(payloadSize), &options, &reply[0], uint32(len(reply)), milliseconds)
+		statusOffset = icmp6EchoReplyStatusOffset
+	}
+	if err != nil {
+		return err
+	}
+	if status := binary.LittleEndian.Uint32(reply[statusOffset:]); status != ipSuccess {
+		return fmt.Errorf("Echo reply status %d", status)
+	}
+	return nil
+}
@@ -977,6 +977,53 @@ func (tab *mibIPforwardTable2) free() {
 	freeMibTable(unsafe.Pointer(tab))
 }
 
+//
+// Statistics API
+//
+
+// MibIPStats structure stores the IP statistics of the local computer for an address family.
+// https://docs.microsoft.com/en-us/windows/win32/api/ipmib/ns-ipmib-mib_ipstats_lh
+type MibIPStats struct {
+	Forwarding      uint32
+	DefaultTTL      uint32
+	InReceives      uint32
+	InHdrErrors     uint32
+	InAddrErrors    uint32
+	ForwDatagrams   uint32
+	InUnknownProtos uint32
+	InDiscards      uint32
+	InDelivers      uint32
+	OutRequests     uint32
+	RoutingDiscards uint32
+	OutDiscards     uint32
+	OutNoRoutes     uint32
+	ReasmTimeout    uint32
+	ReasmReqds      uint32
+	ReasmOks        uint32
+	ReasmFails      uint32
+	FragOks         uint32
+	FragFails       uint32
+	FragCreates     uint32
+	NumIf           uint32
+	NumAddr         uint32
+	NumRoutes       uint32
+}
+
+// MibICMPStats structure stores the counts of ICMP messages received or sent, by type.
+// https://docs.microsoft.com/en-us/windows/win32/api/ipmib/ns-ipmib-mibicmpstats_ex_xpsp1
+type MibICMPStats struct {
+	Msgs      uint32
+	Errors    uint32
+	TypeCount [256]uint32
+}
+
+// MibICMPEx structure stores the ICMP statistics of the local computer for an address family.
+// https://docs.microsoft.com/en-us/windows/win32/api/ipmib/ns-ipmib-mib_icmp_ex_xpsp1
+type MibICMPEx struct {
+	In  MibICMPStats
+	Out MibICMPStats
+}
+
 //
 // DNS API
 //
@@ -153,6 +153,54 @@ func GetIPForwardTable2(family AddressFamily) ([]MibIPforwardRow2, error) {
 	return t, nil
 }
 
+//
+// Statistics-related functions
+//
+
+//sys	getIPStatisticsEx(statistics *MibIPStats, family AddressFamily) (ret error) = iphlpapi.GetIpStatisticsEx
+//sys	getICMPStatisticsEx(statistics *MibICMPEx, family AddressFamily) (ret error) = iphlpapi.GetIcmpStatisticsEx
+
+// GetIPStatistics function retrieves the IP statistics of the local computer for an address family.
+// https://docs.microsoft.com/en-us/windows/win32/api/iphlpapi/nf-iphlpapi-getipstatisticsex
+func GetIPStatistics(family AddressFamily) (*MibIPStats, error) {
+	statistics := &MibIPStats{}
+	err := getIPStatisticsEx(statistics, family)
+	if err != nil {
+		return nil, err
+	}
+	return statistics, nil
+}
+
+// GetICMPStatistics function retrieves the ICMP statistics of the local computer for an address family.
+// https://docs.microsoft.com/en-us/windows/win32/api/iphlpapi/nf-iphlpapi-geticmpstatisticsex
+func GetICMPStatistics(family AddressFamily) (*MibICMPEx, error) {
+	statistics := &MibICMPEx{}
+	err := getICMPStatisticsEx(statistics, family)
+	if err != nil {
+		return nil, err
+	}
+	return statistics, nil
+}
+
+//
+// ICMP-related functions
+//
+
+// https://docs.microsoft.com/en-us/windows/win32/api/icmpapi/nf-icmpapi-icmpcreatefile
+//sys	icmpCreateFile() (handle windows.Handle, err error) [failretval==windows.InvalidHandle] = iphlpapi.IcmpCreateFile
+
+// https://docs.microsoft.com/en-us/windows/win32/api/icmpapi/nf-icmpapi-icmp6createfile
+//sys	icmp6CreateFile() (handle windows.Handle, err error) [failretval==windows.InvalidHandle] = iphlpapi.Icmp6CreateFile
+
+// https://docs.microsoft.com/en-us/windows/win32/api/icmpapi/nf-icmpapi-icmpclosehandle
+//sys	icmpCloseHandle(handle windows.Handle) (err error) = iphlpapi.IcmpCloseHandle
+
+// https://docs.microsoft.com/en-us/windows/win32/api/icmpapi/nf-icmpapi-icmpsendecho2ex
+//sys	icmpSendEcho2Ex(handle windows.Handle, event windows.Handle, apcRoutine uintptr, apcContext uintptr, sourceAddress uint32, destinationAddress uint32, requestData *byte, requestSize uint16, requestOptions *ipOptionInformation, replyBuffer *byte, replySize uint32, timeout uint32) (replies uint32, err error) [failretval==0] = iphlpapi.IcmpSendEcho2Ex
+
+// https://docs.microsoft.com/en-us/windows/win32/api/icmpapi/nf-icmpapi-icmp6sendecho2
+//sys	icmp6SendEcho2(handle windows.Handle, event windows.Handle, apcRoutine uintptr, apcContext uintptr, sourceAddress *windows.RawSockaddrInet6, destinationAddress *windows.RawSockaddrInet6, requestData *byte, requestSize uint16, requestOptions *ipOptionInformation, replyBuffer *byte, replySize uint32, timeout uint32) (replies uint32, err error) [failretval==0] = iphlpapi.Icmp6SendEcho2
+
 //
 // Notifications-related functions
 //
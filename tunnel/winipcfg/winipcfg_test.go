@@ -351,6 +351,21 @@ func TestGetIfRows(t *testing.T) {
 	}
 }
 
+func TestGetStatistics(t *testing.T) {
+	for _, family := range []AddressFamily{windows.AF_INET, windows.AF_INET6} {
+		ip, err := GetIPStatistics(family)
+		if err != nil {
+			t.Errorf("GetIPStatistics(%d) returned an error: %v", family, err)
+		} else if ip.NumIf == 0 {
+			t.Errorf("GetIPStatistics(%d) returned no interfaces", family)
+		}
+		_, err = GetICMPStatistics(family)
+		if err != nil {
+			t.Errorf("GetICMPStatistics(%d) returned an error: %v", family, err)
+		}
+	}
+}
+
 func TestUnicastIPAddress(t *testing.T) {
 	_, err := GetUnicastIPAddressTable(windows.AF_UNSPEC)
 	if err != nil {
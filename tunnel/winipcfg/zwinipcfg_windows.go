@@ -53,14 +53,21 @@ var (
 	procFreeMibTable                    = modiphlpapi.NewProc("FreeMibTable")
 	procGetAnycastIpAddressEntry        = modiphlpapi.NewProc("GetAnycastIpAddressEntry")
 	procGetAnycastIpAddressTable        = modiphlpapi.NewProc("GetAnycastIpAddressTable")
+	procGetIcmpStatisticsEx             = modiphlpapi.NewProc("GetIcmpStatisticsEx")
 	procGetIfEntry2                     = modiphlpapi.NewProc("GetIfEntry2")
 	procGetIfTable2Ex                   = modiphlpapi.NewProc("GetIfTable2Ex")
 	procGetIpForwardEntry2              = modiphlpapi.NewProc("GetIpForwardEntry2")
 	procGetIpForwardTable2              = modiphlpapi.NewProc("GetIpForwardTable2")
 	procGetIpInterfaceEntry             = modiphlpapi.NewProc("GetIpInterfaceEntry")
 	procGetIpInterfaceTable             = modiphlpapi.NewProc("GetIpInterfaceTable")
+	procGetIpStatisticsEx               = modiphlpapi.NewProc("GetIpStatisticsEx")
 	procGetUnicastIpAddressEntry        = modiphlpapi.NewProc("GetUnicastIpAddressEntry")
 	procGetUnicastIpAddressTable        = modiphlpapi.NewProc("GetUnicastIpAddressTable")
+	procIcmp6CreateFile                 = modiphlpapi.NewProc("Icmp6CreateFile")
+	procIcmp6SendEcho2                  = modiphlpapi.NewProc("Icmp6SendEcho2")
+	procIcmpCloseHandle                 = modiphlpapi.NewProc("IcmpCloseHandle")
+	procIcmpCreateFile                  = modiphlpapi.NewProc("IcmpCreateFile")
+	procIcmpSendEcho2Ex                 = modiphlpapi.NewProc("IcmpSendEcho2Ex")
 	procInitializeIpForwardEntry        = modiphlpapi.NewProc("InitializeIpForwardEntry")
 	procInitializeIpInterfaceEntry      = modiphlpapi.NewProc("InitializeIpInterfaceEntry")
 	procInitializeUnicastIpAddressEntry = modiphlpapi.NewProc("InitializeUnicastIpAddressEntry")
@@ -174,6 +181,14 @@ func getAnycastIPAddressTable(family AddressFamily, table **mibAnycastIPAddressT
 	return
 }
 
+func getICMPStatisticsEx(statistics *MibICMPEx, family AddressFamily) (ret error) {
+	r0, _, _ := syscall.Syscall(procGetIcmpStatisticsEx.Addr(), 2, uintptr(unsafe.Pointer(statistics)), uintptr(family), 0)
+	if r0 != 0 {
+		ret = syscall.Errno(r0)
+	}
+	return
+}
+
 func getIfEntry2(row *MibIfRow2) (ret error) {
 	r0, _, _ := syscall.Syscall(procGetIfEntry2.Addr(), 1, uintptr(unsafe.Pointer(row)), 0, 0)
 	if r0 != 0 {
@@ -222,6 +237,14 @@ func getIPInterfaceTable(family AddressFamily, table **mibIPInterfaceTable) (ret
 	return
 }
 
+func getIPStatisticsEx(statistics *MibIPStats, family AddressFamily) (ret error) {
+	r0, _, _ := syscall.Syscall(procGetIpStatisticsEx.Addr(), 2, uintptr(unsafe.Pointer(statistics)), uintptr(family), 0)
+	if r0 != 0 {
+		ret = syscall.Errno(r0)
+	}
+	return
+}
+
 func getUnicastIPAddressEntry(row *MibUnicastIPAddressRow) (ret error) {
 	r0, _, _ := syscall.Syscall(procGetUnicastIpAddressEntry.Addr(), 1, uintptr(unsafe.Pointer(row)), 0, 0)
 	if r0 != 0 {
@@ -238,6 +261,50 @@ func getUnicastIPAddressTable(family AddressFamily, table **mibUnicastIPAddressT
 	return
 }
 
+func icmp6CreateFile() (handle windows.Handle, err error) {
+	r0, _, e1 := syscall.Syscall(procIcmp6CreateFile.Addr(), 0, 0, 0, 0)
+	handle = windows.Handle(r0)
+	if handle == windows.InvalidHandle {
+		err = errnoErr(e1)
+	}
+	return
+}
+
+func icmp6SendEcho2(handle windows.Handle, event windows.Handle, apcRoutine uintptr, apcContext uintptr, sourceAddress *windows.RawSockaddrInet6, destinationAddress *windows.RawSockaddrInet6, requestData *byte, requestSize uint16, requestOptions *ipOptionInformation, replyBuffer *byte, replySize uint32, timeout uint32) (replies uint32, err error) {
+	r0, _, e1 := syscall.Syscall12(procIcmp6SendEcho2.Addr(), 12, uintptr(handle), uintptr(event), uintptr(apcRoutine), uintptr(apcContext), uintptr(unsafe.Pointer(sourceAddress)), uintptr(unsafe.Pointer(destinationAddress)), uintptr(unsafe.Pointer(requestData)), uintptr(requestSize), uintptr(unsafe.Pointer(requestOptions)), uintptr(unsafe.Pointer(replyBuffer)), uintptr(replySize), uintptr(timeout))
+	replies = uint32(r0)
+	if replies == 0 {
+		err = errnoErr(e1)
+	}
+	return
+}
+
+func icmpCloseHandle(handle windows.Handle) (err error) {
+	r1, _, e1 := syscall.Syscall(procIcmpCloseHandle.Addr(), 1, uintptr(handle), 0, 0)
+	if r1 == 0 {
+		err = errnoErr(e1)
+	}
+	return
+}
+
+func icmpCreateFile() (handle windows.Handle, err error) {
+	r0, _, e1 := syscall.Syscall(procIcmpCreateFile.Addr(), 0, 0, 0, 0)
+	handle = windows.Handle(r0)
+	if handle == windows.InvalidHandle {
+		err = errnoErr(e1)
+	}
+	return
+}
+
+func icmpSendEcho2Ex(handle windows.Handle, event windows.Handle, apcRoutine uintptr, apcContext uintptr, sourceAddress uint32, destinationAddress uint32, requestData *byte, requestSize uint16, requestOptions *ipOptionInformation, replyBuffer *byte, replySize uint32, timeout uint32) (replies uint32, err error) {
+	r0, _, e1 := syscall.Syscall12(procIcmpSendEcho2Ex.Addr(), 12, uintptr(handle), uintptr(event), uintptr(apcRoutine), uintptr(apcContext), uintptr(sourceAddress), uintptr(destinationAddress), uintptr(unsafe.Pointer(requestData)), uintptr(requestSize), uintptr(unsafe.Pointer(requestOptions)), uintptr(unsafe.Pointer(replyBuffer)), uintptr(replySize), uintptr(timeout))
+	replies = uint32(r0)
+	if replies == 0 {
+		err = errnoErr(e1)
+	}
+	return
+}
+
 func initializeIPForwardEntry(route *MibIPforwardRow2) {
 	syscall.Syscall(procInitializeIpForwardEntry.Addr(), 1, uintptr(unsafe.Pointer(route)), 0, 0)
 	return
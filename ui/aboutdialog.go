@@ -14,7 +14,9 @@ import (
 	"golang.org/x/sys/windows"
 	"golang.zx2c4.com/wireguard/windows/driver"
 
+	"golang.zx2c4.com/wireguard/windows/conf"
 	"golang.zx2c4.com/wireguard/windows/l18n"
+	"golang.zx2c4.com/wireguard/windows/manager"
 	"golang.zx2c4.com/wireguard/windows/version"
 )
 
@@ -98,7 +100,29 @@ func runAboutDialog(owner walk.Form) error {
 		return err
 	}
 	detailsLbl.SetTextAlignment(walk.AlignHCenterVNear)
-	detailsLbl.SetText(l18n.Sprintf("App version: %s\nDriver version: %s\nGo version: %s\nOperating system: %s\nArchitecture: %s", version.Number, driver.Version(), strings.TrimPrefix(runtime.Version(), "go"), version.OsName(), version.Arch()))
+	details := l18n.Sprintf("App version: %s\nDriver version: %s\nGo version: %s\nOperating system: %s\nArchitecture: %s", version.Number, driver.Version(), strings.TrimPrefix(runtime.Version(), "go"), version.OsName(), version.Arch())
+	detailsLbl.SetText(details)
+
+	if info, err := manager.IPCClientDriverInfo(); err == nil {
+		driverLbl, err := walk.NewTextLabel(showingAboutDialog)
+		if err != nil {
+			return err
+		}
+		driverLbl.SetTextAlignment(walk.AlignHCenterVNear)
+		running := info.Version
+		if len(running) == 0 {
+			running = l18n.Sprintf("not loaded")
+		}
+		lines := []string{
+			l18n.Sprintf("Running driver: %s", running),
+			l18n.Sprintf("Driver features: %s", strings.Join(info.Features, ", ")),
+		}
+		for _, adapter := range info.Adapters {
+			lines = append(lines, l18n.Sprintf("%s: LUID %d, GUID %s", adapter.Tunnel, adapter.LUID, adapter.GUID.String()))
+		}
+		driverLbl.SetText(strings.Join(lines, "\n"))
+		details += "\n" + driverLbl.Text()
+	}
 
 	copyrightLbl, err := walk.NewTextLabel(showingAboutDialog)
 	if err != nil {
@@ -109,6 +133,40 @@ func runAboutDialog(owner walk.Form) error {
 	copyrightLbl.SetTextAlignment(walk.AlignHCenterVNear)
 	copyrightLbl.SetText("Copyright © 2015-2022 Jason A. Donenfeld. All Rights Reserved.")
 
+	if IsAdmin {
+		if channel, byPolicy, err := manager.IPCClientUpdateChannel(); err == nil {
+			betaCB, err := walk.NewCheckBox(showingAboutDialog)
+			if err != nil {
+				return err
+			}
+			betaCB.SetAlignment(walk.AlignHCenterVNear)
+			betaCB.SetText(l18n.Sprintf("Receive &beta releases"))
+			betaCB.SetChecked(channel == conf.UpdateChannelBeta)
+			betaCB.SetEnabled(!byPolicy)
+			if byPolicy {
+				betaCB.SetToolTipText(l18n.Sprintf("Managed by your organization"))
+			}
+			betaCB.CheckedChanged().Attach(func() {
+				channel := conf.UpdateChannelStable
+				if betaCB.Checked() {
+					channel = conf.UpdateChannelBeta
+				}
+				if err := manager.IPCClientSetUpdateChannel(channel); err != nil {
+					showErrorCustom(showingAboutDialog, l18n.Sprintf("Unable to change update channel"), err.Error())
+				}
+			})
+		}
+		proxyPB, err := walk.NewPushButton(showingAboutDialog)
+		if err != nil {
+			return err
+		}
+		proxyPB.SetAlignment(walk.AlignHCenterVNear)
+		proxyPB.SetText(l18n.Sprintf("Update &proxy…"))
+		proxyPB.Clicked().Attach(func() {
+			onUpdateProxy(showingAboutDialog)
+		})
+	}
+
 	buttonCP, err := walk.NewComposite(showingAboutDialog)
 	if err != nil {
 		return err
@@ -124,6 +182,15 @@ func runAboutDialog(owner walk.Form) error {
 	closePB.SetAlignment(walk.AlignHCenterVNear)
 	closePB.SetText(l18n.Sprintf("Close"))
 	closePB.Clicked().Attach(showingAboutDialog.Accept)
+	copyPB, err := walk.NewPushButton(buttonCP)
+	if err != nil {
+		return err
+	}
+	copyPB.SetAlignment(walk.AlignHCenterVNear)
+	copyPB.SetText(l18n.Sprintf("Co&py details"))
+	copyPB.Clicked().Attach(func() {
+		walk.Clipboard().SetText(details)
+	})
 	donatePB, err := walk.NewPushButton(buttonCP)
 	if err != nil {
 		return err
@@ -0,0 +1,49 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"strings"
+
+	"golang.zx2c4.com/wireguard/windows/driver"
+	"golang.zx2c4.com/wireguard/windows/l18n"
+	"golang.zx2c4.com/wireguard/windows/manager"
+)
+
+// adapterErrorMessage explains a failure to create an adapter by its likely causes, and what to
+// do about them, rather than by the error that Windows gives.
+func adapterErrorMessage() string {
+	var b strings.Builder
+	b.WriteString(l18n.Sprintf("The network adapter of the tunnel could not be created."))
+	diagnosis, err := manager.IPCClientAdapterDiagnosis()
+	if err != nil || len(diagnosis.Problems) == 0 {
+		b.WriteString("\n\n")
+		b.WriteString(l18n.Sprintf("No known cause was found. Please consult the log for more information."))
+		return b.String()
+	}
+	b.WriteString("\n\n")
+	b.WriteString(l18n.Sprintf("Likely causes:"))
+	for _, problem := range diagnosis.Problems {
+		b.WriteString("\n\n• ")
+		switch problem {
+		case driver.AdapterProblemSignature:
+			b.WriteString(l18n.Sprintf("Windows did not accept the signature of the WireGuard driver. Reinstall WireGuard from wireguard.com, and make sure that the system date and time are correct."))
+		case driver.AdapterProblemBlocked:
+			b.WriteString(l18n.Sprintf("The WireGuard driver was blocked by a policy of this computer. Ask your administrator to allow it."))
+		case driver.AdapterProblemMemoryIntegrity:
+			b.WriteString(l18n.Sprintf("Memory integrity is turned on, which refuses drivers that are not compatible with it. If this is a custom build of WireGuard, use an official one, or turn off memory integrity under Core isolation in Windows Security."))
+		case driver.AdapterProblemSecureBoot:
+			b.WriteString(l18n.Sprintf("Secure Boot is turned on, which refuses test-signed drivers. Use an official build of WireGuard, whose driver is signed by Microsoft."))
+		case driver.AdapterProblemSHA2Support:
+			b.WriteString(l18n.Sprintf("This version of Windows cannot check the SHA-2 signature of the driver. Install update KB4474419 from Windows Update and restart."))
+		case driver.AdapterProblemFilterDrivers:
+			b.WriteString(l18n.Sprintf("Network filter drivers of other programs are installed, which sometimes get in the way of new adapters: %s. Try again with these programs, such as antivirus or firewall software, turned off or uninstalled.", strings.Join(diagnosis.FilterDrivers, ", ")))
+		}
+	}
+	b.WriteString("\n\n")
+	b.WriteString(l18n.Sprintf("Please consult the log for more information."))
+	return b.String()
+}
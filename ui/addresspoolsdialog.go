@@ -0,0 +1,182 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/lxn/walk"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/l18n"
+	"golang.zx2c4.com/wireguard/windows/manager"
+)
+
+func onAddressPools(owner walk.Form, tunnel *manager.Tunnel) {
+	showError(runAddressPoolsDialog(owner, tunnel), owner)
+}
+
+func parseAddressPools(text string) ([]netip.Prefix, error) {
+	var pools []netip.Prefix
+	for _, field := range strings.Split(text, ",") {
+		field = strings.TrimSpace(field)
+		if len(field) == 0 {
+			continue
+		}
+		pool, err := netip.ParsePrefix(field)
+		if err != nil {
+			return nil, err
+		}
+		pools = append(pools, pool.Masked())
+	}
+	return pools, nil
+}
+
+// addressPoolsReport lists who holds which address, followed by the collisions, if any.
+func addressPoolsReport(config *conf.Config, pools *conf.AddressPools) string {
+	names := make(map[string]string, len(pools.Allocations))
+	var report strings.Builder
+	if len(pools.Allocations) == 0 {
+		report.WriteString(l18n.Sprintf("No addresses have been allocated yet."))
+		report.WriteString("\r\n")
+	}
+	for _, allocation := range pools.Allocations {
+		if len(allocation.PublicKey) == 0 {
+			report.WriteString(l18n.Sprintf("%s: %s", allocation.Address.String(), allocation.Name))
+			report.WriteString("\r\n")
+			continue
+		}
+		names[allocation.PublicKey] = allocation.Name
+		report.WriteString(l18n.Sprintf("%s: %s (%s)", allocation.Address.String(), allocation.Name, allocation.PublicKey))
+		report.WriteString("\r\n")
+	}
+	for _, collision := range pools.Collisions(config) {
+		owners := make([]string, len(collision.PublicKeys))
+		for i, publicKey := range collision.PublicKeys {
+			switch {
+			case publicKey == "":
+				owners[i] = l18n.Sprintf("this interface")
+			case names[publicKey] != "":
+				owners[i] = names[publicKey]
+			default:
+				owners[i] = publicKey
+			}
+		}
+		report.WriteString("\r\n")
+		report.WriteString(l18n.Sprintf("Collision: %s is held by %s", collision.Address.String(), strings.Join(owners, l18n.EnumerationSeparator())))
+	}
+	return report.String()
+}
+
+func runAddressPoolsDialog(owner walk.Form, tunnel *manager.Tunnel) error {
+	config, err := tunnel.StoredConfig()
+	if err != nil {
+		return err
+	}
+	pools, err := tunnel.AddressPools()
+	if err != nil {
+		return err
+	}
+
+	var disposables walk.Disposables
+	defer disposables.Treat()
+
+	dlg, err := walk.NewDialog(owner)
+	if err != nil {
+		return err
+	}
+	disposables.Add(dlg)
+	dlg.SetTitle(l18n.Sprintf("Address pools: %s", tunnel.Name))
+	vbl := walk.NewVBoxLayout()
+	vbl.SetMargins(walk.Margins{HNear: 10, VNear: 10, HFar: 10, VFar: 10})
+	dlg.SetLayout(vbl)
+	dlg.SetMinMaxSize(walk.Size{Width: 500, Height: 350}, walk.Size{})
+	if icon, err := loadLogoIcon(32); err == nil {
+		dlg.SetIcon(icon)
+	}
+
+	poolsLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return err
+	}
+	poolsLabel.SetText(l18n.Sprintf("&Pools, separated by commas. Without any, clients are given addresses from the subnets of the interface addresses:"))
+	poolsEdit, err := walk.NewLineEdit(dlg)
+	if err != nil {
+		return err
+	}
+	poolStrings := make([]string, len(pools.Pools))
+	for i, pool := range pools.Pools {
+		poolStrings[i] = pool.String()
+	}
+	poolsEdit.SetText(strings.Join(poolStrings, ", "))
+
+	allocationsLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return err
+	}
+	allocationsLabel.SetText(l18n.Sprintf("Allocations:"))
+	allocationsEdit, err := walk.NewTextEdit(dlg)
+	if err != nil {
+		return err
+	}
+	allocationsEdit.SetReadOnly(true)
+	allocationsEdit.SetText(addressPoolsReport(&config, &pools))
+
+	buttonsContainer, err := walk.NewComposite(dlg)
+	if err != nil {
+		return err
+	}
+	hbl := walk.NewHBoxLayout()
+	hbl.SetMargins(walk.Margins{})
+	buttonsContainer.SetLayout(hbl)
+	walk.NewHSpacer(buttonsContainer)
+	saveButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return err
+	}
+	saveButton.SetText(l18n.Sprintf("&Save"))
+	cancelButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return err
+	}
+	cancelButton.SetText(l18n.Sprintf("Cancel"))
+	cancelButton.Clicked().Attach(dlg.Cancel)
+	dlg.SetDefaultButton(saveButton)
+	dlg.SetCancelButton(cancelButton)
+
+	poolsEdit.TextChanged().Attach(func() {
+		newPools, err := parseAddressPools(poolsEdit.Text())
+		if err != nil {
+			return
+		}
+		preview := pools
+		preview.Pools = newPools
+		allocationsEdit.SetText(addressPoolsReport(&config, &preview))
+	})
+	saveButton.Clicked().Attach(func() {
+		newPools, err := parseAddressPools(poolsEdit.Text())
+		if err != nil {
+			showErrorCustom(dlg, l18n.Sprintf("Invalid address pool"), err.Error())
+			return
+		}
+		pools.Pools = newPools
+		err = tunnel.SetAddressPools(&pools)
+		if err != nil {
+			showErrorCustom(dlg, l18n.Sprintf("Unable to save address pools"), err.Error())
+			return
+		}
+		dlg.Accept()
+	})
+
+	applyTheme(dlg)
+
+	disposables.Spare()
+
+	dlg.Run()
+
+	return nil
+}
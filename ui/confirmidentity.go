@@ -0,0 +1,144 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"errors"
+	"strings"
+	"unsafe"
+
+	"github.com/lxn/walk"
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/l18n"
+	"golang.zx2c4.com/wireguard/windows/manager"
+)
+
+const creduiwinEnumerateCurrentUser = 0x200
+
+type credUIInfo struct {
+	size        uint32
+	parent      windows.HWND
+	messageText *uint16
+	captionText *uint16
+	banner      windows.Handle
+}
+
+// confirmIdentity asks the user for the credentials of their own account, be it a password, a PIN,
+// or Windows Hello, and returns them packed, for the manager to verify. It must be called on the
+// UI thread, and returns windows.ERROR_CANCELLED if the user cancels.
+func confirmIdentity(owner walk.Form, tunnelName string) (authPackage uint32, authBuffer []byte, err error) {
+	caption, err := windows.UTF16PtrFromString(l18n.Sprintf("Activate tunnel"))
+	if err != nil {
+		return
+	}
+	message, err := windows.UTF16PtrFromString(l18n.Sprintf("The tunnel ‘%s’ is protected. Please confirm your identity to activate it.", tunnelName))
+	if err != nil {
+		return
+	}
+	info := credUIInfo{
+		parent:      windows.HWND(owner.Handle()),
+		messageText: message,
+		captionText: caption,
+	}
+	info.size = uint32(unsafe.Sizeof(info))
+	var (
+		out     unsafe.Pointer
+		outSize uint32
+		save    int32
+	)
+	err = credUIPromptForWindowsCredentials(&info, 0, &authPackage, nil, 0, &out, &outSize, &save, creduiwinEnumerateCurrentUser)
+	if err != nil {
+		return
+	}
+	packed := unsafe.Slice((*byte)(out), outSize)
+	authBuffer = append([]byte(nil), packed...)
+	for i := range packed {
+		packed[i] = 0
+	}
+	windows.CoTaskMemFree(out)
+	return
+}
+
+// confirmConflicts asks the user whether to activate a tunnel that conflicts with running tunnels,
+// unless the manager is going to refuse it anyway. It must not be called on the UI thread, which it
+// waits for, and returns windows.ERROR_CANCELLED if the user declines.
+func confirmConflicts(owner walk.Form, tunnel *manager.Tunnel) error {
+	if owner == nil || conf.LoadPolicies().RefuseConflictingTunnels {
+		return nil
+	}
+	conflicts, err := tunnel.Conflicts()
+	if err != nil || len(conflicts) == 0 {
+		return nil
+	}
+	details := make([]string, 0, len(conflicts))
+	for _, conflict := range conflicts {
+		details = append(details, "• "+conflict.String())
+	}
+	var ret int
+	done := make(chan struct{})
+	owner.Synchronize(func() {
+		ret = walk.MsgBox(owner, l18n.Sprintf("Activate tunnel"), l18n.Sprintf("The tunnel ‘%s’ conflicts with running tunnels:\n\n%s\n\nActivate it anyway?", tunnel.Name, strings.Join(details, "\n")), walk.MsgBoxYesNo|walk.MsgBoxIconWarning)
+		close(done)
+	})
+	<-done
+	if ret != walk.DlgCmdYes {
+		return windows.ERROR_CANCELLED
+	}
+	return nil
+}
+
+// startTunnel starts a tunnel, first asking the user whether to go ahead if it conflicts with
+// running tunnels, and to confirm their identity if it is protected. It must not be called on the
+// UI thread, which it waits for.
+func startTunnel(owner walk.Form, tunnel *manager.Tunnel) error {
+	err := confirmConflicts(owner, tunnel)
+	if err != nil {
+		return err
+	}
+	protected, err := tunnel.Protected()
+	if err != nil || !protected {
+		return tunnel.Start()
+	}
+	var (
+		authPackage uint32
+		authBuffer  []byte
+		done        = make(chan struct{})
+	)
+	owner.Synchronize(func() {
+		authPackage, authBuffer, err = confirmIdentity(owner, tunnel.Name)
+		close(done)
+	})
+	<-done
+	if err != nil {
+		return err
+	}
+	err = tunnel.StartConfirmed(authPackage, authBuffer)
+	for i := range authBuffer {
+		authBuffer[i] = 0
+	}
+	return err
+}
+
+// toggleTunnel is like manager.Tunnel.Toggle, but asks for confirmation to start a protected
+// tunnel, and treats the user cancelling that as nothing having happened.
+func toggleTunnel(owner walk.Form, tunnel *manager.Tunnel) (oldState manager.TunnelState, err error) {
+	oldState, err = tunnel.State()
+	if err != nil {
+		oldState = manager.TunnelUnknown
+		return
+	}
+	if oldState == manager.TunnelStarted {
+		err = tunnel.Stop()
+	} else if oldState == manager.TunnelStopped {
+		err = startTunnel(owner, tunnel)
+		if errors.Is(err, windows.ERROR_CANCELLED) {
+			err = nil
+		}
+	}
+	return
+}
@@ -52,6 +52,7 @@ type interfaceView struct {
 	dns          *labelTextLine
 	scripts      *labelTextLine
 	table        *labelTextLine
+	killSwitch   *labelTextLine
 	toggleActive *toggleActiveLine
 	lines        []widgetsLine
 }
@@ -67,10 +68,27 @@ type peerView struct {
 	lines               []widgetsLine
 }
 
+// pitfallsView lists the problems that the tunnel service found with the system, each of
+// which may be dismissed until the UI restarts.
+type pitfallsView struct {
+	group     *walk.GroupBox
+	shown     []conf.Pitfall
+	onDismiss func(pitfall conf.Pitfall)
+}
+
+type dismissedPitfall struct {
+	tunnelName string
+	pitfall    conf.Pitfall
+}
+
 type ConfView struct {
 	*walk.ScrollView
+	pitfalls        *pitfallsView
+	dismissed       map[dismissedPitfall]bool
 	name            *walk.GroupBox
 	interfaze       *interfaceView
+	rateGroup       *walk.GroupBox
+	rate            *rateGraph
 	peers           map[conf.Key]*peerView
 	tunnelChangedCB *manager.TunnelChangeCallback
 	tunnel          *manager.Tunnel
@@ -309,6 +327,7 @@ func newInterfaceView(parent walk.Container) (*interfaceView, error) {
 		{l18n.Sprintf("DNS servers:"), &iv.dns},
 		{l18n.Sprintf("Scripts:"), &iv.scripts},
 		{l18n.Sprintf("Table:"), &iv.table},
+		{l18n.Sprintf("Kill switch:"), &iv.killSwitch},
 	}
 	if iv.lines, err = createLabelTextLines(items, parent, &disposables); err != nil {
 		return nil, err
@@ -367,7 +386,7 @@ func (iv *interfaceView) widgetsLines() []widgetsLine {
 	return iv.lines
 }
 
-func (iv *interfaceView) apply(c *conf.Interface) {
+func (iv *interfaceView) apply(c *conf.Interface, lockdown bool) {
 	if IsAdmin {
 		iv.publicKey.show(c.PrivateKey.Public().String())
 	} else {
@@ -435,13 +454,35 @@ func (iv *interfaceView) apply(c *conf.Interface) {
 	} else {
 		iv.table.hide()
 	}
+
+	if lockdown {
+		iv.killSwitch.show(l18n.Sprintf("lockdown active"))
+	} else if conf.LoadPolicies().ForceKillSwitch {
+		iv.killSwitch.show(l18n.Sprintf("enabled, managed by your organization"))
+	} else if c.KillSwitch {
+		iv.killSwitch.show(l18n.Sprintf("enabled"))
+	} else {
+		iv.killSwitch.hide()
+	}
 }
 
 func (pv *peerView) widgetsLines() []widgetsLine {
 	return pv.lines
 }
 
+func (pv *peerView) groupBox() *walk.GroupBox {
+	return pv.publicKey.label.Parent().AsContainerBase().Parent().(*walk.GroupBox)
+}
+
 func (pv *peerView) apply(c *conf.Peer) {
+	title := l18n.Sprintf("Peer")
+	if len(c.Name) > 0 {
+		title = l18n.Sprintf("Peer: %s", c.Name)
+	}
+	if groupBox := pv.groupBox(); groupBox.Title() != title {
+		groupBox.SetTitle(title)
+	}
+
 	if IsAdmin {
 		pv.publicKey.show(c.PublicKey.String())
 	} else {
@@ -489,6 +530,100 @@ func (pv *peerView) apply(c *conf.Peer) {
 	}
 }
 
+func textForPitfall(pitfall conf.Pitfall) string {
+	switch pitfall.Kind {
+	case conf.PitfallDNSCacheDisabled:
+		return l18n.Sprintf("The DNS Client service is disabled, so the DNS servers of the tunnel may not be used. Please re-enable it.")
+	case conf.PitfallOutdatedVirtioDriver:
+		return l18n.Sprintf("The VirtIO network driver (NetKVM) is out of date and may cause known problems. Please update it to v100.85.104.20800 or later.")
+	case conf.PitfallWeakHostSend:
+		return l18n.Sprintf("The “%s” interface has forwarding or weak host send enabled, which will cause routing loops.", pitfall.Detail)
+	case conf.PitfallEndpointPending:
+		return l18n.Sprintf("The endpoint “%s” could not be resolved at boot. Its peer is unreachable until it can be, which is retried in the background.", pitfall.Detail)
+	case conf.PitfallObfuscationUnsupported:
+		return l18n.Sprintf("The configuration has AmneziaWG obfuscation parameters (Jc, Jmin, Jmax, S1, S2, H1–H4), which the driver does not support and ignores. Peers that expect obfuscation will not be reachable.")
+	case conf.PitfallMTUTooLarge:
+		return l18n.Sprintf("Many packets are too big for the path to the peers, which suggests that the MTU is too large. Please try setting “MTU = %s” in the configuration.", pitfall.Detail)
+	case conf.PitfallMTULowered:
+		return l18n.Sprintf("Many packets were too big for the path to the peers, so the MTU was lowered to %s until the tunnel restarts. Consider setting “MTU = %s” in the configuration.", pitfall.Detail, pitfall.Detail)
+	default:
+		return pitfall.String()
+	}
+}
+
+func newPitfallsView(parent walk.Container) (*pitfallsView, error) {
+	group, err := walk.NewGroupBox(parent)
+	if err != nil {
+		return nil, err
+	}
+	layout := walk.NewVBoxLayout()
+	layout.SetMargins(walk.Margins{10, 5, 10, 5})
+	err = group.SetLayout(layout)
+	if err != nil {
+		group.Dispose()
+		return nil, err
+	}
+	group.SetTitle(l18n.Sprintf("Warnings"))
+	group.SetVisible(false)
+	return &pitfallsView{group: group}, nil
+}
+
+func (pv *pitfallsView) apply(pitfalls []conf.Pitfall) {
+	if len(pitfalls) == len(pv.shown) {
+		same := true
+		for i := range pitfalls {
+			if pitfalls[i] != pv.shown[i] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return
+		}
+	}
+	pv.shown = pitfalls
+
+	children := pv.group.Children()
+	for children.Len() > 0 {
+		row := children.At(children.Len() - 1)
+		children.Remove(row)
+		row.Dispose()
+	}
+	for _, pitfall := range pitfalls {
+		pv.addRow(pitfall)
+	}
+	applyThemeToWidget(pv.group)
+	pv.group.SetVisible(len(pitfalls) > 0)
+}
+
+func (pv *pitfallsView) addRow(pitfall conf.Pitfall) {
+	row, err := walk.NewComposite(pv.group)
+	if err != nil {
+		return
+	}
+	layout := walk.NewHBoxLayout()
+	layout.SetMargins(walk.Margins{})
+	row.SetLayout(layout)
+
+	image, err := walk.NewImageView(row)
+	if err == nil {
+		image.SetMode(walk.ImageViewModeShrink)
+		image.SetMinMaxSize(walk.Size{16, 16}, walk.Size{16, 16})
+		image.SetImage(walk.IconWarning())
+	}
+	label, err := walk.NewTextLabel(row)
+	if err == nil {
+		label.SetText(textForPitfall(pitfall))
+	}
+	button, err := walk.NewPushButton(row)
+	if err == nil {
+		button.SetText(l18n.Sprintf("Dismiss"))
+		button.Clicked().Attach(func() {
+			pv.onDismiss(pitfall)
+		})
+	}
+}
+
 func newPaddedGroupGrid(parent walk.Container) (group *walk.GroupBox, err error) {
 	group, err = walk.NewGroupBox(parent)
 	if err != nil {
@@ -527,6 +662,11 @@ func NewConfView(parent walk.Container) (*ConfView, error) {
 	vlayout := walk.NewVBoxLayout()
 	vlayout.SetMargins(walk.Margins{5, 0, 5, 0})
 	cv.SetLayout(vlayout)
+	if cv.pitfalls, err = newPitfallsView(cv); err != nil {
+		return nil, err
+	}
+	cv.dismissed = make(map[dismissedPitfall]bool)
+	cv.pitfalls.onDismiss = cv.onDismissPitfall
 	if cv.name, err = newPaddedGroupGrid(cv); err != nil {
 		return nil, err
 	}
@@ -534,6 +674,16 @@ func NewConfView(parent walk.Container) (*ConfView, error) {
 		return nil, err
 	}
 	cv.interfaze.toggleActive.button.Clicked().Attach(cv.onToggleActiveClicked)
+	if cv.rateGroup, err = walk.NewGroupBox(cv); err != nil {
+		return nil, err
+	}
+	cv.rateGroup.SetTitle(l18n.Sprintf("Transfer rate"))
+	rateLayout := walk.NewVBoxLayout()
+	rateLayout.SetMargins(walk.Margins{10, 5, 10, 5})
+	cv.rateGroup.SetLayout(rateLayout)
+	if cv.rate, err = newRateGraph(cv.rateGroup); err != nil {
+		return nil, err
+	}
 	cv.peers = make(map[conf.Key]*peerView)
 	cv.tunnelChangedCB = manager.IPCClientRegisterTunnelChange(cv.onTunnelChanged)
 	cv.SetTunnel(nil)
@@ -563,8 +713,10 @@ func NewConfView(parent walk.Container) (*ConfView, error) {
 				if config.Name == "" {
 					config, _ = tunnel.StoredConfig()
 				}
+				lockdown, _ := tunnel.KillSwitchActive()
+				pitfalls, _ := tunnel.Pitfalls()
 				cv.Synchronize(func() {
-					cv.setTunnel(tunnel, &config, state)
+					cv.setTunnel(tunnel, &config, state, lockdown, pitfalls)
 				})
 			}
 		}
@@ -590,7 +742,7 @@ func (cv *ConfView) Dispose() {
 func (cv *ConfView) onToggleActiveClicked() {
 	cv.interfaze.toggleActive.button.SetEnabled(false)
 	go func() {
-		oldState, err := cv.tunnel.Toggle()
+		oldState, err := toggleTunnel(cv.Form(), cv.tunnel)
 		if err != nil {
 			cv.Synchronize(func() {
 				if oldState == manager.TunnelUnknown {
@@ -621,12 +773,31 @@ func (cv *ConfView) onTunnelChanged(tunnel *manager.Tunnel, state, globalState m
 		if config.Name == "" {
 			config, _ = tunnel.StoredConfig()
 		}
+		lockdown, _ := tunnel.KillSwitchActive()
+		pitfalls, _ := tunnel.Pitfalls()
 		cv.Synchronize(func() {
-			cv.setTunnel(tunnel, &config, state)
+			cv.setTunnel(tunnel, &config, state, lockdown, pitfalls)
 		})
 	}
 }
 
+func (cv *ConfView) onDismissPitfall(pitfall conf.Pitfall) {
+	if cv.tunnel == nil {
+		return
+	}
+	cv.dismissed[dismissedPitfall{cv.tunnel.Name, pitfall}] = true
+	shownPitfalls := make([]conf.Pitfall, 0, len(cv.pitfalls.shown))
+	for _, shown := range cv.pitfalls.shown {
+		if shown != pitfall {
+			shownPitfalls = append(shownPitfalls, shown)
+		}
+	}
+	// The button that was clicked is disposed of along with its row, so wait for its handler to return.
+	cv.Synchronize(func() {
+		cv.pitfalls.apply(shownPitfalls)
+	})
+}
+
 func (cv *ConfView) SetTunnel(tunnel *manager.Tunnel) {
 	cv.tunnel = tunnel // XXX: This races with the read in the updateTicker, but it's pointer-sized!
 
@@ -640,20 +811,32 @@ func (cv *ConfView) SetTunnel(tunnel *manager.Tunnel) {
 			if config.Name == "" {
 				config, _ = tunnel.StoredConfig()
 			}
+			lockdown, _ := tunnel.KillSwitchActive()
+			pitfalls, _ := tunnel.Pitfalls()
 			cv.Synchronize(func() {
-				cv.setTunnel(tunnel, &config, state)
+				cv.setTunnel(tunnel, &config, state, lockdown, pitfalls)
 			})
 		}()
 	} else {
-		cv.setTunnel(tunnel, &config, state)
+		cv.setTunnel(tunnel, &config, state, false, nil)
 	}
 }
 
-func (cv *ConfView) setTunnel(tunnel *manager.Tunnel, config *conf.Config, state manager.TunnelState) {
+func (cv *ConfView) setTunnel(tunnel *manager.Tunnel, config *conf.Config, state manager.TunnelState, lockdown bool, pitfalls []conf.Pitfall) {
 	if !(cv.tunnel == nil || tunnel == nil || tunnel.Name == cv.tunnel.Name) {
 		return
 	}
 
+	var shownPitfalls []conf.Pitfall
+	if tunnel != nil {
+		for _, pitfall := range pitfalls {
+			if !cv.dismissed[dismissedPitfall{tunnel.Name, pitfall}] {
+				shownPitfalls = append(shownPitfalls, pitfall)
+			}
+		}
+	}
+	cv.pitfalls.apply(shownPitfalls)
+
 	title := l18n.Sprintf("Interface: %s", config.Name)
 	if cv.name.Title() != title {
 		cv.SetSuspended(true)
@@ -661,8 +844,12 @@ func (cv *ConfView) setTunnel(tunnel *manager.Tunnel, config *conf.Config, state
 		cv.name.SetTitle(title)
 	}
 	cv.name.SetVisible(tunnel != nil)
+	if tunnel != nil {
+		cv.rate.setTunnel(tunnel.Name)
+	}
+	cv.rateGroup.SetVisible(tunnel != nil && state == manager.TunnelStarted)
 
-	cv.interfaze.apply(&config.Interface)
+	cv.interfaze.apply(&config.Interface, lockdown)
 	cv.interfaze.status.update(state)
 	cv.interfaze.toggleActive.update(state)
 	inverse := make(map[*peerView]bool, len(cv.peers))
@@ -698,13 +885,13 @@ func (cv *ConfView) setTunnel(tunnel *manager.Tunnel, config *conf.Config, state
 			if err != nil {
 				continue
 			}
-			group.SetTitle(l18n.Sprintf("Peer"))
 			pv, err := newPeerView(group)
 			if err != nil {
 				group.Dispose()
 				continue
 			}
 			pv.apply(&peer)
+			applyThemeToWidget(group)
 			cv.peers[peer.PublicKey] = pv
 		}
 	}
@@ -717,7 +904,7 @@ func (cv *ConfView) setTunnel(tunnel *manager.Tunnel, config *conf.Config, state
 			continue
 		}
 		delete(cv.peers, *k)
-		groupBox := pv.publicKey.label.Parent().AsContainerBase().Parent().(*walk.GroupBox)
+		groupBox := pv.groupBox()
 		groupBox.SetVisible(false)
 		groupBox.Parent().Children().Remove(groupBox)
 		groupBox.Dispose()
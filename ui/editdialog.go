@@ -6,6 +6,7 @@
 package ui
 
 import (
+	"errors"
 	"net/netip"
 	"strings"
 
@@ -24,27 +25,36 @@ type EditDialog struct {
 	nameEdit                        *walk.LineEdit
 	pubkeyEdit                      *walk.LineEdit
 	syntaxEdit                      *syntax.SyntaxEdit
+	validationLabel                 *walk.TextLabel
+	validationMessage               string
+	lintMessage                     string
 	blockUntunneledTrafficCB        *walk.CheckBox
+	excludeAddressesButton          *walk.PushButton
+	onUntrustedWiFiCB               *walk.CheckBox
+	onEthernetCB                    *walk.CheckBox
+	trustedSSIDsEdit                *walk.LineEdit
 	saveButton                      *walk.PushButton
 	config                          conf.Config
+	rules                           conf.ActivationRules
 	lastPrivateKey                  string
 	blockUntunneledTraficCheckGuard bool
 }
 
-func runEditDialog(owner walk.Form, tunnel *manager.Tunnel) *conf.Config {
-	dlg, err := newEditDialog(owner, tunnel)
+// runEditDialog edits the tunnel, or creates a new one, starting from the template if there is one.
+func runEditDialog(owner walk.Form, tunnel *manager.Tunnel, template *conf.Config) (*conf.Config, *conf.ActivationRules) {
+	dlg, err := newEditDialog(owner, tunnel, template)
 	if showError(err, owner) {
-		return nil
+		return nil, nil
 	}
 
 	if dlg.Run() == walk.DlgCmdOK {
-		return &dlg.config
+		return &dlg.config, &dlg.rules
 	}
 
-	return nil
+	return nil, nil
 }
 
-func newEditDialog(owner walk.Form, tunnel *manager.Tunnel) (*EditDialog, error) {
+func newEditDialog(owner walk.Form, tunnel *manager.Tunnel, template *conf.Config) (*EditDialog, error) {
 	var err error
 	var disposables walk.Disposables
 	defer disposables.Treat()
@@ -58,12 +68,18 @@ func newEditDialog(owner walk.Form, tunnel *manager.Tunnel) (*EditDialog, error)
 		title = l18n.Sprintf("Edit tunnel")
 	}
 
-	if tunnel == nil {
+	if tunnel == nil && template != nil {
+		dlg.config = *template
+	} else if tunnel == nil {
 		// Creating a new tunnel, create a new private key and use the default template
 		pk, _ := conf.NewPrivateKey()
 		dlg.config = conf.Config{Interface: conf.Interface{PrivateKey: *pk}}
+		if settings, err := manager.IPCClientSettings(); err == nil {
+			dlg.config.Interface.KillSwitch = settings.DefaultKillSwitch
+		}
 	} else {
 		dlg.config, _ = tunnel.StoredConfig()
+		dlg.rules, _ = tunnel.ActivationRules()
 	}
 
 	layout := walk.NewGridLayout()
@@ -117,12 +133,60 @@ func newEditDialog(owner walk.Form, tunnel *manager.Tunnel) (*EditDialog, error)
 		return nil, err
 	}
 	layout.SetRange(dlg.syntaxEdit, walk.Rectangle{0, 2, 2, 1})
+	dlg.syntaxEdit.SetToolTipText(l18n.Sprintf("Press Ctrl+Space to complete the key being typed."))
+
+	if dlg.validationLabel, err = walk.NewTextLabel(dlg); err != nil {
+		return nil, err
+	}
+	layout.SetRange(dlg.validationLabel, walk.Rectangle{0, 3, 2, 1})
+	dlg.validationLabel.SetVisible(false)
+
+	activationGroup, err := walk.NewGroupBox(dlg)
+	if err != nil {
+		return nil, err
+	}
+	layout.SetRange(activationGroup, walk.Rectangle{0, 4, 2, 1})
+	activationGroup.SetTitle(l18n.Sprintf("On-demand activation"))
+	activationGroup.SetLayout(walk.NewVBoxLayout())
+
+	if dlg.onUntrustedWiFiCB, err = walk.NewCheckBox(activationGroup); err != nil {
+		return nil, err
+	}
+	dlg.onUntrustedWiFiCB.SetText(l18n.Sprintf("Activate on &untrusted Wi-Fi networks"))
+	dlg.onUntrustedWiFiCB.SetChecked(dlg.rules.OnUntrustedWiFi)
+	dlg.onUntrustedWiFiCB.CheckedChanged().Attach(func() {
+		dlg.trustedSSIDsEdit.SetEnabled(dlg.onUntrustedWiFiCB.Checked())
+	})
+
+	trustedSSIDsContainer, err := walk.NewComposite(activationGroup)
+	if err != nil {
+		return nil, err
+	}
+	trustedSSIDsContainer.SetLayout(walk.NewHBoxLayout())
+	trustedSSIDsContainer.Layout().SetMargins(walk.Margins{})
+	trustedSSIDsLabel, err := walk.NewTextLabel(trustedSSIDsContainer)
+	if err != nil {
+		return nil, err
+	}
+	trustedSSIDsLabel.SetText(l18n.Sprintf("&Trusted SSIDs:"))
+	if dlg.trustedSSIDsEdit, err = walk.NewLineEdit(trustedSSIDsContainer); err != nil {
+		return nil, err
+	}
+	dlg.trustedSSIDsEdit.SetText(strings.Join(dlg.rules.TrustedSSIDs, ", "))
+	dlg.trustedSSIDsEdit.SetToolTipText(l18n.Sprintf("A comma-separated list of Wi-Fi networks on which the tunnel is deactivated."))
+	dlg.trustedSSIDsEdit.SetEnabled(dlg.rules.OnUntrustedWiFi)
+
+	if dlg.onEthernetCB, err = walk.NewCheckBox(activationGroup); err != nil {
+		return nil, err
+	}
+	dlg.onEthernetCB.SetText(l18n.Sprintf("Activate on &Ethernet networks"))
+	dlg.onEthernetCB.SetChecked(dlg.rules.OnEthernet)
 
 	buttonsContainer, err := walk.NewComposite(dlg)
 	if err != nil {
 		return nil, err
 	}
-	layout.SetRange(buttonsContainer, walk.Rectangle{0, 3, 2, 1})
+	layout.SetRange(buttonsContainer, walk.Rectangle{0, 5, 2, 1})
 	buttonsContainer.SetLayout(walk.NewHBoxLayout())
 	buttonsContainer.Layout().SetMargins(walk.Margins{})
 
@@ -134,6 +198,13 @@ func newEditDialog(owner walk.Form, tunnel *manager.Tunnel) (*EditDialog, error)
 	dlg.blockUntunneledTrafficCB.SetVisible(false)
 	dlg.blockUntunneledTrafficCB.CheckedChanged().Attach(dlg.onBlockUntunneledTrafficCBCheckedChanged)
 
+	if dlg.excludeAddressesButton, err = walk.NewPushButton(buttonsContainer); err != nil {
+		return nil, err
+	}
+	dlg.excludeAddressesButton.SetText(l18n.Sprintf("E&xclude addresses…"))
+	dlg.excludeAddressesButton.SetToolTipText(l18n.Sprintf("Takes addresses, such as those of the local network, out of the allowed IPs of the peers."))
+	dlg.excludeAddressesButton.Clicked().Attach(dlg.onExcludeAddressesButtonClicked)
+
 	walk.NewHSpacer(buttonsContainer)
 
 	if dlg.saveButton, err = walk.NewPushButton(buttonsContainer); err != nil {
@@ -154,6 +225,9 @@ func newEditDialog(owner walk.Form, tunnel *manager.Tunnel) (*EditDialog, error)
 
 	dlg.syntaxEdit.PrivateKeyChanged().Attach(dlg.onSyntaxEditPrivateKeyChanged)
 	dlg.syntaxEdit.BlockUntunneledTrafficStateChanged().Attach(dlg.onBlockUntunneledTrafficStateChanged)
+	dlg.syntaxEdit.ValidationChanged().Attach(dlg.onSyntaxEditValidationChanged)
+	dlg.syntaxEdit.TextChanged().Attach(dlg.onSyntaxEditTextChanged)
+	dlg.syntaxEdit.SetValidator(validateConfig)
 	dlg.syntaxEdit.SetText(dlg.config.ToWgQuick())
 
 	// Insert a dummy label immediately preceding syntaxEdit to have screen readers read it.
@@ -176,6 +250,8 @@ func newEditDialog(owner walk.Form, tunnel *manager.Tunnel) (*EditDialog, error)
 		})
 	}
 
+	applyTheme(dlg)
+
 	disposables.Spare()
 
 	return dlg, nil
@@ -280,6 +356,26 @@ err:
 	dlg.syntaxEdit.SetText(text)
 }
 
+func (dlg *EditDialog) onExcludeAddressesButtonClicked() {
+	cfg, err := conf.FromWgQuick(dlg.syntaxEdit.Text(), "temporary")
+	if err != nil {
+		showErrorCustom(dlg, l18n.Sprintf("Unable to exclude addresses"), err.Error())
+		return
+	}
+	if len(cfg.Peers) == 0 {
+		showWarningCustom(dlg, l18n.Sprintf("Unable to exclude addresses"), l18n.Sprintf("The configuration has no peers."))
+		return
+	}
+	excluded, ok, err := runExcludeAddressesDialog(dlg)
+	if showError(err, dlg) || !ok {
+		return
+	}
+	for i := range cfg.Peers {
+		cfg.Peers[i].AllowedIPs = conf.CalculateAllowedIPs(cfg.Peers[i].AllowedIPs, excluded)
+	}
+	dlg.syntaxEdit.SetText(cfg.ToWgQuick())
+}
+
 func (dlg *EditDialog) onBlockUntunneledTrafficStateChanged(state int) {
 	dlg.blockUntunneledTraficCheckGuard = true
 	switch syntax.BlockState(state) {
@@ -308,6 +404,45 @@ func (dlg *EditDialog) onSyntaxEditPrivateKeyChanged(privateKey string) {
 	}
 }
 
+// validateConfig parses the configuration as saving it would, for the editor to point at what
+// keeps it from being saved.
+func validateConfig(config string) (int, int, error) {
+	_, err := conf.FromWgQuick(config, "temporary")
+	var parseErr *conf.ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.Line, parseErr.Column, err
+	}
+	return 0, 0, err
+}
+
+func (dlg *EditDialog) onSyntaxEditValidationChanged(message string) {
+	dlg.validationMessage = message
+	dlg.updateValidationLabel()
+}
+
+// onSyntaxEditTextChanged lints the configuration, whose warnings are shown while there is no
+// error to show instead.
+func (dlg *EditDialog) onSyntaxEditTextChanged() {
+	dlg.lintMessage = ""
+	if cfg, err := conf.FromWgQuick(dlg.syntaxEdit.Text(), "temporary"); err == nil {
+		var messages []string
+		for _, warning := range cfg.Lint() {
+			messages = append(messages, warning.String())
+		}
+		dlg.lintMessage = strings.Join(messages, "\n")
+	}
+	dlg.updateValidationLabel()
+}
+
+func (dlg *EditDialog) updateValidationLabel() {
+	message := dlg.validationMessage
+	if len(message) == 0 {
+		message = dlg.lintMessage
+	}
+	dlg.validationLabel.SetText(message)
+	dlg.validationLabel.SetVisible(len(message) > 0)
+}
+
 func (dlg *EditDialog) onSaveButtonClicked() {
 	newName := dlg.nameEdit.Text()
 	if newName == "" {
@@ -341,5 +476,14 @@ func (dlg *EditDialog) onSaveButtonClicked() {
 	}
 
 	dlg.config = *cfg
+	dlg.rules = conf.ActivationRules{
+		OnUntrustedWiFi: dlg.onUntrustedWiFiCB.Checked(),
+		OnEthernet:      dlg.onEthernetCB.Checked(),
+	}
+	for _, ssid := range strings.Split(dlg.trustedSSIDsEdit.Text(), ",") {
+		if ssid = strings.TrimSpace(ssid); len(ssid) > 0 {
+			dlg.rules.TrustedSSIDs = append(dlg.rules.TrustedSSIDs, ssid)
+		}
+	}
 	dlg.Accept()
 }
@@ -0,0 +1,113 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/lxn/walk"
+
+	"golang.zx2c4.com/wireguard/windows/l18n"
+)
+
+// runExcludeAddressesDialog asks for the addresses and prefixes to take out of the allowed IPs
+// of the peers.
+func runExcludeAddressesDialog(owner walk.Form) (excluded []netip.Prefix, ok bool, err error) {
+	var disposables walk.Disposables
+	defer disposables.Treat()
+
+	dlg, err := walk.NewDialog(owner)
+	if err != nil {
+		return
+	}
+	disposables.Add(dlg)
+	dlg.SetTitle(l18n.Sprintf("Exclude addresses"))
+	layout := walk.NewGridLayout()
+	layout.SetSpacing(6)
+	layout.SetMargins(walk.Margins{HNear: 10, VNear: 10, HFar: 10, VFar: 10})
+	dlg.SetLayout(layout)
+	if icon, err := loadLogoIcon(32); err == nil {
+		dlg.SetIcon(icon)
+	}
+
+	excludedLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return
+	}
+	layout.SetRange(excludedLabel, walk.Rectangle{X: 0, Y: 0, Width: 1, Height: 1})
+	excludedLabel.SetTextAlignment(walk.AlignHFarVCenter)
+	excludedLabel.SetText(l18n.Sprintf("&Addresses:"))
+	excludedEdit, err := walk.NewLineEdit(dlg)
+	if err != nil {
+		return
+	}
+	layout.SetRange(excludedEdit, walk.Rectangle{X: 1, Y: 0, Width: 1, Height: 1})
+	excludedEdit.SetMinMaxSize(walk.Size{Width: 350}, walk.Size{})
+	excludedEdit.SetCueBanner("10.0.0.0/8, 192.168.0.0/16")
+
+	hintLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return
+	}
+	layout.SetRange(hintLabel, walk.Rectangle{X: 0, Y: 1, Width: 2, Height: 1})
+	hintLabel.SetMinMaxSize(walk.Size{Width: 400}, walk.Size{Width: 400})
+	hintLabel.SetText(l18n.Sprintf("The allowed IPs of each peer are replaced with the fewest prefixes that cover them except for these comma-separated addresses and prefixes, so that other WireGuard clients understand the result too."))
+
+	buttonsContainer, err := walk.NewComposite(dlg)
+	if err != nil {
+		return
+	}
+	layout.SetRange(buttonsContainer, walk.Rectangle{X: 0, Y: 2, Width: 2, Height: 1})
+	hbl := walk.NewHBoxLayout()
+	hbl.SetMargins(walk.Margins{})
+	buttonsContainer.SetLayout(hbl)
+	walk.NewHSpacer(buttonsContainer)
+	excludeButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return
+	}
+	excludeButton.SetText(l18n.Sprintf("&Exclude"))
+	cancelButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return
+	}
+	cancelButton.SetText(l18n.Sprintf("Cancel"))
+	cancelButton.Clicked().Attach(dlg.Cancel)
+	dlg.SetDefaultButton(excludeButton)
+	dlg.SetCancelButton(cancelButton)
+
+	excludeButton.Clicked().Attach(func() {
+		excluded = excluded[:0]
+		for _, address := range strings.Split(excludedEdit.Text(), ",") {
+			if address = strings.TrimSpace(address); len(address) == 0 {
+				continue
+			}
+			prefix, err := netip.ParsePrefix(address)
+			if err != nil {
+				addr, err2 := netip.ParseAddr(address)
+				if err2 != nil {
+					showErrorCustom(dlg, l18n.Sprintf("Invalid address"), l18n.Sprintf("‘%s’ is neither an address nor a prefix.", address))
+					return
+				}
+				prefix = netip.PrefixFrom(addr, addr.BitLen())
+			}
+			excluded = append(excluded, prefix)
+		}
+		if len(excluded) == 0 {
+			showErrorCustom(dlg, l18n.Sprintf("Invalid address"), l18n.Sprintf("Enter at least one address or prefix to exclude."))
+			return
+		}
+		dlg.Accept()
+	})
+
+	applyTheme(dlg)
+
+	disposables.Spare()
+
+	ok = dlg.Run() == walk.DlgCmdOK
+	return
+}
@@ -0,0 +1,257 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/lxn/walk"
+	"github.com/lxn/win"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+
+	"golang.zx2c4.com/wireguard/windows/l18n"
+	"golang.zx2c4.com/wireguard/windows/manager"
+)
+
+// Each user may mark one tunnel as their favorite, which a click on the tray icon and a global
+// hotkey toggle. Like the onboarding, this is remembered per user. The hotkey is only registered
+// while there is a favorite, so that it is not taken from other programs for nothing.
+
+const (
+	favoriteRegKey            = `Software\WireGuard`
+	favoriteTunnelRegValue    = "FavoriteTunnel"
+	favoriteHotkeyRegValue    = "FavoriteHotkey"
+	favoriteTrayClickRegValue = "TrayClickTogglesFavorite"
+	defaultFavoriteHotkey     = "Ctrl+Alt+W"
+	favoriteHotkeyID          = 1
+)
+
+const (
+	hotkeyModifierAlt            = 0x1
+	hotkeyModifierControl        = 0x2
+	hotkeyModifierShift          = 0x4
+	hotkeyModifierWin            = 0x8
+	hotkeyModifierNoRepeat       = 0x4000
+	hotkeyVirtualKeyF1           = 0x70
+	hotkeyVirtualKeyFunctionKeys = 24
+)
+
+type hotkey struct {
+	modifiers  uint32
+	virtualKey uint32
+}
+
+func (h hotkey) IsEmpty() bool {
+	return h.virtualKey == 0
+}
+
+func (h hotkey) String() string {
+	if h.IsEmpty() {
+		return ""
+	}
+	var parts []string
+	for _, modifier := range [...]struct {
+		flag uint32
+		name string
+	}{
+		{hotkeyModifierControl, "Ctrl"},
+		{hotkeyModifierAlt, "Alt"},
+		{hotkeyModifierShift, "Shift"},
+		{hotkeyModifierWin, "Win"},
+	} {
+		if h.modifiers&modifier.flag != 0 {
+			parts = append(parts, modifier.name)
+		}
+	}
+	if h.virtualKey >= hotkeyVirtualKeyF1 && h.virtualKey < hotkeyVirtualKeyF1+hotkeyVirtualKeyFunctionKeys {
+		parts = append(parts, "F"+strconv.Itoa(int(h.virtualKey-hotkeyVirtualKeyF1+1)))
+	} else {
+		parts = append(parts, string(rune(h.virtualKey)))
+	}
+	return strings.Join(parts, "+")
+}
+
+// parseHotkey parses modifiers and a key, such as Ctrl+Alt+W, in any case. Letters and digits
+// need at least one modifier, so as not to take them from typing; function keys do not. An empty
+// string is no hotkey.
+func parseHotkey(s string) (h hotkey, err error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return
+	}
+	invalid := errors.New(l18n.Sprintf("The hotkey must be given as modifiers and a letter, digit, or function key, such as %s.", defaultFavoriteHotkey))
+	parts := strings.Split(s, "+")
+	for _, part := range parts[:len(parts)-1] {
+		switch strings.ToLower(strings.TrimSpace(part)) {
+		case "ctrl", "control":
+			h.modifiers |= hotkeyModifierControl
+		case "alt":
+			h.modifiers |= hotkeyModifierAlt
+		case "shift":
+			h.modifiers |= hotkeyModifierShift
+		case "win", "windows":
+			h.modifiers |= hotkeyModifierWin
+		default:
+			return hotkey{}, invalid
+		}
+	}
+	key := strings.ToUpper(strings.TrimSpace(parts[len(parts)-1]))
+	if len(key) == 1 && ((key[0] >= 'A' && key[0] <= 'Z') || (key[0] >= '0' && key[0] <= '9')) {
+		if h.modifiers == 0 {
+			return hotkey{}, invalid
+		}
+		h.virtualKey = uint32(key[0])
+	} else if n, err := strconv.Atoi(strings.TrimPrefix(key, "F")); err == nil && strings.HasPrefix(key, "F") && n >= 1 && n <= hotkeyVirtualKeyFunctionKeys {
+		h.virtualKey = hotkeyVirtualKeyF1 + uint32(n-1)
+	} else {
+		return hotkey{}, invalid
+	}
+	return
+}
+
+func loadFavoriteString(name string) (value string, found bool) {
+	key, err := registry.OpenKey(registry.CURRENT_USER, favoriteRegKey, registry.QUERY_VALUE)
+	if err != nil {
+		return "", false
+	}
+	defer key.Close()
+	value, _, err = key.GetStringValue(name)
+	return value, err == nil
+}
+
+func saveFavoriteString(name, value string) error {
+	key, _, err := registry.CreateKey(registry.CURRENT_USER, favoriteRegKey, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	return key.SetStringValue(name, value)
+}
+
+func loadFavoriteTunnel() string {
+	name, _ := loadFavoriteString(favoriteTunnelRegValue)
+	return name
+}
+
+// saveFavoriteTunnel makes the named tunnel the favorite, or clears the favorite if name is empty.
+func saveFavoriteTunnel(name string) error {
+	if len(name) > 0 {
+		return saveFavoriteString(favoriteTunnelRegValue, name)
+	}
+	key, err := registry.OpenKey(registry.CURRENT_USER, favoriteRegKey, registry.SET_VALUE)
+	if err != nil {
+		return nil
+	}
+	defer key.Close()
+	err = key.DeleteValue(favoriteTunnelRegValue)
+	if err == registry.ErrNotExist {
+		err = nil
+	}
+	return err
+}
+
+// loadFavoriteHotkey returns the hotkey of the favorite tunnel, which is Ctrl+Alt+W unless the
+// user changed it, possibly to none.
+func loadFavoriteHotkey() (hotkey, error) {
+	s, found := loadFavoriteString(favoriteHotkeyRegValue)
+	if !found {
+		s = defaultFavoriteHotkey
+	}
+	return parseHotkey(s)
+}
+
+func saveFavoriteHotkey(h hotkey) error {
+	return saveFavoriteString(favoriteHotkeyRegValue, h.String())
+}
+
+// trayClickTogglesFavorite returns whether a click on the tray icon toggles the favorite tunnel,
+// if there is one, rather than opening the window, which then takes a double click.
+func trayClickTogglesFavorite() bool {
+	key, err := registry.OpenKey(registry.CURRENT_USER, favoriteRegKey, registry.QUERY_VALUE)
+	if err != nil {
+		return true
+	}
+	defer key.Close()
+	toggles, _, err := key.GetIntegerValue(favoriteTrayClickRegValue)
+	return err != nil || toggles != 0
+}
+
+func setTrayClickTogglesFavorite(toggles bool) error {
+	key, _, err := registry.CreateKey(registry.CURRENT_USER, favoriteRegKey, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	var value uint32
+	if toggles {
+		value = 1
+	}
+	return key.SetDWordValue(favoriteTrayClickRegValue, value)
+}
+
+var (
+	favoriteHotkeyWindow     win.HWND
+	favoriteHotkeyRegistered bool
+)
+
+// applyFavoriteHotkey registers the hotkey of the favorite tunnel with the window that handles
+// it, replacing any earlier one, or unregisters it if there is no favorite or no hotkey.
+func applyFavoriteHotkey() error {
+	if favoriteHotkeyWindow == 0 {
+		return nil
+	}
+	if favoriteHotkeyRegistered {
+		unregisterHotKey(windows.HWND(favoriteHotkeyWindow), favoriteHotkeyID)
+		favoriteHotkeyRegistered = false
+	}
+	if len(loadFavoriteTunnel()) == 0 {
+		return nil
+	}
+	h, err := loadFavoriteHotkey()
+	if err != nil || h.IsEmpty() {
+		return err
+	}
+	err = registerHotKey(windows.HWND(favoriteHotkeyWindow), favoriteHotkeyID, h.modifiers|hotkeyModifierNoRepeat, h.virtualKey)
+	if err != nil {
+		return errors.New(l18n.Sprintf("Unable to register the hotkey %s, which may be in use by another program: %v", h, err))
+	}
+	favoriteHotkeyRegistered = true
+	return nil
+}
+
+// toggleFavoriteTunnel activates the favorite tunnel, or deactivates it if it is active, and
+// reports failures with report, on the thread of the UI.
+func toggleFavoriteTunnel(form walk.Form, report func(title, message string)) {
+	name := loadFavoriteTunnel()
+	if len(name) == 0 {
+		return
+	}
+	go func() {
+		globalState, err := manager.IPCClientGlobalState()
+		if err != nil || (globalState != manager.TunnelStarted && globalState != manager.TunnelStopped) {
+			return
+		}
+		oldState, err := toggleTunnel(nil, &manager.Tunnel{Name: name})
+		if err == nil {
+			return
+		}
+		var title string
+		switch oldState {
+		case manager.TunnelUnknown:
+			title = l18n.Sprintf("Failed to determine tunnel state")
+		case manager.TunnelStopped:
+			title = l18n.Sprintf("Failed to activate tunnel")
+		default:
+			title = l18n.Sprintf("Failed to deactivate tunnel")
+		}
+		form.Synchronize(func() {
+			report(title, err.Error())
+		})
+	}()
+}
@@ -0,0 +1,129 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"github.com/lxn/walk"
+
+	"golang.zx2c4.com/wireguard/windows/l18n"
+)
+
+func onFavoriteHotkey(owner walk.Form) {
+	showError(runFavoriteHotkeyDialog(owner), owner)
+}
+
+// runFavoriteHotkeyDialog edits the hotkey that toggles the favorite tunnel, and whether clicks on
+// the tray icon toggle it too.
+func runFavoriteHotkeyDialog(owner walk.Form) error {
+	current, err := loadFavoriteHotkey()
+	if err != nil {
+		current = hotkey{}
+	}
+
+	var disposables walk.Disposables
+	defer disposables.Treat()
+
+	dlg, err := walk.NewDialog(owner)
+	if err != nil {
+		return err
+	}
+	disposables.Add(dlg)
+	dlg.SetTitle(l18n.Sprintf("Favorite tunnel"))
+	layout := walk.NewGridLayout()
+	layout.SetSpacing(6)
+	layout.SetMargins(walk.Margins{HNear: 10, VNear: 10, HFar: 10, VFar: 10})
+	dlg.SetLayout(layout)
+	if icon, err := loadLogoIcon(32); err == nil {
+		dlg.SetIcon(icon)
+	}
+
+	hotkeyLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return err
+	}
+	layout.SetRange(hotkeyLabel, walk.Rectangle{X: 0, Y: 0, Width: 1, Height: 1})
+	hotkeyLabel.SetTextAlignment(walk.AlignHFarVCenter)
+	hotkeyLabel.SetText(l18n.Sprintf("&Hotkey:"))
+	hotkeyEdit, err := walk.NewLineEdit(dlg)
+	if err != nil {
+		return err
+	}
+	layout.SetRange(hotkeyEdit, walk.Rectangle{X: 1, Y: 0, Width: 1, Height: 1})
+	hotkeyEdit.SetCueBanner(l18n.Sprintf("None"))
+	hotkeyEdit.SetText(current.String())
+
+	trayClickCheckBox, err := walk.NewCheckBox(dlg)
+	if err != nil {
+		return err
+	}
+	layout.SetRange(trayClickCheckBox, walk.Rectangle{X: 0, Y: 1, Width: 2, Height: 1})
+	trayClickCheckBox.SetText(l18n.Sprintf("&Clicking the tray icon toggles the favorite tunnel, and double-clicking it opens this window"))
+	trayClickCheckBox.SetChecked(trayClickTogglesFavorite())
+
+	hintLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return err
+	}
+	layout.SetRange(hintLabel, walk.Rectangle{X: 0, Y: 2, Width: 2, Height: 1})
+	hintLabel.SetMinMaxSize(walk.Size{Width: 350}, walk.Size{Width: 350})
+	if favorite := loadFavoriteTunnel(); len(favorite) > 0 {
+		hintLabel.SetText(l18n.Sprintf("The hotkey toggles the favorite tunnel, %s, even while this window is closed. It is written as modifiers and a key, such as %s.", favorite, defaultFavoriteHotkey))
+	} else {
+		hintLabel.SetText(l18n.Sprintf("There is no favorite tunnel yet. Mark one as favorite from the context menu of the tunnel list, and the hotkey toggles it, even while this window is closed."))
+	}
+
+	buttonsContainer, err := walk.NewComposite(dlg)
+	if err != nil {
+		return err
+	}
+	layout.SetRange(buttonsContainer, walk.Rectangle{X: 0, Y: 3, Width: 2, Height: 1})
+	hbl := walk.NewHBoxLayout()
+	hbl.SetMargins(walk.Margins{})
+	buttonsContainer.SetLayout(hbl)
+	walk.NewHSpacer(buttonsContainer)
+	saveButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return err
+	}
+	saveButton.SetText(l18n.Sprintf("&Save"))
+	cancelButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return err
+	}
+	cancelButton.SetText(l18n.Sprintf("Cancel"))
+	cancelButton.Clicked().Attach(dlg.Cancel)
+	dlg.SetDefaultButton(saveButton)
+	dlg.SetCancelButton(cancelButton)
+
+	saveButton.Clicked().Attach(func() {
+		h, err := parseHotkey(hotkeyEdit.Text())
+		if err != nil {
+			showErrorCustom(dlg, l18n.Sprintf("Invalid hotkey"), err.Error())
+			return
+		}
+		err = saveFavoriteHotkey(h)
+		if err == nil {
+			err = setTrayClickTogglesFavorite(trayClickCheckBox.Checked())
+		}
+		if err != nil {
+			showErrorCustom(dlg, l18n.Sprintf("Unable to save favorite settings"), err.Error())
+			return
+		}
+		if err := applyFavoriteHotkey(); err != nil {
+			showErrorCustom(dlg, l18n.Sprintf("Invalid hotkey"), err.Error())
+			return
+		}
+		dlg.Accept()
+	})
+
+	applyTheme(dlg)
+
+	disposables.Spare()
+
+	dlg.Run()
+
+	return nil
+}
@@ -0,0 +1,165 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lxn/walk"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/l18n"
+	"golang.zx2c4.com/wireguard/windows/manager"
+)
+
+// Groups are edited as text, one per line, as the name of the group, an equals sign, and its
+// tunnels, separated by commas, in the order in which they are to be activated.
+
+func parseTunnelGroups(text string) (*conf.TunnelGroups, error) {
+	groups := &conf.TunnelGroups{}
+	for i, line := range strings.Split(text, "\n") {
+		if comment := strings.IndexByte(line, '#'); comment >= 0 {
+			line = line[:comment]
+		}
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		equals := strings.IndexByte(line, '=')
+		if equals < 0 {
+			return nil, errors.New(l18n.Sprintf("Line %d is not understood: %s", i+1, line))
+		}
+		group := conf.TunnelGroup{Name: strings.TrimSpace(line[:equals])}
+		for _, tunnel := range strings.Split(line[equals+1:], ",") {
+			if tunnel = strings.TrimSpace(tunnel); len(tunnel) > 0 {
+				group.Tunnels = append(group.Tunnels, tunnel)
+			}
+		}
+		groups.Groups = append(groups.Groups, group)
+	}
+	return groups, groups.Validate()
+}
+
+func formatTunnelGroups(groups *conf.TunnelGroups) string {
+	var text strings.Builder
+	for _, group := range groups.Groups {
+		fmt.Fprintf(&text, "%s = %s\r\n", group.Name, strings.Join(group.Tunnels, ", "))
+	}
+	return text.String()
+}
+
+// fillTunnelGroupsMenu replaces the actions with a submenu for each group, to activate or
+// deactivate it, reporting failures with showErr.
+func fillTunnelGroupsMenu(actions *walk.ActionList, groups *conf.TunnelGroups, showErr func(error)) {
+	actions.Clear()
+	for _, group := range groups.Groups {
+		groupName := group.Name
+		menu, err := walk.NewMenu()
+		if err != nil {
+			continue
+		}
+		activateAction := walk.NewAction()
+		activateAction.SetText(l18n.Sprintf("&Activate"))
+		activateAction.SetEnabled(len(group.Tunnels) > 0)
+		activateAction.Triggered().Attach(func() {
+			if err := manager.IPCClientStartGroup(groupName); err != nil {
+				showErr(err)
+			}
+		})
+		menu.Actions().Add(activateAction)
+		deactivateAction := walk.NewAction()
+		deactivateAction.SetText(l18n.Sprintf("&Deactivate"))
+		deactivateAction.SetEnabled(len(group.Tunnels) > 0)
+		deactivateAction.Triggered().Attach(func() {
+			if err := manager.IPCClientStopGroup(groupName); err != nil {
+				showErr(err)
+			}
+		})
+		menu.Actions().Add(deactivateAction)
+		menuAction := walk.NewMenuAction(menu)
+		menuAction.SetText(groupName)
+		actions.Add(menuAction)
+	}
+}
+
+// runTunnelGroupsDialog edits the groups, and reports whether they were saved.
+func runTunnelGroupsDialog(owner walk.Form) (bool, error) {
+	groups, err := manager.IPCClientTunnelGroups()
+	if err != nil {
+		return false, err
+	}
+
+	var disposables walk.Disposables
+	defer disposables.Treat()
+
+	dlg, err := walk.NewDialog(owner)
+	if err != nil {
+		return false, err
+	}
+	disposables.Add(dlg)
+	dlg.SetTitle(l18n.Sprintf("Tunnel groups"))
+	vbl := walk.NewVBoxLayout()
+	vbl.SetMargins(walk.Margins{HNear: 10, VNear: 10, HFar: 10, VFar: 10})
+	dlg.SetLayout(vbl)
+	dlg.SetMinMaxSize(walk.Size{Width: 500, Height: 300}, walk.Size{})
+	if icon, err := loadLogoIcon(32); err == nil {
+		dlg.SetIcon(icon)
+	}
+
+	groupsLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return false, err
+	}
+	groupsLabel.SetText(l18n.Sprintf("&Groups, one per line, as a name followed by = and the tunnels, separated by commas, in the order in which they are activated:"))
+	groupsEdit, err := walk.NewTextEdit(dlg)
+	if err != nil {
+		return false, err
+	}
+	groupsEdit.SetText(formatTunnelGroups(&groups))
+
+	buttonsContainer, err := walk.NewComposite(dlg)
+	if err != nil {
+		return false, err
+	}
+	hbl := walk.NewHBoxLayout()
+	hbl.SetMargins(walk.Margins{})
+	buttonsContainer.SetLayout(hbl)
+	walk.NewHSpacer(buttonsContainer)
+	saveButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return false, err
+	}
+	saveButton.SetText(l18n.Sprintf("&Save"))
+	cancelButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return false, err
+	}
+	cancelButton.SetText(l18n.Sprintf("Cancel"))
+	cancelButton.Clicked().Attach(dlg.Cancel)
+	dlg.SetCancelButton(cancelButton)
+
+	saveButton.Clicked().Attach(func() {
+		newGroups, err := parseTunnelGroups(groupsEdit.Text())
+		if err != nil {
+			showErrorCustom(dlg, l18n.Sprintf("Invalid groups"), err.Error())
+			return
+		}
+		err = manager.IPCClientSetTunnelGroups(newGroups)
+		if err != nil {
+			showErrorCustom(dlg, l18n.Sprintf("Unable to save groups"), err.Error())
+			return
+		}
+		dlg.Accept()
+	})
+
+	applyTheme(dlg)
+
+	disposables.Spare()
+
+	return dlg.Run() == walk.DlgCmdOK, nil
+}
@@ -0,0 +1,198 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/lxn/win"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/l18n"
+	"golang.zx2c4.com/wireguard/windows/manager"
+	"golang.zx2c4.com/wireguard/windows/ui/jumplist"
+)
+
+// The jump list of the taskbar button lists the tunnels that were most recently activated, which
+// are remembered per user, and a task to import tunnels. Its items start another process, which
+// cannot talk to the manager itself, so it passes what was chosen to the window of the UI with
+// WM_COPYDATA, and the UI then carries it out as it would from the tray.
+
+const (
+	recentTunnelsRegKey   = `Software\WireGuard`
+	recentTunnelsRegValue = "RecentTunnels"
+	maxRecentTunnels      = 5
+
+	// jumpListCopyDataID tells the WM_COPYDATA messages of jump list tasks from others.
+	jumpListCopyDataID = 0x57474a4c
+
+	JumpListActivateTask = "activate"
+	JumpListImportTask   = "import"
+
+	logoIconIndex = -7 // The resource ID of the logo in resources.rc, negated.
+)
+
+type copyDataStruct struct {
+	data    uintptr
+	size    uint32
+	pointer unsafe.Pointer
+}
+
+func loadRecentTunnels() []string {
+	key, err := registry.OpenKey(registry.CURRENT_USER, recentTunnelsRegKey, registry.QUERY_VALUE)
+	if err != nil {
+		return nil
+	}
+	defer key.Close()
+	names, _, err := key.GetStringsValue(recentTunnelsRegValue)
+	if err != nil {
+		return nil
+	}
+	return names
+}
+
+func saveRecentTunnels(names []string) {
+	key, _, err := registry.CreateKey(registry.CURRENT_USER, recentTunnelsRegKey, registry.SET_VALUE)
+	if err != nil {
+		return
+	}
+	defer key.Close()
+	if len(names) == 0 {
+		key.DeleteValue(recentTunnelsRegValue)
+		return
+	}
+	key.SetStringsValue(recentTunnelsRegValue, names)
+}
+
+// noteRecentTunnel moves the named tunnel to the front of the most recently used ones.
+func noteRecentTunnel(name string) {
+	names := []string{name}
+	for _, recent := range loadRecentTunnels() {
+		if recent != name && len(names) < maxRecentTunnels {
+			names = append(names, recent)
+		}
+	}
+	saveRecentTunnels(names)
+}
+
+func forgetRecentTunnels(forgotten map[string]bool) {
+	var names []string
+	for _, recent := range loadRecentTunnels() {
+		if !forgotten[recent] {
+			names = append(names, recent)
+		}
+	}
+	saveRecentTunnels(names)
+}
+
+var jumpListLock sync.Mutex
+
+// refreshJumpList rebuilds the jump list in the background, leaving out recent tunnels that no
+// longer exist.
+func refreshJumpList() {
+	go func() {
+		jumpListLock.Lock()
+		defer jumpListLock.Unlock()
+		tunnels, err := manager.IPCClientTunnels()
+		if err != nil {
+			return
+		}
+		existing := make(map[string]bool, len(tunnels))
+		for _, tunnel := range tunnels {
+			existing[tunnel.Name] = true
+		}
+		var items []jumplist.Item
+		for _, name := range loadRecentTunnels() {
+			if existing[name] {
+				items = append(items, jumplist.Item{Title: name, Arguments: "/activatetunnel " + name, IconIndex: logoIconIndex})
+			}
+		}
+		var tasks []jumplist.Item
+		if IsAdmin && !conf.LoadPolicies().DisableConfigEditing {
+			tasks = append(tasks, jumplist.Item{Title: l18n.Sprintf("Import tunnel…"), Arguments: "/importtunnel", IconIndex: logoIconIndex})
+		}
+
+		// COM is initialized for each thread, so this goroutine keeps to one.
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		if windows.CoInitializeEx(0, windows.COINIT_APARTMENTTHREADED) != nil {
+			return
+		}
+		defer windows.CoUninitialize()
+		removed, _ := jumplist.Commit(l18n.Sprintf("Recent Tunnels"), items, tasks)
+		if len(removed) > 0 {
+			forgotten := make(map[string]bool, len(removed))
+			for _, arguments := range removed {
+				if name, found := strings.CutPrefix(arguments, "/activatetunnel "); found {
+					forgotten[name] = true
+				}
+			}
+			forgetRecentTunnels(forgotten)
+		}
+	}()
+}
+
+// RunJumpListTask passes a task chosen from the jump list to the running UI, returning false if
+// there is none.
+func RunJumpListTask(task, tunnelName string) bool {
+	hwnd := win.FindWindow(windows.StringToUTF16Ptr(manageWindowWindowClass), nil)
+	if hwnd == 0 {
+		return false
+	}
+	if task == JumpListImportTask {
+		// The file dialog is only brought to the front if the window is.
+		raiseRemote(hwnd)
+	}
+	payload := []byte(task + " " + tunnelName)
+	cds := copyDataStruct{
+		data:    jumpListCopyDataID,
+		size:    uint32(len(payload)),
+		pointer: unsafe.Pointer(&payload[0]),
+	}
+	return win.SendMessage(hwnd, win.WM_COPYDATA, 0, uintptr(unsafe.Pointer(&cds))) == win.TRUE
+}
+
+// onJumpListTask handles the WM_COPYDATA of RunJumpListTask, which it returns quickly from, as the
+// sender waits.
+func (mtw *ManageTunnelsWindow) onJumpListTask(lParam uintptr) bool {
+	cds := *(**copyDataStruct)(unsafe.Pointer(&lParam))
+	if cds.data != jumpListCopyDataID || cds.size == 0 {
+		return false
+	}
+	task, tunnelName, _ := strings.Cut(string(unsafe.Slice((*byte)(cds.pointer), cds.size)), " ")
+	switch task {
+	case JumpListActivateTask:
+		if !conf.TunnelNameIsValid(tunnelName) {
+			return false
+		}
+		go func() {
+			err := startTunnel(mtw, &manager.Tunnel{Name: tunnelName})
+			if err != nil && !errors.Is(err, windows.ERROR_CANCELLED) {
+				mtw.Synchronize(func() {
+					showErrorCustom(nil, l18n.Sprintf("Failed to activate tunnel"), err.Error())
+				})
+			}
+		}()
+	case JumpListImportTask:
+		if !IsAdmin || conf.LoadPolicies().DisableConfigEditing {
+			return false
+		}
+		mtw.Synchronize(func() {
+			mtw.tabs.SetCurrentIndex(0)
+			raise(mtw.Handle())
+			mtw.tunnelsPage.onImport()
+		})
+	default:
+		return false
+	}
+	return true
+}
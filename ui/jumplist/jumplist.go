@@ -0,0 +1,220 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+// Package jumplist sets the jump list of the taskbar button, from items that each run the
+// executable of the process with some arguments.
+package jumplist
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Item is an entry of the jump list, which runs the executable with Arguments when clicked.
+type Item struct {
+	Title     string
+	Arguments string
+	// IconIndex is that of the icon in the resources of the executable.
+	IconIndex int32
+}
+
+func hresult(r uintptr) error {
+	if int32(r) < 0 {
+		return syscall.Errno(r)
+	}
+	return nil
+}
+
+func method(object unsafe.Pointer, index int) uintptr {
+	vtbl := *(*unsafe.Pointer)(object)
+	return *(*uintptr)(unsafe.Add(vtbl, uintptr(index)*unsafe.Sizeof(uintptr(0))))
+}
+
+func release(object unsafe.Pointer) {
+	if object != nil {
+		syscall.SyscallN(method(object, _IUnknown_Release), uintptr(object))
+	}
+}
+
+func call(object unsafe.Pointer, index int, args ...uintptr) error {
+	r, _, _ := syscall.SyscallN(method(object, index), append([]uintptr{uintptr(object)}, args...)...)
+	return hresult(r)
+}
+
+func newShellLink(path string, item *Item) (unsafe.Pointer, error) {
+	path16, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	arguments16, err := windows.UTF16PtrFromString(item.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	title16, err := windows.UTF16PtrFromString(item.Title)
+	if err != nil {
+		return nil, err
+	}
+	var link unsafe.Pointer
+	err = coCreateInstance(&_CLSID_ShellLink, nil, _CLSCTX_INPROC_SERVER, &_IID_IShellLinkW, &link)
+	if err != nil {
+		return nil, err
+	}
+	err = call(link, _IShellLinkW_SetPath, uintptr(unsafe.Pointer(path16)))
+	if err == nil {
+		err = call(link, _IShellLinkW_SetArguments, uintptr(unsafe.Pointer(arguments16)))
+	}
+	if err == nil {
+		err = call(link, _IShellLinkW_SetIconLocation, uintptr(unsafe.Pointer(path16)), uintptr(item.IconIndex))
+	}
+	if err != nil {
+		release(link)
+		return nil, err
+	}
+
+	// Items of jump lists are titled by a property, rather than by a description of the link.
+	var store unsafe.Pointer
+	err = call(link, _IUnknown_QueryInterface, uintptr(unsafe.Pointer(&_IID_IPropertyStore)), uintptr(unsafe.Pointer(&store)))
+	if err != nil {
+		release(link)
+		return nil, err
+	}
+	defer release(store)
+	title := propVariant{vt: _VT_LPWSTR, pointer: title16}
+	err = call(store, _IPropertyStore_SetValue, uintptr(unsafe.Pointer(&_PKEY_Title)), uintptr(unsafe.Pointer(&title)))
+	if err == nil {
+		err = call(store, _IPropertyStore_Commit)
+	}
+	if err != nil {
+		release(link)
+		return nil, err
+	}
+	return link, nil
+}
+
+// newCollection returns an IObjectArray of links for items, skipping those whose arguments are
+// in skipped.
+func newCollection(path string, items []Item, skipped map[string]bool) (unsafe.Pointer, int, error) {
+	var collection unsafe.Pointer
+	err := coCreateInstance(&_CLSID_EnumerableObjectCollection, nil, _CLSCTX_INPROC_SERVER, &_IID_IObjectCollection, &collection)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer release(collection)
+	count := 0
+	for i := range items {
+		if skipped[items[i].Arguments] {
+			continue
+		}
+		link, err := newShellLink(path, &items[i])
+		if err != nil {
+			return nil, 0, err
+		}
+		err = call(collection, _IObjectCollection_AddObject, uintptr(link))
+		release(link)
+		if err != nil {
+			return nil, 0, err
+		}
+		count++
+	}
+	var array unsafe.Pointer
+	err = call(collection, _IUnknown_QueryInterface, uintptr(unsafe.Pointer(&_IID_IObjectArray)), uintptr(unsafe.Pointer(&array)))
+	if err != nil {
+		return nil, 0, err
+	}
+	return array, count, nil
+}
+
+// removedArguments returns the arguments of the links in an IObjectArray of destinations, which
+// the user removed from the jump list.
+func removedArguments(removed unsafe.Pointer) map[string]bool {
+	arguments := make(map[string]bool)
+	var count uint32
+	if call(removed, _IObjectArray_GetCount, uintptr(unsafe.Pointer(&count))) != nil {
+		return arguments
+	}
+	buffer := make([]uint16, windows.MAX_PATH)
+	for i := uint32(0); i < count; i++ {
+		var link unsafe.Pointer
+		if call(removed, _IObjectArray_GetAt, uintptr(i), uintptr(unsafe.Pointer(&_IID_IShellLinkW)), uintptr(unsafe.Pointer(&link))) != nil {
+			continue
+		}
+		if call(link, _IShellLinkW_GetArguments, uintptr(unsafe.Pointer(&buffer[0])), uintptr(len(buffer))) == nil {
+			arguments[windows.UTF16ToString(buffer)] = true
+		}
+		release(link)
+	}
+	return arguments
+}
+
+// Commit replaces the jump list with a custom category of items, under the given name, followed
+// by tasks. It returns the arguments of the items that the user has removed from the jump list,
+// which are left out of it, as the shell refuses them, and which the caller should forget. The
+// calling thread must have initialized COM.
+func Commit(category string, items, tasks []Item) (removed []string, err error) {
+	path, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	category16, err := windows.UTF16PtrFromString(category)
+	if err != nil {
+		return nil, err
+	}
+
+	var list unsafe.Pointer
+	err = coCreateInstance(&_CLSID_DestinationList, nil, _CLSCTX_INPROC_SERVER, &_IID_ICustomDestinationList, &list)
+	if err != nil {
+		return nil, err
+	}
+	defer release(list)
+	var (
+		minSlots     uint32
+		removedArray unsafe.Pointer
+	)
+	err = call(list, _ICustomDestinationList_BeginList, uintptr(unsafe.Pointer(&minSlots)), uintptr(unsafe.Pointer(&_IID_IObjectArray)), uintptr(unsafe.Pointer(&removedArray)))
+	if err != nil {
+		return nil, err
+	}
+	skipped := removedArguments(removedArray)
+	release(removedArray)
+	for arguments := range skipped {
+		removed = append(removed, arguments)
+	}
+	commit := func() error {
+		array, count, err := newCollection(path, items, skipped)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			err = call(list, _ICustomDestinationList_AppendCategory, uintptr(unsafe.Pointer(category16)), uintptr(array))
+			// Custom categories are refused while the user has recent items turned off, in
+			// which case the tasks still go in.
+			if err == syscall.Errno(_E_ACCESSDENIED) {
+				err = nil
+			}
+		}
+		release(array)
+		if err != nil {
+			return err
+		}
+		array, _, err = newCollection(path, tasks, nil)
+		if err != nil {
+			return err
+		}
+		err = call(list, _ICustomDestinationList_AddUserTasks, uintptr(array))
+		release(array)
+		if err != nil {
+			return err
+		}
+		return call(list, _ICustomDestinationList_CommitList)
+	}
+	if err = commit(); err != nil {
+		call(list, _ICustomDestinationList_AbortList)
+		return removed, err
+	}
+	return removed, nil
+}
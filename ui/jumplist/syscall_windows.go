@@ -0,0 +1,69 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package jumplist
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+const (
+	_CLSCTX_INPROC_SERVER = 0x1
+	_E_ACCESSDENIED       = 0x80070005
+	_VT_LPWSTR            = 31
+)
+
+// Methods are numbered by their place in the vtables of the Windows SDK headers, after the three
+// of IUnknown.
+const (
+	_IUnknown_QueryInterface = 0
+	_IUnknown_Release        = 2
+
+	_ICustomDestinationList_BeginList      = 4
+	_ICustomDestinationList_AppendCategory = 5
+	_ICustomDestinationList_AddUserTasks   = 7
+	_ICustomDestinationList_CommitList     = 8
+	_ICustomDestinationList_AbortList      = 11
+
+	_IObjectArray_GetCount = 3
+	_IObjectArray_GetAt    = 4
+
+	_IObjectCollection_AddObject = 5
+
+	_IShellLinkW_GetArguments    = 10
+	_IShellLinkW_SetArguments    = 11
+	_IShellLinkW_SetIconLocation = 17
+	_IShellLinkW_SetPath         = 20
+
+	_IPropertyStore_SetValue = 6
+	_IPropertyStore_Commit   = 7
+)
+
+var (
+	_CLSID_DestinationList            = windows.GUID{Data1: 0x77f10cf0, Data2: 0x3db5, Data3: 0x4966, Data4: [8]byte{0xb5, 0x20, 0xb7, 0xc5, 0x4f, 0xd3, 0x5e, 0xd6}}
+	_CLSID_EnumerableObjectCollection = windows.GUID{Data1: 0x2d3468c1, Data2: 0x36a7, Data3: 0x43b6, Data4: [8]byte{0xac, 0x24, 0xd3, 0xf0, 0x2f, 0xd9, 0x60, 0x7a}}
+	_CLSID_ShellLink                  = windows.GUID{Data1: 0x00021401, Data2: 0x0000, Data3: 0x0000, Data4: [8]byte{0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}}
+	_IID_ICustomDestinationList       = windows.GUID{Data1: 0x6332debf, Data2: 0x87b5, Data3: 0x4670, Data4: [8]byte{0x90, 0xc0, 0x5e, 0x57, 0xb4, 0x08, 0xa4, 0x9e}}
+	_IID_IObjectArray                 = windows.GUID{Data1: 0x92ca9dcd, Data2: 0x5622, Data3: 0x4bba, Data4: [8]byte{0xa8, 0x05, 0x5e, 0x9f, 0x54, 0x1b, 0xd8, 0xc9}}
+	_IID_IObjectCollection            = windows.GUID{Data1: 0x5632b1a4, Data2: 0xe38a, Data3: 0x400a, Data4: [8]byte{0x92, 0x8a, 0xd4, 0xcd, 0x63, 0x23, 0x02, 0x95}}
+	_IID_IShellLinkW                  = windows.GUID{Data1: 0x000214f9, Data2: 0x0000, Data3: 0x0000, Data4: [8]byte{0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}}
+	_IID_IPropertyStore               = windows.GUID{Data1: 0x886d8eeb, Data2: 0x8cf2, Data3: 0x4446, Data4: [8]byte{0x8d, 0x02, 0xcd, 0xba, 0x1d, 0xbd, 0xcf, 0x99}}
+	_PKEY_Title                       = propertyKey{fmtid: windows.GUID{Data1: 0xf29f85e0, Data2: 0x4ff9, Data3: 0x1068, Data4: [8]byte{0xab, 0x91, 0x08, 0x00, 0x2b, 0x27, 0xb3, 0xd9}}, pid: 2}
+)
+
+type propertyKey struct {
+	fmtid windows.GUID
+	pid   uint32
+}
+
+// propVariant is a PROPVARIANT holding a pointer, which is all that is set here.
+type propVariant struct {
+	vt      uint16
+	_       [3]uint16
+	pointer *uint16
+	_       uintptr
+}
+
+//sys	coCreateInstance(clsid *windows.GUID, outer unsafe.Pointer, context uint32, iid *windows.GUID, object *unsafe.Pointer) (ret error) = ole32.CoCreateInstance
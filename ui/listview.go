@@ -8,15 +8,28 @@ package ui
 import (
 	"sort"
 	"sync/atomic"
+	"time"
 
 	"github.com/lxn/win"
 
 	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/l18n"
 	"golang.zx2c4.com/wireguard/windows/manager"
 
 	"github.com/lxn/walk"
 )
 
+// The columns of the tunnel list. The statistics columns are filled from the snapshot that the
+// manager keeps of all running tunnels, which is fetched once per interval for the whole list.
+const (
+	listColumnName = iota
+	listColumnLastHandshake
+	listColumnReceived
+	listColumnSent
+)
+
+const listStatsInterval = time.Second
+
 // ListModel is a struct to store the currently known tunnels to the GUI, suitable as a model for a walk.TableView.
 type ListModel struct {
 	walk.TableModelBase
@@ -24,6 +37,7 @@ type ListModel struct {
 
 	tunnels           []manager.Tunnel
 	lastObservedState map[manager.Tunnel]manager.TunnelState
+	stats             map[string]manager.TunnelStats
 }
 
 var cachedListViewIconsForWidthAndState = make(map[widthAndState]*walk.Bitmap)
@@ -32,21 +46,94 @@ func (t *ListModel) RowCount() int {
 	return len(t.tunnels)
 }
 
+// formatHandshakeAge is a terser form of conf.HandshakeTime.String, which fits in a column.
+func formatHandshakeAge(t conf.HandshakeTime, now time.Time) string {
+	if t.IsEmpty() {
+		return ""
+	}
+	age := now.Sub(time.Unix(0, 0).Add(time.Duration(t)))
+	switch {
+	case age < 0:
+		return ""
+	case age < time.Minute:
+		return l18n.Sprintf("%d s ago", age/time.Second)
+	case age < time.Hour:
+		return l18n.Sprintf("%d min ago", age/time.Minute)
+	case age < 24*time.Hour:
+		return l18n.Sprintf("%d h ago", age/time.Hour)
+	}
+	return l18n.Sprintf("%d d ago", age/(24*time.Hour))
+}
+
 func (t *ListModel) Value(row, col int) any {
-	if col != 0 || row < 0 || row >= len(t.tunnels) {
+	if row < 0 || row >= len(t.tunnels) {
 		return ""
 	}
-	return t.tunnels[row].Name
+	if col == listColumnName {
+		return t.tunnels[row].Name
+	}
+	stats, ok := t.stats[t.tunnels[row].Name]
+	if !ok {
+		return ""
+	}
+	switch col {
+	case listColumnLastHandshake:
+		return formatHandshakeAge(stats.LastHandshakeTime, time.Now())
+	case listColumnReceived:
+		return stats.RxBytes.String()
+	case listColumnSent:
+		return stats.TxBytes.String()
+	}
+	return ""
+}
+
+// less orders tunnels by the sorted column, and then by name. Tunnels that are not running sort
+// after those that are, in either order, since they have no statistics to compare.
+func (t *ListModel) less(a, b *manager.Tunnel, col int, order walk.SortOrder) bool {
+	if col != listColumnName {
+		statsA, okA := t.stats[a.Name]
+		statsB, okB := t.stats[b.Name]
+		if okA != okB {
+			return okA
+		}
+		var x, y uint64
+		switch col {
+		case listColumnLastHandshake:
+			x, y = uint64(statsA.LastHandshakeTime), uint64(statsB.LastHandshakeTime)
+			// The most recent handshake is the smallest age, so sorts first when ascending.
+			x, y = y, x
+		case listColumnReceived:
+			x, y = uint64(statsA.RxBytes), uint64(statsB.RxBytes)
+		case listColumnSent:
+			x, y = uint64(statsA.TxBytes), uint64(statsB.TxBytes)
+		}
+		if x != y {
+			return (x < y) == (order == walk.SortAscending)
+		}
+		return conf.TunnelNameIsLess(a.Name, b.Name)
+	}
+	if order == walk.SortDescending {
+		return conf.TunnelNameIsLess(b.Name, a.Name)
+	}
+	return conf.TunnelNameIsLess(a.Name, b.Name)
 }
 
 func (t *ListModel) Sort(col int, order walk.SortOrder) error {
 	sort.SliceStable(t.tunnels, func(i, j int) bool {
-		return conf.TunnelNameIsLess(t.tunnels[i].Name, t.tunnels[j].Name)
+		return t.less(&t.tunnels[i], &t.tunnels[j], col, order)
 	})
 
 	return t.SorterBase.Sort(col, order)
 }
 
+// ID lets the view keep the current tunnel selected when the rows are sorted by another column.
+func (t *ListModel) ID(row int) any {
+	if row < 0 || row >= len(t.tunnels) {
+		return nil
+	}
+	return t.tunnels[row].Name
+}
+
 type ListView struct {
 	*walk.TableView
 
@@ -55,6 +142,10 @@ type ListView struct {
 	tunnelChangedCB        *manager.TunnelChangeCallback
 	tunnelsChangedCB       *manager.TunnelsChangeCallback
 	tunnelsUpdateSuspended int32
+	statsDone              chan struct{}
+
+	// Only tunnels that match the filter are listed, if not nil.
+	filter *tunnelFilter
 }
 
 func NewListView(parent walk.Container) (*ListView, error) {
@@ -72,15 +163,30 @@ func NewListView(parent walk.Container) (*ListView, error) {
 	model := new(ListModel)
 	model.lastObservedState = make(map[manager.Tunnel]manager.TunnelState)
 	tv.SetModel(model)
-	tv.SetLastColumnStretched(true)
-	tv.SetHeaderHidden(true)
 	tv.SetIgnoreNowhere(true)
+	tv.SetRestoringCurrentItemOnReset(true)
 	tv.SetScrollbarOrientation(walk.Vertical)
-	tv.Columns().Add(walk.NewTableViewColumn())
+	for _, column := range []struct {
+		title string
+		width int
+		align walk.Alignment1D
+	}{
+		{l18n.Sprintf("Name"), 140, walk.AlignNear},
+		{l18n.Sprintf("Handshake"), 80, walk.AlignFar},
+		{l18n.Sprintf("Received"), 80, walk.AlignFar},
+		{l18n.Sprintf("Sent"), 80, walk.AlignFar},
+	} {
+		tvc := walk.NewTableViewColumn()
+		tvc.SetTitle(column.title)
+		tvc.SetWidth(column.width)
+		tvc.SetAlignment(column.align)
+		tv.Columns().Add(tvc)
+	}
 
 	tunnelsView := &ListView{
 		TableView: tv,
 		model:     model,
+		statsDone: make(chan struct{}),
 	}
 	tv.SetCellStyler(tunnelsView)
 
@@ -88,6 +194,7 @@ func NewListView(parent walk.Container) (*ListView, error) {
 
 	tunnelsView.tunnelChangedCB = manager.IPCClientRegisterTunnelChange(tunnelsView.onTunnelChange)
 	tunnelsView.tunnelsChangedCB = manager.IPCClientRegisterTunnelsChange(tunnelsView.onTunnelsChange)
+	go tunnelsView.refreshStats()
 
 	return tunnelsView, nil
 }
@@ -101,9 +208,71 @@ func (tv *ListView) Dispose() {
 		tv.tunnelsChangedCB.Unregister()
 		tv.tunnelsChangedCB = nil
 	}
+	if tv.statsDone != nil {
+		close(tv.statsDone)
+		tv.statsDone = nil
+	}
 	tv.TableView.Dispose()
 }
 
+// PreferredWidth is the width that shows all columns of the list without scrolling horizontally.
+func (tv *ListView) PreferredWidth() int {
+	width := tv.IntFrom96DPI(4) + int(win.GetSystemMetrics(win.SM_CXVSCROLL))
+	for i := 0; i < tv.Columns().Len(); i++ {
+		width += tv.IntFrom96DPI(tv.Columns().At(i).Width())
+	}
+	return width
+}
+
+// refreshStats fetches the statistics of the running tunnels, once per interval, until disposed.
+// The rows are redrawn but not reordered, lest the selection jump around while sorted by traffic;
+// they are sorted again when the header is clicked.
+func (tv *ListView) refreshStats() {
+	done := tv.statsDone
+	ticker := time.NewTicker(listStatsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		stats, err := manager.IPCClientTunnelStats()
+		if err != nil {
+			continue
+		}
+		byName := make(map[string]manager.TunnelStats, len(stats))
+		for _, s := range stats {
+			byName[s.Name] = s
+		}
+		tv.Synchronize(func() {
+			if tv.statsDone == nil || (len(byName) == 0 && len(tv.model.stats) == 0) {
+				return
+			}
+			tv.model.stats = byName
+			if len(tv.model.tunnels) > 0 {
+				tv.model.PublishRowsChanged(0, len(tv.model.tunnels)-1)
+			}
+		})
+	}
+}
+
+// SetFilter lists only the tunnels that match filter from now on, and whenever it changes.
+func (tv *ListView) SetFilter(filter *tunnelFilter) {
+	tv.filter = filter
+	filter.Changed(func() { tv.Load(false) })
+	tv.Load(false)
+}
+
+// IsFiltered reports whether tunnels may be missing from the list because of the filter.
+func (tv *ListView) IsFiltered() bool {
+	return tv.filter != nil && !tv.filter.IsEmpty()
+}
+
+func (tv *ListView) matches(tunnel manager.Tunnel) bool {
+	return tv.filter == nil || tv.filter.Matches(tunnel.Name)
+}
+
 func (tv *ListView) CurrentTunnel() *manager.Tunnel {
 	idx := tv.CurrentIndex()
 	if idx == -1 {
@@ -120,6 +289,7 @@ func (tv *ListView) StyleCell(style *walk.CellStyle) {
 	if row < 0 || row >= len(tv.model.tunnels) {
 		return
 	}
+	styleThemedCell(style, false)
 	tunnel := &tv.model.tunnels[row]
 
 	var state manager.TunnelState
@@ -199,10 +369,47 @@ func (tv *ListView) onTunnelChange(tunnel *manager.Tunnel, state, globalState ma
 	})
 }
 
-func (tv *ListView) onTunnelsChange() {
-	if atomic.LoadInt32(&tv.tunnelsUpdateSuspended) == 0 {
-		tv.Load(true)
+func (tv *ListView) onTunnelsChange(added, removed []manager.Tunnel) {
+	if atomic.LoadInt32(&tv.tunnelsUpdateSuspended) != 0 {
+		return
 	}
+	tv.Synchronize(func() {
+		for _, tunnel := range removed {
+			if tv.filter != nil {
+				tv.filter.Forget(tunnel.Name)
+			}
+			for i := range tv.model.tunnels {
+				if tv.model.tunnels[i] == tunnel {
+					tv.model.tunnels = append(tv.model.tunnels[:i], tv.model.tunnels[i+1:]...)
+					tv.model.PublishRowsRemoved(i, i)
+					delete(tv.model.lastObservedState, tunnel)
+					break
+				}
+			}
+		}
+		firstTunnelName := ""
+		for _, tunnel := range added {
+			if !tv.matches(tunnel) {
+				continue
+			}
+			i := sort.Search(len(tv.model.tunnels), func(i int) bool {
+				return !tv.model.less(&tv.model.tunnels[i], &tunnel, tv.model.SortedColumn(), tv.model.SortOrder())
+			})
+			if i < len(tv.model.tunnels) && tv.model.tunnels[i] == tunnel {
+				continue
+			}
+			tv.model.tunnels = append(tv.model.tunnels, manager.Tunnel{})
+			copy(tv.model.tunnels[i+1:], tv.model.tunnels[i:])
+			tv.model.tunnels[i] = tunnel
+			tv.model.PublishRowsInserted(i, i)
+			if len(firstTunnelName) == 0 || !conf.TunnelNameIsLess(firstTunnelName, tunnel.Name) {
+				firstTunnelName = tunnel.Name
+			}
+		}
+		if len(firstTunnelName) > 0 && len(tv.SelectedIndexes()) == 0 {
+			tv.selectTunnel(firstTunnelName)
+		}
+	})
 }
 
 func (tv *ListView) SetSuspendTunnelsUpdate(suspend bool) {
@@ -223,7 +430,9 @@ func (tv *ListView) Load(asyncUI bool) {
 		newTunnels := make(map[manager.Tunnel]bool, len(tunnels))
 		oldTunnels := make(map[manager.Tunnel]bool, len(tv.model.tunnels))
 		for _, tunnel := range tunnels {
-			newTunnels[tunnel] = true
+			if tv.matches(tunnel) {
+				newTunnels[tunnel] = true
+			}
 		}
 		for i := len(tv.model.tunnels); i > 0; {
 			i--
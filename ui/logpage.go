@@ -12,7 +12,9 @@ import (
 	"time"
 
 	"github.com/lxn/walk"
+	"golang.zx2c4.com/wireguard/windows/conf"
 	"golang.zx2c4.com/wireguard/windows/l18n"
+	"golang.zx2c4.com/wireguard/windows/manager"
 	"golang.zx2c4.com/wireguard/windows/ringlogger"
 )
 
@@ -22,10 +24,17 @@ const (
 
 type LogPage struct {
 	*walk.TabPage
-	logView *walk.TableView
-	model   *logModel
+	logView            *walk.TableView
+	model              *logModel
+	componentCB        *walk.ComboBox
+	updatingComponents bool
+	saveFilteredAction *walk.Action
+	saveFilteredButton *walk.PushButton
 }
 
+// The entries of the severity filter, in the order of the drop down box.
+var logFilterLevels = []ringlogger.Level{ringlogger.LevelDebug, ringlogger.LevelInfo, ringlogger.LevelWarning, ringlogger.LevelError}
+
 func NewLogPage() (*LogPage, error) {
 	lp := &LogPage{}
 
@@ -51,6 +60,7 @@ func NewLogPage() (*LogPage, error) {
 	lp.logView.SetAlternatingRowBG(true)
 	lp.logView.SetLastColumnStretched(true)
 	lp.logView.SetGridlines(true)
+	lp.logView.SetCellStyler(lp)
 
 	contextMenu, err := walk.NewMenu()
 	if err != nil {
@@ -75,6 +85,14 @@ func NewLogPage() (*LogPage, error) {
 	saveAction.Triggered().Attach(lp.onSave)
 	contextMenu.Actions().Add(saveAction)
 	lp.ShortcutActions().Add(saveAction)
+	lp.saveFilteredAction = walk.NewAction()
+	lp.saveFilteredAction.SetText(l18n.Sprintf("Save &filtered to file…"))
+	lp.saveFilteredAction.Triggered().Attach(lp.onSaveFiltered)
+	contextMenu.Actions().Add(lp.saveFilteredAction)
+	diagnosticsAction := walk.NewAction()
+	diagnosticsAction.SetText(l18n.Sprintf("Export &diagnostics…"))
+	diagnosticsAction.Triggered().Attach(lp.onExportDiagnostics)
+	contextMenu.Actions().Add(diagnosticsAction)
 	lp.logView.SetContextMenu(contextMenu)
 	setSelectionStatus := func() {
 		copyAction.SetEnabled(len(lp.logView.SelectedIndexes()) > 0)
@@ -106,8 +124,63 @@ func NewLogPage() (*LogPage, error) {
 	buttonsContainer.SetLayout(walk.NewHBoxLayout())
 	buttonsContainer.Layout().SetMargins(walk.Margins{})
 
+	searchEdit, err := walk.NewLineEdit(buttonsContainer)
+	if err != nil {
+		return nil, err
+	}
+	searchEdit.SetCueBanner(l18n.Sprintf("Search log"))
+	searchEdit.Accessibility().SetName(l18n.Sprintf("Search log"))
+	searchEdit.SetMinMaxSize(walk.Size{Width: 180}, walk.Size{Width: 180})
+	searchEdit.TextChanged().Attach(func() {
+		lp.model.setSearch(searchEdit.Text())
+		lp.onFilterChanged()
+	})
+
+	levelLabel, err := walk.NewTextLabel(buttonsContainer)
+	if err != nil {
+		return nil, err
+	}
+	levelLabel.SetText(l18n.Sprintf("S&how:"))
+	levelCB, err := walk.NewDropDownBox(buttonsContainer)
+	if err != nil {
+		return nil, err
+	}
+	levelCB.SetModel([]string{
+		l18n.Sprintf("All messages"),
+		l18n.Sprintf("Information and above"),
+		l18n.Sprintf("Warnings and errors"),
+		l18n.Sprintf("Errors only"),
+	})
+	levelCB.SetCurrentIndex(0)
+	levelCB.CurrentIndexChanged().Attach(func() {
+		if i := levelCB.CurrentIndex(); i >= 0 {
+			lp.model.setMinLevel(logFilterLevels[i])
+			lp.onFilterChanged()
+		}
+	})
+
+	if lp.componentCB, err = walk.NewDropDownBox(buttonsContainer); err != nil {
+		return nil, err
+	}
+	lp.componentCB.Accessibility().SetName(l18n.Sprintf("Component"))
+	lp.updateComponents()
+	lp.componentCB.CurrentIndexChanged().Attach(func() {
+		if lp.updatingComponents {
+			return
+		}
+		source, tunnel := lp.selectedComponent()
+		lp.model.setComponent(source, tunnel)
+		lp.onFilterChanged()
+	})
+
 	walk.NewHSpacer(buttonsContainer)
 
+	if lp.saveFilteredButton, err = walk.NewPushButton(buttonsContainer); err != nil {
+		return nil, err
+	}
+	lp.saveFilteredButton.SetText(l18n.Sprintf("Save fi&ltered"))
+	lp.saveFilteredButton.Clicked().Attach(lp.onSaveFiltered)
+
 	saveButton, err := walk.NewPushButton(buttonsContainer)
 	if err != nil {
 		return nil, err
@@ -115,11 +188,92 @@ func NewLogPage() (*LogPage, error) {
 	saveButton.SetText(l18n.Sprintf("&Save"))
 	saveButton.Clicked().Attach(lp.onSave)
 
+	diagnosticsButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return nil, err
+	}
+	diagnosticsButton.SetText(l18n.Sprintf("Export &diagnostics…"))
+	diagnosticsButton.Clicked().Attach(lp.onExportDiagnostics)
+
+	lp.onFilterChanged()
+
 	disposables.Spare()
 
 	return lp, nil
 }
 
+func (lp *LogPage) StyleCell(style *walk.CellStyle) {
+	styleThemedCell(style, true)
+	if style.Row() < 0 || style.Row() >= len(lp.model.items) {
+		return
+	}
+	switch lp.model.items[style.Row()].Level {
+	case ringlogger.LevelWarning:
+		style.TextColor = walk.RGB(0xc0, 0x70, 0x00)
+	case ringlogger.LevelError:
+		style.TextColor = walk.RGB(0xd0, 0x20, 0x20)
+	}
+}
+
+// The first entries of the component filter, which are followed by the tunnels named in the log.
+const (
+	logComponentAll = iota
+	logComponentManager
+	logComponentUI
+	logComponentTunnels
+)
+
+// updateComponents fills the component filter with the tunnels that the log has named so far,
+// keeping the current selection.
+func (lp *LogPage) updateComponents() {
+	source, tunnel := lp.selectedComponent()
+	items := []string{
+		l18n.Sprintf("All components"),
+		l18n.Sprintf("Manager"),
+		l18n.Sprintf("User interface"),
+	}
+	current := logComponentAll
+	switch source {
+	case "MGR":
+		current = logComponentManager
+	case "GUI":
+		current = logComponentUI
+	}
+	for i, name := range lp.model.tunnels {
+		items = append(items, l18n.Sprintf("Tunnel: %s", name))
+		if name == tunnel {
+			current = logComponentTunnels + i
+		}
+	}
+	lp.updatingComponents = true
+	lp.componentCB.SetModel(items)
+	lp.componentCB.SetCurrentIndex(current)
+	lp.updatingComponents = false
+}
+
+// selectedComponent returns the log tag or the tunnel that the component filter selects.
+func (lp *LogPage) selectedComponent() (source, tunnel string) {
+	i := lp.componentCB.CurrentIndex()
+	switch {
+	case i == logComponentManager:
+		source = "MGR"
+	case i == logComponentUI:
+		source = "GUI"
+	case i >= logComponentTunnels && i-logComponentTunnels < len(lp.model.tunnels):
+		tunnel = lp.model.tunnels[i-logComponentTunnels]
+	}
+	return
+}
+
+func (lp *LogPage) onFilterChanged() {
+	filtered := lp.model.isFiltered()
+	lp.saveFilteredAction.SetEnabled(filtered)
+	lp.saveFilteredButton.SetVisible(filtered)
+	if len(lp.model.items) > 0 {
+		lp.scrollToBottom()
+	}
+}
+
 func (lp *LogPage) isAtBottom() bool {
 	return len(lp.model.items) == 0 || lp.logView.ItemVisible(len(lp.model.items)-1)
 }
@@ -171,11 +325,144 @@ func (lp *LogPage) onSave() {
 	})
 }
 
+// onSaveFiltered writes only the lines that the filters let through, unlike onSave.
+func (lp *LogPage) onSaveFiltered() {
+	fd := walk.FileDialog{
+		Filter:   l18n.Sprintf("Text Files (*.txt)|*.txt|All Files (*.*)|*.*"),
+		FilePath: fmt.Sprintf("wireguard-log-filtered-%s.txt", time.Now().Format("2006-01-02T150405")),
+		Title:    l18n.Sprintf("Export filtered log to file"),
+	}
+
+	form := lp.Form()
+
+	if ok, _ := fd.ShowSave(form); !ok {
+		return
+	}
+
+	if fd.FilterIndex == 1 && !strings.HasSuffix(fd.FilePath, ".txt") {
+		fd.FilePath = fd.FilePath + ".txt"
+	}
+
+	items := lp.model.items
+	writeFileWithOverwriteHandling(form, fd.FilePath, func(file *os.File) error {
+		for _, item := range items {
+			if _, err := fmt.Fprintf(file, "%s: %s\n", item.Stamp.Format("2006-01-02 15:04:05.000000"), item.Line); err != nil {
+				return fmt.Errorf("exportFilteredLog: Write failed: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (lp *LogPage) onExportDiagnostics() {
+	fd := walk.FileDialog{
+		Filter:   l18n.Sprintf("ZIP Files (*.zip)|*.zip"),
+		FilePath: fmt.Sprintf("wireguard-diagnostics-%s.zip", time.Now().Format("2006-01-02T150405")),
+		Title:    l18n.Sprintf("Export diagnostics to file"),
+	}
+
+	form := lp.Form()
+
+	if ok, _ := fd.ShowSave(form); !ok {
+		return
+	}
+
+	if !strings.HasSuffix(fd.FilePath, ".zip") {
+		fd.FilePath += ".zip"
+	}
+
+	bundle, err := manager.IPCClientDiagnostics()
+	if err != nil {
+		showErrorCustom(form, l18n.Sprintf("Unable to collect diagnostics"), err.Error())
+		return
+	}
+
+	writeFileWithOverwriteHandling(form, fd.FilePath, func(file *os.File) error {
+		if _, err := file.Write(bundle); err != nil {
+			return fmt.Errorf("exportDiagnostics: Write failed: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// logItem is a log line as shown, with its severity and component spelled out.
+type logItem struct {
+	Stamp time.Time
+	Line  string
+	Level ringlogger.Level
+
+	source string // The tag of the process that wrote the line, such as MGR.
+	tunnel string // The tunnel that the line is about, if any.
+}
+
+func newLogItem(line *ringlogger.FollowLine) logItem {
+	item := logItem{Stamp: line.Stamp, Line: line.String(), Level: line.Level}
+	var subject string
+	item.source, subject = line.Tags()
+	if conf.TunnelNameIsValid(subject) {
+		item.tunnel = subject
+	}
+	return item
+}
+
 type logModel struct {
 	walk.ReflectTableModelBase
-	lp    *LogPage
-	quit  chan bool
-	items []ringlogger.FollowLine
+	lp       *LogPage
+	quit     chan bool
+	all      []logItem
+	items    []logItem
+	tunnels  []string // The tunnels named in the log, in the order of their first appearance.
+	minLevel ringlogger.Level
+	search   string
+	source   string
+	tunnel   string
+}
+
+func (mdl *logModel) matches(item *logItem) bool {
+	if item.Level < mdl.minLevel {
+		return false
+	}
+	if len(mdl.source) > 0 && item.source != mdl.source {
+		return false
+	}
+	if len(mdl.tunnel) > 0 && item.tunnel != mdl.tunnel {
+		return false
+	}
+	return len(mdl.search) == 0 || strings.Contains(strings.ToLower(item.Line), mdl.search)
+}
+
+func (mdl *logModel) isFiltered() bool {
+	return mdl.minLevel > logFilterLevels[0] || len(mdl.search) > 0 || len(mdl.source) > 0 || len(mdl.tunnel) > 0
+}
+
+// refilter applies the filters anew to all lines, rather than to those that passed before.
+func (mdl *logModel) refilter() {
+	mdl.items = mdl.items[:0:0]
+	for i := range mdl.all {
+		if mdl.matches(&mdl.all[i]) {
+			mdl.items = append(mdl.items, mdl.all[i])
+		}
+	}
+	mdl.PublishRowsReset()
+}
+
+func (mdl *logModel) setMinLevel(level ringlogger.Level) {
+	mdl.minLevel = level
+	mdl.refilter()
+}
+
+func (mdl *logModel) setSearch(text string) {
+	mdl.search = strings.ToLower(strings.TrimSpace(text))
+	mdl.refilter()
+}
+
+// setComponent limits the lines to those written by the process with the tag source, or to those
+// about tunnel, or neither if both are empty.
+func (mdl *logModel) setComponent(source, tunnel string) {
+	mdl.source, mdl.tunnel = source, tunnel
+	mdl.refilter()
 }
 
 func newLogModel(lp *LogPage) *logModel {
@@ -195,11 +482,28 @@ func newLogModel(lp *LogPage) *logModel {
 				mdl.lp.Synchronize(func() {
 					isAtBottom := mdl.lp.isAtBottom() && len(lp.logView.SelectedIndexes()) <= 1
 
-					mdl.items = append(mdl.items, items...)
+					newTunnels := false
+					for i := range items {
+						item := newLogItem(&items[i])
+						mdl.all = append(mdl.all, item)
+						if mdl.matches(&item) {
+							mdl.items = append(mdl.items, item)
+						}
+						if len(item.tunnel) > 0 && !mdl.hasTunnel(item.tunnel) {
+							mdl.tunnels = append(mdl.tunnels, item.tunnel)
+							newTunnels = true
+						}
+					}
+					if len(mdl.all) > maxLogLinesDisplayed {
+						mdl.all = mdl.all[len(mdl.all)-maxLogLinesDisplayed:]
+					}
 					if len(mdl.items) > maxLogLinesDisplayed {
 						mdl.items = mdl.items[len(mdl.items)-maxLogLinesDisplayed:]
 					}
 					mdl.PublishRowsReset()
+					if newTunnels {
+						mdl.lp.updateComponents()
+					}
 
 					if isAtBottom {
 						mdl.lp.scrollToBottom()
@@ -216,6 +520,15 @@ func newLogModel(lp *LogPage) *logModel {
 	return mdl
 }
 
+func (mdl *logModel) hasTunnel(name string) bool {
+	for _, tunnel := range mdl.tunnels {
+		if tunnel == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (mdl *logModel) Items() any {
 	return mdl.items
 }
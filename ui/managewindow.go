@@ -20,10 +20,11 @@ import (
 type ManageTunnelsWindow struct {
 	walk.FormBase
 
-	tabs        *walk.TabWidget
-	tunnelsPage *TunnelsPage
-	logPage     *LogPage
-	updatePage  *UpdatePage
+	tabs         *walk.TabWidget
+	tunnelsPage  *TunnelsPage
+	logPage      *LogPage
+	settingsPage *SettingsPage
+	updatePage   *UpdatePage
 
 	tunnelChangedCB *manager.TunnelChangeCallback
 }
@@ -32,6 +33,7 @@ const (
 	manageWindowWindowClass = "WireGuard UI - Manage Tunnels"
 	raiseMsg                = win.WM_USER + 0x3510
 	aboutWireGuardCmd       = 0x37
+	wmCopyGlobalData        = 0x0049
 )
 
 var taskbarButtonCreatedMsg uint32
@@ -64,6 +66,10 @@ func NewManageTunnelsWindow() (*ManageTunnelsWindow, error) {
 	}
 	disposables.Add(mtw)
 	win.ChangeWindowMessageFilterEx(mtw.Handle(), raiseMsg, win.MSGFLT_ALLOW, nil)
+	win.ChangeWindowMessageFilterEx(mtw.Handle(), win.WM_COPYDATA, win.MSGFLT_ALLOW, nil)
+	// Explorer runs unelevated, so files can only be dropped onto the elevated window if the messages of drag and drop are let through.
+	win.ChangeWindowMessageFilterEx(mtw.Handle(), win.WM_DROPFILES, win.MSGFLT_ALLOW, nil)
+	win.ChangeWindowMessageFilterEx(mtw.Handle(), wmCopyGlobalData, win.MSGFLT_ALLOW, nil)
 	mtw.SetPersistent(true)
 
 	if icon, err := loadLogoIcon(32); err == nil {
@@ -91,6 +97,7 @@ func NewManageTunnelsWindow() (*ManageTunnelsWindow, error) {
 			win.SetForegroundWindow(mtw.Handle())
 			win.BringWindowToTop(mtw.Handle())
 			mtw.logPage.scrollToBottom()
+			mtw.Synchronize(mtw.tunnelsPage.maybeRunOnboarding)
 		}
 	})
 
@@ -103,12 +110,22 @@ func NewManageTunnelsWindow() (*ManageTunnelsWindow, error) {
 	}
 	mtw.tabs.Pages().Add(mtw.tunnelsPage.TabPage)
 	mtw.tunnelsPage.CreateToolbar()
+	// The notification area does not pass drops on to tray icons, so only the window accepts them.
+	mtw.DropFiles().Attach(func(paths []string) {
+		mtw.tabs.SetCurrentIndex(0)
+		mtw.tunnelsPage.onDropFiles(paths)
+	})
 
 	if mtw.logPage, err = NewLogPage(); err != nil {
 		return nil, err
 	}
 	mtw.tabs.Pages().Add(mtw.logPage.TabPage)
 
+	if mtw.settingsPage, err = NewSettingsPage(); err != nil {
+		return nil, err
+	}
+	mtw.tabs.Pages().Add(mtw.settingsPage.TabPage)
+
 	mtw.tunnelChangedCB = manager.IPCClientRegisterTunnelChange(mtw.onTunnelChange)
 	globalState, _ := manager.IPCClientGlobalState()
 	mtw.onTunnelChange(nil, manager.TunnelUnknown, globalState, nil)
@@ -129,12 +146,24 @@ func NewManageTunnelsWindow() (*ManageTunnelsWindow, error) {
 		})
 	}
 
+	updateDarkMode()
+	applyTheme(mtw)
+
+	favoriteHotkeyWindow = mtw.Handle()
+	applyFavoriteHotkey()
+	refreshJumpList()
+
 	disposables.Spare()
 
 	return mtw, nil
 }
 
 func (mtw *ManageTunnelsWindow) Dispose() {
+	if favoriteHotkeyRegistered {
+		unregisterHotKey(windows.HWND(mtw.Handle()), favoriteHotkeyID)
+		favoriteHotkeyRegistered = false
+	}
+	favoriteHotkeyWindow = 0
 	if mtw.tunnelChangedCB != nil {
 		mtw.tunnelChangedCB.Unregister()
 		mtw.tunnelChangedCB = nil
@@ -165,7 +194,13 @@ func (mtw *ManageTunnelsWindow) onTunnelChange(tunnel *manager.Tunnel, state, gl
 	mtw.Synchronize(func() {
 		mtw.updateProgressIndicator(globalState)
 
-		if err != nil && mtw.Visible() {
+		if err == nil && tunnel != nil && state == manager.TunnelStarted {
+			noteRecentTunnel(tunnel.Name)
+			refreshJumpList()
+		}
+		if manager.IsAdapterCreationError(err) && mtw.Visible() {
+			showWarningCustom(mtw, l18n.Sprintf("Tunnel Error"), adapterErrorMessage())
+		} else if err != nil && mtw.Visible() {
 			errMsg := err.Error()
 			if len(errMsg) > 0 && errMsg[len(errMsg)-1] != '.' {
 				errMsg += "."
@@ -186,9 +221,14 @@ func (mtw *ManageTunnelsWindow) UpdateFound() {
 	if err == nil {
 		mtw.updatePage = updatePage
 		mtw.tabs.Pages().Add(updatePage.TabPage)
+		applyThemeToWidget(mtw.tabs)
 	}
 }
 
+func (mtw *ManageTunnelsWindow) showUpdatePage() {
+	mtw.tabs.SetCurrentIndex(mtw.tabs.Pages().Index(mtw.updatePage.TabPage))
+}
+
 func (mtw *ManageTunnelsWindow) WndProc(hwnd win.HWND, msg uint32, wParam, lParam uintptr) uintptr {
 	switch msg {
 	case win.WM_QUERYENDSESSION:
@@ -199,6 +239,25 @@ func (mtw *ManageTunnelsWindow) WndProc(hwnd win.HWND, msg uint32, wParam, lPara
 		if lParam == win.ENDSESSION_CLOSEAPP && wParam == 1 {
 			walk.App().Exit(198)
 		}
+	case win.WM_SETTINGCHANGE:
+		if lParam != 0 && windows.UTF16PtrToString(*(**uint16)(unsafe.Pointer(&lParam))) == "ImmersiveColorSet" && updateDarkMode() {
+			applyTheme(mtw)
+		}
+	case win.WM_SYSCOLORCHANGE:
+		if updateDarkMode() {
+			applyTheme(mtw)
+		}
+	case win.WM_HOTKEY:
+		if wParam == favoriteHotkeyID {
+			toggleFavoriteTunnel(mtw, func(title, message string) {
+				showErrorCustom(nil, title, message)
+			})
+			return 0
+		}
+	case win.WM_COPYDATA:
+		if mtw.tunnelsPage != nil && mtw.onJumpListTask(lParam) {
+			return win.TRUE
+		}
 	case win.WM_SYSCOMMAND:
 		if wParam == aboutWireGuardCmd {
 			onAbout(mtw)
@@ -213,12 +272,12 @@ func (mtw *ManageTunnelsWindow) WndProc(hwnd win.HWND, msg uint32, wParam, lPara
 		}
 		if !mtw.Visible() {
 			mtw.tunnelsPage.listView.SelectFirstActiveTunnel()
-			if mtw.tabs.Pages().Len() != 3 {
+			if mtw.updatePage == nil {
 				mtw.tabs.SetCurrentIndex(0)
 			}
 		}
-		if mtw.tabs.Pages().Len() == 3 {
-			mtw.tabs.SetCurrentIndex(2)
+		if mtw.updatePage != nil {
+			mtw.showUpdatePage()
 		}
 		raise(mtw.Handle())
 		return 0
@@ -0,0 +1,146 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"github.com/lxn/walk"
+	"golang.org/x/sys/windows/registry"
+
+	"golang.zx2c4.com/wireguard/windows/l18n"
+)
+
+// The onboarding dialog greets users who open the window for the first time and find it empty.
+// Whether it has been shown is remembered per user, rather than per computer, since each user
+// sees the window afresh.
+
+const (
+	onboardingRegKey   = `Software\WireGuard`
+	onboardingRegValue = "OnboardingShown"
+)
+
+func onboardingShown() bool {
+	key, err := registry.OpenKey(registry.CURRENT_USER, onboardingRegKey, registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	defer key.Close()
+	shown, _, err := key.GetIntegerValue(onboardingRegValue)
+	return err == nil && shown != 0
+}
+
+func setOnboardingShown() {
+	key, _, err := registry.CreateKey(registry.CURRENT_USER, onboardingRegKey, registry.SET_VALUE)
+	if err != nil {
+		return
+	}
+	defer key.Close()
+	key.SetDWordValue(onboardingRegValue, 1)
+}
+
+// maybeRunOnboarding shows the onboarding dialog if there are no tunnels yet and the user has
+// not seen it before, and then carries out what the user chose in it.
+func (tp *TunnelsPage) maybeRunOnboarding() {
+	if tp.listView.model.RowCount() > 0 || onboardingShown() {
+		return
+	}
+	setOnboardingShown()
+	next, err := runOnboardingDialog(tp.Form(), tp)
+	if showError(err, tp.Form()) || next == nil {
+		return
+	}
+	next()
+}
+
+func runOnboardingDialog(owner walk.Form, tp *TunnelsPage) (func(), error) {
+	var disposables walk.Disposables
+	defer disposables.Treat()
+
+	dlg, err := walk.NewDialogWithFixedSize(owner)
+	if err != nil {
+		return nil, err
+	}
+	disposables.Add(dlg)
+	dlg.SetTitle(l18n.Sprintf("Welcome to WireGuard"))
+	vbl := walk.NewVBoxLayout()
+	vbl.SetMargins(walk.Margins{HNear: 20, VNear: 20, HFar: 20, VFar: 20})
+	vbl.SetSpacing(10)
+	dlg.SetLayout(vbl)
+	if icon, err := loadLogoIcon(32); err == nil {
+		dlg.SetIcon(icon)
+	}
+
+	headingLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return nil, err
+	}
+	if font, err := walk.NewFont(dlg.Font().Family(), 12, walk.FontBold); err == nil {
+		headingLabel.SetFont(font)
+	}
+	headingLabel.SetText(l18n.Sprintf("Welcome to WireGuard"))
+
+	introLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return nil, err
+	}
+	introLabel.SetMinMaxSize(walk.Size{Width: 420}, walk.Size{Width: 420})
+
+	var next func()
+	if IsAdmin {
+		introLabel.SetText(l18n.Sprintf("There are no tunnels yet. A tunnel is usually set up with a configuration from whoever runs the server, as a .conf file or as a QR code. How would you like to add your first tunnel?"))
+		for _, choice := range []struct {
+			text    string
+			handler func()
+		}{
+			{l18n.Sprintf("&Import tunnel(s) from file…"), tp.onImport},
+			{l18n.Sprintf("Scan &QR code on screen…"), tp.onImportFromScreen},
+			{l18n.Sprintf("Set up a new tunnel &step by step…"), tp.onAddTunnelWithWizard},
+		} {
+			handler := choice.handler
+			button, err := walk.NewPushButton(dlg)
+			if err != nil {
+				return nil, err
+			}
+			button.SetText(choice.text)
+			button.Clicked().Attach(func() {
+				next = handler
+				dlg.Accept()
+			})
+		}
+	} else {
+		introLabel.SetText(l18n.Sprintf("There are no tunnels yet. Tunnels can only be added by an administrator, after which you will find them here."))
+	}
+
+	adminLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return nil, err
+	}
+	adminLabel.SetMinMaxSize(walk.Size{Width: 420}, walk.Size{Width: 420})
+	adminLabel.SetText(l18n.Sprintf("Adding, editing and removing tunnels requires administrator rights, because tunnels change how the whole computer reaches the network. Administrators can let other users activate and deactivate existing tunnels by adding them to the Network Configuration Operators group and setting the LimitedOperatorUI policy."))
+
+	buttonsContainer, err := walk.NewComposite(dlg)
+	if err != nil {
+		return nil, err
+	}
+	hbl := walk.NewHBoxLayout()
+	hbl.SetMargins(walk.Margins{})
+	buttonsContainer.SetLayout(hbl)
+	walk.NewHSpacer(buttonsContainer)
+	closeButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return nil, err
+	}
+	closeButton.SetText(l18n.Sprintf("Close"))
+	closeButton.Clicked().Attach(dlg.Cancel)
+	dlg.SetCancelButton(closeButton)
+
+	applyTheme(dlg)
+
+	disposables.Spare()
+
+	dlg.Run()
+
+	return next, nil
+}
@@ -0,0 +1,115 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"github.com/lxn/walk"
+
+	"golang.zx2c4.com/wireguard/windows/l18n"
+)
+
+// runPassphraseDialog asks for the passphrase of an encrypted archive. When choosing a new one,
+// confirm asks for it twice, so that a typo does not make the archive impossible to open.
+func runPassphraseDialog(owner walk.Form, title, prompt string, confirm bool) (passphrase string, ok bool, err error) {
+	var disposables walk.Disposables
+	defer disposables.Treat()
+
+	dlg, err := walk.NewDialog(owner)
+	if err != nil {
+		return
+	}
+	disposables.Add(dlg)
+	dlg.SetTitle(title)
+	layout := walk.NewGridLayout()
+	layout.SetSpacing(6)
+	layout.SetMargins(walk.Margins{HNear: 10, VNear: 10, HFar: 10, VFar: 10})
+	dlg.SetLayout(layout)
+	if icon, err := loadLogoIcon(32); err == nil {
+		dlg.SetIcon(icon)
+	}
+
+	promptLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return
+	}
+	layout.SetRange(promptLabel, walk.Rectangle{X: 0, Y: 0, Width: 2, Height: 1})
+	promptLabel.SetMinMaxSize(walk.Size{Width: 350}, walk.Size{Width: 350})
+	promptLabel.SetText(prompt)
+
+	passphraseLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return
+	}
+	layout.SetRange(passphraseLabel, walk.Rectangle{X: 0, Y: 1, Width: 1, Height: 1})
+	passphraseLabel.SetTextAlignment(walk.AlignHFarVCenter)
+	passphraseLabel.SetText(l18n.Sprintf("&Passphrase:"))
+	passphraseEdit, err := walk.NewLineEdit(dlg)
+	if err != nil {
+		return
+	}
+	layout.SetRange(passphraseEdit, walk.Rectangle{X: 1, Y: 1, Width: 1, Height: 1})
+	passphraseEdit.SetPasswordMode(true)
+
+	var confirmEdit *walk.LineEdit
+	if confirm {
+		confirmLabel, err := walk.NewTextLabel(dlg)
+		if err != nil {
+			return "", false, err
+		}
+		layout.SetRange(confirmLabel, walk.Rectangle{X: 0, Y: 2, Width: 1, Height: 1})
+		confirmLabel.SetTextAlignment(walk.AlignHFarVCenter)
+		confirmLabel.SetText(l18n.Sprintf("&Confirm:"))
+		confirmEdit, err = walk.NewLineEdit(dlg)
+		if err != nil {
+			return "", false, err
+		}
+		layout.SetRange(confirmEdit, walk.Rectangle{X: 1, Y: 2, Width: 1, Height: 1})
+		confirmEdit.SetPasswordMode(true)
+	}
+
+	buttonsContainer, err := walk.NewComposite(dlg)
+	if err != nil {
+		return
+	}
+	layout.SetRange(buttonsContainer, walk.Rectangle{X: 0, Y: 3, Width: 2, Height: 1})
+	hbl := walk.NewHBoxLayout()
+	hbl.SetMargins(walk.Margins{})
+	buttonsContainer.SetLayout(hbl)
+	walk.NewHSpacer(buttonsContainer)
+	okButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return
+	}
+	okButton.SetText(l18n.Sprintf("OK"))
+	cancelButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return
+	}
+	cancelButton.SetText(l18n.Sprintf("Cancel"))
+	cancelButton.Clicked().Attach(dlg.Cancel)
+	dlg.SetDefaultButton(okButton)
+	dlg.SetCancelButton(cancelButton)
+
+	okButton.Clicked().Attach(func() {
+		if len(passphraseEdit.Text()) == 0 {
+			showErrorCustom(dlg, l18n.Sprintf("Invalid passphrase"), l18n.Sprintf("The passphrase cannot be empty."))
+			return
+		}
+		if confirmEdit != nil && confirmEdit.Text() != passphraseEdit.Text() {
+			showErrorCustom(dlg, l18n.Sprintf("Invalid passphrase"), l18n.Sprintf("The passphrases do not match."))
+			return
+		}
+		passphrase = passphraseEdit.Text()
+		dlg.Accept()
+	})
+
+	applyTheme(dlg)
+
+	disposables.Spare()
+
+	ok = dlg.Run() == walk.DlgCmdOK
+	return
+}
@@ -0,0 +1,247 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lxn/walk"
+
+	"golang.zx2c4.com/wireguard/windows/l18n"
+	"golang.zx2c4.com/wireguard/windows/manager"
+)
+
+const peersRefreshInterval = time.Second
+
+type peersModel struct {
+	walk.TableModelBase
+	statuses []manager.PeerStatus
+}
+
+func (m *peersModel) RowCount() int {
+	return len(m.statuses)
+}
+
+func (m *peersModel) Value(row, col int) any {
+	if row < 0 || row >= len(m.statuses) {
+		return ""
+	}
+	status := &m.statuses[row]
+	switch col {
+	case 0:
+		return status.Name
+	case 1:
+		if status.PublicKey.IsZero() {
+			return ""
+		}
+		return status.PublicKey.String()
+	case 2:
+		if status.Endpoint.IsEmpty() {
+			return ""
+		}
+		return status.Endpoint.String()
+	case 3:
+		if status.LastHandshakeTime.IsEmpty() {
+			return l18n.Sprintf("Never")
+		}
+		return status.LastHandshakeTime.String()
+	case 4:
+		return status.RxBytes.String()
+	case 5:
+		return status.TxBytes.String()
+	}
+	return ""
+}
+
+type peersDialog struct {
+	*walk.Dialog
+	tunnel    *manager.Tunnel
+	peersView *walk.TableView
+	model     *peersModel
+	status    *walk.TextLabel
+}
+
+func onShowPeers(owner walk.Form, tunnel *manager.Tunnel) {
+	showError(runPeersDialog(owner, tunnel), owner)
+}
+
+// runPeersDialog shows a live table of the peers of a tunnel, in the manner of `wg show`, with
+// those that have not completed a handshake recently highlighted.
+func runPeersDialog(owner walk.Form, tunnel *manager.Tunnel) error {
+	var disposables walk.Disposables
+	defer disposables.Treat()
+
+	pd := &peersDialog{tunnel: tunnel, model: new(peersModel)}
+	var err error
+	pd.Dialog, err = walk.NewDialog(owner)
+	if err != nil {
+		return err
+	}
+	disposables.Add(pd)
+	pd.SetTitle(l18n.Sprintf("Peers: %s", tunnel.Name))
+	vbl := walk.NewVBoxLayout()
+	vbl.SetMargins(walk.Margins{HNear: 10, VNear: 10, HFar: 10, VFar: 10})
+	pd.SetLayout(vbl)
+	pd.SetMinMaxSize(walk.Size{Width: 800, Height: 400}, walk.Size{})
+	if icon, err := loadLogoIcon(32); err == nil {
+		pd.SetIcon(icon)
+	}
+
+	if pd.peersView, err = walk.NewTableView(pd); err != nil {
+		return err
+	}
+	pd.peersView.SetAlternatingRowBG(true)
+	pd.peersView.SetLastColumnStretched(true)
+	pd.peersView.SetGridlines(true)
+	pd.peersView.SetCellStyler(pd)
+	for _, column := range []struct {
+		title string
+		width int
+	}{
+		{l18n.Sprintf("Name"), 100},
+		{l18n.Sprintf("Public key"), 300},
+		{l18n.Sprintf("Endpoint"), 140},
+		{l18n.Sprintf("Latest handshake"), 140},
+		{l18n.Sprintf("Received"), 80},
+		{l18n.Sprintf("Sent"), 80},
+	} {
+		col := walk.NewTableViewColumn()
+		col.SetTitle(column.title)
+		col.SetWidth(column.width)
+		pd.peersView.Columns().Add(col)
+	}
+	pd.peersView.SetModel(pd.model)
+
+	buttonsContainer, err := walk.NewComposite(pd)
+	if err != nil {
+		return err
+	}
+	hbl := walk.NewHBoxLayout()
+	hbl.SetMargins(walk.Margins{})
+	buttonsContainer.SetLayout(hbl)
+	if pd.status, err = walk.NewTextLabel(buttonsContainer); err != nil {
+		return err
+	}
+	walk.NewHSpacer(buttonsContainer)
+	exportButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return err
+	}
+	exportButton.SetText(l18n.Sprintf("&Export to CSV…"))
+	exportButton.Clicked().Attach(pd.onExport)
+	closeButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return err
+	}
+	closeButton.SetText(l18n.Sprintf("Close"))
+	closeButton.Clicked().Attach(pd.Accept)
+	pd.SetDefaultButton(closeButton)
+	pd.SetCancelButton(closeButton)
+
+	applyTheme(pd)
+
+	disposables.Spare()
+
+	done := make(chan struct{})
+	go pd.refresh(done)
+	pd.Run()
+	close(done)
+
+	return nil
+}
+
+func (pd *peersDialog) refresh(done chan struct{}) {
+	ticker := time.NewTicker(peersRefreshInterval)
+	defer ticker.Stop()
+	for {
+		statuses, err := pd.tunnel.PeerStatuses()
+		select {
+		case <-done:
+			return
+		default:
+		}
+		pd.Synchronize(func() {
+			if err != nil {
+				pd.model.statuses = nil
+				pd.status.SetText(l18n.Sprintf("The tunnel is not running."))
+			} else {
+				pd.model.statuses = statuses
+				stale := 0
+				for i := range statuses {
+					if statuses[i].Stale {
+						stale++
+					}
+				}
+				pd.status.SetText(l18n.Sprintf("%d peers, %d without a recent handshake", len(statuses), stale))
+			}
+			pd.model.PublishRowsReset()
+		})
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (pd *peersDialog) StyleCell(style *walk.CellStyle) {
+	styleThemedCell(style, true)
+	if style.Row() < 0 || style.Row() >= len(pd.model.statuses) {
+		return
+	}
+	if pd.model.statuses[style.Row()].Stale {
+		style.TextColor = walk.RGB(0xd0, 0x20, 0x20)
+	}
+}
+
+func (pd *peersDialog) onExport() {
+	fd := walk.FileDialog{
+		Filter:   l18n.Sprintf("CSV Files (*.csv)|*.csv|All Files (*.*)|*.*"),
+		FilePath: fmt.Sprintf("wireguard-peers-%s-%s.csv", pd.tunnel.Name, time.Now().Format("2006-01-02T150405")),
+		Title:    l18n.Sprintf("Export peers to file"),
+	}
+	if ok, _ := fd.ShowSave(pd); !ok {
+		return
+	}
+	if !strings.HasSuffix(fd.FilePath, ".csv") {
+		fd.FilePath += ".csv"
+	}
+
+	statuses := pd.model.statuses
+	writeFileWithOverwriteHandling(pd, fd.FilePath, func(file *os.File) error {
+		writer := csv.NewWriter(file)
+		writer.Write([]string{"name", "public_key", "endpoint", "latest_handshake", "rx_bytes", "tx_bytes", "stale"})
+		for i := range statuses {
+			status := &statuses[i]
+			var publicKey, endpoint, lastHandshake string
+			if !status.PublicKey.IsZero() {
+				publicKey = status.PublicKey.String()
+			}
+			if !status.Endpoint.IsEmpty() {
+				endpoint = status.Endpoint.String()
+			}
+			if !status.LastHandshakeTime.IsEmpty() {
+				lastHandshake = time.Unix(0, 0).Add(time.Duration(status.LastHandshakeTime)).UTC().Format(time.RFC3339)
+			}
+			writer.Write([]string{
+				status.Name,
+				publicKey,
+				endpoint,
+				lastHandshake,
+				strconv.FormatUint(uint64(status.RxBytes), 10),
+				strconv.FormatUint(uint64(status.TxBytes), 10),
+				strconv.FormatBool(status.Stale),
+			})
+		}
+		writer.Flush()
+		return writer.Error()
+	})
+}
@@ -0,0 +1,196 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lxn/walk"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/l18n"
+	"golang.zx2c4.com/wireguard/windows/manager"
+)
+
+// Profiles are edited as text, in the style of a configuration file: each [Profile] section may
+// be followed by [Peer] sections, which amend the peers of the tunnel with the same public key.
+
+func onProfiles(owner walk.Form, tunnel *manager.Tunnel) {
+	showError(runProfilesDialog(owner, tunnel), owner)
+}
+
+func splitProfileList(value string) []string {
+	var list []string
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); len(field) > 0 {
+			list = append(list, field)
+		}
+	}
+	return list
+}
+
+func parseProfiles(text string) ([]conf.Profile, error) {
+	var profiles []conf.Profile
+	var peer *conf.ProfilePeer
+	for i, line := range strings.Split(text, "\n") {
+		if comment := strings.IndexByte(line, '#'); comment >= 0 {
+			line = line[:comment]
+		}
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if strings.EqualFold(line, "[Profile]") {
+			profiles = append(profiles, conf.Profile{})
+			peer = nil
+			continue
+		}
+		if strings.EqualFold(line, "[Peer]") {
+			if len(profiles) == 0 {
+				return nil, errors.New(l18n.Sprintf("Line %d: [Peer] must follow a [Profile]", i+1))
+			}
+			profile := &profiles[len(profiles)-1]
+			profile.Peers = append(profile.Peers, conf.ProfilePeer{})
+			peer = &profile.Peers[len(profile.Peers)-1]
+			continue
+		}
+		equals := strings.IndexByte(line, '=')
+		if equals < 0 || len(profiles) == 0 {
+			return nil, errors.New(l18n.Sprintf("Line %d is not understood: %s", i+1, line))
+		}
+		key, value := strings.ToLower(strings.TrimSpace(line[:equals])), strings.TrimSpace(line[equals+1:])
+		profile := &profiles[len(profiles)-1]
+		switch {
+		case peer == nil && key == "name":
+			profile.Name = value
+		case peer == nil && key == "ssids":
+			profile.SSIDs = splitProfileList(value)
+		case peer == nil && key == "ethernet":
+			profile.Ethernet = strings.EqualFold(value, "true")
+		case peer == nil && key == "dns":
+			profile.DNS = splitProfileList(value)
+		case peer != nil && key == "publickey":
+			peer.PublicKey = value
+		case peer != nil && key == "endpoint":
+			peer.Endpoint = value
+		case peer != nil && key == "allowedips":
+			peer.AllowedIPs = splitProfileList(value)
+		default:
+			return nil, errors.New(l18n.Sprintf("Line %d is not understood: %s", i+1, line))
+		}
+	}
+	return profiles, nil
+}
+
+func formatProfiles(profiles []conf.Profile) string {
+	var text strings.Builder
+	for i, profile := range profiles {
+		if i > 0 {
+			text.WriteString("\r\n")
+		}
+		fmt.Fprintf(&text, "[Profile]\r\nName = %s\r\n", profile.Name)
+		if len(profile.SSIDs) > 0 {
+			fmt.Fprintf(&text, "SSIDs = %s\r\n", strings.Join(profile.SSIDs, ", "))
+		}
+		if profile.Ethernet {
+			text.WriteString("Ethernet = true\r\n")
+		}
+		if len(profile.DNS) > 0 {
+			fmt.Fprintf(&text, "DNS = %s\r\n", strings.Join(profile.DNS, ", "))
+		}
+		for _, peer := range profile.Peers {
+			fmt.Fprintf(&text, "\r\n[Peer]\r\nPublicKey = %s\r\n", peer.PublicKey)
+			if len(peer.Endpoint) > 0 {
+				fmt.Fprintf(&text, "Endpoint = %s\r\n", peer.Endpoint)
+			}
+			if len(peer.AllowedIPs) > 0 {
+				fmt.Fprintf(&text, "AllowedIPs = %s\r\n", strings.Join(peer.AllowedIPs, ", "))
+			}
+		}
+	}
+	return text.String()
+}
+
+func runProfilesDialog(owner walk.Form, tunnel *manager.Tunnel) error {
+	profiles, err := tunnel.Profiles()
+	if err != nil {
+		return err
+	}
+
+	var disposables walk.Disposables
+	defer disposables.Treat()
+
+	dlg, err := walk.NewDialog(owner)
+	if err != nil {
+		return err
+	}
+	disposables.Add(dlg)
+	dlg.SetTitle(l18n.Sprintf("Profiles: %s", tunnel.Name))
+	vbl := walk.NewVBoxLayout()
+	vbl.SetMargins(walk.Margins{HNear: 10, VNear: 10, HFar: 10, VFar: 10})
+	dlg.SetLayout(vbl)
+	dlg.SetMinMaxSize(walk.Size{Width: 500, Height: 400}, walk.Size{})
+	if icon, err := loadLogoIcon(32); err == nil {
+		dlg.SetIcon(icon)
+	}
+
+	profilesLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return err
+	}
+	profilesLabel.SetText(l18n.Sprintf("&Profiles are used on the Wi-Fi networks in their SSIDs, or on Ethernet, the first match winning, and override the DNS servers, and the endpoints and allowed IPs of the peers with the given public keys:"))
+	profilesEdit, err := walk.NewTextEdit(dlg)
+	if err != nil {
+		return err
+	}
+	profilesEdit.SetText(formatProfiles(profiles.Profiles))
+
+	buttonsContainer, err := walk.NewComposite(dlg)
+	if err != nil {
+		return err
+	}
+	hbl := walk.NewHBoxLayout()
+	hbl.SetMargins(walk.Margins{})
+	buttonsContainer.SetLayout(hbl)
+	walk.NewHSpacer(buttonsContainer)
+	saveButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return err
+	}
+	saveButton.SetText(l18n.Sprintf("&Save"))
+	cancelButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return err
+	}
+	cancelButton.SetText(l18n.Sprintf("Cancel"))
+	cancelButton.Clicked().Attach(dlg.Cancel)
+	dlg.SetCancelButton(cancelButton)
+
+	saveButton.Clicked().Attach(func() {
+		newProfiles, err := parseProfiles(profilesEdit.Text())
+		if err != nil {
+			showErrorCustom(dlg, l18n.Sprintf("Invalid profiles"), err.Error())
+			return
+		}
+		profiles.Profiles = newProfiles
+		err = tunnel.SetProfiles(&profiles)
+		if err != nil {
+			showErrorCustom(dlg, l18n.Sprintf("Unable to save profiles"), err.Error())
+			return
+		}
+		dlg.Accept()
+	})
+
+	applyTheme(dlg)
+
+	disposables.Spare()
+
+	dlg.Run()
+
+	return nil
+}
@@ -0,0 +1,189 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lxn/walk"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/l18n"
+	"golang.zx2c4.com/wireguard/windows/manager"
+	"golang.zx2c4.com/wireguard/windows/ui/qrcode"
+)
+
+func onCreateClient(owner walk.Form, tunnel *manager.Tunnel) {
+	showError(runProvisionDialog(owner, tunnel), owner)
+}
+
+// runProvisionDialog creates clients for a hub tunnel one after another. Each new client is added
+// to the hub as a peer right away, so that its configuration works as soon as it is handed over.
+func runProvisionDialog(owner walk.Form, tunnel *manager.Tunnel) error {
+	hub, err := tunnel.StoredConfig()
+	if err != nil {
+		return err
+	}
+	pools, err := tunnel.AddressPools()
+	if err != nil {
+		return err
+	}
+	if !hub.IsHub() {
+		showWarningCustom(owner, l18n.Sprintf("Not a hub"), l18n.Sprintf("Clients can only be created for tunnels with a listen port and at least one address, from whose subnets the clients are given addresses."))
+		return nil
+	}
+
+	var disposables walk.Disposables
+	defer disposables.Treat()
+
+	dlg, err := walk.NewDialog(owner)
+	if err != nil {
+		return err
+	}
+	disposables.Add(dlg)
+	dlg.SetTitle(l18n.Sprintf("New client for %s", tunnel.Name))
+	layout := walk.NewGridLayout()
+	layout.SetSpacing(6)
+	layout.SetMargins(walk.Margins{HNear: 10, VNear: 10, HFar: 10, VFar: 10})
+	dlg.SetLayout(layout)
+	if icon, err := loadLogoIcon(32); err == nil {
+		dlg.SetIcon(icon)
+	}
+
+	nameLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return err
+	}
+	layout.SetRange(nameLabel, walk.Rectangle{X: 0, Y: 0, Width: 1, Height: 1})
+	nameLabel.SetTextAlignment(walk.AlignHFarVCenter)
+	nameLabel.SetText(l18n.Sprintf("&Name:"))
+	nameEdit, err := walk.NewLineEdit(dlg)
+	if err != nil {
+		return err
+	}
+	layout.SetRange(nameEdit, walk.Rectangle{X: 1, Y: 0, Width: 1, Height: 1})
+
+	endpointLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return err
+	}
+	layout.SetRange(endpointLabel, walk.Rectangle{X: 0, Y: 1, Width: 1, Height: 1})
+	endpointLabel.SetTextAlignment(walk.AlignHFarVCenter)
+	endpointLabel.SetText(l18n.Sprintf("Hub &endpoint:"))
+	endpointEdit, err := walk.NewLineEdit(dlg)
+	if err != nil {
+		return err
+	}
+	layout.SetRange(endpointEdit, walk.Rectangle{X: 1, Y: 1, Width: 1, Height: 1})
+	endpointEdit.SetCueBanner(fmt.Sprintf("vpn.example.com:%d", hub.Interface.ListenPort))
+	endpointEdit.SetToolTipText(l18n.Sprintf("The public host name or IP address and port at which clients reach this computer."))
+
+	var code *qrcode.Code
+	codeView, err := newQRCodeView(dlg, func() *qrcode.Code { return code })
+	if err != nil {
+		return err
+	}
+	layout.SetRange(codeView, walk.Rectangle{X: 0, Y: 2, Width: 2, Height: 1})
+
+	hintLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return err
+	}
+	layout.SetRange(hintLabel, walk.Rectangle{X: 0, Y: 3, Width: 2, Height: 1})
+	hintLabel.SetMinMaxSize(walk.Size{Width: 400}, walk.Size{Width: 400})
+	hintLabel.SetText(l18n.Sprintf("The client is given the next free address from the address pools of %s, and is added to it as a new peer.", tunnel.Name))
+
+	buttonsContainer, err := walk.NewComposite(dlg)
+	if err != nil {
+		return err
+	}
+	layout.SetRange(buttonsContainer, walk.Rectangle{X: 0, Y: 4, Width: 2, Height: 1})
+	hbl := walk.NewHBoxLayout()
+	hbl.SetMargins(walk.Margins{})
+	buttonsContainer.SetLayout(hbl)
+	createButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return err
+	}
+	createButton.SetText(l18n.Sprintf("&Create"))
+	saveButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return err
+	}
+	saveButton.SetText(l18n.Sprintf("&Save to file…"))
+	saveButton.SetEnabled(false)
+	walk.NewHSpacer(buttonsContainer)
+	closeButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return err
+	}
+	closeButton.SetText(l18n.Sprintf("Close"))
+	closeButton.Clicked().Attach(dlg.Accept)
+	dlg.SetDefaultButton(createButton)
+	dlg.SetCancelButton(closeButton)
+
+	var client *conf.Config
+	createButton.Clicked().Attach(func() {
+		newClient, peer, err := hub.NewClient(strings.TrimSpace(nameEdit.Text()), strings.TrimSpace(endpointEdit.Text()), &pools)
+		if err != nil {
+			showErrorCustom(dlg, l18n.Sprintf("Unable to create client"), err.Error())
+			return
+		}
+		err = tunnel.AddPeer(peer, newClient.Name)
+		if err != nil {
+			showErrorCustom(dlg, l18n.Sprintf("Unable to add client to %s", tunnel.Name), err.Error())
+			return
+		}
+		// The next client must not be given the same address.
+		hub.Peers = append(hub.Peers, *peer)
+		pools.Allocate(&hub, &peer.PublicKey, newClient.Name, peer.AllowedIPs)
+		client = newClient
+		code = encodeQRCode(client.ToWgQuick())
+		addresses := make([]string, len(peer.AllowedIPs))
+		for i, a := range peer.AllowedIPs {
+			addresses[i] = a.String()
+		}
+		if code == nil {
+			hintLabel.SetText(l18n.Sprintf("Client %s was added with address %s. Its configuration is too large to fit into a QR code, so save it to a file instead.", client.Name, strings.Join(addresses, ", ")))
+		} else {
+			hintLabel.SetText(l18n.Sprintf("Client %s was added with address %s. Scan this QR code on the new device, or save its configuration to a file. It contains the private key of the new device.", client.Name, strings.Join(addresses, ", ")))
+		}
+		saveButton.SetEnabled(true)
+		dlg.SetDefaultButton(saveButton)
+		codeView.Invalidate()
+	})
+	saveButton.Clicked().Attach(func() {
+		if client == nil {
+			return
+		}
+		fd := walk.FileDialog{
+			Filter:   l18n.Sprintf("Configuration Files (*.conf)|*.conf|All Files (*.*)|*.*"),
+			FilePath: client.Name + ".conf",
+			Title:    l18n.Sprintf("Save client configuration"),
+		}
+		if ok, _ := fd.ShowSave(dlg); !ok {
+			return
+		}
+		if !strings.HasSuffix(fd.FilePath, ".conf") {
+			fd.FilePath += ".conf"
+		}
+		text := client.ToWgQuick()
+		writeFileWithOverwriteHandling(dlg, fd.FilePath, func(file *os.File) error {
+			_, err := file.WriteString(text)
+			return err
+		})
+	})
+
+	applyTheme(dlg)
+
+	disposables.Spare()
+
+	dlg.Run()
+
+	return nil
+}
@@ -0,0 +1,634 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package qrcode
+
+import (
+	"errors"
+	"image"
+	"math"
+	"sort"
+)
+
+// ErrNotFound is returned by Decode when there is no readable QR code in an image.
+var ErrNotFound = errors.New("No readable QR code found")
+
+var errCorrupt = errors.New("QR code is corrupt")
+
+// Decode finds a QR code in the image and returns its contents. Since the image is assumed to be
+// computer generated, the code must be upright or rotated by a right angle, and neither skewed
+// nor blurred.
+func Decode(img image.Image) ([]byte, error) {
+	bits := binarize(img)
+	for _, finders := range finderTriples(findFinderPatterns(bits)) {
+		estimate := finders.dimension()
+		for _, dimension := range []int{estimate, estimate - 4, estimate + 4} {
+			if dimension < 21 || dimension > 177 {
+				continue
+			}
+			data, err := decodeCode(finders.sample(bits, dimension))
+			if err == nil {
+				return data, nil
+			}
+		}
+	}
+	return nil, ErrNotFound
+}
+
+type bitmap struct {
+	width, height int
+	dark          []bool
+}
+
+func (b *bitmap) at(x, y int) bool {
+	if x < 0 || y < 0 || x >= b.width || y >= b.height {
+		return false
+	}
+	return b.dark[y*b.width+x]
+}
+
+func binarize(img image.Image) *bitmap {
+	bounds := img.Bounds()
+	b := &bitmap{width: bounds.Dx(), height: bounds.Dy(), dark: make([]bool, bounds.Dx()*bounds.Dy())}
+	switch img := img.(type) {
+	case *image.Gray:
+		for y := 0; y < b.height; y++ {
+			row := img.Pix[y*img.Stride:]
+			for x := 0; x < b.width; x++ {
+				b.dark[y*b.width+x] = row[x] < 0x80
+			}
+		}
+	case *image.RGBA:
+		for y := 0; y < b.height; y++ {
+			row := img.Pix[y*img.Stride:]
+			for x := 0; x < b.width; x++ {
+				r, g, b2 := uint32(row[x*4]), uint32(row[x*4+1]), uint32(row[x*4+2])
+				b.dark[y*b.width+x] = (299*r+587*g+114*b2)/1000 < 0x80
+			}
+		}
+	default:
+		for y := 0; y < b.height; y++ {
+			for x := 0; x < b.width; x++ {
+				r, g, b2, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+				b.dark[y*b.width+x] = (299*r+587*g+114*b2)/1000 < 0x8000
+			}
+		}
+	}
+	return b
+}
+
+type finderPattern struct {
+	x, y       float64
+	moduleSize float64
+	count      int
+}
+
+// finderRatio reports whether the runs of dark, light, dark, light and dark pixels have the
+// 1:1:3:1:1 proportions of a finder pattern.
+func finderRatio(counts *[5]int) bool {
+	total := 0
+	for _, count := range counts {
+		if count == 0 {
+			return false
+		}
+		total += count
+	}
+	if total < 7 {
+		return false
+	}
+	moduleSize := float64(total) / 7
+	variance := moduleSize / 2
+	return math.Abs(moduleSize-float64(counts[0])) < variance &&
+		math.Abs(moduleSize-float64(counts[1])) < variance &&
+		math.Abs(3*moduleSize-float64(counts[2])) < 3*variance &&
+		math.Abs(moduleSize-float64(counts[3])) < variance &&
+		math.Abs(moduleSize-float64(counts[4])) < variance
+}
+
+// crossCheck measures the finder pattern through (x, y) along the direction (dx, dy), and returns
+// the center along that direction, or NaN if the pattern is not there.
+func (b *bitmap) crossCheck(x, y, dx, dy, maxCount, originalTotal int) float64 {
+	var counts [5]int
+	i := 0
+	for b.at(x-i*dx, y-i*dy) && i <= 3*maxCount {
+		counts[2]++
+		i++
+	}
+	for state := 1; state >= 0; state-- {
+		for b.at(x-i*dx, y-i*dy) == (state == 0) && counts[state] <= maxCount {
+			counts[state]++
+			i++
+		}
+	}
+	i = 1
+	for b.at(x+i*dx, y+i*dy) && i <= 3*maxCount {
+		counts[2]++
+		i++
+	}
+	for state := 3; state <= 4; state++ {
+		for b.at(x+i*dx, y+i*dy) == (state == 4) && counts[state] <= maxCount {
+			counts[state]++
+			i++
+		}
+	}
+	total := counts[0] + counts[1] + counts[2] + counts[3] + counts[4]
+	if 5*abs(total-originalTotal) >= 2*originalTotal || !finderRatio(&counts) {
+		return math.NaN()
+	}
+	end := x*dx + y*dy + i
+	return float64(end-counts[4]-counts[3]) - float64(counts[2])/2
+}
+
+func findFinderPatterns(b *bitmap) []*finderPattern {
+	var patterns []*finderPattern
+	found := func(counts *[5]int, end, y int) {
+		total := counts[0] + counts[1] + counts[2] + counts[3] + counts[4]
+		centerX := float64(end-counts[4]-counts[3]) - float64(counts[2])/2
+		centerY := b.crossCheck(int(centerX), y, 0, 1, counts[2], total)
+		if math.IsNaN(centerY) {
+			return
+		}
+		centerX = b.crossCheck(int(centerX), int(centerY), 1, 0, counts[2], total)
+		if math.IsNaN(centerX) {
+			return
+		}
+		moduleSize := float64(total) / 7
+		for _, p := range patterns {
+			if math.Abs(p.x-centerX) <= moduleSize && math.Abs(p.y-centerY) <= moduleSize && math.Abs(p.moduleSize-moduleSize) <= math.Max(1, moduleSize/2) {
+				weight := float64(p.count)
+				p.x = (p.x*weight + centerX) / (weight + 1)
+				p.y = (p.y*weight + centerY) / (weight + 1)
+				p.moduleSize = (p.moduleSize*weight + moduleSize) / (weight + 1)
+				p.count++
+				return
+			}
+		}
+		patterns = append(patterns, &finderPattern{centerX, centerY, moduleSize, 1})
+	}
+
+	for y := 0; y < b.height; y++ {
+		var counts [5]int
+		state := 0
+		for x := 0; x < b.width; x++ {
+			if b.at(x, y) {
+				if state%2 == 1 {
+					state++
+				}
+				counts[state]++
+				continue
+			}
+			if state%2 == 1 {
+				counts[state]++
+				continue
+			}
+			if counts[0] == 0 {
+				continue
+			}
+			if state < 4 {
+				state++
+				counts[state]++
+				continue
+			}
+			if finderRatio(&counts) {
+				found(&counts, x, y)
+			}
+			counts = [5]int{counts[2], counts[3], counts[4], 1, 0}
+			state = 3
+		}
+		if state == 4 && finderRatio(&counts) {
+			found(&counts, b.width, y)
+		}
+	}
+	return patterns
+}
+
+// finderGroup holds the top left, top right and bottom left finder patterns of a code.
+type finderGroup struct {
+	topLeft, topRight, bottomLeft *finderPattern
+	score                         float64
+}
+
+func distance(a, b *finderPattern) float64 {
+	return math.Hypot(a.x-b.x, a.y-b.y)
+}
+
+// finderTriples returns the plausible groups of three finder patterns, best first.
+func finderTriples(patterns []*finderPattern) []finderGroup {
+	sort.SliceStable(patterns, func(i, j int) bool { return patterns[i].count > patterns[j].count })
+	if len(patterns) > 24 {
+		patterns = patterns[:24]
+	}
+	var groups []finderGroup
+	for i := 0; i < len(patterns); i++ {
+		for j := i + 1; j < len(patterns); j++ {
+			for k := j + 1; k < len(patterns); k++ {
+				a, b, c := patterns[i], patterns[j], patterns[k]
+				smallest := math.Min(a.moduleSize, math.Min(b.moduleSize, c.moduleSize))
+				largest := math.Max(a.moduleSize, math.Max(b.moduleSize, c.moduleSize))
+				if largest > 1.5*smallest {
+					continue
+				}
+				// The top left pattern is the one opposite the hypotenuse.
+				ab, bc, ca := distance(a, b), distance(b, c), distance(c, a)
+				group := finderGroup{topLeft: c, topRight: a, bottomLeft: b}
+				hypotenuse, leg1, leg2 := ab, bc, ca
+				if bc > hypotenuse && bc >= ca {
+					group = finderGroup{topLeft: a, topRight: b, bottomLeft: c}
+					hypotenuse, leg1, leg2 = bc, ab, ca
+				} else if ca > hypotenuse {
+					group = finderGroup{topLeft: b, topRight: c, bottomLeft: a}
+					hypotenuse, leg1, leg2 = ca, ab, bc
+				}
+				legError := math.Abs(leg1-leg2) / math.Max(leg1, leg2)
+				hypotenuseError := math.Abs(hypotenuse-math.Hypot(leg1, leg2)) / hypotenuse
+				if legError > 0.1 || hypotenuseError > 0.1 || leg1 < 10*smallest {
+					continue
+				}
+				tl, tr, bl := group.topLeft, group.topRight, group.bottomLeft
+				if (tr.x-tl.x)*(bl.y-tl.y)-(tr.y-tl.y)*(bl.x-tl.x) < 0 {
+					group.topRight, group.bottomLeft = bl, tr
+				}
+				group.score = legError + hypotenuseError + (largest-smallest)/largest
+				groups = append(groups, group)
+			}
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].score < groups[j].score })
+	return groups
+}
+
+// dimension estimates the number of modules per side, which is always 1 modulo 4.
+func (g *finderGroup) dimension() int {
+	moduleSize := (g.topLeft.moduleSize + g.topRight.moduleSize + g.bottomLeft.moduleSize) / 3
+	side := (distance(g.topLeft, g.topRight) + distance(g.topLeft, g.bottomLeft)) / 2
+	dimension := int(math.Round(side/moduleSize)) + 7
+	switch dimension % 4 {
+	case 0:
+		dimension++
+	case 2:
+		dimension--
+	case 3:
+		dimension -= 2
+	}
+	return dimension
+}
+
+// sample reads the modules at the positions interpolated from the finder pattern centers, which
+// are three and a half modules in from the corners.
+func (g *finderGroup) sample(b *bitmap, dimension int) *Code {
+	code := &Code{Size: dimension, modules: make([]bool, dimension*dimension)}
+	steps := float64(dimension - 7)
+	rightX, rightY := (g.topRight.x-g.topLeft.x)/steps, (g.topRight.y-g.topLeft.y)/steps
+	downX, downY := (g.bottomLeft.x-g.topLeft.x)/steps, (g.bottomLeft.y-g.topLeft.y)/steps
+	for y := 0; y < dimension; y++ {
+		for x := 0; x < dimension; x++ {
+			px := g.topLeft.x + float64(x-3)*rightX + float64(y-3)*downX
+			py := g.topLeft.y + float64(x-3)*rightY + float64(y-3)*downY
+			code.modules[y*dimension+x] = b.at(int(math.Floor(px)), int(math.Floor(py)))
+		}
+	}
+	return code
+}
+
+func decodeCode(code *Code) ([]byte, error) {
+	version := (code.Size - 17) / 4
+	if version < 1 || version > 40 || version*4+17 != code.Size {
+		return nil, errCorrupt
+	}
+
+	var first, second uint
+	firstPositions, secondPositions := formatPositions(code.Size)
+	for i := 0; i < 15; i++ {
+		if code.Black(firstPositions[i][0], firstPositions[i][1]) {
+			first |= 1 << uint(i)
+		}
+		if code.Black(secondPositions[i][0], secondPositions[i][1]) {
+			second |= 1 << uint(i)
+		}
+	}
+	level, mask, bestDistance := LevelL, 0, 4
+	for l := LevelL; l <= LevelH; l++ {
+		for m := 0; m < 8; m++ {
+			bits := formatInformation(l, m)
+			for _, read := range []uint{first, second} {
+				if distance := bitCount(bits ^ read); distance < bestDistance {
+					level, mask, bestDistance = l, m, distance
+				}
+			}
+		}
+	}
+	if bestDistance > 3 {
+		return nil, errCorrupt
+	}
+
+	c := newCode(version)
+	c.drawFunctionPatterns(version, level)
+	raw := make([]byte, rawDataModules(version)/8)
+	i := 0
+	c.walkDataModules(func(x, y int) {
+		if i < len(raw)*8 {
+			if code.Black(x, y) != maskInverts(mask, x, y) {
+				raw[i>>3] |= 0x80 >> uint(i&7)
+			}
+			i++
+		}
+	})
+
+	data, err := deinterleave(raw, version, level)
+	if err != nil {
+		return nil, err
+	}
+	return parseSegments(data, version)
+}
+
+func bitCount(v uint) int {
+	n := 0
+	for ; v != 0; v &= v - 1 {
+		n++
+	}
+	return n
+}
+
+// deinterleave undoes interleave, corrects errors in each block and returns the data codewords.
+func deinterleave(raw []byte, version int, level Level) ([]byte, error) {
+	numBlocks := errorCorrectionBlocks[level][version]
+	blockEccLen := eccCodewordsPerBlock[level][version]
+	numShortBlocks := numBlocks - len(raw)%numBlocks
+	shortBlockLen := len(raw) / numBlocks
+
+	// Like in interleave, the short blocks get padded, so that all blocks line up.
+	blocks := make([][]byte, numBlocks)
+	for i := range blocks {
+		blocks[i] = make([]byte, shortBlockLen+1)
+	}
+	k := 0
+	for i := 0; i <= shortBlockLen; i++ {
+		for j, block := range blocks {
+			if i != shortBlockLen-blockEccLen || j >= numShortBlocks {
+				block[i] = raw[k]
+				k++
+			}
+		}
+	}
+	for j := 0; j < numShortBlocks; j++ {
+		pad := shortBlockLen - blockEccLen
+		blocks[j] = append(blocks[j][:pad], blocks[j][pad+1:]...)
+	}
+
+	var data []byte
+	for _, block := range blocks {
+		err := reedSolomonCorrect(block, blockEccLen)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, block[:len(block)-blockEccLen]...)
+	}
+	return data, nil
+}
+
+var (
+	gfExp [510]byte
+	gfLog [256]int
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfExp[i+255] = byte(x)
+		gfLog[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+}
+
+func gfMul(x, y byte) byte {
+	if x == 0 || y == 0 {
+		return 0
+	}
+	return gfExp[gfLog[x]+gfLog[y]]
+}
+
+func gfDiv(x, y byte) byte {
+	if x == 0 {
+		return 0
+	}
+	return gfExp[gfLog[x]+255-gfLog[y]]
+}
+
+// gfEval evaluates the polynomial with its coefficients in ascending order at x.
+func gfEval(poly []byte, x byte) byte {
+	var result byte
+	for i := len(poly) - 1; i >= 0; i-- {
+		result = gfMul(result, x) ^ poly[i]
+	}
+	return result
+}
+
+func syndromes(block []byte, eccLen int) ([]byte, bool) {
+	s := make([]byte, eccLen)
+	clean := true
+	for j := range s {
+		// The first codeword is the coefficient of the highest power.
+		for _, c := range block {
+			s[j] = gfMul(s[j], gfExp[j]) ^ c
+		}
+		if s[j] != 0 {
+			clean = false
+		}
+	}
+	return s, clean
+}
+
+// reedSolomonCorrect fixes up to half as many wrong codewords in the block as it has error
+// correction codewords, using Berlekamp-Massey to find the error locator polynomial and Forney's
+// algorithm for the error values.
+func reedSolomonCorrect(block []byte, eccLen int) error {
+	s, clean := syndromes(block, eccLen)
+	if clean {
+		return nil
+	}
+
+	locator, previous := []byte{1}, []byte{1}
+	errors, shift, previousDiscrepancy := 0, 1, byte(1)
+	for n := 0; n < eccLen; n++ {
+		discrepancy := s[n]
+		for i := 1; i <= errors && i < len(locator); i++ {
+			discrepancy ^= gfMul(locator[i], s[n-i])
+		}
+		if discrepancy == 0 {
+			shift++
+			continue
+		}
+		last := append([]byte(nil), locator...)
+		coefficient := gfDiv(discrepancy, previousDiscrepancy)
+		for len(locator) < len(previous)+shift {
+			locator = append(locator, 0)
+		}
+		for i, p := range previous {
+			locator[i+shift] ^= gfMul(coefficient, p)
+		}
+		if 2*errors <= n {
+			errors = n + 1 - errors
+			previous, previousDiscrepancy, shift = last, discrepancy, 1
+		} else {
+			shift++
+		}
+	}
+	if 2*errors > eccLen {
+		return errCorrupt
+	}
+	for len(locator) > errors+1 {
+		if locator[len(locator)-1] != 0 {
+			return errCorrupt
+		}
+		locator = locator[:len(locator)-1]
+	}
+
+	evaluator := make([]byte, eccLen)
+	for i := range evaluator {
+		for j := 0; j <= i && j < len(locator); j++ {
+			evaluator[i] ^= gfMul(locator[j], s[i-j])
+		}
+	}
+
+	found := 0
+	for power := 0; power < len(block); power++ {
+		inverse := gfExp[(255-power%255)%255]
+		if gfEval(locator, inverse) != 0 {
+			continue
+		}
+		var derivative byte
+		for i := 1; i < len(locator); i += 2 {
+			derivative ^= gfMul(locator[i], gfExp[(gfLog[inverse]*(i-1))%255])
+		}
+		if derivative == 0 {
+			return errCorrupt
+		}
+		block[len(block)-1-power] ^= gfMul(gfExp[power%255], gfDiv(gfEval(evaluator, inverse), derivative))
+		found++
+	}
+	if found != errors {
+		return errCorrupt
+	}
+	if _, clean = syndromes(block, eccLen); !clean {
+		return errCorrupt
+	}
+	return nil
+}
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) read(n int) (int, error) {
+	if r.pos+n > len(r.data)*8 {
+		return 0, errCorrupt
+	}
+	v := 0
+	for i := 0; i < n; i++ {
+		v = v<<1 | int(r.data[r.pos>>3]>>(7-uint(r.pos&7))&1)
+		r.pos++
+	}
+	return v, nil
+}
+
+const alphanumericCharset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+func parseSegments(data []byte, version int) ([]byte, error) {
+	sizeClass := 0
+	if version >= 27 {
+		sizeClass = 2
+	} else if version >= 10 {
+		sizeClass = 1
+	}
+	r := &bitReader{data: data}
+	var output []byte
+	for r.pos+4 <= len(data)*8 {
+		mode, _ := r.read(4)
+		switch mode {
+		case 0:
+			return output, nil
+		case 1:
+			count, err := r.read([]int{10, 12, 14}[sizeClass])
+			if err != nil {
+				return nil, err
+			}
+			for ; count >= 3; count -= 3 {
+				v, err := r.read(10)
+				if err != nil || v > 999 {
+					return nil, errCorrupt
+				}
+				output = append(output, byte('0'+v/100), byte('0'+v/10%10), byte('0'+v%10))
+			}
+			if count > 0 {
+				v, err := r.read(3*count + 1)
+				if err != nil {
+					return nil, err
+				}
+				if count == 2 {
+					output = append(output, byte('0'+v/10%10))
+				}
+				output = append(output, byte('0'+v%10))
+			}
+		case 2:
+			count, err := r.read([]int{9, 11, 13}[sizeClass])
+			if err != nil {
+				return nil, err
+			}
+			for ; count >= 2; count -= 2 {
+				v, err := r.read(11)
+				if err != nil || v >= 45*45 {
+					return nil, errCorrupt
+				}
+				output = append(output, alphanumericCharset[v/45], alphanumericCharset[v%45])
+			}
+			if count > 0 {
+				v, err := r.read(6)
+				if err != nil || v >= 45 {
+					return nil, errCorrupt
+				}
+				output = append(output, alphanumericCharset[v])
+			}
+		case 3:
+			// Structured append headers are irrelevant for a single code.
+			if _, err := r.read(16); err != nil {
+				return nil, err
+			}
+		case 4:
+			count, err := r.read([]int{8, 16, 16}[sizeClass])
+			if err != nil {
+				return nil, err
+			}
+			for ; count > 0; count-- {
+				v, err := r.read(8)
+				if err != nil {
+					return nil, err
+				}
+				output = append(output, byte(v))
+			}
+		case 7:
+			// The character set is guessed by whoever interprets the bytes anyway.
+			v, err := r.read(8)
+			if err != nil {
+				return nil, err
+			}
+			if v&0xc0 == 0x80 {
+				_, err = r.read(8)
+			} else if v&0xe0 == 0xc0 {
+				_, err = r.read(16)
+			}
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errCorrupt
+		}
+	}
+	return output, nil
+}
@@ -0,0 +1,143 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package qrcode
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+// render draws the code the way a screenshot of it would look, with rotation being the number
+// of quarter turns clockwise.
+func render(code *Code, scale, margin, rotation int) *image.Gray {
+	side := code.Size*scale + 2*margin
+	img := image.NewGray(image.Rect(0, 0, side, side))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if !code.Black(x, y) {
+				continue
+			}
+			rx, ry := x, y
+			for i := 0; i < rotation; i++ {
+				rx, ry = code.Size-1-ry, rx
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.Pix[(margin+ry*scale+dy)*img.Stride+margin+rx*scale+dx] = 0
+				}
+			}
+		}
+	}
+	return img
+}
+
+func TestReedSolomonCorrect(t *testing.T) {
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	block := append(append([]byte{}, data...), reedSolomonRemainder(data, reedSolomonDivisor(10))...)
+	damaged := append([]byte{}, block...)
+	damaged[0] ^= 0xff
+	damaged[7] ^= 0x01
+	damaged[12] = 0
+	damaged[20] ^= 0x42
+	damaged[25] ^= 0x80
+	if err := reedSolomonCorrect(damaged, 10); err != nil || !bytes.Equal(damaged, block) {
+		t.Errorf("Correcting five errors failed: %v", err)
+	}
+	damaged[1] ^= 0x01
+	damaged[2] ^= 0x01
+	damaged[3] ^= 0x01
+	damaged[4] ^= 0x01
+	damaged[5] ^= 0x01
+	damaged[6] ^= 0x01
+	if err := reedSolomonCorrect(damaged, 10); err == nil {
+		t.Error("Correcting six errors succeeded")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		length   int
+		level    Level
+		scale    int
+		rotation int
+	}{
+		{5, LevelL, 1, 0},
+		{17, LevelM, 3, 1},
+		{150, LevelL, 4, 2},
+		{300, LevelM, 2, 3},
+		{600, LevelQ, 5, 0},
+		{1200, LevelH, 3, 0},
+	}
+	for _, test := range tests {
+		data := make([]byte, test.length)
+		for i := range data {
+			data[i] = byte(i*7 + test.length)
+		}
+		code, err := Encode(data, test.level)
+		if err != nil {
+			t.Fatalf("Unable to encode %d bytes: %v", test.length, err)
+		}
+		img := render(code, test.scale, 5*test.scale+3, test.rotation)
+		decoded, err := Decode(img)
+		if err != nil {
+			t.Errorf("Unable to decode %d bytes at scale %d and rotation %d: %v", test.length, test.scale, test.rotation, err)
+			continue
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Errorf("Decoding %d bytes returned different data", test.length)
+		}
+	}
+}
+
+func TestDecodeDamaged(t *testing.T) {
+	text := []byte("[Interface]\nPrivateKey = yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=\n")
+	code, err := Encode(text, LevelM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, module := range [][2]int{{12, 12}, {13, 20}, {20, 13}, {code.Size - 2, code.Size - 2}} {
+		code.modules[module[1]*code.Size+module[0]] = !code.modules[module[1]*code.Size+module[0]]
+	}
+	decoded, err := Decode(render(code, 4, 16, 0))
+	if err != nil || !bytes.Equal(decoded, text) {
+		t.Errorf("Decoding damaged code returned %q, %v", decoded, err)
+	}
+
+	_, err = Decode(image.NewGray(image.Rect(0, 0, 100, 100)))
+	if err != ErrNotFound {
+		t.Errorf("Decoding empty image returned %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestParseSegments(t *testing.T) {
+	// "01234567" in numeric mode followed by "AC-42" in alphanumeric mode, from the specification.
+	var bits bitBuffer
+	bits.append(1, 4)
+	bits.append(8, 10)
+	bits.append(12, 10)
+	bits.append(345, 10)
+	bits.append(67, 7)
+	bits.append(2, 4)
+	bits.append(5, 9)
+	bits.append(10*45+12, 11)
+	bits.append(41*45+4, 11)
+	bits.append(2, 6)
+	bits.append(0, 4)
+	data := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit {
+			data[i>>3] |= 0x80 >> uint(i&7)
+		}
+	}
+	output, err := parseSegments(data, 1)
+	if got := string(output); err != nil || got != "01234567AC-42" {
+		t.Errorf("parseSegments = %q, %v", got, err)
+	}
+}
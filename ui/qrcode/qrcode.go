@@ -0,0 +1,505 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+// Package qrcode is a minimal QR code encoder and decoder. The encoder only supports byte mode,
+// since that is all that is needed to encode configuration files, and the decoder only copes
+// with computer generated images, such as screenshots.
+package qrcode
+
+import (
+	"errors"
+)
+
+// Level is the error correction level of a QR code.
+type Level int
+
+const (
+	LevelL Level = iota // Recovers 7% of the symbol
+	LevelM              // Recovers 15% of the symbol
+	LevelQ              // Recovers 25% of the symbol
+	LevelH              // Recovers 30% of the symbol
+)
+
+// ErrTooLong is returned when the data does not fit into the largest QR code version.
+var ErrTooLong = errors.New("Data too long for a QR code")
+
+// Code is an encoded QR code, without its quiet zone.
+type Code struct {
+	Size    int
+	modules []bool
+}
+
+// Black reports whether the module at the given coordinates is dark.
+func (code *Code) Black(x, y int) bool {
+	if x < 0 || y < 0 || x >= code.Size || y >= code.Size {
+		return false
+	}
+	return code.modules[y*code.Size+x]
+}
+
+var eccCodewordsPerBlock = [4][41]int{
+	LevelL: {-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	LevelM: {-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	LevelQ: {-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	LevelH: {-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+var errorCorrectionBlocks = [4][41]int{
+	LevelL: {-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	LevelM: {-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	LevelQ: {-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	LevelH: {-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// formatBits are the two bits that encode the level in the format information, which are
+// not in the same order as the levels themselves.
+var formatBits = [4]uint{LevelL: 1, LevelM: 0, LevelQ: 3, LevelH: 2}
+
+func rawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		alignments := version/7 + 2
+		result -= (25*alignments-10)*alignments - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+func dataCodewords(version int, level Level) int {
+	return rawDataModules(version)/8 - eccCodewordsPerBlock[level][version]*errorCorrectionBlocks[level][version]
+}
+
+// Encode encodes the data in byte mode into the smallest QR code version that fits it at the
+// given error correction level.
+func Encode(data []byte, level Level) (*Code, error) {
+	if level < LevelL || level > LevelH {
+		return nil, errors.New("Invalid error correction level")
+	}
+	version := 1
+	for ; version <= 40; version++ {
+		countBits := 8
+		if version >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+len(data)*8 <= dataCodewords(version, level)*8 {
+			break
+		}
+	}
+	if version > 40 {
+		return nil, ErrTooLong
+	}
+
+	var bits bitBuffer
+	bits.append(0x4, 4)
+	if version >= 10 {
+		bits.append(uint(len(data)), 16)
+	} else {
+		bits.append(uint(len(data)), 8)
+	}
+	for _, b := range data {
+		bits.append(uint(b), 8)
+	}
+	capacity := dataCodewords(version, level) * 8
+	terminator := capacity - len(bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+	bits.append(0, terminator)
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := uint(0xec); len(bits) < capacity; pad ^= 0xec ^ 0x11 {
+		bits.append(pad, 8)
+	}
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i/8] |= 1 << (7 - i%8)
+		}
+	}
+
+	code := newCode(version)
+	code.drawFunctionPatterns(version, level)
+	code.drawCodewords(interleave(codewords, version, level))
+	bestMask, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		code.applyMask(mask)
+		code.drawFormatBits(level, mask)
+		penalty := code.penalty()
+		if bestPenalty < 0 || penalty < bestPenalty {
+			bestMask, bestPenalty = mask, penalty
+		}
+		code.applyMask(mask)
+	}
+	code.applyMask(bestMask)
+	code.drawFormatBits(level, bestMask)
+	return &Code{Size: code.size, modules: code.modules}, nil
+}
+
+type bitBuffer []bool
+
+func (buffer *bitBuffer) append(value uint, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*buffer = append(*buffer, (value>>uint(i))&1 != 0)
+	}
+}
+
+// interleave splits the data into blocks, appends the error correction codewords to each,
+// and interleaves the results.
+func interleave(data []byte, version int, level Level) []byte {
+	numBlocks := errorCorrectionBlocks[level][version]
+	eccLength := eccCodewordsPerBlock[level][version]
+	rawCodewords := rawDataModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLength := rawCodewords / numBlocks
+
+	divisor := reedSolomonDivisor(eccLength)
+	blocks := make([][]byte, numBlocks)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		length := shortBlockLength - eccLength
+		if i >= numShortBlocks {
+			length++
+		}
+		block := append([]byte{}, data[k:k+length]...)
+		k += length
+		ecc := reedSolomonRemainder(block, divisor)
+		if i < numShortBlocks {
+			block = append(block, 0)
+		}
+		blocks[i] = append(block, ecc...)
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := 0; i < len(blocks[0]); i++ {
+		for j, block := range blocks {
+			// The padding byte that evens out the short blocks is skipped.
+			if i != shortBlockLength-eccLength || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+func gfMultiply(x, y byte) byte {
+	var z uint
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11d)
+		z ^= ((uint(y) >> uint(i)) & 1) * uint(x)
+	}
+	return byte(z)
+}
+
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+func reedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coefficient := range divisor {
+			result[i] ^= gfMultiply(coefficient, factor)
+		}
+	}
+	return result
+}
+
+type canvas struct {
+	size       int
+	modules    []bool
+	isFunction []bool
+}
+
+func newCode(version int) *canvas {
+	size := version*4 + 17
+	return &canvas{size: size, modules: make([]bool, size*size), isFunction: make([]bool, size*size)}
+}
+
+func (c *canvas) set(x, y int, black bool) {
+	c.modules[y*c.size+x] = black
+	c.isFunction[y*c.size+x] = true
+}
+
+func (c *canvas) get(x, y int) bool {
+	return c.modules[y*c.size+x]
+}
+
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	count := version/7 + 2
+	var step int
+	if version == 32 {
+		step = 26
+	} else {
+		step = (version*4 + count*2 + 1) / (count*2 - 2) * 2
+	}
+	positions := make([]int, count)
+	positions[0] = 6
+	for i, pos := count-1, version*4+10; i >= 1; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+func (c *canvas) drawFunctionPatterns(version int, level Level) {
+	for i := 0; i < c.size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+	c.drawFinder(3, 3)
+	c.drawFinder(c.size-4, 3)
+	c.drawFinder(3, c.size-4)
+
+	positions := alignmentPositions(version)
+	for i := range positions {
+		for j := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == len(positions)-1) || (i == len(positions)-1 && j == 0) {
+				continue
+			}
+			c.drawAlignment(positions[i], positions[j])
+		}
+	}
+
+	// Reserve the format areas with dummy bits, to be overwritten once the mask is known.
+	c.drawFormatBits(level, 0)
+	c.drawVersion(version)
+}
+
+func (c *canvas) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= c.size || yy < 0 || yy >= c.size {
+				continue
+			}
+			dist := chebyshev(dx, dy)
+			c.set(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (c *canvas) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.set(x+dx, y+dy, chebyshev(dx, dy) != 1)
+		}
+	}
+}
+
+func formatInformation(level Level, mask int) uint {
+	data := formatBits[level]<<3 | uint(mask)
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// formatPositions returns the coordinates of each of the 15 format bits, for both of its copies.
+func formatPositions(size int) (first, second [15][2]int) {
+	for i := 0; i <= 5; i++ {
+		first[i] = [2]int{8, i}
+	}
+	first[6] = [2]int{8, 7}
+	first[7] = [2]int{8, 8}
+	first[8] = [2]int{7, 8}
+	for i := 9; i < 15; i++ {
+		first[i] = [2]int{14 - i, 8}
+	}
+
+	for i := 0; i < 8; i++ {
+		second[i] = [2]int{size - 1 - i, 8}
+	}
+	for i := 8; i < 15; i++ {
+		second[i] = [2]int{8, size - 15 + i}
+	}
+	return
+}
+
+func (c *canvas) drawFormatBits(level Level, mask int) {
+	bits := formatInformation(level, mask)
+	first, second := formatPositions(c.size)
+	for i := 0; i < 15; i++ {
+		black := (bits>>uint(i))&1 != 0
+		c.set(first[i][0], first[i][1], black)
+		c.set(second[i][0], second[i][1], black)
+	}
+	c.set(8, c.size-8, true)
+}
+
+func (c *canvas) drawVersion(version int) {
+	if version < 7 {
+		return
+	}
+	rem := uint(version)
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1f25)
+	}
+	bits := uint(version)<<12 | rem
+	for i := 0; i < 18; i++ {
+		black := (bits>>uint(i))&1 != 0
+		a, b := c.size-11+i%3, i/3
+		c.set(a, b, black)
+		c.set(b, a, black)
+	}
+}
+
+// walkDataModules calls f for each module that is not part of a function pattern, in the zigzag
+// order in which codewords are placed.
+func (c *canvas) walkDataModules(f func(x, y int)) {
+	for right := c.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				upward := (right+1)&2 == 0
+				y := vert
+				if upward {
+					y = c.size - 1 - vert
+				}
+				if !c.isFunction[y*c.size+x] {
+					f(x, y)
+				}
+			}
+		}
+	}
+}
+
+func (c *canvas) drawCodewords(data []byte) {
+	i := 0
+	c.walkDataModules(func(x, y int) {
+		if i < len(data)*8 {
+			c.modules[y*c.size+x] = (data[i>>3]>>(7-uint(i&7)))&1 != 0
+			i++
+		}
+	})
+}
+
+func maskInverts(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	case 7:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+	return false
+}
+
+// applyMask flips the data modules according to the mask pattern, so calling it twice undoes it.
+func (c *canvas) applyMask(mask int) {
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if maskInverts(mask, x, y) && !c.isFunction[y*c.size+x] {
+				c.modules[y*c.size+x] = !c.modules[y*c.size+x]
+			}
+		}
+	}
+}
+
+// penalty scores the symbol by the four rules of the specification, lower being better.
+func (c *canvas) penalty() int {
+	result := 0
+	for _, transpose := range []bool{false, true} {
+		get := c.get
+		if transpose {
+			get = func(x, y int) bool { return c.get(y, x) }
+		}
+		for y := 0; y < c.size; y++ {
+			run := 0
+			var runColor bool
+			var pattern uint
+			for x := 0; x < c.size; x++ {
+				black := get(x, y)
+				if x == 0 || black != runColor {
+					runColor = black
+					run = 1
+				} else {
+					run++
+					if run == 5 {
+						result += 3
+					} else if run > 5 {
+						result++
+					}
+				}
+				pattern = (pattern<<1 | boolBit(black)) & 0x7ff
+				if x >= 10 && (pattern == 0x05d || pattern == 0x5d0) {
+					result += 40
+				}
+			}
+		}
+	}
+
+	for y := 0; y < c.size-1; y++ {
+		for x := 0; x < c.size-1; x++ {
+			color := c.get(x, y)
+			if color == c.get(x+1, y) && color == c.get(x, y+1) && color == c.get(x+1, y+1) {
+				result += 3
+			}
+		}
+	}
+
+	black := 0
+	for _, module := range c.modules {
+		if module {
+			black++
+		}
+	}
+	total := c.size * c.size
+	k := (abs(black*20-total*10)+total-1)/total - 1
+	result += k * 10
+	return result
+}
+
+func chebyshev(dx, dy int) int {
+	if abs(dx) > abs(dy) {
+		return abs(dx)
+	}
+	return abs(dy)
+}
+
+func boolBit(b bool) uint {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
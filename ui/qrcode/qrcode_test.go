@@ -0,0 +1,75 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package qrcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// The "HELLO WORLD" 1-M example from the specification.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	got := reedSolomonRemainder(data, reedSolomonDivisor(len(want)))
+	if !bytes.Equal(got, want) {
+		t.Errorf("reedSolomonRemainder = %v, want %v", got, want)
+	}
+}
+
+func TestFormatInformation(t *testing.T) {
+	tests := []struct {
+		level Level
+		mask  int
+		want  uint
+	}{
+		{LevelL, 0, 0b111011111000100},
+		{LevelM, 0, 0b101010000010010},
+		{LevelQ, 7, 0b010101111101101},
+		{LevelH, 3, 0b001100111010000},
+	}
+	for _, test := range tests {
+		if got := formatInformation(test.level, test.mask); got != test.want {
+			t.Errorf("formatInformation(%d, %d) = %015b, want %015b", test.level, test.mask, got, test.want)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		length int
+		level  Level
+		size   int
+	}{
+		{5, LevelL, 21},
+		{17, LevelM, 25},
+		{150, LevelL, 45},
+		{300, LevelM, 69},
+		{1200, LevelH, 173},
+	}
+	for _, test := range tests {
+		code, err := Encode([]byte(strings.Repeat("x", test.length)), test.level)
+		if err != nil {
+			t.Errorf("Unable to encode %d bytes: %v", test.length, err)
+			continue
+		}
+		if code.Size != test.size {
+			t.Errorf("Encoding %d bytes at level %d gave size %d, want %d", test.length, test.level, code.Size, test.size)
+		}
+		// The finder patterns must be intact whichever mask was chosen.
+		for _, corner := range [][2]int{{0, 0}, {code.Size - 7, 0}, {0, code.Size - 7}} {
+			if !code.Black(corner[0], corner[1]) || code.Black(corner[0]+1, corner[1]+1) || !code.Black(corner[0]+3, corner[1]+3) {
+				t.Errorf("Finder pattern at %v is damaged", corner)
+			}
+		}
+	}
+
+	_, err := Encode(make([]byte, 3000), LevelL)
+	if err != ErrTooLong {
+		t.Errorf("Encoding 3000 bytes returned %v, want %v", err, ErrTooLong)
+	}
+}
@@ -0,0 +1,229 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"strings"
+
+	"github.com/lxn/walk"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/l18n"
+	"golang.zx2c4.com/wireguard/windows/manager"
+	"golang.zx2c4.com/wireguard/windows/ui/qrcode"
+)
+
+// The quiet zone is the white border, in modules, that scanners need around the code.
+const qrCodeQuietZone = 4
+
+type qrCodeRedaction int
+
+const (
+	qrCodeEntireConfig qrCodeRedaction = iota
+	qrCodeWithoutPrivateKey
+	qrCodeNewPrivateKey
+)
+
+// qrCodeConfig returns the configuration text to encode. Without the private key, it has to be
+// typed in on the other device. With a new private key, the other device becomes a peer of its
+// own, rather than a clone of this computer that the server could not tell apart from it.
+func qrCodeConfig(config *conf.Config, redaction qrCodeRedaction, newPrivateKey *conf.Key) string {
+	switch redaction {
+	case qrCodeWithoutPrivateKey:
+		lines := strings.SplitAfter(config.ToWgQuick(), "\n")
+		var output strings.Builder
+		for _, line := range lines {
+			if !strings.HasPrefix(line, "PrivateKey = ") {
+				output.WriteString(line)
+			}
+		}
+		return output.String()
+	case qrCodeNewPrivateKey:
+		newConfig := *config
+		newConfig.Interface.PrivateKey = *newPrivateKey
+		return newConfig.ToWgQuick()
+	}
+	return config.ToWgQuick()
+}
+
+func encodeQRCode(text string) *qrcode.Code {
+	code, err := qrcode.Encode([]byte(text), qrcode.LevelM)
+	if err == qrcode.ErrTooLong {
+		code, err = qrcode.Encode([]byte(text), qrcode.LevelL)
+	}
+	if err != nil {
+		return nil
+	}
+	return code
+}
+
+// newQRCodeView creates a square widget that draws whatever code returns, with its quiet zone.
+func newQRCodeView(parent walk.Container, code func() *qrcode.Code) (*walk.CustomWidget, error) {
+	whiteBrush, err := walk.NewSolidColorBrush(walk.RGB(0xff, 0xff, 0xff))
+	if err != nil {
+		return nil, err
+	}
+	blackBrush, err := walk.NewSolidColorBrush(walk.RGB(0x00, 0x00, 0x00))
+	if err != nil {
+		whiteBrush.Dispose()
+		return nil, err
+	}
+
+	var codeView *walk.CustomWidget
+	codeView, err = walk.NewCustomWidgetPixels(parent, 0, func(canvas *walk.Canvas, updateBounds walk.Rectangle) error {
+		bounds := codeView.ClientBoundsPixels()
+		canvas.FillRectanglePixels(whiteBrush, bounds)
+		code := code()
+		if code == nil {
+			return nil
+		}
+		modules := code.Size + 2*qrCodeQuietZone
+		scale := bounds.Width / modules
+		if bounds.Height < bounds.Width {
+			scale = bounds.Height / modules
+		}
+		if scale < 1 {
+			scale = 1
+		}
+		left := (bounds.Width-modules*scale)/2 + qrCodeQuietZone*scale
+		top := (bounds.Height-modules*scale)/2 + qrCodeQuietZone*scale
+		for y := 0; y < code.Size; y++ {
+			for x := 0; x < code.Size; x++ {
+				if code.Black(x, y) {
+					canvas.FillRectanglePixels(blackBrush, walk.Rectangle{X: left + x*scale, Y: top + y*scale, Width: scale, Height: scale})
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		whiteBrush.Dispose()
+		blackBrush.Dispose()
+		return nil, err
+	}
+	codeView.Disposing().Attach(func() {
+		whiteBrush.Dispose()
+		blackBrush.Dispose()
+	})
+	codeView.SetClearsBackground(true)
+	codeView.SetInvalidatesOnResize(true)
+	codeView.SetMinMaxSize(walk.Size{Width: 400, Height: 400}, walk.Size{})
+	codeView.Accessibility().SetName(l18n.Sprintf("QR code image"))
+	return codeView, nil
+}
+
+func onShowQRCode(owner walk.Form, tunnel *manager.Tunnel) {
+	showError(runQRCodeDialog(owner, tunnel), owner)
+}
+
+func runQRCodeDialog(owner walk.Form, tunnel *manager.Tunnel) error {
+	config, err := tunnel.StoredConfig()
+	if err != nil {
+		return err
+	}
+
+	var disposables walk.Disposables
+	defer disposables.Treat()
+
+	dlg, err := walk.NewDialog(owner)
+	if err != nil {
+		return err
+	}
+	disposables.Add(dlg)
+	dlg.SetTitle(l18n.Sprintf("QR code: %s", tunnel.Name))
+	vbl := walk.NewVBoxLayout()
+	vbl.SetMargins(walk.Margins{HNear: 10, VNear: 10, HFar: 10, VFar: 10})
+	dlg.SetLayout(vbl)
+	if icon, err := loadLogoIcon(32); err == nil {
+		dlg.SetIcon(icon)
+	}
+
+	redactionCB, err := walk.NewDropDownBox(dlg)
+	if err != nil {
+		return err
+	}
+	redactionCB.SetModel([]string{
+		l18n.Sprintf("Entire configuration"),
+		l18n.Sprintf("Without private key"),
+		l18n.Sprintf("With a new private key"),
+	})
+
+	var code *qrcode.Code
+	codeView, err := newQRCodeView(dlg, func() *qrcode.Code { return code })
+	if err != nil {
+		return err
+	}
+
+	hintLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return err
+	}
+	hintLabel.SetMinMaxSize(walk.Size{Width: 400}, walk.Size{Width: 400})
+
+	publicKeyEdit, err := walk.NewLineEdit(dlg)
+	if err != nil {
+		return err
+	}
+	publicKeyEdit.SetReadOnly(true)
+
+	buttonsContainer, err := walk.NewComposite(dlg)
+	if err != nil {
+		return err
+	}
+	hbl := walk.NewHBoxLayout()
+	hbl.SetMargins(walk.Margins{})
+	buttonsContainer.SetLayout(hbl)
+	walk.NewHSpacer(buttonsContainer)
+	closeButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return err
+	}
+	closeButton.SetText(l18n.Sprintf("Close"))
+	closeButton.Clicked().Attach(dlg.Accept)
+	dlg.SetDefaultButton(closeButton)
+	dlg.SetCancelButton(closeButton)
+
+	var newPrivateKey *conf.Key
+	update := func() {
+		redaction := qrCodeRedaction(redactionCB.CurrentIndex())
+		if redaction == qrCodeNewPrivateKey && newPrivateKey == nil {
+			key, err := conf.NewPrivateKey()
+			if err != nil {
+				showErrorCustom(dlg, l18n.Sprintf("Unable to create new private key"), err.Error())
+				redactionCB.SetCurrentIndex(int(qrCodeWithoutPrivateKey))
+				return
+			}
+			newPrivateKey = key
+		}
+		code = encodeQRCode(qrCodeConfig(&config, redaction, newPrivateKey))
+		switch {
+		case code == nil:
+			hintLabel.SetText(l18n.Sprintf("The configuration is too large to fit into a QR code."))
+		case redaction == qrCodeEntireConfig:
+			hintLabel.SetText(l18n.Sprintf("This QR code contains the private key of this computer. Anyone who scans it can impersonate this computer."))
+		case redaction == qrCodeWithoutPrivateKey:
+			hintLabel.SetText(l18n.Sprintf("The private key has to be entered by hand on the other device."))
+		case redaction == qrCodeNewPrivateKey:
+			hintLabel.SetText(l18n.Sprintf("The other device gets its own private key. Add it as a new peer on the server with this public key, and give it an address of its own:"))
+		}
+		publicKeyEdit.SetVisible(code != nil && redaction == qrCodeNewPrivateKey)
+		if newPrivateKey != nil {
+			publicKeyEdit.SetText(newPrivateKey.Public().String())
+		}
+		codeView.Invalidate()
+	}
+	redactionCB.CurrentIndexChanged().Attach(update)
+	redactionCB.SetCurrentIndex(int(qrCodeWithoutPrivateKey))
+	update()
+
+	applyTheme(dlg)
+
+	disposables.Spare()
+
+	dlg.Run()
+
+	return nil
+}
@@ -0,0 +1,230 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/lxn/win"
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/l18n"
+	"golang.zx2c4.com/wireguard/windows/ui/qrcode"
+)
+
+// Long enough for the window manager to finish hiding the window before the screen is captured.
+const screenCaptureDelay = 300 * time.Millisecond
+
+// Screenshots of whole desktops can be large, but anything beyond this is surely not a screenshot.
+const maxQRCodeImageFileSize = 64 * 1024 * 1024
+
+// qrCodeText turns the contents of a QR code into configuration text. Codes exported without
+// the private key get a new one, so that they can be saved as is.
+func qrCodeText(payload []byte) (string, error) {
+	if len(payload) > conf.MaxConfigFileSize {
+		return "", errors.New(l18n.Sprintf("configuration file is too large"))
+	}
+	text := string(payload)
+	if strings.Contains(text, "PrivateKey") {
+		return text, nil
+	}
+	interfaceSection := strings.Index(text, "[Interface]")
+	if interfaceSection < 0 {
+		return text, nil
+	}
+	key, err := conf.NewPrivateKey()
+	if err != nil {
+		return "", err
+	}
+	interfaceSection += len("[Interface]")
+	return text[:interfaceSection] + "\nPrivateKey = " + key.String() + text[interfaceSection:], nil
+}
+
+func readQRCodeImage(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err == nil && info.Size() > maxQRCodeImageFileSize {
+		return "", errors.New(l18n.Sprintf("image ‘%s’ is too large", path))
+	}
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return "", err
+	}
+	payload, err := qrcode.Decode(img)
+	if err != nil {
+		return "", err
+	}
+	return qrCodeText(payload)
+}
+
+// shellWindowClasses are the taskbars and the desktop, which never show a QR code themselves.
+var shellWindowClasses = map[string]bool{
+	"Shell_TrayWnd":            true,
+	"Shell_SecondaryTrayWnd":   true,
+	"NotifyIconOverflowWindow": true,
+	"Progman":                  true,
+	"WorkerW":                  true,
+}
+
+func isCapturableWindow(hwnd win.HWND) bool {
+	if hwnd == 0 || !win.IsWindowVisible(hwnd) || win.IsIconic(hwnd) || win.GetWindowLong(hwnd, win.GWL_EXSTYLE)&win.WS_EX_TOOLWINDOW != 0 {
+		return false
+	}
+	var processID uint32
+	win.GetWindowThreadProcessId(hwnd, &processID)
+	if processID == windows.GetCurrentProcessId() {
+		return false
+	}
+	class := make([]uint16, 64)
+	n, err := win.GetClassName(hwnd, &class[0], len(class))
+	return err == nil && !shellWindowClasses[windows.UTF16ToString(class[:n])]
+}
+
+// windowToCapture picks the window under the pointer, or, when the pointer rests on the taskbar, as
+// it does after choosing the tray menu entry, the topmost window of another program.
+func windowToCapture() win.HWND {
+	var point win.POINT
+	if win.GetCursorPos(&point) {
+		if hwnd := win.GetAncestor(win.WindowFromPoint(point), win.GA_ROOT); isCapturableWindow(hwnd) {
+			return hwnd
+		}
+	}
+	for hwnd := win.GetWindow(win.GetDesktopWindow(), win.GW_CHILD); hwnd != 0; hwnd = win.GetWindow(hwnd, win.GW_HWNDNEXT) {
+		if isCapturableWindow(hwnd) {
+			return hwnd
+		}
+	}
+	return 0
+}
+
+// captureWindow copies what the screen shows of a single window, so that nothing else on the
+// desktop is read.
+func captureWindow() (*image.RGBA, error) {
+	hwnd := windowToCapture()
+	if hwnd == 0 {
+		return nil, errors.New(l18n.Sprintf("No window to capture"))
+	}
+	var bounds win.RECT
+	if !win.GetWindowRect(hwnd, &bounds) {
+		return nil, errors.New("GetWindowRect failed")
+	}
+	// Parts of the window beyond the edges of the monitors have nothing to copy.
+	x := win.GetSystemMetrics(win.SM_XVIRTUALSCREEN)
+	y := win.GetSystemMetrics(win.SM_YVIRTUALSCREEN)
+	right := x + win.GetSystemMetrics(win.SM_CXVIRTUALSCREEN)
+	bottom := y + win.GetSystemMetrics(win.SM_CYVIRTUALSCREEN)
+	if bounds.Left > x {
+		x = bounds.Left
+	}
+	if bounds.Top > y {
+		y = bounds.Top
+	}
+	if bounds.Right < right {
+		right = bounds.Right
+	}
+	if bounds.Bottom < bottom {
+		bottom = bounds.Bottom
+	}
+	width, height := right-x, bottom-y
+	if width <= 0 || height <= 0 {
+		return nil, errors.New(l18n.Sprintf("No window to capture"))
+	}
+
+	screenDC := win.GetDC(0)
+	if screenDC == 0 {
+		return nil, errors.New("GetDC failed")
+	}
+	defer win.ReleaseDC(0, screenDC)
+	memoryDC := win.CreateCompatibleDC(screenDC)
+	if memoryDC == 0 {
+		return nil, errors.New("CreateCompatibleDC failed")
+	}
+	defer win.DeleteDC(memoryDC)
+	bitmap := win.CreateCompatibleBitmap(screenDC, width, height)
+	if bitmap == 0 {
+		return nil, errors.New("CreateCompatibleBitmap failed")
+	}
+	defer win.DeleteObject(win.HGDIOBJ(bitmap))
+	previous := win.SelectObject(memoryDC, win.HGDIOBJ(bitmap))
+	copied := win.BitBlt(memoryDC, 0, 0, width, height, screenDC, x, y, win.SRCCOPY|win.CAPTUREBLT)
+	win.SelectObject(memoryDC, previous)
+	if !copied {
+		return nil, errors.New("BitBlt failed")
+	}
+
+	var info win.BITMAPINFO
+	info.BmiHeader = win.BITMAPINFOHEADER{
+		BiSize:        uint32(unsafe.Sizeof(info.BmiHeader)),
+		BiWidth:       width,
+		BiHeight:      -height, // Top-down, like image.RGBA.
+		BiPlanes:      1,
+		BiBitCount:    32,
+		BiCompression: win.BI_RGB,
+	}
+	img := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	if win.GetDIBits(memoryDC, bitmap, 0, uint32(height), &img.Pix[0], &info, win.DIB_RGB_COLORS) == 0 {
+		return nil, errors.New("GetDIBits failed")
+	}
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+2], img.Pix[i+3] = img.Pix[i+2], img.Pix[i], 0xff
+	}
+	return img, nil
+}
+
+// onImportFromScreen looks for a QR code in the window under the pointer, with the main window out
+// of the way, and opens the result in the editor, so that the new tunnel can be named and reviewed.
+func (tp *TunnelsPage) onImportFromScreen() {
+	form := tp.Form()
+	form.SetVisible(false)
+	time.AfterFunc(screenCaptureDelay, func() {
+		img, err := captureWindow()
+		tp.Synchronize(func() {
+			form.SetVisible(true)
+			raise(form.Handle())
+		})
+		if err != nil {
+			tp.Synchronize(func() {
+				showErrorCustom(form, l18n.Sprintf("Unable to capture screen"), err.Error())
+			})
+			return
+		}
+		var config *conf.Config
+		payload, err := qrcode.Decode(img)
+		if err == nil {
+			var text string
+			text, err = qrCodeText(payload)
+			if err == nil {
+				// The name is chosen in the editor, so any valid one does for parsing.
+				config, err = conf.FromWgQuickWithUnknownEncoding(text, "qrcode")
+			}
+		}
+		tp.Synchronize(func() {
+			if err == qrcode.ErrNotFound {
+				showWarningCustom(form, l18n.Sprintf("No QR code found"), l18n.Sprintf("Make sure the entire QR code is visible in the window under the pointer, and try again."))
+				return
+			} else if err != nil {
+				showErrorCustom(form, l18n.Sprintf("Unable to import configuration"), err.Error())
+				return
+			}
+			config.Name = ""
+			if config, rules := runEditDialog(form, nil, config); config != nil {
+				tp.addTunnel(config, rules)
+			}
+		})
+	})
+}
@@ -0,0 +1,148 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"time"
+
+	"github.com/lxn/walk"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/l18n"
+	"golang.zx2c4.com/wireguard/windows/manager"
+)
+
+// Five minutes, at the rate the manager samples.
+const rateGraphSamples = 300
+
+type ratePoint struct {
+	rx, tx float64 // Bytes per second.
+}
+
+// rateGraph charts the recent throughput of one tunnel from the rates that the manager streams.
+// It keeps the history of every tunnel, so that switching between them shows their past at once.
+type rateGraph struct {
+	*walk.CustomWidget
+	histories  map[string][]ratePoint
+	tunnelName string
+	rateCB     *manager.TransferRateCallback
+}
+
+func newRateGraph(parent walk.Container) (*rateGraph, error) {
+	rg := &rateGraph{histories: make(map[string][]ratePoint)}
+	var err error
+	if rg.CustomWidget, err = walk.NewCustomWidgetPixels(parent, 0, rg.paint); err != nil {
+		return nil, err
+	}
+	rg.SetClearsBackground(true)
+	rg.SetInvalidatesOnResize(true)
+	rg.SetMinMaxSize(walk.Size{Height: 120}, walk.Size{Height: 120})
+	rg.Accessibility().SetName(l18n.Sprintf("Transfer rate graph"))
+	rg.rateCB = manager.IPCClientRegisterTransferRate(rg.onTransferRate)
+	rg.Disposing().Attach(rg.rateCB.Unregister)
+	return rg, nil
+}
+
+func (rg *rateGraph) onTransferRate(tunnel *manager.Tunnel, rate manager.TransferRate) {
+	if rate.Interval <= 0 {
+		return
+	}
+	seconds := float64(rate.Interval) / float64(time.Second)
+	point := ratePoint{float64(rate.RxBytes) / seconds, float64(rate.TxBytes) / seconds}
+	rg.Synchronize(func() {
+		history := append(rg.histories[tunnel.Name], point)
+		if len(history) > rateGraphSamples {
+			history = history[len(history)-rateGraphSamples:]
+		}
+		rg.histories[tunnel.Name] = history
+		if tunnel.Name == rg.tunnelName && rg.Visible() {
+			rg.Invalidate()
+		}
+	})
+}
+
+func (rg *rateGraph) setTunnel(name string) {
+	if name == rg.tunnelName {
+		return
+	}
+	rg.tunnelName = name
+	rg.Invalidate()
+}
+
+func formatRate(rate float64) string {
+	return l18n.Sprintf("%s/s", conf.Bytes(uint64(rate)).String())
+}
+
+func (rg *rateGraph) paint(canvas *walk.Canvas, updateBounds walk.Rectangle) error {
+	bounds := rg.ClientBoundsPixels()
+	background, foreground := walk.RGB(0xff, 0xff, 0xff), walk.RGB(0x00, 0x00, 0x00)
+	if darkModeActive {
+		background, foreground = darkControlColor, darkTextColor
+	}
+	backgroundBrush, err := walk.NewSolidColorBrush(background)
+	if err != nil {
+		return err
+	}
+	defer backgroundBrush.Dispose()
+	canvas.FillRectanglePixels(backgroundBrush, bounds)
+
+	gridPen, err := walk.NewCosmeticPen(walk.PenDot, walk.RGB(0x80, 0x80, 0x80))
+	if err != nil {
+		return err
+	}
+	defer gridPen.Dispose()
+	rxPen, err := walk.NewCosmeticPen(walk.PenSolid, walk.RGB(0x2e, 0x9e, 0x44))
+	if err != nil {
+		return err
+	}
+	defer rxPen.Dispose()
+	txPen, err := walk.NewCosmeticPen(walk.PenSolid, walk.RGB(0x1e, 0x6f, 0xd9))
+	if err != nil {
+		return err
+	}
+	defer txPen.Dispose()
+
+	history := rg.histories[rg.tunnelName]
+	peak := 1024.0
+	for _, point := range history {
+		if point.rx > peak {
+			peak = point.rx
+		}
+		if point.tx > peak {
+			peak = point.tx
+		}
+	}
+
+	textHeight := rg.IntFrom96DPI(16)
+	plot := walk.Rectangle{X: bounds.X, Y: bounds.Y + textHeight, Width: bounds.Width, Height: bounds.Height - textHeight}
+	for i := 0; i <= 2; i++ {
+		y := plot.Y + plot.Height*i/2
+		canvas.DrawLinePixels(gridPen, walk.Point{X: plot.X, Y: y}, walk.Point{X: plot.X + plot.Width, Y: y})
+	}
+
+	if len(history) > 1 {
+		rxPoints := make([]walk.Point, len(history))
+		txPoints := make([]walk.Point, len(history))
+		for i, point := range history {
+			// The newest sample is at the right edge, so that the graph scrolls to the left.
+			x := plot.X + plot.Width - 1 - (len(history)-1-i)*plot.Width/rateGraphSamples
+			rxPoints[i] = walk.Point{X: x, Y: plot.Y + plot.Height - 1 - int(point.rx/peak*float64(plot.Height-1))}
+			txPoints[i] = walk.Point{X: x, Y: plot.Y + plot.Height - 1 - int(point.tx/peak*float64(plot.Height-1))}
+		}
+		canvas.DrawPolylinePixels(rxPen, rxPoints)
+		canvas.DrawPolylinePixels(txPen, txPoints)
+	}
+
+	var current ratePoint
+	if len(history) > 0 {
+		current = history[len(history)-1]
+	}
+	textBounds := walk.Rectangle{X: bounds.X + 2, Y: bounds.Y, Width: bounds.Width - 4, Height: textHeight}
+	canvas.DrawTextPixels(l18n.Sprintf("Received: %s", formatRate(current.rx)), rg.Font(), walk.RGB(0x2e, 0x9e, 0x44), textBounds, walk.TextLeft|walk.TextVCenter|walk.TextSingleLine)
+	canvas.DrawTextPixels(l18n.Sprintf("Sent: %s", formatRate(current.tx)), rg.Font(), walk.RGB(0x1e, 0x6f, 0xd9), textBounds, walk.TextCenter|walk.TextVCenter|walk.TextSingleLine)
+	canvas.DrawTextPixels(l18n.Sprintf("Peak: %s", formatRate(peak)), rg.Font(), foreground, textBounds, walk.TextRight|walk.TextVCenter|walk.TextSingleLine)
+	return nil
+}
@@ -0,0 +1,130 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/lxn/walk"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/l18n"
+	"golang.zx2c4.com/wireguard/windows/manager"
+)
+
+// Schedules are edited as text, one window per line, as its days followed by its times, such as
+// "Mon-Fri 09:00-17:00".
+
+func onSchedule(owner walk.Form, tunnel *manager.Tunnel) {
+	showError(runScheduleDialog(owner, tunnel), owner)
+}
+
+func parseSchedule(text string) (*conf.Schedule, error) {
+	schedule := &conf.Schedule{}
+	for i, line := range strings.Split(text, "\n") {
+		if comment := strings.IndexByte(line, '#'); comment >= 0 {
+			line = line[:comment]
+		}
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		window, err := conf.ParseScheduleWindow(line)
+		if err != nil {
+			return nil, errors.New(l18n.Sprintf("Line %d: %v", i+1, err))
+		}
+		schedule.Windows = append(schedule.Windows, window)
+	}
+	return schedule, schedule.Validate()
+}
+
+func formatSchedule(schedule *conf.Schedule) string {
+	var text strings.Builder
+	for i := range schedule.Windows {
+		text.WriteString(schedule.Windows[i].String())
+		text.WriteString("\r\n")
+	}
+	return text.String()
+}
+
+func runScheduleDialog(owner walk.Form, tunnel *manager.Tunnel) error {
+	schedule, err := tunnel.Schedule()
+	if err != nil {
+		return err
+	}
+
+	var disposables walk.Disposables
+	defer disposables.Treat()
+
+	dlg, err := walk.NewDialog(owner)
+	if err != nil {
+		return err
+	}
+	disposables.Add(dlg)
+	dlg.SetTitle(l18n.Sprintf("Schedule: %s", tunnel.Name))
+	vbl := walk.NewVBoxLayout()
+	vbl.SetMargins(walk.Margins{HNear: 10, VNear: 10, HFar: 10, VFar: 10})
+	dlg.SetLayout(vbl)
+	dlg.SetMinMaxSize(walk.Size{Width: 500, Height: 300}, walk.Size{})
+	if icon, err := loadLogoIcon(32); err == nil {
+		dlg.SetIcon(icon)
+	}
+
+	scheduleLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return err
+	}
+	scheduleLabel.SetText(l18n.Sprintf("&Windows of time during which the tunnel is active, one per line, as days and times in local time, such as Mon-Fri 09:00-17:00 or Daily 22:00-06:00. The tunnel is activated and deactivated when a window starts and ends, and activating or deactivating it by hand holds until then:"))
+	scheduleEdit, err := walk.NewTextEdit(dlg)
+	if err != nil {
+		return err
+	}
+	scheduleEdit.SetText(formatSchedule(&schedule))
+
+	buttonsContainer, err := walk.NewComposite(dlg)
+	if err != nil {
+		return err
+	}
+	hbl := walk.NewHBoxLayout()
+	hbl.SetMargins(walk.Margins{})
+	buttonsContainer.SetLayout(hbl)
+	walk.NewHSpacer(buttonsContainer)
+	saveButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return err
+	}
+	saveButton.SetText(l18n.Sprintf("&Save"))
+	cancelButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return err
+	}
+	cancelButton.SetText(l18n.Sprintf("Cancel"))
+	cancelButton.Clicked().Attach(dlg.Cancel)
+	dlg.SetCancelButton(cancelButton)
+
+	saveButton.Clicked().Attach(func() {
+		newSchedule, err := parseSchedule(scheduleEdit.Text())
+		if err != nil {
+			showErrorCustom(dlg, l18n.Sprintf("Invalid schedule"), err.Error())
+			return
+		}
+		err = tunnel.SetSchedule(newSchedule)
+		if err != nil {
+			showErrorCustom(dlg, l18n.Sprintf("Unable to save schedule"), err.Error())
+			return
+		}
+		dlg.Accept()
+	})
+
+	applyTheme(dlg)
+
+	disposables.Spare()
+
+	dlg.Run()
+
+	return nil
+}
@@ -0,0 +1,376 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"strings"
+
+	"github.com/lxn/walk"
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/text/language/display"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/l18n"
+	"golang.zx2c4.com/wireguard/windows/manager"
+	"golang.zx2c4.com/wireguard/windows/updater"
+)
+
+const openWindowAtSignInRegValue = "OpenWindowAtSignIn"
+
+// openWindowAtSignIn returns whether the window opens when the UI starts, which is when the user
+// signs in, rather than the UI waiting in the tray.
+func openWindowAtSignIn() bool {
+	key, err := registry.OpenKey(registry.CURRENT_USER, favoriteRegKey, registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	defer key.Close()
+	open, _, err := key.GetIntegerValue(openWindowAtSignInRegValue)
+	return err == nil && open != 0
+}
+
+func setOpenWindowAtSignIn(open bool) error {
+	key, _, err := registry.CreateKey(registry.CURRENT_USER, favoriteRegKey, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	var value uint32
+	if open {
+		value = 1
+	}
+	return key.SetDWordValue(openWindowAtSignInRegValue, value)
+}
+
+// The entries of the update check drop down box, in order.
+var updateCheckIntervals = []int{conf.UpdateCheckHourly, conf.UpdateCheckDaily, conf.UpdateCheckWeekly, conf.UpdateCheckNever}
+
+// SettingsPage gathers the settings of the computer, which administrators change through the
+// manager, and those of the user, which the UI keeps in the registry.
+type SettingsPage struct {
+	*walk.TabPage
+}
+
+// addSettingsRow puts a widget in a row of the grid of a group box, labeled on its left.
+func addSettingsRow(group *walk.GroupBox, row int, label string, widget walk.Widget) error {
+	layout := group.Layout().(*walk.GridLayout)
+	textLabel, err := walk.NewTextLabel(group)
+	if err != nil {
+		return err
+	}
+	textLabel.SetTextAlignment(walk.AlignHFarVCenter)
+	textLabel.SetText(label)
+	layout.SetRange(textLabel, walk.Rectangle{X: 0, Y: row, Width: 1, Height: 1})
+	layout.SetRange(widget, walk.Rectangle{X: 1, Y: row, Width: 1, Height: 1})
+	return nil
+}
+
+func newSettingsGroup(parent walk.Container, title string) (*walk.GroupBox, error) {
+	group, err := walk.NewGroupBox(parent)
+	if err != nil {
+		return nil, err
+	}
+	group.SetTitle(title)
+	layout := walk.NewGridLayout()
+	layout.SetSpacing(6)
+	layout.SetColumnStretchFactor(1, 3)
+	group.SetLayout(layout)
+	return group, nil
+}
+
+func NewSettingsPage() (*SettingsPage, error) {
+	var err error
+	var disposables walk.Disposables
+	defer disposables.Treat()
+
+	sp := &SettingsPage{}
+	if sp.TabPage, err = walk.NewTabPage(); err != nil {
+		return nil, err
+	}
+	disposables.Add(sp)
+	sp.SetTitle(l18n.Sprintf("Settings"))
+	sp.SetLayout(walk.NewVBoxLayout())
+
+	if IsAdmin {
+		if err = sp.createMachineSettings(); err != nil {
+			return nil, err
+		}
+	}
+	if err = sp.createUserSettings(); err != nil {
+		return nil, err
+	}
+	walk.NewVSpacer(sp)
+
+	disposables.Spare()
+	return sp, nil
+}
+
+func (sp *SettingsPage) createMachineSettings() error {
+	group, err := newSettingsGroup(sp, l18n.Sprintf("This computer"))
+	if err != nil {
+		return err
+	}
+	settings, err := manager.IPCClientSettings()
+	if err != nil {
+		return err
+	}
+	policies := conf.LoadPolicies()
+
+	channelComboBox, err := walk.NewDropDownBox(group)
+	if err != nil {
+		return err
+	}
+	channelComboBox.SetModel([]string{l18n.Sprintf("Stable releases"), l18n.Sprintf("Beta releases")})
+	if err = addSettingsRow(group, 0, l18n.Sprintf("&Update channel:"), channelComboBox); err != nil {
+		return err
+	}
+	if channel, byPolicy, err := manager.IPCClientUpdateChannel(); err == nil {
+		if channel == conf.UpdateChannelBeta {
+			channelComboBox.SetCurrentIndex(1)
+		} else {
+			channelComboBox.SetCurrentIndex(0)
+		}
+		channelComboBox.SetEnabled(!byPolicy)
+		if byPolicy {
+			channelComboBox.SetToolTipText(l18n.Sprintf("Managed by your organization"))
+		}
+	}
+	channelComboBox.CurrentIndexChanged().Attach(func() {
+		channel := conf.UpdateChannelStable
+		if channelComboBox.CurrentIndex() == 1 {
+			channel = conf.UpdateChannelBeta
+		}
+		if err := manager.IPCClientSetUpdateChannel(channel); err != nil {
+			showErrorCustom(sp.Form(), l18n.Sprintf("Unable to change update channel"), err.Error())
+		}
+	})
+
+	updateCheckComboBox, err := walk.NewDropDownBox(group)
+	if err != nil {
+		return err
+	}
+	updateCheckComboBox.SetModel([]string{l18n.Sprintf("Hourly"), l18n.Sprintf("Daily"), l18n.Sprintf("Weekly"), l18n.Sprintf("Never")})
+	if err = addSettingsRow(group, 1, l18n.Sprintf("&Check for updates:"), updateCheckComboBox); err != nil {
+		return err
+	}
+	updateCheckComboBox.SetCurrentIndex(0)
+	for i, hours := range updateCheckIntervals {
+		if hours == settings.UpdateCheckHours {
+			updateCheckComboBox.SetCurrentIndex(i)
+		}
+	}
+
+	killSwitchCheckBox, err := walk.NewCheckBox(group)
+	if err != nil {
+		return err
+	}
+	killSwitchCheckBox.SetText(l18n.Sprintf("New tunnels block &untunneled traffic (kill-switch)"))
+	killSwitchCheckBox.SetChecked(settings.DefaultKillSwitch || policies.ForceKillSwitch)
+	killSwitchCheckBox.SetEnabled(!policies.ForceKillSwitch)
+	if policies.ForceKillSwitch {
+		killSwitchCheckBox.SetToolTipText(l18n.Sprintf("Managed by your organization"))
+	}
+	group.Layout().(*walk.GridLayout).SetRange(killSwitchCheckBox, walk.Rectangle{X: 1, Y: 2, Width: 1, Height: 1})
+
+	logLevelComboBox, err := walk.NewDropDownBox(group)
+	if err != nil {
+		return err
+	}
+	logLevelComboBox.SetModel([]string{
+		conf.AdapterLogVerbose: l18n.Sprintf("Verbose, with handshakes"),
+		conf.AdapterLogErrors:  l18n.Sprintf("Errors only"),
+		conf.AdapterLogOff:     l18n.Sprintf("Off"),
+	})
+	logLevelComboBox.SetCurrentIndex(int(settings.DefaultAdapterLogLevel))
+	if err = addSettingsRow(group, 3, l18n.Sprintf("Driver &logging:"), logLevelComboBox); err != nil {
+		return err
+	}
+
+	silentUpdatesCheckBox, err := walk.NewCheckBox(group)
+	if err != nil {
+		return err
+	}
+	silentUpdatesCheckBox.SetText(l18n.Sprintf("&Install updates automatically, without anyone signed in"))
+	silentUpdatesCheckBox.SetChecked(settings.SilentUpdates)
+	group.Layout().(*walk.GridLayout).SetRange(silentUpdatesCheckBox, walk.Rectangle{X: 1, Y: 4, Width: 1, Height: 1})
+
+	maintenanceWindowEdit, err := walk.NewLineEdit(group)
+	if err != nil {
+		return err
+	}
+	maintenanceWindowEdit.SetCueBanner(l18n.Sprintf("Any time, such as: Daily 03:00-05:00"))
+	if settings.MaintenanceWindow != nil {
+		maintenanceWindowEdit.SetText(settings.MaintenanceWindow.String())
+	}
+	maintenanceWindowEdit.SetEnabled(settings.SilentUpdates)
+	if err = addSettingsRow(group, 5, l18n.Sprintf("&Maintenance window:"), maintenanceWindowEdit); err != nil {
+		return err
+	}
+	maintenanceWindow := settings.MaintenanceWindow
+
+	if err = sp.addRollbackButton(group, 6); err != nil {
+		return err
+	}
+
+	saveSettings := func() {
+		settings := conf.Settings{
+			DefaultKillSwitch:      killSwitchCheckBox.Checked() && !policies.ForceKillSwitch,
+			DefaultAdapterLogLevel: conf.AdapterLogLevel(logLevelComboBox.CurrentIndex()),
+			SilentUpdates:          silentUpdatesCheckBox.Checked(),
+			MaintenanceWindow:      maintenanceWindow,
+		}
+		if i := updateCheckComboBox.CurrentIndex(); i > 0 {
+			settings.UpdateCheckHours = updateCheckIntervals[i]
+		}
+		if err := manager.IPCClientSetSettings(&settings); err != nil {
+			showErrorCustom(sp.Form(), l18n.Sprintf("Unable to change settings"), err.Error())
+		}
+	}
+	updateCheckComboBox.CurrentIndexChanged().Attach(saveSettings)
+	killSwitchCheckBox.CheckedChanged().Attach(saveSettings)
+	logLevelComboBox.CurrentIndexChanged().Attach(saveSettings)
+	silentUpdatesCheckBox.CheckedChanged().Attach(func() {
+		maintenanceWindowEdit.SetEnabled(silentUpdatesCheckBox.Checked())
+		saveSettings()
+	})
+	maintenanceWindowEdit.EditingFinished().Attach(func() {
+		text := strings.TrimSpace(maintenanceWindowEdit.Text())
+		if len(text) == 0 {
+			if maintenanceWindow == nil {
+				return
+			}
+			maintenanceWindow = nil
+			saveSettings()
+			return
+		}
+		w, err := conf.ParseScheduleWindow(text)
+		if err != nil {
+			showErrorCustom(sp.Form(), l18n.Sprintf("Invalid maintenance window"), err.Error())
+			return
+		}
+		if maintenanceWindow != nil && *maintenanceWindow == w {
+			return
+		}
+		maintenanceWindow = &w
+		maintenanceWindowEdit.SetText(w.String())
+		saveSettings()
+	})
+	return nil
+}
+
+// addRollbackButton adds a button that reinstalls the version before the running one, if the
+// manager kept its package.
+func (sp *SettingsPage) addRollbackButton(group *walk.GroupBox, row int) error {
+	version, err := manager.IPCClientRollbackVersion()
+	if err != nil || len(version) == 0 {
+		return nil
+	}
+	rollbackButton, err := walk.NewPushButton(group)
+	if err != nil {
+		return err
+	}
+	rollbackButton.SetText(l18n.Sprintf("&Roll back to version %s…", version))
+	rollbackButton.SetAlignment(walk.AlignHNearVCenter)
+	group.Layout().(*walk.GridLayout).SetRange(rollbackButton, walk.Rectangle{X: 1, Y: row, Width: 1, Height: 1})
+	rollbackButton.Clicked().Attach(func() {
+		if walk.MsgBox(sp.Form(), l18n.Sprintf("Roll back"), l18n.Sprintf("WireGuard %s will be reinstalled in place of this version. Running tunnels are kept up, and their configurations are kept.\n\nRoll back now?", version), walk.MsgBoxYesNo|walk.MsgBoxIconWarning) != walk.DlgCmdYes {
+			return
+		}
+		rollbackButton.SetEnabled(false)
+		if err := manager.IPCClientRollback(); err != nil {
+			rollbackButton.SetEnabled(true)
+			showErrorCustom(sp.Form(), l18n.Sprintf("Unable to roll back"), err.Error())
+		}
+	})
+	progressCB := manager.IPCClientRegisterUpdateProgress(func(dp updater.DownloadProgress) {
+		sp.Synchronize(func() {
+			if rollbackButton.Enabled() {
+				return
+			}
+			if dp.Error != nil {
+				rollbackButton.SetEnabled(true)
+				showErrorCustom(sp.Form(), l18n.Sprintf("Unable to roll back"), dp.Error.Error())
+			}
+		})
+	})
+	rollbackButton.Disposing().Attach(progressCB.Unregister)
+	return nil
+}
+
+func (sp *SettingsPage) createUserSettings() error {
+	group, err := newSettingsGroup(sp, l18n.Sprintf("This user"))
+	if err != nil {
+		return err
+	}
+
+	openWindowCheckBox, err := walk.NewCheckBox(group)
+	if err != nil {
+		return err
+	}
+	openWindowCheckBox.SetText(l18n.Sprintf("&Open this window when signing in"))
+	openWindowCheckBox.SetChecked(openWindowAtSignIn())
+	openWindowCheckBox.CheckedChanged().Attach(func() {
+		if err := setOpenWindowAtSignIn(openWindowCheckBox.Checked()); err != nil {
+			showErrorCustom(sp.Form(), l18n.Sprintf("Unable to change settings"), err.Error())
+		}
+	})
+	group.Layout().(*walk.GridLayout).SetRange(openWindowCheckBox, walk.Rectangle{X: 1, Y: 0, Width: 1, Height: 1})
+
+	trayClickComboBox, err := walk.NewDropDownBox(group)
+	if err != nil {
+		return err
+	}
+	trayClickComboBox.SetModel([]string{l18n.Sprintf("Opens this window"), l18n.Sprintf("Toggles the favorite tunnel")})
+	if trayClickTogglesFavorite() {
+		trayClickComboBox.SetCurrentIndex(1)
+	} else {
+		trayClickComboBox.SetCurrentIndex(0)
+	}
+	trayClickComboBox.CurrentIndexChanged().Attach(func() {
+		if err := setTrayClickTogglesFavorite(trayClickComboBox.CurrentIndex() == 1); err != nil {
+			showErrorCustom(sp.Form(), l18n.Sprintf("Unable to change settings"), err.Error())
+		}
+	})
+	if err = addSettingsRow(group, 1, l18n.Sprintf("Clicking the &tray icon:"), trayClickComboBox); err != nil {
+		return err
+	}
+
+	languages := l18n.Languages()
+	languageNames := []string{l18n.Sprintf("Same as Windows")}
+	current := 0
+	override := l18n.LanguageOverride()
+	for i, tag := range languages {
+		name := display.Self.Name(tag)
+		if len(name) == 0 {
+			name = tag.String()
+		}
+		languageNames = append(languageNames, name)
+		if tag.String() == override {
+			current = i + 1
+		}
+	}
+	languageComboBox, err := walk.NewDropDownBox(group)
+	if err != nil {
+		return err
+	}
+	languageComboBox.SetModel(languageNames)
+	languageComboBox.SetCurrentIndex(current)
+	languageComboBox.CurrentIndexChanged().Attach(func() {
+		name := ""
+		if i := languageComboBox.CurrentIndex(); i > 0 {
+			name = languages[i-1].String()
+		}
+		if err := l18n.SetLanguageOverride(name); err != nil {
+			showErrorCustom(sp.Form(), l18n.Sprintf("Unable to change settings"), err.Error())
+			return
+		}
+		rebuildUI()
+	})
+	if err = addSettingsRow(group, 2, l18n.Sprintf("Lan&guage:"), languageComboBox); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package syntax
+
+import (
+	"strings"
+	"unsafe"
+
+	"github.com/lxn/win"
+	"golang.org/x/sys/windows"
+)
+
+// completions returns the keys of the section at caret that the word before it begins, along with
+// where that word starts. There are none unless the word is all the line holds before the caret.
+func completions(config string, caret int) (start int, keys []string) {
+	if caret > len(config) {
+		return 0, nil
+	}
+	lineStart := strings.LastIndexByte(config[:caret], '\n') + 1
+	start = lineStart
+	for start < caret && (config[start] == ' ' || config[start] == '\t') {
+		start++
+	}
+	prefix := config[start:caret]
+	for _, c := range []byte(prefix) {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return 0, nil
+		}
+	}
+
+	section := fieldInvalid
+	for end := lineStart - 1; end > 0; {
+		begin := strings.LastIndexByte(config[:end], '\n') + 1
+		line, _, _ := strings.Cut(config[begin:end], "#")
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "[") {
+			if strings.EqualFold(line, "[Interface]") {
+				section = fieldInterfaceSection
+			} else if strings.EqualFold(line, "[Peer]") {
+				section = fieldPeerSection
+			}
+			break
+		}
+		end = begin - 1
+	}
+	if section == fieldInvalid {
+		return 0, nil
+	}
+	for f, name := range fieldNames {
+		if len(name) > 0 && sectionForField(field(f)) == section && len(name) >= len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) {
+			keys = append(keys, name)
+		}
+	}
+	return start, keys
+}
+
+// complete offers the keys that complete the word before the caret, inserting the key if there is
+// only one, and otherwise letting the user pick one from a menu at the caret.
+func (se *SyntaxEdit) complete() {
+	hWnd := se.Handle()
+	var selection win.CHARRANGE
+	win.SendMessage(hWnd, win.EM_EXGETSEL, 0, uintptr(unsafe.Pointer(&selection)))
+	cfg, err := se.textWithPositions()
+	if err != nil {
+		return
+	}
+	start, keys := completions(cfg, int(selection.CpMin))
+	if len(keys) == 0 {
+		win.MessageBeep(win.MB_OK)
+		return
+	}
+	key := keys[0]
+	if len(keys) > 1 {
+		var caret win.POINT
+		win.SendMessage(hWnd, win.EM_POSFROMCHAR, uintptr(unsafe.Pointer(&caret)), uintptr(selection.CpMin))
+		caret.Y += int32(se.yheight / 20 * se.DPI() / 72)
+		win.ClientToScreen(hWnd, &caret)
+		menu := win.CreatePopupMenu()
+		defer win.DestroyMenu(menu)
+		for i, name := range keys {
+			win.InsertMenuItem(menu, uint32(i), true, &win.MENUITEMINFO{
+				CbSize:     uint32(unsafe.Sizeof(win.MENUITEMINFO{})),
+				FMask:      win.MIIM_ID | win.MIIM_STRING | win.MIIM_FTYPE,
+				FType:      win.MFT_STRING,
+				DwTypeData: windows.StringToUTF16Ptr(name),
+				WID:        uint32(i + 1),
+			})
+		}
+		cmd := win.TrackPopupMenu(menu, win.TPM_LEFTALIGN|win.TPM_TOPALIGN|win.TPM_RETURNCMD|win.TPM_NONOTIFY, caret.X, caret.Y, 0, hWnd, nil)
+		if cmd == 0 {
+			return
+		}
+		key = keys[cmd-1]
+	}
+	selection = win.CHARRANGE{CpMin: int32(start), CpMax: selection.CpMin}
+	win.SendMessage(hWnd, win.EM_EXSETSEL, 0, uintptr(unsafe.Pointer(&selection)))
+	win.SendMessage(hWnd, win.EM_REPLACESEL, win.TRUE, uintptr(unsafe.Pointer(windows.StringToUTF16Ptr(key+" = "))))
+}
@@ -27,6 +27,7 @@ const (
 	highlightComment
 	highlightDelimiter
 	highlightTable
+	highlightBool
 	highlightCmd
 	highlightError
 )
@@ -261,6 +262,10 @@ func (s stringSpan) isValidTable() bool {
 	return s.isSame("off") || s.isSame("auto") || s.isSame("main") || s.isValidUint(false, 0, (1<<32)-1)
 }
 
+func (s stringSpan) isValidBool() bool {
+	return s.isCaselessSame("true") || s.isCaselessSame("false")
+}
+
 func (s stringSpan) isValidPersistentKeepAlive() bool {
 	if s.isSame("off") {
 		return true
@@ -273,6 +278,33 @@ func (s stringSpan) isValidPrePostUpDown() bool {
 	return s.len != 0
 }
 
+func (s stringSpan) isValidAbsolutePath() bool {
+	if s.len >= 3 && (*s.at(0)|0x20)-'a' < 26 && *s.at(1) == ':' && (*s.at(2) == '\\' || *s.at(2) == '/') {
+		return true
+	}
+	return s.len >= 3 && *s.at(0) == '\\' && *s.at(1) == '\\'
+}
+
+// isValidGUID accepts a GUID such as {1b3d5f7a-0000-4000-8000-000000000001}, with or without its braces.
+func (s stringSpan) isValidGUID() bool {
+	if s.len == 38 && *s.at(0) == '{' && *s.at(37) == '}' {
+		s = stringSpan{s.at(1), 36}
+	}
+	if s.len != 36 {
+		return false
+	}
+	for i := 0; i < s.len; i++ {
+		if i == 8 || i == 13 || i == 18 || i == 23 {
+			if *s.at(i) != '-' {
+				return false
+			}
+		} else if !isHexadecimal(*s.at(i)) {
+			return false
+		}
+	}
+	return true
+}
+
 func (s stringSpan) isValidScope() bool {
 	if s.len > 64 || s.len == 0 {
 		return false
@@ -365,15 +397,41 @@ const (
 	fieldAddress
 	fieldDNS
 	fieldMTU
+	fieldInterfaceMetric
+	fieldRouteMetric
 	fieldTable
+	fieldKillSwitch
+	fieldDisableTemporaryAddresses
+	fieldDisableDAD
+	fieldExcludeIPs
+	fieldExcludePorts
+	fieldExcludeApps
+	fieldAllowLocalNetwork
+	fieldBindInterface
+	fieldJc
+	fieldJmin
+	fieldJmax
+	fieldS1
+	fieldS2
+	fieldH1
+	fieldH2
+	fieldH3
+	fieldH4
 	fieldPreUp
 	fieldPostUp
 	fieldPreDown
 	fieldPostDown
+	fieldScriptTimeout
+	fieldCheckHost
+	fieldCheckTimeout
+	fieldAdapterGUID
+	fieldPeerSource
+	fieldPeerSourceKey
 	fieldPeerSection
 	fieldPublicKey
 	fieldPresharedKey
 	fieldAllowedIPs
+	fieldDisallowedIPs
 	fieldEndpoint
 	fieldPersistentKeepalive
 	fieldInvalid
@@ -389,38 +447,56 @@ func sectionForField(t field) field {
 	return fieldInvalid
 }
 
+// fieldNames are the keys of the fields, as written in configurations.
+var fieldNames = [...]string{
+	fieldPrivateKey:                "PrivateKey",
+	fieldListenPort:                "ListenPort",
+	fieldAddress:                   "Address",
+	fieldDNS:                       "DNS",
+	fieldMTU:                       "MTU",
+	fieldInterfaceMetric:           "InterfaceMetric",
+	fieldRouteMetric:               "RouteMetric",
+	fieldTable:                     "Table",
+	fieldKillSwitch:                "KillSwitch",
+	fieldDisableTemporaryAddresses: "DisableTemporaryAddresses",
+	fieldDisableDAD:                "DisableDAD",
+	fieldExcludeIPs:                "ExcludeIPs",
+	fieldExcludePorts:              "ExcludePorts",
+	fieldExcludeApps:               "ExcludeApps",
+	fieldAllowLocalNetwork:         "AllowLocalNetwork",
+	fieldBindInterface:             "BindInterface",
+	fieldJc:                        "Jc",
+	fieldJmin:                      "Jmin",
+	fieldJmax:                      "Jmax",
+	fieldS1:                        "S1",
+	fieldS2:                        "S2",
+	fieldH1:                        "H1",
+	fieldH2:                        "H2",
+	fieldH3:                        "H3",
+	fieldH4:                        "H4",
+	fieldPreUp:                     "PreUp",
+	fieldPostUp:                    "PostUp",
+	fieldPreDown:                   "PreDown",
+	fieldPostDown:                  "PostDown",
+	fieldScriptTimeout:             "ScriptTimeout",
+	fieldCheckHost:                 "CheckHost",
+	fieldCheckTimeout:              "CheckTimeout",
+	fieldAdapterGUID:               "AdapterGUID",
+	fieldPeerSource:                "PeerSource",
+	fieldPeerSourceKey:             "PeerSourceKey",
+	fieldPublicKey:                 "PublicKey",
+	fieldPresharedKey:              "PresharedKey",
+	fieldAllowedIPs:                "AllowedIPs",
+	fieldDisallowedIPs:             "DisallowedIPs",
+	fieldEndpoint:                  "Endpoint",
+	fieldPersistentKeepalive:       "PersistentKeepalive",
+}
+
 func (s stringSpan) field() field {
-	switch {
-	case s.isCaselessSame("PrivateKey"):
-		return fieldPrivateKey
-	case s.isCaselessSame("ListenPort"):
-		return fieldListenPort
-	case s.isCaselessSame("Address"):
-		return fieldAddress
-	case s.isCaselessSame("DNS"):
-		return fieldDNS
-	case s.isCaselessSame("MTU"):
-		return fieldMTU
-	case s.isCaselessSame("Table"):
-		return fieldTable
-	case s.isCaselessSame("PublicKey"):
-		return fieldPublicKey
-	case s.isCaselessSame("PresharedKey"):
-		return fieldPresharedKey
-	case s.isCaselessSame("AllowedIPs"):
-		return fieldAllowedIPs
-	case s.isCaselessSame("Endpoint"):
-		return fieldEndpoint
-	case s.isCaselessSame("PersistentKeepalive"):
-		return fieldPersistentKeepalive
-	case s.isCaselessSame("PreUp"):
-		return fieldPreUp
-	case s.isCaselessSame("PostUp"):
-		return fieldPostUp
-	case s.isCaselessSame("PreDown"):
-		return fieldPreDown
-	case s.isCaselessSame("PostDown"):
-		return fieldPostDown
+	for f, name := range fieldNames {
+		if len(name) > 0 && s.isCaselessSame(name) {
+			return field(f)
+		}
 	}
 	return fieldInvalid
 }
@@ -454,7 +530,11 @@ func (hsa *highlightSpanArray) highlightMultivalueValue(parent, s stringSpan, se
 		} else {
 			hsa.append(parent.s, s, highlightError)
 		}
-	case fieldAddress, fieldAllowedIPs:
+	case fieldExcludePorts:
+		hsa.append(parent.s, s, validateHighlight(s.isValidUint(false, 1, 65535), highlightPort))
+	case fieldExcludeApps:
+		hsa.append(parent.s, s, validateHighlight(s.isValidAbsolutePath(), highlightCmd))
+	case fieldAddress, fieldAllowedIPs, fieldDisallowedIPs, fieldExcludeIPs:
 		if !s.isValidNetwork() {
 			hsa.append(parent.s, s, highlightError)
 			break
@@ -516,10 +596,34 @@ func (hsa *highlightSpanArray) highlightValue(parent, s stringSpan, section fiel
 		hsa.append(parent.s, s, validateHighlight(s.isValidKey(), highlightPresharedKey))
 	case fieldMTU:
 		hsa.append(parent.s, s, validateHighlight(s.isValidMTU(), highlightMTU))
+	case fieldInterfaceMetric, fieldRouteMetric:
+		hsa.append(parent.s, s, validateHighlight(s.isValidUint(false, 1, 9999), highlightMTU))
+	case fieldScriptTimeout:
+		hsa.append(parent.s, s, validateHighlight(s.isValidUint(false, 1, 3600), highlightMTU))
+	case fieldCheckTimeout:
+		hsa.append(parent.s, s, validateHighlight(s.isValidUint(false, 1, 300), highlightMTU))
+	case fieldAdapterGUID:
+		hsa.append(parent.s, s, validateHighlight(s.isValidGUID(), highlightHost))
 	case fieldTable:
 		hsa.append(parent.s, s, validateHighlight(s.isValidTable(), highlightTable))
+	case fieldKillSwitch, fieldDisableTemporaryAddresses, fieldDisableDAD, fieldAllowLocalNetwork:
+		hsa.append(parent.s, s, validateHighlight(s.isValidBool(), highlightBool))
+	case fieldJc:
+		hsa.append(parent.s, s, validateHighlight(s.isValidUint(false, 0, 128), highlightMTU))
+	case fieldJmin, fieldJmax:
+		hsa.append(parent.s, s, validateHighlight(s.isValidUint(false, 0, 1280), highlightMTU))
+	case fieldS1:
+		hsa.append(parent.s, s, validateHighlight(s.isValidUint(false, 0, 1132), highlightMTU))
+	case fieldS2:
+		hsa.append(parent.s, s, validateHighlight(s.isValidUint(false, 0, 1188), highlightMTU))
+	case fieldH1, fieldH2, fieldH3, fieldH4:
+		hsa.append(parent.s, s, validateHighlight(s.isValidUint(false, 0, 0xffffffff), highlightMTU))
 	case fieldPreUp, fieldPostUp, fieldPreDown, fieldPostDown:
 		hsa.append(parent.s, s, validateHighlight(s.isValidPrePostUpDown(), highlightCmd))
+	case fieldBindInterface, fieldCheckHost, fieldPeerSource:
+		hsa.append(parent.s, s, validateHighlight(s.len != 0, highlightHost))
+	case fieldPeerSourceKey:
+		hsa.append(parent.s, s, validateHighlight(s.len != 0, highlightPublicKey))
 	case fieldListenPort:
 		hsa.append(parent.s, s, validateHighlight(s.isValidPort(), highlightPort))
 	case fieldPersistentKeepalive:
@@ -539,7 +643,7 @@ func (hsa *highlightSpanArray) highlightValue(parent, s stringSpan, section fiel
 		hsa.append(parent.s, stringSpan{s.s, colon}, highlightHost)
 		hsa.append(parent.s, stringSpan{s.at(colon), 1}, highlightDelimiter)
 		hsa.append(parent.s, stringSpan{s.at(colon + 1), s.len - colon - 1}, highlightPort)
-	case fieldAddress, fieldDNS, fieldAllowedIPs:
+	case fieldAddress, fieldDNS, fieldAllowedIPs, fieldDisallowedIPs, fieldExcludeIPs, fieldExcludePorts, fieldExcludeApps:
 		hsa.highlightMultivalue(parent, s, section)
 	default:
 		hsa.append(parent.s, s, highlightError)
@@ -25,8 +25,12 @@ type SyntaxEdit struct {
 	lastBlockState                  BlockState
 	yheight                         int
 	highlightGuard                  uint32
+	darkMode                        bool
 	textChangedPublisher            walk.EventPublisher
 	privateKeyPublisher             walk.StringEventPublisher
+	validator                       Validator
+	validationMessage               string
+	validationPublisher             walk.StringEventPublisher
 	blockUntunneledTrafficPublisher walk.IntEventPublisher
 }
 
@@ -72,6 +76,15 @@ func (se *SyntaxEdit) SetText(text string) (err error) {
 	return
 }
 
+// SetDarkMode switches between the system colors and light text on a dark background.
+func (se *SyntaxEdit) SetDarkMode(dark bool) {
+	if se.darkMode == dark {
+		return
+	}
+	se.darkMode = dark
+	se.highlightText()
+}
+
 func (se *SyntaxEdit) TextChanged() *walk.Event {
 	return se.textChangedPublisher.Event()
 }
@@ -84,6 +97,56 @@ func (se *SyntaxEdit) BlockUntunneledTrafficStateChanged() *walk.IntEvent {
 	return se.blockUntunneledTrafficPublisher.Event()
 }
 
+// Validator checks a configuration as it is typed, returning why it is invalid, and the line and
+// column that are to blame, counting from 1, or 0 if unknown.
+type Validator func(config string) (line, column int, err error)
+
+// SetValidator sets the validator of the text, whose errors are underlined on their line, and
+// published by ValidationChanged.
+func (se *SyntaxEdit) SetValidator(validator Validator) {
+	se.validator = validator
+	se.highlightText()
+}
+
+// ValidationChanged publishes the message of the error that the validator found, or the empty
+// string once there is none.
+func (se *SyntaxEdit) ValidationChanged() *walk.StringEvent {
+	return se.validationPublisher.Event()
+}
+
+// lineRange returns the start and end of the given line from the given column, counting both from
+// 1, or from its indentation if the column is unknown.
+func lineRange(cfg string, line, column int) (start, end int) {
+	if line <= 0 {
+		return 0, 0
+	}
+	for ; line > 1; line-- {
+		next := strings.IndexByte(cfg[start:], '\n')
+		if next < 0 {
+			return 0, 0
+		}
+		start += next + 1
+	}
+	end = len(cfg)
+	if next := strings.IndexByte(cfg[start:], '\n'); next >= 0 {
+		end = start + next
+	}
+	if column > 0 {
+		if start+column-1 < end {
+			start += column - 1
+		}
+		return start, end
+	}
+	for start < end && (cfg[start] == ' ' || cfg[start] == '\t') {
+		start++
+	}
+	return start, end
+}
+
+// underlineColorRed is the index of red among the colors of underlines, which RichEdit 8 and
+// later take.
+const underlineColorRed = 6
+
 type spanStyle struct {
 	color   win.COLORREF
 	effects uint32
@@ -101,6 +164,7 @@ var stylemap = map[highlight]spanStyle{
 	highlightPort:         {color: win.RGB(0x81, 0x5F, 0x03)},
 	highlightMTU:          {color: win.RGB(0x1C, 0x00, 0xCF)},
 	highlightTable:        {color: win.RGB(0x1C, 0x00, 0xCF)},
+	highlightBool:         {color: win.RGB(0x1C, 0x00, 0xCF)},
 	highlightKeepalive:    {color: win.RGB(0x1C, 0x00, 0xCF)},
 	highlightComment:      {color: win.RGB(0x53, 0x65, 0x79), effects: win.CFE_ITALIC},
 	highlightDelimiter:    {color: win.RGB(0x00, 0x00, 0x00)},
@@ -191,12 +255,9 @@ done:
 	}
 }
 
-func (se *SyntaxEdit) highlightText() error {
-	if !atomic.CompareAndSwapUint32(&se.highlightGuard, 0, 1) {
-		return nil
-	}
-	defer atomic.StoreUint32(&se.highlightGuard, 0)
-
+// textWithPositions returns the text with one character for each line break, as the positions
+// of the control count them.
+func (se *SyntaxEdit) textWithPositions() (string, error) {
 	hWnd := se.Handle()
 	gettextlengthex := win.GETTEXTLENGTHEX{
 		Flags:    win.GTL_NUMBYTES,
@@ -204,7 +265,7 @@ func (se *SyntaxEdit) highlightText() error {
 	}
 	msgSize := uint32(win.SendMessage(hWnd, win.EM_GETTEXTLENGTHEX, uintptr(unsafe.Pointer(&gettextlengthex)), 0))
 	if msgSize == win.E_INVALIDARG {
-		return errors.New("Failed to get text length")
+		return "", errors.New("Failed to get text length")
 	}
 
 	gettextex := win.GETTEXTEX{
@@ -215,12 +276,33 @@ func (se *SyntaxEdit) highlightText() error {
 	msg := make([]byte, msgSize+1)
 	msgCount := win.SendMessage(hWnd, win.EM_GETTEXTEX, uintptr(unsafe.Pointer(&gettextex)), uintptr(unsafe.Pointer(&msg[0])))
 	if msgCount < 0 {
-		return errors.New("Failed to get text")
+		return "", errors.New("Failed to get text")
+	}
+	return strings.Replace(string(msg[:msgCount]), "\r", "\n", -1), nil
+}
+
+func (se *SyntaxEdit) highlightText() error {
+	if !atomic.CompareAndSwapUint32(&se.highlightGuard, 0, 1) {
+		return nil
+	}
+	defer atomic.StoreUint32(&se.highlightGuard, 0)
+
+	hWnd := se.Handle()
+	cfg, err := se.textWithPositions()
+	if err != nil {
+		return err
 	}
-	cfg := strings.Replace(string(msg[:msgCount]), "\r", "\n", -1)
 
 	spans := highlightConfig(cfg)
 	se.evaluateUntunneledBlocking(cfg, spans)
+	var errorLine, errorColumn int
+	validationMessage := ""
+	if se.validator != nil {
+		var err error
+		if errorLine, errorColumn, err = se.validator(cfg); err != nil {
+			validationMessage = err.Error()
+		}
+	}
 
 	se.idoc.Undo(win.TomSuspend, nil)
 	win.SendMessage(hWnd, win.EM_SETEVENTMASK, 0, 0)
@@ -233,16 +315,22 @@ func (se *SyntaxEdit) highlightText() error {
 	format := win.CHARFORMAT2{
 		CHARFORMAT: win.CHARFORMAT{
 			CbSize:    uint32(unsafe.Sizeof(win.CHARFORMAT2{})),
-			DwMask:    win.CFM_COLOR | win.CFM_CHARSET | win.CFM_SIZE | win.CFM_BOLD | win.CFM_ITALIC | win.CFM_UNDERLINE,
+			DwMask:    win.CFM_COLOR | win.CFM_CHARSET | win.CFM_SIZE | win.CFM_BOLD | win.CFM_ITALIC | win.CFM_UNDERLINE | win.CFM_UNDERLINETYPE,
 			DwEffects: win.CFE_AUTOCOLOR,
 			BCharSet:  win.ANSI_CHARSET,
 		},
+		BUnderlineType: win.CFU_UNDERLINE,
 	}
 	if se.yheight != 0 {
 		format.YHeight = 20 * 10
 	}
-	win.SendMessage(hWnd, win.EM_SETCHARFORMAT, win.SCF_ALL, uintptr(unsafe.Pointer(&format)))
 	bgColor := win.COLORREF(win.GetSysColor(win.COLOR_WINDOW))
+	if se.darkMode {
+		bgColor = win.RGB(0x1E, 0x1E, 0x1E)
+		format.DwEffects = 0
+		format.CrTextColor = win.RGB(0xF0, 0xF0, 0xF0)
+	}
+	win.SendMessage(hWnd, win.EM_SETCHARFORMAT, win.SCF_ALL, uintptr(unsafe.Pointer(&format)))
 	bgInversion := (bgColor & win.RGB(0xFF, 0xFF, 0xFF)) ^ win.RGB(0xFF, 0xFF, 0xFF)
 	win.SendMessage(hWnd, win.EM_SETBKGNDCOLOR, 0, uintptr(bgColor))
 	numSpans := len(spans)
@@ -262,6 +350,20 @@ func (se *SyntaxEdit) highlightText() error {
 			foundPrivateKey = true
 		}
 	}
+	if start, end := lineRange(cfg, errorLine, errorColumn); start < end {
+		selection := win.CHARRANGE{CpMin: int32(start), CpMax: int32(end)}
+		win.SendMessage(hWnd, win.EM_EXSETSEL, 0, uintptr(unsafe.Pointer(&selection)))
+		errorFormat := win.CHARFORMAT2{
+			CHARFORMAT: win.CHARFORMAT{
+				CbSize:    uint32(unsafe.Sizeof(win.CHARFORMAT2{})),
+				DwMask:    win.CFM_UNDERLINE | win.CFM_UNDERLINETYPE,
+				DwEffects: win.CFE_UNDERLINE,
+			},
+			BUnderlineType:  win.CFU_UNDERLINEWAVE,
+			BUnderlineColor: underlineColorRed,
+		}
+		win.SendMessage(hWnd, win.EM_SETCHARFORMAT, win.SCF_SELECTION, uintptr(unsafe.Pointer(&errorFormat)))
+	}
 	win.SendMessage(hWnd, win.EM_SETSCROLLPOS, 0, uintptr(unsafe.Pointer(&origScroll)))
 	win.SendMessage(hWnd, win.EM_EXSETSEL, 0, uintptr(unsafe.Pointer(&origSelection)))
 	win.SendMessage(hWnd, win.EM_HIDESELECTION, win.FALSE, 0)
@@ -272,6 +374,10 @@ func (se *SyntaxEdit) highlightText() error {
 	if !foundPrivateKey {
 		se.privateKeyPublisher.Publish("")
 	}
+	if validationMessage != se.validationMessage {
+		se.validationMessage = validationMessage
+		se.validationPublisher.Publish(validationMessage)
+	}
 	return nil
 }
 
@@ -423,12 +529,22 @@ func (se *SyntaxEdit) WndProc(hWnd win.HWND, msg uint32, wParam, lParam uintptr)
 
 	case win.WM_KEYDOWN:
 		key := win.LOWORD(uint32(wParam))
+		if key == win.VK_SPACE && win.GetKeyState(win.VK_CONTROL) < 0 {
+			se.complete()
+			return 0
+		}
 		if key == 'V' && win.GetKeyState(win.VK_CONTROL) < 0 ||
 			key == win.VK_INSERT && win.GetKeyState(win.VK_SHIFT) < 0 {
 			win.SendMessage(hWnd, win.EM_PASTESPECIAL, win.CF_TEXT, 0)
 			return 0
 		}
 
+	case win.WM_CHAR:
+		// The space of the Ctrl+Space that completes keys is not to be typed.
+		if wParam == ' ' && win.GetKeyState(win.VK_CONTROL) < 0 {
+			return 0
+		}
+
 	case win.WM_CONTEXTMENU:
 		se.contextMenu(win.GET_X_LPARAM(lParam), win.GET_Y_LPARAM(lParam))
 		return 0
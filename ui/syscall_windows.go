@@ -0,0 +1,25 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+// https://docs.microsoft.com/en-us/windows/win32/api/dwmapi/nf-dwmapi-dwmsetwindowattribute
+//sys	dwmSetWindowAttribute(hwnd windows.HWND, attribute uint32, value unsafe.Pointer, size uint32) (ret error) = dwmapi.DwmSetWindowAttribute
+
+// https://docs.microsoft.com/en-us/windows/win32/api/wincred/nf-wincred-creduipromptforwindowscredentialsw
+//sys	credUIPromptForWindowsCredentials(uiInfo *credUIInfo, authError uint32, authPackage *uint32, inAuthBuffer unsafe.Pointer, inAuthBufferSize uint32, outAuthBuffer *unsafe.Pointer, outAuthBufferSize *uint32, save *int32, flags uint32) (ret error) = credui.CredUIPromptForWindowsCredentialsW
+
+// https://docs.microsoft.com/en-us/windows/win32/api/shellapi/nf-shellapi-shell_notifyicongetrect
+//sys	shellNotifyIconGetRect(identifier *notifyIconIdentifier, iconLocation unsafe.Pointer) (ret error) = shell32.Shell_NotifyIconGetRect
+
+// https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-getguithreadinfo
+//sys	getGUIThreadInfo(thread uint32, info *guiThreadInfo) (err error) = user32.GetGUIThreadInfo
+
+// https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-registerhotkey
+//sys	registerHotKey(hwnd windows.HWND, id int32, modifiers uint32, virtualKey uint32) (err error) = user32.RegisterHotKey
+//sys	unregisterHotKey(hwnd windows.HWND, id int32) (err error) = user32.UnregisterHotKey
+
+// https://docs.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-getdoubleclicktime
+//sys	getDoubleClickTime() (milliseconds uint32) = user32.GetDoubleClickTime
@@ -0,0 +1,183 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"unsafe"
+
+	"github.com/lxn/walk"
+	"github.com/lxn/win"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+
+	"golang.zx2c4.com/wireguard/windows/ui/syntax"
+)
+
+// Walk has no notion of themes, so in dark mode every widget gets its colors set explicitly, and
+// the table views paint their rows through their cell stylers.
+
+var (
+	darkModeActive     = false
+	darkWindowColor    = walk.RGB(0x20, 0x20, 0x20)
+	darkControlColor   = walk.RGB(0x2d, 0x2d, 0x2d)
+	darkAlternateColor = walk.RGB(0x2a, 0x2a, 0x2a)
+	darkTextColor      = walk.RGB(0xf0, 0xf0, 0xf0)
+	darkWindowBrush    *walk.SolidColorBrush
+	darkControlBrush   *walk.SolidColorBrush
+)
+
+const (
+	dwmwaUseImmersiveDarkModeBefore20H1 = 19
+	dwmwaUseImmersiveDarkMode           = 20
+)
+
+func systemUsesDarkMode() bool {
+	key, err := registry.OpenKey(registry.CURRENT_USER, `Software\Microsoft\Windows\CurrentVersion\Themes\Personalize`, registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	defer key.Close()
+	lightTheme, _, err := key.GetIntegerValue("AppsUseLightTheme")
+	return err == nil && lightTheme == 0
+}
+
+func highContrastActive() bool {
+	highContrast := win.HIGHCONTRAST{CbSize: uint32(unsafe.Sizeof(win.HIGHCONTRAST{}))}
+	if !win.SystemParametersInfo(win.SPI_GETHIGHCONTRAST, highContrast.CbSize, unsafe.Pointer(&highContrast), 0) {
+		return false
+	}
+	return highContrast.DwFlags&win.HCF_HIGHCONTRASTON != 0
+}
+
+// updateDarkMode reevaluates the system setting, and returns whether it has changed.
+func updateDarkMode() bool {
+	dark := systemUsesDarkMode() && !highContrastActive()
+	if dark == darkModeActive {
+		return false
+	}
+	darkModeActive = dark
+	if dark && darkWindowBrush == nil {
+		darkWindowBrush, _ = walk.NewSolidColorBrush(darkWindowColor)
+		darkControlBrush, _ = walk.NewSolidColorBrush(darkControlColor)
+	}
+	return true
+}
+
+func applyTheme(form walk.Form) {
+	var useDark uint32
+	if darkModeActive {
+		useDark = 1
+	}
+	hwnd := windows.HWND(form.Handle())
+	if dwmSetWindowAttribute(hwnd, dwmwaUseImmersiveDarkMode, unsafe.Pointer(&useDark), uint32(unsafe.Sizeof(useDark))) != nil {
+		dwmSetWindowAttribute(hwnd, dwmwaUseImmersiveDarkModeBefore20H1, unsafe.Pointer(&useDark), uint32(unsafe.Sizeof(useDark)))
+	}
+	if darkModeActive {
+		form.SetBackground(darkWindowBrush)
+	} else {
+		form.SetBackground(nil)
+	}
+	applyThemeToChildren(form)
+	form.Invalidate()
+}
+
+func applyThemeToChildren(container walk.Container) {
+	children := container.Children()
+	for i := 0; i < children.Len(); i++ {
+		applyThemeToWidget(children.At(i))
+	}
+}
+
+func applyThemeToWidget(widget walk.Widget) {
+	switch w := widget.(type) {
+	case *walk.TabWidget:
+		setControlTheme(w.Handle())
+		pages := w.Pages()
+		for i := 0; i < pages.Len(); i++ {
+			page := pages.At(i)
+			if darkModeActive {
+				page.SetBackground(darkWindowBrush)
+			} else {
+				page.SetBackground(nil)
+			}
+			applyThemeToChildren(page)
+		}
+		return
+	case *walk.TableView:
+		applyThemeToTableView(w)
+	case *syntax.SyntaxEdit:
+		w.SetDarkMode(darkModeActive)
+	case *walk.LineEdit:
+		if !w.ReadOnly() {
+			setEditBackground(w)
+		}
+	case *walk.TextEdit:
+		if !w.ReadOnly() {
+			setEditBackground(w)
+		}
+	case *walk.PushButton, *walk.CheckBox, *walk.RadioButton, *walk.ComboBox:
+		setControlTheme(w.Handle())
+	}
+	if colorer, ok := widget.(interface{ SetTextColor(walk.Color) }); ok {
+		if darkModeActive {
+			colorer.SetTextColor(darkTextColor)
+		} else {
+			colorer.SetTextColor(0)
+		}
+	}
+	if container, ok := widget.(walk.Container); ok {
+		applyThemeToChildren(container)
+	}
+}
+
+func setEditBackground(widget walk.Widget) {
+	if darkModeActive {
+		widget.SetBackground(darkControlBrush)
+	} else {
+		widget.SetBackground(nil)
+	}
+}
+
+func setControlTheme(hwnd win.HWND) {
+	if darkModeActive {
+		win.SetWindowTheme(hwnd, windows.StringToUTF16Ptr("DarkMode_Explorer"), nil)
+	} else {
+		win.SetWindowTheme(hwnd, nil, nil)
+	}
+}
+
+func applyThemeToTableView(tv *walk.TableView) {
+	theme := windows.StringToUTF16Ptr("Explorer")
+	if darkModeActive {
+		theme = windows.StringToUTF16Ptr("DarkMode_Explorer")
+	}
+	var className [32]uint16
+	for child := win.GetWindow(tv.Handle(), win.GW_CHILD); child != 0; child = win.GetWindow(child, win.GW_HWNDNEXT) {
+		if n, err := win.GetClassName(child, &className[0], len(className)); err != nil || windows.UTF16ToString(className[:n]) != "SysListView32" {
+			continue
+		}
+		win.SetWindowTheme(child, theme, nil)
+		if darkModeActive {
+			win.SendMessage(child, win.LVM_SETBKCOLOR, 0, uintptr(darkWindowColor))
+		}
+	}
+	if !darkModeActive {
+		tv.ApplySysColors()
+	}
+	tv.Invalidate()
+}
+
+// styleThemedCell is meant to be called first by the cell stylers of table views.
+func styleThemedCell(style *walk.CellStyle, alternatingRows bool) {
+	if !darkModeActive || style.Row() < 0 {
+		return
+	}
+	style.BackgroundColor = darkWindowColor
+	if alternatingRows && style.Row()%2 == 1 {
+		style.BackgroundColor = darkAlternateColor
+	}
+	style.TextColor = darkTextColor
+}
@@ -0,0 +1,59 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package toast
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// hstring is an HSTRING, an immutable string of the Windows Runtime.
+type hstring uintptr
+
+const (
+	_S_OK          = 0
+	_E_NOINTERFACE = 0x80004002
+)
+
+// Methods are numbered by their place in the vtables of the Windows SDK headers, after the three
+// of IUnknown and, for Windows Runtime interfaces, the three more of IInspectable.
+const (
+	_IUnknown_QueryInterface = 0
+	_IUnknown_AddRef         = 1
+	_IUnknown_Release        = 2
+
+	_IXmlDocumentIO_LoadXml = 6
+
+	_IToastNotificationFactory_CreateToastNotification = 6
+
+	_IToastNotificationManagerStatics_CreateToastNotifierWithId = 7
+
+	_IToastNotifier_Show = 6
+
+	_IToastNotification_add_Activated = 11
+
+	_IToastActivatedEventArgs_get_Arguments = 6
+)
+
+var (
+	_IID_IUnknown                         = windows.GUID{Data1: 0x00000000, Data2: 0x0000, Data3: 0x0000, Data4: [8]byte{0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}}
+	_IID_IAgileObject                     = windows.GUID{Data1: 0x94ea2b94, Data2: 0xe9cc, Data3: 0x49e0, Data4: [8]byte{0xc0, 0xff, 0xee, 0x64, 0xca, 0x8f, 0x5b, 0x90}}
+	_IID_IXmlDocument                     = windows.GUID{Data1: 0xf7f3a506, Data2: 0x1e87, Data3: 0x42d6, Data4: [8]byte{0xbc, 0xfb, 0xb8, 0xc8, 0x09, 0xfa, 0x54, 0x94}}
+	_IID_IXmlDocumentIO                   = windows.GUID{Data1: 0x6cd0e74e, Data2: 0xee65, Data3: 0x4489, Data4: [8]byte{0x9e, 0xbf, 0xca, 0x43, 0xe8, 0x7b, 0xa6, 0x37}}
+	_IID_IToastNotificationFactory        = windows.GUID{Data1: 0x04124b20, Data2: 0x82c6, Data3: 0x4229, Data4: [8]byte{0xb1, 0x09, 0xfd, 0x9e, 0xd4, 0x66, 0x2b, 0x53}}
+	_IID_IToastNotificationManagerStatics = windows.GUID{Data1: 0x50ac103f, Data2: 0xd235, Data3: 0x4598, Data4: [8]byte{0xbb, 0xef, 0x98, 0xfe, 0x4d, 0x1a, 0x3a, 0xd4}}
+	_IID_IToastActivatedEventArgs         = windows.GUID{Data1: 0xe3bf92f3, Data2: 0xc197, Data3: 0x436f, Data4: [8]byte{0x82, 0x65, 0x06, 0x25, 0x82, 0x4f, 0x8d, 0xac}}
+
+	// The IID of TypedEventHandler<ToastNotification, IInspectable>, which is derived from its
+	// type arguments.
+	_IID_ToastActivatedHandler = windows.GUID{Data1: 0xab54de2d, Data2: 0x97d9, Data3: 0x5528, Data4: [8]byte{0xb6, 0xad, 0x10, 0x5a, 0xfe, 0x15, 0x65, 0x30}}
+)
+
+//sys	roActivateInstance(classID hstring, instance *unsafe.Pointer) (ret error) = combase.RoActivateInstance
+//sys	roGetActivationFactory(classID hstring, iid *windows.GUID, factory *unsafe.Pointer) (ret error) = combase.RoGetActivationFactory
+//sys	windowsCreateString(s *uint16, length uint32, hs *hstring) (ret error) = combase.WindowsCreateString
+//sys	windowsDeleteString(hs hstring) (ret error) = combase.WindowsDeleteString
+//sys	windowsGetStringRawBuffer(hs hstring, length *uint32) (buffer uintptr) = combase.WindowsGetStringRawBuffer
+//sys	setCurrentProcessExplicitAppUserModelID(appID *uint16) (ret error) = shell32.SetCurrentProcessExplicitAppUserModelID
@@ -0,0 +1,315 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+// Package toast shows toast notifications of Windows 10 and later, with buttons, through the
+// Windows Runtime. The system holds them back while Focus Assist is on, as it does for any app.
+//
+// An unpackaged program may only show toasts under an application user model ID that a shortcut in
+// the Start menu carries, and learns of their activation only while it runs, as it does not
+// register a COM activator. Toasts are shown from the thread of the UI, whose apartment they are
+// created in; activation is reported on some other thread.
+package toast
+
+import (
+	"encoding/xml"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Action is a button of a notification, which reports its arguments when clicked.
+type Action struct {
+	Content   string
+	Arguments string
+}
+
+type Notification struct {
+	Title   string
+	Message string
+	// Launch is reported when the body of the notification is clicked.
+	Launch  string
+	Actions []Action
+}
+
+// Supported returns whether the system shows toasts with buttons, which is Windows 10 and later.
+func Supported() bool {
+	return windows.RtlGetVersion().MajorVersion >= 10
+}
+
+// SetAppID sets the application user model ID of the process, which must be that of its shortcut
+// in the Start menu, before any window is shown.
+func SetAppID(appID string) error {
+	appID16, err := windows.UTF16PtrFromString(appID)
+	if err != nil {
+		return err
+	}
+	return setCurrentProcessExplicitAppUserModelID(appID16)
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// content returns the XML of a notification, in the generic template.
+func (n *Notification) content() string {
+	var b strings.Builder
+	b.WriteString(`<toast launch="` + escape(n.Launch) + `"><visual><binding template="ToastGeneric">`)
+	b.WriteString(`<text>` + escape(n.Title) + `</text><text>` + escape(n.Message) + `</text>`)
+	b.WriteString(`</binding></visual>`)
+	if len(n.Actions) > 0 {
+		b.WriteString(`<actions>`)
+		for _, action := range n.Actions {
+			b.WriteString(`<action content="` + escape(action.Content) + `" arguments="` + escape(action.Arguments) + `" activationType="foreground"/>`)
+		}
+		b.WriteString(`</actions>`)
+	}
+	b.WriteString(`</toast>`)
+	return b.String()
+}
+
+func hresult(r uintptr) error {
+	if int32(r) < 0 {
+		return syscall.Errno(r)
+	}
+	return nil
+}
+
+func method(object unsafe.Pointer, index int) uintptr {
+	vtbl := *(*unsafe.Pointer)(object)
+	return *(*uintptr)(unsafe.Add(vtbl, uintptr(index)*unsafe.Sizeof(uintptr(0))))
+}
+
+func release(object unsafe.Pointer) {
+	if object != nil {
+		syscall.SyscallN(method(object, _IUnknown_Release), uintptr(object))
+	}
+}
+
+func queryInterface(object unsafe.Pointer, iid *windows.GUID) (unsafe.Pointer, error) {
+	var result unsafe.Pointer
+	r, _, _ := syscall.SyscallN(method(object, _IUnknown_QueryInterface), uintptr(object), uintptr(unsafe.Pointer(iid)), uintptr(unsafe.Pointer(&result)))
+	if err := hresult(r); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func newHstring(s string) (hstring, error) {
+	s16 := utf16.Encode([]rune(s))
+	var hs hstring
+	if len(s16) == 0 {
+		return 0, nil // The empty string is the null HSTRING.
+	}
+	err := windowsCreateString(&s16[0], uint32(len(s16)), &hs)
+	return hs, err
+}
+
+func hstringToString(hs hstring) string {
+	var length uint32
+	buffer := windowsGetStringRawBuffer(hs, &length)
+	if buffer == 0 || length == 0 {
+		return ""
+	}
+	return string(utf16.Decode(unsafe.Slice(*(**uint16)(unsafe.Pointer(&buffer)), length)))
+}
+
+func activationFactory(className string, iid *windows.GUID) (unsafe.Pointer, error) {
+	name, err := newHstring(className)
+	if err != nil {
+		return nil, err
+	}
+	defer windowsDeleteString(name)
+	var factory unsafe.Pointer
+	err = roGetActivationFactory(name, iid, &factory)
+	return factory, err
+}
+
+// activatedHandler is a TypedEventHandler<ToastNotification, IInspectable>, implemented in Go. Since
+// it is agile, the Windows Runtime calls it on whatever thread the activation arrives on.
+type activatedHandler struct {
+	vtbl      *activatedHandlerVtbl
+	refs      int32
+	activated func(arguments string)
+}
+
+type activatedHandlerVtbl struct {
+	queryInterface uintptr
+	addRef         uintptr
+	release        uintptr
+	invoke         uintptr
+}
+
+var (
+	activatedHandlerVtblOnce sync.Once
+	activatedHandlerMethods  activatedHandlerVtbl
+
+	// Handlers are referenced by the Windows Runtime, which the garbage collector does not see, so
+	// they are kept here until it releases them.
+	liveHandlersLock sync.Mutex
+	liveHandlers     = make(map[*activatedHandler]bool)
+)
+
+func newActivatedHandler(activated func(arguments string)) *activatedHandler {
+	activatedHandlerVtblOnce.Do(func() {
+		activatedHandlerMethods = activatedHandlerVtbl{
+			queryInterface: windows.NewCallback(func(this *activatedHandler, iid *windows.GUID, object *unsafe.Pointer) uintptr {
+				if *iid != _IID_IUnknown && *iid != _IID_IAgileObject && *iid != _IID_ToastActivatedHandler {
+					*object = nil
+					return _E_NOINTERFACE
+				}
+				atomic.AddInt32(&this.refs, 1)
+				*object = unsafe.Pointer(this)
+				return _S_OK
+			}),
+			addRef: windows.NewCallback(func(this *activatedHandler) uintptr {
+				return uintptr(atomic.AddInt32(&this.refs, 1))
+			}),
+			release: windows.NewCallback(func(this *activatedHandler) uintptr {
+				refs := atomic.AddInt32(&this.refs, -1)
+				if refs == 0 {
+					liveHandlersLock.Lock()
+					delete(liveHandlers, this)
+					liveHandlersLock.Unlock()
+				}
+				return uintptr(refs)
+			}),
+			invoke: windows.NewCallback(func(this *activatedHandler, sender, args unsafe.Pointer) uintptr {
+				var arguments string
+				if args != nil {
+					if activatedArgs, err := queryInterface(args, &_IID_IToastActivatedEventArgs); err == nil {
+						var hs hstring
+						r, _, _ := syscall.SyscallN(method(activatedArgs, _IToastActivatedEventArgs_get_Arguments), uintptr(activatedArgs), uintptr(unsafe.Pointer(&hs)))
+						if hresult(r) == nil {
+							arguments = hstringToString(hs)
+							windowsDeleteString(hs)
+						}
+						release(activatedArgs)
+					}
+				}
+				this.activated(arguments)
+				return _S_OK
+			}),
+		}
+	})
+	handler := &activatedHandler{vtbl: &activatedHandlerMethods, refs: 1, activated: activated}
+	liveHandlersLock.Lock()
+	liveHandlers[handler] = true
+	liveHandlersLock.Unlock()
+	return handler
+}
+
+// The events of a toast are only raised while it is referenced, so the most recent ones are kept.
+const keptToasts = 8
+
+var (
+	recentToastsLock sync.Mutex
+	recentToasts     []unsafe.Pointer
+)
+
+func keepToast(toast unsafe.Pointer) {
+	recentToastsLock.Lock()
+	defer recentToastsLock.Unlock()
+	recentToasts = append(recentToasts, toast)
+	if len(recentToasts) > keptToasts {
+		release(recentToasts[0])
+		recentToasts = recentToasts[1:]
+	}
+}
+
+func loadXML(content string) (unsafe.Pointer, error) {
+	className, err := newHstring("Windows.Data.Xml.Dom.XmlDocument")
+	if err != nil {
+		return nil, err
+	}
+	defer windowsDeleteString(className)
+	var inspectable unsafe.Pointer
+	err = roActivateInstance(className, &inspectable)
+	if err != nil {
+		return nil, err
+	}
+	defer release(inspectable)
+	documentIO, err := queryInterface(inspectable, &_IID_IXmlDocumentIO)
+	if err != nil {
+		return nil, err
+	}
+	defer release(documentIO)
+	text, err := newHstring(content)
+	if err != nil {
+		return nil, err
+	}
+	defer windowsDeleteString(text)
+	r, _, _ := syscall.SyscallN(method(documentIO, _IXmlDocumentIO_LoadXml), uintptr(documentIO), uintptr(text))
+	if err = hresult(r); err != nil {
+		return nil, err
+	}
+	return queryInterface(inspectable, &_IID_IXmlDocument)
+}
+
+// Show shows a notification under the given application user model ID, and calls activated with
+// the arguments of the button or body that is clicked, on some thread other than that of the UI.
+func Show(appID string, n *Notification, activated func(arguments string)) error {
+	document, err := loadXML(n.content())
+	if err != nil {
+		return err
+	}
+	defer release(document)
+
+	factory, err := activationFactory("Windows.UI.Notifications.ToastNotification", &_IID_IToastNotificationFactory)
+	if err != nil {
+		return err
+	}
+	defer release(factory)
+	var toast unsafe.Pointer
+	r, _, _ := syscall.SyscallN(method(factory, _IToastNotificationFactory_CreateToastNotification), uintptr(factory), uintptr(document), uintptr(unsafe.Pointer(&toast)))
+	if err = hresult(r); err != nil {
+		return err
+	}
+
+	if activated != nil {
+		handler := newActivatedHandler(activated)
+		var token int64
+		r, _, _ = syscall.SyscallN(method(toast, _IToastNotification_add_Activated), uintptr(toast), uintptr(unsafe.Pointer(handler)), uintptr(unsafe.Pointer(&token)))
+		// The toast holds its own reference to the handler, if it took it.
+		syscall.SyscallN(activatedHandlerMethods.release, uintptr(unsafe.Pointer(handler)))
+		if err = hresult(r); err != nil {
+			release(toast)
+			return err
+		}
+	}
+
+	statics, err := activationFactory("Windows.UI.Notifications.ToastNotificationManager", &_IID_IToastNotificationManagerStatics)
+	if err != nil {
+		release(toast)
+		return err
+	}
+	defer release(statics)
+	id, err := newHstring(appID)
+	if err != nil {
+		release(toast)
+		return err
+	}
+	defer windowsDeleteString(id)
+	var notifier unsafe.Pointer
+	r, _, _ = syscall.SyscallN(method(statics, _IToastNotificationManagerStatics_CreateToastNotifierWithId), uintptr(statics), uintptr(id), uintptr(unsafe.Pointer(&notifier)))
+	if err = hresult(r); err != nil {
+		release(toast)
+		return err
+	}
+	defer release(notifier)
+	r, _, _ = syscall.SyscallN(method(notifier, _IToastNotifier_Show), uintptr(notifier), uintptr(toast))
+	if err = hresult(r); err != nil {
+		release(toast)
+		return err
+	}
+	keepToast(toast)
+	return nil
+}
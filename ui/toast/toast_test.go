@@ -0,0 +1,32 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package toast
+
+import (
+	"testing"
+)
+
+func TestContent(t *testing.T) {
+	n := &Notification{
+		Title:   "WireGuard Activated",
+		Message: "The <a&b> tunnel has been activated.",
+		Launch:  "action=open&tunnel=a",
+		Actions: []Action{{Content: "Disconnect", Arguments: `action=disconnect&tunnel="a"`}},
+	}
+	expected := `<toast launch="action=open&amp;tunnel=a"><visual><binding template="ToastGeneric">` +
+		`<text>WireGuard Activated</text><text>The &lt;a&amp;b&gt; tunnel has been activated.</text></binding></visual>` +
+		`<actions><action content="Disconnect" arguments="action=disconnect&amp;tunnel=&#34;a&#34;" activationType="foreground"/></actions></toast>`
+	if content := n.content(); content != expected {
+		t.Errorf("content = %s, expected %s", content, expected)
+	}
+
+	n.Actions = nil
+	expected = `<toast launch="action=open&amp;tunnel=a"><visual><binding template="ToastGeneric">` +
+		`<text>WireGuard Activated</text><text>The &lt;a&amp;b&gt; tunnel has been activated.</text></binding></visual></toast>`
+	if content := n.content(); content != expected {
+		t.Errorf("content = %s, expected %s", content, expected)
+	}
+}
@@ -26,24 +26,47 @@ const trayTunnelActionsOffset = 3
 type Tray struct {
 	*walk.NotifyIcon
 
-	// Current known tunnels by name
+	// Current known tunnels by name, each followed by a disabled action with its transfer
+	// statistics, which is shown only while the tunnel is active
 	tunnels                  map[string]*walk.Action
+	statsActions             map[string]*walk.Action
 	tunnelsAreInBreakoutMenu bool
 
+	// Last fetched statistics of active tunnels by name, and the state of them all
+	stats         map[string]*trayTunnelStats
+	statsFetching bool
+	statsDone     chan struct{}
+	globalState   manager.TunnelState
+
+	// Addresses of active tunnels by name
+	addresses map[string][]string
+
+	// Profiles of all tunnels that have any, rebuilt whenever the menu is opened
+	profilesMenu   *walk.Menu
+	profilesAction *walk.Action
+
+	// Tunnel groups, rebuilt whenever the menu is opened
+	groupsMenu   *walk.Menu
+	groupsAction *walk.Action
+
 	mtw *ManageTunnelsWindow
 
 	tunnelChangedCB  *manager.TunnelChangeCallback
 	tunnelsChangedCB *manager.TunnelsChangeCallback
 
-	clicked func()
+	clicked      func()
+	pendingClick *time.Timer
 }
 
 func NewTray(mtw *ManageTunnelsWindow) (*Tray, error) {
 	var err error
 
 	tray := &Tray{
-		mtw:     mtw,
-		tunnels: make(map[string]*walk.Action),
+		mtw:          mtw,
+		tunnels:      make(map[string]*walk.Action),
+		statsActions: make(map[string]*walk.Action),
+		stats:        make(map[string]*trayTunnelStats),
+		addresses:    make(map[string][]string),
 	}
 
 	tray.NotifyIcon, err = walk.NewNotifyIcon(mtw)
@@ -65,13 +88,19 @@ func (tray *Tray) setup() error {
 
 	tray.MouseDown().Attach(func(x, y int, button walk.MouseButton) {
 		if button == walk.LeftButton {
-			tray.clicked()
+			tray.onLeftClick()
+		} else if button == walk.RightButton {
+			tray.refreshProfiles()
+			tray.refreshGroups()
+			tray.applyTunnelFilter()
+			tray.refreshStats()
 		}
 	})
 	tray.MessageClicked().Attach(func() {
 		tray.clicked()
 	})
 
+	policies := conf.LoadPolicies()
 	for _, item := range [...]struct {
 		label     string
 		handler   walk.EventHandler
@@ -85,10 +114,12 @@ func (tray *Tray) setup() error {
 		{separator: true},
 		{separator: true},
 		{label: l18n.Sprintf("&Manage tunnels…"), handler: tray.onManageTunnels, enabled: true, defawlt: true},
-		{label: l18n.Sprintf("&Import tunnel(s) from file…"), handler: tray.onImport, enabled: true, hidden: !IsAdmin},
+		{label: l18n.Sprintf("&Import tunnel(s) from file…"), handler: tray.onImport, enabled: !policies.DisableConfigEditing, hidden: !IsAdmin},
+		{label: l18n.Sprintf("Import tunnel from QR code on &screen…"), handler: tray.onImportFromScreen, enabled: !policies.DisableConfigEditing, hidden: !IsAdmin},
+		{label: l18n.Sprintf("Show &QR code…"), handler: tray.onShowQRCode, enabled: true, hidden: !IsAdmin},
 		{separator: true},
 		{label: l18n.Sprintf("&About WireGuard…"), handler: tray.onAbout, enabled: true},
-		{label: l18n.Sprintf("E&xit"), handler: onQuit, enabled: true, hidden: !IsAdmin},
+		{label: l18n.Sprintf("E&xit"), handler: onQuit, enabled: true, hidden: !IsAdmin || policies.HideExitMenuItem},
 	} {
 		var action *walk.Action
 		if item.separator {
@@ -106,9 +137,28 @@ func (tray *Tray) setup() error {
 
 		tray.ContextMenu().Actions().Add(action)
 	}
+	var err error
+	tray.profilesMenu, err = walk.NewMenu()
+	if err != nil {
+		return err
+	}
+	tray.profilesAction = walk.NewMenuAction(tray.profilesMenu)
+	tray.profilesAction.SetText(l18n.Sprintf("&Profiles"))
+	tray.profilesAction.SetVisible(false)
+	// Right after the separator that follows the tunnels, of which there are none yet.
+	tray.ContextMenu().Actions().Insert(trayTunnelActionsOffset+1, tray.profilesAction)
+	tray.groupsMenu, err = walk.NewMenu()
+	if err != nil {
+		return err
+	}
+	tray.groupsAction = walk.NewMenuAction(tray.groupsMenu)
+	tray.groupsAction.SetText(l18n.Sprintf("&Groups"))
+	tray.groupsAction.SetVisible(false)
+	tray.ContextMenu().Actions().Insert(trayTunnelActionsOffset+1, tray.groupsAction)
+	currentTunnelFilter.Changed(tray.applyTunnelFilter)
 	tray.tunnelChangedCB = manager.IPCClientRegisterTunnelChange(tray.onTunnelChange)
 	tray.tunnelsChangedCB = manager.IPCClientRegisterTunnelsChange(tray.onTunnelsChange)
-	tray.onTunnelsChange()
+	tray.loadTunnels()
 	globalState, _ := manager.IPCClientGlobalState()
 	tray.updateGlobalState(globalState)
 
@@ -116,6 +166,7 @@ func (tray *Tray) setup() error {
 }
 
 func (tray *Tray) Dispose() error {
+	tray.stopWatchingStats()
 	if tray.tunnelChangedCB != nil {
 		tray.tunnelChangedCB.Unregister()
 		tray.tunnelChangedCB = nil
@@ -127,22 +178,24 @@ func (tray *Tray) Dispose() error {
 	return tray.NotifyIcon.Dispose()
 }
 
-func (tray *Tray) onTunnelsChange() {
+func (tray *Tray) loadTunnels() {
 	tunnels, err := manager.IPCClientTunnels()
 	if err != nil {
 		return
 	}
+	tray.onTunnelsChange(tunnels, nil)
+}
+
+func (tray *Tray) onTunnelsChange(added, removed []manager.Tunnel) {
 	tray.mtw.Synchronize(func() {
-		tunnelSet := make(map[string]bool, len(tunnels))
-		for _, tunnel := range tunnels {
-			tunnelSet[tunnel.Name] = true
-			if tray.tunnels[tunnel.Name] == nil {
-				tray.addTunnelAction(&tunnel)
+		for i := range removed {
+			if tray.tunnels[removed[i].Name] != nil {
+				tray.removeTunnelAction(removed[i].Name)
 			}
 		}
-		for trayTunnel := range tray.tunnels {
-			if !tunnelSet[trayTunnel] {
-				tray.removeTunnelAction(trayTunnel)
+		for i := range added {
+			if tray.tunnels[added[i].Name] == nil {
+				tray.addTunnelAction(&added[i])
 			}
 		}
 	})
@@ -178,6 +231,8 @@ func (tray *Tray) addTunnelAction(tunnel *manager.Tunnel) {
 		}(tunnel)
 	})
 	tray.tunnels[tunnel.Name] = tunnelAction
+	statsAction := newStatsAction()
+	tray.statsActions[tunnel.Name] = statsAction
 
 	var (
 		idx  int
@@ -191,10 +246,13 @@ func (tray *Tray) addTunnelAction(tunnel *manager.Tunnel) {
 
 	if tray.tunnelsAreInBreakoutMenu {
 		if tray.ContextMenu().Actions().Len() > trayTunnelActionsOffset {
-			tray.ContextMenu().Actions().At(trayTunnelActionsOffset).Menu().Actions().Insert(idx, tunnelAction)
+			breakoutActions := tray.ContextMenu().Actions().At(trayTunnelActionsOffset).Menu().Actions()
+			breakoutActions.Insert(2*idx, tunnelAction)
+			breakoutActions.Insert(2*idx+1, statsAction)
 		}
 	} else {
-		tray.ContextMenu().Actions().Insert(trayTunnelActionsOffset+idx, tunnelAction)
+		tray.ContextMenu().Actions().Insert(trayTunnelActionsOffset+2*idx, tunnelAction)
+		tray.ContextMenu().Actions().Insert(trayTunnelActionsOffset+2*idx+1, statsAction)
 	}
 	tray.rebalanceTunnelsMenu()
 
@@ -205,6 +263,7 @@ func (tray *Tray) addTunnelAction(tunnel *manager.Tunnel) {
 		}
 		tray.mtw.Synchronize(func() {
 			tray.setTunnelState(tunnel, state)
+			tray.updateTunnelAddresses(tunnel, state)
 		})
 	}()
 }
@@ -212,13 +271,25 @@ func (tray *Tray) addTunnelAction(tunnel *manager.Tunnel) {
 func (tray *Tray) removeTunnelAction(tunnelName string) {
 	if tray.tunnelsAreInBreakoutMenu {
 		if tray.ContextMenu().Actions().Len() > trayTunnelActionsOffset {
-			tray.ContextMenu().Actions().At(trayTunnelActionsOffset).Menu().Actions().Remove(tray.tunnels[tunnelName])
+			breakoutActions := tray.ContextMenu().Actions().At(trayTunnelActionsOffset).Menu().Actions()
+			breakoutActions.Remove(tray.tunnels[tunnelName])
+			breakoutActions.Remove(tray.statsActions[tunnelName])
 		}
 	} else {
 		tray.ContextMenu().Actions().Remove(tray.tunnels[tunnelName])
+		tray.ContextMenu().Actions().Remove(tray.statsActions[tunnelName])
 	}
 	delete(tray.tunnels, tunnelName)
+	delete(tray.statsActions, tunnelName)
+	if _, ok := tray.stats[tunnelName]; ok {
+		delete(tray.stats, tunnelName)
+		tray.updateToolTip()
+	}
 	tray.rebalanceTunnelsMenu()
+	if _, ok := tray.addresses[tunnelName]; ok {
+		delete(tray.addresses, tunnelName)
+		tray.showAddresses()
+	}
 }
 
 func (tray *Tray) rebalanceTunnelsMenu() {
@@ -229,7 +300,8 @@ func (tray *Tray) rebalanceTunnelsMenu() {
 			idx := 1
 			for _, name := range tray.sortedTunnels() {
 				actions.Insert(trayTunnelActionsOffset+idx, tray.tunnels[name])
-				idx++
+				actions.Insert(trayTunnelActionsOffset+idx+1, tray.statsActions[name])
+				idx += 2
 			}
 			actions.Remove(menuAction)
 			if menuAction.Menu() != nil { // Schütze vor nil-Panik
@@ -243,9 +315,10 @@ func (tray *Tray) rebalanceTunnelsMenu() {
 			return
 		}
 		for _, name := range tray.sortedTunnels() {
-			action := tray.tunnels[name]
-			menu.Actions().Add(action)
-			actions.Remove(action)
+			for _, action := range [...]*walk.Action{tray.tunnels[name], tray.statsActions[name]} {
+				menu.Actions().Add(action)
+				actions.Remove(action)
+			}
 		}
 		menuAction, err := actions.InsertMenu(trayTunnelActionsOffset, menu)
 		if err != nil {
@@ -254,6 +327,24 @@ func (tray *Tray) rebalanceTunnelsMenu() {
 		menuAction.SetText(l18n.Sprintf("&Tunnels"))
 		tray.tunnelsAreInBreakoutMenu = true
 	}
+	tray.applyTunnelFilter()
+}
+
+// applyTunnelFilter hides the tunnels in the breakout menu that do not match the filter of the
+// tunnel list, except for active ones, so that they can always be deactivated from here.
+func (tray *Tray) applyTunnelFilter() {
+	for name, action := range tray.tunnels {
+		action.SetVisible(!tray.tunnelsAreInBreakoutMenu || action.Checked() || currentTunnelFilter.Matches(name))
+		tray.updateStatsAction(name)
+	}
+	actions := tray.ContextMenu().Actions()
+	if tray.tunnelsAreInBreakoutMenu && actions.Len() > trayTunnelActionsOffset {
+		if currentTunnelFilter.IsEmpty() {
+			actions.At(trayTunnelActionsOffset).SetText(l18n.Sprintf("&Tunnels"))
+		} else {
+			actions.At(trayTunnelActionsOffset).SetText(l18n.Sprintf("&Tunnels matching ‘%s’", currentTunnelFilter.Text()))
+		}
+	}
 }
 
 func (tray *Tray) onTunnelChange(tunnel *manager.Tunnel, state, globalState manager.TunnelState, err error) {
@@ -266,23 +357,28 @@ func (tray *Tray) onTunnelChange(tunnel *manager.Tunnel, state, globalState mana
 				switch state {
 				case manager.TunnelStarted:
 					if !wasChecked {
-						if icon, err := iconWithOverlayForState(state, 128); err == nil {
-							tray.ShowCustom(l18n.Sprintf("WireGuard Activated"), l18n.Sprintf("The %s tunnel has been activated.", tunnel.Name), icon)
-						}
+						tray.notifyTunnelStarted(tunnel.Name, func() {
+							if icon, err := iconWithOverlayForState(state, 128); err == nil {
+								tray.ShowCustom(l18n.Sprintf("WireGuard Activated"), l18n.Sprintf("The %s tunnel has been activated.", tunnel.Name), icon)
+							}
+						})
 					}
 
 				case manager.TunnelStopped:
 					if wasChecked {
-						if icon, err := loadSystemIcon("imageres", -31, 128); err == nil { // TODO: this icon isn't sehr gut...
-							tray.ShowCustom(l18n.Sprintf("WireGuard Deactivated"), l18n.Sprintf("The %s tunnel has been deactivated.", tunnel.Name), icon)
-						}
+						tray.notifyTunnelStopped(tunnel.Name, func() {
+							if icon, err := loadSystemIcon("imageres", -31, 128); err == nil { // TODO: this icon isn't sehr gut...
+								tray.ShowCustom(l18n.Sprintf("WireGuard Deactivated"), l18n.Sprintf("The %s tunnel has been deactivated.", tunnel.Name), icon)
+							}
+						})
 					}
 				}
 			}
 		} else if !tray.mtw.Visible() {
-			tray.ShowError(l18n.Sprintf("WireGuard Tunnel Error"), err.Error())
+			tray.notifyTunnelError(tunnel.Name, err)
 		}
 		tray.setTunnelState(tunnel, state)
+		tray.updateTunnelAddresses(tunnel, state)
 	})
 }
 
@@ -298,41 +394,59 @@ func (tray *Tray) updateGlobalState(globalState manager.TunnelState) {
 	}
 	statusAction := actions.At(0)
 
-	tray.SetToolTip(l18n.Sprintf("WireGuard: %s", textForState(globalState, true)))
+	tray.globalState = globalState
+	tray.updateToolTip()
 	stateText := textForState(globalState, false)
 	if stateIcon, err := iconForState(globalState, 16); err == nil {
 		statusAction.SetImage(stateIcon)
 	}
 	statusAction.SetText(l18n.Sprintf("Status: %s", stateText))
 
+	for _, action := range tray.tunnels {
+		action.SetEnabled(globalState == manager.TunnelStarted || globalState == manager.TunnelStopped)
+	}
+}
+
+// updateTunnelAddresses refreshes the addresses of only the tunnel whose state has changed.
+func (tray *Tray) updateTunnelAddresses(tunnel *manager.Tunnel, state manager.TunnelState) {
+	if state != manager.TunnelStarted {
+		if _, ok := tray.addresses[tunnel.Name]; ok {
+			delete(tray.addresses, tunnel.Name)
+			tray.showAddresses()
+		}
+		return
+	}
 	go func() {
-		var addrs []string
-		if tunnels, err := manager.IPCClientTunnels(); err == nil {
-			addrs = make([]string, 0, len(tunnels)*2)
-			for i := range tunnels {
-				if state, err := tunnels[i].State(); err == nil && state == manager.TunnelStarted {
-					if config, err := tunnels[i].RuntimeConfig(); err == nil {
-						for _, addr := range config.Interface.Addresses {
-							addrs = append(addrs, addr.String())
-						}
-					}
-				}
-			}
+		config, err := tunnel.RuntimeConfig()
+		if err != nil {
+			return
+		}
+		addrs := make([]string, 0, len(config.Interface.Addresses))
+		for _, addr := range config.Interface.Addresses {
+			addrs = append(addrs, addr.String())
 		}
 		tray.mtw.Synchronize(func() {
-			actions := tray.ContextMenu().Actions()
-			if actions.Len() < 2 {
-				return
+			if action := tray.tunnels[tunnel.Name]; action == nil || !action.Checked() {
+				return // Deactivated or removed in the meantime.
 			}
-			activeCIDRsAction := actions.At(1)
-			activeCIDRsAction.SetText(l18n.Sprintf("Addresses: %s", strings.Join(addrs, l18n.EnumerationSeparator())))
-			activeCIDRsAction.SetVisible(len(addrs) > 0)
+			tray.addresses[tunnel.Name] = addrs
+			tray.showAddresses()
 		})
 	}()
+}
 
-	for _, action := range tray.tunnels {
-		action.SetEnabled(globalState == manager.TunnelStarted || globalState == manager.TunnelStopped)
+func (tray *Tray) showAddresses() {
+	actions := tray.ContextMenu().Actions()
+	if actions.Len() < 2 {
+		return
+	}
+	var addrs []string
+	for _, name := range tray.sortedTunnels() {
+		addrs = append(addrs, tray.addresses[name]...)
 	}
+	activeCIDRsAction := actions.At(1)
+	activeCIDRsAction.SetText(l18n.Sprintf("Addresses: %s", strings.Join(addrs, l18n.EnumerationSeparator())))
+	activeCIDRsAction.SetVisible(len(addrs) > 0)
 }
 
 func (tray *Tray) setTunnelState(tunnel *manager.Tunnel, state manager.TunnelState) {
@@ -345,9 +459,72 @@ func (tray *Tray) setTunnelState(tunnel *manager.Tunnel, state manager.TunnelSta
 	case manager.TunnelStarted:
 		tunnelAction.SetEnabled(true)
 		tunnelAction.SetChecked(true)
+		tray.watchStats()
 	case manager.TunnelStopped:
 		tunnelAction.SetChecked(false)
+		tray.forgetStats(tunnel.Name)
+	}
+}
+
+// refreshProfiles lists the profiles of each tunnel that has any, with the one picked by hand,
+// or automatic selection, checked.
+func (tray *Tray) refreshProfiles() {
+	actions := tray.profilesMenu.Actions()
+	actions.Clear()
+	for _, name := range tray.sortedTunnels() {
+		tunnel := manager.Tunnel{Name: name}
+		profiles, err := tunnel.Profiles()
+		if err != nil || profiles.IsEmpty() {
+			continue
+		}
+		if actions.Len() > 0 {
+			actions.Add(walk.NewSeparatorAction())
+		}
+		header := walk.NewAction()
+		header.SetText(name)
+		header.SetEnabled(false)
+		actions.Add(header)
+		choices := []string{""}
+		for _, profile := range profiles.Profiles {
+			choices = append(choices, profile.Name)
+		}
+		for _, choice := range choices {
+			choice := choice
+			action := walk.NewAction()
+			if len(choice) == 0 {
+				if active := profiles.Find(profiles.Active); active != nil {
+					action.SetText(l18n.Sprintf("Automatic (%s)", active.Name))
+				} else {
+					action.SetText(l18n.Sprintf("Automatic"))
+				}
+			} else {
+				action.SetText(choice)
+			}
+			action.SetCheckable(true)
+			action.SetExclusive(true)
+			action.SetChecked(choice == profiles.Manual)
+			action.Triggered().Attach(func() {
+				if err := tunnel.SelectProfile(choice); err != nil {
+					tray.ShowError(l18n.Sprintf("WireGuard Tunnel Error"), err.Error())
+				}
+			})
+			actions.Add(action)
+		}
 	}
+	tray.profilesAction.SetVisible(actions.Len() > 0)
+}
+
+// refreshGroups lists the tunnel groups, each with actions to activate and deactivate it.
+func (tray *Tray) refreshGroups() {
+	groups, err := manager.IPCClientTunnelGroups()
+	if err != nil {
+		tray.groupsAction.SetVisible(false)
+		return
+	}
+	fillTunnelGroupsMenu(tray.groupsMenu.Actions(), &groups, func(err error) {
+		tray.ShowError(l18n.Sprintf("WireGuard Tunnel Error"), err.Error())
+	})
+	tray.groupsAction.SetVisible(tray.groupsMenu.Actions().Len() > 0)
 }
 
 func (tray *Tray) UpdateFound() {
@@ -357,24 +534,23 @@ func (tray *Tray) UpdateFound() {
 		action.SetImage(menuIcon)
 	}
 	action.SetDefault(true)
-	showUpdateTab := func() {
-		if !tray.mtw.Visible() {
-			tray.mtw.tunnelsPage.listView.SelectFirstActiveTunnel()
-		}
-		tray.mtw.tabs.SetCurrentIndex(2)
-		raise(tray.mtw.Handle())
-	}
-	action.Triggered().Attach(showUpdateTab)
-	tray.clicked = showUpdateTab
+	action.Triggered().Attach(tray.showUpdateTab)
+	tray.clicked = tray.showUpdateTab
 	actions := tray.ContextMenu().Actions()
 	if actions.Len() >= 2 {
 		actions.Insert(actions.Len()-2, action)
 	}
 
+	message := l18n.Sprintf("An update to WireGuard is now available. You are advised to update as soon as possible.")
+	if channel, _, err := manager.IPCClientUpdateChannel(); err == nil && channel != conf.UpdateChannelStable {
+		message = l18n.Sprintf("An update to WireGuard is now available on the %s channel. You are advised to update as soon as possible.", channel)
+	}
 	showUpdateBalloon := func() {
-		if icon, err := loadShieldIcon(128); err == nil {
-			tray.ShowCustom(l18n.Sprintf("WireGuard Update Available"), l18n.Sprintf("An update to WireGuard is now available. You are advised to update as soon as possible."), icon)
-		}
+		tray.notifyUpdate(message, func() {
+			if icon, err := loadShieldIcon(128); err == nil {
+				tray.ShowCustom(l18n.Sprintf("WireGuard Update Available"), message, icon)
+			}
+		})
 	}
 
 	delta := time.Since(startTime)
@@ -387,6 +563,44 @@ func (tray *Tray) UpdateFound() {
 	}
 }
 
+func (tray *Tray) showUpdateTab() {
+	if !tray.mtw.Visible() {
+		tray.mtw.tunnelsPage.listView.SelectFirstActiveTunnel()
+	}
+	tray.mtw.showUpdatePage()
+	raise(tray.mtw.Handle())
+}
+
+// onLeftClick toggles the favorite tunnel, if there is one and clicks are to toggle it, in which
+// case a double click does what a click otherwise does. Telling them apart takes waiting for the
+// double click time before toggling.
+func (tray *Tray) onLeftClick() {
+	if tray.pendingClick != nil {
+		tray.pendingClick.Stop()
+		tray.pendingClick = nil
+		tray.clicked()
+		return
+	}
+	favorite := loadFavoriteTunnel()
+	if len(favorite) == 0 || tray.tunnels[favorite] == nil || !trayClickTogglesFavorite() {
+		tray.clicked()
+		return
+	}
+	var pending *time.Timer
+	pending = time.AfterFunc(time.Duration(getDoubleClickTime())*time.Millisecond, func() {
+		tray.mtw.Synchronize(func() {
+			if tray.pendingClick != pending {
+				return
+			}
+			tray.pendingClick = nil
+			toggleFavoriteTunnel(tray.mtw, func(title, message string) {
+				tray.ShowError(title, message)
+			})
+		})
+	})
+	tray.pendingClick = pending
+}
+
 func (tray *Tray) onManageTunnels() {
 	tray.mtw.tunnelsPage.listView.SelectFirstActiveTunnel()
 	tray.mtw.tabs.SetCurrentIndex(0)
@@ -401,7 +615,33 @@ func (tray *Tray) onAbout() {
 	}
 }
 
+// onShowQRCode shows the code of the first active tunnel, or otherwise of the selected one.
+func (tray *Tray) onShowQRCode() {
+	tunnel := tray.mtw.tunnelsPage.listView.CurrentTunnel()
+	for _, name := range tray.sortedTunnels() {
+		if tray.tunnels[name].Checked() {
+			tunnel = &manager.Tunnel{Name: name}
+			break
+		}
+	}
+	if tunnel == nil {
+		tray.onManageTunnels()
+		return
+	}
+	if tray.mtw.Visible() {
+		onShowQRCode(tray.mtw, tunnel)
+	} else {
+		onShowQRCode(nil, tunnel)
+	}
+}
+
 func (tray *Tray) onImport() {
 	raise(tray.mtw.Handle())
 	tray.mtw.tunnelsPage.onImport()
 }
+
+func (tray *Tray) onImportFromScreen() {
+	raise(tray.mtw.Handle())
+	tray.mtw.tabs.SetCurrentIndex(0)
+	tray.mtw.tunnelsPage.onImportFromScreen()
+}
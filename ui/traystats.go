@@ -0,0 +1,228 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unsafe"
+
+	"github.com/lxn/walk"
+	"github.com/lxn/win"
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/l18n"
+	"golang.zx2c4.com/wireguard/windows/manager"
+)
+
+// The transfer statistics of active tunnels are shown in the tray menu and tool tip, but they are
+// only asked of the manager while someone may be looking at them: while the menu is open, or while
+// the cursor rests on the icon, which is when the tool tip shows. The timer itself is cheap, and
+// only runs while some tunnel is active.
+const trayStatsInterval = 2 * time.Second
+
+// The tool tip of a notification icon holds at most this many UTF-16 code units, with the NUL.
+const trayToolTipLength = 128
+
+type notifyIconIdentifier struct {
+	size uint32
+	hwnd win.HWND
+	id   uint32
+	guid windows.GUID
+}
+
+type guiThreadInfo struct {
+	size      uint32
+	flags     uint32
+	active    win.HWND
+	focus     win.HWND
+	capture   win.HWND
+	menuOwner win.HWND
+	moveSize  win.HWND
+	caret     win.HWND
+	caretRect win.RECT
+}
+
+const guiInMenuMode = 0x4
+
+type trayTunnelStats struct {
+	rx, tx        conf.Bytes
+	lastHandshake conf.HandshakeTime
+}
+
+func (stats *trayTunnelStats) String() string {
+	if stats.lastHandshake.IsEmpty() {
+		return l18n.Sprintf("↓ %s  ↑ %s, no handshake", stats.rx, stats.tx)
+	}
+	return l18n.Sprintf("↓ %s  ↑ %s, handshake %s", stats.rx, stats.tx, stats.lastHandshake)
+}
+
+// statsMayBeSeen returns whether the tray menu is open, or the cursor is over the tray icon. It
+// must be called on the thread of the UI, whose menu modes it looks at.
+func (tray *Tray) statsMayBeSeen() bool {
+	info := guiThreadInfo{size: uint32(unsafe.Sizeof(guiThreadInfo{}))}
+	if getGUIThreadInfo(windows.GetCurrentThreadId(), &info) == nil && info.flags&guiInMenuMode != 0 && info.menuOwner == tray.mtw.Handle() {
+		return true
+	}
+	// walk adds the icon to the window of the tunnel manager, with an identifier of zero.
+	identifier := notifyIconIdentifier{size: uint32(unsafe.Sizeof(notifyIconIdentifier{})), hwnd: tray.mtw.Handle()}
+	var location win.RECT
+	if shellNotifyIconGetRect(&identifier, unsafe.Pointer(&location)) != nil {
+		return false
+	}
+	var cursor win.POINT
+	return win.GetCursorPos(&cursor) && cursor.X >= location.Left && cursor.X < location.Right && cursor.Y >= location.Top && cursor.Y < location.Bottom
+}
+
+func (tray *Tray) activeTunnelNames() []string {
+	var names []string
+	for _, name := range tray.sortedTunnels() {
+		if tray.tunnels[name].Checked() {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// watchStats refreshes the statistics on a timer for as long as any tunnel is active.
+func (tray *Tray) watchStats() {
+	if tray.statsDone != nil || len(tray.activeTunnelNames()) == 0 {
+		return
+	}
+	done := make(chan struct{})
+	tray.statsDone = done
+	go func() {
+		ticker := time.NewTicker(trayStatsInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			tray.mtw.Synchronize(func() {
+				if tray.statsDone != done {
+					return
+				}
+				if len(tray.activeTunnelNames()) == 0 {
+					tray.stopWatchingStats()
+					return
+				}
+				if tray.statsMayBeSeen() {
+					tray.refreshStats()
+				}
+			})
+		}
+	}()
+}
+
+func (tray *Tray) stopWatchingStats() {
+	if tray.statsDone == nil {
+		return
+	}
+	close(tray.statsDone)
+	tray.statsDone = nil
+}
+
+// refreshStats fetches the statistics of the active tunnels from the manager, unless that is
+// already underway.
+func (tray *Tray) refreshStats() {
+	names := tray.activeTunnelNames()
+	if tray.statsFetching || len(names) == 0 {
+		return
+	}
+	tray.statsFetching = true
+	go func() {
+		fetched := make(map[string]*trayTunnelStats, len(names))
+		for _, name := range names {
+			tunnel := manager.Tunnel{Name: name}
+			config, err := tunnel.RuntimeConfig()
+			if err != nil {
+				continue
+			}
+			stats := &trayTunnelStats{}
+			for i := range config.Peers {
+				stats.rx += config.Peers[i].RxBytes
+				stats.tx += config.Peers[i].TxBytes
+				if config.Peers[i].LastHandshakeTime > stats.lastHandshake {
+					stats.lastHandshake = config.Peers[i].LastHandshakeTime
+				}
+			}
+			fetched[name] = stats
+		}
+		tray.mtw.Synchronize(func() {
+			tray.statsFetching = false
+			for name, stats := range fetched {
+				if action := tray.tunnels[name]; action != nil && action.Checked() {
+					tray.stats[name] = stats
+				}
+			}
+			for name := range tray.statsActions {
+				tray.updateStatsAction(name)
+			}
+			tray.updateToolTip()
+		})
+	}()
+}
+
+// forgetStats drops the statistics of a tunnel that is no longer active.
+func (tray *Tray) forgetStats(tunnelName string) {
+	if _, ok := tray.stats[tunnelName]; !ok {
+		return
+	}
+	delete(tray.stats, tunnelName)
+	tray.updateStatsAction(tunnelName)
+	tray.updateToolTip()
+}
+
+// updateStatsAction shows the statistics of a tunnel under its action, if the tunnel is active
+// and shown.
+func (tray *Tray) updateStatsAction(tunnelName string) {
+	action, statsAction := tray.tunnels[tunnelName], tray.statsActions[tunnelName]
+	if action == nil || statsAction == nil {
+		return
+	}
+	stats := tray.stats[tunnelName]
+	if stats == nil || !action.Checked() || !action.Visible() {
+		statsAction.SetVisible(false)
+		return
+	}
+	statsAction.SetText(stats.String())
+	statsAction.SetVisible(true)
+}
+
+func (tray *Tray) updateToolTip() {
+	lines := []string{l18n.Sprintf("WireGuard: %s", textForState(tray.globalState, true))}
+	for _, name := range tray.activeTunnelNames() {
+		if stats := tray.stats[name]; stats != nil {
+			lines = append(lines, l18n.Sprintf("%s: %s", name, stats))
+		}
+	}
+	tray.SetToolTip(truncateToolTip(strings.Join(lines, "\n")))
+}
+
+// truncateToolTip shortens s to what fits in the tool tip of a notification icon, with an
+// ellipsis if anything is cut.
+func truncateToolTip(s string) string {
+	u := utf16.Encode([]rune(s))
+	if len(u) < trayToolTipLength {
+		return s
+	}
+	u = u[:trayToolTipLength-2]
+	if last := u[len(u)-1]; last >= 0xd800 && last < 0xdc00 {
+		u = u[:len(u)-1] // The first half of a surrogate pair.
+	}
+	return string(utf16.Decode(u)) + "…"
+}
+
+func newStatsAction() *walk.Action {
+	action := walk.NewAction()
+	action.SetEnabled(false)
+	action.SetVisible(false)
+	return action
+}
@@ -0,0 +1,118 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"net/url"
+
+	"golang.zx2c4.com/wireguard/windows/l18n"
+	"golang.zx2c4.com/wireguard/windows/manager"
+	"golang.zx2c4.com/wireguard/windows/ui/toast"
+)
+
+// appUserModelID is that of the shortcut in the Start menu, which the installer sets, and
+// under which toasts are shown.
+const appUserModelID = "WireGuard.WireGuard"
+
+const (
+	toastActionDisconnect = "disconnect"
+	toastActionOpen       = "open"
+	toastActionUpdate     = "update"
+)
+
+func toastArguments(action, tunnelName string) string {
+	values := url.Values{"action": {action}}
+	if len(tunnelName) > 0 {
+		values.Set("tunnel", tunnelName)
+	}
+	return values.Encode()
+}
+
+func openToastAction(tunnelName string) toast.Action {
+	return toast.Action{Content: l18n.Sprintf("Open"), Arguments: toastArguments(toastActionOpen, tunnelName)}
+}
+
+// notify shows a toast where the system supports them, and otherwise calls balloon, which shows
+// the legacy balloon of the notification icon instead.
+func (tray *Tray) notify(n *toast.Notification, balloon func()) {
+	if toast.Supported() && toast.Show(appUserModelID, n, tray.onToastActivated) == nil {
+		return
+	}
+	balloon()
+}
+
+func (tray *Tray) notifyTunnelStarted(tunnelName string, balloon func()) {
+	tray.notify(&toast.Notification{
+		Title:   l18n.Sprintf("WireGuard Activated"),
+		Message: l18n.Sprintf("The %s tunnel has been activated.", tunnelName),
+		Launch:  toastArguments(toastActionOpen, tunnelName),
+		Actions: []toast.Action{
+			{Content: l18n.Sprintf("Disconnect"), Arguments: toastArguments(toastActionDisconnect, tunnelName)},
+			openToastAction(tunnelName),
+		},
+	}, balloon)
+}
+
+func (tray *Tray) notifyTunnelStopped(tunnelName string, balloon func()) {
+	tray.notify(&toast.Notification{
+		Title:   l18n.Sprintf("WireGuard Deactivated"),
+		Message: l18n.Sprintf("The %s tunnel has been deactivated.", tunnelName),
+		Launch:  toastArguments(toastActionOpen, tunnelName),
+		Actions: []toast.Action{openToastAction(tunnelName)},
+	}, balloon)
+}
+
+func (tray *Tray) notifyTunnelError(tunnelName string, err error) {
+	title := l18n.Sprintf("WireGuard Tunnel Error")
+	tray.notify(&toast.Notification{
+		Title:   title,
+		Message: err.Error(),
+		Launch:  toastArguments(toastActionOpen, tunnelName),
+		Actions: []toast.Action{openToastAction(tunnelName)},
+	}, func() {
+		tray.ShowError(title, err.Error())
+	})
+}
+
+func (tray *Tray) notifyUpdate(message string, balloon func()) {
+	tray.notify(&toast.Notification{
+		Title:   l18n.Sprintf("WireGuard Update Available"),
+		Message: message,
+		Launch:  toastArguments(toastActionUpdate, ""),
+		Actions: []toast.Action{{Content: l18n.Sprintf("Open"), Arguments: toastArguments(toastActionUpdate, "")}},
+	}, balloon)
+}
+
+// onToastActivated is called by the Windows Runtime, off the thread of the UI, when a toast or
+// one of its buttons is clicked.
+func (tray *Tray) onToastActivated(arguments string) {
+	values, err := url.ParseQuery(arguments)
+	if err != nil {
+		return
+	}
+	tunnelName := values.Get("tunnel")
+	tray.mtw.Synchronize(func() {
+		switch values.Get("action") {
+		case toastActionDisconnect:
+			go func() {
+				tunnel := manager.Tunnel{Name: tunnelName}
+				if err := tunnel.Stop(); err != nil {
+					tray.mtw.Synchronize(func() {
+						tray.ShowError(l18n.Sprintf("WireGuard Tunnel Error"), err.Error())
+					})
+				}
+			}()
+		case toastActionOpen:
+			if len(tunnelName) > 0 {
+				tray.mtw.tunnelsPage.listView.selectTunnel(tunnelName)
+			}
+			tray.mtw.tabs.SetCurrentIndex(0)
+			raise(tray.mtw.Handle())
+		case toastActionUpdate:
+			tray.showUpdateTab()
+		}
+	})
+}
@@ -0,0 +1,77 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"strings"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/manager"
+)
+
+// tunnelFilter is the text typed into the filter box above the tunnel list, which the breakout
+// menu of the tray icon honors too. Configurations are fetched the first time that a tunnel is
+// matched against a filter, and kept until the filter is cleared or the tunnel changes.
+type tunnelFilter struct {
+	text     string
+	configs  map[string]*conf.Config
+	handlers []func()
+}
+
+var currentTunnelFilter tunnelFilter
+
+func (filter *tunnelFilter) IsEmpty() bool {
+	return len(filter.text) == 0
+}
+
+func (filter *tunnelFilter) Text() string {
+	return filter.text
+}
+
+func (filter *tunnelFilter) SetText(text string) {
+	text = strings.TrimSpace(text)
+	if text == filter.text {
+		return
+	}
+	filter.text = text
+	if len(text) == 0 {
+		filter.configs = nil
+	}
+	for _, handler := range filter.handlers {
+		handler()
+	}
+}
+
+// Changed registers a handler to be called when the text of the filter changes.
+func (filter *tunnelFilter) Changed(handler func()) {
+	filter.handlers = append(filter.handlers, handler)
+}
+
+// Forget drops the cached configuration of a tunnel that was changed or removed.
+func (filter *tunnelFilter) Forget(tunnelName string) {
+	delete(filter.configs, tunnelName)
+}
+
+func (filter *tunnelFilter) Matches(tunnelName string) bool {
+	if filter.IsEmpty() {
+		return true
+	}
+	config, ok := filter.configs[tunnelName]
+	if !ok {
+		tunnel := manager.Tunnel{Name: tunnelName}
+		stored, err := tunnel.StoredConfig()
+		if err != nil {
+			config = &conf.Config{Name: tunnelName}
+		} else {
+			config = &stored
+		}
+		if filter.configs == nil {
+			filter.configs = make(map[string]*conf.Config)
+		}
+		filter.configs[tunnelName] = config
+	}
+	return config.MatchesFilter(filter.text)
+}
@@ -0,0 +1,107 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"github.com/lxn/walk"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/l18n"
+)
+
+// runTunnelNameDialog asks for a new name for a tunnel, proposing name. With offerNewKey, it also
+// offers to generate a new private key, for duplicating a tunnel.
+func runTunnelNameDialog(owner walk.Form, title, name string, offerNewKey bool) (newName string, newPrivateKey, ok bool, err error) {
+	var disposables walk.Disposables
+	defer disposables.Treat()
+
+	dlg, err := walk.NewDialog(owner)
+	if err != nil {
+		return
+	}
+	disposables.Add(dlg)
+	dlg.SetTitle(title)
+	layout := walk.NewGridLayout()
+	layout.SetSpacing(6)
+	layout.SetMargins(walk.Margins{HNear: 10, VNear: 10, HFar: 10, VFar: 10})
+	dlg.SetLayout(layout)
+	if icon, err := loadLogoIcon(32); err == nil {
+		dlg.SetIcon(icon)
+	}
+
+	nameLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return
+	}
+	layout.SetRange(nameLabel, walk.Rectangle{X: 0, Y: 0, Width: 1, Height: 1})
+	nameLabel.SetTextAlignment(walk.AlignHFarVCenter)
+	nameLabel.SetText(l18n.Sprintf("&Name:"))
+	nameEdit, err := walk.NewLineEdit(dlg)
+	if err != nil {
+		return
+	}
+	layout.SetRange(nameEdit, walk.Rectangle{X: 1, Y: 0, Width: 1, Height: 1})
+	nameEdit.SetMinMaxSize(walk.Size{Width: 250}, walk.Size{})
+	nameEdit.SetText(name)
+
+	var newKeyCheckBox *walk.CheckBox
+	if offerNewKey {
+		newKeyCheckBox, err = walk.NewCheckBox(dlg)
+		if err != nil {
+			return
+		}
+		layout.SetRange(newKeyCheckBox, walk.Rectangle{X: 1, Y: 1, Width: 1, Height: 1})
+		newKeyCheckBox.SetText(l18n.Sprintf("&Generate a new private key"))
+		newKeyCheckBox.SetChecked(true)
+	}
+
+	buttonsContainer, err := walk.NewComposite(dlg)
+	if err != nil {
+		return
+	}
+	layout.SetRange(buttonsContainer, walk.Rectangle{X: 0, Y: 2, Width: 2, Height: 1})
+	hbl := walk.NewHBoxLayout()
+	hbl.SetMargins(walk.Margins{})
+	buttonsContainer.SetLayout(hbl)
+	walk.NewHSpacer(buttonsContainer)
+	okButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return
+	}
+	okButton.SetText(l18n.Sprintf("OK"))
+	cancelButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return
+	}
+	cancelButton.SetText(l18n.Sprintf("Cancel"))
+	cancelButton.Clicked().Attach(dlg.Cancel)
+	dlg.SetDefaultButton(okButton)
+	dlg.SetCancelButton(cancelButton)
+
+	okButton.Clicked().Attach(func() {
+		text := nameEdit.Text()
+		if text == "" {
+			showWarningCustom(dlg, l18n.Sprintf("Invalid name"), l18n.Sprintf("A name is required."))
+			return
+		}
+		if !conf.TunnelNameIsValid(text) {
+			showWarningCustom(dlg, l18n.Sprintf("Invalid name"), l18n.Sprintf("Tunnel name ‘%s’ is invalid.", text))
+			return
+		}
+		newName = text
+		newPrivateKey = newKeyCheckBox != nil && newKeyCheckBox.Checked()
+		dlg.Accept()
+	})
+
+	applyTheme(dlg)
+	nameEdit.SetFocus()
+	nameEdit.SetTextSelection(0, -1)
+
+	disposables.Spare()
+
+	ok = dlg.Run() == walk.DlgCmdOK
+	return
+}
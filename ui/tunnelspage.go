@@ -34,6 +34,9 @@ type TunnelsPage struct {
 
 	fillerContainer        *walk.Composite
 	currentTunnelContainer *walk.Composite
+
+	groupsMenu *walk.Menu
+	filterEdit *walk.LineEdit
 }
 
 func NewTunnelsPage() (*TunnelsPage, error) {
@@ -56,6 +59,15 @@ func NewTunnelsPage() (*TunnelsPage, error) {
 	vlayout.SetSpacing(0)
 	tp.listContainer.SetLayout(vlayout)
 
+	if tp.filterEdit, err = walk.NewLineEdit(tp.listContainer); err != nil {
+		return nil, err
+	}
+	tp.filterEdit.SetCueBanner(l18n.Sprintf("Filter by name, endpoint, or address"))
+	tp.filterEdit.Accessibility().SetName(l18n.Sprintf("Filter tunnels"))
+	tp.filterEdit.TextChanged().Attach(func() {
+		currentTunnelFilter.SetText(tp.filterEdit.Text())
+	})
+
 	if tp.listView, err = NewListView(tp.listContainer); err != nil {
 		return nil, err
 	}
@@ -97,13 +109,31 @@ func NewTunnelsPage() (*TunnelsPage, error) {
 
 	walk.NewHSpacer(controlsContainer)
 
+	showQRCode, err := walk.NewPushButton(controlsContainer)
+	if err != nil {
+		return nil, err
+	}
+	showQRCode.SetEnabled(false)
+	tp.listView.CurrentIndexChanged().Attach(func() {
+		showQRCode.SetEnabled(tp.listView.CurrentIndex() > -1)
+	})
+	showQRCode.SetText(l18n.Sprintf("&QR code"))
+	showQRCode.Clicked().Attach(tp.onShowQRCode)
+	showQRCode.SetVisible(IsAdmin)
+
 	editTunnel, err := walk.NewPushButton(controlsContainer)
 	if err != nil {
 		return nil, err
 	}
 	editTunnel.SetEnabled(false)
 	tp.listView.CurrentIndexChanged().Attach(func() {
-		editTunnel.SetEnabled(tp.listView.CurrentIndex() > -1)
+		managed := conf.LoadPolicies().DisableConfigEditing
+		editTunnel.SetEnabled(tp.listView.CurrentIndex() > -1 && !managed)
+		if managed {
+			editTunnel.SetToolTipText(l18n.Sprintf("Managed by your organization"))
+		} else {
+			editTunnel.SetToolTipText("")
+		}
 	})
 	editTunnel.SetText(l18n.Sprintf("&Edit"))
 	editTunnel.Clicked().Attach(tp.onEditTunnel)
@@ -115,7 +145,7 @@ func NewTunnelsPage() (*TunnelsPage, error) {
 	tp.listView.SelectedIndexesChanged().Attach(tp.onSelectedTunnelsChanged)
 	tp.listView.ItemActivated().Attach(tp.onTunnelsViewItemActivated)
 	tp.listView.CurrentIndexChanged().Attach(tp.updateConfView)
-	tp.listView.Load(false)
+	tp.listView.SetFilter(&currentTunnelFilter)
 	tp.onTunnelsChanged()
 
 	return tp, nil
@@ -155,6 +185,18 @@ func (tp *TunnelsPage) CreateToolbar() error {
 	importAction.SetDefault(true)
 	importAction.Triggered().Attach(tp.onImport)
 	addMenu.Actions().Add(importAction)
+	importScreenAction := walk.NewAction()
+	importScreenAction.SetText(l18n.Sprintf("Import tunnel from QR code on &screen…"))
+	importScreenAction.Triggered().Attach(tp.onImportFromScreen)
+	addMenu.Actions().Add(importScreenAction)
+	importURLAction := walk.NewAction()
+	importURLAction.SetText(l18n.Sprintf("Import tunnel(s) from &URL…"))
+	importURLAction.Triggered().Attach(tp.onImportFromURL)
+	addMenu.Actions().Add(importURLAction)
+	wizardAction := walk.NewAction()
+	wizardAction.SetText(l18n.Sprintf("Set up a new tunnel &step by step…"))
+	wizardAction.Triggered().Attach(tp.onAddTunnelWithWizard)
+	addMenu.Actions().Add(wizardAction)
 	addAction := walk.NewAction()
 	addAction.SetText(l18n.Sprintf("Add &empty tunnel…"))
 	addActionIcon, _ := loadSystemIcon("imageres", -2, 16)
@@ -190,6 +232,9 @@ func (tp *TunnelsPage) CreateToolbar() error {
 
 	fixContainerWidthToToolbarWidth := func() {
 		toolbarWidth := tp.listToolbar.SizeHint().Width
+		if listWidth := tp.listView.PreferredWidth(); listWidth > toolbarWidth {
+			toolbarWidth = listWidth
+		}
 		tp.listContainer.SetMinMaxSizePixels(walk.Size{toolbarWidth, 0}, walk.Size{toolbarWidth, 0})
 	}
 	fixContainerWidthToToolbarWidth()
@@ -213,6 +258,16 @@ func (tp *TunnelsPage) CreateToolbar() error {
 	importAction2.SetVisible(IsAdmin)
 	contextMenu.Actions().Add(importAction2)
 	tp.ShortcutActions().Add(importAction2)
+	importScreenAction2 := walk.NewAction()
+	importScreenAction2.SetText(l18n.Sprintf("Import tunnel from QR code on &screen…"))
+	importScreenAction2.Triggered().Attach(tp.onImportFromScreen)
+	importScreenAction2.SetVisible(IsAdmin)
+	contextMenu.Actions().Add(importScreenAction2)
+	importURLAction2 := walk.NewAction()
+	importURLAction2.SetText(l18n.Sprintf("Import tunnel(s) from &URL…"))
+	importURLAction2.Triggered().Attach(tp.onImportFromURL)
+	importURLAction2.SetVisible(IsAdmin)
+	contextMenu.Actions().Add(importURLAction2)
 	addAction2 := walk.NewAction()
 	addAction2.SetText(l18n.Sprintf("Add &empty tunnel…"))
 	addAction2.SetShortcut(walk.Shortcut{walk.ModControl, walk.KeyN})
@@ -225,6 +280,11 @@ func (tp *TunnelsPage) CreateToolbar() error {
 	exportAction2.Triggered().Attach(tp.onExportTunnels)
 	exportAction2.SetVisible(IsAdmin)
 	contextMenu.Actions().Add(exportAction2)
+	exportEncryptedAction := walk.NewAction()
+	exportEncryptedAction.SetText(l18n.Sprintf("Export all tunnels to &encrypted zip…"))
+	exportEncryptedAction.Triggered().Attach(tp.onExportEncryptedTunnels)
+	exportEncryptedAction.SetVisible(IsAdmin)
+	contextMenu.Actions().Add(exportEncryptedAction)
 	contextMenu.Actions().Add(walk.NewSeparatorAction())
 	editAction := walk.NewAction()
 	editAction.SetText(l18n.Sprintf("Edit &selected tunnel…"))
@@ -233,6 +293,99 @@ func (tp *TunnelsPage) CreateToolbar() error {
 	editAction.Triggered().Attach(tp.onEditTunnel)
 	contextMenu.Actions().Add(editAction)
 	tp.ShortcutActions().Add(editAction)
+	renameAction := walk.NewAction()
+	renameAction.SetText(l18n.Sprintf("Re&name…"))
+	renameAction.SetShortcut(walk.Shortcut{0, walk.KeyF2})
+	renameAction.SetVisible(IsAdmin)
+	renameAction.Triggered().Attach(tp.onRenameTunnel)
+	contextMenu.Actions().Add(renameAction)
+	tp.listView.ShortcutActions().Add(renameAction)
+	duplicateAction := walk.NewAction()
+	duplicateAction.SetText(l18n.Sprintf("&Duplicate…"))
+	duplicateAction.SetVisible(IsAdmin)
+	duplicateAction.Triggered().Attach(tp.onDuplicateTunnel)
+	contextMenu.Actions().Add(duplicateAction)
+	qrCodeAction := walk.NewAction()
+	qrCodeAction.SetText(l18n.Sprintf("Show &QR code…"))
+	qrCodeAction.SetVisible(IsAdmin)
+	qrCodeAction.Triggered().Attach(tp.onShowQRCode)
+	contextMenu.Actions().Add(qrCodeAction)
+	createClientAction := walk.NewAction()
+	createClientAction.SetText(l18n.Sprintf("Create &client for this hub…"))
+	createClientAction.SetVisible(IsAdmin)
+	createClientAction.Triggered().Attach(tp.onCreateClient)
+	contextMenu.Actions().Add(createClientAction)
+	addressPoolsAction := walk.NewAction()
+	addressPoolsAction.SetText(l18n.Sprintf("Address &pools…"))
+	addressPoolsAction.SetVisible(IsAdmin)
+	addressPoolsAction.Triggered().Attach(tp.onAddressPools)
+	contextMenu.Actions().Add(addressPoolsAction)
+	profilesAction := walk.NewAction()
+	profilesAction.SetText(l18n.Sprintf("P&rofiles…"))
+	profilesAction.SetVisible(IsAdmin)
+	profilesAction.Triggered().Attach(tp.onProfiles)
+	contextMenu.Actions().Add(profilesAction)
+	scheduleAction := walk.NewAction()
+	scheduleAction.SetText(l18n.Sprintf("Sc&hedule…"))
+	scheduleAction.SetVisible(IsAdmin)
+	scheduleAction.Triggered().Attach(tp.onSchedule)
+	contextMenu.Actions().Add(scheduleAction)
+	tp.groupsMenu, err = walk.NewMenu()
+	if err != nil {
+		return err
+	}
+	tp.listView.AddDisposable(tp.groupsMenu)
+	groupsMenuAction := walk.NewMenuAction(tp.groupsMenu)
+	groupsMenuAction.SetText(l18n.Sprintf("Tunnel &groups"))
+	contextMenu.Actions().Add(groupsMenuAction)
+	tp.refreshGroups()
+	protectedAction := walk.NewAction()
+	protectedAction.SetText(l18n.Sprintf("Require &confirmation to activate"))
+	protectedAction.SetCheckable(true)
+	protectedAction.SetVisible(IsAdmin)
+	protectedAction.Triggered().Attach(func() { tp.onSetProtected(protectedAction) })
+	contextMenu.Actions().Add(protectedAction)
+	favoriteAction := walk.NewAction()
+	favoriteAction.SetText(l18n.Sprintf("Fa&vorite"))
+	favoriteAction.SetCheckable(true)
+	favoriteAction.Triggered().Attach(func() { tp.onSetFavorite(favoriteAction) })
+	contextMenu.Actions().Add(favoriteAction)
+	favoriteHotkeyAction := walk.NewAction()
+	favoriteHotkeyAction.SetText(l18n.Sprintf("Favorite hot&key…"))
+	favoriteHotkeyAction.Triggered().Attach(func() { onFavoriteHotkey(tp.Form()) })
+	contextMenu.Actions().Add(favoriteHotkeyAction)
+	peersAction := walk.NewAction()
+	peersAction.SetText(l18n.Sprintf("Show p&eers…"))
+	peersAction.Triggered().Attach(tp.onShowPeers)
+	contextMenu.Actions().Add(peersAction)
+	usageAction := walk.NewAction()
+	usageAction.SetText(l18n.Sprintf("Show &bandwidth usage…"))
+	usageAction.Triggered().Attach(tp.onShowUsage)
+	contextMenu.Actions().Add(usageAction)
+	logLevelMenu, err := walk.NewMenu()
+	if err != nil {
+		return err
+	}
+	tp.listView.AddDisposable(logLevelMenu)
+	var logLevelActions []*walk.Action
+	for level, text := range []string{
+		conf.AdapterLogVerbose: l18n.Sprintf("&Verbose, with handshakes"),
+		conf.AdapterLogErrors:  l18n.Sprintf("&Errors only"),
+		conf.AdapterLogOff:     l18n.Sprintf("&Off"),
+	} {
+		level := conf.AdapterLogLevel(level)
+		logLevelAction := walk.NewAction()
+		logLevelAction.SetText(text)
+		logLevelAction.SetCheckable(true)
+		logLevelAction.SetExclusive(true)
+		logLevelAction.Triggered().Attach(func() { tp.onSetAdapterLogLevel(level) })
+		logLevelMenu.Actions().Add(logLevelAction)
+		logLevelActions = append(logLevelActions, logLevelAction)
+	}
+	logLevelMenuAction := walk.NewMenuAction(logLevelMenu)
+	logLevelMenuAction.SetText(l18n.Sprintf("Driver &logging"))
+	logLevelMenuAction.SetVisible(IsAdmin)
+	contextMenu.Actions().Add(logLevelMenuAction)
 	deleteAction2 := walk.NewAction()
 	deleteAction2.SetText(l18n.Sprintf("&Remove selected tunnel(s)"))
 	deleteAction2.SetShortcut(walk.Shortcut{0, walk.KeyDelete})
@@ -252,11 +405,49 @@ func (tp *TunnelsPage) CreateToolbar() error {
 	setSelectionOrientedOptions := func() {
 		selected := len(tp.listView.SelectedIndexes())
 		all := len(tp.listView.model.tunnels)
-		deleteAction.SetEnabled(selected > 0)
-		deleteAction2.SetEnabled(selected > 0)
+		// Policies are read anew, so that a Group Policy refresh is reflected without a restart.
+		policies := conf.LoadPolicies()
+		for _, action := range []*walk.Action{importAction, importScreenAction, importURLAction, wizardAction, addAction, addMenuAction, importAction2, importScreenAction2, importURLAction2, addAction2} {
+			action.SetEnabled(!policies.DisableConfigEditing)
+		}
+		if policies.DisableConfigEditing {
+			addMenuAction.SetToolTip(l18n.Sprintf("Managed by your organization"))
+		} else {
+			addMenuAction.SetToolTip(importAction.Text())
+		}
+		if policies.DisableTunnelDeletion {
+			deleteAction.SetToolTip(l18n.Sprintf("Managed by your organization"))
+		} else {
+			deleteAction.SetToolTip(l18n.Sprintf("Remove selected tunnel(s)"))
+		}
+		deleteAction.SetEnabled(selected > 0 && !policies.DisableTunnelDeletion)
+		deleteAction2.SetEnabled(selected > 0 && !policies.DisableTunnelDeletion)
 		toggleAction.SetEnabled(selected == 1)
 		selectAllAction.SetEnabled(selected < all)
-		editAction.SetEnabled(selected == 1)
+		editAction.SetEnabled(selected == 1 && !policies.DisableConfigEditing)
+		renameAction.SetEnabled(selected == 1 && !policies.DisableConfigEditing && !policies.DisableTunnelDeletion)
+		duplicateAction.SetEnabled(selected == 1 && !policies.DisableConfigEditing)
+		qrCodeAction.SetEnabled(selected == 1)
+		createClientAction.SetEnabled(selected == 1 && !policies.DisableConfigEditing)
+		addressPoolsAction.SetEnabled(selected == 1)
+		scheduleAction.SetEnabled(selected == 1)
+		peersAction.SetEnabled(selected == 1)
+		usageAction.SetEnabled(selected == 1)
+		logLevelMenuAction.SetEnabled(selected == 1)
+		protectedAction.SetEnabled(selected == 1)
+		favoriteAction.SetEnabled(selected == 1)
+		if tunnel := tp.listView.CurrentTunnel(); selected == 1 && tunnel != nil {
+			favoriteAction.SetChecked(tunnel.Name == loadFavoriteTunnel())
+		}
+		if tunnel := tp.listView.CurrentTunnel(); IsAdmin && selected == 1 && tunnel != nil {
+			protected, err := tunnel.Protected()
+			protectedAction.SetChecked(err == nil && protected)
+			if level, err := tunnel.AdapterLogLevel(); err == nil {
+				for i, logLevelAction := range logLevelActions {
+					logLevelAction.SetChecked(conf.AdapterLogLevel(i) == level)
+				}
+			}
+		}
 	}
 	tp.listView.SelectedIndexesChanged().Attach(setSelectionOrientedOptions)
 	setSelectionOrientedOptions()
@@ -264,6 +455,7 @@ func (tp *TunnelsPage) CreateToolbar() error {
 		all := len(tp.listView.model.tunnels)
 		exportAction.SetEnabled(all > 0)
 		exportAction2.SetEnabled(all > 0)
+		exportEncryptedAction.SetEnabled(all > 0)
 	}
 	setExportRange := func(from, to int) { setExport() }
 	tp.listView.model.RowsInserted().Attach(setExportRange)
@@ -278,32 +470,71 @@ func (tp *TunnelsPage) updateConfView() {
 	tp.confView.SetTunnel(tp.listView.CurrentTunnel())
 }
 
+func readImportedConfig(r io.Reader) ([]byte, error) {
+	textConfig, err := io.ReadAll(io.LimitReader(r, conf.MaxConfigFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(textConfig) > conf.MaxConfigFileSize {
+		return nil, errors.New(l18n.Sprintf("configuration file is too large"))
+	}
+	return textConfig, nil
+}
+
 func (tp *TunnelsPage) importFiles(paths []string) {
 	go func() {
-		syncedMsgBox := func(title, message string, flags walk.MsgBoxStyle) {
-			tp.Synchronize(func() {
-				walk.MsgBox(tp.Form(), title, message, flags)
-			})
-		}
-		type unparsedConfig struct {
-			Name   string
-			Config string
-		}
-
 		var (
-			unparsedConfigs []unparsedConfig
+			unparsedConfigs []ImportedConfig
+			skeletonConfigs []*conf.Config
+			archives        []encryptedArchive
 			lastErr         error
 		)
 
 		for _, path := range paths {
+			if len(unparsedConfigs) >= conf.MaxTunnelsPerImport {
+				lastErr = errors.New(l18n.Sprintf("no more than %d tunnels may be imported at once", conf.MaxTunnelsPerImport))
+				break
+			}
 			switch strings.ToLower(filepath.Ext(path)) {
 			case ".conf":
-				textConfig, err := os.ReadFile(path)
+				file, err := os.Open(path)
+				if err != nil {
+					lastErr = err
+					continue
+				}
+				textConfig, err := readImportedConfig(file)
+				file.Close()
+				if err != nil {
+					lastErr = err
+					continue
+				}
+				unparsedConfigs = append(unparsedConfigs, ImportedConfig{Name: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), Config: string(textConfig)})
+			case ".ovpn":
+				// OpenVPN profiles lack WireGuard keys, so they are completed in the editor rather than imported directly.
+				file, err := os.Open(path)
+				if err != nil {
+					lastErr = err
+					continue
+				}
+				textConfig, err := readImportedConfig(file)
+				file.Close()
+				if err != nil {
+					lastErr = err
+					continue
+				}
+				config, err := conf.FromOpenVPN(string(textConfig), strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
 				if err != nil {
 					lastErr = err
 					continue
 				}
-				unparsedConfigs = append(unparsedConfigs, unparsedConfig{Name: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), Config: string(textConfig)})
+				skeletonConfigs = append(skeletonConfigs, config)
+			case ".png", ".jpg", ".jpeg", ".gif":
+				textConfig, err := readQRCodeImage(path)
+				if err != nil {
+					lastErr = err
+					continue
+				}
+				unparsedConfigs = append(unparsedConfigs, ImportedConfig{Name: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), Config: textConfig})
 			case ".zip":
 				// 1 .conf + 1 error .zip edge case?
 				r, err := zip.OpenReader(path)
@@ -311,86 +542,182 @@ func (tp *TunnelsPage) importFiles(paths []string) {
 					lastErr = err
 					continue
 				}
+				// Encrypted archives are decrypted by the manager, once the passphrase is known.
+				if conf.ArchiveIsEncrypted(&r.Reader) {
+					r.Close()
+					archive, err := readEncryptedArchive(path)
+					if err != nil {
+						lastErr = err
+						continue
+					}
+					archives = append(archives, encryptedArchive{filepath.Base(path), archive})
+					continue
+				}
 
 				for _, f := range r.File {
 					if strings.ToLower(filepath.Ext(f.Name)) != ".conf" {
 						continue
 					}
+					if len(unparsedConfigs) >= conf.MaxTunnelsPerImport {
+						lastErr = errors.New(l18n.Sprintf("no more than %d tunnels may be imported at once", conf.MaxTunnelsPerImport))
+						break
+					}
+					if f.UncompressedSize64 > conf.MaxConfigFileSize {
+						lastErr = errors.New(l18n.Sprintf("configuration file ‘%s’ is too large", f.Name))
+						continue
+					}
 
 					rc, err := f.Open()
 					if err != nil {
 						lastErr = err
 						continue
 					}
-					textConfig, err := io.ReadAll(rc)
+					textConfig, err := readImportedConfig(rc)
 					rc.Close()
 					if err != nil {
 						lastErr = err
 						continue
 					}
-					unparsedConfigs = append(unparsedConfigs, unparsedConfig{Name: strings.TrimSuffix(filepath.Base(f.Name), filepath.Ext(f.Name)), Config: string(textConfig)})
+					unparsedConfigs = append(unparsedConfigs, ImportedConfig{Name: strings.TrimSuffix(filepath.Base(f.Name), filepath.Ext(f.Name)), Config: string(textConfig)})
 				}
 
 				r.Close()
 			}
 		}
 
-		if lastErr != nil || unparsedConfigs == nil {
-			if lastErr == nil {
-				lastErr = errors.New(l18n.Sprintf("no configuration files were found"))
-			}
-			syncedMsgBox(l18n.Sprintf("Error"), l18n.Sprintf("Could not import selected configuration: %v", lastErr), walk.MsgBoxIconWarning)
+		for _, config := range skeletonConfigs {
+			config := config
+			tp.Synchronize(func() {
+				if config, rules := runEditDialog(tp.Form(), nil, config); config != nil {
+					tp.addTunnel(config, rules)
+				}
+			})
+		}
+		for _, archive := range archives {
+			archive := archive
+			tp.Synchronize(func() {
+				tp.importArchive(archive)
+			})
+		}
+		if lastErr == nil && unparsedConfigs == nil && (skeletonConfigs != nil || archives != nil) {
 			return
 		}
 
-		// Add in reverse order so that the first one is selected.
-		sort.Slice(unparsedConfigs, func(i, j int) bool {
-			return conf.TunnelNameIsLess(unparsedConfigs[j].Name, unparsedConfigs[i].Name)
+		tp.importConfigs(unparsedConfigs, lastErr)
+	}()
+}
+
+// importConfigs adds the configurations as tunnels, or reports lastErr, an error in reading them,
+// in a message box. It is called from outside of the UI thread.
+func (tp *TunnelsPage) importConfigs(unparsedConfigs []ImportedConfig, lastErr error) {
+	syncedMsgBox := func(title, message string, flags walk.MsgBoxStyle) {
+		tp.Synchronize(func() {
+			walk.MsgBox(tp.Form(), title, message, flags)
 		})
+	}
 
-		existingTunnelList, err := manager.IPCClientTunnels()
+	if lastErr != nil || unparsedConfigs == nil {
+		if lastErr == nil {
+			lastErr = errors.New(l18n.Sprintf("no configuration files were found"))
+		}
+		syncedMsgBox(l18n.Sprintf("Error"), l18n.Sprintf("Could not import selected configuration: %v", lastErr), walk.MsgBoxIconWarning)
+		return
+	}
+
+	// Add in reverse order so that the first one is selected.
+	sort.Slice(unparsedConfigs, func(i, j int) bool {
+		return conf.TunnelNameIsLess(unparsedConfigs[j].Name, unparsedConfigs[i].Name)
+	})
+
+	existingTunnelList, err := manager.IPCClientTunnels()
+	if err != nil {
+		syncedMsgBox(l18n.Sprintf("Error"), l18n.Sprintf("Could not enumerate existing tunnels: %v", lastErr), walk.MsgBoxIconWarning)
+		return
+	}
+	existingLowerTunnels := make(map[string]bool, len(existingTunnelList))
+	for _, tunnel := range existingTunnelList {
+		existingLowerTunnels[strings.ToLower(tunnel.Name)] = true
+	}
+
+	configCount := 0
+	var warnings []string
+	tp.listView.SetSuspendTunnelsUpdate(true)
+	for _, unparsedConfig := range unparsedConfigs {
+		if existingLowerTunnels[strings.ToLower(unparsedConfig.Name)] {
+			lastErr = errors.New(l18n.Sprintf("Another tunnel already exists with the name ‘%s’", unparsedConfig.Name))
+			continue
+		}
+		config, err := conf.FromWgQuickWithUnknownEncoding(unparsedConfig.Config, unparsedConfig.Name)
 		if err != nil {
-			syncedMsgBox(l18n.Sprintf("Error"), l18n.Sprintf("Could not enumerate existing tunnels: %v", lastErr), walk.MsgBoxIconWarning)
-			return
+			lastErr = err
+			continue
 		}
-		existingLowerTunnels := make(map[string]bool, len(existingTunnelList))
-		for _, tunnel := range existingTunnelList {
-			existingLowerTunnels[strings.ToLower(tunnel.Name)] = true
+		_, err = manager.IPCClientNewTunnel(config)
+		if err != nil {
+			lastErr = err
+			continue
 		}
-
-		configCount := 0
-		tp.listView.SetSuspendTunnelsUpdate(true)
-		for _, unparsedConfig := range unparsedConfigs {
-			if existingLowerTunnels[strings.ToLower(unparsedConfig.Name)] {
-				lastErr = errors.New(l18n.Sprintf("Another tunnel already exists with the name ‘%s’", unparsedConfig.Name))
-				continue
-			}
-			config, err := conf.FromWgQuickWithUnknownEncoding(unparsedConfig.Config, unparsedConfig.Name)
-			if err != nil {
-				lastErr = err
-				continue
-			}
-			_, err = manager.IPCClientNewTunnel(config)
-			if err != nil {
-				lastErr = err
-				continue
-			}
-			configCount++
+		configCount++
+		for _, warning := range config.Lint() {
+			warnings = append(warnings, l18n.Sprintf("%s: %s", config.Name, warning))
 		}
-		tp.listView.SetSuspendTunnelsUpdate(false)
+	}
+	tp.listView.SetSuspendTunnelsUpdate(false)
+
+	m, n := configCount, len(unparsedConfigs)
+	switch {
+	case n == 1 && m != n:
+		syncedMsgBox(l18n.Sprintf("Error"), l18n.Sprintf("Unable to import configuration: %v", lastErr), walk.MsgBoxIconWarning)
+	case n == 1 && m == n:
+		// nothing
+	case m == n:
+		syncedMsgBox(l18n.Sprintf("Imported tunnels"), l18n.Sprintf("Imported %d tunnels", m), walk.MsgBoxIconInformation)
+	case m != n:
+		syncedMsgBox(l18n.Sprintf("Imported tunnels"), l18n.Sprintf("Imported %d of %d tunnels", m, n), walk.MsgBoxIconWarning)
+	}
+	if len(warnings) > 0 {
+		syncedMsgBox(l18n.Sprintf("Configuration warnings"), l18n.Sprintf("The imported tunnels work, but may not do what was meant:\n\n%s", strings.Join(warnings, "\n\n")), walk.MsgBoxIconWarning)
+	}
+}
 
-		m, n := configCount, len(unparsedConfigs)
+type encryptedArchive struct {
+	name string
+	data []byte
+}
+
+func readEncryptedArchive(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > conf.MaxArchiveSize {
+		return nil, errors.New(l18n.Sprintf("archive ‘%s’ is too large", filepath.Base(path)))
+	}
+	return os.ReadFile(path)
+}
+
+// importArchive asks for the passphrase of an encrypted archive, again if it is wrong, and has
+// the manager create its tunnels, renaming those whose names are taken.
+func (tp *TunnelsPage) importArchive(archive encryptedArchive) {
+	for {
+		passphrase, ok, err := runPassphraseDialog(tp.Form(), l18n.Sprintf("Import encrypted zip"), l18n.Sprintf("Enter the passphrase with which ‘%s’ was exported.", archive.name), false)
+		if showError(err, tp.Form()) || !ok {
+			return
+		}
+		created, err := manager.IPCClientImportArchive(archive.data, passphrase)
 		switch {
-		case n == 1 && m != n:
-			syncedMsgBox(l18n.Sprintf("Error"), l18n.Sprintf("Unable to import configuration: %v", lastErr), walk.MsgBoxIconWarning)
-		case n == 1 && m == n:
-			// nothing
-		case m == n:
-			syncedMsgBox(l18n.Sprintf("Imported tunnels"), l18n.Sprintf("Imported %d tunnels", m), walk.MsgBoxIconInformation)
-		case m != n:
-			syncedMsgBox(l18n.Sprintf("Imported tunnels"), l18n.Sprintf("Imported %d of %d tunnels", m, n), walk.MsgBoxIconWarning)
+		case err != nil && strings.HasSuffix(err.Error(), conf.ErrArchivePassphrase.Error()):
+			showErrorCustom(tp.Form(), l18n.Sprintf("Wrong passphrase"), err.Error())
+			continue
+		case err != nil && len(created) == 0:
+			showErrorCustom(tp.Form(), l18n.Sprintf("Unable to import archive"), err.Error())
+		case err != nil:
+			walk.MsgBox(tp.Form(), l18n.Sprintf("Imported tunnels"), l18n.Sprintf("Imported %d tunnels, but not all of them: %v", len(created), err), walk.MsgBoxIconWarning)
+		default:
+			walk.MsgBox(tp.Form(), l18n.Sprintf("Imported tunnels"), l18n.Sprintf("Imported %d tunnels", len(created)), walk.MsgBoxIconInformation)
 		}
-	}()
+		return
+	}
 }
 
 func (tp *TunnelsPage) exportTunnels(filePath string) {
@@ -417,8 +744,11 @@ func (tp *TunnelsPage) exportTunnels(filePath string) {
 	})
 }
 
-func (tp *TunnelsPage) addTunnel(config *conf.Config) {
-	_, err := manager.IPCClientNewTunnel(config)
+func (tp *TunnelsPage) addTunnel(config *conf.Config, rules *conf.ActivationRules) {
+	tunnel, err := manager.IPCClientNewTunnel(config)
+	if err == nil && !rules.IsEmpty() {
+		err = tunnel.SetActivationRules(rules)
+	}
 	if err != nil {
 		showErrorCustom(tp.Form(), l18n.Sprintf("Unable to create tunnel"), err.Error())
 	}
@@ -432,7 +762,7 @@ func (tp *TunnelsPage) onTunnelsViewItemActivated() {
 		if err != nil || (globalState != manager.TunnelStarted && globalState != manager.TunnelStopped) {
 			return
 		}
-		oldState, err := tp.listView.CurrentTunnel().Toggle()
+		oldState, err := toggleTunnel(tp.Form(), tp.listView.CurrentTunnel())
 		if err != nil {
 			tp.Synchronize(func() {
 				if oldState == manager.TunnelUnknown {
@@ -454,23 +784,261 @@ func (tp *TunnelsPage) onEditTunnel() {
 		return
 	}
 
-	if config := runEditDialog(tp.Form(), tunnel); config != nil {
+	if config, rules := runEditDialog(tp.Form(), tunnel, nil); config != nil {
 		go func() {
 			priorState, err := tunnel.State()
-			tunnel.Delete()
+			oldName := tunnel.Name
+			if config.Name != oldName && conf.LoadPolicies().DisableTunnelDeletion {
+				tp.Synchronize(func() {
+					showErrorCustom(tp.Form(), l18n.Sprintf("Unable to rename tunnel"), l18n.Sprintf("Removing tunnels is disabled by your organization, so they cannot be renamed."))
+				})
+				return
+			}
+			// The configuration is overwritten in place, so that everything else that belongs to
+			// the tunnel, such as its usage and its address pools, stays with it.
+			tunnel.Stop()
 			tunnel.WaitForStop()
-			tunnel, err2 := manager.IPCClientNewTunnel(config)
-			if err == nil && err2 == nil && (priorState == manager.TunnelStarting || priorState == manager.TunnelStarted) {
-				tunnel.Start()
+			err2 := tunnel.ReplaceConfig(config)
+			if err2 != nil {
+				tp.Synchronize(func() {
+					showErrorCustom(tp.Form(), l18n.Sprintf("Unable to create new configuration"), err2.Error())
+				})
+				return
+			}
+			tunnel := manager.Tunnel{Name: config.Name}
+			tunnel.SetActivationRules(rules)
+			if config.Name != oldName {
+				tp.listView.Load(true)
+				tp.Synchronize(func() {
+					if loadFavoriteTunnel() == oldName {
+						saveFavoriteTunnel(config.Name)
+					}
+					tp.listView.selectTunnel(config.Name)
+				})
+			}
+			if err == nil && (priorState == manager.TunnelStarting || priorState == manager.TunnelStarted) {
+				startTunnel(tp.Form(), &tunnel)
 			}
 		}()
 	}
 }
 
+// onRenameTunnel renames the selected tunnel, which the manager does without deleting it, so that
+// everything that belongs to it stays with it, and it keeps running if it is.
+func (tp *TunnelsPage) onRenameTunnel() {
+	current := tp.listView.CurrentTunnel()
+	if current == nil {
+		return
+	}
+	tunnel := *current
+	oldName := tunnel.Name
+	newName, _, ok, err := runTunnelNameDialog(tp.Form(), l18n.Sprintf("Rename tunnel"), oldName, false)
+	if err != nil || !ok || newName == oldName {
+		return
+	}
+	go func() {
+		err := tunnel.Rename(newName)
+		if err == nil {
+			tp.listView.Load(true)
+		}
+		tp.Synchronize(func() {
+			if err != nil {
+				showErrorCustom(tp.Form(), l18n.Sprintf("Unable to rename tunnel"), err.Error())
+				return
+			}
+			if loadFavoriteTunnel() == oldName {
+				saveFavoriteTunnel(newName)
+			}
+			tp.listView.selectTunnel(newName)
+		})
+	}()
+}
+
+// onDuplicateTunnel copies the configuration of the selected tunnel to a new one.
+func (tp *TunnelsPage) onDuplicateTunnel() {
+	tunnel := tp.listView.CurrentTunnel()
+	if tunnel == nil {
+		return
+	}
+	newName, newPrivateKey, ok, err := runTunnelNameDialog(tp.Form(), l18n.Sprintf("Duplicate tunnel"), tp.unusedTunnelName(tunnel.Name), true)
+	if err != nil || !ok {
+		return
+	}
+	go func() {
+		duplicate, err := tunnel.Duplicate(newName, newPrivateKey)
+		if err == nil {
+			tp.listView.Load(true)
+		}
+		tp.Synchronize(func() {
+			if err != nil {
+				showErrorCustom(tp.Form(), l18n.Sprintf("Unable to duplicate tunnel"), err.Error())
+				return
+			}
+			tp.listView.selectTunnel(duplicate.Name)
+		})
+	}()
+}
+
+// unusedTunnelName proposes a name for a copy of the named tunnel that no other tunnel has.
+func (tp *TunnelsPage) unusedTunnelName(name string) string {
+	taken := make(map[string]bool, len(tp.listView.model.tunnels))
+	for _, tunnel := range tp.listView.model.tunnels {
+		taken[strings.ToLower(tunnel.Name)] = true
+	}
+	for i := 2; i < 100; i++ {
+		candidate := fmt.Sprintf("%s-%d", name, i)
+		if !taken[strings.ToLower(candidate)] && conf.TunnelNameIsValid(candidate) {
+			return candidate
+		}
+	}
+	return name
+}
+
+func (tp *TunnelsPage) onShowQRCode() {
+	tunnel := tp.listView.CurrentTunnel()
+	if tunnel == nil {
+		return
+	}
+	onShowQRCode(tp.Form(), tunnel)
+}
+
+func (tp *TunnelsPage) onCreateClient() {
+	tunnel := tp.listView.CurrentTunnel()
+	if tunnel == nil {
+		return
+	}
+	onCreateClient(tp.Form(), tunnel)
+}
+
+func (tp *TunnelsPage) onAddressPools() {
+	tunnel := tp.listView.CurrentTunnel()
+	if tunnel == nil {
+		return
+	}
+	onAddressPools(tp.Form(), tunnel)
+}
+
+func (tp *TunnelsPage) onProfiles() {
+	tunnel := tp.listView.CurrentTunnel()
+	if tunnel == nil {
+		return
+	}
+	onProfiles(tp.Form(), tunnel)
+}
+
+func (tp *TunnelsPage) onSchedule() {
+	tunnel := tp.listView.CurrentTunnel()
+	if tunnel == nil {
+		return
+	}
+	onSchedule(tp.Form(), tunnel)
+}
+
+// refreshGroups lists the tunnel groups in the context menu, for activating them, followed by
+// an action for administrators to edit them.
+func (tp *TunnelsPage) refreshGroups() {
+	groups, err := manager.IPCClientTunnelGroups()
+	if err != nil {
+		groups = conf.TunnelGroups{}
+	}
+	fillTunnelGroupsMenu(tp.groupsMenu.Actions(), &groups, func(err error) {
+		showErrorCustom(tp.Form(), l18n.Sprintf("Unable to change group"), err.Error())
+	})
+	if !IsAdmin {
+		return
+	}
+	if tp.groupsMenu.Actions().Len() > 0 {
+		tp.groupsMenu.Actions().Add(walk.NewSeparatorAction())
+	}
+	editAction := walk.NewAction()
+	editAction.SetText(l18n.Sprintf("&Edit groups…"))
+	editAction.Triggered().Attach(tp.onEditGroups)
+	tp.groupsMenu.Actions().Add(editAction)
+}
+
+func (tp *TunnelsPage) onEditGroups() {
+	saved, err := runTunnelGroupsDialog(tp.Form())
+	if err != nil {
+		showError(err, tp.Form())
+		return
+	}
+	if saved {
+		tp.refreshGroups()
+	}
+}
+
+func (tp *TunnelsPage) onShowPeers() {
+	tunnel := tp.listView.CurrentTunnel()
+	if tunnel == nil {
+		return
+	}
+	onShowPeers(tp.Form(), tunnel)
+}
+
+func (tp *TunnelsPage) onShowUsage() {
+	tunnel := tp.listView.CurrentTunnel()
+	if tunnel == nil {
+		return
+	}
+	onShowUsage(tp.Form(), tunnel)
+}
+
+func (tp *TunnelsPage) onSetAdapterLogLevel(level conf.AdapterLogLevel) {
+	tunnel := tp.listView.CurrentTunnel()
+	if tunnel == nil {
+		return
+	}
+	err := tunnel.SetAdapterLogLevel(level)
+	if err != nil {
+		showErrorCustom(tp.Form(), l18n.Sprintf("Unable to change driver logging"), err.Error())
+	}
+}
+
+// onSetProtected applies the checked state of the action, which walk has already toggled.
+func (tp *TunnelsPage) onSetProtected(action *walk.Action) {
+	tunnel := tp.listView.CurrentTunnel()
+	if tunnel == nil {
+		return
+	}
+	err := tunnel.SetProtected(action.Checked())
+	if err != nil {
+		action.SetChecked(!action.Checked())
+		showErrorCustom(tp.Form(), l18n.Sprintf("Unable to change protection"), err.Error())
+	}
+}
+
+// onSetFavorite makes the selected tunnel the favorite, replacing any other, or clears it.
+func (tp *TunnelsPage) onSetFavorite(action *walk.Action) {
+	tunnel := tp.listView.CurrentTunnel()
+	if tunnel == nil {
+		return
+	}
+	var name string
+	if action.Checked() {
+		name = tunnel.Name
+	}
+	err := saveFavoriteTunnel(name)
+	if err != nil {
+		action.SetChecked(!action.Checked())
+		showErrorCustom(tp.Form(), l18n.Sprintf("Unable to change favorite"), err.Error())
+		return
+	}
+	if err := applyFavoriteHotkey(); err != nil {
+		showWarningCustom(tp.Form(), l18n.Sprintf("Favorite hotkey"), err.Error())
+	}
+}
+
 func (tp *TunnelsPage) onAddTunnel() {
-	if config := runEditDialog(tp.Form(), nil); config != nil {
+	if config, rules := runEditDialog(tp.Form(), nil, nil); config != nil {
 		// Save new
-		tp.addTunnel(config)
+		tp.addTunnel(config, rules)
+	}
+}
+
+func (tp *TunnelsPage) onAddTunnelWithWizard() {
+	config, err := runTunnelWizard(tp.Form())
+	if !showError(err, tp.Form()) && config != nil {
+		tp.addTunnel(config, nil)
 	}
 }
 
@@ -545,9 +1113,45 @@ func (tp *TunnelsPage) onSelectAll() {
 	tp.listView.SetSelectedIndexes([]int{-1})
 }
 
+// onDropFiles imports files dropped onto the window as if they had been chosen in the file dialog.
+// The files of a dropped folder are imported too, but not those of its subfolders.
+func (tp *TunnelsPage) onDropFiles(paths []string) {
+	if !IsAdmin {
+		showErrorCustom(tp.Form(), l18n.Sprintf("Unable to import tunnels"), l18n.Sprintf("Only administrators can import tunnels."))
+		return
+	}
+	if conf.LoadPolicies().DisableConfigEditing {
+		showErrorCustom(tp.Form(), l18n.Sprintf("Unable to import tunnels"), l18n.Sprintf("Managed by your organization"))
+		return
+	}
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	if len(files) > 0 {
+		tp.importFiles(files)
+	}
+}
+
 func (tp *TunnelsPage) onImport() {
 	dlg := walk.FileDialog{
-		Filter: l18n.Sprintf("Configuration Files (*.zip, *.conf)|*.zip;*.conf|All Files (*.*)|*.*"),
+		Filter: l18n.Sprintf("Configuration Files (*.zip, *.conf)|*.zip;*.conf|OpenVPN Profiles (*.ovpn)|*.ovpn|QR Code Images (*.png, *.jpg, *.gif)|*.png;*.jpg;*.jpeg;*.gif|All Files (*.*)|*.*"),
 		Title:  l18n.Sprintf("Import tunnel(s) from file"),
 	}
 
@@ -558,6 +1162,20 @@ func (tp *TunnelsPage) onImport() {
 	tp.importFiles(dlg.FilePaths)
 }
 
+func (tp *TunnelsPage) onImportFromURL() {
+	rawURL, digest, ok, err := runURLImportDialog(tp.Form())
+	if showError(err, tp.Form()) || !ok {
+		return
+	}
+	go func() {
+		configs, err := FetchConfigs(rawURL, digest)
+		if err == nil && len(configs) > conf.MaxTunnelsPerImport {
+			configs, err = nil, errors.New(l18n.Sprintf("no more than %d tunnels may be imported at once", conf.MaxTunnelsPerImport))
+		}
+		tp.importConfigs(configs, err)
+	}()
+}
+
 func (tp *TunnelsPage) onExportTunnels() {
 	dlg := walk.FileDialog{
 		Filter: l18n.Sprintf("Configuration ZIP Files (*.zip)|*.zip"),
@@ -575,6 +1193,37 @@ func (tp *TunnelsPage) onExportTunnels() {
 	tp.exportTunnels(dlg.FilePath)
 }
 
+func (tp *TunnelsPage) onExportEncryptedTunnels() {
+	passphrase, ok, err := runPassphraseDialog(tp.Form(), l18n.Sprintf("Export encrypted zip"), l18n.Sprintf("The private keys of the tunnels are only as safe as this passphrase, which is needed to import them again."), true)
+	if showError(err, tp.Form()) || !ok {
+		return
+	}
+
+	dlg := walk.FileDialog{
+		Filter: l18n.Sprintf("Configuration ZIP Files (*.zip)|*.zip"),
+		Title:  l18n.Sprintf("Export tunnels to encrypted zip"),
+	}
+
+	if ok, _ := dlg.ShowSave(tp.Form()); !ok {
+		return
+	}
+
+	if !strings.HasSuffix(dlg.FilePath, ".zip") {
+		dlg.FilePath += ".zip"
+	}
+
+	archive, err := manager.IPCClientExportArchive(passphrase)
+	if showError(err, tp.Form()) {
+		return
+	}
+	writeFileWithOverwriteHandling(tp.Form(), dlg.FilePath, func(file *os.File) error {
+		if _, err := file.Write(archive); err != nil {
+			return fmt.Errorf("onExportEncryptedTunnels: file.Write failed: %w", err)
+		}
+		return nil
+	})
+}
+
 func (tp *TunnelsPage) swapFiller(enabled bool) bool {
 	if tp.fillerContainer.Visible() == enabled {
 		return enabled
@@ -587,9 +1236,14 @@ func (tp *TunnelsPage) swapFiller(enabled bool) bool {
 }
 
 func (tp *TunnelsPage) onTunnelsChanged() {
-	if tp.swapFiller(tp.listView.model.RowCount() == 0) {
+	// Deleted tunnels are removed from their groups.
+	if tp.groupsMenu != nil {
+		tp.refreshGroups()
+	}
+	if tp.swapFiller(tp.listView.model.RowCount() == 0 && !tp.listView.IsFiltered()) {
 		tp.fillerButton.SetText(l18n.Sprintf("Import tunnel(s) from file"))
 		tp.fillerHandler = tp.onImport
+		tp.setFillerManaged(conf.LoadPolicies().DisableConfigEditing)
 	}
 }
 
@@ -602,5 +1256,16 @@ func (tp *TunnelsPage) onSelectedTunnelsChanged() {
 	if tp.swapFiller(tunnelCount > 1) {
 		tp.fillerButton.SetText(l18n.Sprintf("Delete %d tunnels", tunnelCount))
 		tp.fillerHandler = tp.onDelete
+		tp.setFillerManaged(conf.LoadPolicies().DisableTunnelDeletion)
+	}
+}
+
+// setFillerManaged grays out the filler button when a policy forbids what it does.
+func (tp *TunnelsPage) setFillerManaged(managed bool) {
+	tp.fillerButton.SetEnabled(!managed)
+	if managed {
+		tp.fillerButton.SetToolTipText(l18n.Sprintf("Managed by your organization"))
+	} else {
+		tp.fillerButton.SetToolTipText("")
 	}
 }
@@ -15,14 +15,17 @@ import (
 	"github.com/lxn/win"
 	"golang.org/x/sys/windows"
 
+	"golang.zx2c4.com/wireguard/windows/crashreport"
 	"golang.zx2c4.com/wireguard/windows/l18n"
 	"golang.zx2c4.com/wireguard/windows/manager"
+	"golang.zx2c4.com/wireguard/windows/ui/toast"
 	"golang.zx2c4.com/wireguard/windows/version"
 )
 
 var (
 	noTrayAvailable              = false
 	shouldQuitManagerWhenExiting = false
+	rebuildUIRequested           = false
 	startTime                    = time.Now()
 	IsAdmin                      = false // A global, because this really is global for the process
 )
@@ -32,34 +35,21 @@ func RunUI() {
 	windows.SetProcessPriorityBoost(windows.CurrentProcess(), false)
 	defer func() {
 		if err := recover(); err != nil {
-			showErrorCustom(nil, "Panic", fmt.Sprint(err, "\n\n", string(debug.Stack())))
+			stack := debug.Stack()
+			crashreport.ReportForUser("GUI", err, stack)
+			showErrorCustom(nil, "Panic", fmt.Sprint(err, "\n\n", string(stack)))
 			panic(err)
 		}
 	}()
 
+	toast.SetAppID(appUserModelID)
+
 	var (
-		err  error
 		mtw  *ManageTunnelsWindow
 		tray *Tray
 	)
 
-	for mtw == nil {
-		mtw, err = NewManageTunnelsWindow()
-		if err != nil {
-			time.Sleep(time.Millisecond * 400)
-		}
-	}
-
-	for tray == nil {
-		tray, err = NewTray(mtw)
-		if err != nil {
-			if version.OsIsCore() {
-				noTrayAvailable = true
-				break
-			}
-			time.Sleep(time.Millisecond * 400)
-		}
-	}
+	mtw, tray = newWindows()
 
 	manager.IPCClientRegisterManagerStopping(func() {
 		mtw.Synchronize(func() {
@@ -84,22 +74,35 @@ func RunUI() {
 		})
 	}
 	manager.IPCClientRegisterUpdateFound(onUpdateNotification)
-	go func() {
+	checkUpdateState := func() {
 		updateState, err := manager.IPCClientUpdateState()
 		if err == nil {
 			onUpdateNotification(updateState)
 		}
-	}()
+	}
+	go checkUpdateState()
 
 	if tray == nil {
 		win.ShowWindow(mtw.Handle(), win.SW_MINIMIZE)
+	} else if openWindowAtSignIn() {
+		mtw.Show()
 	}
 
-	mtw.Run()
-	if tray != nil {
-		tray.Dispose()
+	for {
+		mtw.Run()
+		if tray != nil {
+			tray.Dispose()
+		}
+		mtw.Dispose()
+		if !rebuildUIRequested {
+			break
+		}
+		rebuildUIRequested = false
+		mtw, tray = newWindows()
+		mtw.tabs.SetCurrentIndex(mtw.tabs.Pages().Index(mtw.settingsPage.TabPage))
+		mtw.Show()
+		go checkUpdateState()
 	}
-	mtw.Dispose()
 
 	if shouldQuitManagerWhenExiting {
 		_, err := manager.IPCClientQuit(true)
@@ -109,6 +112,37 @@ func RunUI() {
 	}
 }
 
+// newWindows makes the manage tunnels window and the tray icon, retrying until they can be made,
+// except that there is no tray icon on Server Core.
+func newWindows() (mtw *ManageTunnelsWindow, tray *Tray) {
+	var err error
+	for mtw == nil {
+		mtw, err = NewManageTunnelsWindow()
+		if err != nil {
+			time.Sleep(time.Millisecond * 400)
+		}
+	}
+
+	for tray == nil && !noTrayAvailable {
+		tray, err = NewTray(mtw)
+		if err != nil {
+			if version.OsIsCore() {
+				noTrayAvailable = true
+				break
+			}
+			time.Sleep(time.Millisecond * 400)
+		}
+	}
+	return
+}
+
+// rebuildUI makes the windows anew, with the settings page showing, which is how a change of
+// language reaches all text at once.
+func rebuildUI() {
+	rebuildUIRequested = true
+	walk.App().Exit(0)
+}
+
 func onQuit() {
 	shouldQuitManagerWhenExiting = true
 	walk.App().Exit(0)
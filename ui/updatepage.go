@@ -106,7 +106,7 @@ func NewUpdatePage() (*UpdatePage, error) {
 		}
 	})
 
-	manager.IPCClientRegisterUpdateProgress(func(dp updater.DownloadProgress) {
+	progressCB := manager.IPCClientRegisterUpdateProgress(func(dp updater.DownloadProgress) {
 		up.Synchronize(func() {
 			switchToUpdatingState()
 			if dp.Error != nil {
@@ -135,6 +135,8 @@ func NewUpdatePage() (*UpdatePage, error) {
 			}
 		})
 	})
+	// The page goes away with the window when the UI is made anew.
+	up.Disposing().Attach(progressCB.Unregister)
 
 	disposables.Spare()
 
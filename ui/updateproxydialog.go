@@ -0,0 +1,174 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/lxn/walk"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/l18n"
+	"golang.zx2c4.com/wireguard/windows/manager"
+)
+
+func onUpdateProxy(owner walk.Form) {
+	showError(runUpdateProxyDialog(owner), owner)
+}
+
+func parseUpdateProxyServer(server string) (host string, port uint16, err error) {
+	if len(server) == 0 {
+		return "", 0, nil
+	}
+	host, portStr, err := net.SplitHostPort(server)
+	if err != nil {
+		return "", 0, errors.New(l18n.Sprintf("The proxy server must be given as host:port."))
+	}
+	port64, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil || port64 == 0 || len(host) == 0 {
+		return "", 0, errors.New(l18n.Sprintf("The proxy server must be given as host:port."))
+	}
+	return host, uint16(port64), nil
+}
+
+// runUpdateProxyDialog edits the proxy that the updater uses. With an empty server, the system's
+// WinHTTP proxy settings stay in effect, and only the credentials are given to that proxy.
+func runUpdateProxyDialog(owner walk.Form) error {
+	proxy, byPolicy, err := manager.IPCClientUpdateProxy()
+	if err != nil {
+		return err
+	}
+
+	var disposables walk.Disposables
+	defer disposables.Treat()
+
+	dlg, err := walk.NewDialog(owner)
+	if err != nil {
+		return err
+	}
+	disposables.Add(dlg)
+	dlg.SetTitle(l18n.Sprintf("Update proxy"))
+	layout := walk.NewGridLayout()
+	layout.SetSpacing(6)
+	layout.SetMargins(walk.Margins{HNear: 10, VNear: 10, HFar: 10, VFar: 10})
+	dlg.SetLayout(layout)
+	if icon, err := loadLogoIcon(32); err == nil {
+		dlg.SetIcon(icon)
+	}
+
+	serverLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return err
+	}
+	layout.SetRange(serverLabel, walk.Rectangle{X: 0, Y: 0, Width: 1, Height: 1})
+	serverLabel.SetTextAlignment(walk.AlignHFarVCenter)
+	serverLabel.SetText(l18n.Sprintf("&Server:"))
+	serverEdit, err := walk.NewLineEdit(dlg)
+	if err != nil {
+		return err
+	}
+	layout.SetRange(serverEdit, walk.Rectangle{X: 1, Y: 0, Width: 1, Height: 1})
+	serverEdit.SetCueBanner(l18n.Sprintf("System proxy settings"))
+	serverEdit.SetText(proxy.Server())
+	serverEdit.SetEnabled(!byPolicy)
+	if byPolicy {
+		serverEdit.SetToolTipText(l18n.Sprintf("The proxy server is set by policy."))
+	}
+
+	usernameLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return err
+	}
+	layout.SetRange(usernameLabel, walk.Rectangle{X: 0, Y: 1, Width: 1, Height: 1})
+	usernameLabel.SetTextAlignment(walk.AlignHFarVCenter)
+	usernameLabel.SetText(l18n.Sprintf("&Username:"))
+	usernameEdit, err := walk.NewLineEdit(dlg)
+	if err != nil {
+		return err
+	}
+	layout.SetRange(usernameEdit, walk.Rectangle{X: 1, Y: 1, Width: 1, Height: 1})
+	usernameEdit.SetText(proxy.Username)
+
+	passwordLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return err
+	}
+	layout.SetRange(passwordLabel, walk.Rectangle{X: 0, Y: 2, Width: 1, Height: 1})
+	passwordLabel.SetTextAlignment(walk.AlignHFarVCenter)
+	passwordLabel.SetText(l18n.Sprintf("&Password:"))
+	passwordEdit, err := walk.NewLineEdit(dlg)
+	if err != nil {
+		return err
+	}
+	layout.SetRange(passwordEdit, walk.Rectangle{X: 1, Y: 2, Width: 1, Height: 1})
+	passwordEdit.SetPasswordMode(true)
+	if len(proxy.Username) > 0 {
+		passwordEdit.SetCueBanner(l18n.Sprintf("Unchanged"))
+	}
+
+	hintLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return err
+	}
+	layout.SetRange(hintLabel, walk.Rectangle{X: 0, Y: 3, Width: 2, Height: 1})
+	hintLabel.SetMinMaxSize(walk.Size{Width: 350}, walk.Size{Width: 350})
+	hintLabel.SetText(l18n.Sprintf("Without a server, updates are downloaded through the system’s WinHTTP proxy settings, which are given the credentials if they ask for them."))
+
+	buttonsContainer, err := walk.NewComposite(dlg)
+	if err != nil {
+		return err
+	}
+	layout.SetRange(buttonsContainer, walk.Rectangle{X: 0, Y: 4, Width: 2, Height: 1})
+	hbl := walk.NewHBoxLayout()
+	hbl.SetMargins(walk.Margins{})
+	buttonsContainer.SetLayout(hbl)
+	walk.NewHSpacer(buttonsContainer)
+	saveButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return err
+	}
+	saveButton.SetText(l18n.Sprintf("&Save"))
+	cancelButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return err
+	}
+	cancelButton.SetText(l18n.Sprintf("Cancel"))
+	cancelButton.Clicked().Attach(dlg.Cancel)
+	dlg.SetDefaultButton(saveButton)
+	dlg.SetCancelButton(cancelButton)
+
+	saveButton.Clicked().Attach(func() {
+		newProxy := conf.UpdateProxy{
+			Username: strings.TrimSpace(usernameEdit.Text()),
+			Password: passwordEdit.Text(),
+		}
+		if !byPolicy {
+			var err error
+			newProxy.Host, newProxy.Port, err = parseUpdateProxyServer(strings.TrimSpace(serverEdit.Text()))
+			if err != nil {
+				showErrorCustom(dlg, l18n.Sprintf("Invalid proxy"), err.Error())
+				return
+			}
+		}
+		err := manager.IPCClientSetUpdateProxy(newProxy)
+		if err != nil {
+			showErrorCustom(dlg, l18n.Sprintf("Unable to save proxy"), err.Error())
+			return
+		}
+		dlg.Accept()
+	})
+
+	applyTheme(dlg)
+
+	disposables.Spare()
+
+	dlg.Run()
+
+	return nil
+}
@@ -0,0 +1,157 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+// Configurations are fetched by the UI or the command line, in the session of the user who asked
+// for them, and then imported like files, so the manager service never makes requests of its own
+// on their behalf.
+
+const (
+	urlImportTimeout = 30 * time.Second
+	maxURLImportSize = 4 * conf.MaxConfigFileSize
+
+	// A zip can inflate to far more than its download, so the files in it are capped in total too.
+	maxURLImportUncompressedSize = 4 * conf.MaxConfigFileSize
+)
+
+// ImportedConfig is the text of a fetched configuration, named after its file.
+type ImportedConfig struct {
+	Name   string
+	Config string
+}
+
+// FetchConfigs downloads a .conf file, or a zip of them, over HTTPS, verifying the certificate of
+// the server against the system's roots. If expectedSHA256 is not empty, it is the hex digest
+// that the download must have.
+func FetchConfigs(rawURL, expectedSHA256 string) ([]ImportedConfig, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" {
+		return nil, errors.New("Configurations can only be imported from https URLs")
+	}
+	var expectedDigest []byte
+	if len(expectedSHA256) > 0 {
+		expectedDigest, err = hex.DecodeString(strings.TrimSpace(expectedSHA256))
+		if err != nil || len(expectedDigest) != sha256.Size {
+			return nil, errors.New("SHA256 digest is not valid")
+		}
+	}
+	client := http.Client{
+		Timeout: urlImportTimeout,
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if request.URL.Scheme != "https" {
+				return errors.New("Redirects away from https are not followed")
+			}
+			if len(via) >= 10 {
+				return errors.New("Too many redirects")
+			}
+			return nil
+		},
+	}
+	response, err := client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Download failed with status %s", response.Status)
+	}
+	if response.ContentLength > maxURLImportSize {
+		return nil, errors.New("Download is too large")
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxURLImportSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxURLImportSize {
+		return nil, errors.New("Download is too large")
+	}
+	if expectedDigest != nil {
+		if digest := sha256.Sum256(body); !bytes.Equal(digest[:], expectedDigest) {
+			return nil, errors.New("Download does not match the SHA256 digest")
+		}
+	}
+
+	// The final redirect names the file, as with downloads in a browser.
+	fileName := path.Base(response.Request.URL.Path)
+	ext := strings.ToLower(path.Ext(fileName))
+	if ext == ".zip" || ext != ".conf" && bytes.HasPrefix(body, []byte("PK\x03\x04")) {
+		return unzipConfigs(body)
+	}
+	if len(body) > conf.MaxConfigFileSize {
+		return nil, errors.New("Configuration file is too large")
+	}
+	name := strings.TrimSuffix(fileName, path.Ext(fileName))
+	if !conf.TunnelNameIsValid(name) {
+		return nil, fmt.Errorf("Tunnel name ‘%s’ is invalid", name)
+	}
+	return []ImportedConfig{{Name: name, Config: string(body)}}, nil
+}
+
+func unzipConfigs(body []byte) ([]ImportedConfig, error) {
+	r, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, err
+	}
+	var configs []ImportedConfig
+	var total uint64
+	for _, f := range r.File {
+		if strings.ToLower(path.Ext(f.Name)) != ".conf" {
+			continue
+		}
+		if len(configs) >= conf.MaxTunnelsPerImport {
+			return nil, fmt.Errorf("Zip has more than %d configuration files", conf.MaxTunnelsPerImport)
+		}
+		if f.UncompressedSize64 > conf.MaxConfigFileSize {
+			return nil, fmt.Errorf("Configuration file ‘%s’ is too large", f.Name)
+		}
+		if total+f.UncompressedSize64 > maxURLImportUncompressedSize {
+			return nil, errors.New("Configuration files in zip are too large in total")
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		// The sizes in the zip may lie, so what is actually read counts.
+		text, err := io.ReadAll(io.LimitReader(rc, conf.MaxConfigFileSize+1))
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(text) > conf.MaxConfigFileSize {
+			return nil, fmt.Errorf("Configuration file ‘%s’ is too large", f.Name)
+		}
+		total += uint64(len(text))
+		if total > maxURLImportUncompressedSize {
+			return nil, errors.New("Configuration files in zip are too large in total")
+		}
+		base := path.Base(f.Name)
+		configs = append(configs, ImportedConfig{Name: strings.TrimSuffix(base, path.Ext(base)), Config: string(text)})
+	}
+	if len(configs) == 0 {
+		return nil, errors.New("No configuration files were found")
+	}
+	return configs, nil
+}
@@ -0,0 +1,114 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/lxn/walk"
+
+	"golang.zx2c4.com/wireguard/windows/l18n"
+)
+
+// runURLImportDialog asks for the https URL of a configuration file, or a zip of them, and
+// optionally the SHA256 digest that it must have. The download is left to the caller, so that
+// the dialog does not sit there while it is under way.
+func runURLImportDialog(owner walk.Form) (rawURL, digest string, ok bool, err error) {
+	var disposables walk.Disposables
+	defer disposables.Treat()
+
+	dlg, err := walk.NewDialog(owner)
+	if err != nil {
+		return
+	}
+	disposables.Add(dlg)
+	dlg.SetTitle(l18n.Sprintf("Import tunnel(s) from URL"))
+	layout := walk.NewGridLayout()
+	layout.SetSpacing(6)
+	layout.SetMargins(walk.Margins{HNear: 10, VNear: 10, HFar: 10, VFar: 10})
+	dlg.SetLayout(layout)
+	if icon, err := loadLogoIcon(32); err == nil {
+		dlg.SetIcon(icon)
+	}
+
+	urlLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return
+	}
+	layout.SetRange(urlLabel, walk.Rectangle{X: 0, Y: 0, Width: 1, Height: 1})
+	urlLabel.SetTextAlignment(walk.AlignHFarVCenter)
+	urlLabel.SetText(l18n.Sprintf("&URL:"))
+	urlEdit, err := walk.NewLineEdit(dlg)
+	if err != nil {
+		return
+	}
+	layout.SetRange(urlEdit, walk.Rectangle{X: 1, Y: 0, Width: 1, Height: 1})
+	urlEdit.SetMinMaxSize(walk.Size{Width: 350}, walk.Size{})
+	urlEdit.SetCueBanner("https://")
+
+	digestLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return
+	}
+	layout.SetRange(digestLabel, walk.Rectangle{X: 0, Y: 1, Width: 1, Height: 1})
+	digestLabel.SetTextAlignment(walk.AlignHFarVCenter)
+	digestLabel.SetText(l18n.Sprintf("&SHA256:"))
+	digestEdit, err := walk.NewLineEdit(dlg)
+	if err != nil {
+		return
+	}
+	layout.SetRange(digestEdit, walk.Rectangle{X: 1, Y: 1, Width: 1, Height: 1})
+	digestEdit.SetCueBanner(l18n.Sprintf("Optional"))
+
+	hintLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return
+	}
+	layout.SetRange(hintLabel, walk.Rectangle{X: 0, Y: 2, Width: 2, Height: 1})
+	hintLabel.SetMinMaxSize(walk.Size{Width: 400}, walk.Size{Width: 400})
+	hintLabel.SetText(l18n.Sprintf("A .conf file or a zip of them is downloaded over HTTPS, from a server with a certificate that this computer trusts. With a SHA256 digest, the download must match it."))
+
+	buttonsContainer, err := walk.NewComposite(dlg)
+	if err != nil {
+		return
+	}
+	layout.SetRange(buttonsContainer, walk.Rectangle{X: 0, Y: 3, Width: 2, Height: 1})
+	hbl := walk.NewHBoxLayout()
+	hbl.SetMargins(walk.Margins{})
+	buttonsContainer.SetLayout(hbl)
+	walk.NewHSpacer(buttonsContainer)
+	importButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return
+	}
+	importButton.SetText(l18n.Sprintf("&Import"))
+	cancelButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return
+	}
+	cancelButton.SetText(l18n.Sprintf("Cancel"))
+	cancelButton.Clicked().Attach(dlg.Cancel)
+	dlg.SetDefaultButton(importButton)
+	dlg.SetCancelButton(cancelButton)
+
+	importButton.Clicked().Attach(func() {
+		u, err := url.Parse(strings.TrimSpace(urlEdit.Text()))
+		if err != nil || u.Scheme != "https" || len(u.Host) == 0 {
+			showErrorCustom(dlg, l18n.Sprintf("Invalid URL"), l18n.Sprintf("Tunnels can only be imported from https URLs."))
+			return
+		}
+		rawURL, digest = u.String(), strings.TrimSpace(digestEdit.Text())
+		dlg.Accept()
+	})
+
+	applyTheme(dlg)
+
+	disposables.Spare()
+
+	ok = dlg.Run() == walk.DlgCmdOK
+	return
+}
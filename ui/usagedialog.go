@@ -0,0 +1,209 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"time"
+
+	"github.com/lxn/walk"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/l18n"
+	"golang.zx2c4.com/wireguard/windows/manager"
+)
+
+// The manager counts usage once a minute while no UI is listening, and saves it every few
+// minutes, so there is no point in asking for it more often than this.
+const usageRefreshInterval = 30 * time.Second
+
+type usageModel struct {
+	walk.TableModelBase
+	periods []conf.UsagePeriod // Newest first.
+}
+
+func (m *usageModel) RowCount() int {
+	return len(m.periods)
+}
+
+func (m *usageModel) Value(row, col int) any {
+	if row < 0 || row >= len(m.periods) {
+		return ""
+	}
+	period := &m.periods[row]
+	switch col {
+	case 0:
+		return period.Start
+	case 1:
+		return period.RxBytes.String()
+	case 2:
+		return period.TxBytes.String()
+	case 3:
+		return (period.RxBytes + period.TxBytes).String()
+	}
+	return ""
+}
+
+type usageDialog struct {
+	*walk.Dialog
+	tunnel    *manager.Tunnel
+	periodCB  *walk.ComboBox
+	usageView *walk.TableView
+	model     *usageModel
+	status    *walk.TextLabel
+	usage     conf.Usage
+}
+
+func onShowUsage(owner walk.Form, tunnel *manager.Tunnel) {
+	showError(runUsageDialog(owner, tunnel), owner)
+}
+
+// runUsageDialog shows how much traffic a tunnel has had during each of its recent days or months,
+// for those on metered connections.
+func runUsageDialog(owner walk.Form, tunnel *manager.Tunnel) error {
+	var disposables walk.Disposables
+	defer disposables.Treat()
+
+	ud := &usageDialog{tunnel: tunnel, model: new(usageModel)}
+	var err error
+	ud.Dialog, err = walk.NewDialog(owner)
+	if err != nil {
+		return err
+	}
+	disposables.Add(ud)
+	ud.SetTitle(l18n.Sprintf("Usage: %s", tunnel.Name))
+	vbl := walk.NewVBoxLayout()
+	vbl.SetMargins(walk.Margins{HNear: 10, VNear: 10, HFar: 10, VFar: 10})
+	ud.SetLayout(vbl)
+	ud.SetMinMaxSize(walk.Size{Width: 500, Height: 400}, walk.Size{})
+	if icon, err := loadLogoIcon(32); err == nil {
+		ud.SetIcon(icon)
+	}
+
+	periodContainer, err := walk.NewComposite(ud)
+	if err != nil {
+		return err
+	}
+	hbl := walk.NewHBoxLayout()
+	hbl.SetMargins(walk.Margins{})
+	periodContainer.SetLayout(hbl)
+	periodLabel, err := walk.NewTextLabel(periodContainer)
+	if err != nil {
+		return err
+	}
+	periodLabel.SetText(l18n.Sprintf("S&how:"))
+	if ud.periodCB, err = walk.NewDropDownBox(periodContainer); err != nil {
+		return err
+	}
+	ud.periodCB.SetModel([]string{
+		l18n.Sprintf("Daily"),
+		l18n.Sprintf("Monthly"),
+	})
+	ud.periodCB.SetCurrentIndex(0)
+	ud.periodCB.CurrentIndexChanged().Attach(ud.updateModel)
+	walk.NewHSpacer(periodContainer)
+
+	if ud.usageView, err = walk.NewTableView(ud); err != nil {
+		return err
+	}
+	ud.usageView.SetAlternatingRowBG(true)
+	ud.usageView.SetLastColumnStretched(true)
+	ud.usageView.SetGridlines(true)
+	ud.usageView.SetCellStyler(ud)
+	for _, column := range []struct {
+		title string
+		width int
+	}{
+		{l18n.Sprintf("Period"), 100},
+		{l18n.Sprintf("Received"), 100},
+		{l18n.Sprintf("Sent"), 100},
+		{l18n.Sprintf("Total"), 100},
+	} {
+		col := walk.NewTableViewColumn()
+		col.SetTitle(column.title)
+		col.SetWidth(column.width)
+		ud.usageView.Columns().Add(col)
+	}
+	ud.usageView.SetModel(ud.model)
+
+	buttonsContainer, err := walk.NewComposite(ud)
+	if err != nil {
+		return err
+	}
+	hbl = walk.NewHBoxLayout()
+	hbl.SetMargins(walk.Margins{})
+	buttonsContainer.SetLayout(hbl)
+	if ud.status, err = walk.NewTextLabel(buttonsContainer); err != nil {
+		return err
+	}
+	walk.NewHSpacer(buttonsContainer)
+	closeButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return err
+	}
+	closeButton.SetText(l18n.Sprintf("Close"))
+	closeButton.Clicked().Attach(ud.Accept)
+	ud.SetDefaultButton(closeButton)
+	ud.SetCancelButton(closeButton)
+
+	applyTheme(ud)
+
+	disposables.Spare()
+
+	done := make(chan struct{})
+	go ud.refresh(done)
+	ud.Run()
+	close(done)
+
+	return nil
+}
+
+func (ud *usageDialog) refresh(done chan struct{}) {
+	ticker := time.NewTicker(usageRefreshInterval)
+	defer ticker.Stop()
+	for {
+		usage, err := ud.tunnel.Usage()
+		select {
+		case <-done:
+			return
+		default:
+		}
+		ud.Synchronize(func() {
+			if err != nil {
+				ud.usage = conf.Usage{}
+				ud.status.SetText(l18n.Sprintf("Unable to load usage: %v", err))
+			} else {
+				ud.usage = usage
+				var month conf.UsagePeriod
+				if len(usage.Months) > 0 && usage.Months[len(usage.Months)-1].Start == time.Now().Format(conf.UsageMonthLayout) {
+					month = usage.Months[len(usage.Months)-1]
+				}
+				ud.status.SetText(l18n.Sprintf("This month: %s", (month.RxBytes + month.TxBytes).String()))
+			}
+			ud.updateModel()
+		})
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (ud *usageDialog) updateModel() {
+	periods := ud.usage.Days
+	if ud.periodCB.CurrentIndex() == 1 {
+		periods = ud.usage.Months
+	}
+	ud.model.periods = make([]conf.UsagePeriod, len(periods))
+	for i := range periods {
+		ud.model.periods[len(periods)-1-i] = periods[i]
+	}
+	ud.model.PublishRowsReset()
+}
+
+func (ud *usageDialog) StyleCell(style *walk.CellStyle) {
+	styleThemedCell(style, true)
+}
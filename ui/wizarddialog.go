@@ -0,0 +1,330 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package ui
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/lxn/walk"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/l18n"
+	"golang.zx2c4.com/wireguard/windows/manager"
+)
+
+// wizardStep is one page of the new tunnel wizard, which takes its answers into the setup and
+// checks them before the next page is shown.
+type wizardStep struct {
+	title   string
+	page    *walk.Composite
+	collect func(setup *conf.GuidedSetup)
+	check   func(setup *conf.GuidedSetup) error
+}
+
+func newWizardPage(parent walk.Container, intro string) (*walk.Composite, *walk.GridLayout, error) {
+	page, err := walk.NewComposite(parent)
+	if err != nil {
+		return nil, nil, err
+	}
+	layout := walk.NewGridLayout()
+	layout.SetSpacing(6)
+	layout.SetMargins(walk.Margins{})
+	layout.SetColumnStretchFactor(1, 3)
+	page.SetLayout(layout)
+	introLabel, err := walk.NewTextLabel(page)
+	if err != nil {
+		return nil, nil, err
+	}
+	introLabel.SetMinMaxSize(walk.Size{Width: 450}, walk.Size{Width: 450})
+	introLabel.SetText(intro)
+	layout.SetRange(introLabel, walk.Rectangle{X: 0, Y: 0, Width: 2, Height: 1})
+	return page, layout, nil
+}
+
+func newWizardLineEdit(page *walk.Composite, row int, label, cueBanner string) (*walk.LineEdit, error) {
+	layout := page.Layout().(*walk.GridLayout)
+	textLabel, err := walk.NewTextLabel(page)
+	if err != nil {
+		return nil, err
+	}
+	textLabel.SetTextAlignment(walk.AlignHFarVCenter)
+	textLabel.SetText(label)
+	layout.SetRange(textLabel, walk.Rectangle{X: 0, Y: row, Width: 1, Height: 1})
+	lineEdit, err := walk.NewLineEdit(page)
+	if err != nil {
+		return nil, err
+	}
+	lineEdit.SetCueBanner(cueBanner)
+	layout.SetRange(lineEdit, walk.Rectangle{X: 1, Y: row, Width: 1, Height: 1})
+	return lineEdit, nil
+}
+
+// runTunnelWizard guides through making a tunnel to a server, one step at a time: the keys and
+// address of this computer, the server, which traffic goes to it, and DNS. It returns the
+// configuration, or nil if the wizard was canceled.
+func runTunnelWizard(owner walk.Form) (*conf.Config, error) {
+	var disposables walk.Disposables
+	defer disposables.Treat()
+
+	privateKey, err := conf.NewPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	setup := conf.GuidedSetup{PrivateKey: *privateKey}
+	if settings, err := manager.IPCClientSettings(); err == nil {
+		setup.KillSwitch = settings.DefaultKillSwitch
+	}
+
+	dlg, err := walk.NewDialog(owner)
+	if err != nil {
+		return nil, err
+	}
+	disposables.Add(dlg)
+	dlg.SetTitle(l18n.Sprintf("Set up a new tunnel"))
+	vbl := walk.NewVBoxLayout()
+	vbl.SetMargins(walk.Margins{HNear: 10, VNear: 10, HFar: 10, VFar: 10})
+	vbl.SetSpacing(10)
+	dlg.SetLayout(vbl)
+	if icon, err := loadSystemIcon("imageres", -114, 32); err == nil {
+		dlg.SetIcon(icon)
+	}
+
+	headingLabel, err := walk.NewTextLabel(dlg)
+	if err != nil {
+		return nil, err
+	}
+	if font, err := walk.NewFont(dlg.Font().Family(), 10, walk.FontBold); err == nil {
+		headingLabel.SetFont(font)
+	}
+
+	// This computer
+	interfacePage, _, err := newWizardPage(dlg, l18n.Sprintf("WireGuard has generated a key pair for this computer. Give the public key below to the administrator of the server, who adds this computer to it and tells you the address it has in the tunnel."))
+	if err != nil {
+		return nil, err
+	}
+	nameEdit, err := newWizardLineEdit(interfacePage, 1, l18n.Sprintf("&Name:"), l18n.Sprintf("office"))
+	if err != nil {
+		return nil, err
+	}
+	pubkeyEdit, err := newWizardLineEdit(interfacePage, 2, l18n.Sprintf("&Public key:"), "")
+	if err != nil {
+		return nil, err
+	}
+	pubkeyEdit.SetReadOnly(true)
+	pubkeyEdit.SetText(privateKey.Public().String())
+	copyButton, err := walk.NewPushButton(interfacePage)
+	if err != nil {
+		return nil, err
+	}
+	copyButton.SetText(l18n.Sprintf("&Copy public key"))
+	copyButton.Clicked().Attach(func() {
+		walk.Clipboard().SetText(pubkeyEdit.Text())
+	})
+	interfacePage.Layout().(*walk.GridLayout).SetRange(copyButton, walk.Rectangle{X: 1, Y: 3, Width: 1, Height: 1})
+	addressesEdit, err := newWizardLineEdit(interfacePage, 4, l18n.Sprintf("&Address:"), "10.0.0.2/32")
+	if err != nil {
+		return nil, err
+	}
+	addressesEdit.SetToolTipText(l18n.Sprintf("The address of this computer in the tunnel, as assigned by the server. Separate several addresses with commas."))
+
+	// Server
+	serverPage, _, err := newWizardPage(dlg, l18n.Sprintf("Enter the public key of the server and where to reach it, as given by its administrator."))
+	if err != nil {
+		return nil, err
+	}
+	serverKeyEdit, err := newWizardLineEdit(serverPage, 1, l18n.Sprintf("Server public &key:"), "")
+	if err != nil {
+		return nil, err
+	}
+	endpointEdit, err := newWizardLineEdit(serverPage, 2, l18n.Sprintf("&Endpoint:"), "vpn.example.com:51820")
+	if err != nil {
+		return nil, err
+	}
+	endpointEdit.SetToolTipText(l18n.Sprintf("The host name or IP address of the server and its port."))
+
+	// Traffic
+	routingPage, routingLayout, err := newWizardPage(dlg, l18n.Sprintf("Choose which traffic goes through the tunnel."))
+	if err != nil {
+		return nil, err
+	}
+	fullTunnelRB, err := walk.NewRadioButton(routingPage)
+	if err != nil {
+		return nil, err
+	}
+	fullTunnelRB.SetText(l18n.Sprintf("&All traffic (full tunnel)"))
+	routingLayout.SetRange(fullTunnelRB, walk.Rectangle{X: 0, Y: 1, Width: 2, Height: 1})
+	killSwitchCB, err := walk.NewCheckBox(routingPage)
+	if err != nil {
+		return nil, err
+	}
+	killSwitchCB.SetText(l18n.Sprintf("&Block untunneled traffic (kill-switch)"))
+	killSwitchCB.SetChecked(setup.KillSwitch)
+	routingLayout.SetRange(killSwitchCB, walk.Rectangle{X: 1, Y: 2, Width: 1, Height: 1})
+	splitTunnelRB, err := walk.NewRadioButton(routingPage)
+	if err != nil {
+		return nil, err
+	}
+	splitTunnelRB.SetText(l18n.Sprintf("&Only traffic to the networks behind the server (split tunnel)"))
+	routingLayout.SetRange(splitTunnelRB, walk.Rectangle{X: 0, Y: 3, Width: 2, Height: 1})
+	networksEdit, err := newWizardLineEdit(routingPage, 4, l18n.Sprintf("&Networks:"), "192.168.1.0/24, 10.0.0.0/24")
+	if err != nil {
+		return nil, err
+	}
+	updateRouting := func() {
+		killSwitchCB.SetEnabled(fullTunnelRB.Checked())
+		networksEdit.SetEnabled(splitTunnelRB.Checked())
+	}
+	fullTunnelRB.SetChecked(true)
+	fullTunnelRB.CheckedChanged().Attach(updateRouting)
+	splitTunnelRB.CheckedChanged().Attach(updateRouting)
+	updateRouting()
+
+	// DNS
+	dnsPage, _, err := newWizardPage(dlg, l18n.Sprintf("Enter the DNS servers to use while the tunnel is active, usually ones in the network of the server. Leave this empty to keep using the DNS servers of the network this computer is in."))
+	if err != nil {
+		return nil, err
+	}
+	dnsEdit, err := newWizardLineEdit(dnsPage, 1, l18n.Sprintf("&DNS servers:"), "10.0.0.1")
+	if err != nil {
+		return nil, err
+	}
+	dnsEdit.SetToolTipText(l18n.Sprintf("Separate several DNS servers with commas. Names rather than addresses are used as search domains."))
+
+	steps := []wizardStep{
+		{
+			title: l18n.Sprintf("This computer"),
+			page:  interfacePage,
+			collect: func(setup *conf.GuidedSetup) {
+				setup.Name = strings.TrimSpace(nameEdit.Text())
+				setup.Addresses = addressesEdit.Text()
+			},
+			check: func(setup *conf.GuidedSetup) error {
+				if err := setup.CheckInterface(); err != nil {
+					return err
+				}
+				existingTunnelList, err := manager.IPCClientTunnels()
+				if err != nil {
+					return err
+				}
+				for _, tunnel := range existingTunnelList {
+					if strings.EqualFold(tunnel.Name, setup.Name) {
+						return errors.New(l18n.Sprintf("Another tunnel already exists with the name ‘%s’.", setup.Name))
+					}
+				}
+				return nil
+			},
+		},
+		{
+			title: l18n.Sprintf("Server"),
+			page:  serverPage,
+			collect: func(setup *conf.GuidedSetup) {
+				setup.ServerPublicKey = serverKeyEdit.Text()
+				setup.ServerEndpoint = endpointEdit.Text()
+			},
+			check: (*conf.GuidedSetup).CheckServer,
+		},
+		{
+			title: l18n.Sprintf("Traffic"),
+			page:  routingPage,
+			collect: func(setup *conf.GuidedSetup) {
+				setup.Routing = conf.FullTunnel
+				if splitTunnelRB.Checked() {
+					setup.Routing = conf.SplitTunnel
+				}
+				setup.SplitNetworks = networksEdit.Text()
+				setup.KillSwitch = killSwitchCB.Checked()
+			},
+			check: (*conf.GuidedSetup).CheckRouting,
+		},
+		{
+			title: l18n.Sprintf("DNS"),
+			page:  dnsPage,
+			collect: func(setup *conf.GuidedSetup) {
+				setup.DNS = dnsEdit.Text()
+			},
+			check: (*conf.GuidedSetup).CheckDNS,
+		},
+	}
+
+	buttonsContainer, err := walk.NewComposite(dlg)
+	if err != nil {
+		return nil, err
+	}
+	hbl := walk.NewHBoxLayout()
+	hbl.SetMargins(walk.Margins{})
+	buttonsContainer.SetLayout(hbl)
+	walk.NewHSpacer(buttonsContainer)
+	backButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return nil, err
+	}
+	backButton.SetText(l18n.Sprintf("< &Back"))
+	nextButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return nil, err
+	}
+	cancelButton, err := walk.NewPushButton(buttonsContainer)
+	if err != nil {
+		return nil, err
+	}
+	cancelButton.SetText(l18n.Sprintf("Cancel"))
+	cancelButton.Clicked().Attach(dlg.Cancel)
+	dlg.SetDefaultButton(nextButton)
+	dlg.SetCancelButton(cancelButton)
+
+	current := 0
+	showStep := func(i int) {
+		current = i
+		for j, step := range steps {
+			step.page.SetVisible(j == i)
+		}
+		headingLabel.SetText(l18n.Sprintf("Step %d of %d: %s", i+1, len(steps), steps[i].title))
+		backButton.SetEnabled(i > 0)
+		if i == len(steps)-1 {
+			nextButton.SetText(l18n.Sprintf("&Finish"))
+		} else {
+			nextButton.SetText(l18n.Sprintf("&Next >"))
+		}
+	}
+	backButton.Clicked().Attach(func() {
+		if current > 0 {
+			showStep(current - 1)
+		}
+	})
+
+	var config *conf.Config
+	nextButton.Clicked().Attach(func() {
+		step := steps[current]
+		step.collect(&setup)
+		if err := step.check(&setup); err != nil {
+			showWarningCustom(dlg, l18n.Sprintf("Invalid configuration"), err.Error())
+			return
+		}
+		if current < len(steps)-1 {
+			showStep(current + 1)
+			return
+		}
+		var err error
+		config, err = setup.Config()
+		if err != nil {
+			showErrorCustom(dlg, l18n.Sprintf("Unable to create new configuration"), err.Error())
+			return
+		}
+		dlg.Accept()
+	})
+	showStep(0)
+
+	applyTheme(dlg)
+	nameEdit.SetFocus()
+
+	disposables.Spare()
+
+	if dlg.Run() != walk.DlgCmdOK {
+		return nil, nil
+	}
+	return config, nil
+}
@@ -15,6 +15,7 @@ import (
 
 	"golang.org/x/crypto/blake2b"
 
+	"golang.zx2c4.com/wireguard/windows/conf"
 	"golang.zx2c4.com/wireguard/windows/elevate"
 	"golang.zx2c4.com/wireguard/windows/updater/winhttp"
 	"golang.zx2c4.com/wireguard/windows/version"
@@ -43,8 +44,15 @@ func (pm *progressHashWatcher) Write(p []byte) (int, error) {
 }
 
 type UpdateFound struct {
-	name string
-	hash [blake2b.Size256]byte
+	name    string
+	hash    [blake2b.Size256]byte
+	channel string
+	path    string
+}
+
+// Channel is the channel that the update was released on.
+func (update *UpdateFound) Channel() string {
+	return update.channel
 }
 
 func CheckForUpdate() (updateFound *UpdateFound, err error) {
@@ -56,7 +64,15 @@ func checkForUpdate(keepSession bool) (*UpdateFound, *winhttp.Session, *winhttp.
 	if !version.IsRunningOfficialVersion() {
 		return nil, nil, nil, errors.New("Build is not official, so updates are disabled")
 	}
-	session, err := winhttp.NewSession(version.UserAgent())
+	server, err := currentUpdateServer()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var proxy *winhttp.Proxy
+	if settings, _ := conf.UpdateProxySettings(); settings != nil {
+		proxy = &winhttp.Proxy{Server: settings.Server(), Username: settings.Username, Password: settings.Password}
+	}
+	session, err := winhttp.NewSessionWithProxy(version.UserAgent(), proxy)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -65,7 +81,7 @@ func checkForUpdate(keepSession bool) (*UpdateFound, *winhttp.Session, *winhttp.
 			session.Close()
 		}
 	}()
-	connection, err := session.Connect(updateServerHost, updateServerPort, updateServerUseHttps)
+	connection, err := session.Connect(server.host, server.port, server.https)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -74,7 +90,7 @@ func checkForUpdate(keepSession bool) (*UpdateFound, *winhttp.Session, *winhttp.
 			connection.Close()
 		}
 	}()
-	response, err := connection.Get(latestVersionPath, true)
+	response, err := connection.Get(server.listPath, true)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -84,14 +100,18 @@ func checkForUpdate(keepSession bool) (*UpdateFound, *winhttp.Session, *winhttp.
 	if err != nil && (err != io.EOF || bytesRead == 0) {
 		return nil, nil, nil, err
 	}
-	files, err := readFileList(fileList[:bytesRead])
+	files, err := readFileList(fileList[:bytesRead], server.publicKey)
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	updateFound, err := findCandidate(files)
+	channel, _ := conf.UpdateChannel()
+	updateFound, err := findCandidate(files, channel)
 	if err != nil {
 		return nil, nil, nil, err
 	}
+	if updateFound != nil {
+		updateFound.path = server.msiDirectory + updateFound.name
+	}
 	if keepSession {
 		return updateFound, session, connection, nil
 	}
@@ -101,6 +121,16 @@ func checkForUpdate(keepSession bool) (*UpdateFound, *winhttp.Session, *winhttp.
 var updateInProgress = uint32(0)
 
 func DownloadVerifyAndExecute(userToken uintptr) (progress chan DownloadProgress) {
+	return downloadVerifyAndExecute(userToken, false)
+}
+
+// DownloadVerifyAndInstallQuietly installs the update as SYSTEM without showing anything, for
+// when there may be no one signed in to see it.
+func DownloadVerifyAndInstallQuietly() (progress chan DownloadProgress) {
+	return downloadVerifyAndExecute(0, true)
+}
+
+func downloadVerifyAndExecute(userToken uintptr, quiet bool) (progress chan DownloadProgress) {
 	progress = make(chan DownloadProgress, 128)
 	progress <- DownloadProgress{Activity: "Initializing"}
 
@@ -124,6 +154,10 @@ func DownloadVerifyAndExecute(userToken uintptr) (progress chan DownloadProgress
 			progress <- DownloadProgress{Error: errors.New("No update was found")}
 			return
 		}
+		if quiet && update.RolledBack() {
+			progress <- DownloadProgress{Error: errors.New("The update was rolled back, so it is not installed again automatically")}
+			return
+		}
 
 		progress <- DownloadProgress{Activity: "Creating temporary file"}
 		file, err := msiTempFile()
@@ -140,7 +174,7 @@ func DownloadVerifyAndExecute(userToken uintptr) (progress chan DownloadProgress
 
 		dp := DownloadProgress{Activity: "Downloading update"}
 		progress <- dp
-		response, err := connection.Get(fmt.Sprintf(msiPath, update.name), false)
+		response, err := connection.Get(update.path, false)
 		if err != nil {
 			progress <- DownloadProgress{Error: err}
 			return
@@ -173,9 +207,16 @@ func DownloadVerifyAndExecute(userToken uintptr) (progress chan DownloadProgress
 			return
 		}
 
+		progress <- DownloadProgress{Activity: "Keeping package for rollback"}
+		err = cachePackage(file.ExclusivePath(), update.name)
+		if err != nil {
+			progress <- DownloadProgress{Activity: fmt.Sprintf("Unable to keep package for rollback: %v", err)}
+		}
+
 		progress <- DownloadProgress{Activity: "Installing update"}
-		err = runMsi(file, userToken)
+		err = runMsi(file, userToken, quiet)
 		if err != nil {
+			uncachePackage(update.name)
 			progress <- DownloadProgress{Error: err}
 			return
 		}
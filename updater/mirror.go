@@ -0,0 +1,88 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package updater
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+)
+
+// updateServer is where the list of releases and the releases themselves are downloaded from,
+// and the signify key that the list is signed with.
+type updateServer struct {
+	host         string
+	port         uint16
+	https        bool
+	listPath     string
+	msiDirectory string
+	publicKey    string
+}
+
+var officialUpdateServer = updateServer{
+	host:         updateServerHost,
+	port:         updateServerPort,
+	https:        updateServerUseHttps,
+	listPath:     latestVersionPath,
+	msiDirectory: strings.TrimSuffix(msiPath, "%s"),
+	publicKey:    releasePublicKeyBase64,
+}
+
+// parseMirrorURL returns the server for a mirror of the official download directory, such as
+// https://mirror.example.com/wireguard/, which has latest.sig and the releases in it.
+func parseMirrorURL(rawURL string) (*updateServer, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || len(u.Hostname()) == 0 || u.User != nil || len(u.RawQuery) > 0 || len(u.Fragment) > 0 {
+		return nil, errors.New("Update URL must be an http or https URL of a directory")
+	}
+	server := &updateServer{
+		host:      u.Hostname(),
+		https:     u.Scheme == "https",
+		publicKey: releasePublicKeyBase64,
+	}
+	if port := u.Port(); len(port) > 0 {
+		p, err := strconv.ParseUint(port, 10, 16)
+		if err != nil || p == 0 {
+			return nil, errors.New("Update URL has an invalid port")
+		}
+		server.port = uint16(p)
+	} else if server.https {
+		server.port = 443
+	} else {
+		server.port = 80
+	}
+	server.msiDirectory = u.EscapedPath()
+	if !strings.HasSuffix(server.msiDirectory, "/") {
+		server.msiDirectory += "/"
+	}
+	server.listPath = server.msiDirectory + "latest.sig"
+	return server, nil
+}
+
+// currentUpdateServer returns the mirror in the UpdateURL policy, if set, or else the official
+// server, with the key in the UpdatePublicKey policy, if set. A policy that does not parse is an
+// error, rather than a reason to fall back to the official server, which a mirror may well be
+// meant to keep the computer away from.
+func currentUpdateServer() (*updateServer, error) {
+	server := officialUpdateServer
+	if values := conf.AdminStrings("UpdateURL"); len(values) > 0 && len(values[0]) > 0 {
+		mirror, err := parseMirrorURL(values[0])
+		if err != nil {
+			return nil, err
+		}
+		server = *mirror
+	}
+	if values := conf.AdminStrings("UpdatePublicKey"); len(values) > 0 && len(values[0]) > 0 {
+		server.publicKey = values[0]
+	}
+	return &server, nil
+}
@@ -47,7 +47,7 @@ func (t *tempFile) Delete() error {
 	return err
 }
 
-func runMsi(msi *tempFile, userToken uintptr) error {
+func runMsi(msi *tempFile, userToken uintptr, quiet bool, properties ...string) error {
 	system32, err := windows.GetSystemDirectory()
 	if err != nil {
 		return err
@@ -66,7 +66,12 @@ func runMsi(msi *tempFile, userToken uintptr) error {
 		Dir:   filepath.Dir(msiPath),
 	}
 	msiexec := filepath.Join(system32, "msiexec.exe")
-	proc, err := os.StartProcess(msiexec, []string{msiexec, "/qb!-", "/i", filepath.Base(msiPath)}, attr)
+	ui := "/qb!-"
+	if quiet {
+		ui = "/qn"
+	}
+	args := append([]string{msiexec, ui, "/i", filepath.Base(msiPath)}, properties...)
+	proc, err := os.StartProcess(msiexec, args, attr)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,229 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
+	"golang.zx2c4.com/wireguard/windows/elevate"
+	"golang.zx2c4.com/wireguard/windows/version"
+)
+
+// The packages that the updater installs are kept in the data directory, so that an update that
+// regresses on some machine can be undone by reinstalling the package of the version before it.
+// Only the package being installed and that of the version it replaces are kept.
+
+func packageCacheDirectory() (string, error) {
+	root, err := conf.RootDirectory(true)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, "Packages")
+	err = os.MkdirAll(dir, os.ModeDir|0o700)
+	if err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// packageVersion returns the version of a package for our architecture from its name.
+func packageVersion(name string) (string, bool) {
+	prefix := fmt.Sprintf(msiArchPrefix, version.Arch())
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, msiSuffix) {
+		return "", false
+	}
+	v := strings.TrimSuffix(strings.TrimPrefix(name, prefix), msiSuffix)
+	if _, err := compareVersions(v, version.Number); err != nil {
+		return "", false
+	}
+	return v, true
+}
+
+// cachePackage keeps a copy of the verified package about to be installed, and drops all others
+// but the package of the running version.
+func cachePackage(msiPath, name string) (err error) {
+	dir, err := packageCacheDirectory()
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(msiPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	path := filepath.Join(dir, name)
+	dst, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		os.Remove(path + ".tmp")
+		return err
+	}
+	running := fmt.Sprintf(msiArchPrefix, version.Arch()) + version.Number + msiSuffix
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		if entry.Name() != name && entry.Name() != running {
+			os.Remove(filepath.Join(dir, entry.Name()))
+		}
+	}
+	return nil
+}
+
+func uncachePackage(name string) {
+	if dir, err := packageCacheDirectory(); err == nil {
+		os.Remove(filepath.Join(dir, name))
+	}
+}
+
+// cachedPackages calls f with the path and version of each cached package for our architecture.
+func cachedPackages(f func(path, version string)) {
+	dir, err := packageCacheDirectory()
+	if err != nil {
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if v, ok := packageVersion(entry.Name()); ok && entry.Type().IsRegular() {
+			f(filepath.Join(dir, entry.Name()), v)
+		}
+	}
+}
+
+// RolledBack reports whether the update was installed before and rolled back from, in which case
+// it is kept in the cache, and should not be installed again without someone asking for it.
+func (update *UpdateFound) RolledBack() bool {
+	rolledBack := false
+	cachedPackages(func(path, version string) {
+		if filepath.Base(path) == update.name {
+			rolledBack = true
+		}
+	})
+	return rolledBack
+}
+
+// rollbackPackage returns the path and version of the newest cached package older than us.
+func rollbackPackage() (string, string) {
+	var bestPath, bestVersion string
+	cachedPackages(func(path, v string) {
+		if c, _ := compareVersions(v, version.Number); c >= 0 {
+			return
+		}
+		if len(bestVersion) > 0 {
+			if c, _ := compareVersions(v, bestVersion); c <= 0 {
+				return
+			}
+		}
+		bestPath, bestVersion = path, v
+	})
+	return bestPath, bestVersion
+}
+
+// RollbackVersion returns the version that Rollback would reinstall, or an empty string if no
+// package of an earlier version is kept.
+func RollbackVersion() string {
+	_, v := rollbackPackage()
+	return v
+}
+
+// Rollback reinstalls the package of the version that was installed before the running one,
+// verifying it as an update is verified. Tunnels are handed over as with an update, and the
+// configuration is kept.
+func Rollback(userToken uintptr) (progress chan DownloadProgress) {
+	progress = make(chan DownloadProgress, 128)
+	progress <- DownloadProgress{Activity: "Initializing"}
+
+	if !atomic.CompareAndSwapUint32(&updateInProgress, 0, 1) {
+		progress <- DownloadProgress{Error: errors.New("An update is already in progress")}
+		return
+	}
+
+	doIt := func() {
+		defer atomic.StoreUint32(&updateInProgress, 0)
+
+		progress <- DownloadProgress{Activity: "Finding package to roll back to"}
+		path, v := rollbackPackage()
+		if len(path) == 0 {
+			progress <- DownloadProgress{Error: errors.New("No earlier version is available to roll back to")}
+			return
+		}
+		cached, err := os.Open(path)
+		if err != nil {
+			progress <- DownloadProgress{Error: err}
+			return
+		}
+		defer cached.Close()
+
+		progress <- DownloadProgress{Activity: "Creating temporary file"}
+		file, err := msiTempFile()
+		if err != nil {
+			progress <- DownloadProgress{Error: err}
+			return
+		}
+		progress <- DownloadProgress{Activity: fmt.Sprintf("Msi destination is %#q", file.Name())}
+		defer func() {
+			if file != nil {
+				file.Delete()
+			}
+		}()
+		_, err = io.Copy(file, cached)
+		if err != nil {
+			progress <- DownloadProgress{Error: err}
+			return
+		}
+
+		progress <- DownloadProgress{Activity: "Verifying authenticode signature"}
+		if !verifyAuthenticode(file.ExclusivePath()) {
+			progress <- DownloadProgress{Error: errors.New("The kept package does not have an authentic authenticode signature")}
+			return
+		}
+
+		progress <- DownloadProgress{Activity: fmt.Sprintf("Rolling back to version %s", v)}
+		// Only packages that allow it can downgrade, and their files must replace the newer ones.
+		err = runMsi(file, userToken, false, "ALLOWDOWNGRADE=1", "REINSTALLMODE=amus")
+		if err != nil {
+			progress <- DownloadProgress{Error: err}
+			return
+		}
+
+		progress <- DownloadProgress{Complete: true}
+	}
+	if userToken == 0 {
+		go func() {
+			err := elevate.DoAsSystem(func() error {
+				doIt()
+				return nil
+			})
+			if err != nil {
+				progress <- DownloadProgress{Error: err}
+			}
+		}()
+	} else {
+		go doIt()
+	}
+
+	return progress
+}
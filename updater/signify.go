@@ -14,6 +14,8 @@ import (
 	"strings"
 
 	"golang.org/x/crypto/blake2b"
+
+	"golang.zx2c4.com/wireguard/windows/conf"
 )
 
 /*
@@ -21,12 +23,19 @@ import (
  *   $ b2sum -l 256 *.msi > list
  *   $ signify -S -e -s release.sec -m list
  *   $ upload ./list.sec
+ *
+ * Pre-releases are tagged by appending two spaces and the channel to their line.
  */
 
-type fileList map[string][blake2b.Size256]byte
+type listedFile struct {
+	hash    [blake2b.Size256]byte
+	channel string
+}
 
-func readFileList(input []byte) (fileList, error) {
-	publicKeyBytes, err := base64.StdEncoding.DecodeString(releasePublicKeyBase64)
+type fileList map[string]listedFile
+
+func readFileList(input []byte, publicKey string) (fileList, error) {
+	publicKeyBytes, err := base64.StdEncoding.DecodeString(publicKey)
 	if err != nil || len(publicKeyBytes) != ed25519.PublicKeySize+10 || publicKeyBytes[0] != 'E' || publicKeyBytes[1] != 'd' {
 		return nil, errors.New("Invalid public key")
 	}
@@ -48,7 +57,7 @@ func readFileList(input []byte) (fileList, error) {
 		return nil, errors.New("Signature is invalid")
 	}
 	fileLines := strings.Split(string(lines[2]), "\n")
-	fileHashes := make(map[string][blake2b.Size256]byte, len(fileLines))
+	fileHashes := make(fileList, len(fileLines))
 	for index, line := range fileLines {
 		if len(line) == 0 && index == len(fileLines)-1 {
 			break
@@ -61,9 +70,12 @@ func readFileList(input []byte) (fileList, error) {
 		if err != nil || len(maybeHash) != blake2b.Size256 {
 			return nil, errors.New("File hash is invalid base64 or incorrect number of bytes")
 		}
-		var hash [blake2b.Size256]byte
-		copy(hash[:], maybeHash)
-		fileHashes[second] = hash
+		file := listedFile{channel: conf.UpdateChannelStable}
+		copy(file.hash[:], maybeHash)
+		if name, channel, ok := strings.Cut(second, "  "); ok {
+			second, file.channel = name, channel
+		}
+		fileHashes[second] = file
 	}
 	if len(fileHashes) == 0 {
 		return nil, errors.New("No file hashes found in signed input")
@@ -11,65 +11,95 @@ import (
 	"strconv"
 	"strings"
 
+	"golang.zx2c4.com/wireguard/windows/conf"
 	"golang.zx2c4.com/wireguard/windows/version"
 )
 
-func versionNewerThanUs(candidate string) (bool, error) {
-	candidateParts := strings.Split(candidate, ".")
-	ourParts := strings.Split(version.Number, ".")
-	if len(candidateParts) == 0 || len(ourParts) == 0 {
-		return false, errors.New("Empty version")
+// compareVersions returns -1, 0, or 1, as a is older than, the same as, or newer than b.
+func compareVersions(a, b string) (int, error) {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	if len(aParts) == 0 || len(bParts) == 0 {
+		return 0, errors.New("Empty version")
 	}
-	l := len(candidateParts)
-	if len(ourParts) > l {
-		l = len(ourParts)
+	l := len(aParts)
+	if len(bParts) > l {
+		l = len(bParts)
 	}
 	for i := 0; i < l; i++ {
 		var err error
-		cP, oP := uint64(0), uint64(0)
-		if i < len(candidateParts) {
-			if len(candidateParts[i]) == 0 {
-				return false, errors.New("Empty version part")
+		aP, bP := uint64(0), uint64(0)
+		if i < len(aParts) {
+			if len(aParts[i]) == 0 {
+				return 0, errors.New("Empty version part")
 			}
-			cP, err = strconv.ParseUint(candidateParts[i], 10, 16)
+			aP, err = strconv.ParseUint(aParts[i], 10, 16)
 			if err != nil {
-				return false, errors.New("Invalid version integer part")
+				return 0, errors.New("Invalid version integer part")
 			}
 		}
-		if i < len(ourParts) {
-			if len(ourParts[i]) == 0 {
-				return false, errors.New("Empty version part")
+		if i < len(bParts) {
+			if len(bParts[i]) == 0 {
+				return 0, errors.New("Empty version part")
 			}
-			oP, err = strconv.ParseUint(ourParts[i], 10, 16)
+			bP, err = strconv.ParseUint(bParts[i], 10, 16)
 			if err != nil {
-				return false, errors.New("Invalid version integer part")
+				return 0, errors.New("Invalid version integer part")
 			}
 		}
-		if cP == oP {
+		if aP == bP {
 			continue
 		}
-		return cP > oP, nil
+		if aP > bP {
+			return 1, nil
+		}
+		return -1, nil
 	}
-	return false, nil
+	return 0, nil
+}
+
+func versionNewerThanUs(candidate string) (bool, error) {
+	comparison, err := compareVersions(candidate, version.Number)
+	return comparison > 0, err
+}
+
+// channelFollows reports whether releases of the given channel are offered on the followed one.
+func channelFollows(followed, channel string) bool {
+	return channel == conf.UpdateChannelStable || channel == followed
 }
 
-func findCandidate(candidates fileList) (*UpdateFound, error) {
+// findCandidate returns the newest release for our architecture on the given channel, if it is
+// newer than us.
+func findCandidate(candidates fileList, channel string) (*UpdateFound, error) {
 	prefix := fmt.Sprintf(msiArchPrefix, version.Arch())
 	suffix := msiSuffix
-	for name, hash := range candidates {
-		if strings.HasPrefix(name, prefix) && strings.HasSuffix(name, suffix) {
-			version := strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix)
-			if len(version) > 128 {
-				return nil, errors.New("Version length is too long")
-			}
-			newer, err := versionNewerThanUs(version)
+	var best *UpdateFound
+	var bestVersion string
+	for name, file := range candidates {
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) || !channelFollows(channel, file.channel) {
+			continue
+		}
+		version := strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix)
+		if len(version) > 128 {
+			return nil, errors.New("Version length is too long")
+		}
+		newer, err := versionNewerThanUs(version)
+		if err != nil {
+			return nil, err
+		}
+		if !newer {
+			continue
+		}
+		if best != nil {
+			comparison, err := compareVersions(version, bestVersion)
 			if err != nil {
 				return nil, err
 			}
-			if newer {
-				return &UpdateFound{name, hash}, nil
+			if comparison <= 0 {
+				continue
 			}
 		}
+		best, bestVersion = &UpdateFound{name: name, hash: file.hash, channel: file.channel}, version
 	}
-	return nil, nil
+	return best, nil
 }
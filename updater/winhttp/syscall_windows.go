@@ -246,6 +246,22 @@ const (
 	_WINHTTP_OPTION_REQUEST_STATS                    = 146
 	_WINHTTP_OPTION_SERVER_CERT_CHAIN_CONTEXT        = 147
 	_WINHTTP_LAST_OPTION                             = _WINHTTP_OPTION_SERVER_CERT_CHAIN_CONTEXT
+	_WINHTTP_OPTION_USERNAME                         = 0x1000
+	_WINHTTP_OPTION_PASSWORD                         = 0x1001
+	_WINHTTP_OPTION_PROXY_USERNAME                   = 0x1002
+	_WINHTTP_OPTION_PROXY_PASSWORD                   = 0x1003
+
+	_WINHTTP_AUTH_TARGET_SERVER = 0
+	_WINHTTP_AUTH_TARGET_PROXY  = 1
+
+	_WINHTTP_AUTH_SCHEME_BASIC     = 0x00000001
+	_WINHTTP_AUTH_SCHEME_NTLM      = 0x00000002
+	_WINHTTP_AUTH_SCHEME_PASSPORT  = 0x00000004
+	_WINHTTP_AUTH_SCHEME_DIGEST    = 0x00000008
+	_WINHTTP_AUTH_SCHEME_NEGOTIATE = 0x00000010
+
+	_HTTP_STATUS_OK             = 200
+	_HTTP_STATUS_PROXY_AUTH_REQ = 407
 
 	_ICU_ESCAPE           = 0x80000000
 	_ICU_ESCAPE_AUTHORITY = 0x00002000
@@ -352,4 +368,6 @@ type _URL_COMPONENTS struct {
 //sys	winHttpQueryDataAvailable(requestHandle _HINTERNET, bytesAvailable *uint32) (err error) = winhttp.WinHttpQueryDataAvailable
 //sys	winHttpReadData(requestHandle _HINTERNET, buffer *byte, bufferSize uint32, bytesRead *uint32) (err error) = winhttp.WinHttpReadData
 //sys	winHttpCrackUrl(url *uint16, urlSize uint32, flags uint32, components *_URL_COMPONENTS) (err error) = winhttp.WinHttpCrackUrl
+//sys	winHttpQueryAuthSchemes(requestHandle _HINTERNET, supportedSchemes *uint32, firstScheme *uint32, authTarget *uint32) (err error) = winhttp.WinHttpQueryAuthSchemes
+//sys	winHttpSetCredentials(requestHandle _HINTERNET, authTargets uint32, authScheme uint32, username *uint16, password *uint16, params uintptr) (err error) = winhttp.WinHttpSetCredentials
 //sys	winHttpSetOption(sessionOrRequestHandle _HINTERNET, option uint32, buffer unsafe.Pointer, bufferLen uint32) (err error) = winhttp.WinHttpSetOption
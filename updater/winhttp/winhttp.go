@@ -20,8 +20,20 @@ import (
 
 type Session struct {
 	handle _HINTERNET
+	proxy  *Proxy
 }
 
+// Proxy is an explicitly configured proxy. An empty Server leaves the system's proxy settings in
+// effect, so that only the credentials are used, if the proxy asks for them.
+type Proxy struct {
+	Server   string // host:port
+	Username string
+	Password string
+}
+
+// StatusError is returned when a request is answered with a status other than 200.
+type StatusError uint32
+
 type Connection struct {
 	handle  _HINTERNET
 	session *Session
@@ -56,7 +68,12 @@ func isWin8DotZeroOrBelow() bool {
 }
 
 func NewSession(userAgent string) (session *Session, err error) {
-	session = new(Session)
+	return NewSessionWithProxy(userAgent, nil)
+}
+
+// NewSessionWithProxy is like NewSession, but connects through proxy, if not nil.
+func NewSessionWithProxy(userAgent string, proxy *Proxy) (session *Session, err error) {
+	session = &Session{proxy: proxy}
 	defer convertError(&err)
 	defer func() {
 		if err != nil {
@@ -72,7 +89,15 @@ func NewSession(userAgent string) (session *Session, err error) {
 	if isWin7() {
 		proxyFlag = _WINHTTP_ACCESS_TYPE_DEFAULT_PROXY
 	}
-	session.handle, err = winHttpOpen(userAgent16, proxyFlag, nil, nil, 0)
+	var proxy16 *uint16
+	if proxy != nil && len(proxy.Server) > 0 {
+		proxyFlag = _WINHTTP_ACCESS_TYPE_NAMED_PROXY
+		proxy16, err = windows.UTF16PtrFromString(proxy.Server)
+		if err != nil {
+			return
+		}
+	}
+	session.handle, err = winHttpOpen(userAgent16, proxyFlag, proxy16, nil, 0)
 	if err != nil {
 		return
 	}
@@ -164,13 +189,33 @@ func (connection *Connection) Get(path string, refresh bool) (response *Response
 	if err != nil {
 		return
 	}
-	err = winHttpSendRequest(response.handle, nil, 0, nil, 0, 0, 0)
-	if err != nil {
-		return
-	}
-	err = winHttpReceiveResponse(response.handle, 0)
-	if err != nil {
-		return
+	for authenticated := false; ; authenticated = true {
+		err = winHttpSendRequest(response.handle, nil, 0, nil, 0, 0, 0)
+		if err != nil {
+			return
+		}
+		err = winHttpReceiveResponse(response.handle, 0)
+		if err != nil {
+			return
+		}
+		var status uint32
+		statusLen := uint32(unsafe.Sizeof(status))
+		err = winHttpQueryHeaders(response.handle, _WINHTTP_QUERY_STATUS_CODE|_WINHTTP_QUERY_FLAG_NUMBER, nil, unsafe.Pointer(&status), &statusLen, nil)
+		if err != nil {
+			return
+		}
+		if status == _HTTP_STATUS_PROXY_AUTH_REQ && !authenticated && connection.session.proxy != nil && len(connection.session.proxy.Username) > 0 {
+			err = response.authenticateProxy(connection.session.proxy)
+			if err != nil {
+				return
+			}
+			continue
+		}
+		if status != _HTTP_STATUS_OK {
+			err = StatusError(status)
+			return
+		}
+		break
 	}
 
 	runtime.SetFinalizer(response, func(response *Response) {
@@ -179,6 +224,35 @@ func (connection *Connection) Get(path string, refresh bool) (response *Response
 	return
 }
 
+// authenticateProxy answers the proxy's challenge with the strongest scheme that it offers, for
+// the request to be sent again.
+func (response *Response) authenticateProxy(proxy *Proxy) error {
+	var supported, first, target uint32
+	err := winHttpQueryAuthSchemes(response.handle, &supported, &first, &target)
+	if err != nil {
+		return err
+	}
+	var scheme uint32
+	for _, s := range []uint32{_WINHTTP_AUTH_SCHEME_NEGOTIATE, _WINHTTP_AUTH_SCHEME_NTLM, _WINHTTP_AUTH_SCHEME_DIGEST, _WINHTTP_AUTH_SCHEME_BASIC} {
+		if supported&s != 0 {
+			scheme = s
+			break
+		}
+	}
+	if scheme == 0 {
+		return StatusError(_HTTP_STATUS_PROXY_AUTH_REQ)
+	}
+	username16, err := windows.UTF16PtrFromString(proxy.Username)
+	if err != nil {
+		return err
+	}
+	password16, err := windows.UTF16PtrFromString(proxy.Password)
+	if err != nil {
+		return err
+	}
+	return winHttpSetCredentials(response.handle, _WINHTTP_AUTH_TARGET_PROXY, scheme, username16, password16, 0)
+}
+
 func (response *Response) Length() (length uint64, err error) {
 	defer convertError(&err)
 	numBuf := make([]uint16, 22)
@@ -202,7 +276,7 @@ func (response *Response) Read(p []byte) (n int, err error) {
 	var bytesRead uint32
 	err = winHttpReadData(response.handle, &p[0], uint32(len(p)), &bytesRead)
 	if err != nil {
-		return 0, nil
+		return 0, err
 	}
 	if bytesRead == 0 || int(bytesRead) < 0 {
 		return 0, io.EOF
@@ -219,6 +293,13 @@ func (response *Response) Close() (err error) {
 	return winHttpCloseHandle(handle)
 }
 
+func (status StatusError) Error() string {
+	if status == _HTTP_STATUS_PROXY_AUTH_REQ {
+		return "Proxy authentication required"
+	}
+	return fmt.Sprintf("HTTP status %d", uint32(status))
+}
+
 func (error Error) Error() string {
 	var message [2048]uint16
 	n, err := windows.FormatMessage(windows.FORMAT_MESSAGE_FROM_HMODULE|windows.FORMAT_MESSAGE_IGNORE_INSERTS|windows.FORMAT_MESSAGE_MAX_WIDTH_MASK,
@@ -45,11 +45,13 @@ var (
 	procWinHttpCrackUrl           = modwinhttp.NewProc("WinHttpCrackUrl")
 	procWinHttpOpen               = modwinhttp.NewProc("WinHttpOpen")
 	procWinHttpOpenRequest        = modwinhttp.NewProc("WinHttpOpenRequest")
+	procWinHttpQueryAuthSchemes   = modwinhttp.NewProc("WinHttpQueryAuthSchemes")
 	procWinHttpQueryDataAvailable = modwinhttp.NewProc("WinHttpQueryDataAvailable")
 	procWinHttpQueryHeaders       = modwinhttp.NewProc("WinHttpQueryHeaders")
 	procWinHttpReadData           = modwinhttp.NewProc("WinHttpReadData")
 	procWinHttpReceiveResponse    = modwinhttp.NewProc("WinHttpReceiveResponse")
 	procWinHttpSendRequest        = modwinhttp.NewProc("WinHttpSendRequest")
+	procWinHttpSetCredentials     = modwinhttp.NewProc("WinHttpSetCredentials")
 	procWinHttpSetOption          = modwinhttp.NewProc("WinHttpSetOption")
 	procWinHttpSetStatusCallback  = modwinhttp.NewProc("WinHttpSetStatusCallback")
 )
@@ -97,6 +99,14 @@ func winHttpOpenRequest(connectHandle _HINTERNET, verb *uint16, objectName *uint
 	return
 }
 
+func winHttpQueryAuthSchemes(requestHandle _HINTERNET, supportedSchemes *uint32, firstScheme *uint32, authTarget *uint32) (err error) {
+	r1, _, e1 := syscall.Syscall6(procWinHttpQueryAuthSchemes.Addr(), 4, uintptr(requestHandle), uintptr(unsafe.Pointer(supportedSchemes)), uintptr(unsafe.Pointer(firstScheme)), uintptr(unsafe.Pointer(authTarget)), 0, 0)
+	if r1 == 0 {
+		err = errnoErr(e1)
+	}
+	return
+}
+
 func winHttpQueryDataAvailable(requestHandle _HINTERNET, bytesAvailable *uint32) (err error) {
 	r1, _, e1 := syscall.Syscall(procWinHttpQueryDataAvailable.Addr(), 2, uintptr(requestHandle), uintptr(unsafe.Pointer(bytesAvailable)), 0)
 	if r1 == 0 {
@@ -137,6 +147,14 @@ func winHttpSendRequest(requestHandle _HINTERNET, headers *uint16, headersLength
 	return
 }
 
+func winHttpSetCredentials(requestHandle _HINTERNET, authTargets uint32, authScheme uint32, username *uint16, password *uint16, params uintptr) (err error) {
+	r1, _, e1 := syscall.Syscall6(procWinHttpSetCredentials.Addr(), 6, uintptr(requestHandle), uintptr(authTargets), uintptr(authScheme), uintptr(unsafe.Pointer(username)), uintptr(unsafe.Pointer(password)), uintptr(params))
+	if r1 == 0 {
+		err = errnoErr(e1)
+	}
+	return
+}
+
 func winHttpSetOption(sessionOrRequestHandle _HINTERNET, option uint32, buffer unsafe.Pointer, bufferLen uint32) (err error) {
 	r1, _, e1 := syscall.Syscall6(procWinHttpSetOption.Addr(), 4, uintptr(sessionOrRequestHandle), uintptr(option), uintptr(buffer), uintptr(bufferLen), 0, 0)
 	if r1 == 0 {
@@ -0,0 +1,155 @@
+/* SPDX-License-Identifier: MIT
+ *
+ * Copyright (C) 2019-2022 WireGuard LLC. All Rights Reserved.
+ */
+
+package version
+
+import (
+	"debug/pe"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/windows/l18n"
+)
+
+// PlatformProblem explains why this build of WireGuard cannot work on this computer.
+type PlatformProblem struct {
+	Description string
+	Remedy      string
+	DownloadURL string // For the build that would work, if there is one.
+}
+
+func (problem *PlatformProblem) Error() string {
+	return problem.Description
+}
+
+const (
+	minimumMajorVersion = 6
+	minimumMinorVersion = 1
+	minimumBuildNumber  = 7601
+)
+
+func machineArch(machine uint16) string {
+	switch machine {
+	case pe.IMAGE_FILE_MACHINE_I386:
+		return "x86"
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		return "amd64"
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		return "arm64"
+	case pe.IMAGE_FILE_MACHINE_ARMNT:
+		return "arm"
+	}
+	return ""
+}
+
+func processMachine() uint16 {
+	switch runtime.GOARCH {
+	case "386":
+		return pe.IMAGE_FILE_MACHINE_I386
+	case "amd64":
+		return pe.IMAGE_FILE_MACHINE_AMD64
+	case "arm64":
+		return pe.IMAGE_FILE_MACHINE_ARM64
+	case "arm":
+		return pe.IMAGE_FILE_MACHINE_ARMNT
+	}
+	return pe.IMAGE_FILE_MACHINE_UNKNOWN
+}
+
+func downloadURL(arch string) string {
+	return fmt.Sprintf("https://download.wireguard.com/windows-client/wireguard-%s-%s.msi", arch, Number)
+}
+
+// nativeMachine returns the architecture of the operating system. Unlike IsWow64Process, which
+// only knows about 32-bit processes on 64-bit systems, it also sees through x64 emulation on ARM64.
+func nativeMachine() (uint16, error) {
+	var process, native uint16
+	err := windows.IsWow64Process2(windows.CurrentProcess(), &process, &native)
+	if err == nil {
+		return native, nil
+	}
+	if !errors.Is(err, windows.ERROR_PROC_NOT_FOUND) {
+		return 0, err
+	}
+	var wow64 bool
+	err = windows.IsWow64Process(windows.CurrentProcess(), &wow64)
+	if err != nil {
+		return 0, err
+	}
+	if wow64 {
+		// Before IsWow64Process2, the only WOW64 was x86 on amd64.
+		return pe.IMAGE_FILE_MACHINE_AMD64, nil
+	}
+	return processMachine(), nil
+}
+
+// CheckPlatform looks for the mismatches between this build and the computer that would keep
+// tunnels from working, returning the first it finds, or nil.
+func CheckPlatform() (*PlatformProblem, error) {
+	versionInfo := windows.RtlGetVersion()
+	if versionInfo.MajorVersion < minimumMajorVersion ||
+		(versionInfo.MajorVersion == minimumMajorVersion && versionInfo.MinorVersion < minimumMinorVersion) ||
+		(versionInfo.MajorVersion == minimumMajorVersion && versionInfo.MinorVersion == minimumMinorVersion && versionInfo.BuildNumber < minimumBuildNumber) {
+		return &PlatformProblem{
+			Description: l18n.Sprintf("%s is not supported by WireGuard.", OsName()),
+			Remedy:      l18n.Sprintf("WireGuard requires Windows 7 with Service Pack 1 or later. Please install Service Pack 1 or upgrade Windows."),
+		}, nil
+	}
+
+	native, err := nativeMachine()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to determine the architecture of Windows: %w", err)
+	}
+	if native != processMachine() {
+		nativeArch := machineArch(native)
+		problem := &PlatformProblem{
+			Description: l18n.Sprintf("This is the %s version of WireGuard, but Windows is %s, so it would run emulated, and its driver could not be loaded.", Arch(), nativeArch),
+			Remedy:      l18n.Sprintf("Please uninstall this version and install the %s version of WireGuard instead.", nativeArch),
+		}
+		if nativeArch == "" {
+			problem.Description = l18n.Sprintf("This is the %s version of WireGuard, which does not match the architecture of Windows.", Arch())
+			problem.Remedy = l18n.Sprintf("Please install the version of WireGuard made for this computer.")
+		} else {
+			problem.DownloadURL = downloadURL(nativeArch)
+		}
+		return problem, nil
+	}
+
+	return checkDriverArch()
+}
+
+// checkDriverArch makes sure that a driver library shipped next to the executable, rather than
+// embedded in it, was built for the same architecture.
+func checkDriverArch() (*PlatformProblem, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, nil
+	}
+	path := filepath.Join(filepath.Dir(executable), "wireguard.dll")
+	file, err := pe.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Unable to read driver library: %w", err)
+	}
+	defer file.Close()
+	if file.Machine == processMachine() {
+		return nil, nil
+	}
+	driverArch := machineArch(file.Machine)
+	if driverArch == "" {
+		driverArch = fmt.Sprintf("0x%x", file.Machine)
+	}
+	return &PlatformProblem{
+		Description: l18n.Sprintf("The driver library %s was built for %s, but this is the %s version of WireGuard.", path, driverArch, Arch()),
+		Remedy:      l18n.Sprintf("Please reinstall WireGuard, so that the driver library matches it."),
+		DownloadURL: downloadURL(Arch()),
+	}, nil
+}